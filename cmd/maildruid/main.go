@@ -2,20 +2,41 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/akhil-datla/maildruid/internal/config"
+	"github.com/akhil-datla/maildruid/internal/domain/apikey"
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/akhil-datla/maildruid/internal/domain/lockout"
+	"github.com/akhil-datla/maildruid/internal/domain/mailbox"
+	"github.com/akhil-datla/maildruid/internal/domain/mfa"
+	"github.com/akhil-datla/maildruid/internal/domain/passwordreset"
+	"github.com/akhil-datla/maildruid/internal/domain/session"
 	"github.com/akhil-datla/maildruid/internal/domain/summary"
+	"github.com/akhil-datla/maildruid/internal/domain/syncstate"
+	"github.com/akhil-datla/maildruid/internal/domain/team"
 	"github.com/akhil-datla/maildruid/internal/domain/user"
+	"github.com/akhil-datla/maildruid/internal/domain/verification"
 	"github.com/akhil-datla/maildruid/internal/infrastructure/encryption"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/imap"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/migrate"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/oauth"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/oidc"
 	"github.com/akhil-datla/maildruid/internal/infrastructure/postgres"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/signing"
 	"github.com/akhil-datla/maildruid/internal/infrastructure/smtp"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/sqlite"
 	"github.com/akhil-datla/maildruid/internal/infrastructure/wordcloud"
+	"github.com/akhil-datla/maildruid/internal/push"
 	"github.com/akhil-datla/maildruid/internal/scheduler"
 	"github.com/akhil-datla/maildruid/internal/server"
 	"github.com/spf13/cobra"
@@ -47,9 +68,84 @@ and delivers periodic summary digests to your inbox.`,
 
 	migrateCmd := &cobra.Command{
 		Use:   "migrate",
-		Short: "Run database migrations",
-		RunE:  runMigrate,
+		Short: "Manage database schema migrations",
 	}
+	migrateCmd.AddCommand(
+		&cobra.Command{
+			Use:   "up",
+			Short: "Apply all pending migrations",
+			Args:  cobra.NoArgs,
+			RunE:  runMigrateUp,
+		},
+		&cobra.Command{
+			Use:   "down",
+			Short: "Roll back the most recent migration",
+			Args:  cobra.NoArgs,
+			RunE:  runMigrateDown,
+		},
+		&cobra.Command{
+			Use:   "status",
+			Short: "Show applied and pending migrations",
+			Args:  cobra.NoArgs,
+			RunE:  runMigrateStatus,
+		},
+		&cobra.Command{
+			Use:   "to <version>",
+			Short: "Migrate up or down to the given version",
+			Args:  cobra.ExactArgs(1),
+			RunE:  runMigrateTo,
+		},
+	)
+
+	keysCmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Manage encryption and token signing keys",
+	}
+	generateKeyCmd := &cobra.Command{
+		Use:   "generate <file>",
+		Short: "Write a new private key for signing access tokens",
+		Long: `Write a new PEM-encoded private key for signing access tokens.
+
+To roll over to a new key, add it to auth.signing_keys with an
+active_from time in the future. The key is published at
+/.well-known/jwks.json right away and signs new tokens from active_from
+on. Remove the old key once token_expiry has passed after that.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runKeysGenerate,
+	}
+	generateKeyCmd.Flags().String("alg", "EdDSA", "signing algorithm: EdDSA or RS256")
+	keysCmd.AddCommand(generateKeyCmd)
+	keysCmd.AddCommand(&cobra.Command{
+		Use:   "rotate",
+		Short: "Re-encrypt all stored secrets under the primary encryption key",
+		Long: `Re-encrypt all stored secrets under auth.encryption_key.
+
+To rotate keys, move the current key into auth.previous_encryption_keys,
+configure the new key as auth.encryption_key with a new
+auth.encryption_key_id, and run this command. Once it completes, the
+previous key can be removed from the configuration.`,
+		Args: cobra.NoArgs,
+		RunE: runKeysRotate,
+	})
+
+	adminCmd := &cobra.Command{
+		Use:   "admin",
+		Short: "Manage administrator accounts",
+	}
+	adminCmd.AddCommand(
+		&cobra.Command{
+			Use:   "grant <email>",
+			Short: "Give the admin role to the user with the given email",
+			Args:  cobra.ExactArgs(1),
+			RunE:  runAdminSetRole(user.RoleAdmin),
+		},
+		&cobra.Command{
+			Use:   "revoke <email>",
+			Short: "Take the admin role away from the user with the given email",
+			Args:  cobra.ExactArgs(1),
+			RunE:  runAdminSetRole(user.RoleUser),
+		},
+	)
 
 	versionCmd := &cobra.Command{
 		Use:   "version",
@@ -59,7 +155,7 @@ and delivers periodic summary digests to your inbox.`,
 		},
 	}
 
-	rootCmd.AddCommand(serveCmd, migrateCmd, versionCmd)
+	rootCmd.AddCommand(serveCmd, migrateCmd, keysCmd, adminCmd, versionCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -101,41 +197,97 @@ func runServe(cmd *cobra.Command, args []string) error {
 	logger.Info("starting MailDruid", "version", version)
 
 	// Initialize encryption
-	enc, err := encryption.New([]byte(cfg.Auth.EncryptionKey))
+	enc, err := newEncryptor(cfg.Auth)
 	if err != nil {
 		return fmt.Errorf("initializing encryption: %w", err)
 	}
 
+	tokenKeys, err := newTokenKeys(cfg.Auth)
+	if err != nil {
+		return fmt.Errorf("loading token signing keys: %w", err)
+	}
+	if id := tokenKeys.SigningKeyID(); id != "" {
+		logger.Info("signing access tokens with asymmetric keys", "key_id", id, "keys", len(cfg.Auth.SigningKeys))
+	}
+
 	// Connect to database
-	db, err := postgres.New(cfg.Database, logger)
+	db, repos, err := openDatabase(cfg.Database, logger)
 	if err != nil {
 		return fmt.Errorf("connecting to database: %w", err)
 	}
 	defer db.Close()
 
-	// Run migrations
-	if err := db.Migrate(); err != nil {
-		return fmt.Errorf("running migrations: %w", err)
+	if err := ensureSchema(cmd.Context(), db, cfg.Database.AutoMigrate, logger); err != nil {
+		return err
 	}
 
 	// Initialize services
-	userRepo := postgres.NewUserRepository(db)
-	userSvc := user.NewService(userRepo, enc, logger)
+	auditSvc := audit.NewService(repos.audit, logger)
+	userSvc := user.NewService(repos.users, enc, auditSvc, logger)
+	mailboxSvc := mailbox.NewService(repos.mailboxes, enc, auditSvc, logger)
+	sessionSvc := session.NewService(repos.sessions, userSvc, cfg.Auth.RefreshTokenExpiry, logger)
+	mfaSvc := mfa.NewService(repos.mfa, userSvc, enc, cfg.Auth.TOTPIssuer, logger)
+	apiKeySvc := apikey.NewService(repos.apiKeys, logger)
+	mailer := smtp.New(cfg.SMTP)
+	publicURL := strings.TrimSuffix(cfg.Server.PublicURL, "/")
+	lockoutSvc := lockout.NewService(repos.logins, userSvc, auditSvc, mailer, lockoutPolicy(cfg.Auth.Lockout),
+		publicURL+"/unlock", logger)
+	verifySvc := verification.NewService(repos.verify, userSvc, mailer, publicURL+"/api/v1/verify-email", logger)
+	resetSvc := passwordreset.NewService(repos.resets, userSvc, mailer, publicURL+"/reset-password", logger)
+	teamSvc := team.NewService(repos.teams, userSvc, mailboxSvc, auditSvc, logger)
+
+	var oidcProvider *oidc.Provider
+	if cfg.Auth.OIDC.Enabled {
+		oidcProvider, err = oidc.New(cmd.Context(), cfg.Auth.OIDC)
+		if err != nil {
+			return fmt.Errorf("initializing single sign-on: %w", err)
+		}
+		logger.Info("single sign-on enabled", "issuer", cfg.Auth.OIDC.IssuerURL)
+	}
+
+	mailProviders := oauth.New(cfg.IMAP.OAuth2)
+	if names := mailProviders.Names(); len(names) > 0 {
+		logger.Info("mailbox OAuth2 providers configured", "providers", names)
+	}
 
 	// Locate font file relative to executable or CWD
 	fontPath := findFontPath()
 	generator := wordcloud.New(fontPath)
-	summarySvc := summary.NewService(userSvc, generator, logger)
-
-	mailer := smtp.New(cfg.SMTP)
-
-	sched := scheduler.New(userSvc, summarySvc, mailer, logger)
+	sessions := imap.NewPool(imap.PoolConfig{
+		MaxPerHost:    cfg.IMAP.Pool.MaxPerHost,
+		MaxIdlePerKey: cfg.IMAP.Pool.MaxIdlePerMailbox,
+		IdleTimeout:   cfg.IMAP.Pool.IdleTimeout,
+	})
+	summarySvc := summary.NewService(userSvc, mailboxSvc, teamSvc, mailProviders, generator, repos.digests, repos.syncStates,
+		imap.Timeouts{Dial: cfg.IMAP.DialTimeout, Command: cfg.IMAP.CommandTimeout}, sessions, fetchLimits(cfg.IMAP.Fetch), logger)
+
+	sched := scheduler.New(userSvc, teamSvc, summarySvc, mailer, logger)
 	if err := sched.LoadExisting(cmd.Context()); err != nil {
 		logger.Warn("failed to load existing tasks", "error", err)
 	}
 
+	var pusher *push.Supervisor
+	if cfg.IMAP.Push.Enabled {
+		dial := func(ctx context.Context, mb *mailbox.Mailbox) (push.Conn, error) {
+			im, err := summarySvc.Dial(ctx, mb)
+			if err != nil {
+				return nil, err
+			}
+			return im, nil
+		}
+		pusher = push.New(cfg.IMAP.Push, mailboxSvc.Watched, dial, summarySvc.Progress, sched.Push, logger)
+		pusher.Start()
+	}
+	stopWorkers := func() {
+		if pusher != nil {
+			pusher.Stop()
+		}
+		sched.Stop()
+		sessions.Close()
+	}
+
 	// Create and start server
-	srv := server.New(*cfg, db, userSvc, summarySvc, sched, logger)
+	srv := server.New(*cfg, db, userSvc, mailboxSvc, sessionSvc, tokenKeys, mfaSvc, lockoutSvc, verifySvc, resetSvc, apiKeySvc, auditSvc, teamSvc, oidcProvider, mailProviders, summarySvc, sched, logger)
 
 	// Graceful shutdown
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
@@ -149,18 +301,101 @@ func runServe(cmd *cobra.Command, args []string) error {
 	select {
 	case <-ctx.Done():
 		logger.Info("shutdown signal received")
-		sched.Stop()
+		stopWorkers()
 		if err := srv.Shutdown(context.Background()); err != nil {
 			logger.Error("server shutdown error", "error", err)
 		}
+		resetSvc.Wait()
+		lockoutSvc.Wait()
 		return nil
 	case err := <-errCh:
-		sched.Stop()
+		stopWorkers()
 		return err
 	}
 }
 
-func runMigrate(cmd *cobra.Command, args []string) error {
+// lockoutPolicy maps the lockout configuration onto the domain policy.
+func lockoutPolicy(cfg config.LockoutConfig) lockout.Policy {
+	return lockout.Policy{
+		Enabled:          cfg.Enabled,
+		AccountThreshold: cfg.AccountThreshold,
+		IPThreshold:      cfg.IPThreshold,
+		Window:           cfg.Window,
+		LockoutDuration:  cfg.Duration,
+		FreeAttempts:     cfg.FreeAttempts,
+		MaxDelay:         cfg.MaxDelay,
+	}
+}
+
+func fetchLimits(cfg config.FetchConfig) imap.Limits {
+	return imap.Limits{
+		BatchSize:       cfg.BatchSize,
+		MaxMessageBytes: cfg.MaxMessageBytes,
+		MaxTotalBytes:   cfg.MaxTotalBytes,
+	}
+}
+
+// newEncryptor builds the encryption keyring from the auth configuration.
+func newEncryptor(cfg config.AuthConfig) (*encryption.Service, error) {
+	primaryID := cfg.EncryptionKeyID
+	if primaryID == "" {
+		primaryID = encryption.DefaultKeyID
+	}
+	previous := make([]encryption.Key, 0, len(cfg.PreviousEncryptionKeys))
+	for _, k := range cfg.PreviousEncryptionKeys {
+		previous = append(previous, encryption.Key{ID: k.ID, Secret: []byte(k.Key)})
+	}
+	return encryption.NewKeyring(
+		encryption.Key{ID: primaryID, Secret: []byte(cfg.EncryptionKey)},
+		previous,
+		cfg.LegacyEncryptionKeyID,
+	)
+}
+
+// newTokenKeys loads the access token signing keys, falling back to HS256
+// with the shared signing key when no asymmetric keys are configured.
+func newTokenKeys(cfg config.AuthConfig) (*signing.KeySet, error) {
+	if len(cfg.SigningKeys) == 0 {
+		return signing.NewHMAC([]byte(cfg.SigningKey)), nil
+	}
+	keys := make([]signing.Key, 0, len(cfg.SigningKeys))
+	for _, k := range cfg.SigningKeys {
+		data, err := os.ReadFile(k.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading key %q: %w", k.ID, err)
+		}
+		priv, err := signing.ParsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", k.ID, err)
+		}
+		keys = append(keys, signing.Key{ID: k.ID, Private: priv, ActiveFrom: k.ActiveFrom})
+	}
+	return signing.NewKeySet(keys)
+}
+
+func runKeysGenerate(cmd *cobra.Command, args []string) error {
+	alg, _ := cmd.Flags().GetString("alg")
+	data, err := signing.GenerateKey(alg)
+	if err != nil {
+		return err
+	}
+	// Never overwrite a key that may still be in use.
+	f, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("creating key file: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("writing key: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing key: %w", err)
+	}
+	fmt.Printf("Wrote %s private key to %s\n", alg, args[0])
+	return nil
+}
+
+func runKeysRotate(cmd *cobra.Command, args []string) error {
 	cfg, err := config.Load(cfgFile)
 	if err != nil {
 		return fmt.Errorf("loading config: %w", err)
@@ -168,20 +403,283 @@ func runMigrate(cmd *cobra.Command, args []string) error {
 
 	logger := setupLogger(cfg.Log)
 
-	db, err := postgres.New(cfg.Database, logger)
+	enc, err := newEncryptor(cfg.Auth)
+	if err != nil {
+		return fmt.Errorf("initializing encryption: %w", err)
+	}
+
+	db, repos, err := openDatabase(cfg.Database, logger)
 	if err != nil {
 		return fmt.Errorf("connecting to database: %w", err)
 	}
 	defer db.Close()
 
-	if err := db.Migrate(); err != nil {
-		return fmt.Errorf("running migrations: %w", err)
+	if err := ensureSchema(cmd.Context(), db, false, logger); err != nil {
+		return err
+	}
+
+	auditSvc := audit.NewService(repos.audit, logger)
+	userSvc := user.NewService(repos.users, enc, auditSvc, logger)
+	rotated, err := userSvc.RotateSecrets(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("rotating secrets (%d users updated before failure): %w", rotated, err)
+	}
+
+	mailboxSvc := mailbox.NewService(repos.mailboxes, enc, auditSvc, logger)
+	rotatedMailboxes, err := mailboxSvc.RotateSecrets(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("rotating mailbox credentials (%d updated before failure): %w", rotatedMailboxes, err)
+	}
+
+	mfaSvc := mfa.NewService(repos.mfa, userSvc, enc, cfg.Auth.TOTPIssuer, logger)
+	rotatedTOTP, err := mfaSvc.RotateSecrets(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("rotating TOTP secrets (%d updated before failure): %w", rotatedTOTP, err)
+	}
+
+	logger.Info("rotated user secrets", "key_id", enc.PrimaryKeyID(), "users_updated", rotated, "mailboxes_updated", rotatedMailboxes, "totp_secrets_updated", rotatedTOTP)
+	return nil
+}
+
+// runAdminSetRole returns a command that assigns role to the user whose
+// email is given as the only argument.
+func runAdminSetRole(role string) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load(cfgFile)
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+
+		logger := setupLogger(cfg.Log)
+
+		enc, err := newEncryptor(cfg.Auth)
+		if err != nil {
+			return fmt.Errorf("initializing encryption: %w", err)
+		}
+
+		db, repos, err := openDatabase(cfg.Database, logger)
+		if err != nil {
+			return fmt.Errorf("connecting to database: %w", err)
+		}
+		defer db.Close()
+
+		if err := ensureSchema(cmd.Context(), db, false, logger); err != nil {
+			return err
+		}
+
+		userSvc := user.NewService(repos.users, enc, audit.NewService(repos.audit, logger), logger)
+		if _, err := userSvc.SetRoleByEmail(cmd.Context(), args[0], role); err != nil {
+			if errors.Is(err, user.ErrNotFound) {
+				return fmt.Errorf("no user with email %s", args[0])
+			}
+			return err
+		}
+		return nil
+	}
+}
+
+// ensureSchema verifies the database is at the latest migration version,
+// applying pending migrations first when autoMigrate is set.
+func ensureSchema(ctx context.Context, db database, autoMigrate bool, logger *slog.Logger) error {
+	m, err := db.Migrator()
+	if err != nil {
+		return fmt.Errorf("loading migrations: %w", err)
+	}
+
+	if autoMigrate {
+		applied, err := m.Up(ctx)
+		if err != nil {
+			return fmt.Errorf("running migrations: %w", err)
+		}
+		for _, mig := range applied {
+			logger.Info("applied migration", "version", mig.Version, "name", mig.Name)
+		}
+		return nil
 	}
 
-	logger.Info("migrations completed successfully")
+	current, err := m.Current(ctx)
+	if err != nil {
+		return fmt.Errorf("checking schema version: %w", err)
+	}
+	if current > m.Latest() {
+		return fmt.Errorf("database schema version %d is newer than this binary supports (%d)", current, m.Latest())
+	}
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return fmt.Errorf("checking schema version: %w", err)
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is behind (version %d, latest %d): run `maildruid migrate up` or set database.auto_migrate", current, m.Latest())
+	}
 	return nil
 }
 
+// withMigrator loads config, connects to the database and passes its
+// migrator to fn.
+func withMigrator(cmd *cobra.Command, fn func(ctx context.Context, m *migrate.Migrator, logger *slog.Logger) error) error {
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	logger := setupLogger(cfg.Log)
+
+	db, _, err := openDatabase(cfg.Database, logger)
+	if err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
+	}
+	defer db.Close()
+
+	m, err := db.Migrator()
+	if err != nil {
+		return fmt.Errorf("loading migrations: %w", err)
+	}
+
+	return fn(cmd.Context(), m, logger)
+}
+
+func runMigrateUp(cmd *cobra.Command, args []string) error {
+	return withMigrator(cmd, func(ctx context.Context, m *migrate.Migrator, logger *slog.Logger) error {
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			logger.Info("applied migration", "version", mig.Version, "name", mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			logger.Info("database is up to date", "version", m.Latest())
+		}
+		return nil
+	})
+}
+
+func runMigrateDown(cmd *cobra.Command, args []string) error {
+	return withMigrator(cmd, func(ctx context.Context, m *migrate.Migrator, logger *slog.Logger) error {
+		reverted, err := m.Down(ctx)
+		if err != nil {
+			return err
+		}
+		if reverted == nil {
+			logger.Info("no migrations to roll back")
+			return nil
+		}
+		logger.Info("rolled back migration", "version", reverted.Version, "name", reverted.Name)
+		return nil
+	})
+}
+
+func runMigrateStatus(cmd *cobra.Command, args []string) error {
+	return withMigrator(cmd, func(ctx context.Context, m *migrate.Migrator, logger *slog.Logger) error {
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		current, err := m.Current(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := cmd.OutOrStdout()
+		fmt.Fprintf(w, "Current version: %d (latest: %d)\n\n", current, m.Latest())
+		for _, st := range statuses {
+			applied := "pending"
+			if st.Applied {
+				applied = "applied " + st.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d  %-40s %s\n", st.Version, st.Name, applied)
+		}
+		return nil
+	})
+}
+
+func runMigrateTo(cmd *cobra.Command, args []string) error {
+	version, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("version must be a number: %w", err)
+	}
+	return withMigrator(cmd, func(ctx context.Context, m *migrate.Migrator, logger *slog.Logger) error {
+		run, err := m.To(ctx, version)
+		for _, mig := range run {
+			logger.Info("migrated", "version", mig.Version, "name", mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		logger.Info("database migrated", "version", version)
+		return nil
+	})
+}
+
+// database is the storage backend selected by database.driver.
+type database interface {
+	Migrator() (*migrate.Migrator, error)
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// repositories holds the driver-specific implementations of the domain
+// repositories.
+type repositories struct {
+	users      user.Repository
+	mailboxes  mailbox.Repository
+	digests    summary.Repository
+	syncStates syncstate.Repository
+	sessions   session.Repository
+	apiKeys    apikey.Repository
+	mfa        mfa.Repository
+	audit      audit.Repository
+	logins     lockout.Repository
+	verify     verification.Repository
+	resets     passwordreset.Repository
+	teams      team.Repository
+}
+
+// openDatabase connects to the configured database driver and returns it
+// together with the matching repositories.
+func openDatabase(cfg config.DatabaseConfig, logger *slog.Logger) (database, *repositories, error) {
+	switch cfg.Driver {
+	case "sqlite":
+		db, err := sqlite.New(cfg, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		return db, &repositories{
+			users:      sqlite.NewUserRepository(db),
+			mailboxes:  sqlite.NewMailboxRepository(db),
+			digests:    sqlite.NewDigestRepository(db),
+			syncStates: sqlite.NewSyncStateRepository(db),
+			sessions:   sqlite.NewSessionRepository(db),
+			apiKeys:    sqlite.NewAPIKeyRepository(db),
+			mfa:        sqlite.NewMFARepository(db),
+			audit:      sqlite.NewAuditRepository(db),
+			logins:     sqlite.NewLoginAttemptRepository(db),
+			verify:     sqlite.NewVerificationRepository(db),
+			resets:     sqlite.NewPasswordResetRepository(db),
+			teams:      sqlite.NewTeamRepository(db),
+		}, nil
+	default:
+		db, err := postgres.New(cfg, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		return db, &repositories{
+			users:      postgres.NewUserRepository(db),
+			mailboxes:  postgres.NewMailboxRepository(db),
+			digests:    postgres.NewDigestRepository(db),
+			syncStates: postgres.NewSyncStateRepository(db),
+			sessions:   postgres.NewSessionRepository(db),
+			apiKeys:    postgres.NewAPIKeyRepository(db),
+			mfa:        postgres.NewMFARepository(db),
+			audit:      postgres.NewAuditRepository(db),
+			logins:     postgres.NewLoginAttemptRepository(db),
+			verify:     postgres.NewVerificationRepository(db),
+			resets:     postgres.NewPasswordResetRepository(db),
+			teams:      postgres.NewTeamRepository(db),
+		}, nil
+	}
+}
+
 func findFontPath() string {
 	// Check relative to executable
 	exe, err := os.Executable()
@@ -2,9 +2,11 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"strings"
 	"time"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 )
 
@@ -14,6 +16,7 @@ type Config struct {
 	Database DatabaseConfig `mapstructure:"database"`
 	SMTP     SMTPConfig     `mapstructure:"smtp"`
 	Auth     AuthConfig     `mapstructure:"auth"`
+	IMAP     IMAPConfig     `mapstructure:"imap"`
 	Log      LogConfig      `mapstructure:"log"`
 }
 
@@ -23,15 +26,25 @@ type ServerConfig struct {
 	WriteTimeout time.Duration `mapstructure:"write_timeout"`
 	AllowOrigins []string      `mapstructure:"allow_origins"`
 	RateLimit    float64       `mapstructure:"rate_limit"`
+	// PublicURL is the externally reachable base URL of the server, used
+	// for links in emails.
+	PublicURL string `mapstructure:"public_url"`
+	// TrustedProxies lists the CIDRs of reverse proxies whose
+	// X-Forwarded-For header is believed. When empty, the client IP is
+	// the address of the connecting peer.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 }
 
 type DatabaseConfig struct {
-	Host     string `mapstructure:"host"`
-	Port     int    `mapstructure:"port"`
-	User     string `mapstructure:"user"`
-	Password string `mapstructure:"password"`
-	Name     string `mapstructure:"name"`
-	SSLMode  string `mapstructure:"sslmode"`
+	Driver      string `mapstructure:"driver"`
+	Path        string `mapstructure:"path"`
+	Host        string `mapstructure:"host"`
+	Port        int    `mapstructure:"port"`
+	User        string `mapstructure:"user"`
+	Password    string `mapstructure:"password"`
+	Name        string `mapstructure:"name"`
+	SSLMode     string `mapstructure:"sslmode"`
+	AutoMigrate bool   `mapstructure:"auto_migrate"`
 }
 
 func (d DatabaseConfig) DSN() string {
@@ -49,9 +62,160 @@ type SMTPConfig struct {
 }
 
 type AuthConfig struct {
-	SigningKey    string        `mapstructure:"signing_key"`
-	EncryptionKey string        `mapstructure:"encryption_key"`
-	TokenExpiry  time.Duration `mapstructure:"token_expiry"`
+	SigningKey string `mapstructure:"signing_key"`
+	// SigningKeys are asymmetric keys for access tokens, published at
+	// /.well-known/jwks.json. When empty, access tokens are signed with
+	// HS256 and SigningKey.
+	SigningKeys     []SigningKey `mapstructure:"signing_keys"`
+	EncryptionKey   string       `mapstructure:"encryption_key"`
+	EncryptionKeyID string       `mapstructure:"encryption_key_id"`
+	// PreviousEncryptionKeys remain available for decryption after a key
+	// rotation until `maildruid keys rotate` has re-encrypted everything.
+	PreviousEncryptionKeys []EncryptionKey `mapstructure:"previous_encryption_keys"`
+	// LegacyEncryptionKeyID names the key that decrypts values written
+	// before ciphertexts carried a key ID. Defaults to the primary key.
+	LegacyEncryptionKeyID string        `mapstructure:"legacy_encryption_key_id"`
+	TokenExpiry           time.Duration `mapstructure:"token_expiry"`
+	RefreshTokenExpiry    time.Duration `mapstructure:"refresh_token_expiry"`
+	// Require2FA makes every login, by password or SSO, go through TOTP.
+	// Users who have not enrolled yet are asked to do so before they get a
+	// token.
+	Require2FA bool          `mapstructure:"require_2fa"`
+	TOTPIssuer string        `mapstructure:"totp_issuer"`
+	OIDC       OIDCConfig    `mapstructure:"oidc"`
+	Lockout    LockoutConfig `mapstructure:"lockout"`
+}
+
+// LockoutConfig configures protection against password guessing. Failed
+// logins are counted per account and per client IP.
+type LockoutConfig struct {
+	Enabled          bool `mapstructure:"enabled"`
+	AccountThreshold int  `mapstructure:"account_threshold"`
+	IPThreshold      int  `mapstructure:"ip_threshold"`
+	// Window is how long a failed attempt counts towards a lockout.
+	Window   time.Duration `mapstructure:"window"`
+	Duration time.Duration `mapstructure:"duration"`
+	// FreeAttempts failures are allowed before each further attempt is
+	// delayed, doubling from one second up to MaxDelay.
+	FreeAttempts int           `mapstructure:"free_attempts"`
+	MaxDelay     time.Duration `mapstructure:"max_delay"`
+}
+
+// OIDCConfig configures single sign-on through an OpenID Connect provider.
+type OIDCConfig struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	IssuerURL    string   `mapstructure:"issuer_url"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	RedirectURL  string   `mapstructure:"redirect_url"`
+	Scopes       []string `mapstructure:"scopes"`
+	// AutoProvision creates an account on first login for identities that
+	// do not match an existing user.
+	AutoProvision bool `mapstructure:"auto_provision"`
+	// DisablePasswordLogin turns off registration and password login so
+	// SSO is the only way in.
+	DisablePasswordLogin bool `mapstructure:"disable_password_login"`
+}
+
+// SigningKey names a PEM-encoded RSA or Ed25519 private key. The newest key
+// whose ActiveFrom has passed signs new tokens; every configured key
+// verifies them.
+type SigningKey struct {
+	ID             string    `mapstructure:"id"`
+	PrivateKeyFile string    `mapstructure:"private_key_file"`
+	ActiveFrom     time.Time `mapstructure:"active_from"`
+}
+
+// IMAPConfig configures how MailDruid signs in to users' mailboxes.
+type IMAPConfig struct {
+	// DialTimeout bounds connecting, the TLS handshake and the server's
+	// greeting; CommandTimeout bounds each command. Zero means no limit.
+	DialTimeout    time.Duration    `mapstructure:"dial_timeout"`
+	CommandTimeout time.Duration    `mapstructure:"command_timeout"`
+	OAuth2         IMAPOAuth2Config `mapstructure:"oauth2"`
+	Push           PushConfig       `mapstructure:"push"`
+	Fetch          FetchConfig      `mapstructure:"fetch"`
+	Pool           PoolConfig       `mapstructure:"pool"`
+}
+
+// PoolConfig configures the pool of signed-in IMAP sessions that folder
+// listings and summary runs reuse instead of logging in every time.
+type PoolConfig struct {
+	// MaxPerHost caps the sessions open to one server, in use or idle;
+	// 0 for no limit. Push mode connections are not counted.
+	MaxPerHost int `mapstructure:"max_per_host"`
+	// MaxIdlePerMailbox caps the unused sessions kept per mailbox; 0 turns
+	// reuse off.
+	MaxIdlePerMailbox int `mapstructure:"max_idle_per_mailbox"`
+	// IdleTimeout is how long an unused session is kept.
+	IdleTimeout time.Duration `mapstructure:"idle_timeout"`
+}
+
+// FetchConfig bounds the memory a summary run uses while reading mail. A
+// zero value means no limit.
+type FetchConfig struct {
+	// BatchSize is the number of messages downloaded per round trip.
+	BatchSize int `mapstructure:"batch_size"`
+	// MaxMessageBytes caps the bytes downloaded per message; longer
+	// messages are truncated.
+	MaxMessageBytes int `mapstructure:"max_message_bytes"`
+	// MaxTotalBytes caps the text collected per run; matching messages
+	// beyond it are skipped.
+	MaxTotalBytes int `mapstructure:"max_total_bytes"`
+}
+
+// PushConfig configures push mode, in which MailDruid keeps a connection
+// open to every mailbox that asks for it and processes new mail as it
+// arrives instead of waiting for the next scheduled run.
+type PushConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// MaxConnections caps the mailboxes this server instance watches at
+	// once; the rest wait for a free slot.
+	MaxConnections int `mapstructure:"max_connections"`
+	// PollInterval is how often servers without IDLE are polled with NOOP,
+	// and how often folders other than the watched one are checked for new
+	// mail with STATUS.
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	// Debounce collects the notifications of a burst of new messages into
+	// one run.
+	Debounce time.Duration `mapstructure:"debounce"`
+	// MinBackoff and MaxBackoff bound the delay before reconnecting a
+	// failed connection, which doubles after every failure.
+	MinBackoff time.Duration `mapstructure:"min_backoff"`
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+	// RefreshInterval is how often the set of watched mailboxes is
+	// reloaded from the database.
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+}
+
+// IMAPOAuth2Config lists the OAuth2 providers users can connect a mailbox
+// through instead of storing an IMAP password.
+type IMAPOAuth2Config struct {
+	// RedirectURL is the callback registered with every provider, normally
+	// <public_url>/api/v1/imap/oauth2/callback.
+	RedirectURL string           `mapstructure:"redirect_url"`
+	Providers   []OAuth2Provider `mapstructure:"providers"`
+}
+
+// OAuth2Provider is an OAuth2 authorization server whose access tokens the
+// IMAP server accepts. Endpoints are configurable so any provider, or a
+// local fake, can be used.
+type OAuth2Provider struct {
+	Name         string   `mapstructure:"name"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	AuthURL      string   `mapstructure:"auth_url"`
+	TokenURL     string   `mapstructure:"token_url"`
+	Scopes       []string `mapstructure:"scopes"`
+	// AuthParams are extra query parameters for the authorization URL,
+	// such as access_type=offline, which Google needs to issue a refresh
+	// token.
+	AuthParams map[string]string `mapstructure:"auth_params"`
+}
+
+type EncryptionKey struct {
+	ID  string `mapstructure:"id"`
+	Key string `mapstructure:"key"`
 }
 
 type LogConfig struct {
@@ -69,13 +233,18 @@ func Load(cfgFile string) (*Config, error) {
 	v.SetDefault("server.write_timeout", "30s")
 	v.SetDefault("server.allow_origins", []string{"*"})
 	v.SetDefault("server.rate_limit", 20)
+	v.SetDefault("server.public_url", "http://localhost:8080")
+	v.SetDefault("server.trusted_proxies", []string{})
 
+	v.SetDefault("database.driver", "postgres")
+	v.SetDefault("database.path", "maildruid.db")
 	v.SetDefault("database.host", "localhost")
 	v.SetDefault("database.port", 5432)
 	v.SetDefault("database.user", "postgres")
 	v.SetDefault("database.password", "password")
 	v.SetDefault("database.name", "maildruid")
 	v.SetDefault("database.sslmode", "disable")
+	v.SetDefault("database.auto_migrate", false)
 
 	v.SetDefault("smtp.host", "")
 	v.SetDefault("smtp.port", 587)
@@ -84,7 +253,44 @@ func Load(cfgFile string) (*Config, error) {
 
 	v.SetDefault("auth.signing_key", "")
 	v.SetDefault("auth.encryption_key", "")
-	v.SetDefault("auth.token_expiry", "24h")
+	v.SetDefault("auth.encryption_key_id", "default")
+	v.SetDefault("auth.legacy_encryption_key_id", "")
+	v.SetDefault("auth.token_expiry", "15m")
+	v.SetDefault("auth.refresh_token_expiry", "720h")
+	v.SetDefault("auth.require_2fa", false)
+	v.SetDefault("auth.totp_issuer", "MailDruid")
+	v.SetDefault("auth.lockout.enabled", true)
+	v.SetDefault("auth.lockout.account_threshold", 10)
+	v.SetDefault("auth.lockout.ip_threshold", 50)
+	v.SetDefault("auth.lockout.window", "15m")
+	v.SetDefault("auth.lockout.duration", "15m")
+	v.SetDefault("auth.lockout.free_attempts", 3)
+	v.SetDefault("auth.lockout.max_delay", "30s")
+	v.SetDefault("auth.oidc.enabled", false)
+	v.SetDefault("auth.oidc.issuer_url", "")
+	v.SetDefault("auth.oidc.client_id", "")
+	v.SetDefault("auth.oidc.client_secret", "")
+	v.SetDefault("auth.oidc.redirect_url", "")
+	v.SetDefault("auth.oidc.scopes", []string{"openid", "email", "profile"})
+	v.SetDefault("auth.oidc.auto_provision", true)
+	v.SetDefault("auth.oidc.disable_password_login", false)
+
+	v.SetDefault("imap.oauth2.redirect_url", "")
+	v.SetDefault("imap.dial_timeout", "30s")
+	v.SetDefault("imap.command_timeout", "2m")
+	v.SetDefault("imap.pool.max_per_host", 10)
+	v.SetDefault("imap.pool.max_idle_per_mailbox", 2)
+	v.SetDefault("imap.pool.idle_timeout", "5m")
+	v.SetDefault("imap.push.enabled", false)
+	v.SetDefault("imap.push.max_connections", 100)
+	v.SetDefault("imap.push.poll_interval", "1m")
+	v.SetDefault("imap.push.debounce", "5s")
+	v.SetDefault("imap.push.min_backoff", "5s")
+	v.SetDefault("imap.push.max_backoff", "5m")
+	v.SetDefault("imap.push.refresh_interval", "1m")
+	v.SetDefault("imap.fetch.batch_size", 100)
+	v.SetDefault("imap.fetch.max_message_bytes", 1<<20)
+	v.SetDefault("imap.fetch.max_total_bytes", 32<<20)
 
 	v.SetDefault("log.level", "info")
 	v.SetDefault("log.format", "text")
@@ -112,7 +318,11 @@ func Load(cfgFile string) (*Config, error) {
 	}
 
 	var cfg Config
-	if err := v.Unmarshal(&cfg); err != nil {
+	if err := v.Unmarshal(&cfg, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		mapstructure.StringToTimeHookFunc(time.RFC3339),
+	))); err != nil {
 		return nil, fmt.Errorf("unmarshaling config: %w", err)
 	}
 
@@ -133,6 +343,84 @@ func (c *Config) validate() error {
 	if len(c.Auth.EncryptionKey) != 16 && len(c.Auth.EncryptionKey) != 24 && len(c.Auth.EncryptionKey) != 32 {
 		return fmt.Errorf("auth.encryption_key must be 16, 24, or 32 bytes for AES")
 	}
+	ids := map[string]bool{c.Auth.EncryptionKeyID: true}
+	for _, k := range c.Auth.PreviousEncryptionKeys {
+		if k.ID == "" || ids[k.ID] {
+			return fmt.Errorf("auth.previous_encryption_keys: key IDs must be unique and non-empty (got %q)", k.ID)
+		}
+		if len(k.Key) != 16 && len(k.Key) != 24 && len(k.Key) != 32 {
+			return fmt.Errorf("auth.previous_encryption_keys: key %q must be 16, 24, or 32 bytes for AES", k.ID)
+		}
+		ids[k.ID] = true
+	}
+	if c.Auth.LegacyEncryptionKeyID != "" && !ids[c.Auth.LegacyEncryptionKeyID] {
+		return fmt.Errorf("auth.legacy_encryption_key_id %q does not match a configured key", c.Auth.LegacyEncryptionKeyID)
+	}
+	signingIDs := make(map[string]bool, len(c.Auth.SigningKeys))
+	for _, k := range c.Auth.SigningKeys {
+		if k.ID == "" || signingIDs[k.ID] {
+			return fmt.Errorf("auth.signing_keys: key IDs must be unique and non-empty (got %q)", k.ID)
+		}
+		if k.PrivateKeyFile == "" {
+			return fmt.Errorf("auth.signing_keys: key %q needs a private_key_file", k.ID)
+		}
+		signingIDs[k.ID] = true
+	}
+	if c.Auth.OIDC.Enabled {
+		if c.Auth.OIDC.IssuerURL == "" || c.Auth.OIDC.ClientID == "" || c.Auth.OIDC.RedirectURL == "" {
+			return fmt.Errorf("auth.oidc.issuer_url, client_id and redirect_url are required when auth.oidc.enabled is set")
+		}
+	} else if c.Auth.OIDC.DisablePasswordLogin {
+		return fmt.Errorf("auth.oidc.disable_password_login requires auth.oidc.enabled")
+	}
+	if l := c.Auth.Lockout; l.Enabled && (l.AccountThreshold < 1 || l.IPThreshold < 1 || l.Window <= 0 || l.Duration <= 0) {
+		return fmt.Errorf("auth.lockout thresholds, window and duration must be positive")
+	}
+	providers := make(map[string]bool, len(c.IMAP.OAuth2.Providers))
+	for _, p := range c.IMAP.OAuth2.Providers {
+		if p.Name == "" || providers[p.Name] {
+			return fmt.Errorf("imap.oauth2.providers: names must be unique and non-empty (got %q)", p.Name)
+		}
+		if p.ClientID == "" || p.AuthURL == "" || p.TokenURL == "" {
+			return fmt.Errorf("imap.oauth2.providers: provider %q needs a client_id, auth_url and token_url", p.Name)
+		}
+		providers[p.Name] = true
+	}
+	if len(providers) > 0 && c.IMAP.OAuth2.RedirectURL == "" {
+		return fmt.Errorf("imap.oauth2.redirect_url is required when providers are configured")
+	}
+	if p := c.IMAP.Push; p.Enabled {
+		if p.MaxConnections < 1 || p.PollInterval <= 0 || p.MinBackoff <= 0 || p.RefreshInterval <= 0 {
+			return fmt.Errorf("imap.push max_connections, poll_interval, min_backoff and refresh_interval must be positive")
+		}
+		if p.MaxBackoff < p.MinBackoff {
+			return fmt.Errorf("imap.push.max_backoff must be at least min_backoff")
+		}
+	}
+	if c.IMAP.DialTimeout < 0 || c.IMAP.CommandTimeout < 0 {
+		return fmt.Errorf("imap.dial_timeout and imap.command_timeout must not be negative")
+	}
+	if pool := c.IMAP.Pool; pool.MaxPerHost < 0 || pool.MaxIdlePerMailbox < 0 || pool.IdleTimeout < 0 {
+		return fmt.Errorf("imap.pool settings must not be negative")
+	}
+	f := c.IMAP.Fetch
+	if f.BatchSize < 0 || f.MaxMessageBytes < 0 || f.MaxTotalBytes < 0 {
+		return fmt.Errorf("imap.fetch limits must not be negative")
+	}
+	if f.MaxTotalBytes > 0 && f.MaxTotalBytes < f.MaxMessageBytes {
+		return fmt.Errorf("imap.fetch.max_total_bytes must be at least max_message_bytes")
+	}
+	for _, cidr := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("server.trusted_proxies: %q is not a CIDR", cidr)
+		}
+	}
+	if c.Database.Driver != "postgres" && c.Database.Driver != "sqlite" {
+		return fmt.Errorf("database.driver must be postgres or sqlite")
+	}
+	if c.Database.Driver == "sqlite" && c.Database.Path == "" {
+		return fmt.Errorf("database.path is required when database.driver is sqlite")
+	}
 	if c.SMTP.Email == "" {
 		return fmt.Errorf("smtp.email is required (set MAILDRUID_SMTP_EMAIL)")
 	}
@@ -2,7 +2,9 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestValidateRequiredFields(t *testing.T) {
@@ -59,3 +61,261 @@ func TestLoadFromEnv(t *testing.T) {
 		t.Errorf("expected signing key 'test-key', got %q", cfg.Auth.SigningKey)
 	}
 }
+
+func TestValidateDatabaseDriver(t *testing.T) {
+	cfg := &Config{
+		Database: DatabaseConfig{Driver: "mysql"},
+		Auth: AuthConfig{
+			SigningKey:    "test-signing-key",
+			EncryptionKey: "0123456789abcdef",
+		},
+		SMTP: SMTPConfig{
+			Email:    "test@test.com",
+			Password: "pass",
+			Host:     "smtp.test.com",
+		},
+	}
+	if err := cfg.validate(); err == nil {
+		t.Error("expected error for unsupported database driver")
+	}
+
+	cfg.Database = DatabaseConfig{Driver: "sqlite", Path: "maildruid.db"}
+	if err := cfg.validate(); err != nil {
+		t.Errorf("unexpected error for sqlite driver: %v", err)
+	}
+}
+
+func TestValidatePreviousEncryptionKeys(t *testing.T) {
+	valid := func() *Config {
+		return &Config{
+			Database: DatabaseConfig{Driver: "postgres"},
+			Auth: AuthConfig{
+				SigningKey:      "test-signing-key",
+				EncryptionKey:   "0123456789abcdef",
+				EncryptionKeyID: "2026",
+				PreviousEncryptionKeys: []EncryptionKey{
+					{ID: "2025", Key: "fedcba9876543210"},
+				},
+				LegacyEncryptionKeyID: "2025",
+			},
+			SMTP: SMTPConfig{
+				Email:    "test@test.com",
+				Password: "pass",
+				Host:     "smtp.test.com",
+			},
+		}
+	}
+
+	if err := valid().validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg := valid()
+	cfg.Auth.PreviousEncryptionKeys[0].ID = "2026"
+	if err := cfg.validate(); err == nil {
+		t.Error("expected error for duplicate key ID")
+	}
+
+	cfg = valid()
+	cfg.Auth.PreviousEncryptionKeys[0].Key = "short"
+	if err := cfg.validate(); err == nil {
+		t.Error("expected error for invalid previous key length")
+	}
+
+	cfg = valid()
+	cfg.Auth.LegacyEncryptionKeyID = "2024"
+	if err := cfg.validate(); err == nil {
+		t.Error("expected error for unknown legacy key ID")
+	}
+}
+
+func TestValidateOIDC(t *testing.T) {
+	cfg := &Config{
+		Database: DatabaseConfig{Driver: "postgres"},
+		Auth: AuthConfig{
+			SigningKey:    "test-signing-key",
+			EncryptionKey: "0123456789abcdef",
+			OIDC:          OIDCConfig{Enabled: true, IssuerURL: "https://id.test.com"},
+		},
+		SMTP: SMTPConfig{
+			Email:    "test@test.com",
+			Password: "pass",
+			Host:     "smtp.test.com",
+		},
+	}
+	if err := cfg.validate(); err == nil {
+		t.Error("expected error for OIDC without client ID and redirect URL")
+	}
+
+	cfg.Auth.OIDC.ClientID = "maildruid"
+	cfg.Auth.OIDC.RedirectURL = "https://maildruid.test.com/api/v1/auth/oidc/callback"
+	if err := cfg.validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	cfg.Auth.OIDC = OIDCConfig{DisablePasswordLogin: true}
+	if err := cfg.validate(); err == nil {
+		t.Error("expected error for disabling password login without OIDC")
+	}
+}
+
+func TestLoadSigningKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `
+auth:
+  signing_key: test-key
+  encryption_key: 0123456789abcdef
+  signing_keys:
+    - id: 2026-01
+      private_key_file: /etc/maildruid/jwt-2026-01.pem
+    - id: 2026-07
+      private_key_file: /etc/maildruid/jwt-2026-07.pem
+      active_from: 2026-07-01T00:00:00Z
+smtp:
+  email: test@test.com
+  password: pass
+  host: smtp.test.com
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	keys := cfg.Auth.SigningKeys
+	if len(keys) != 2 || keys[0].ID != "2026-01" || !keys[0].ActiveFrom.IsZero() {
+		t.Fatalf("unexpected signing keys %+v", keys)
+	}
+	if want := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC); !keys[1].ActiveFrom.Equal(want) {
+		t.Errorf("expected active_from %s, got %s", want, keys[1].ActiveFrom)
+	}
+
+	cfg.Auth.SigningKeys[1].ID = "2026-01"
+	if err := cfg.validate(); err == nil {
+		t.Error("expected an error for duplicate signing key IDs")
+	}
+}
+
+func TestLoadIMAPOAuth2Providers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `
+auth:
+  signing_key: test-key
+  encryption_key: 0123456789abcdef
+imap:
+  oauth2:
+    redirect_url: https://maildruid.test.com/api/v1/imap/oauth2/callback
+    providers:
+      - name: google
+        client_id: maildruid
+        client_secret: secret
+        auth_url: https://accounts.google.com/o/oauth2/auth
+        token_url: https://oauth2.googleapis.com/token
+        scopes: [https://mail.google.com/]
+        auth_params:
+          access_type: offline
+          prompt: consent
+smtp:
+  email: test@test.com
+  password: pass
+  host: smtp.test.com
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	providers := cfg.IMAP.OAuth2.Providers
+	if len(providers) != 1 || providers[0].Name != "google" || providers[0].AuthParams["access_type"] != "offline" {
+		t.Fatalf("unexpected providers %+v", providers)
+	}
+
+	cfg.IMAP.OAuth2.RedirectURL = ""
+	if err := cfg.validate(); err == nil {
+		t.Error("expected an error for providers without a redirect URL")
+	}
+	cfg.IMAP.OAuth2.RedirectURL = "https://maildruid.test.com/api/v1/imap/oauth2/callback"
+	cfg.IMAP.OAuth2.Providers = append(cfg.IMAP.OAuth2.Providers, providers[0])
+	if err := cfg.validate(); err == nil {
+		t.Error("expected an error for duplicate provider names")
+	}
+}
+
+func TestLoadIMAPPush(t *testing.T) {
+	os.Setenv("MAILDRUID_AUTH_SIGNING_KEY", "test-key")
+	os.Setenv("MAILDRUID_AUTH_ENCRYPTION_KEY", "0123456789abcdef")
+	os.Setenv("MAILDRUID_SMTP_EMAIL", "test@test.com")
+	os.Setenv("MAILDRUID_SMTP_PASSWORD", "pass")
+	os.Setenv("MAILDRUID_SMTP_HOST", "smtp.test.com")
+	os.Setenv("MAILDRUID_IMAP_PUSH_ENABLED", "true")
+	os.Setenv("MAILDRUID_IMAP_PUSH_MAX_CONNECTIONS", "25")
+	defer func() {
+		os.Unsetenv("MAILDRUID_AUTH_SIGNING_KEY")
+		os.Unsetenv("MAILDRUID_AUTH_ENCRYPTION_KEY")
+		os.Unsetenv("MAILDRUID_SMTP_EMAIL")
+		os.Unsetenv("MAILDRUID_SMTP_PASSWORD")
+		os.Unsetenv("MAILDRUID_SMTP_HOST")
+		os.Unsetenv("MAILDRUID_IMAP_PUSH_ENABLED")
+		os.Unsetenv("MAILDRUID_IMAP_PUSH_MAX_CONNECTIONS")
+	}()
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	p := cfg.IMAP.Push
+	if !p.Enabled || p.MaxConnections != 25 || p.PollInterval != time.Minute || p.MaxBackoff != 5*time.Minute {
+		t.Fatalf("unexpected push config %+v", p)
+	}
+
+	cfg.IMAP.Push.MaxBackoff = time.Second
+	if err := cfg.validate(); err == nil {
+		t.Error("expected an error for max_backoff below min_backoff")
+	}
+	cfg.IMAP.Push = PushConfig{Enabled: true}
+	if err := cfg.validate(); err == nil {
+		t.Error("expected an error for push without a connection limit")
+	}
+}
+
+func TestLoadIMAPFetch(t *testing.T) {
+	os.Setenv("MAILDRUID_AUTH_SIGNING_KEY", "test-key")
+	os.Setenv("MAILDRUID_AUTH_ENCRYPTION_KEY", "0123456789abcdef")
+	os.Setenv("MAILDRUID_SMTP_EMAIL", "test@test.com")
+	os.Setenv("MAILDRUID_SMTP_PASSWORD", "pass")
+	os.Setenv("MAILDRUID_SMTP_HOST", "smtp.test.com")
+	os.Setenv("MAILDRUID_IMAP_FETCH_BATCH_SIZE", "20")
+	defer func() {
+		os.Unsetenv("MAILDRUID_AUTH_SIGNING_KEY")
+		os.Unsetenv("MAILDRUID_AUTH_ENCRYPTION_KEY")
+		os.Unsetenv("MAILDRUID_SMTP_EMAIL")
+		os.Unsetenv("MAILDRUID_SMTP_PASSWORD")
+		os.Unsetenv("MAILDRUID_SMTP_HOST")
+		os.Unsetenv("MAILDRUID_IMAP_FETCH_BATCH_SIZE")
+	}()
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	f := cfg.IMAP.Fetch
+	if f.BatchSize != 20 || f.MaxMessageBytes != 1<<20 || f.MaxTotalBytes != 32<<20 {
+		t.Fatalf("unexpected fetch config %+v", f)
+	}
+	if cfg.IMAP.DialTimeout != 30*time.Second || cfg.IMAP.CommandTimeout != 2*time.Minute {
+		t.Errorf("unexpected IMAP timeouts %v, %v", cfg.IMAP.DialTimeout, cfg.IMAP.CommandTimeout)
+	}
+	if p := cfg.IMAP.Pool; p.MaxPerHost != 10 || p.MaxIdlePerMailbox != 2 || p.IdleTimeout != 5*time.Minute {
+		t.Errorf("unexpected pool config %+v", p)
+	}
+
+	cfg.IMAP.Fetch.MaxTotalBytes = 1024
+	if err := cfg.validate(); err == nil {
+		t.Error("expected an error for max_total_bytes below max_message_bytes")
+	}
+}
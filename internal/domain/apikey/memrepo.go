@@ -0,0 +1,74 @@
+package apikey
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRepository is an in-memory API key repository for testing.
+type MemoryRepository struct {
+	mu   sync.RWMutex
+	keys map[string]*APIKey
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{keys: make(map[string]*APIKey)}
+}
+
+func (r *MemoryRepository) Create(_ context.Context, k *APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k.CreatedAt = time.Now()
+	cp := *k
+	r.keys[k.ID] = &cp
+	return nil
+}
+
+func (r *MemoryRepository) FindByHash(_ context.Context, hash string) (*APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, k := range r.keys {
+		if k.KeyHash == hash {
+			cp := *k
+			return &cp, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryRepository) ListByUser(_ context.Context, userID string) ([]*APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result []*APIKey
+	for _, k := range r.keys {
+		if k.UserID == userID {
+			cp := *k
+			result = append(result, &cp)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.After(result[j].CreatedAt) })
+	return result, nil
+}
+
+func (r *MemoryRepository) Delete(_ context.Context, userID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k, ok := r.keys[id]
+	if !ok || k.UserID != userID {
+		return ErrNotFound
+	}
+	delete(r.keys, id)
+	return nil
+}
+
+func (r *MemoryRepository) Touch(_ context.Context, id string, at time.Time, ip string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if k, ok := r.keys[id]; ok {
+		k.LastUsedAt = &at
+		k.LastUsedIP = ip
+	}
+	return nil
+}
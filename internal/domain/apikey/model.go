@@ -0,0 +1,46 @@
+package apikey
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Domain errors.
+var (
+	ErrNotFound     = errors.New("api key not found")
+	ErrInvalidKey   = errors.New("invalid or expired api key")
+	ErrInvalidScope = errors.New("unknown api key scope")
+)
+
+// Scopes that restrict what an API key may do. A key without scopes has the
+// same access as its owner's login session.
+const (
+	ScopeReadOnly          = "read-only"
+	ScopeSummariesGenerate = "summaries:generate"
+	ScopeSchedulesWrite    = "schedules:write"
+)
+
+// Scopes lists every valid scope.
+var Scopes = []string{ScopeReadOnly, ScopeSummariesGenerate, ScopeSchedulesWrite}
+
+// APIKey is a named, long-lived credential a user creates for scripts and
+// integrations. Only a hash of the secret is stored.
+type APIKey struct {
+	ID         string         `json:"id" gorm:"primaryKey"`
+	UserID     string         `json:"-" gorm:"index"`
+	Name       string         `json:"name"`
+	Prefix     string         `json:"prefix"` // leading characters of the key, for recognising it
+	KeyHash    string         `json:"-" gorm:"uniqueIndex"`
+	Scopes     pq.StringArray `json:"scopes" gorm:"type:text[]"`
+	ExpiresAt  *time.Time     `json:"expiresAt"`
+	LastUsedAt *time.Time     `json:"lastUsedAt"`
+	LastUsedIP string         `json:"lastUsedIp"`
+	CreatedAt  time.Time      `json:"createdAt"`
+}
+
+// Expired reports whether the key has passed its expiry at now.
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
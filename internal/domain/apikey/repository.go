@@ -0,0 +1,19 @@
+package apikey
+
+import (
+	"context"
+	"time"
+)
+
+// Repository defines persistence operations for API keys.
+type Repository interface {
+	Create(ctx context.Context, k *APIKey) error
+	// FindByHash returns the key with the given secret hash or ErrNotFound.
+	FindByHash(ctx context.Context, hash string) (*APIKey, error)
+	// ListByUser returns the user's keys, newest first.
+	ListByUser(ctx context.Context, userID string) ([]*APIKey, error)
+	// Delete removes the user's key or returns ErrNotFound.
+	Delete(ctx context.Context, userID, id string) error
+	// Touch records when and from where a key was last used.
+	Touch(ctx context.Context, id string, at time.Time, ip string) error
+}
@@ -0,0 +1,98 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setupTestService(t *testing.T) (*Service, *MemoryRepository) {
+	t.Helper()
+	repo := NewMemoryRepository()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	return NewService(repo, logger), repo
+}
+
+func TestCreateAndAuthenticate(t *testing.T) {
+	svc, repo := setupTestService(t)
+	ctx := context.Background()
+
+	k, key, err := svc.Create(ctx, "u1", CreateInput{
+		Name:   "cron",
+		Scopes: []string{ScopeReadOnly, ScopeSummariesGenerate, ScopeReadOnly},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !strings.HasPrefix(key, KeyPrefix) || !strings.HasPrefix(key, k.Prefix) {
+		t.Errorf("unexpected key %q with prefix %q", key, k.Prefix)
+	}
+	if k.KeyHash == key || strings.Contains(k.KeyHash, key) {
+		t.Error("key must be stored hashed")
+	}
+	if len(k.Scopes) != 2 {
+		t.Errorf("expected duplicate scopes to collapse, got %v", k.Scopes)
+	}
+
+	got, err := svc.Authenticate(ctx, key, "203.0.113.7")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if got.UserID != "u1" {
+		t.Errorf("expected user u1, got %q", got.UserID)
+	}
+
+	keys, _ := repo.ListByUser(ctx, "u1")
+	if len(keys) != 1 || keys[0].LastUsedAt == nil || keys[0].LastUsedIP != "203.0.113.7" {
+		t.Errorf("expected last use to be recorded, got %+v", keys[0])
+	}
+
+	if _, err := svc.Authenticate(ctx, key+"x", ""); err != ErrInvalidKey {
+		t.Errorf("expected ErrInvalidKey for wrong key, got %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, "eyJhbGciOi", ""); err != ErrInvalidKey {
+		t.Errorf("expected ErrInvalidKey for non-key token, got %v", err)
+	}
+}
+
+func TestCreateRejectsUnknownScope(t *testing.T) {
+	svc, _ := setupTestService(t)
+	_, _, err := svc.Create(context.Background(), "u1", CreateInput{Name: "bad", Scopes: []string{"admin"}})
+	if !errors.Is(err, ErrInvalidScope) {
+		t.Fatalf("expected ErrInvalidScope, got %v", err)
+	}
+}
+
+func TestExpiredKeyRejected(t *testing.T) {
+	svc, _ := setupTestService(t)
+	ctx := context.Background()
+
+	past := time.Now().Add(-time.Minute)
+	_, key, err := svc.Create(ctx, "u1", CreateInput{Name: "old", ExpiresAt: &past})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, key, ""); err != ErrInvalidKey {
+		t.Fatalf("expected ErrInvalidKey for expired key, got %v", err)
+	}
+}
+
+func TestDeleteOnlyOwnKeys(t *testing.T) {
+	svc, _ := setupTestService(t)
+	ctx := context.Background()
+
+	k, key, _ := svc.Create(ctx, "u1", CreateInput{Name: "k"})
+	if err := svc.Delete(ctx, "u2", k.ID); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound deleting another user's key, got %v", err)
+	}
+	if err := svc.Delete(ctx, "u1", k.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, key, ""); err != ErrInvalidKey {
+		t.Fatalf("expected ErrInvalidKey after revocation, got %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package audit
+
+import "context"
+
+// Source describes who caused an event and from where. It travels in the
+// request context so services deep in a call can attribute their events.
+type Source struct {
+	ActorID   string
+	IP        string
+	RequestID string
+}
+
+type sourceKey struct{}
+
+// WithSource returns a copy of ctx carrying src.
+func WithSource(ctx context.Context, src Source) context.Context {
+	return context.WithValue(ctx, sourceKey{}, src)
+}
+
+// WithActor returns a copy of ctx whose source names actorID as the actor.
+func WithActor(ctx context.Context, actorID string) context.Context {
+	src := SourceFromContext(ctx)
+	src.ActorID = actorID
+	return WithSource(ctx, src)
+}
+
+// SourceFromContext returns the source carried by ctx, if any.
+func SourceFromContext(ctx context.Context) Source {
+	src, _ := ctx.Value(sourceKey{}).(Source)
+	return src
+}
@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryRepository is an in-memory audit log for testing.
+type MemoryRepository struct {
+	mu     sync.RWMutex
+	events []Event
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{}
+}
+
+func (r *MemoryRepository) Append(_ context.Context, e *Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, *e)
+	return nil
+}
+
+func (r *MemoryRepository) List(_ context.Context, f Filter) ([]*Event, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matched []*Event
+	for i := len(r.events) - 1; i >= 0; i-- {
+		e := r.events[i]
+		if (f.UserID != "" && e.UserID != f.UserID) ||
+			(f.ActorID != "" && e.ActorID != f.ActorID) ||
+			(f.TeamID != "" && e.TeamID != f.TeamID) ||
+			(f.Action != "" && e.Action != f.Action) ||
+			(!f.Since.IsZero() && e.CreatedAt.Before(f.Since)) ||
+			(!f.Until.IsZero() && !e.CreatedAt.Before(f.Until)) {
+			continue
+		}
+		matched = append(matched, &e)
+	}
+
+	total := int64(len(matched))
+	if f.Offset >= len(matched) {
+		return nil, total, nil
+	}
+	matched = matched[f.Offset:]
+	if f.Limit > 0 && f.Limit < len(matched) {
+		matched = matched[:f.Limit]
+	}
+	return matched, total, nil
+}
+
+// Events returns a copy of every recorded event, oldest first.
+func (r *MemoryRepository) Events() []Event {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Event(nil), r.events...)
+}
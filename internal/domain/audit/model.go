@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"reflect"
+	"time"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionLoginSucceeded = "login.succeeded"
+	ActionLoginFailed    = "login.failed"
+	ActionLoginLocked    = "login.locked"
+	ActionLoginIPLocked  = "login.ip_locked"
+	ActionLoginUnlocked  = "login.unlocked"
+
+	ActionUserCreated       = "user.created"
+	ActionUserUpdated       = "user.updated"
+	ActionUserDeleted       = "user.deleted"
+	ActionPasswordChanged   = "user.password_changed"
+	ActionPasswordReset     = "user.password_reset"
+	ActionEmailVerified     = "user.receiving_email_verified"
+	ActionSessionsRevoked   = "user.sessions_revoked"
+	ActionUserDisabled      = "user.disabled"
+	ActionUserEnabled       = "user.enabled"
+	ActionRoleChanged       = "user.role_changed"
+	ActionScheduleUpdated   = "schedule.updated"
+	ActionTwoFactorEnabled  = "2fa.enabled"
+	ActionTwoFactorDisabled = "2fa.disabled"
+	ActionRecoveryCodesNew  = "2fa.recovery_codes_regenerated"
+	ActionAPIKeyCreated     = "api_key.created"
+	ActionAPIKeyDeleted     = "api_key.deleted"
+
+	ActionMailboxCreated       = "mailbox.created"
+	ActionMailboxUpdated       = "mailbox.updated"
+	ActionMailboxDeleted       = "mailbox.deleted"
+	ActionMailboxOAuthLinked   = "mailbox.oauth_linked"
+	ActionMailboxOAuthUnlinked = "mailbox.oauth_unlinked"
+
+	ActionTeamCreated              = "team.created"
+	ActionTeamUpdated              = "team.updated"
+	ActionTeamDeleted              = "team.deleted"
+	ActionTeamMemberAdded          = "team.member_added"
+	ActionTeamMemberUpdated        = "team.member_updated"
+	ActionTeamMemberRemoved        = "team.member_removed"
+	ActionTeamMailboxCreated       = "team.mailbox_created"
+	ActionTeamMailboxUpdated       = "team.mailbox_updated"
+	ActionTeamMailboxDeleted       = "team.mailbox_deleted"
+	ActionTeamMailboxOAuthLinked   = "team.mailbox_oauth_linked"
+	ActionTeamMailboxOAuthUnlinked = "team.mailbox_oauth_unlinked"
+	ActionTeamDigestCreated        = "team.digest_created"
+	ActionTeamDigestUpdated        = "team.digest_updated"
+	ActionTeamDigestDeleted        = "team.digest_deleted"
+)
+
+// Redacted replaces secret values in a diff.
+const Redacted = "[redacted]"
+
+// Event is an entry in the append-only audit log. UserID is the account
+// the event is about and is empty for events that cannot be tied to an
+// account, such as an IP lockout. ActorID is the user who caused it; it is
+// empty for unauthenticated requests and the server itself. TeamID is set
+// for changes to a team and the mailboxes and digests it shares.
+type Event struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	UserID    string    `json:"userId,omitempty" gorm:"index"`
+	ActorID   string    `json:"actorId,omitempty"`
+	TeamID    string    `json:"teamId,omitempty"`
+	Action    string    `json:"action"`
+	IP        string    `json:"ip,omitempty"`
+	RequestID string    `json:"requestId,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	Changes   Changes   `json:"changes,omitempty" gorm:"serializer:json"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (Event) TableName() string { return "audit_events" }
+
+// Change is one field of a before/after diff.
+type Change struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// Changes is a before/after diff of the fields an event touched.
+type Changes []Change
+
+// Add records field if before and after differ.
+func (c *Changes) Add(field string, before, after any) {
+	if reflect.DeepEqual(before, after) {
+		return
+	}
+	*c = append(*c, Change{Field: field, Before: before, After: after})
+}
+
+// AddSecret records that a secret field changed without its values.
+func (c *Changes) AddSecret(field string, before, after string) {
+	if before == after {
+		return
+	}
+	*c = append(*c, Change{Field: field, Before: redact(before), After: redact(after)})
+}
+
+// Has reports whether field is part of the diff.
+func (c Changes) Has(field string) bool {
+	for _, ch := range c {
+		if ch.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func redact(v string) any {
+	if v == "" {
+		return nil
+	}
+	return Redacted
+}
+
+// Filter selects events from the audit log. Zero fields match everything.
+type Filter struct {
+	UserID  string
+	ActorID string
+	TeamID  string
+	Action  string
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+	Offset  int
+}
@@ -0,0 +1,12 @@
+package audit
+
+import "context"
+
+// Repository defines persistence operations for the audit log. Events are
+// only ever appended.
+type Repository interface {
+	Append(ctx context.Context, e *Event) error
+	// List returns a page of events matching f, newest first, along with
+	// the total number of matches.
+	List(ctx context.Context, f Filter) ([]*Event, int64, error)
+}
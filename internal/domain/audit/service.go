@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// maxListLimit caps how many events one List call returns.
+const maxListLimit = 100
+
+// Service writes security-relevant events to the audit log.
+type Service struct {
+	repo   Repository
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+// NewService creates a new audit service.
+func NewService(repo Repository, logger *slog.Logger) *Service {
+	return &Service{repo: repo, logger: logger, now: time.Now}
+}
+
+// Record appends e to the audit log, filling in its ID and time. The actor,
+// IP and request ID default to the Source carried by ctx.
+func (s *Service) Record(ctx context.Context, e Event) error {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return fmt.Errorf("generating UUID: %w", err)
+	}
+	e.ID = id.String()
+	e.CreatedAt = s.now()
+
+	src := SourceFromContext(ctx)
+	if e.ActorID == "" {
+		e.ActorID = src.ActorID
+	}
+	if e.IP == "" {
+		e.IP = src.IP
+	}
+	if e.RequestID == "" {
+		e.RequestID = src.RequestID
+	}
+
+	if err := s.repo.Append(ctx, &e); err != nil {
+		return fmt.Errorf("recording audit event: %w", err)
+	}
+	s.logger.Info("audit event", "action", e.Action, "user_id", e.UserID, "actor_id", e.ActorID, "ip", e.IP, "request_id", e.RequestID)
+	return nil
+}
+
+// TryRecord records e like Record but only logs a failure, for callers
+// whose own operation has already succeeded and must not fail because the
+// audit log is unavailable.
+func (s *Service) TryRecord(ctx context.Context, e Event) {
+	if err := s.Record(ctx, e); err != nil {
+		s.logger.Error("failed to record audit event", "action", e.Action, "user_id", e.UserID, "error", err)
+	}
+}
+
+// List returns a page of events matching f, newest first, along with the
+// total number of matches.
+func (s *Service) List(ctx context.Context, f Filter) ([]*Event, int64, error) {
+	if f.Limit <= 0 || f.Limit > maxListLimit {
+		f.Limit = maxListLimit
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	return s.repo.List(ctx, f)
+}
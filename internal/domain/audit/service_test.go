@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+)
+
+func newTestService() (*Service, *time.Time) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	svc := NewService(NewMemoryRepository(), logger)
+	clock := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return clock }
+	return svc, &clock
+}
+
+func TestRecordFillsSourceFromContext(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := WithActor(WithSource(context.Background(), Source{IP: "10.0.0.1", RequestID: "req-1"}), "admin")
+
+	if err := svc.Record(ctx, Event{UserID: "u1", Action: ActionUserDisabled}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if err := svc.Record(ctx, Event{UserID: "u1", ActorID: "u1", IP: "10.0.0.2", Action: ActionLoginSucceeded}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	events, total, err := svc.List(context.Background(), Filter{UserID: "u1"})
+	if err != nil || total != 2 {
+		t.Fatalf("List: %d events, %v", total, err)
+	}
+	if e := events[1]; e.ActorID != "admin" || e.IP != "10.0.0.1" || e.RequestID != "req-1" || e.ID == "" || e.CreatedAt.IsZero() {
+		t.Errorf("expected source from context, got %+v", e)
+	}
+	if e := events[0]; e.ActorID != "u1" || e.IP != "10.0.0.2" || e.RequestID != "req-1" {
+		t.Errorf("expected explicit fields to win, got %+v", e)
+	}
+}
+
+func TestListFilters(t *testing.T) {
+	svc, clock := newTestService()
+	ctx := context.Background()
+	for _, e := range []Event{
+		{UserID: "u1", Action: ActionLoginSucceeded},
+		{UserID: "u2", Action: ActionLoginSucceeded},
+		{UserID: "u1", Action: ActionUserUpdated},
+	} {
+		*clock = clock.Add(time.Minute)
+		if err := svc.Record(ctx, e); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string // actions, newest first
+	}{
+		{"user", Filter{UserID: "u1"}, []string{ActionUserUpdated, ActionLoginSucceeded}},
+		{"action", Filter{Action: ActionLoginSucceeded}, []string{ActionLoginSucceeded, ActionLoginSucceeded}},
+		{"since", Filter{Since: start.Add(2 * time.Minute)}, []string{ActionUserUpdated, ActionLoginSucceeded}},
+		{"until", Filter{Until: start.Add(2 * time.Minute)}, []string{ActionLoginSucceeded}},
+		{"page", Filter{Limit: 1, Offset: 1}, []string{ActionLoginSucceeded}},
+	}
+	for _, tt := range tests {
+		events, _, err := svc.List(ctx, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: List: %v", tt.name, err)
+		}
+		if len(events) != len(tt.want) {
+			t.Errorf("%s: expected %d events, got %d", tt.name, len(tt.want), len(events))
+			continue
+		}
+		for i, e := range events {
+			if e.Action != tt.want[i] {
+				t.Errorf("%s: event %d is %s, want %s", tt.name, i, e.Action, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestChanges(t *testing.T) {
+	var c Changes
+	c.Add("name", "a", "a")
+	c.Add("port", 993, 143)
+	c.AddSecret("password", "hash1", "hash1")
+	c.AddSecret("imapPassword", "", "cipher")
+
+	if len(c) != 2 || !c.Has("port") || !c.Has("imapPassword") {
+		t.Fatalf("unexpected changes %+v", c)
+	}
+	if c[1].Before != nil || c[1].After != Redacted {
+		t.Errorf("expected a redacted secret, got %+v", c[1])
+	}
+}
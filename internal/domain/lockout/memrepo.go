@@ -0,0 +1,88 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryRepository is an in-memory counter repository for testing.
+type MemoryRepository struct {
+	mu       sync.Mutex
+	counters map[string]*Counter
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{counters: make(map[string]*Counter)}
+}
+
+func (r *MemoryRepository) Find(_ context.Context, subject string) (*Counter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.counters[subject]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *c
+	return &cp, nil
+}
+
+func (r *MemoryRepository) RecordFailure(_ context.Context, subject string, now, since time.Time) (*Counter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.counters[subject]
+	switch {
+	case !ok:
+		c = &Counter{Subject: subject}
+		r.counters[subject] = c
+	case c.LockedUntil != nil && !now.Before(*c.LockedUntil):
+		c.Failures, c.LockedUntil, c.UnlockTokenHash = 0, nil, ""
+	case c.LastFailureAt.Before(since):
+		c.Failures = 0
+	}
+	c.Failures++
+	c.LastFailureAt, c.UpdatedAt = now, now
+	cp := *c
+	return &cp, nil
+}
+
+func (r *MemoryRepository) Lock(_ context.Context, subject string, until time.Time, tokenHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.counters[subject]
+	if !ok {
+		return ErrNotFound
+	}
+	c.LockedUntil, c.UnlockTokenHash = &until, tokenHash
+	return nil
+}
+
+func (r *MemoryRepository) FindByUnlockToken(_ context.Context, tokenHash string) (*Counter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.counters {
+		if tokenHash != "" && c.UnlockTokenHash == tokenHash {
+			cp := *c
+			return &cp, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryRepository) Reset(_ context.Context, subject string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.counters, subject)
+	return nil
+}
+
+func (r *MemoryRepository) DeleteStale(_ context.Context, before time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k, c := range r.counters {
+		if c.UpdatedAt.Before(before) {
+			delete(r.counters, k)
+		}
+	}
+	return nil
+}
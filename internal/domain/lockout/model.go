@@ -0,0 +1,78 @@
+package lockout
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Domain errors.
+var (
+	ErrNotFound     = errors.New("login attempt counter not found")
+	ErrInvalidToken = errors.New("invalid or expired unlock token")
+)
+
+// Counter tracks recent failed logins for one account or one client IP.
+type Counter struct {
+	Subject         string `gorm:"primaryKey"` // "account:<email>" or "ip:<address>"
+	Failures        int
+	LastFailureAt   time.Time
+	LockedUntil     *time.Time
+	UnlockTokenHash string // SHA-256 of the emailed unlock token, empty for IPs
+	UpdatedAt       time.Time
+}
+
+func (Counter) TableName() string { return "login_attempts" }
+
+// Locked reports whether the subject is locked out at now.
+func (c *Counter) Locked(now time.Time) bool {
+	return c.LockedUntil != nil && now.Before(*c.LockedUntil)
+}
+
+// BlockedError is returned by Check when a login attempt is refused, either
+// because of a lockout or because the progressive delay has not elapsed.
+type BlockedError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *BlockedError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("locked out for %s", e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("too many failed attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// Policy configures when failed logins are slowed down and locked out.
+type Policy struct {
+	Enabled bool
+	// AccountThreshold and IPThreshold are the numbers of failures that
+	// lock an account or a client IP.
+	AccountThreshold int
+	IPThreshold      int
+	// Window is how long a failure is remembered: the count restarts when
+	// the previous failure is older than this.
+	Window          time.Duration
+	LockoutDuration time.Duration
+	// FreeAttempts failures are allowed before delays start. Each further
+	// failure doubles the wait, starting at one second, up to MaxDelay.
+	FreeAttempts int
+	MaxDelay     time.Duration
+}
+
+// delay returns how long an account must wait after its last failure
+// before the next attempt is accepted.
+func (p Policy) delay(failures int) time.Duration {
+	n := failures - p.FreeAttempts
+	if n <= 0 {
+		return 0
+	}
+	if n > 30 {
+		return p.MaxDelay
+	}
+	d := time.Second << (n - 1)
+	if d > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return d
+}
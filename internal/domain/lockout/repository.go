@@ -0,0 +1,30 @@
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+// Repository defines persistence operations for failed-login counters.
+// Counters live in the database so that every server instance sees the
+// same counts.
+type Repository interface {
+	// Find returns the counter for subject or ErrNotFound.
+	Find(ctx context.Context, subject string) (*Counter, error)
+	// RecordFailure atomically counts a failed attempt at now against
+	// subject and returns the updated counter. The count starts over when
+	// the previous failure happened before since or the subject's lockout
+	// has expired.
+	RecordFailure(ctx context.Context, subject string, now, since time.Time) (*Counter, error)
+	// Lock locks subject until the given time. tokenHash, if not empty,
+	// identifies an unlock token.
+	Lock(ctx context.Context, subject string, until time.Time, tokenHash string) error
+	// FindByUnlockToken returns the counter with the given unlock token
+	// hash or ErrNotFound.
+	FindByUnlockToken(ctx context.Context, tokenHash string) (*Counter, error)
+	// Reset forgets every failure recorded against subject.
+	Reset(ctx context.Context, subject string) error
+	// DeleteStale removes counters last updated before the given time.
+	// Callers pick a time far enough back that any lockout is over.
+	DeleteStale(ctx context.Context, before time.Time) error
+}
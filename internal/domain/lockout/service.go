@@ -0,0 +1,259 @@
+package lockout
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+)
+
+// unlockTokenBytes is the amount of randomness in an unlock token.
+const unlockTokenBytes = 32
+
+// Mailer delivers unlock links to locked-out users.
+type Mailer interface {
+	SendAccountUnlock(to, name, link string, until time.Time) error
+}
+
+// Service slows down and locks out repeated failed logins per account and
+// per client IP.
+type Service struct {
+	repo      Repository
+	userSvc   *user.Service
+	audit     *audit.Service
+	mailer    Mailer
+	policy    Policy
+	unlockURL string
+	logger    *slog.Logger
+	now       func() time.Time
+	pending   sync.WaitGroup
+}
+
+// NewService creates a lockout service. unlockURL is the page that
+// submits the unlock token; the token is appended as the "token" query
+// parameter.
+func NewService(
+	repo Repository,
+	userSvc *user.Service,
+	auditSvc *audit.Service,
+	mailer Mailer,
+	policy Policy,
+	unlockURL string,
+	logger *slog.Logger,
+) *Service {
+	return &Service{
+		repo:      repo,
+		userSvc:   userSvc,
+		audit:     auditSvc,
+		mailer:    mailer,
+		policy:    policy,
+		unlockURL: unlockURL,
+		logger:    logger,
+		now:       time.Now,
+	}
+}
+
+// Check decides whether a login attempt for email from ip may proceed. It
+// returns a *BlockedError if the account or IP is locked out or the
+// account's progressive delay has not elapsed yet.
+func (s *Service) Check(ctx context.Context, email, ip string) error {
+	if !s.policy.Enabled {
+		return nil
+	}
+	now := s.now()
+
+	for _, subject := range []string{accountSubject(email), ipSubject(ip)} {
+		c, err := s.repo.Find(ctx, subject)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if c.Locked(now) {
+			return &BlockedError{RetryAfter: c.LockedUntil.Sub(now), Locked: true}
+		}
+		if subject != accountSubject(email) || c.LastFailureAt.Before(now.Add(-s.policy.Window)) {
+			continue
+		}
+		if wait := c.LastFailureAt.Add(s.policy.delay(c.Failures)).Sub(now); wait > 0 {
+			return &BlockedError{RetryAfter: wait}
+		}
+	}
+	return nil
+}
+
+// Failed records a failed login for email from ip and locks the account or
+// IP once it reaches its threshold. A locked account is sent an unlock
+// link, and every lockout is written to the audit log.
+func (s *Service) Failed(ctx context.Context, email, ip string) error {
+	if !s.policy.Enabled {
+		return nil
+	}
+	now := s.now()
+	since := now.Add(-s.policy.Window)
+
+	account, err := s.repo.RecordFailure(ctx, accountSubject(email), now, since)
+	if err != nil {
+		return err
+	}
+	if account.Failures >= s.policy.AccountThreshold && !account.Locked(now) {
+		if err := s.lockAccount(ctx, email, ip, account.Failures, now); err != nil {
+			return err
+		}
+	}
+
+	if ip == "" {
+		return nil
+	}
+	client, err := s.repo.RecordFailure(ctx, ipSubject(ip), now, since)
+	if err != nil {
+		return err
+	}
+	if client.Failures >= s.policy.IPThreshold && !client.Locked(now) {
+		until := now.Add(s.policy.LockoutDuration)
+		if err := s.repo.Lock(ctx, ipSubject(ip), until, ""); err != nil {
+			return err
+		}
+		s.record(ctx, audit.Event{
+			Action: audit.ActionLoginIPLocked,
+			IP:     ip,
+			Detail: fmt.Sprintf("%d failed logins, locked until %s", client.Failures, until.UTC().Format(time.RFC3339)),
+		})
+	}
+	return nil
+}
+
+// Succeeded clears the failed-login count of the account. The IP's count
+// is kept, so one valid account cannot be used to reset it.
+func (s *Service) Succeeded(ctx context.Context, email string) error {
+	if !s.policy.Enabled {
+		return nil
+	}
+	if err := s.repo.DeleteStale(ctx, s.now().Add(-(s.policy.Window + s.policy.LockoutDuration))); err != nil {
+		s.logger.Warn("failed to purge stale login counters", "error", err)
+	}
+	return s.repo.Reset(ctx, accountSubject(email))
+}
+
+// Wait blocks until every unlock email in progress has been handled.
+func (s *Service) Wait() {
+	s.pending.Wait()
+}
+
+// Unlock lifts an account lockout using the token from an unlock email.
+// Tokens work once and only while the lockout lasts.
+func (s *Service) Unlock(ctx context.Context, token, ip string) error {
+	c, err := s.repo.FindByUnlockToken(ctx, hashToken(token))
+	if errors.Is(err, ErrNotFound) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+	if !c.Locked(s.now()) {
+		return ErrInvalidToken
+	}
+	if err := s.repo.Reset(ctx, c.Subject); err != nil {
+		return err
+	}
+
+	event := audit.Event{Action: audit.ActionLoginUnlocked, IP: ip}
+	email := strings.TrimPrefix(c.Subject, "account:")
+	if u, err := s.userSvc.GetByEmail(ctx, email); err == nil {
+		event.UserID = u.ID
+	} else {
+		event.Detail = "account " + email
+	}
+	s.record(ctx, event)
+	return nil
+}
+
+// lockAccount locks the account, then records the lockout and emails the
+// owner an unlock link in the background. Attempts against unknown emails
+// are locked the same way, and neither the response nor the time it takes
+// reveals which accounts exist.
+func (s *Service) lockAccount(ctx context.Context, email, ip string, failures int, now time.Time) error {
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+	until := now.Add(s.policy.LockoutDuration)
+	if err := s.repo.Lock(ctx, accountSubject(email), until, hashToken(token)); err != nil {
+		return err
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		s.notifyLocked(ctx, email, ip, token, failures, until)
+	}()
+	return nil
+}
+
+// notifyLocked records an account lockout and emails the unlock link to
+// the account, if there is one.
+func (s *Service) notifyLocked(ctx context.Context, email, ip, token string, failures int, until time.Time) {
+	event := audit.Event{
+		Action: audit.ActionLoginLocked,
+		IP:     ip,
+		Detail: fmt.Sprintf("%d failed logins, locked until %s", failures, until.UTC().Format(time.RFC3339)),
+	}
+	u, err := s.userSvc.GetByEmail(ctx, email)
+	if err != nil {
+		if !errors.Is(err, user.ErrNotFound) {
+			s.logger.Warn("failed to look up locked account", "error", err)
+		}
+		event.Detail += "; unknown account " + email
+		s.record(ctx, event)
+		return
+	}
+	event.UserID = u.ID
+	s.record(ctx, event)
+
+	link := s.unlockURL + "?" + url.Values{"token": {token}}.Encode()
+	if err := s.mailer.SendAccountUnlock(u.Email, u.Name, link, until); err != nil {
+		s.logger.Error("failed to send unlock email", "user_id", u.ID, "error", err)
+	}
+}
+
+// record writes an audit event, logging rather than failing the login if
+// the audit log is unavailable.
+func (s *Service) record(ctx context.Context, e audit.Event) {
+	if err := s.audit.Record(ctx, e); err != nil {
+		s.logger.Error("failed to record audit event", "action", e.Action, "error", err)
+	}
+}
+
+func accountSubject(email string) string {
+	return "account:" + email
+}
+
+func ipSubject(ip string) string {
+	return "ip:" + ip
+}
+
+func newToken() (string, error) {
+	b := make([]byte, unlockTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating unlock token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
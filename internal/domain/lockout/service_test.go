@@ -0,0 +1,253 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/encryption"
+)
+
+type sentUnlock struct {
+	to, link string
+}
+
+type fakeMailer struct {
+	sent []sentUnlock
+}
+
+func (m *fakeMailer) SendAccountUnlock(to, _, link string, _ time.Time) error {
+	m.sent = append(m.sent, sentUnlock{to: to, link: link})
+	return nil
+}
+
+var testPolicy = Policy{
+	Enabled:          true,
+	AccountThreshold: 5,
+	IPThreshold:      8,
+	Window:           15 * time.Minute,
+	LockoutDuration:  15 * time.Minute,
+	FreeAttempts:     2,
+	MaxDelay:         4 * time.Second,
+}
+
+type testEnv struct {
+	svc    *Service
+	mailer *fakeMailer
+	events *audit.MemoryRepository
+	clock  *time.Time
+}
+
+func setupTestService(t *testing.T) *testEnv {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	enc, err := encryption.New([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("encryption.New: %v", err)
+	}
+	userSvc := user.NewService(user.NewMemoryRepository(), enc, audit.NewService(audit.NewMemoryRepository(), logger), logger)
+	if err := userSvc.Create(context.Background(), user.CreateInput{
+		Name: "Test", Email: "u@ex.com", ReceivingEmail: "r@ex.com",
+		Password: "secret123",
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	events := audit.NewMemoryRepository()
+	mailer := &fakeMailer{}
+	svc := NewService(NewMemoryRepository(), userSvc, audit.NewService(events, logger), mailer,
+		testPolicy, "https://maildruid.test/unlock", logger)
+
+	clock := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	env := &testEnv{svc: svc, mailer: mailer, events: events, clock: &clock}
+	svc.now = func() time.Time { return *env.clock }
+	return env
+}
+
+func (e *testEnv) advance(d time.Duration) { *e.clock = e.clock.Add(d) }
+
+// fail waits out any delay and records a failed login.
+func (e *testEnv) fail(t *testing.T, email, ip string) {
+	t.Helper()
+	var blocked *BlockedError
+	if err := e.svc.Check(context.Background(), email, ip); errors.As(err, &blocked) && !blocked.Locked {
+		e.advance(blocked.RetryAfter)
+	}
+	if err := e.svc.Failed(context.Background(), email, ip); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	e.svc.Wait()
+}
+
+func TestProgressiveDelay(t *testing.T) {
+	env := setupTestService(t)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := env.svc.Check(ctx, "u@ex.com", "10.0.0.1"); err != nil {
+			t.Fatalf("attempt %d: expected no delay, got %v", i+1, err)
+		}
+		env.fail(t, "u@ex.com", "10.0.0.1")
+	}
+
+	for _, want := range []time.Duration{time.Second, 2 * time.Second} {
+		env.fail(t, "u@ex.com", "10.0.0.9")
+		var blocked *BlockedError
+		err := env.svc.Check(ctx, "u@ex.com", "10.0.0.1")
+		if !errors.As(err, &blocked) || blocked.Locked || blocked.RetryAfter != want {
+			t.Fatalf("expected a %s delay, got %v", want, err)
+		}
+	}
+
+	// Other accounts are not slowed down.
+	if err := env.svc.Check(ctx, "other@ex.com", "10.0.0.2"); err != nil {
+		t.Errorf("expected other account to be unaffected, got %v", err)
+	}
+}
+
+func TestAccountLockoutAndUnlock(t *testing.T) {
+	env := setupTestService(t)
+	ctx := context.Background()
+
+	for i := 0; i < testPolicy.AccountThreshold; i++ {
+		env.fail(t, "u@ex.com", "10.0.0.1")
+	}
+
+	var blocked *BlockedError
+	if err := env.svc.Check(ctx, "u@ex.com", "10.0.0.2"); !errors.As(err, &blocked) || !blocked.Locked {
+		t.Fatalf("expected account lockout, got %v", err)
+	}
+	if blocked.RetryAfter != testPolicy.LockoutDuration {
+		t.Errorf("expected retry after %s, got %s", testPolicy.LockoutDuration, blocked.RetryAfter)
+	}
+
+	events := env.events.Events()
+	if len(events) != 1 || events[0].Action != audit.ActionLoginLocked || events[0].UserID == "" || events[0].IP != "10.0.0.1" {
+		t.Fatalf("expected one lockout audit event, got %+v", events)
+	}
+
+	if len(env.mailer.sent) != 1 || env.mailer.sent[0].to != "u@ex.com" {
+		t.Fatalf("expected unlock email to u@ex.com, got %+v", env.mailer.sent)
+	}
+	link, err := url.Parse(env.mailer.sent[0].link)
+	if err != nil {
+		t.Fatalf("bad link: %v", err)
+	}
+	token := link.Query().Get("token")
+
+	if err := env.svc.Unlock(ctx, "wrong", ""); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+	if err := env.svc.Unlock(ctx, token, "10.0.0.3"); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if err := env.svc.Check(ctx, "u@ex.com", "10.0.0.2"); err != nil {
+		t.Errorf("expected unlocked account, got %v", err)
+	}
+	if err := env.svc.Unlock(ctx, token, ""); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected token to be single-use, got %v", err)
+	}
+	if events := env.events.Events(); len(events) != 2 || events[1].Action != audit.ActionLoginUnlocked {
+		t.Errorf("expected unlock audit event, got %+v", events)
+	}
+}
+
+func TestLockoutExpires(t *testing.T) {
+	env := setupTestService(t)
+	ctx := context.Background()
+
+	for i := 0; i < testPolicy.AccountThreshold; i++ {
+		env.fail(t, "nobody@ex.com", "10.0.0.1")
+	}
+	if err := env.svc.Check(ctx, "nobody@ex.com", "10.0.0.2"); err == nil {
+		t.Fatal("expected unknown accounts to be locked too")
+	}
+	if len(env.mailer.sent) != 0 {
+		t.Errorf("expected no email for an unknown account, got %+v", env.mailer.sent)
+	}
+
+	env.advance(testPolicy.LockoutDuration)
+	if err := env.svc.Check(ctx, "nobody@ex.com", "10.0.0.2"); err != nil {
+		t.Fatalf("expected lockout to expire, got %v", err)
+	}
+	// The count starts over after a lockout.
+	env.fail(t, "nobody@ex.com", "10.0.0.2")
+	if err := env.svc.Check(ctx, "nobody@ex.com", "10.0.0.2"); err != nil {
+		t.Errorf("expected a single failure not to delay, got %v", err)
+	}
+}
+
+func TestIPLockout(t *testing.T) {
+	env := setupTestService(t)
+	ctx := context.Background()
+
+	// Spread failures over many accounts, as a password-spraying client would.
+	for i := 0; i < testPolicy.IPThreshold; i++ {
+		env.fail(t, string(rune('a'+i))+"@ex.com", "10.0.0.66")
+	}
+
+	var blocked *BlockedError
+	if err := env.svc.Check(ctx, "u@ex.com", "10.0.0.66"); !errors.As(err, &blocked) || !blocked.Locked {
+		t.Fatalf("expected IP lockout, got %v", err)
+	}
+	if err := env.svc.Check(ctx, "u@ex.com", "10.0.0.1"); err != nil {
+		t.Errorf("expected other IPs to be unaffected, got %v", err)
+	}
+	events := env.events.Events()
+	if len(events) != 1 || events[0].Action != audit.ActionLoginIPLocked || events[0].IP != "10.0.0.66" {
+		t.Errorf("expected IP lockout audit event, got %+v", events)
+	}
+}
+
+func TestSucceededResetsAccount(t *testing.T) {
+	env := setupTestService(t)
+	ctx := context.Background()
+
+	for i := 0; i < testPolicy.AccountThreshold-1; i++ {
+		env.fail(t, "u@ex.com", "10.0.0.1")
+	}
+	env.advance(time.Minute)
+	if err := env.svc.Succeeded(ctx, "u@ex.com"); err != nil {
+		t.Fatalf("Succeeded: %v", err)
+	}
+	env.fail(t, "u@ex.com", "10.0.0.1")
+	if err := env.svc.Check(ctx, "u@ex.com", "10.0.0.1"); err != nil {
+		t.Errorf("expected count to restart after a successful login, got %v", err)
+	}
+}
+
+func TestDisabledPolicy(t *testing.T) {
+	env := setupTestService(t)
+	env.svc.policy.Enabled = false
+	ctx := context.Background()
+
+	for i := 0; i < 20; i++ {
+		if err := env.svc.Failed(ctx, "u@ex.com", "10.0.0.1"); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+	}
+	if err := env.svc.Check(ctx, "u@ex.com", "10.0.0.1"); err != nil {
+		t.Errorf("expected no blocking with the policy disabled, got %v", err)
+	}
+}
+
+func TestPolicyDelay(t *testing.T) {
+	p := Policy{FreeAttempts: 3, MaxDelay: 10 * time.Second}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0}, {3, 0}, {4, time.Second}, {5, 2 * time.Second}, {7, 8 * time.Second}, {8, 10 * time.Second}, {100, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := p.delay(tt.failures); got != tt.want {
+			t.Errorf("delay(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
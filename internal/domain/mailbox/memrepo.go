@@ -0,0 +1,103 @@
+package mailbox
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRepository is an in-memory mailbox repository for testing.
+type MemoryRepository struct {
+	mu        sync.RWMutex
+	mailboxes map[string]*Mailbox
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{mailboxes: make(map[string]*Mailbox)}
+}
+
+func (r *MemoryRepository) Create(_ context.Context, mb *Mailbox) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	mb.CreatedAt, mb.UpdatedAt = now, now
+	cp := *mb
+	r.mailboxes[mb.ID] = &cp
+	return nil
+}
+
+func (r *MemoryRepository) FindByID(_ context.Context, owner Owner, id string) (*Mailbox, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	mb, ok := r.mailboxes[id]
+	if !ok || !owner.owns(mb) {
+		return nil, ErrNotFound
+	}
+	cp := *mb
+	return &cp, nil
+}
+
+func (r *MemoryRepository) Update(_ context.Context, mb *Mailbox) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.mailboxes[mb.ID]; !ok {
+		return ErrNotFound
+	}
+	mb.UpdatedAt = time.Now()
+	cp := *mb
+	r.mailboxes[mb.ID] = &cp
+	return nil
+}
+
+func (r *MemoryRepository) Delete(_ context.Context, owner Owner, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if mb, ok := r.mailboxes[id]; ok && owner.owns(mb) {
+		delete(r.mailboxes, id)
+	}
+	return nil
+}
+
+func (r *MemoryRepository) ListByOwner(_ context.Context, owner Owner) ([]*Mailbox, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result []*Mailbox
+	for _, mb := range r.mailboxes {
+		if owner.owns(mb) {
+			cp := *mb
+			result = append(result, &cp)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+func (r *MemoryRepository) ListPush(_ context.Context) ([]*Mailbox, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result []*Mailbox
+	for _, mb := range r.mailboxes {
+		if mb.Enabled && mb.Push {
+			cp := *mb
+			result = append(result, &cp)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+func (r *MemoryRepository) ListAll(_ context.Context) ([]*Mailbox, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make([]*Mailbox, 0, len(r.mailboxes))
+	for _, mb := range r.mailboxes {
+		cp := *mb
+		result = append(result, &cp)
+	}
+	return result, nil
+}
@@ -0,0 +1,73 @@
+package mailbox
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Domain errors.
+var (
+	ErrNotFound       = errors.New("mailbox not found")
+	ErrNotLinked      = errors.New("mailbox is not linked to an OAuth2 provider")
+	ErrNoMailboxes    = errors.New("no mailboxes to read")
+	ErrInvalidFolder  = errors.New("invalid folder pattern")
+	ErrPasswordNeeded = errors.New("changing the server or login of a mailbox requires its password")
+)
+
+// Mailbox is an IMAP connection owned by a user or a team. A user's digest
+// reads every enabled mailbox of the user and merges them into one
+// summary; a team digest reads the team mailbox it names. The owner that
+// does not apply is empty and stored as NULL.
+type Mailbox struct {
+	ID       string `json:"id" gorm:"primaryKey"`
+	UserID   string `json:"userId,omitempty" gorm:"index;default:null"`
+	TeamID   string `json:"teamId,omitempty" gorm:"index;default:null"`
+	Name     string `json:"name"`
+	Username string `json:"username"` // IMAP login
+	Password string `json:"-"`        // encrypted IMAP credential
+	Domain   string `json:"domain"`
+	Port     int    `json:"port"`
+	Enabled  bool   `json:"enabled"` // whether digests read the mailbox
+	Push     bool   `json:"push"`    // whether new mail is processed as it arrives
+
+	// Folders lists the folders the digest reads, INBOX when empty. Entries
+	// may be wildcard patterns such as "Lists/*", which are matched against
+	// the folders the server lists on every run.
+	Folders pq.StringArray `json:"folders" gorm:"type:text[]"`
+
+	// OAuthProvider names the OAuth2 provider whose access tokens sign in
+	// instead of the password; OAuthToken is its encrypted refresh token.
+	OAuthProvider string `json:"oauthProvider" gorm:"column:oauth_provider"`
+	OAuthToken    string `json:"-" gorm:"column:oauth_token"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// TableName keeps the table name stable across GORM naming strategies.
+func (Mailbox) TableName() string { return "mailboxes" }
+
+// Owner returns the user or team the mailbox belongs to.
+func (mb *Mailbox) Owner() Owner {
+	return Owner{UserID: mb.UserID, TeamID: mb.TeamID}
+}
+
+// Owner identifies the user or the team a mailbox belongs to. Exactly one
+// of the fields is set.
+type Owner struct {
+	UserID string
+	TeamID string
+}
+
+// UserOwner is the owner of a user's own mailboxes.
+func UserOwner(userID string) Owner { return Owner{UserID: userID} }
+
+// TeamOwner is the owner of the mailboxes a team shares.
+func TeamOwner(teamID string) Owner { return Owner{TeamID: teamID} }
+
+// owns reports whether mb belongs to o.
+func (o Owner) owns(mb *Mailbox) bool {
+	return mb.Owner() == o
+}
@@ -0,0 +1,76 @@
+package mailbox
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+)
+
+// LinkOAuth makes a mailbox sign in with access tokens
+// from the named OAuth2 provider instead of the IMAP password. The refresh
+// token is stored encrypted.
+func (s *Service) LinkOAuth(ctx context.Context, owner Owner, id, provider, refreshToken string) error {
+	mb, err := s.repo.FindByID(ctx, owner, id)
+	if err != nil {
+		return err
+	}
+	before := *mb
+
+	encrypted, err := s.encrypt(refreshToken)
+	if err != nil {
+		return fmt.Errorf("encrypting refresh token: %w", err)
+	}
+	mb.OAuthProvider = provider
+	mb.OAuthToken = encrypted
+	return s.save(ctx, audit.ActionMailboxOAuthLinked, &before, mb)
+}
+
+// UnlinkOAuth forgets a mailbox's OAuth2 tokens, so it signs in with the
+// IMAP password again.
+func (s *Service) UnlinkOAuth(ctx context.Context, owner Owner, id string) error {
+	mb, err := s.repo.FindByID(ctx, owner, id)
+	if err != nil {
+		return err
+	}
+	if mb.OAuthProvider == "" {
+		return ErrNotLinked
+	}
+	before := *mb
+
+	mb.OAuthProvider = ""
+	mb.OAuthToken = ""
+	return s.save(ctx, audit.ActionMailboxOAuthUnlinked, &before, mb)
+}
+
+// DecryptOAuthToken decrypts and returns a mailbox's OAuth2 refresh token.
+func (s *Service) DecryptOAuthToken(ctx context.Context, mb *Mailbox) (string, error) {
+	if mb.OAuthProvider == "" {
+		return "", ErrNotLinked
+	}
+	token, err := s.decrypt(ctx, mb, oauthTokenSecret)
+	if err != nil {
+		return "", fmt.Errorf("decrypting refresh token: %w", err)
+	}
+	return token, nil
+}
+
+// ReplaceOAuthToken stores the refresh token a provider rotated to during
+// a refresh. Rotation is routine, so it is not audited. Nothing is written
+// if the mailbox has since been unlinked or relinked.
+func (s *Service) ReplaceOAuthToken(ctx context.Context, owner Owner, id, provider, refreshToken string) error {
+	mb, err := s.repo.FindByID(ctx, owner, id)
+	if err != nil {
+		return err
+	}
+	if mb.OAuthProvider != provider {
+		return nil
+	}
+
+	encrypted, err := s.encrypt(refreshToken)
+	if err != nil {
+		return fmt.Errorf("encrypting refresh token: %w", err)
+	}
+	mb.OAuthToken = encrypted
+	return s.repo.Update(ctx, mb)
+}
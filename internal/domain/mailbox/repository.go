@@ -0,0 +1,19 @@
+package mailbox
+
+import "context"
+
+// Repository defines persistence operations for mailboxes.
+type Repository interface {
+	Create(ctx context.Context, mb *Mailbox) error
+	// FindByID returns the mailbox with the given ID belonging to owner.
+	FindByID(ctx context.Context, owner Owner, id string) (*Mailbox, error)
+	Update(ctx context.Context, mb *Mailbox) error
+	Delete(ctx context.Context, owner Owner, id string) error
+	// ListByOwner returns the mailboxes of a user or team, oldest first.
+	ListByOwner(ctx context.Context, owner Owner) ([]*Mailbox, error)
+	// ListPush returns the enabled mailboxes with push mode turned on,
+	// oldest first.
+	ListPush(ctx context.Context) ([]*Mailbox, error)
+	// ListAll returns every mailbox, for key rotation.
+	ListAll(ctx context.Context) ([]*Mailbox, error)
+}
@@ -0,0 +1,357 @@
+package mailbox
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/encryption"
+	"github.com/gofrs/uuid"
+)
+
+// Service manages the mailboxes users and teams connect to MailDruid.
+// Credentials are stored encrypted and changes are written to the audit
+// log. Callers acting for a team check the member's role first.
+type Service struct {
+	repo      Repository
+	encryptor *encryption.Service
+	audit     *audit.Service
+	logger    *slog.Logger
+}
+
+// NewService creates a new mailbox service.
+func NewService(repo Repository, enc *encryption.Service, auditSvc *audit.Service, logger *slog.Logger) *Service {
+	return &Service{repo: repo, encryptor: enc, audit: auditSvc, logger: logger}
+}
+
+// Input holds the fields of a mailbox connection. Nil fields are left
+// unchanged, and an empty password keeps the stored one.
+type Input struct {
+	Name     *string
+	Username *string
+	Password *string
+	Domain   *string
+	Port     *int
+	Folders  *[]string
+	Enabled  *bool
+	Push     *bool
+}
+
+// Create adds a mailbox connection for a user or team. New mailboxes are
+// read by digests unless Enabled is false.
+func (s *Service) Create(ctx context.Context, owner Owner, in Input) (*Mailbox, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, fmt.Errorf("generating UUID: %w", err)
+	}
+	mb := &Mailbox{ID: id.String(), UserID: owner.UserID, TeamID: owner.TeamID, Enabled: true}
+	if err := s.apply(mb, in); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(ctx, mb); err != nil {
+		return nil, fmt.Errorf("creating mailbox: %w", err)
+	}
+	s.record(ctx, audit.ActionMailboxCreated, mb, diff(&Mailbox{}, mb))
+
+	s.logger.Info("mailbox created", "id", mb.ID, "user_id", owner.UserID, "team_id", owner.TeamID)
+	return mb, nil
+}
+
+// List returns the mailboxes of a user or team, oldest first.
+func (s *Service) List(ctx context.Context, owner Owner) ([]*Mailbox, error) {
+	return s.repo.ListByOwner(ctx, owner)
+}
+
+// Enabled returns the mailboxes the user's digest reads, oldest first.
+func (s *Service) Enabled(ctx context.Context, userID string) ([]*Mailbox, error) {
+	all, err := s.repo.ListByOwner(ctx, UserOwner(userID))
+	if err != nil {
+		return nil, err
+	}
+	var enabled []*Mailbox
+	for _, mb := range all {
+		if mb.Enabled {
+			enabled = append(enabled, mb)
+		}
+	}
+	return enabled, nil
+}
+
+// Watched returns the enabled mailboxes of every user and team that have
+// push mode turned on, oldest first.
+func (s *Service) Watched(ctx context.Context) ([]*Mailbox, error) {
+	return s.repo.ListPush(ctx)
+}
+
+// Get returns one of the mailboxes of a user or team.
+func (s *Service) Get(ctx context.Context, owner Owner, id string) (*Mailbox, error) {
+	return s.repo.FindByID(ctx, owner, id)
+}
+
+// Update changes a mailbox connection. Changing the server, port or login
+// drops the mailbox's OAuth2 link and requires the password to be entered
+// again, so the stored credentials are never sent to another server.
+func (s *Service) Update(ctx context.Context, owner Owner, id string, in Input) (*Mailbox, error) {
+	mb, err := s.repo.FindByID(ctx, owner, id)
+	if err != nil {
+		return nil, err
+	}
+	before := *mb
+	if err := s.apply(mb, in); err != nil {
+		return nil, err
+	}
+	if err := s.save(ctx, audit.ActionMailboxUpdated, &before, mb); err != nil {
+		return nil, err
+	}
+	return mb, nil
+}
+
+// Delete removes a mailbox connection together with its sync state.
+func (s *Service) Delete(ctx context.Context, owner Owner, id string) error {
+	mb, err := s.repo.FindByID(ctx, owner, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, owner, id); err != nil {
+		return fmt.Errorf("deleting mailbox: %w", err)
+	}
+	s.record(ctx, audit.ActionMailboxDeleted, mb, nil)
+	return nil
+}
+
+func (s *Service) apply(mb *Mailbox, in Input) error {
+	newPassword := in.Password != nil && *in.Password != ""
+	if connectionChanged(mb, in) {
+		if mb.Password != "" && !newPassword {
+			return ErrPasswordNeeded
+		}
+		mb.OAuthProvider = ""
+		mb.OAuthToken = ""
+	}
+	if in.Name != nil {
+		mb.Name = *in.Name
+	}
+	if in.Username != nil {
+		mb.Username = *in.Username
+	}
+	if in.Domain != nil {
+		mb.Domain = *in.Domain
+	}
+	if in.Port != nil {
+		mb.Port = *in.Port
+	}
+	if in.Folders != nil {
+		folders, err := normalizeFolders(*in.Folders)
+		if err != nil {
+			return err
+		}
+		mb.Folders = folders
+	}
+	if in.Enabled != nil {
+		mb.Enabled = *in.Enabled
+	}
+	if in.Push != nil {
+		mb.Push = *in.Push
+	}
+	if newPassword {
+		encrypted, err := s.encrypt(*in.Password)
+		if err != nil {
+			return fmt.Errorf("encrypting IMAP password: %w", err)
+		}
+		mb.Password = encrypted
+	}
+	return nil
+}
+
+// connectionChanged reports whether in points an existing mailbox at a
+// different server or login.
+func connectionChanged(mb *Mailbox, in Input) bool {
+	return in.Username != nil && *in.Username != mb.Username ||
+		in.Domain != nil && *in.Domain != mb.Domain ||
+		in.Port != nil && *in.Port != mb.Port
+}
+
+// normalizeFolders trims the folder list, drops blank and repeated entries
+// and rejects malformed wildcard patterns.
+func normalizeFolders(in []string) ([]string, error) {
+	var folders []string
+	seen := make(map[string]bool)
+	for _, folder := range in {
+		folder = strings.TrimSpace(folder)
+		if folder == "" || seen[folder] {
+			continue
+		}
+		if _, err := path.Match(folder, ""); err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidFolder, folder)
+		}
+		seen[folder] = true
+		folders = append(folders, folder)
+	}
+	return folders, nil
+}
+
+// save writes mb and records action in the audit log with the fields that
+// differ from before. Plain updates that change nothing are not recorded.
+func (s *Service) save(ctx context.Context, action string, before, mb *Mailbox) error {
+	if err := s.repo.Update(ctx, mb); err != nil {
+		return fmt.Errorf("updating mailbox: %w", err)
+	}
+	changes := diff(before, mb)
+	if action == audit.ActionMailboxUpdated && len(changes) == 0 {
+		return nil
+	}
+	s.record(ctx, action, mb, changes)
+	return nil
+}
+
+// teamActions maps mailbox audit actions to the ones recorded for team
+// mailboxes.
+var teamActions = map[string]string{
+	audit.ActionMailboxCreated:       audit.ActionTeamMailboxCreated,
+	audit.ActionMailboxUpdated:       audit.ActionTeamMailboxUpdated,
+	audit.ActionMailboxDeleted:       audit.ActionTeamMailboxDeleted,
+	audit.ActionMailboxOAuthLinked:   audit.ActionTeamMailboxOAuthLinked,
+	audit.ActionMailboxOAuthUnlinked: audit.ActionTeamMailboxOAuthUnlinked,
+}
+
+// record writes a change to mb to the audit log. Changes to a team's
+// mailbox go to the team's log; the member who made them is the actor.
+func (s *Service) record(ctx context.Context, action string, mb *Mailbox, changes audit.Changes) {
+	e := audit.Event{UserID: mb.UserID, Action: action, Detail: mb.ID, Changes: changes}
+	if mb.TeamID != "" {
+		e.TeamID = mb.TeamID
+		e.Action = teamActions[action]
+	}
+	s.audit.TryRecord(ctx, e)
+}
+
+// DecryptPassword returns the plaintext IMAP credential of a mailbox.
+func (s *Service) DecryptPassword(ctx context.Context, mb *Mailbox) (string, error) {
+	return s.decrypt(ctx, mb, passwordSecret)
+}
+
+// RotateSecrets re-encrypts every mailbox credential and refresh token not
+// sealed with the primary key and returns how many mailboxes were updated.
+func (s *Service) RotateSecrets(ctx context.Context) (int, error) {
+	mailboxes, err := s.repo.ListAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+	updated := 0
+	for _, mb := range mailboxes {
+		passwordChanged, err := s.rotate(&mb.Password)
+		if err != nil {
+			return updated, fmt.Errorf("mailbox %s: re-encrypting IMAP password: %w", mb.ID, err)
+		}
+		tokenChanged, err := s.rotate(&mb.OAuthToken)
+		if err != nil {
+			return updated, fmt.Errorf("mailbox %s: re-encrypting refresh token: %w", mb.ID, err)
+		}
+		if !passwordChanged && !tokenChanged {
+			continue
+		}
+		if err := s.repo.Update(ctx, mb); err != nil {
+			return updated, fmt.Errorf("mailbox %s: %w", mb.ID, err)
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// rotate re-encrypts one stored secret in place and reports whether it
+// changed.
+func (s *Service) rotate(secret *string) (bool, error) {
+	if *secret == "" {
+		return false, nil
+	}
+	raw, err := base64.RawStdEncoding.DecodeString(*secret)
+	if err != nil {
+		return false, fmt.Errorf("decoding: %w", err)
+	}
+	rotated, changed, err := s.encryptor.Rotate(raw)
+	if err != nil {
+		return false, err
+	}
+	if changed {
+		*secret = base64.RawStdEncoding.EncodeToString(rotated)
+	}
+	return changed, nil
+}
+
+func (s *Service) encrypt(secret string) (string, error) {
+	encrypted, err := s.encryptor.Encrypt(secret)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(encrypted), nil
+}
+
+// passwordSecret and oauthTokenSecret select one of a mailbox's stored
+// secrets.
+func passwordSecret(mb *Mailbox) *string   { return &mb.Password }
+func oauthTokenSecret(mb *Mailbox) *string { return &mb.OAuthToken }
+
+// decrypt returns the plaintext of one of mb's secrets. A secret not sealed
+// with the primary key is re-encrypted and saved, so values written by
+// older versions or keys are upgraded as they are read.
+func (s *Service) decrypt(ctx context.Context, mb *Mailbox, secret func(*Mailbox) *string) (string, error) {
+	raw, err := base64.RawStdEncoding.DecodeString(*secret(mb))
+	if err != nil {
+		return "", fmt.Errorf("decoding: %w", err)
+	}
+	plain, rotated, err := s.encryptor.DecryptRotate(raw)
+	if err != nil || rotated == nil {
+		return plain, err
+	}
+
+	old := *secret(mb)
+	*secret(mb) = base64.RawStdEncoding.EncodeToString(rotated)
+	if err := s.replaceSecret(ctx, mb, secret, old); err != nil {
+		s.logger.Error("failed to upgrade mailbox secret encryption", "id", mb.ID, "error", err)
+	}
+	return plain, nil
+}
+
+// replaceSecret stores the re-encrypted secret of mb unless the stored one
+// has changed in the meantime.
+func (s *Service) replaceSecret(ctx context.Context, mb *Mailbox, secret func(*Mailbox) *string, old string) error {
+	stored, err := s.repo.FindByID(ctx, mb.Owner(), mb.ID)
+	if err != nil {
+		return err
+	}
+	if *secret(stored) != old {
+		return nil
+	}
+	*secret(stored) = *secret(mb)
+	return s.repo.Update(ctx, stored)
+}
+
+// diff lists the fields that differ between two versions of a mailbox.
+// Credentials only ever appear redacted.
+func diff(before, after *Mailbox) audit.Changes {
+	var c audit.Changes
+	c.Add("name", before.Name, after.Name)
+	c.Add("username", before.Username, after.Username)
+	c.AddSecret("password", before.Password, after.Password)
+	c.Add("domain", before.Domain, after.Domain)
+	c.Add("port", before.Port, after.Port)
+	c.Add("folders", folderList(before), folderList(after))
+	c.Add("enabled", before.Enabled, after.Enabled)
+	c.Add("push", before.Push, after.Push)
+	c.Add("oauthProvider", before.OAuthProvider, after.OAuthProvider)
+	c.AddSecret("oauthToken", before.OAuthToken, after.OAuthToken)
+	return c
+}
+
+// folderList returns the mailbox's folders with an empty list as nil, so
+// that a stored empty list and an unset one compare equal.
+func folderList(mb *Mailbox) []string {
+	if len(mb.Folders) == 0 {
+		return nil
+	}
+	return []string(mb.Folders)
+}
@@ -0,0 +1,372 @@
+package mailbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/encryption"
+)
+
+func setupTestService(t *testing.T) (*Service, *MemoryRepository, *audit.MemoryRepository) {
+	t.Helper()
+	enc, err := encryption.New([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("encryption.New: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	repo := NewMemoryRepository()
+	events := audit.NewMemoryRepository()
+	return NewService(repo, enc, audit.NewService(events, logger), logger), repo, events
+}
+
+func strPtr(s string) *string { return &s }
+
+func TestCreateAndList(t *testing.T) {
+	svc, repo, _ := setupTestService(t)
+	ctx := context.Background()
+
+	port := 993
+	work, err := svc.Create(ctx, UserOwner("u1"), Input{
+		Name: strPtr("Work"), Username: strPtr("me@work.example"), Password: strPtr("imap-secret"),
+		Domain: strPtr("imap.work.example"), Port: &port,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !work.Enabled {
+		t.Error("new mailboxes should be enabled")
+	}
+	stored, _ := repo.FindByID(ctx, UserOwner("u1"), work.ID)
+	if stored.Password == "" || stored.Password == "imap-secret" {
+		t.Errorf("password should be encrypted, got %q", stored.Password)
+	}
+	if pass, err := svc.DecryptPassword(ctx, stored); err != nil || pass != "imap-secret" {
+		t.Errorf("DecryptPassword = %q, %v", pass, err)
+	}
+
+	disabled, push := false, true
+	if _, err := svc.Create(ctx, UserOwner("u1"), Input{
+		Name: strPtr("Personal"), Username: strPtr("me@home.example"), Password: strPtr("p"),
+		Domain: strPtr("imap.home.example"), Port: &port, Enabled: &disabled, Push: &push,
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	all, _ := svc.List(ctx, UserOwner("u1"))
+	if len(all) != 2 || all[0].ID != work.ID {
+		t.Fatalf("expected both mailboxes oldest first, got %+v", all)
+	}
+	enabled, _ := svc.Enabled(ctx, "u1")
+	if len(enabled) != 1 || enabled[0].ID != work.ID {
+		t.Errorf("expected only the work mailbox to be enabled, got %+v", enabled)
+	}
+	if watched, _ := svc.Watched(ctx); len(watched) != 0 {
+		t.Errorf("a disabled mailbox should not be watched, got %+v", watched)
+	}
+	if _, err := svc.Update(ctx, UserOwner("u1"), work.ID, Input{Push: &push}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if watched, _ := svc.Watched(ctx); len(watched) != 1 || watched[0].ID != work.ID {
+		t.Errorf("expected only the work mailbox to be watched, got %+v", watched)
+	}
+	if others, _ := svc.List(ctx, UserOwner("u2")); len(others) != 0 {
+		t.Errorf("another user should see no mailboxes, got %+v", others)
+	}
+	if _, err := svc.Get(ctx, UserOwner("u2"), work.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for another user's mailbox, got %v", err)
+	}
+}
+
+func TestUpdateAndDelete(t *testing.T) {
+	svc, repo, events := setupTestService(t)
+	ctx := context.Background()
+
+	port := 993
+	mb, err := svc.Create(ctx, UserOwner("u1"), Input{
+		Name: strPtr("Work"), Username: strPtr("me"), Password: strPtr("old-pass"),
+		Domain: strPtr("imap.example.com"), Port: &port,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// An empty password keeps the stored one; folders are trimmed and
+	// deduplicated.
+	folders := []string{" Lists/* ", "INBOX", "", "Lists/*"}
+	if _, err := svc.Update(ctx, UserOwner("u1"), mb.ID, Input{Folders: &folders, Password: strPtr("")}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	stored, _ := repo.FindByID(ctx, UserOwner("u1"), mb.ID)
+	if pass, _ := svc.DecryptPassword(ctx, stored); len(stored.Folders) != 2 || stored.Folders[0] != "Lists/*" ||
+		stored.Folders[1] != "INBOX" || pass != "old-pass" {
+		t.Errorf("unexpected mailbox after update: %+v (password %q)", stored, pass)
+	}
+	// An update that changes nothing is not recorded.
+	same := []string{"Lists/*", "INBOX"}
+	if _, err := svc.Update(ctx, UserOwner("u1"), mb.ID, Input{Folders: &same}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	bad := []string{"Lists/["}
+	if _, err := svc.Update(ctx, UserOwner("u1"), mb.ID, Input{Folders: &bad}); !errors.Is(err, ErrInvalidFolder) {
+		t.Errorf("expected ErrInvalidFolder, got %v", err)
+	}
+	if _, err := svc.Update(ctx, UserOwner("u2"), mb.ID, Input{Folders: &same}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound updating another user's mailbox, got %v", err)
+	}
+
+	if err := svc.Delete(ctx, UserOwner("u2"), mb.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound deleting another user's mailbox, got %v", err)
+	}
+	if err := svc.Delete(ctx, UserOwner("u1"), mb.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := svc.Get(ctx, UserOwner("u1"), mb.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the mailbox to be gone, got %v", err)
+	}
+
+	got := events.Events()
+	want := []string{audit.ActionMailboxCreated, audit.ActionMailboxUpdated, audit.ActionMailboxDeleted}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), got)
+	}
+	for i, action := range want {
+		if got[i].Action != action || got[i].UserID != "u1" || got[i].Detail != mb.ID {
+			t.Errorf("event %d: unexpected %+v", i, got[i])
+		}
+	}
+	for _, c := range got[0].Changes {
+		if c.Field == "password" && c.After != audit.Redacted {
+			t.Errorf("expected the password to be redacted, got %+v", c)
+		}
+	}
+	if !got[1].Changes.Has("folders") || got[1].Changes.Has("password") {
+		t.Errorf("unexpected update diff %+v", got[1].Changes)
+	}
+}
+
+func TestTeamMailboxes(t *testing.T) {
+	svc, _, events := setupTestService(t)
+	ctx := context.Background()
+
+	port := 993
+	mb, err := svc.Create(ctx, TeamOwner("t1"), Input{
+		Name: strPtr("Shared"), Username: strPtr("support@example.com"), Password: strPtr("secret"),
+		Domain: strPtr("imap.example.com"), Port: &port,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if mb.TeamID != "t1" || mb.UserID != "" {
+		t.Fatalf("expected a mailbox owned by the team, got %+v", mb)
+	}
+	if _, err := svc.Get(ctx, UserOwner("t1"), mb.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("team mailboxes must not be found as a user's, got %v", err)
+	}
+	if list, _ := svc.List(ctx, TeamOwner("t2")); len(list) != 0 {
+		t.Errorf("expected no mailboxes for another team, got %+v", list)
+	}
+
+	// The stored credential is never sent to a new server.
+	if _, err := svc.Update(ctx, TeamOwner("t1"), mb.ID, Input{Domain: strPtr("imap.attacker.example")}); !errors.Is(err, ErrPasswordNeeded) {
+		t.Errorf("expected ErrPasswordNeeded, got %v", err)
+	}
+	if _, err := svc.Update(ctx, TeamOwner("t1"), mb.ID, Input{Port: &port, Domain: strPtr("imap.example.com")}); err != nil {
+		t.Errorf("an unchanged server needs no password, got %v", err)
+	}
+
+	// A linked mailbox has no password; a new login drops the link instead.
+	linked, err := svc.Create(ctx, TeamOwner("t1"), Input{
+		Name: strPtr("Gmail"), Username: strPtr("team@gmail.com"), Domain: strPtr("imap.gmail.com"), Port: &port,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := svc.LinkOAuth(ctx, TeamOwner("t1"), linked.ID, "google", "refresh"); err != nil {
+		t.Fatalf("LinkOAuth: %v", err)
+	}
+	linked, err = svc.Update(ctx, TeamOwner("t1"), linked.ID, Input{Username: strPtr("other@gmail.com")})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if linked.OAuthProvider != "" || linked.OAuthToken != "" {
+		t.Errorf("expected a new login to drop the OAuth2 link, got %+v", linked)
+	}
+
+	for _, e := range events.Events() {
+		if e.TeamID != "t1" || e.UserID != "" {
+			t.Errorf("expected team mailbox events in the team's log, got %+v", e)
+		}
+	}
+	if got := events.Events(); got[0].Action != audit.ActionTeamMailboxCreated {
+		t.Errorf("expected %s, got %s", audit.ActionTeamMailboxCreated, got[0].Action)
+	}
+}
+
+func TestOAuthLink(t *testing.T) {
+	svc, repo, events := setupTestService(t)
+	ctx := context.Background()
+
+	port := 993
+	mb, err := svc.Create(ctx, UserOwner("u1"), Input{
+		Name: strPtr("Gmail"), Username: strPtr("me@gmail.com"), Domain: strPtr("imap.gmail.com"), Port: &port,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, err := svc.DecryptOAuthToken(ctx, mb); !errors.Is(err, ErrNotLinked) {
+		t.Errorf("expected ErrNotLinked, got %v", err)
+	}
+	if err := svc.LinkOAuth(ctx, UserOwner("u2"), mb.ID, "google", "refresh-0"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound linking another user's mailbox, got %v", err)
+	}
+	if err := svc.LinkOAuth(ctx, UserOwner("u1"), mb.ID, "google", "refresh-1"); err != nil {
+		t.Fatalf("LinkOAuth: %v", err)
+	}
+	mb, _ = repo.FindByID(ctx, UserOwner("u1"), mb.ID)
+	if mb.OAuthProvider != "google" || mb.OAuthToken == "refresh-1" {
+		t.Fatalf("expected an encrypted refresh token for google, got %+v", mb)
+	}
+	if tok, err := svc.DecryptOAuthToken(ctx, mb); err != nil || tok != "refresh-1" {
+		t.Errorf("DecryptOAuthToken = %q, %v", tok, err)
+	}
+
+	// A rotated token replaces the old one, but not after a relink.
+	if err := svc.ReplaceOAuthToken(ctx, UserOwner("u1"), mb.ID, "google", "refresh-2"); err != nil {
+		t.Fatalf("ReplaceOAuthToken: %v", err)
+	}
+	if err := svc.ReplaceOAuthToken(ctx, UserOwner("u1"), mb.ID, "microsoft", "refresh-3"); err != nil {
+		t.Fatalf("ReplaceOAuthToken: %v", err)
+	}
+	mb, _ = repo.FindByID(ctx, UserOwner("u1"), mb.ID)
+	if tok, _ := svc.DecryptOAuthToken(ctx, mb); tok != "refresh-2" {
+		t.Errorf("expected refresh-2, got %q", tok)
+	}
+
+	if err := svc.UnlinkOAuth(ctx, UserOwner("u1"), mb.ID); err != nil {
+		t.Fatalf("UnlinkOAuth: %v", err)
+	}
+	if err := svc.UnlinkOAuth(ctx, UserOwner("u1"), mb.ID); !errors.Is(err, ErrNotLinked) {
+		t.Errorf("expected ErrNotLinked, got %v", err)
+	}
+	mb, _ = repo.FindByID(ctx, UserOwner("u1"), mb.ID)
+	if mb.OAuthProvider != "" || mb.OAuthToken != "" {
+		t.Errorf("expected tokens to be forgotten, got %+v", mb)
+	}
+
+	got := events.Events()
+	if len(got) != 3 || got[1].Action != audit.ActionMailboxOAuthLinked || got[2].Action != audit.ActionMailboxOAuthUnlinked {
+		t.Fatalf("unexpected events %+v", got)
+	}
+	for _, c := range got[1].Changes {
+		if c.Field == "oauthToken" && c.After != audit.Redacted {
+			t.Errorf("expected the refresh token to be redacted, got %+v", c)
+		}
+	}
+}
+
+func TestRotateSecrets(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	repo := NewMemoryRepository()
+	auditSvc := audit.NewService(audit.NewMemoryRepository(), logger)
+
+	oldKey := encryption.Key{ID: "old", Secret: []byte("0123456789abcdef")}
+	newKey := encryption.Key{ID: "new", Secret: []byte("fedcba9876543210fedcba9876543210")}
+
+	oldEnc, _ := encryption.NewKeyring(oldKey, nil, "")
+	oldSvc := NewService(repo, oldEnc, auditSvc, logger)
+	port := 993
+	var linked string
+	for _, user := range []string{"u1", "u2"} {
+		mb, err := oldSvc.Create(ctx, UserOwner(user), Input{
+			Name: strPtr("Mail"), Username: strPtr(user), Password: strPtr("imap-" + user),
+			Domain: strPtr("imap.ex.com"), Port: &port,
+		})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		linked = mb.ID
+	}
+	if err := oldSvc.LinkOAuth(ctx, UserOwner("u2"), linked, "google", "refresh-token"); err != nil {
+		t.Fatalf("LinkOAuth: %v", err)
+	}
+
+	enc, _ := encryption.NewKeyring(newKey, []encryption.Key{oldKey}, "")
+	svc := NewService(repo, enc, auditSvc, logger)
+	n, err := svc.RotateSecrets(ctx)
+	if err != nil {
+		t.Fatalf("RotateSecrets: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 mailboxes rotated, got %d", n)
+	}
+	if n, _ := svc.RotateSecrets(ctx); n != 0 {
+		t.Errorf("second rotation should be a no-op, rotated %d", n)
+	}
+
+	// Secrets are now readable without the old key.
+	newEnc, _ := encryption.NewKeyring(newKey, nil, "")
+	newSvc := NewService(repo, newEnc, auditSvc, logger)
+	all, _ := repo.ListAll(ctx)
+	for _, mb := range all {
+		pass, err := newSvc.DecryptPassword(ctx, mb)
+		if err != nil || pass != "imap-"+mb.UserID {
+			t.Errorf("mailbox %s: got %q, %v", mb.ID, pass, err)
+		}
+		if mb.ID == linked {
+			if tok, err := newSvc.DecryptOAuthToken(ctx, mb); err != nil || tok != "refresh-token" {
+				t.Errorf("refresh token: got %q, %v", tok, err)
+			}
+		}
+	}
+}
+
+func TestDecryptUpgradesOldSecrets(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	repo := NewMemoryRepository()
+	auditSvc := audit.NewService(audit.NewMemoryRepository(), logger)
+
+	oldKey := encryption.Key{ID: "old", Secret: []byte("0123456789abcdef")}
+	newKey := encryption.Key{ID: "new", Secret: []byte("fedcba9876543210fedcba9876543210")}
+
+	oldEnc, _ := encryption.NewKeyring(oldKey, nil, "")
+	oldSvc := NewService(repo, oldEnc, auditSvc, logger)
+	port := 993
+	mb, err := oldSvc.Create(ctx, UserOwner("u1"), Input{
+		Name: strPtr("Mail"), Username: strPtr("u1"), Password: strPtr("imap-secret"),
+		Domain: strPtr("imap.ex.com"), Port: &port,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := oldSvc.LinkOAuth(ctx, UserOwner("u1"), mb.ID, "google", "refresh-token"); err != nil {
+		t.Fatalf("LinkOAuth: %v", err)
+	}
+
+	// Reading the secrets once after the key changed rewrites them.
+	enc, _ := encryption.NewKeyring(newKey, []encryption.Key{oldKey}, "")
+	svc := NewService(repo, enc, auditSvc, logger)
+	mb, _ = repo.FindByID(ctx, UserOwner("u1"), mb.ID)
+	if pass, err := svc.DecryptPassword(ctx, mb); err != nil || pass != "imap-secret" {
+		t.Fatalf("DecryptPassword = %q, %v", pass, err)
+	}
+	if tok, err := svc.DecryptOAuthToken(ctx, mb); err != nil || tok != "refresh-token" {
+		t.Fatalf("DecryptOAuthToken = %q, %v", tok, err)
+	}
+
+	newEnc, _ := encryption.NewKeyring(newKey, nil, "")
+	newSvc := NewService(repo, newEnc, auditSvc, logger)
+	stored, _ := repo.FindByID(ctx, UserOwner("u1"), mb.ID)
+	if pass, err := newSvc.DecryptPassword(ctx, stored); err != nil || pass != "imap-secret" {
+		t.Errorf("expected the password to be re-encrypted, got %q, %v", pass, err)
+	}
+	if tok, err := newSvc.DecryptOAuthToken(ctx, stored); err != nil || tok != "refresh-token" {
+		t.Errorf("expected the refresh token to be re-encrypted, got %q, %v", tok, err)
+	}
+}
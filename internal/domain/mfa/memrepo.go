@@ -0,0 +1,122 @@
+package mfa
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryRepository is an in-memory TOTP repository for testing.
+type MemoryRepository struct {
+	mu          sync.RWMutex
+	credentials map[string]*Credential
+	codes       map[string][]*RecoveryCode
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		credentials: make(map[string]*Credential),
+		codes:       make(map[string][]*RecoveryCode),
+	}
+}
+
+func (r *MemoryRepository) FindCredential(_ context.Context, userID string) (*Credential, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.credentials[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *c
+	return &cp, nil
+}
+
+func (r *MemoryRepository) SaveCredential(_ context.Context, c *Credential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = now
+	}
+	c.UpdatedAt = now
+	cp := *c
+	r.credentials[c.UserID] = &cp
+	return nil
+}
+
+func (r *MemoryRepository) AdvanceStep(_ context.Context, userID string, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.credentials[userID]
+	if !ok || c.LastStep >= step {
+		return false, nil
+	}
+	c.LastStep = step
+	return true, nil
+}
+
+func (r *MemoryRepository) UpdateAttempts(_ context.Context, userID string, failed int, lockedUntil *time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.credentials[userID]; ok {
+		c.FailedAttempts = failed
+		c.LockedUntil = lockedUntil
+	}
+	return nil
+}
+
+func (r *MemoryRepository) DeleteCredential(_ context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.credentials, userID)
+	delete(r.codes, userID)
+	return nil
+}
+
+func (r *MemoryRepository) ListCredentials(_ context.Context) ([]*Credential, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]*Credential, 0, len(r.credentials))
+	for _, c := range r.credentials {
+		cp := *c
+		out = append(out, &cp)
+	}
+	return out, nil
+}
+
+func (r *MemoryRepository) ReplaceRecoveryCodes(_ context.Context, userID string, codes []*RecoveryCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := make([]*RecoveryCode, 0, len(codes))
+	for _, c := range codes {
+		cp := *c
+		cp.CreatedAt = time.Now()
+		stored = append(stored, &cp)
+	}
+	r.codes[userID] = stored
+	return nil
+}
+
+func (r *MemoryRepository) UseRecoveryCode(_ context.Context, userID, codeHash string, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.codes[userID] {
+		if c.CodeHash == codeHash && c.UsedAt == nil {
+			c.UsedAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *MemoryRepository) CountRecoveryCodes(_ context.Context, userID string) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	n := 0
+	for _, c := range r.codes[userID] {
+		if c.UsedAt == nil {
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,48 @@
+package mfa
+
+import (
+	"errors"
+	"time"
+)
+
+// Domain errors.
+var (
+	ErrNotFound       = errors.New("two-factor authentication not set up")
+	ErrNotEnrolled    = errors.New("no pending two-factor enrollment")
+	ErrAlreadyEnabled = errors.New("two-factor authentication already enabled")
+	ErrInvalidCode    = errors.New("invalid verification code")
+	ErrLocked         = errors.New("too many failed verification attempts")
+)
+
+// Credential is a user's TOTP secret. It is created unconfirmed by an
+// enrollment and enabled once the user proves they can generate codes.
+type Credential struct {
+	UserID  string `gorm:"primaryKey"`
+	Secret  string // encrypted TOTP secret
+	Enabled bool
+	// LastStep is the time step of the last accepted code. Codes from
+	// this step or earlier are rejected so a code cannot be replayed.
+	LastStep       int64
+	FailedAttempts int
+	LockedUntil    *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// TableName keeps the table name stable across GORM naming strategies.
+func (Credential) TableName() string { return "totp_credentials" }
+
+// Locked reports whether verification is temporarily blocked at now.
+func (c *Credential) Locked(now time.Time) bool {
+	return c.LockedUntil != nil && now.Before(*c.LockedUntil)
+}
+
+// RecoveryCode is a single-use code that stands in for a TOTP code when
+// the authenticator is lost. Only its hash is stored.
+type RecoveryCode struct {
+	ID        string `gorm:"primaryKey"`
+	UserID    string `gorm:"index"`
+	CodeHash  string
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,32 @@
+package mfa
+
+import (
+	"context"
+	"time"
+)
+
+// Repository defines persistence operations for TOTP credentials and
+// recovery codes.
+type Repository interface {
+	// FindCredential returns the user's credential or ErrNotFound.
+	FindCredential(ctx context.Context, userID string) (*Credential, error)
+	// SaveCredential creates or replaces the user's credential.
+	SaveCredential(ctx context.Context, c *Credential) error
+	// AdvanceStep records step as the last accepted time step if it is
+	// newer than the stored one and reports whether it was.
+	AdvanceStep(ctx context.Context, userID string, step int64) (bool, error)
+	// UpdateAttempts stores the failed attempt counter and lockout.
+	UpdateAttempts(ctx context.Context, userID string, failed int, lockedUntil *time.Time) error
+	// DeleteCredential removes the user's credential and recovery codes.
+	DeleteCredential(ctx context.Context, userID string) error
+	// ListCredentials returns every stored credential.
+	ListCredentials(ctx context.Context) ([]*Credential, error)
+	// ReplaceRecoveryCodes discards the user's recovery codes and stores
+	// codes instead.
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codes []*RecoveryCode) error
+	// UseRecoveryCode marks an unused code with the given hash as used and
+	// reports whether one was found.
+	UseRecoveryCode(ctx context.Context, userID, codeHash string, at time.Time) (bool, error)
+	// CountRecoveryCodes returns how many unused codes the user has left.
+	CountRecoveryCodes(ctx context.Context, userID string) (int, error)
+}
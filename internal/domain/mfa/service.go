@@ -0,0 +1,369 @@
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/encryption"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	secretBytes       = 20
+	recoveryCodeCount = 10
+	recoveryCodeBytes = 6
+
+	// After maxFailedAttempts wrong codes in a row, verification is
+	// refused for lockoutDuration. Six-digit codes are otherwise easy to
+	// guess within the lifetime of a login challenge.
+	maxFailedAttempts = 5
+	lockoutDuration   = 5 * time.Minute
+)
+
+// Enrollment holds what an authenticator app needs to start generating
+// codes. URI is usually rendered as a QR code.
+type Enrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// Status summarises a user's two-factor setup.
+type Status struct {
+	Enabled                bool `json:"enabled"`
+	RecoveryCodesRemaining int  `json:"recoveryCodesRemaining"`
+}
+
+// Service manages TOTP enrollment, verification and recovery codes.
+type Service struct {
+	repo    Repository
+	userSvc *user.Service
+	enc     *encryption.Service
+	issuer  string
+	logger  *slog.Logger
+	now     func() time.Time
+}
+
+// NewService creates a two-factor service. issuer is the name shown in
+// authenticator apps.
+func NewService(repo Repository, userSvc *user.Service, enc *encryption.Service, issuer string, logger *slog.Logger) *Service {
+	return &Service{repo: repo, userSvc: userSvc, enc: enc, issuer: issuer, logger: logger, now: time.Now}
+}
+
+// Enroll starts TOTP setup with a fresh secret, replacing any enrollment
+// that was never confirmed.
+func (s *Service) Enroll(ctx context.Context, userID string) (*Enrollment, error) {
+	cred, err := s.repo.FindCredential(ctx, userID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if cred != nil && cred.Enabled {
+		return nil, ErrAlreadyEnabled
+	}
+
+	u, err := s.userSvc.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("generating TOTP secret: %w", err)
+	}
+	sealed, err := s.enc.Encrypt(b32.EncodeToString(secret))
+	if err != nil {
+		return nil, fmt.Errorf("encrypting TOTP secret: %w", err)
+	}
+
+	if err := s.repo.SaveCredential(ctx, &Credential{
+		UserID: userID,
+		Secret: base64.RawStdEncoding.EncodeToString(sealed),
+	}); err != nil {
+		return nil, err
+	}
+
+	return &Enrollment{
+		Secret: b32.EncodeToString(secret),
+		URI:    provisioningURI(s.issuer, u.Email, secret),
+	}, nil
+}
+
+// Confirm enables a pending enrollment once code proves the authenticator
+// works, and returns a fresh set of recovery codes.
+func (s *Service) Confirm(ctx context.Context, userID, code string) ([]string, error) {
+	cred, err := s.repo.FindCredential(ctx, userID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cred.Enabled {
+		return nil, ErrAlreadyEnabled
+	}
+
+	if err := s.verify(ctx, cred, code, false); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.replaceRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	cred.Enabled = true
+	if err := s.repo.SaveCredential(ctx, cred); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("two-factor authentication enabled", "user_id", userID)
+	return codes, nil
+}
+
+// Enabled reports whether the user has confirmed TOTP.
+func (s *Service) Enabled(ctx context.Context, userID string) (bool, error) {
+	cred, err := s.repo.FindCredential(ctx, userID)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return cred.Enabled, nil
+}
+
+// Status returns whether TOTP is enabled and how many recovery codes are
+// left.
+func (s *Service) Status(ctx context.Context, userID string) (*Status, error) {
+	enabled, err := s.Enabled(ctx, userID)
+	if err != nil || !enabled {
+		return &Status{}, err
+	}
+	remaining, err := s.repo.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &Status{Enabled: true, RecoveryCodesRemaining: remaining}, nil
+}
+
+// Verify checks a TOTP code or an unused recovery code for a user with
+// two-factor authentication enabled. Each code is accepted only once.
+func (s *Service) Verify(ctx context.Context, userID, code string) error {
+	cred, err := s.enabledCredential(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.verify(ctx, cred, code, true)
+}
+
+// Disable turns two-factor authentication off after verifying code.
+func (s *Service) Disable(ctx context.Context, userID, code string) error {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteCredential(ctx, userID); err != nil {
+		return err
+	}
+	s.logger.Info("two-factor authentication disabled", "user_id", userID)
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after
+// verifying code and returns the new ones.
+func (s *Service) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(ctx, userID)
+}
+
+// RotateSecrets re-encrypts every TOTP secret under the primary encryption
+// key and returns how many were updated.
+func (s *Service) RotateSecrets(ctx context.Context) (int, error) {
+	creds, err := s.repo.ListCredentials(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, c := range creds {
+		raw, err := base64.RawStdEncoding.DecodeString(c.Secret)
+		if err != nil {
+			return updated, fmt.Errorf("user %s: decoding TOTP secret: %w", c.UserID, err)
+		}
+		rotated, changed, err := s.enc.Rotate(raw)
+		if err != nil {
+			return updated, fmt.Errorf("user %s: re-encrypting TOTP secret: %w", c.UserID, err)
+		}
+		if !changed {
+			continue
+		}
+		c.Secret = base64.RawStdEncoding.EncodeToString(rotated)
+		if err := s.repo.SaveCredential(ctx, c); err != nil {
+			return updated, fmt.Errorf("user %s: %w", c.UserID, err)
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+func (s *Service) enabledCredential(ctx context.Context, userID string) (*Credential, error) {
+	cred, err := s.repo.FindCredential(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !cred.Enabled {
+		return nil, ErrNotFound
+	}
+	return cred, nil
+}
+
+// verify checks code against cred, counting failures towards a lockout.
+func (s *Service) verify(ctx context.Context, cred *Credential, code string, allowRecovery bool) error {
+	now := s.now()
+	if cred.Locked(now) {
+		return ErrLocked
+	}
+
+	ok, err := s.check(ctx, cred, normalizeCode(code), allowRecovery, now)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		cred.FailedAttempts++
+		var lockedUntil *time.Time
+		if cred.FailedAttempts >= maxFailedAttempts {
+			until := now.Add(lockoutDuration)
+			lockedUntil = &until
+			cred.FailedAttempts = 0
+			s.logger.Warn("two-factor verification locked", "user_id", cred.UserID, "until", until)
+		}
+		if err := s.repo.UpdateAttempts(ctx, cred.UserID, cred.FailedAttempts, lockedUntil); err != nil {
+			s.logger.Error("failed to record verification attempt", "user_id", cred.UserID, "error", err)
+		}
+		return ErrInvalidCode
+	}
+
+	if cred.FailedAttempts > 0 || cred.LockedUntil != nil {
+		cred.FailedAttempts, cred.LockedUntil = 0, nil
+		if err := s.repo.UpdateAttempts(ctx, cred.UserID, 0, nil); err != nil {
+			s.logger.Error("failed to reset verification attempts", "user_id", cred.UserID, "error", err)
+		}
+	}
+	return nil
+}
+
+func (s *Service) check(ctx context.Context, cred *Credential, code string, allowRecovery bool, now time.Time) (bool, error) {
+	if isTOTPCode(code) {
+		raw, err := base64.RawStdEncoding.DecodeString(cred.Secret)
+		if err != nil {
+			return false, fmt.Errorf("decoding TOTP secret: %w", err)
+		}
+		encoded, rotated, err := s.enc.DecryptRotate(raw)
+		if err != nil {
+			return false, fmt.Errorf("decrypting TOTP secret: %w", err)
+		}
+		if rotated != nil {
+			s.replaceSecret(ctx, cred, base64.RawStdEncoding.EncodeToString(rotated))
+		}
+		secret, err := b32.DecodeString(encoded)
+		if err != nil {
+			return false, fmt.Errorf("decoding TOTP secret: %w", err)
+		}
+		step, ok := matchStep(secret, code, now)
+		if !ok {
+			return false, nil
+		}
+		advanced, err := s.repo.AdvanceStep(ctx, cred.UserID, step)
+		if err != nil {
+			return false, err
+		}
+		if advanced {
+			cred.LastStep = step
+		}
+		return advanced, nil
+	}
+
+	if !allowRecovery || code == "" {
+		return false, nil
+	}
+	used, err := s.repo.UseRecoveryCode(ctx, cred.UserID, hashCode(code), now)
+	if err != nil {
+		return false, err
+	}
+	if used {
+		s.logger.Info("recovery code used", "user_id", cred.UserID)
+	}
+	return used, nil
+}
+
+// replaceSecret stores a TOTP secret re-encrypted with the primary key,
+// unless the stored secret has changed in the meantime. Failures are only
+// logged: the old value still decrypts.
+func (s *Service) replaceSecret(ctx context.Context, cred *Credential, rotated string) {
+	stored, err := s.repo.FindCredential(ctx, cred.UserID)
+	if err == nil && stored.Secret == cred.Secret {
+		stored.Secret = rotated
+		err = s.repo.SaveCredential(ctx, stored)
+	}
+	if err != nil {
+		s.logger.Error("failed to upgrade TOTP secret encryption", "user_id", cred.UserID, "error", err)
+		return
+	}
+	cred.Secret = rotated
+}
+
+func (s *Service) replaceRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	plain := make([]string, 0, recoveryCodeCount)
+	stored := make([]*RecoveryCode, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		raw := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("generating recovery code: %w", err)
+		}
+		id, err := uuid.NewV4()
+		if err != nil {
+			return nil, fmt.Errorf("generating recovery code ID: %w", err)
+		}
+		code := strings.ToLower(b32.EncodeToString(raw))
+		plain = append(plain, code[:5]+"-"+code[5:])
+		stored = append(stored, &RecoveryCode{ID: id.String(), UserID: userID, CodeHash: hashCode(code)})
+	}
+
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, stored); err != nil {
+		return nil, err
+	}
+	return plain, nil
+}
+
+// normalizeCode strips the separators users type or paste along with a
+// code.
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != totpDigits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// hashCode returns the stored form of a recovery code.
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
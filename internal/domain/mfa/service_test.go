@@ -0,0 +1,232 @@
+package mfa
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/encryption"
+)
+
+func setupTestService(t *testing.T) (*Service, string) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	enc, err := encryption.New([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("encryption.New: %v", err)
+	}
+	userSvc := user.NewService(user.NewMemoryRepository(), enc, audit.NewService(audit.NewMemoryRepository(), logger), logger)
+
+	ctx := context.Background()
+	if err := userSvc.Create(ctx, user.CreateInput{
+		Name: "User", Email: "u@ex.com", ReceivingEmail: "r@ex.com",
+		Password: "login-pass",
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	id, err := userSvc.Authenticate(ctx, "u@ex.com", "login-pass")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	svc := NewService(NewMemoryRepository(), userSvc, enc, "MailDruid", logger)
+	now := time.Unix(1_800_000_000, 0)
+	svc.now = func() time.Time { return now }
+	return svc, id
+}
+
+// enable enrolls and confirms TOTP and returns the secret and recovery codes.
+func enable(t *testing.T, svc *Service, userID string) ([]byte, []string) {
+	t.Helper()
+	ctx := context.Background()
+	enr, err := svc.Enroll(ctx, userID)
+	if err != nil {
+		t.Fatalf("Enroll: %v", err)
+	}
+	secret, err := b32.DecodeString(enr.Secret)
+	if err != nil {
+		t.Fatalf("decoding secret: %v", err)
+	}
+	codes, err := svc.Confirm(ctx, userID, hotp(secret, timeStep(svc.now())))
+	if err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	return secret, codes
+}
+
+func TestEnrollAndConfirm(t *testing.T) {
+	svc, userID := setupTestService(t)
+	ctx := context.Background()
+
+	if _, err := svc.Confirm(ctx, userID, "123456"); err != ErrNotEnrolled {
+		t.Fatalf("expected ErrNotEnrolled, got %v", err)
+	}
+
+	enr, err := svc.Enroll(ctx, userID)
+	if err != nil {
+		t.Fatalf("Enroll: %v", err)
+	}
+	if enr.Secret == "" || enr.URI == "" {
+		t.Fatal("enrollment should return a secret and URI")
+	}
+	if enabled, _ := svc.Enabled(ctx, userID); enabled {
+		t.Fatal("enrollment should not be enabled before confirmation")
+	}
+	if _, err := svc.Confirm(ctx, userID, "000000"); err != ErrInvalidCode {
+		t.Fatalf("expected ErrInvalidCode, got %v", err)
+	}
+
+	_, codes := enable(t, svc, userID)
+	if len(codes) != recoveryCodeCount {
+		t.Errorf("expected %d recovery codes, got %d", recoveryCodeCount, len(codes))
+	}
+	status, _ := svc.Status(ctx, userID)
+	if !status.Enabled || status.RecoveryCodesRemaining != recoveryCodeCount {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if _, err := svc.Enroll(ctx, userID); err != ErrAlreadyEnabled {
+		t.Errorf("expected ErrAlreadyEnabled, got %v", err)
+	}
+}
+
+func TestVerifyRejectsReplay(t *testing.T) {
+	svc, userID := setupTestService(t)
+	ctx := context.Background()
+	secret, _ := enable(t, svc, userID)
+
+	// The code used to confirm cannot be used again.
+	if err := svc.Verify(ctx, userID, hotp(secret, timeStep(svc.now()))); err != ErrInvalidCode {
+		t.Fatalf("expected replay to fail, got %v", err)
+	}
+
+	later := svc.now().Add(totpPeriod)
+	svc.now = func() time.Time { return later }
+	code := hotp(secret, timeStep(later))
+	if err := svc.Verify(ctx, userID, code[:3]+" "+code[3:]); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+}
+
+func TestRecoveryCodesAreSingleUse(t *testing.T) {
+	svc, userID := setupTestService(t)
+	ctx := context.Background()
+	_, codes := enable(t, svc, userID)
+
+	if err := svc.Verify(ctx, userID, codes[0]); err != nil {
+		t.Fatalf("Verify recovery code: %v", err)
+	}
+	if err := svc.Verify(ctx, userID, codes[0]); err != ErrInvalidCode {
+		t.Errorf("expected used recovery code to fail, got %v", err)
+	}
+	if status, _ := svc.Status(ctx, userID); status.RecoveryCodesRemaining != recoveryCodeCount-1 {
+		t.Errorf("expected %d codes left, got %d", recoveryCodeCount-1, status.RecoveryCodesRemaining)
+	}
+
+	fresh, err := svc.RegenerateRecoveryCodes(ctx, userID, codes[1])
+	if err != nil {
+		t.Fatalf("RegenerateRecoveryCodes: %v", err)
+	}
+	if err := svc.Verify(ctx, userID, codes[2]); err != ErrInvalidCode {
+		t.Errorf("old recovery codes should be discarded, got %v", err)
+	}
+	if err := svc.Verify(ctx, userID, fresh[0]); err != nil {
+		t.Errorf("new recovery code: %v", err)
+	}
+}
+
+func TestVerifyLocksAfterFailures(t *testing.T) {
+	svc, userID := setupTestService(t)
+	ctx := context.Background()
+	secret, _ := enable(t, svc, userID)
+
+	for range maxFailedAttempts {
+		if err := svc.Verify(ctx, userID, "000000"); err != ErrInvalidCode {
+			t.Fatalf("expected ErrInvalidCode, got %v", err)
+		}
+	}
+
+	next := svc.now().Add(totpPeriod)
+	svc.now = func() time.Time { return next }
+	if err := svc.Verify(ctx, userID, hotp(secret, timeStep(next))); err != ErrLocked {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+
+	unlocked := next.Add(lockoutDuration)
+	svc.now = func() time.Time { return unlocked }
+	if err := svc.Verify(ctx, userID, hotp(secret, timeStep(unlocked))); err != nil {
+		t.Errorf("Verify after lockout: %v", err)
+	}
+}
+
+func TestDisable(t *testing.T) {
+	svc, userID := setupTestService(t)
+	ctx := context.Background()
+	_, codes := enable(t, svc, userID)
+
+	if err := svc.Disable(ctx, userID, "bad"); err != ErrInvalidCode {
+		t.Fatalf("expected ErrInvalidCode, got %v", err)
+	}
+	if err := svc.Disable(ctx, userID, codes[0]); err != nil {
+		t.Fatalf("Disable: %v", err)
+	}
+	if enabled, _ := svc.Enabled(ctx, userID); enabled {
+		t.Error("two-factor should be disabled")
+	}
+	if err := svc.Verify(ctx, userID, codes[1]); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound after disabling, got %v", err)
+	}
+}
+
+func TestRotateSecrets(t *testing.T) {
+	svc, userID := setupTestService(t)
+	ctx := context.Background()
+	secret, _ := enable(t, svc, userID)
+
+	old := encryption.Key{ID: encryption.DefaultKeyID, Secret: []byte("0123456789abcdef0123456789abcdef")}
+	enc, err := encryption.NewKeyring(encryption.Key{ID: "next", Secret: []byte("fedcba9876543210fedcba9876543210")}, []encryption.Key{old}, "")
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	svc.enc = enc
+
+	if n, err := svc.RotateSecrets(ctx); err != nil || n != 1 {
+		t.Fatalf("RotateSecrets: n=%d err=%v", n, err)
+	}
+	if n, _ := svc.RotateSecrets(ctx); n != 0 {
+		t.Errorf("second rotation should be a no-op, got %d", n)
+	}
+
+	later := svc.now().Add(totpPeriod)
+	svc.now = func() time.Time { return later }
+	if err := svc.Verify(ctx, userID, hotp(secret, timeStep(later))); err != nil {
+		t.Errorf("Verify after rotation: %v", err)
+	}
+}
+
+func TestVerifyUpgradesSecretEncryption(t *testing.T) {
+	svc, userID := setupTestService(t)
+	ctx := context.Background()
+	secret, _ := enable(t, svc, userID)
+
+	old := encryption.Key{ID: encryption.DefaultKeyID, Secret: []byte("0123456789abcdef0123456789abcdef")}
+	enc, err := encryption.NewKeyring(encryption.Key{ID: "next", Secret: []byte("fedcba9876543210fedcba9876543210")}, []encryption.Key{old}, "")
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	svc.enc = enc
+
+	later := svc.now().Add(totpPeriod)
+	svc.now = func() time.Time { return later }
+	if err := svc.Verify(ctx, userID, hotp(secret, timeStep(later))); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	// The secret was re-encrypted on that read, so rotation has nothing
+	// left to do.
+	if n, err := svc.RotateSecrets(ctx); err != nil || n != 0 {
+		t.Errorf("expected the secret to be upgraded already, rotated %d (%v)", n, err)
+	}
+}
@@ -0,0 +1,72 @@
+package mfa
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// TOTP parameters (RFC 6238). These are the defaults every authenticator
+// app supports, so they are not configurable.
+const (
+	totpPeriod = 30 * time.Second
+	totpDigits = 6
+	// totpSkew is how many steps before and after the current one are
+	// accepted to allow for clock drift.
+	totpSkew = 1
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// timeStep returns the TOTP time step containing t.
+func timeStep(t time.Time) int64 {
+	return t.Unix() / int64(totpPeriod/time.Second)
+}
+
+// hotp computes the RFC 4226 code for counter.
+func hotp(secret []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1_000_000)
+}
+
+// matchStep returns the time step around now for which code is valid.
+func matchStep(secret []byte, code string, now time.Time) (int64, bool) {
+	current := timeStep(now)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(hotp(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// provisioningURI returns the otpauth:// URI authenticator apps read from
+// a QR code.
+func provisioningURI(issuer, account string, secret []byte) string {
+	q := url.Values{}
+	q.Set("secret", b32.EncodeToString(secret))
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(int(totpPeriod/time.Second)))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
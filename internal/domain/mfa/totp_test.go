@@ -0,0 +1,51 @@
+package mfa
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B test vectors for SHA-1, truncated to six digits.
+func TestHOTPVectors(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, v := range vectors {
+		if got := hotp(secret, timeStep(time.Unix(v.unix, 0))); got != v.code {
+			t.Errorf("t=%d: expected %s, got %s", v.unix, v.code, got)
+		}
+	}
+}
+
+func TestMatchStepAllowsSkew(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	now := time.Unix(1234567890, 0)
+
+	prev := hotp(secret, timeStep(now)-1)
+	if step, ok := matchStep(secret, prev, now); !ok || step != timeStep(now)-1 {
+		t.Errorf("previous step should match, got %d %v", step, ok)
+	}
+	old := hotp(secret, timeStep(now)-2)
+	if _, ok := matchStep(secret, old, now); ok {
+		t.Error("code two steps old should not match")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := provisioningURI("MailDruid", "jane@example.com", []byte("12345678901234567890"))
+	if !strings.HasPrefix(uri, "otpauth://totp/MailDruid:jane@example.com?") {
+		t.Errorf("unexpected URI %q", uri)
+	}
+	if !strings.Contains(uri, "secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ") || !strings.Contains(uri, "issuer=MailDruid") {
+		t.Errorf("URI missing secret or issuer: %q", uri)
+	}
+}
@@ -0,0 +1,59 @@
+package passwordreset
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryRepository is an in-memory password reset repository for testing.
+type MemoryRepository struct {
+	mu     sync.Mutex
+	resets map[string]*Reset
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{resets: make(map[string]*Reset)}
+}
+
+func (m *MemoryRepository) Save(_ context.Context, r *Reset) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := *r
+	m.resets[r.UserID] = &cp
+	return nil
+}
+
+func (m *MemoryRepository) FindByUser(_ context.Context, userID string) (*Reset, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.resets[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *r
+	return &cp, nil
+}
+
+func (m *MemoryRepository) FindByTokenHash(_ context.Context, hash string) (*Reset, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range m.resets {
+		if r.TokenHash == hash {
+			cp := *r
+			return &cp, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MemoryRepository) DeleteByTokenHash(_ context.Context, hash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, r := range m.resets {
+		if r.TokenHash == hash {
+			delete(m.resets, id)
+			return true, nil
+		}
+	}
+	return false, nil
+}
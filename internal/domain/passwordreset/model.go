@@ -0,0 +1,23 @@
+package passwordreset
+
+import (
+	"errors"
+	"time"
+)
+
+// Domain errors.
+var (
+	ErrNotFound     = errors.New("password reset not found")
+	ErrInvalidToken = errors.New("invalid or expired reset token")
+)
+
+// Reset is an outstanding password reset. A user has at most one;
+// requesting another replaces it.
+type Reset struct {
+	UserID    string `gorm:"primaryKey"`
+	TokenHash string `gorm:"uniqueIndex"` // SHA-256 of the emailed token
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+func (Reset) TableName() string { return "password_resets" }
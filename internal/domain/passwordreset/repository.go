@@ -0,0 +1,15 @@
+package passwordreset
+
+import "context"
+
+// Repository defines persistence operations for password resets.
+type Repository interface {
+	// Save stores r, replacing any reset the user already has.
+	Save(ctx context.Context, r *Reset) error
+	FindByUser(ctx context.Context, userID string) (*Reset, error)
+	FindByTokenHash(ctx context.Context, hash string) (*Reset, error)
+	// DeleteByTokenHash removes the reset with the given token hash and
+	// reports whether there was one, so that concurrent uses of a token
+	// cannot both succeed.
+	DeleteByTokenHash(ctx context.Context, hash string) (bool, error)
+}
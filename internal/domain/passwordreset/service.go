@@ -0,0 +1,163 @@
+package passwordreset
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+)
+
+const (
+	// tokenTTL is how long a reset link stays valid.
+	tokenTTL = time.Hour
+	// resendInterval limits how often a link is sent to one account.
+	resendInterval = time.Minute
+	tokenBytes     = 32
+)
+
+// Mailer delivers password reset links.
+type Mailer interface {
+	SendPasswordReset(to, name, link string, expires time.Time) error
+}
+
+// Service lets users who forgot their password set a new one through a
+// link sent to their login email.
+type Service struct {
+	repo     Repository
+	userSvc  *user.Service
+	mailer   Mailer
+	resetURL string
+	logger   *slog.Logger
+	now      func() time.Time
+	pending  sync.WaitGroup
+}
+
+// NewService creates a password reset service. resetURL is the page that
+// collects the new password; the token is appended as the "token" query
+// parameter.
+func NewService(repo Repository, userSvc *user.Service, mailer Mailer, resetURL string, logger *slog.Logger) *Service {
+	return &Service{
+		repo:     repo,
+		userSvc:  userSvc,
+		mailer:   mailer,
+		resetURL: resetURL,
+		logger:   logger,
+		now:      time.Now,
+	}
+}
+
+// Request emails a reset link to the account with the given login email.
+// The request is handled in the background and Request returns at once,
+// so neither the answer nor the time it takes reveals whether an account
+// exists. Unknown and disabled accounts are ignored; failures are logged.
+func (s *Service) Request(ctx context.Context, email string) {
+	ctx = context.WithoutCancel(ctx)
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		if err := s.request(ctx, email); err != nil {
+			s.logger.Error("password reset request failed", "error", err)
+		}
+	}()
+}
+
+// Wait blocks until every request in progress has been handled.
+func (s *Service) Wait() {
+	s.pending.Wait()
+}
+
+func (s *Service) request(ctx context.Context, email string) error {
+	u, err := s.userSvc.GetByEmail(ctx, email)
+	if errors.Is(err, user.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if u.Disabled {
+		s.logger.Info("password reset requested for disabled account", "user_id", u.ID)
+		return nil
+	}
+
+	now := s.now()
+	prev, err := s.repo.FindByUser(ctx, u.ID)
+	switch {
+	case err == nil:
+		if now.Sub(prev.CreatedAt) < resendInterval {
+			return nil
+		}
+	case !errors.Is(err, ErrNotFound):
+		return err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+	r := &Reset{
+		UserID:    u.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(tokenTTL),
+		CreatedAt: now,
+	}
+	if err := s.repo.Save(ctx, r); err != nil {
+		return err
+	}
+
+	link := s.resetURL + "?" + url.Values{"token": {token}}.Encode()
+	if err := s.mailer.SendPasswordReset(u.Email, u.Name, link, r.ExpiresAt); err != nil {
+		return fmt.Errorf("sending password reset email: %w", err)
+	}
+	s.logger.Info("password reset email sent", "user_id", u.ID)
+	return nil
+}
+
+// Reset sets a new password using the token from a reset email. Tokens
+// work once and expire after an hour. Every session of the user is signed
+// out.
+func (s *Service) Reset(ctx context.Context, token, password string) error {
+	r, err := s.repo.FindByTokenHash(ctx, hashToken(token))
+	if errors.Is(err, ErrNotFound) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+
+	// Consume the token before using it so it cannot be used twice.
+	deleted, err := s.repo.DeleteByTokenHash(ctx, r.TokenHash)
+	if err != nil {
+		return err
+	}
+	if !deleted || !s.now().Before(r.ExpiresAt) {
+		return ErrInvalidToken
+	}
+
+	err = s.userSvc.ResetPassword(ctx, r.UserID, password)
+	if errors.Is(err, user.ErrNotFound) {
+		return ErrInvalidToken
+	}
+	return err
+}
+
+func newToken() (string, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating reset token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,161 @@
+package passwordreset
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/encryption"
+)
+
+type fakeMailer struct {
+	links []string
+}
+
+func (m *fakeMailer) SendPasswordReset(_, _, link string, _ time.Time) error {
+	m.links = append(m.links, link)
+	return nil
+}
+
+func (m *fakeMailer) token(t *testing.T) string {
+	t.Helper()
+	if len(m.links) == 0 {
+		t.Fatal("no reset email sent")
+	}
+	u, err := url.Parse(m.links[len(m.links)-1])
+	if err != nil {
+		t.Fatalf("bad link: %v", err)
+	}
+	return u.Query().Get("token")
+}
+
+func setupTestService(t *testing.T) (*Service, *user.Service, *fakeMailer) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	enc, err := encryption.New([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("encryption.New: %v", err)
+	}
+	userSvc := user.NewService(user.NewMemoryRepository(), enc, audit.NewService(audit.NewMemoryRepository(), logger), logger)
+	if err := userSvc.Create(context.Background(), user.CreateInput{
+		Name: "Test", Email: "u@ex.com", ReceivingEmail: "r@ex.com",
+		Password: "secret123",
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	mailer := &fakeMailer{}
+	svc := NewService(NewMemoryRepository(), userSvc, mailer, "https://maildruid.test/reset-password", logger)
+	return svc, userSvc, mailer
+}
+
+func TestRequestAndReset(t *testing.T) {
+	svc, userSvc, mailer := setupTestService(t)
+	ctx := context.Background()
+
+	svc.Request(ctx, "u@ex.com")
+	svc.Wait()
+	token := mailer.token(t)
+	genBefore, _ := userSvc.TokenGeneration(ctx, mustID(t, userSvc))
+
+	if err := svc.Reset(ctx, "bogus", "newpassword"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+	if err := svc.Reset(ctx, token, "newpassword"); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if _, err := userSvc.Authenticate(ctx, "u@ex.com", "newpassword"); err != nil {
+		t.Errorf("expected new password to work, got %v", err)
+	}
+	if _, err := userSvc.Authenticate(ctx, "u@ex.com", "secret123"); !errors.Is(err, user.ErrInvalidPassword) {
+		t.Errorf("expected old password to stop working, got %v", err)
+	}
+	if gen, _ := userSvc.TokenGeneration(ctx, mustID(t, userSvc)); gen != genBefore+1 {
+		t.Errorf("expected sessions to be revoked, generation %d -> %d", genBefore, gen)
+	}
+	if err := svc.Reset(ctx, token, "another-password"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected token to be single-use, got %v", err)
+	}
+}
+
+func TestRequestUnknownEmail(t *testing.T) {
+	svc, _, mailer := setupTestService(t)
+
+	svc.Request(context.Background(), "nobody@ex.com")
+	svc.Wait()
+	if len(mailer.links) != 0 {
+		t.Errorf("expected no email, got %d", len(mailer.links))
+	}
+}
+
+func TestResetExpiryAndResendLimit(t *testing.T) {
+	svc, _, mailer := setupTestService(t)
+	ctx := context.Background()
+	now := time.Now()
+	svc.now = func() time.Time { return now }
+
+	svc.Request(ctx, "u@ex.com")
+	svc.Wait()
+	svc.Request(ctx, "u@ex.com")
+	svc.Wait()
+	if len(mailer.links) != 1 {
+		t.Errorf("expected repeated requests within a minute to send one email, got %d", len(mailer.links))
+	}
+
+	now = now.Add(tokenTTL)
+	if err := svc.Reset(ctx, mailer.token(t), "newpassword"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected expired token to be rejected, got %v", err)
+	}
+}
+
+func mustID(t *testing.T, userSvc *user.Service) string {
+	t.Helper()
+	u, err := userSvc.GetByEmail(context.Background(), "u@ex.com")
+	if err != nil {
+		t.Fatalf("GetByEmail: %v", err)
+	}
+	return u.ID
+}
+
+// blockingMailer holds every email until release is closed.
+type blockingMailer struct {
+	release chan struct{}
+	sent    int
+}
+
+func (m *blockingMailer) SendPasswordReset(_, _, _ string, _ time.Time) error {
+	<-m.release
+	m.sent++
+	return nil
+}
+
+func TestRequestDoesNotWaitForEmail(t *testing.T) {
+	svc, _, _ := setupTestService(t)
+	mailer := &blockingMailer{release: make(chan struct{})}
+	svc.mailer = mailer
+
+	// Known accounts answer as fast as unknown ones: sending the email
+	// does not hold up the request.
+	done := make(chan struct{})
+	go func() {
+		svc.Request(context.Background(), "u@ex.com")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Request to return before the email is sent")
+	}
+
+	close(mailer.release)
+	svc.Wait()
+	if mailer.sent != 1 {
+		t.Errorf("expected the email to be sent in the background, got %d", mailer.sent)
+	}
+}
@@ -0,0 +1,111 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryRepository is an in-memory session repository for testing.
+type MemoryRepository struct {
+	mu       sync.RWMutex
+	sessions map[string]*Session
+	revoked  map[string]*RevokedToken
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		sessions: make(map[string]*Session),
+		revoked:  make(map[string]*RevokedToken),
+	}
+}
+
+func (r *MemoryRepository) Create(_ context.Context, s *Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s.CreatedAt = time.Now()
+	cp := *s
+	r.sessions[s.ID] = &cp
+	return nil
+}
+
+func (r *MemoryRepository) FindByTokenHash(_ context.Context, hash string) (*Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, s := range r.sessions {
+		if s.TokenHash == hash {
+			cp := *s
+			return &cp, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryRepository) Rotate(_ context.Context, oldID string, at time.Time, next *Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old, ok := r.sessions[oldID]
+	if !ok {
+		return ErrNotFound
+	}
+	if old.RevokedAt != nil {
+		return ErrRevoked
+	}
+	old.RevokedAt = &at
+	old.ReplacedBy = next.ID
+	next.CreatedAt = at
+	cp := *next
+	r.sessions[next.ID] = &cp
+	return nil
+}
+
+func (r *MemoryRepository) Revoke(_ context.Context, id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.sessions[id]; ok && s.RevokedAt == nil {
+		s.RevokedAt = &at
+	}
+	return nil
+}
+
+func (r *MemoryRepository) RevokeAllForUser(_ context.Context, userID string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.sessions {
+		if s.UserID == userID && s.RevokedAt == nil {
+			s.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) RevokeToken(_ context.Context, t *RevokedToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := *t
+	r.revoked[t.TokenID] = &cp
+	return nil
+}
+
+func (r *MemoryRepository) IsTokenRevoked(_ context.Context, tokenID string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.revoked[tokenID]
+	return ok, nil
+}
+
+func (r *MemoryRepository) DeleteExpired(_ context.Context, before time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, s := range r.sessions {
+		if s.ExpiresAt.Before(before) {
+			delete(r.sessions, id)
+		}
+	}
+	for id, t := range r.revoked {
+		if t.ExpiresAt.Before(before) {
+			delete(r.revoked, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package session
+
+import (
+	"errors"
+	"time"
+)
+
+// Domain errors.
+var (
+	ErrNotFound     = errors.New("session not found")
+	ErrInvalidToken = errors.New("invalid or expired refresh token")
+	// ErrRevoked is returned by Repository.Rotate when the session was
+	// already revoked, e.g. by a concurrent refresh with the same token.
+	ErrRevoked = errors.New("session already revoked")
+)
+
+// Session is a refresh token issued at login. Refreshing rotates it: the
+// old session is revoked and points at its replacement, so presenting an
+// already rotated token can be detected as reuse.
+type Session struct {
+	ID        string `gorm:"primaryKey"`
+	UserID    string `gorm:"index"`
+	TokenHash string `gorm:"uniqueIndex"`
+	// Generation is the user's token generation at issue time. Sessions
+	// from an older generation can no longer be refreshed.
+	Generation int
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+	ReplacedBy string
+	CreatedAt  time.Time
+}
+
+// Active reports whether the session can still be refreshed at now.
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// RevokedToken records an access token that was revoked before its expiry,
+// e.g. on logout. Entries can be dropped once ExpiresAt has passed.
+type RevokedToken struct {
+	TokenID   string `gorm:"primaryKey"`
+	UserID    string
+	ExpiresAt time.Time
+}
@@ -0,0 +1,29 @@
+package session
+
+import (
+	"context"
+	"time"
+)
+
+// Repository defines persistence operations for sessions and revoked
+// access tokens.
+type Repository interface {
+	Create(ctx context.Context, s *Session) error
+	// FindByTokenHash returns the session for a refresh token hash or
+	// ErrNotFound.
+	FindByTokenHash(ctx context.Context, hash string) (*Session, error)
+	// Rotate atomically revokes the session oldID and creates next. It
+	// returns ErrRevoked if oldID was already revoked.
+	Rotate(ctx context.Context, oldID string, at time.Time, next *Session) error
+	// Revoke marks a single session as revoked.
+	Revoke(ctx context.Context, id string, at time.Time) error
+	// RevokeAllForUser revokes every active session of the user.
+	RevokeAllForUser(ctx context.Context, userID string, at time.Time) error
+	// RevokeToken adds an access token ID to the revocation list.
+	RevokeToken(ctx context.Context, t *RevokedToken) error
+	// IsTokenRevoked reports whether an access token ID was revoked.
+	IsTokenRevoked(ctx context.Context, tokenID string) (bool, error)
+	// DeleteExpired removes sessions and revoked tokens that expired
+	// before the given time.
+	DeleteExpired(ctx context.Context, before time.Time) error
+}
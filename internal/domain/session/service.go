@@ -0,0 +1,187 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+	"github.com/gofrs/uuid"
+)
+
+// refreshTokenBytes is the amount of randomness in a refresh token.
+const refreshTokenBytes = 32
+
+// Service issues, rotates and revokes refresh-token sessions and tracks
+// revoked access tokens.
+type Service struct {
+	repo    Repository
+	userSvc *user.Service
+	ttl     time.Duration
+	logger  *slog.Logger
+	now     func() time.Time
+}
+
+// NewService creates a session service whose refresh tokens live for ttl.
+func NewService(repo Repository, userSvc *user.Service, ttl time.Duration, logger *slog.Logger) *Service {
+	return &Service{repo: repo, userSvc: userSvc, ttl: ttl, logger: logger, now: time.Now}
+}
+
+// Issue starts a new session for the user and returns it together with the
+// plaintext refresh token. Only a hash of the token is stored.
+func (s *Service) Issue(ctx context.Context, userID string) (*Session, string, error) {
+	gen, err := s.userSvc.TokenGeneration(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := s.repo.DeleteExpired(ctx, s.now()); err != nil {
+		s.logger.Warn("failed to purge expired sessions", "error", err)
+	}
+
+	sess, token, err := s.newSession(userID, gen)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.repo.Create(ctx, sess); err != nil {
+		return nil, "", err
+	}
+	return sess, token, nil
+}
+
+// Refresh exchanges a refresh token for a new session and token. The old
+// token stops working. Presenting a token that was already rotated is
+// treated as theft: every session of that user is revoked.
+func (s *Service) Refresh(ctx context.Context, token string) (*Session, string, error) {
+	old, err := s.repo.FindByTokenHash(ctx, hashToken(token))
+	if errors.Is(err, ErrNotFound) {
+		return nil, "", ErrInvalidToken
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := s.now()
+	if old.RevokedAt != nil && old.ReplacedBy != "" {
+		s.revokeOnReuse(ctx, old.UserID, now)
+		return nil, "", ErrInvalidToken
+	}
+	if !old.Active(now) {
+		return nil, "", ErrInvalidToken
+	}
+
+	gen, err := s.userSvc.TokenGeneration(ctx, old.UserID)
+	if errors.Is(err, user.ErrNotFound) {
+		return nil, "", ErrInvalidToken
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if gen != old.Generation {
+		return nil, "", ErrInvalidToken
+	}
+
+	next, nextToken, err := s.newSession(old.UserID, gen)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.repo.Rotate(ctx, old.ID, now, next); err != nil {
+		if errors.Is(err, ErrRevoked) {
+			s.revokeOnReuse(ctx, old.UserID, now)
+			return nil, "", ErrInvalidToken
+		}
+		return nil, "", err
+	}
+	return next, nextToken, nil
+}
+
+// Revoke ends the session identified by token if it belongs to userID.
+// Unknown tokens are ignored so logout is idempotent.
+func (s *Service) Revoke(ctx context.Context, userID, token string) error {
+	sess, err := s.repo.FindByTokenHash(ctx, hashToken(token))
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if sess.UserID != userID {
+		return nil
+	}
+	return s.repo.Revoke(ctx, sess.ID, s.now())
+}
+
+// RevokeAll ends every session of the user.
+func (s *Service) RevokeAll(ctx context.Context, userID string) error {
+	return s.repo.RevokeAllForUser(ctx, userID, s.now())
+}
+
+// RevokeAccessToken adds an access token to the revocation list until it
+// would have expired anyway.
+func (s *Service) RevokeAccessToken(ctx context.Context, userID, tokenID string, expiresAt time.Time) error {
+	if tokenID == "" {
+		return nil
+	}
+	return s.repo.RevokeToken(ctx, &RevokedToken{TokenID: tokenID, UserID: userID, ExpiresAt: expiresAt})
+}
+
+// IsRevoked reports whether an access token with the given claims is no
+// longer valid: its user is gone, the user's token generation has moved on,
+// or the token ID itself was revoked.
+func (s *Service) IsRevoked(ctx context.Context, userID string, generation int, tokenID string) (bool, error) {
+	gen, err := s.userSvc.TokenGeneration(ctx, userID)
+	if errors.Is(err, user.ErrNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if gen != generation {
+		return true, nil
+	}
+	if tokenID == "" {
+		return false, nil
+	}
+	return s.repo.IsTokenRevoked(ctx, tokenID)
+}
+
+func (s *Service) revokeOnReuse(ctx context.Context, userID string, now time.Time) {
+	s.logger.Warn("refresh token reuse detected, revoking all sessions", "user_id", userID)
+	if err := s.repo.RevokeAllForUser(ctx, userID, now); err != nil {
+		s.logger.Error("failed to revoke sessions", "user_id", userID, "error", err)
+	}
+}
+
+func (s *Service) newSession(userID string, generation int) (*Session, string, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, "", fmt.Errorf("generating session ID: %w", err)
+	}
+
+	raw := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", fmt.Errorf("generating refresh token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	return &Session{
+		ID:         id.String(),
+		UserID:     userID,
+		TokenHash:  hashToken(token),
+		Generation: generation,
+		ExpiresAt:  s.now().Add(s.ttl),
+	}, token, nil
+}
+
+// hashToken returns the stored form of a refresh token. Tokens carry 256
+// bits of randomness, so an unsalted SHA-256 is sufficient.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,164 @@
+package session
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/encryption"
+)
+
+func setupTestService(t *testing.T) (*Service, *user.Service, string) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	enc, err := encryption.New([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("encryption.New: %v", err)
+	}
+	userSvc := user.NewService(user.NewMemoryRepository(), enc, audit.NewService(audit.NewMemoryRepository(), logger), logger)
+
+	ctx := context.Background()
+	if err := userSvc.Create(ctx, user.CreateInput{
+		Name: "User", Email: "u@ex.com", ReceivingEmail: "r@ex.com",
+		Password: "login-pass",
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	id, err := userSvc.Authenticate(ctx, "u@ex.com", "login-pass")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	return NewService(NewMemoryRepository(), userSvc, time.Hour, logger), userSvc, id
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	svc, _, userID := setupTestService(t)
+	ctx := context.Background()
+
+	first, token, err := svc.Issue(ctx, userID)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if first.TokenHash == token {
+		t.Fatal("refresh token must not be stored in plaintext")
+	}
+
+	second, token2, err := svc.Refresh(ctx, token)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if second.ID == first.ID || token2 == token {
+		t.Fatal("refresh should issue a new session and token")
+	}
+
+	if _, _, err := svc.Refresh(ctx, token2); err != nil {
+		t.Fatalf("Refresh with rotated token: %v", err)
+	}
+}
+
+func TestRefreshReuseRevokesAllSessions(t *testing.T) {
+	svc, _, userID := setupTestService(t)
+	ctx := context.Background()
+
+	_, token, _ := svc.Issue(ctx, userID)
+	_, other, _ := svc.Issue(ctx, userID)
+
+	_, rotated, err := svc.Refresh(ctx, token)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	// Replaying the old token is treated as theft.
+	if _, _, err := svc.Refresh(ctx, token); err != ErrInvalidToken {
+		t.Fatalf("expected ErrInvalidToken on reuse, got %v", err)
+	}
+	for name, tok := range map[string]string{"rotated": rotated, "other": other} {
+		if _, _, err := svc.Refresh(ctx, tok); err != ErrInvalidToken {
+			t.Errorf("%s session should be revoked after reuse, got %v", name, err)
+		}
+	}
+}
+
+func TestRefreshRejectsExpiredAndUnknown(t *testing.T) {
+	svc, _, userID := setupTestService(t)
+	ctx := context.Background()
+
+	if _, _, err := svc.Refresh(ctx, "not-a-token"); err != ErrInvalidToken {
+		t.Fatalf("expected ErrInvalidToken, got %v", err)
+	}
+
+	_, token, _ := svc.Issue(ctx, userID)
+	svc.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if _, _, err := svc.Refresh(ctx, token); err != ErrInvalidToken {
+		t.Fatalf("expected ErrInvalidToken for expired token, got %v", err)
+	}
+}
+
+func TestRevokeTokensInvalidatesSessions(t *testing.T) {
+	svc, userSvc, userID := setupTestService(t)
+	ctx := context.Background()
+
+	sess, token, _ := svc.Issue(ctx, userID)
+	if revoked, err := svc.IsRevoked(ctx, userID, sess.Generation, "jti-1"); err != nil || revoked {
+		t.Fatalf("fresh token should be valid: revoked=%v err=%v", revoked, err)
+	}
+
+	if err := userSvc.RevokeTokens(ctx, userID); err != nil {
+		t.Fatalf("RevokeTokens: %v", err)
+	}
+	if revoked, _ := svc.IsRevoked(ctx, userID, sess.Generation, "jti-1"); !revoked {
+		t.Error("access token from an old generation should be revoked")
+	}
+	if _, _, err := svc.Refresh(ctx, token); err != ErrInvalidToken {
+		t.Errorf("refresh from an old generation: expected ErrInvalidToken, got %v", err)
+	}
+}
+
+func TestLogoutRevokesSessionAndAccessToken(t *testing.T) {
+	svc, _, userID := setupTestService(t)
+	ctx := context.Background()
+
+	sess, token, _ := svc.Issue(ctx, userID)
+
+	// Another user's logout cannot end this session.
+	if err := svc.Revoke(ctx, "someone-else", token); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, token, _ = svc.Refresh(ctx, token); token == "" {
+		t.Fatal("session should survive a foreign logout")
+	}
+
+	if err := svc.Revoke(ctx, userID, token); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, _, err := svc.Refresh(ctx, token); err != ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken after logout, got %v", err)
+	}
+
+	if err := svc.RevokeAccessToken(ctx, userID, "jti-1", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("RevokeAccessToken: %v", err)
+	}
+	if revoked, _ := svc.IsRevoked(ctx, userID, sess.Generation, "jti-1"); !revoked {
+		t.Error("revoked access token should be reported as revoked")
+	}
+	if revoked, _ := svc.IsRevoked(ctx, userID, sess.Generation, "jti-2"); revoked {
+		t.Error("other access tokens should stay valid")
+	}
+}
+
+func TestIsRevokedForDeletedUser(t *testing.T) {
+	svc, userSvc, userID := setupTestService(t)
+	ctx := context.Background()
+
+	if err := userSvc.Delete(ctx, userID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if revoked, err := svc.IsRevoked(ctx, userID, 0, "jti"); err != nil || !revoked {
+		t.Errorf("tokens of a deleted user should be revoked: revoked=%v err=%v", revoked, err)
+	}
+}
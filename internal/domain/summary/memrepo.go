@@ -0,0 +1,79 @@
+package summary
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryRepository is an in-memory digest repository for testing.
+type MemoryRepository struct {
+	mu      sync.RWMutex
+	digests map[string]*Digest
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{digests: make(map[string]*Digest)}
+}
+
+func (r *MemoryRepository) Create(_ context.Context, d *Digest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.digests[d.ID] = d
+	return nil
+}
+
+func (r *MemoryRepository) FindByID(_ context.Context, userID, id string) (*Digest, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.digests[id]
+	if !ok || d.UserID != userID {
+		return nil, ErrDigestNotFound
+	}
+	cp := *d
+	return &cp, nil
+}
+
+func (r *MemoryRepository) ListByUser(_ context.Context, userID string, limit, offset int) ([]*Digest, int64, error) {
+	return r.list(func(d *Digest) bool { return d.UserID == userID }, limit, offset)
+}
+
+func (r *MemoryRepository) FindByTeamDigest(_ context.Context, teamDigestID, id string) (*Digest, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.digests[id]
+	if !ok || d.TeamDigestID != teamDigestID {
+		return nil, ErrDigestNotFound
+	}
+	cp := *d
+	return &cp, nil
+}
+
+func (r *MemoryRepository) ListByTeamDigest(_ context.Context, teamDigestID string, limit, offset int) ([]*Digest, int64, error) {
+	return r.list(func(d *Digest) bool { return d.TeamDigestID == teamDigestID }, limit, offset)
+}
+
+func (r *MemoryRepository) list(match func(*Digest) bool, limit, offset int) ([]*Digest, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var all []*Digest
+	for _, d := range r.digests {
+		if match(d) {
+			cp := *d
+			cp.WordCloud = nil
+			all = append(all, &cp)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].CreatedAt.After(all[j].CreatedAt) })
+
+	total := int64(len(all))
+	if offset >= len(all) {
+		return []*Digest{}, total, nil
+	}
+	end := offset + limit
+	if end > len(all) {
+		end = len(all)
+	}
+	return all[offset:end], total, nil
+}
@@ -0,0 +1,56 @@
+package summary
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrDigestNotFound is returned when a stored digest does not exist or
+// belongs to another user or team digest.
+var ErrDigestNotFound = errors.New("digest not found")
+
+// ErrNoMatches is returned when a run finds no new mail matching the tags.
+var ErrNoMatches = errors.New("no emails found with tags")
+
+// Trigger identifies what started a summary run.
+type Trigger string
+
+const (
+	TriggerManual    Trigger = "manual"
+	TriggerScheduled Trigger = "scheduled"
+	TriggerPush      Trigger = "push" // new mail on a mailbox in push mode
+)
+
+// Digest is the persisted record of a completed summary run. It belongs to
+// either a user or a team digest configuration; the other owner is empty
+// and stored as NULL.
+type Digest struct {
+	ID           string         `json:"id" gorm:"primaryKey"`
+	UserID       string         `json:"userId,omitempty" gorm:"index;default:null"`
+	TeamDigestID string         `json:"teamDigestId,omitempty" gorm:"index;default:null"`
+	Summary      string         `json:"summary"`
+	Keywords     pq.StringArray `json:"keywords" gorm:"type:text[]"`
+	WordCloud    []byte         `json:"-"`
+	Tags         pq.StringArray `json:"tags" gorm:"type:text[]"`
+	EmailCount   int            `json:"emailCount"`
+	Truncated    int            `json:"truncated"` // emails cut short by the per-message limit
+	Skipped      int            `json:"skipped"`   // matching emails left out by the total limit
+	FirstUID     int            `json:"firstUid"`  // zero unless the mail came from one folder
+	LastUID      int            `json:"lastUid"`
+	Folders      []FolderRange  `json:"folders" gorm:"serializer:json"`
+	Trigger      Trigger        `json:"trigger"`
+	StartedAt    time.Time      `json:"startedAt"`
+	CreatedAt    time.Time      `json:"createdAt"`
+}
+
+// FolderRange is the span of UIDs a run read from one folder of one
+// mailbox. UIDs are only comparable within a folder, so a digest keeps
+// one range per folder it read mail from.
+type FolderRange struct {
+	MailboxID string `json:"mailboxId"`
+	Folder    string `json:"folder"`
+	FirstUID  int    `json:"firstUid"`
+	LastUID   int    `json:"lastUid"`
+}
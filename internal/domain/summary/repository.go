@@ -0,0 +1,18 @@
+package summary
+
+import "context"
+
+// Repository defines persistence operations for digests.
+type Repository interface {
+	Create(ctx context.Context, d *Digest) error
+	// FindByID returns the digest with the given ID owned by userID.
+	FindByID(ctx context.Context, userID, id string) (*Digest, error)
+	// ListByUser returns a page of the user's digests, newest first, without
+	// word cloud images, along with the total number of digests.
+	ListByUser(ctx context.Context, userID string, limit, offset int) ([]*Digest, int64, error)
+	// FindByTeamDigest returns the run with the given ID of a team digest.
+	FindByTeamDigest(ctx context.Context, teamDigestID, id string) (*Digest, error)
+	// ListByTeamDigest returns a page of a team digest's runs, newest first,
+	// without word cloud images, along with the total number of runs.
+	ListByTeamDigest(ctx context.Context, teamDigestID string, limit, offset int) ([]*Digest, int64, error)
+}
@@ -2,120 +2,368 @@ package summary
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
-	"strings"
+	"os"
+	"sort"
+	"time"
 
+	"github.com/akhil-datla/maildruid/internal/domain/mailbox"
+	"github.com/akhil-datla/maildruid/internal/domain/syncstate"
+	"github.com/akhil-datla/maildruid/internal/domain/team"
 	"github.com/akhil-datla/maildruid/internal/domain/user"
 	imapClient "github.com/akhil-datla/maildruid/internal/infrastructure/imap"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/oauth"
 	"github.com/akhil-datla/maildruid/internal/infrastructure/wordcloud"
+	"github.com/gofrs/uuid"
 )
 
+// defaultFolder is scanned when no folder has been chosen.
+const defaultFolder = "INBOX"
+
+// maxStoredKeywords caps the number of keywords persisted with a digest.
+const maxStoredKeywords = 50
+
 // Result holds the output of an email summarization.
 type Result struct {
+	DigestID      string
 	Summary       string
 	WordCloudPath string
+	Truncated     int // emails cut short by the per-message limit
+	Skipped       int // matching emails left out by the total limit
+}
+
+// Note describes the emails the memory limits cut short or left out, or
+// returns "" when there were none.
+func (r *Result) Note() string {
+	if r.Truncated == 0 && r.Skipped == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d long emails were truncated and %d emails were skipped to stay within the size limits.", r.Truncated, r.Skipped)
 }
 
 // Service orchestrates the email summarization pipeline.
 type Service struct {
-	userSvc   *user.Service
-	generator *wordcloud.Generator
-	logger    *slog.Logger
+	userSvc    *user.Service
+	mailboxSvc *mailbox.Service
+	teamSvc    *team.Service
+	providers  *oauth.Providers
+	generator  *wordcloud.Generator
+	digests    Repository
+	syncStates syncstate.Repository
+	timeouts   imapClient.Timeouts
+	pool       *imapClient.Pool // nil to sign in for every run
+	limits     imapClient.Limits
+	logger     *slog.Logger
 }
 
 // NewService creates a new summary service.
-func NewService(userSvc *user.Service, gen *wordcloud.Generator, logger *slog.Logger) *Service {
-	return &Service{userSvc: userSvc, generator: gen, logger: logger}
+func NewService(
+	userSvc *user.Service,
+	mailboxSvc *mailbox.Service,
+	teamSvc *team.Service,
+	providers *oauth.Providers,
+	gen *wordcloud.Generator,
+	digests Repository,
+	syncStates syncstate.Repository,
+	timeouts imapClient.Timeouts,
+	pool *imapClient.Pool,
+	limits imapClient.Limits,
+	logger *slog.Logger,
+) *Service {
+	return &Service{
+		userSvc:    userSvc,
+		mailboxSvc: mailboxSvc,
+		teamSvc:    teamSvc,
+		providers:  providers,
+		generator:  gen,
+		digests:    digests,
+		syncStates: syncStates,
+		timeouts:   timeouts,
+		pool:       pool,
+		limits:     limits,
+		logger:     logger,
+	}
+}
+
+// source describes one run of the pipeline: which mailboxes to read, how
+// to filter their mail and who owns the resulting digest.
+type source struct {
+	owner        string // user or team digest ID, for logging
+	mailboxes    []mailboxSource
+	criteria     imapClient.Criteria
+	summaryCount int
+	digest       Digest // owner fields of the digest to record
 }
 
-// Generate runs the full summarization pipeline for a user.
-func (s *Service) Generate(ctx context.Context, u *user.User) (*Result, error) {
+// mailboxSource describes one mailbox read by a run, the folders to read
+// and where the progress through each folder is kept.
+type mailboxSource struct {
+	id        string // mailbox ID, for logging
+	connect   func(ctx context.Context) (*imapClient.Client, error)
+	folders   []string // folder names or wildcard patterns; INBOX when empty
+	loadState func(ctx context.Context, folder string) (*syncstate.State, error)
+	saveState func(ctx context.Context, s *syncstate.State) error
+}
+
+// Generate runs the full summarization pipeline over the user's enabled
+// mailboxes and records the merged result in the digest history.
+func (s *Service) Generate(ctx context.Context, u *user.User, trigger Trigger) (*Result, error) {
 	if len(u.Tags) == 0 {
 		return nil, user.ErrNoTags
 	}
 
-	password, err := s.userSvc.DecryptPassword(u)
+	mailboxes, err := s.mailboxSvc.Enabled(ctx, u.ID)
 	if err != nil {
-		return nil, fmt.Errorf("decrypting password: %w", err)
+		return nil, fmt.Errorf("loading mailboxes: %w", err)
+	}
+	if len(mailboxes) == 0 {
+		return nil, mailbox.ErrNoMailboxes
+	}
+	return s.generate(ctx, u, mailboxes, trigger)
+}
+
+// GenerateMailbox runs the pipeline over the new mail of a single enabled
+// mailbox of the user, as push mode does when mail arrives.
+func (s *Service) GenerateMailbox(ctx context.Context, u *user.User, mailboxID string, trigger Trigger) (*Result, error) {
+	if len(u.Tags) == 0 {
+		return nil, user.ErrNoTags
 	}
 
-	im, err := imapClient.New(u.Email, password, u.Domain, u.Port)
+	mb, err := s.mailboxSvc.Get(ctx, mailbox.UserOwner(u.ID), mailboxID)
 	if err != nil {
-		return nil, fmt.Errorf("connecting to IMAP: %w", err)
+		return nil, fmt.Errorf("loading mailbox: %w", err)
 	}
+	if !mb.Enabled {
+		return nil, mailbox.ErrNoMailboxes
+	}
+	return s.generate(ctx, u, []*mailbox.Mailbox{mb}, trigger)
+}
 
-	if u.Folder != "" {
-		if err := im.SelectFolder(u.Folder); err != nil {
-			return nil, fmt.Errorf("selecting folder: %w", err)
-		}
+func (s *Service) generate(ctx context.Context, u *user.User, mailboxes []*mailbox.Mailbox, trigger Trigger) (*Result, error) {
+	src := &source{
+		owner:        u.ID,
+		criteria:     imapClient.Criteria{Tags: u.Tags, Blacklist: u.BlackListSenders, Since: u.StartTime},
+		summaryCount: u.SummaryCount,
+		digest:       Digest{UserID: u.ID},
+	}
+	for _, mb := range mailboxes {
+		src.mailboxes = append(src.mailboxes, mailboxSource{
+			id: mb.ID,
+			connect: func(ctx context.Context) (*imapClient.Client, error) {
+				return s.Connect(ctx, mb)
+			},
+			folders: mb.Folders,
+			loadState: func(ctx context.Context, folder string) (*syncstate.State, error) {
+				state, err := s.syncStates.Get(ctx, mb.ID, folder)
+				if errors.Is(err, syncstate.ErrNotFound) {
+					return &syncstate.State{MailboxID: mb.ID, Folder: folder}, nil
+				}
+				return state, err
+			},
+			saveState: s.syncStates.Save,
+		})
 	}
+	return s.run(ctx, src, trigger)
+}
 
-	// Determine starting UID from last processed state
-	uidMap := make(map[string]int)
-	if u.LastUID != "" {
-		if err := json.Unmarshal([]byte(u.LastUID), &uidMap); err != nil {
-			if !strings.Contains(err.Error(), "unexpected end of JSON input") {
-				return nil, fmt.Errorf("parsing UID map: %w", err)
-			}
-		}
+// GenerateTeam runs the summarization pipeline for a team digest
+// configuration and records the result in that digest's history. The team
+// mailbox is read like a user's, so it may sign in with OAuth2 and read
+// several folders.
+func (s *Service) GenerateTeam(ctx context.Context, d *team.Digest, trigger Trigger) (*Result, error) {
+	if len(d.Tags) == 0 {
+		return nil, user.ErrNoTags
 	}
 
-	for _, tag := range u.Tags {
-		if uidMap[tag] == 0 {
-			uidMap[tag] = 1
-		}
+	mb, err := s.teamSvc.DigestMailbox(ctx, d)
+	if err != nil {
+		return nil, fmt.Errorf("loading mailbox: %w", err)
+	}
+	if !mb.Enabled {
+		return nil, mailbox.ErrNoMailboxes
+	}
+
+	return s.run(ctx, &source{
+		owner: d.ID,
+		mailboxes: []mailboxSource{{
+			id: mb.ID,
+			connect: func(ctx context.Context) (*imapClient.Client, error) {
+				return s.Connect(ctx, mb)
+			},
+			folders: teamFolders(d, mb),
+			loadState: func(ctx context.Context, folder string) (*syncstate.State, error) {
+				state, err := s.teamSvc.SyncState(ctx, d.ID, folder)
+				if errors.Is(err, syncstate.ErrNotFound) {
+					return &syncstate.State{Folder: folder}, nil
+				}
+				return state, err
+			},
+			saveState: func(ctx context.Context, state *syncstate.State) error {
+				return s.teamSvc.SaveSyncState(ctx, d.ID, state)
+			},
+		}},
+		criteria:     imapClient.Criteria{Tags: d.Tags, Blacklist: d.BlackListSenders, Since: d.StartTime},
+		summaryCount: d.SummaryCount,
+		digest:       Digest{TeamDigestID: d.ID},
+	}, trigger)
+}
+
+// teamFolders returns the folders a team digest reads: its own folder
+// when it names one, and the mailbox's folders otherwise.
+func teamFolders(d *team.Digest, mb *mailbox.Mailbox) []string {
+	if d.Folder != "" {
+		return []string{d.Folder}
 	}
+	return mb.Folders
+}
 
-	lowestUID := uidMap[u.Tags[0]]
-	for _, tag := range u.Tags {
-		if uidMap[tag] < lowestUID {
-			lowestUID = uidMap[tag]
+// Progress returns how far the digests reading mb have got through a
+// folder: the owner's digest for a user's mailbox, and every digest that
+// reads the folder for a team's. A digest that has not read the folder yet
+// gets a state without a UIDVALIDITY.
+func (s *Service) Progress(ctx context.Context, mb *mailbox.Mailbox, folder string) ([]*syncstate.State, error) {
+	if mb.TeamID == "" {
+		state, err := s.syncStates.Get(ctx, mb.ID, folder)
+		if errors.Is(err, syncstate.ErrNotFound) {
+			state, err = &syncstate.State{MailboxID: mb.ID, Folder: folder}, nil
 		}
+		if err != nil {
+			return nil, err
+		}
+		return []*syncstate.State{state}, nil
 	}
 
-	// Check for latest UID to avoid overshooting
-	latestUIDs, err := im.GetUIDs("999999999:*")
+	digests, err := s.teamSvc.MailboxDigests(ctx, mb)
 	if err != nil {
-		return nil, fmt.Errorf("getting latest UIDs: %w", err)
+		return nil, err
+	}
+	var states []*syncstate.State
+	for _, d := range digests {
+		if d.Folder != "" && d.Folder != folder {
+			continue
+		}
+		state, err := s.teamSvc.SyncState(ctx, d.ID, folder)
+		if errors.Is(err, syncstate.ErrNotFound) {
+			state, err = &syncstate.State{Folder: folder}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
 	}
-	if len(latestUIDs) > 0 && latestUIDs[len(latestUIDs)-1] < lowestUID {
-		lowestUID = latestUIDs[len(latestUIDs)-1]
+	return states, nil
+}
+
+// Connect returns a signed-in session for a mailbox, reusing one
+// from the pool when it can. The session is closed when ctx is done;
+// callers Close it as soon as they are finished with it, which hands it
+// back to the pool.
+func (s *Service) Connect(ctx context.Context, mb *mailbox.Mailbox) (*imapClient.Client, error) {
+	return s.session(ctx, sessionKey(mb.ID, mb.UpdatedAt), mb.Domain, func(ctx context.Context) (*imapClient.Client, error) {
+		return s.Dial(ctx, mb)
+	})
+}
+
+// Dial signs in to a mailbox on a connection of its own, outside
+// the pool, for long-lived uses such as push mode. Mailboxes linked to an
+// OAuth2 provider get a fresh access token first.
+func (s *Service) Dial(ctx context.Context, mb *mailbox.Mailbox) (*imapClient.Client, error) {
+	if mb.OAuthProvider == "" {
+		password, err := s.mailboxSvc.DecryptPassword(ctx, mb)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting password: %w", err)
+		}
+		return imapClient.New(ctx, mb.Username, password, mb.Domain, mb.Port, s.timeouts)
 	}
 
-	emails, uidList, err := im.GetEmails(u.Folder, lowestUID)
+	token, err := s.accessToken(ctx, mb)
 	if err != nil {
-		return nil, fmt.Errorf("fetching emails: %w", err)
+		return nil, err
 	}
+	return imapClient.NewOAuth2(ctx, mb.Username, token, mb.Domain, mb.Port, s.timeouts)
+}
 
-	if len(emails) == 0 {
-		return nil, fmt.Errorf("no emails found")
+// session takes a session from the pool, or signs in with dial when the
+// service has no pool.
+func (s *Service) session(ctx context.Context, key, host string, dial func(ctx context.Context) (*imapClient.Client, error)) (*imapClient.Client, error) {
+	if s.pool == nil {
+		return dial(ctx)
 	}
+	return s.pool.Get(ctx, key, host, dial)
+}
 
-	// Update UID tracking
-	if len(uidList) > 0 {
-		newUID := uidList[len(uidList)-1]
-		for _, tag := range u.Tags {
-			uidMap[tag] = newUID
-		}
-		uidBytes, err := json.Marshal(uidMap)
-		if err == nil {
-			_ = s.userSvc.SaveLastUID(ctx, u, string(uidBytes))
+// sessionKey identifies the pooled sessions of a mailbox. Editing the
+// mailbox changes its version, so new credentials are used right away and
+// the old sessions expire unused.
+func sessionKey(id string, version time.Time) string {
+	return fmt.Sprintf("mailbox/%s/%d", id, version.UnixNano())
+}
+
+// accessToken trades the mailbox's refresh token for an access token. A
+// refresh token the provider rotated to is stored for the next run.
+func (s *Service) accessToken(ctx context.Context, mb *mailbox.Mailbox) (string, error) {
+	refresh, err := s.mailboxSvc.DecryptOAuthToken(ctx, mb)
+	if err != nil {
+		return "", fmt.Errorf("decrypting refresh token: %w", err)
+	}
+	tok, err := s.providers.Refresh(ctx, mb.OAuthProvider, refresh)
+	if err != nil {
+		return "", err
+	}
+	if tok.RefreshToken != "" && tok.RefreshToken != refresh {
+		if err := s.mailboxSvc.ReplaceOAuthToken(ctx, mb.Owner(), mb.ID, mb.OAuthProvider, tok.RefreshToken); err != nil {
+			s.logger.Error("failed to store rotated refresh token", "mailbox_id", mb.ID, "error", err)
 		}
 	}
+	return tok.AccessToken, nil
+}
+
+func (s *Service) run(ctx context.Context, src *source, trigger Trigger) (*Result, error) {
+	startedAt := time.Now()
 
-	filtered := imapClient.FilterEmails(emails, u.Tags, u.BlackListSenders, u.StartTime)
-	if len(filtered) == 0 {
-		return nil, fmt.Errorf("no emails found with tags: %v", u.Tags)
+	// A mailbox that cannot be read is skipped so the others still make it
+	// into the digest; the run only fails when none could be read.
+	out := imapClient.NewCollector(s.limits.MaxTotalBytes)
+	var folders []FolderRange
+	var firstErr error
+	read := 0
+	for i := range src.mailboxes {
+		mb := &src.mailboxes[i]
+		ranges, err := s.fetch(ctx, src, mb, out)
+		folders = append(folders, ranges...)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			if len(src.mailboxes) > 1 {
+				s.logger.Warn("skipping mailbox", "owner", src.owner, "mailbox_id", mb.id, "error", err)
+			}
+			continue
+		}
+		read++
+	}
+	if read == 0 {
+		return nil, firstErr
 	}
 
-	body := imapClient.AggregateBody(filtered)
+	if len(out.Emails()) == 0 && out.Skipped() == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrNoMatches, src.criteria.Tags)
+	}
+	body := out.Text()
 	if body == "" {
 		return nil, fmt.Errorf("no email content to summarize")
 	}
+	if out.Truncated() > 0 || out.Skipped() > 0 {
+		s.logger.Warn("size limits reached while reading mail", "owner", src.owner,
+			"truncated", out.Truncated(), "skipped", out.Skipped())
+	}
 
-	summarized := s.generator.Summarize(body, u.SummaryCount)
+	summarized := s.generator.Summarize(body, src.summaryCount)
 	keywords := s.generator.ExtractKeywords(summarized)
 
 	wordCloudPath, err := s.generator.GenerateWordCloud(keywords)
@@ -124,10 +372,219 @@ func (s *Service) Generate(ctx context.Context, u *user.User) (*Result, error) {
 		// Non-fatal: return summary without word cloud
 	}
 
-	s.logger.Info("summary generated", "user", u.ID, "emails_processed", len(filtered))
+	s.logger.Info("summary generated", "owner", src.owner, "emails_processed", len(out.Emails()))
 
-	return &Result{
+	result := &Result{
 		Summary:       summarized,
 		WordCloudPath: wordCloudPath,
-	}, nil
+		Truncated:     out.Truncated(),
+		Skipped:       out.Skipped(),
+	}
+
+	digest, err := s.record(ctx, src, trigger, startedAt, out, folders, summarized, keywords, wordCloudPath)
+	if err != nil {
+		// Non-fatal: the summary is still delivered
+		s.logger.Error("failed to store digest", "owner", src.owner, "error", err)
+	} else {
+		result.DigestID = digest.ID
+	}
+
+	return result, nil
+}
+
+// fetch reads the new mail of every folder of one mailbox into out and
+// returns the UID range read from each folder that had any. Like
+// mailboxes in run, a folder that cannot be read is skipped unless it is
+// the only one.
+func (s *Service) fetch(ctx context.Context, src *source, mb *mailboxSource, out *imapClient.Collector) ([]FolderRange, error) {
+	im, err := mb.connect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to IMAP: %w", err)
+	}
+	defer im.Close()
+
+	folders := []string{defaultFolder}
+	if len(mb.folders) > 0 {
+		if folders, err = im.ResolveFolders(mb.folders); err != nil {
+			return nil, err
+		}
+		if len(folders) == 0 {
+			return nil, fmt.Errorf("no folders match %v", mb.folders)
+		}
+	}
+
+	var ranges []FolderRange
+	var firstErr error
+	read := 0
+	for _, folder := range folders {
+		// Mail collected before a folder failed is still summarized, so
+		// its range is kept either way.
+		r, err := s.fetchFolder(ctx, src, mb, im, folder, out)
+		if r != nil {
+			ranges = append(ranges, *r)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ranges, err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			if len(folders) > 1 {
+				s.logger.Warn("skipping folder", "owner", src.owner, "mailbox_id", mb.id, "folder", folder, "error", err)
+			}
+			continue
+		}
+		read++
+	}
+	if read == 0 {
+		return ranges, firstErr
+	}
+	return ranges, nil
+}
+
+// fetchFolder reads the new mail of one folder into out and advances its
+// sync state. It returns the UID range of the mail it collected, or nil
+// when there was none.
+func (s *Service) fetchFolder(ctx context.Context, src *source, mb *mailboxSource, im *imapClient.Client, folder string, out *imapClient.Collector) (*FolderRange, error) {
+	status, err := im.SelectFolder(folder)
+	if err != nil {
+		return nil, fmt.Errorf("selecting folder: %w", err)
+	}
+
+	// Determine starting UID from the folder's sync state
+	state, err := mb.loadState(ctx, folder)
+	if err != nil {
+		return nil, fmt.Errorf("loading sync state: %w", err)
+	}
+	if state.Reconcile(status.UIDValidity) {
+		s.logger.Warn("UIDVALIDITY changed, resynchronizing folder",
+			"owner", src.owner, "mailbox_id", mb.id, "folder", folder, "uid_validity", status.UIDValidity)
+	}
+
+	collected := len(out.Emails())
+	uidList, err := im.GetEmails(folder, state.LastUID+1, src.criteria, s.limits, out)
+	read := uidRange(mb.id, folder, out.Emails()[collected:])
+	if err != nil {
+		return read, fmt.Errorf("fetching emails: %w", err)
+	}
+
+	// Update sync state. Messages the search left out do not match and
+	// are skipped along with the rest of the folder as it was selected.
+	state.LastUID = max(state.LastUID, int(status.UIDNext)-1)
+	for _, uid := range uidList {
+		if uid > state.LastUID {
+			state.LastUID = uid
+		}
+	}
+	state.HighestModSeq = status.HighestModSeq
+	if err := mb.saveState(ctx, state); err != nil {
+		s.logger.Warn("failed to save sync state", "owner", src.owner, "mailbox_id", mb.id, "folder", folder, "error", err)
+	}
+	return read, nil
+}
+
+// uidRange returns the lowest and highest UID of emails read from one
+// folder, or nil when there are none.
+func uidRange(mailboxID, folder string, emails []imapClient.Email) *FolderRange {
+	if len(emails) == 0 {
+		return nil
+	}
+	r := &FolderRange{MailboxID: mailboxID, Folder: folder, FirstUID: emails[0].UID, LastUID: emails[0].UID}
+	for _, e := range emails[1:] {
+		r.FirstUID = min(r.FirstUID, e.UID)
+		r.LastUID = max(r.LastUID, e.UID)
+	}
+	return r
+}
+
+// List returns a page of the user's past digests, newest first.
+func (s *Service) List(ctx context.Context, userID string, limit, offset int) ([]*Digest, int64, error) {
+	return s.digests.ListByUser(ctx, userID, limit, offset)
+}
+
+// Get returns a single stored digest owned by the user.
+func (s *Service) Get(ctx context.Context, userID, id string) (*Digest, error) {
+	return s.digests.FindByID(ctx, userID, id)
+}
+
+// ListTeam returns a page of a team digest's past runs, newest first.
+// Callers check the reader's access to the team first.
+func (s *Service) ListTeam(ctx context.Context, teamDigestID string, limit, offset int) ([]*Digest, int64, error) {
+	return s.digests.ListByTeamDigest(ctx, teamDigestID, limit, offset)
+}
+
+// GetTeam returns a single past run of a team digest.
+func (s *Service) GetTeam(ctx context.Context, teamDigestID, id string) (*Digest, error) {
+	return s.digests.FindByTeamDigest(ctx, teamDigestID, id)
+}
+
+func (s *Service) record(
+	ctx context.Context,
+	src *source,
+	trigger Trigger,
+	startedAt time.Time,
+	out *imapClient.Collector,
+	folders []FolderRange,
+	summarized string,
+	keywords map[string]int,
+	wordCloudPath string,
+) (*Digest, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, fmt.Errorf("generating UUID: %w", err)
+	}
+
+	d := &Digest{
+		ID:           id.String(),
+		UserID:       src.digest.UserID,
+		TeamDigestID: src.digest.TeamDigestID,
+		Summary:      summarized,
+		Keywords:     topKeywords(keywords, maxStoredKeywords),
+		Tags:         append([]string(nil), src.criteria.Tags...),
+		EmailCount:   len(out.Emails()),
+		Truncated:    out.Truncated(),
+		Skipped:      out.Skipped(),
+		Folders:      folders,
+		Trigger:      trigger,
+		StartedAt:    startedAt,
+	}
+
+	// UIDs are only comparable within a folder, so the overall range is
+	// kept only for runs that read a single one.
+	if len(folders) == 1 {
+		d.FirstUID, d.LastUID = folders[0].FirstUID, folders[0].LastUID
+	}
+
+	if wordCloudPath != "" {
+		img, err := os.ReadFile(wordCloudPath)
+		if err != nil {
+			s.logger.Warn("could not read word cloud for digest", "error", err)
+		} else {
+			d.WordCloud = img
+		}
+	}
+
+	if err := s.digests.Create(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// topKeywords returns up to n keywords ordered by descending score.
+func topKeywords(keywords map[string]int, n int) []string {
+	words := make([]string, 0, len(keywords))
+	for w := range keywords {
+		words = append(words, w)
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if keywords[words[i]] != keywords[words[j]] {
+			return keywords[words[i]] > keywords[words[j]]
+		}
+		return words[i] < words[j]
+	})
+	if len(words) > n {
+		words = words[:n]
+	}
+	return words
 }
@@ -0,0 +1,40 @@
+package summary
+
+import (
+	"testing"
+
+	imapClient "github.com/akhil-datla/maildruid/internal/infrastructure/imap"
+)
+
+func TestTopKeywords(t *testing.T) {
+	keywords := map[string]int{"budget": 3, "launch": 9, "hiring": 3, "q4": 1}
+
+	got := topKeywords(keywords, 3)
+	want := []string{"launch", "budget", "hiring"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("at index %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+func TestTopKeywordsEmpty(t *testing.T) {
+	if got := topKeywords(nil, 5); len(got) != 0 {
+		t.Errorf("expected no keywords, got %v", got)
+	}
+}
+
+func TestUIDRange(t *testing.T) {
+	if r := uidRange("mb1", "INBOX", nil); r != nil {
+		t.Errorf("expected no range without mail, got %+v", r)
+	}
+
+	r := uidRange("mb1", "Lists/Dev", []imapClient.Email{{UID: 42}, {UID: 7}, {UID: 19}})
+	want := FolderRange{MailboxID: "mb1", Folder: "Lists/Dev", FirstUID: 7, LastUID: 42}
+	if r == nil || *r != want {
+		t.Errorf("expected %+v, got %+v", want, r)
+	}
+}
@@ -0,0 +1,50 @@
+package syncstate
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryRepository is an in-memory sync state repository for testing.
+type MemoryRepository struct {
+	mu     sync.RWMutex
+	states map[[2]string]*State
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{states: make(map[[2]string]*State)}
+}
+
+func (r *MemoryRepository) Get(_ context.Context, mailboxID, folder string) (*State, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.states[[2]string{mailboxID, folder}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *s
+	return &cp, nil
+}
+
+func (r *MemoryRepository) Save(_ context.Context, s *State) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s.UpdatedAt = time.Now()
+	cp := *s
+	r.states[[2]string{s.MailboxID, s.Folder}] = &cp
+	return nil
+}
+
+func (r *MemoryRepository) ListByMailbox(_ context.Context, mailboxID string) ([]*State, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result []*State
+	for _, s := range r.states {
+		if s.MailboxID == mailboxID {
+			cp := *s
+			result = append(result, &cp)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,38 @@
+package syncstate
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when no sync state has been recorded for a folder.
+var ErrNotFound = errors.New("sync state not found")
+
+// State records how far a mailbox folder has been processed. UIDs are
+// only meaningful together with the folder's UIDVALIDITY; when the server
+// reports a different UIDVALIDITY the stored UIDs must be discarded.
+type State struct {
+	MailboxID     string    `json:"mailboxId" gorm:"primaryKey"`
+	Folder        string    `json:"folder" gorm:"primaryKey"`
+	UIDValidity   uint32    `json:"uidValidity"`
+	LastUID       int       `json:"lastUid"`
+	HighestModSeq uint64    `json:"highestModSeq"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// TableName keeps the table name stable across GORM naming strategies.
+func (State) TableName() string { return "sync_states" }
+
+// Reconcile checks the stored state against the UIDVALIDITY the server
+// reported for the folder. If they differ, the stored UIDs refer to a
+// different incarnation of the mailbox and the state is reset so the folder
+// is resynchronized from the start. It reports whether a reset happened.
+func (s *State) Reconcile(uidValidity uint32) bool {
+	reset := s.UIDValidity != 0 && s.UIDValidity != uidValidity
+	if reset {
+		s.LastUID = 0
+		s.HighestModSeq = 0
+	}
+	s.UIDValidity = uidValidity
+	return reset
+}
@@ -0,0 +1,33 @@
+package syncstate
+
+import "testing"
+
+func TestReconcileAdoptsFirstUIDValidity(t *testing.T) {
+	s := &State{LastUID: 42}
+	if s.Reconcile(1000) {
+		t.Error("first UIDVALIDITY should not trigger a reset")
+	}
+	if s.UIDValidity != 1000 || s.LastUID != 42 {
+		t.Errorf("unexpected state: %+v", s)
+	}
+}
+
+func TestReconcileKeepsMatchingUIDValidity(t *testing.T) {
+	s := &State{UIDValidity: 1000, LastUID: 42, HighestModSeq: 7}
+	if s.Reconcile(1000) {
+		t.Error("matching UIDVALIDITY should not trigger a reset")
+	}
+	if s.LastUID != 42 || s.HighestModSeq != 7 {
+		t.Errorf("unexpected state: %+v", s)
+	}
+}
+
+func TestReconcileResetsChangedUIDValidity(t *testing.T) {
+	s := &State{UIDValidity: 1000, LastUID: 42, HighestModSeq: 7}
+	if !s.Reconcile(2000) {
+		t.Error("changed UIDVALIDITY should trigger a reset")
+	}
+	if s.UIDValidity != 2000 || s.LastUID != 0 || s.HighestModSeq != 0 {
+		t.Errorf("unexpected state after reset: %+v", s)
+	}
+}
@@ -0,0 +1,13 @@
+package syncstate
+
+import "context"
+
+// Repository defines persistence operations for folder sync state.
+type Repository interface {
+	// Get returns the state for the mailbox's folder or ErrNotFound.
+	Get(ctx context.Context, mailboxID, folder string) (*State, error)
+	// Save inserts or replaces the state for the mailbox's folder.
+	Save(ctx context.Context, s *State) error
+	// ListByMailbox returns the state of every folder synced for the mailbox.
+	ListByMailbox(ctx context.Context, mailboxID string) ([]*State, error)
+}
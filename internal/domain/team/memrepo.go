@@ -0,0 +1,248 @@
+package team
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/syncstate"
+)
+
+// MemoryRepository is an in-memory team repository for testing.
+type MemoryRepository struct {
+	mu         sync.RWMutex
+	teams      map[string]*Team
+	members    map[[2]string]*Member // team ID, user ID
+	digests    map[string]*Digest
+	syncStates map[[2]string]*syncstate.State // digest ID, folder
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		teams:      make(map[string]*Team),
+		members:    make(map[[2]string]*Member),
+		digests:    make(map[string]*Digest),
+		syncStates: make(map[[2]string]*syncstate.State),
+	}
+}
+
+func (r *MemoryRepository) Create(_ context.Context, t *Team, owner *Member) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	t.CreatedAt, t.UpdatedAt = now, now
+	owner.CreatedAt, owner.UpdatedAt = now, now
+	tc, mc := *t, *owner
+	r.teams[t.ID] = &tc
+	r.members[[2]string{t.ID, owner.UserID}] = &mc
+	return nil
+}
+
+func (r *MemoryRepository) FindByID(_ context.Context, id string) (*Team, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.teams[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *t
+	return &cp, nil
+}
+
+func (r *MemoryRepository) Update(_ context.Context, t *Team) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.teams[t.ID]; !ok {
+		return ErrNotFound
+	}
+	t.UpdatedAt = time.Now()
+	cp := *t
+	r.teams[t.ID] = &cp
+	return nil
+}
+
+func (r *MemoryRepository) Delete(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.teams, id)
+	for k := range r.members {
+		if k[0] == id {
+			delete(r.members, k)
+		}
+	}
+	for did, d := range r.digests {
+		if d.TeamID == id {
+			delete(r.digests, did)
+			for k := range r.syncStates {
+				if k[0] == did {
+					delete(r.syncStates, k)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) ListByUser(_ context.Context, userID string) ([]*Membership, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result []*Membership
+	for k, m := range r.members {
+		if k[1] != userID {
+			continue
+		}
+		t := *r.teams[k[0]]
+		result = append(result, &Membership{Team: &t, Role: m.Role, Deliver: m.Deliver})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+func (r *MemoryRepository) AddMember(_ context.Context, m *Member) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := [2]string{m.TeamID, m.UserID}
+	if _, ok := r.members[key]; ok {
+		return ErrAlreadyMember
+	}
+	now := time.Now()
+	m.CreatedAt, m.UpdatedAt = now, now
+	cp := *m
+	r.members[key] = &cp
+	return nil
+}
+
+func (r *MemoryRepository) FindMember(_ context.Context, teamID, userID string) (*Member, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	m, ok := r.members[[2]string{teamID, userID}]
+	if !ok {
+		return nil, ErrMemberNotFound
+	}
+	cp := *m
+	return &cp, nil
+}
+
+func (r *MemoryRepository) UpdateMember(_ context.Context, m *Member) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := [2]string{m.TeamID, m.UserID}
+	if _, ok := r.members[key]; !ok {
+		return ErrMemberNotFound
+	}
+	m.UpdatedAt = time.Now()
+	cp := *m
+	r.members[key] = &cp
+	return nil
+}
+
+func (r *MemoryRepository) RemoveMember(_ context.Context, teamID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.members, [2]string{teamID, userID})
+	return nil
+}
+
+func (r *MemoryRepository) ListMembers(_ context.Context, teamID string) ([]*Member, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result []*Member
+	for k, m := range r.members {
+		if k[0] == teamID {
+			cp := *m
+			result = append(result, &cp)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.Before(result[j].CreatedAt) })
+	return result, nil
+}
+
+func (r *MemoryRepository) CreateDigest(_ context.Context, d *Digest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	d.CreatedAt, d.UpdatedAt = now, now
+	cp := *d
+	r.digests[d.ID] = &cp
+	return nil
+}
+
+func (r *MemoryRepository) FindDigest(_ context.Context, teamID, id string) (*Digest, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.digests[id]
+	if !ok || (teamID != "" && d.TeamID != teamID) {
+		return nil, ErrDigestNotFound
+	}
+	cp := *d
+	return &cp, nil
+}
+
+func (r *MemoryRepository) UpdateDigest(_ context.Context, d *Digest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.digests[d.ID]; !ok {
+		return ErrDigestNotFound
+	}
+	d.UpdatedAt = time.Now()
+	cp := *d
+	r.digests[d.ID] = &cp
+	return nil
+}
+
+func (r *MemoryRepository) DeleteDigest(_ context.Context, teamID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if d, ok := r.digests[id]; ok && d.TeamID == teamID {
+		delete(r.digests, id)
+	}
+	return nil
+}
+
+func (r *MemoryRepository) ListDigests(_ context.Context, teamID string) ([]*Digest, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result []*Digest
+	for _, d := range r.digests {
+		if d.TeamID == teamID {
+			cp := *d
+			result = append(result, &cp)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+func (r *MemoryRepository) ListScheduledDigests(_ context.Context) ([]*Digest, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result []*Digest
+	for _, d := range r.digests {
+		if d.Scheduled() {
+			cp := *d
+			result = append(result, &cp)
+		}
+	}
+	return result, nil
+}
+
+func (r *MemoryRepository) SyncState(_ context.Context, digestID, folder string) (*syncstate.State, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.syncStates[[2]string{digestID, folder}]
+	if !ok {
+		return nil, syncstate.ErrNotFound
+	}
+	cp := *s
+	return &cp, nil
+}
+
+func (r *MemoryRepository) SaveSyncState(_ context.Context, digestID string, s *syncstate.State) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s.UpdatedAt = time.Now()
+	cp := *s
+	r.syncStates[[2]string{digestID, s.Folder}] = &cp
+	return nil
+}
@@ -0,0 +1,99 @@
+package team
+
+import (
+	"errors"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/mailbox"
+	"github.com/lib/pq"
+)
+
+// Domain errors.
+var (
+	ErrNotFound        = errors.New("team not found")
+	ErrForbidden       = errors.New("team role does not allow this")
+	ErrInvalidRole     = errors.New("invalid team role")
+	ErrMemberNotFound  = errors.New("team member not found")
+	ErrAlreadyMember   = errors.New("user is already a team member")
+	ErrLastOwner       = errors.New("a team needs at least one owner")
+	ErrMailboxNotFound = mailbox.ErrNotFound
+	ErrMailboxInUse    = errors.New("mailbox is used by a digest")
+	ErrDigestNotFound  = errors.New("digest not found")
+	ErrInvalidInterval = errors.New("interval must be a number of minutes")
+)
+
+// Roles a member can hold. Viewers see the team's mailboxes, digests and
+// history; editors also change mailboxes and digests; owners also manage
+// members and the team itself.
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// rank orders roles by the access they grant.
+var rank = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// ValidRole reports whether role is a known team role.
+func ValidRole(role string) bool {
+	return rank[role] > 0
+}
+
+// Team is a group of users sharing mailbox connections and digests.
+type Team struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Member is a user's membership in a team.
+type Member struct {
+	TeamID    string    `json:"teamId" gorm:"primaryKey"`
+	UserID    string    `json:"userId" gorm:"primaryKey"`
+	Role      string    `json:"role"`
+	Deliver   bool      `json:"deliver"` // whether the team's digests are emailed to the member
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// TableName keeps the table name stable across GORM naming strategies.
+func (Member) TableName() string { return "team_members" }
+
+// Allows reports whether the member's role grants at least role.
+func (m *Member) Allows(role string) bool {
+	return rank[m.Role] >= rank[role]
+}
+
+// Membership is a team together with the caller's membership in it.
+type Membership struct {
+	*Team
+	Role    string `json:"role"`
+	Deliver bool   `json:"deliver"`
+}
+
+// Digest is a digest configuration owned by a team: which of the team's
+// mailboxes it reads, how messages are filtered and how often it runs.
+// Each run is delivered to every member who has delivery turned on.
+type Digest struct {
+	ID               string         `json:"id" gorm:"primaryKey"`
+	TeamID           string         `json:"teamId" gorm:"index"`
+	MailboxID        string         `json:"mailboxId"`
+	Name             string         `json:"name"`
+	Folder           string         `json:"folder"` // the only folder read when set; otherwise the mailbox's folders
+	Tags             pq.StringArray `json:"tags" gorm:"type:text[]"`
+	BlackListSenders pq.StringArray `json:"blackListSenders" gorm:"type:text[]"`
+	StartTime        time.Time      `json:"startTime"`
+	SummaryCount     int            `json:"summaryCount"`
+	UpdateInterval   string         `json:"updateInterval"` // minutes between scheduled runs, "" when not scheduled
+	CreatedAt        time.Time      `json:"createdAt"`
+	UpdatedAt        time.Time      `json:"updatedAt"`
+}
+
+// TableName keeps the table name stable across GORM naming strategies.
+func (Digest) TableName() string { return "team_digests" }
+
+// Scheduled reports whether the digest runs on an interval.
+func (d *Digest) Scheduled() bool {
+	return d.UpdateInterval != "" && d.UpdateInterval != "0"
+}
@@ -0,0 +1,45 @@
+package team
+
+import (
+	"context"
+
+	"github.com/akhil-datla/maildruid/internal/domain/syncstate"
+)
+
+// Repository defines persistence operations for teams and everything they
+// own.
+type Repository interface {
+	// Create stores a new team together with its first member.
+	Create(ctx context.Context, t *Team, owner *Member) error
+	FindByID(ctx context.Context, id string) (*Team, error)
+	Update(ctx context.Context, t *Team) error
+	// Delete removes a team with its members, mailboxes, digests and their
+	// history. The team's mailboxes are kept by the mailbox repository and
+	// go with it.
+	Delete(ctx context.Context, id string) error
+	// ListByUser returns the teams userID belongs to, by name.
+	ListByUser(ctx context.Context, userID string) ([]*Membership, error)
+
+	AddMember(ctx context.Context, m *Member) error
+	FindMember(ctx context.Context, teamID, userID string) (*Member, error)
+	UpdateMember(ctx context.Context, m *Member) error
+	RemoveMember(ctx context.Context, teamID, userID string) error
+	// ListMembers returns a team's members, oldest first.
+	ListMembers(ctx context.Context, teamID string) ([]*Member, error)
+
+	CreateDigest(ctx context.Context, d *Digest) error
+	// FindDigest returns the digest with the given ID, or one owned by
+	// teamID when teamID is not empty.
+	FindDigest(ctx context.Context, teamID, id string) (*Digest, error)
+	UpdateDigest(ctx context.Context, d *Digest) error
+	DeleteDigest(ctx context.Context, teamID, id string) error
+	ListDigests(ctx context.Context, teamID string) ([]*Digest, error)
+	// ListScheduledDigests returns the digests of every team that run on
+	// an interval.
+	ListScheduledDigests(ctx context.Context) ([]*Digest, error)
+
+	// SyncState returns how far a digest has read a folder. The returned
+	// state has no MailboxID.
+	SyncState(ctx context.Context, digestID, folder string) (*syncstate.State, error)
+	SaveSyncState(ctx context.Context, digestID string, s *syncstate.State) error
+}
@@ -0,0 +1,570 @@
+package team
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/akhil-datla/maildruid/internal/domain/mailbox"
+	"github.com/akhil-datla/maildruid/internal/domain/syncstate"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+	"github.com/gofrs/uuid"
+	"github.com/lib/pq"
+)
+
+// defaultSummaryCount is the summary length of a new digest.
+const defaultSummaryCount = 5
+
+// Service manages teams, their members and the mailboxes and digests they
+// share. Every method acting for a user checks that user's role in the
+// team first; non-members get ErrNotFound so team IDs are not revealed.
+// The team's mailboxes are kept by the mailbox service like everyone
+// else's.
+type Service struct {
+	repo       Repository
+	userSvc    *user.Service
+	mailboxSvc *mailbox.Service
+	audit      *audit.Service
+	logger     *slog.Logger
+}
+
+// NewService creates a new team service.
+func NewService(repo Repository, userSvc *user.Service, mailboxSvc *mailbox.Service, auditSvc *audit.Service, logger *slog.Logger) *Service {
+	return &Service{repo: repo, userSvc: userSvc, mailboxSvc: mailboxSvc, audit: auditSvc, logger: logger}
+}
+
+// authorize returns userID's membership in teamID if it grants at least
+// role.
+func (s *Service) authorize(ctx context.Context, userID, teamID, role string) (*Member, error) {
+	m, err := s.repo.FindMember(ctx, teamID, userID)
+	if errors.Is(err, ErrMemberNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !m.Allows(role) {
+		return nil, ErrForbidden
+	}
+	return m, nil
+}
+
+// Authorize checks that userID holds at least role in teamID, for
+// operations on team resources that live outside this package.
+func (s *Service) Authorize(ctx context.Context, userID, teamID, role string) error {
+	_, err := s.authorize(ctx, userID, teamID, role)
+	return err
+}
+
+// Create starts a team with userID as its owner.
+func (s *Service) Create(ctx context.Context, userID, name string) (*Membership, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, fmt.Errorf("generating UUID: %w", err)
+	}
+	t := &Team{ID: id.String(), Name: name}
+	owner := &Member{TeamID: t.ID, UserID: userID, Role: RoleOwner, Deliver: true}
+	if err := s.repo.Create(ctx, t, owner); err != nil {
+		return nil, fmt.Errorf("creating team: %w", err)
+	}
+	s.audit.TryRecord(ctx, audit.Event{UserID: userID, TeamID: t.ID, Action: audit.ActionTeamCreated, Changes: audit.Changes{{Field: "name", After: name}}})
+	s.logger.Info("team created", "id", t.ID, "owner", userID)
+	return &Membership{Team: t, Role: owner.Role, Deliver: owner.Deliver}, nil
+}
+
+// List returns the teams userID belongs to.
+func (s *Service) List(ctx context.Context, userID string) ([]*Membership, error) {
+	return s.repo.ListByUser(ctx, userID)
+}
+
+// Get returns a team userID belongs to.
+func (s *Service) Get(ctx context.Context, userID, teamID string) (*Membership, error) {
+	m, err := s.authorize(ctx, userID, teamID, RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	t, err := s.repo.FindByID(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	return &Membership{Team: t, Role: m.Role, Deliver: m.Deliver}, nil
+}
+
+// Rename changes a team's name. Owners only.
+func (s *Service) Rename(ctx context.Context, userID, teamID, name string) error {
+	if _, err := s.authorize(ctx, userID, teamID, RoleOwner); err != nil {
+		return err
+	}
+	t, err := s.repo.FindByID(ctx, teamID)
+	if err != nil {
+		return err
+	}
+	var changes audit.Changes
+	changes.Add("name", t.Name, name)
+	t.Name = name
+	if err := s.repo.Update(ctx, t); err != nil {
+		return fmt.Errorf("updating team: %w", err)
+	}
+	if len(changes) > 0 {
+		s.audit.TryRecord(ctx, audit.Event{UserID: userID, TeamID: teamID, Action: audit.ActionTeamUpdated, Changes: changes})
+	}
+	return nil
+}
+
+// Delete removes a team with everything it owns and returns the digests
+// that were deleted with it, so their schedules can be dropped. Owners
+// only.
+func (s *Service) Delete(ctx context.Context, userID, teamID string) ([]*Digest, error) {
+	if _, err := s.authorize(ctx, userID, teamID, RoleOwner); err != nil {
+		return nil, err
+	}
+	digests, err := s.repo.ListDigests(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Delete(ctx, teamID); err != nil {
+		return nil, fmt.Errorf("deleting team: %w", err)
+	}
+	s.audit.TryRecord(ctx, audit.Event{UserID: userID, TeamID: teamID, Action: audit.ActionTeamDeleted})
+	s.logger.Info("team deleted", "id", teamID, "by", userID)
+	return digests, nil
+}
+
+// Members lists a team's members.
+func (s *Service) Members(ctx context.Context, userID, teamID string) ([]*Member, error) {
+	if _, err := s.authorize(ctx, userID, teamID, RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.ListMembers(ctx, teamID)
+}
+
+// AddMember adds the user with the given login email to a team. New
+// members do not receive the team's digests until they turn delivery on
+// themselves. An unknown email is ignored without an error, so the caller
+// cannot tell which emails have an account. Owners only.
+func (s *Service) AddMember(ctx context.Context, userID, teamID, email, role string) error {
+	if !ValidRole(role) {
+		return ErrInvalidRole
+	}
+	if _, err := s.authorize(ctx, userID, teamID, RoleOwner); err != nil {
+		return err
+	}
+	u, err := s.userSvc.GetByEmail(ctx, email)
+	if errors.Is(err, user.ErrNotFound) {
+		s.logger.Info("team member to add has no account", "team_id", teamID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := s.repo.FindMember(ctx, teamID, u.ID); err == nil {
+		return ErrAlreadyMember
+	} else if !errors.Is(err, ErrMemberNotFound) {
+		return err
+	}
+
+	m := &Member{TeamID: teamID, UserID: u.ID, Role: role}
+	if err := s.repo.AddMember(ctx, m); err != nil {
+		return fmt.Errorf("adding team member: %w", err)
+	}
+	s.audit.TryRecord(ctx, audit.Event{UserID: u.ID, TeamID: teamID, Action: audit.ActionTeamMemberAdded, Changes: audit.Changes{{Field: "role", After: role}}})
+	return nil
+}
+
+// SetRole changes a member's role. A team always keeps at least one owner.
+// Owners only.
+func (s *Service) SetRole(ctx context.Context, userID, teamID, memberID, role string) (*Member, error) {
+	if !ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	if _, err := s.authorize(ctx, userID, teamID, RoleOwner); err != nil {
+		return nil, err
+	}
+	m, err := s.repo.FindMember(ctx, teamID, memberID)
+	if err != nil {
+		return nil, err
+	}
+	if m.Role == role {
+		return m, nil
+	}
+	if m.Role == RoleOwner {
+		if err := s.keepOwner(ctx, teamID); err != nil {
+			return nil, err
+		}
+	}
+
+	var changes audit.Changes
+	changes.Add("role", m.Role, role)
+	m.Role = role
+	if err := s.repo.UpdateMember(ctx, m); err != nil {
+		return nil, fmt.Errorf("updating team member: %w", err)
+	}
+	s.audit.TryRecord(ctx, audit.Event{UserID: memberID, TeamID: teamID, Action: audit.ActionTeamMemberUpdated, Changes: changes})
+	return m, nil
+}
+
+// RemoveMember takes a user out of a team. Owners may remove anyone; every
+// member may leave. The last owner cannot leave.
+func (s *Service) RemoveMember(ctx context.Context, userID, teamID, memberID string) error {
+	role := RoleOwner
+	if memberID == userID {
+		role = RoleViewer
+	}
+	if _, err := s.authorize(ctx, userID, teamID, role); err != nil {
+		return err
+	}
+	m, err := s.repo.FindMember(ctx, teamID, memberID)
+	if err != nil {
+		return err
+	}
+	if m.Role == RoleOwner {
+		if err := s.keepOwner(ctx, teamID); err != nil {
+			return err
+		}
+	}
+	if err := s.repo.RemoveMember(ctx, teamID, memberID); err != nil {
+		return fmt.Errorf("removing team member: %w", err)
+	}
+	s.audit.TryRecord(ctx, audit.Event{UserID: memberID, TeamID: teamID, Action: audit.ActionTeamMemberRemoved})
+	return nil
+}
+
+// SetDelivery turns emailing the team's digests to userID on or off.
+func (s *Service) SetDelivery(ctx context.Context, userID, teamID string, deliver bool) (*Member, error) {
+	m, err := s.authorize(ctx, userID, teamID, RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	if m.Deliver == deliver {
+		return m, nil
+	}
+	var changes audit.Changes
+	changes.Add("deliver", m.Deliver, deliver)
+	m.Deliver = deliver
+	if err := s.repo.UpdateMember(ctx, m); err != nil {
+		return nil, fmt.Errorf("updating team member: %w", err)
+	}
+	s.audit.TryRecord(ctx, audit.Event{UserID: userID, TeamID: teamID, Action: audit.ActionTeamMemberUpdated, Changes: changes})
+	return m, nil
+}
+
+// keepOwner returns ErrLastOwner unless the team has another owner besides
+// the one about to be demoted or removed.
+func (s *Service) keepOwner(ctx context.Context, teamID string) error {
+	members, err := s.repo.ListMembers(ctx, teamID)
+	if err != nil {
+		return err
+	}
+	owners := 0
+	for _, m := range members {
+		if m.Role == RoleOwner {
+			owners++
+		}
+	}
+	if owners < 2 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// KeepOwners returns ErrLastOwner if userID is the only owner of one of
+// their teams. Deleting the account would otherwise leave that team
+// without anyone who can manage or delete it.
+func (s *Service) KeepOwners(ctx context.Context, userID string) error {
+	teams, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, t := range teams {
+		if t.Role != RoleOwner {
+			continue
+		}
+		if err := s.keepOwner(ctx, t.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Mailboxes lists a team's mailbox connections.
+func (s *Service) Mailboxes(ctx context.Context, userID, teamID string) ([]*mailbox.Mailbox, error) {
+	if _, err := s.authorize(ctx, userID, teamID, RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.mailboxSvc.List(ctx, mailbox.TeamOwner(teamID))
+}
+
+// Mailbox returns one of a team's mailbox connections.
+func (s *Service) Mailbox(ctx context.Context, userID, teamID, id string) (*mailbox.Mailbox, error) {
+	if _, err := s.authorize(ctx, userID, teamID, RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.mailboxSvc.Get(ctx, mailbox.TeamOwner(teamID), id)
+}
+
+// CreateMailbox adds a mailbox connection to a team. Editors and owners
+// only.
+func (s *Service) CreateMailbox(ctx context.Context, userID, teamID string, in mailbox.Input) (*mailbox.Mailbox, error) {
+	if _, err := s.authorize(ctx, userID, teamID, RoleEditor); err != nil {
+		return nil, err
+	}
+	return s.mailboxSvc.Create(ctx, mailbox.TeamOwner(teamID), in)
+}
+
+// UpdateMailbox changes a team's mailbox connection. As for every mailbox,
+// changing the server, port or login requires the password to be entered
+// again, so an editor cannot send the stored credential to a server of
+// their choosing. Editors and owners only.
+func (s *Service) UpdateMailbox(ctx context.Context, userID, teamID, id string, in mailbox.Input) (*mailbox.Mailbox, error) {
+	if _, err := s.authorize(ctx, userID, teamID, RoleEditor); err != nil {
+		return nil, err
+	}
+	return s.mailboxSvc.Update(ctx, mailbox.TeamOwner(teamID), id, in)
+}
+
+// DeleteMailbox removes a team's mailbox connection. Mailboxes still read
+// by a digest cannot be deleted. Editors and owners only.
+func (s *Service) DeleteMailbox(ctx context.Context, userID, teamID, id string) error {
+	if _, err := s.authorize(ctx, userID, teamID, RoleEditor); err != nil {
+		return err
+	}
+	digests, err := s.repo.ListDigests(ctx, teamID)
+	if err != nil {
+		return err
+	}
+	for _, d := range digests {
+		if d.MailboxID == id {
+			return ErrMailboxInUse
+		}
+	}
+	return s.mailboxSvc.Delete(ctx, mailbox.TeamOwner(teamID), id)
+}
+
+// DigestInput holds the fields of a digest configuration. Nil fields are
+// left unchanged on update.
+type DigestInput struct {
+	Name             *string
+	MailboxID        *string
+	Folder           *string
+	Tags             []string
+	BlackListSenders []string
+	StartTime        *time.Time
+	SummaryCount     *int
+	UpdateInterval   *string
+}
+
+// Digests lists a team's digest configurations.
+func (s *Service) Digests(ctx context.Context, userID, teamID string) ([]*Digest, error) {
+	if _, err := s.authorize(ctx, userID, teamID, RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.ListDigests(ctx, teamID)
+}
+
+// Digest returns one of a team's digest configurations.
+func (s *Service) Digest(ctx context.Context, userID, teamID, id string) (*Digest, error) {
+	if _, err := s.authorize(ctx, userID, teamID, RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.FindDigest(ctx, teamID, id)
+}
+
+// CreateDigest adds a digest configuration reading one of the team's
+// mailboxes. Editors and owners only.
+func (s *Service) CreateDigest(ctx context.Context, userID, teamID string, in DigestInput) (*Digest, error) {
+	if _, err := s.authorize(ctx, userID, teamID, RoleEditor); err != nil {
+		return nil, err
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, fmt.Errorf("generating UUID: %w", err)
+	}
+	d := &Digest{ID: id.String(), TeamID: teamID, SummaryCount: defaultSummaryCount}
+	if err := s.applyDigest(ctx, d, in); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateDigest(ctx, d); err != nil {
+		return nil, fmt.Errorf("creating digest: %w", err)
+	}
+	s.audit.TryRecord(ctx, audit.Event{UserID: userID, TeamID: teamID, Action: audit.ActionTeamDigestCreated, Detail: d.ID, Changes: digestDiff(&Digest{}, d)})
+	return d, nil
+}
+
+// UpdateDigest changes a team's digest configuration. Editors and owners
+// only.
+func (s *Service) UpdateDigest(ctx context.Context, userID, teamID, id string, in DigestInput) (*Digest, error) {
+	if _, err := s.authorize(ctx, userID, teamID, RoleEditor); err != nil {
+		return nil, err
+	}
+	d, err := s.repo.FindDigest(ctx, teamID, id)
+	if err != nil {
+		return nil, err
+	}
+	before := *d
+	if err := s.applyDigest(ctx, d, in); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateDigest(ctx, d); err != nil {
+		return nil, fmt.Errorf("updating digest: %w", err)
+	}
+	if changes := digestDiff(&before, d); len(changes) > 0 {
+		s.audit.TryRecord(ctx, audit.Event{UserID: userID, TeamID: teamID, Action: audit.ActionTeamDigestUpdated, Detail: d.ID, Changes: changes})
+	}
+	return d, nil
+}
+
+// DeleteDigest removes a team's digest configuration and its history.
+// Editors and owners only.
+func (s *Service) DeleteDigest(ctx context.Context, userID, teamID, id string) error {
+	if _, err := s.authorize(ctx, userID, teamID, RoleEditor); err != nil {
+		return err
+	}
+	if _, err := s.repo.FindDigest(ctx, teamID, id); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteDigest(ctx, teamID, id); err != nil {
+		return fmt.Errorf("deleting digest: %w", err)
+	}
+	s.audit.TryRecord(ctx, audit.Event{UserID: userID, TeamID: teamID, Action: audit.ActionTeamDigestDeleted, Detail: id})
+	return nil
+}
+
+func (s *Service) applyDigest(ctx context.Context, d *Digest, in DigestInput) error {
+	if in.MailboxID != nil {
+		if _, err := s.mailboxSvc.Get(ctx, mailbox.TeamOwner(d.TeamID), *in.MailboxID); err != nil {
+			return err
+		}
+		d.MailboxID = *in.MailboxID
+	}
+	if in.Name != nil {
+		d.Name = *in.Name
+	}
+	if in.Folder != nil {
+		d.Folder = *in.Folder
+	}
+	if in.Tags != nil {
+		d.Tags = in.Tags
+	}
+	if in.BlackListSenders != nil {
+		d.BlackListSenders = in.BlackListSenders
+	}
+	if in.StartTime != nil {
+		d.StartTime = *in.StartTime
+	}
+	if in.SummaryCount != nil {
+		d.SummaryCount = *in.SummaryCount
+	}
+	if in.UpdateInterval != nil {
+		if *in.UpdateInterval != "" {
+			if n, err := strconv.Atoi(*in.UpdateInterval); err != nil || n < 0 {
+				return ErrInvalidInterval
+			}
+		}
+		d.UpdateInterval = *in.UpdateInterval
+	}
+	return nil
+}
+
+// The methods below serve the scheduler and act for no particular user.
+
+// GetDigest returns a digest configuration by ID.
+func (s *Service) GetDigest(ctx context.Context, id string) (*Digest, error) {
+	return s.repo.FindDigest(ctx, "", id)
+}
+
+// ScheduledDigests returns every team digest that runs on an interval.
+func (s *Service) ScheduledDigests(ctx context.Context) ([]*Digest, error) {
+	return s.repo.ListScheduledDigests(ctx)
+}
+
+// DigestMailbox returns the mailbox a digest reads.
+func (s *Service) DigestMailbox(ctx context.Context, d *Digest) (*mailbox.Mailbox, error) {
+	return s.mailboxSvc.Get(ctx, mailbox.TeamOwner(d.TeamID), d.MailboxID)
+}
+
+// MailboxDigests returns the digests that read a team mailbox, for push
+// mode.
+func (s *Service) MailboxDigests(ctx context.Context, mb *mailbox.Mailbox) ([]*Digest, error) {
+	digests, err := s.repo.ListDigests(ctx, mb.TeamID)
+	if err != nil {
+		return nil, err
+	}
+	var reading []*Digest
+	for _, d := range digests {
+		if d.MailboxID == mb.ID {
+			reading = append(reading, d)
+		}
+	}
+	return reading, nil
+}
+
+// Recipients returns the members a team's digests are delivered to: those
+// with delivery on whose account is enabled and whose receiving email is
+// confirmed.
+func (s *Service) Recipients(ctx context.Context, teamID string) ([]*user.User, error) {
+	members, err := s.repo.ListMembers(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	var users []*user.User
+	for _, m := range members {
+		if !m.Deliver {
+			continue
+		}
+		u, err := s.userSvc.GetByID(ctx, m.UserID)
+		if errors.Is(err, user.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if u.Disabled || !u.ReceivingEmailVerified {
+			continue
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+// SyncState returns how far a digest has read a folder.
+func (s *Service) SyncState(ctx context.Context, digestID, folder string) (*syncstate.State, error) {
+	return s.repo.SyncState(ctx, digestID, folder)
+}
+
+// SaveSyncState records how far a digest has read a folder.
+func (s *Service) SaveSyncState(ctx context.Context, digestID string, state *syncstate.State) error {
+	return s.repo.SaveSyncState(ctx, digestID, state)
+}
+
+// digestDiff lists the fields that differ between two versions of a
+// digest configuration.
+func digestDiff(before, after *Digest) audit.Changes {
+	var c audit.Changes
+	c.Add("name", before.Name, after.Name)
+	c.Add("mailboxId", before.MailboxID, after.MailboxID)
+	c.Add("folder", before.Folder, after.Folder)
+	c.Add("tags", list(before.Tags), list(after.Tags))
+	c.Add("blackListSenders", list(before.BlackListSenders), list(after.BlackListSenders))
+	c.Add("startTime", timestamp(before.StartTime), timestamp(after.StartTime))
+	c.Add("summaryCount", before.SummaryCount, after.SummaryCount)
+	c.Add("updateInterval", before.UpdateInterval, after.UpdateInterval)
+	return c
+}
+
+func list(a pq.StringArray) []string {
+	if len(a) == 0 {
+		return nil
+	}
+	return a
+}
+
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
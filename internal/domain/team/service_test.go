@@ -0,0 +1,286 @@
+package team
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/akhil-datla/maildruid/internal/domain/mailbox"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/encryption"
+)
+
+type testEnv struct {
+	svc        *Service
+	userSvc    *user.Service
+	mailboxSvc *mailbox.Service
+	events     *audit.MemoryRepository
+	ids        map[string]string // login email -> user ID
+}
+
+func setupTestService(t *testing.T, emails ...string) *testEnv {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	enc, err := encryption.New([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("encryption.New: %v", err)
+	}
+	events := audit.NewMemoryRepository()
+	auditSvc := audit.NewService(events, logger)
+	userSvc := user.NewService(user.NewMemoryRepository(), enc, auditSvc, logger)
+
+	ctx := context.Background()
+	ids := make(map[string]string)
+	for _, email := range emails {
+		if err := userSvc.Create(ctx, user.CreateInput{
+			Name: "Test", Email: email, ReceivingEmail: email,
+			Password: "secret123",
+		}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		u, err := userSvc.GetByEmail(ctx, email)
+		if err != nil {
+			t.Fatalf("GetByEmail: %v", err)
+		}
+		ids[email] = u.ID
+	}
+
+	mailboxSvc := mailbox.NewService(mailbox.NewMemoryRepository(), enc, auditSvc, logger)
+	return &testEnv{
+		svc:        NewService(NewMemoryRepository(), userSvc, mailboxSvc, auditSvc, logger),
+		userSvc:    userSvc,
+		mailboxSvc: mailboxSvc,
+		events:     events,
+		ids:        ids,
+	}
+}
+
+func TestRolesGateTeamOperations(t *testing.T) {
+	env := setupTestService(t, "owner@ex.com", "editor@ex.com", "viewer@ex.com", "outsider@ex.com")
+	ctx := context.Background()
+	owner, editor, viewer, outsider := env.ids["owner@ex.com"], env.ids["editor@ex.com"], env.ids["viewer@ex.com"], env.ids["outsider@ex.com"]
+
+	m, err := env.svc.Create(ctx, owner, "Support")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	teamID := m.ID
+	if err := env.svc.AddMember(ctx, owner, teamID, "editor@ex.com", RoleEditor); err != nil {
+		t.Fatalf("AddMember: %v", err)
+	}
+	if err := env.svc.AddMember(ctx, owner, teamID, "viewer@ex.com", RoleViewer); err != nil {
+		t.Fatalf("AddMember: %v", err)
+	}
+	if err := env.svc.AddMember(ctx, owner, teamID, "viewer@ex.com", RoleViewer); !errors.Is(err, ErrAlreadyMember) {
+		t.Errorf("expected ErrAlreadyMember, got %v", err)
+	}
+	if err := env.svc.AddMember(ctx, editor, teamID, "outsider@ex.com", RoleViewer); !errors.Is(err, ErrForbidden) {
+		t.Errorf("editors must not add members, got %v", err)
+	}
+	// Unknown emails are accepted like known ones and change nothing.
+	if err := env.svc.AddMember(ctx, owner, teamID, "nobody@ex.com", RoleViewer); err != nil {
+		t.Errorf("expected unknown emails to be ignored silently, got %v", err)
+	}
+	if members, _ := env.svc.Members(ctx, owner, teamID); len(members) != 3 {
+		t.Errorf("expected 3 members, got %d", len(members))
+	}
+
+	name, username, password, domain, port := "Shared", "support@ex.com", "imap-secret", "imap.ex.com", 993
+	in := mailbox.Input{Name: &name, Username: &username, Password: &password, Domain: &domain, Port: &port}
+	if _, err := env.svc.CreateMailbox(ctx, viewer, teamID, in); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewers must not add mailboxes, got %v", err)
+	}
+	mb, err := env.svc.CreateMailbox(ctx, editor, teamID, in)
+	if err != nil {
+		t.Fatalf("CreateMailbox: %v", err)
+	}
+	if mb.Password == password {
+		t.Error("mailbox password must be stored encrypted")
+	}
+	if got, err := env.mailboxSvc.DecryptPassword(ctx, mb); err != nil || got != password {
+		t.Errorf("DecryptPassword = %q, %v", got, err)
+	}
+
+	// An editor cannot point the mailbox at another server and keep the
+	// stored credential, which would hand it to that server.
+	evil := "imap.attacker.example"
+	if _, err := env.svc.UpdateMailbox(ctx, editor, teamID, mb.ID, mailbox.Input{Domain: &evil}); !errors.Is(err, mailbox.ErrPasswordNeeded) {
+		t.Errorf("expected ErrPasswordNeeded, got %v", err)
+	}
+	if stored, _ := env.svc.Mailbox(ctx, editor, teamID, mb.ID); stored.Domain != domain {
+		t.Errorf("expected the mailbox to keep its server, got %q", stored.Domain)
+	}
+	newDomain, newPassword := "imap2.ex.com", "new-secret"
+	if _, err := env.svc.UpdateMailbox(ctx, editor, teamID, mb.ID, mailbox.Input{Domain: &newDomain, Password: &newPassword}); err != nil {
+		t.Errorf("a new server with its password should be accepted, got %v", err)
+	}
+	renamed := "Support inbox"
+	if _, err := env.svc.UpdateMailbox(ctx, editor, teamID, mb.ID, mailbox.Input{Name: &renamed, Domain: &newDomain}); err != nil {
+		t.Errorf("other fields need no password, got %v", err)
+	}
+
+	if _, err := env.svc.Mailboxes(ctx, viewer, teamID); err != nil {
+		t.Errorf("viewers may list mailboxes, got %v", err)
+	}
+	if _, err := env.svc.Mailboxes(ctx, outsider, teamID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("non-members must get ErrNotFound, got %v", err)
+	}
+	if _, err := env.svc.Get(ctx, outsider, teamID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("non-members must get ErrNotFound, got %v", err)
+	}
+
+	digestName := "Escalations"
+	d, err := env.svc.CreateDigest(ctx, editor, teamID, DigestInput{Name: &digestName, MailboxID: &mb.ID, Tags: []string{"urgent"}})
+	if err != nil {
+		t.Fatalf("CreateDigest: %v", err)
+	}
+	if err := env.svc.DeleteMailbox(ctx, editor, teamID, mb.ID); !errors.Is(err, ErrMailboxInUse) {
+		t.Errorf("expected ErrMailboxInUse, got %v", err)
+	}
+	bad := "hourly"
+	if _, err := env.svc.UpdateDigest(ctx, editor, teamID, d.ID, DigestInput{UpdateInterval: &bad}); !errors.Is(err, ErrInvalidInterval) {
+		t.Errorf("expected ErrInvalidInterval, got %v", err)
+	}
+
+	other, err := env.svc.Create(ctx, outsider, "Other")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := env.svc.CreateDigest(ctx, outsider, other.ID, DigestInput{Name: &digestName, MailboxID: &mb.ID}); !errors.Is(err, ErrMailboxNotFound) {
+		t.Errorf("digests must not read another team's mailbox, got %v", err)
+	}
+
+	if err := env.svc.Rename(ctx, editor, teamID, "Renamed"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("editors must not rename the team, got %v", err)
+	}
+	if _, err := env.svc.Delete(ctx, editor, teamID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("editors must not delete the team, got %v", err)
+	}
+	deleted, err := env.svc.Delete(ctx, owner, teamID)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0].ID != d.ID {
+		t.Errorf("expected the team's digest to be returned, got %v", deleted)
+	}
+
+	events, _, _ := env.events.List(ctx, audit.Filter{TeamID: teamID, Limit: 100})
+	if len(events) == 0 {
+		t.Fatal("expected team events in the audit log")
+	}
+	for _, e := range events {
+		if e.Action == audit.ActionTeamMailboxCreated && !e.Changes.Has("password") {
+			t.Error("mailbox creation should record the redacted password change")
+		}
+	}
+}
+
+func TestTeamKeepsAnOwner(t *testing.T) {
+	env := setupTestService(t, "a@ex.com", "b@ex.com")
+	ctx := context.Background()
+	a, b := env.ids["a@ex.com"], env.ids["b@ex.com"]
+
+	m, err := env.svc.Create(ctx, a, "Ops")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := env.svc.SetRole(ctx, a, m.ID, a, RoleEditor); !errors.Is(err, ErrLastOwner) {
+		t.Errorf("expected ErrLastOwner on demotion, got %v", err)
+	}
+	if err := env.svc.RemoveMember(ctx, a, m.ID, a); !errors.Is(err, ErrLastOwner) {
+		t.Errorf("expected ErrLastOwner on leaving, got %v", err)
+	}
+
+	if err := env.svc.AddMember(ctx, a, m.ID, "b@ex.com", RoleViewer); err != nil {
+		t.Fatalf("AddMember: %v", err)
+	}
+	if _, err := env.svc.SetRole(ctx, a, m.ID, b, "admin"); !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("expected ErrInvalidRole, got %v", err)
+	}
+	if _, err := env.svc.SetRole(ctx, a, m.ID, b, RoleOwner); err != nil {
+		t.Fatalf("SetRole: %v", err)
+	}
+	if err := env.svc.RemoveMember(ctx, a, m.ID, a); err != nil {
+		t.Errorf("a second owner should let the first leave, got %v", err)
+	}
+	if _, err := env.svc.Get(ctx, a, m.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after leaving, got %v", err)
+	}
+}
+
+func TestKeepOwnersOnAccountDeletion(t *testing.T) {
+	env := setupTestService(t, "a@ex.com", "b@ex.com")
+	ctx := context.Background()
+	a, b := env.ids["a@ex.com"], env.ids["b@ex.com"]
+
+	m, err := env.svc.Create(ctx, a, "Ops")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := env.svc.AddMember(ctx, a, m.ID, "b@ex.com", RoleEditor); err != nil {
+		t.Fatalf("AddMember: %v", err)
+	}
+	if err := env.svc.KeepOwners(ctx, a); !errors.Is(err, ErrLastOwner) {
+		t.Errorf("expected ErrLastOwner for the only owner, got %v", err)
+	}
+	if err := env.svc.KeepOwners(ctx, b); err != nil {
+		t.Errorf("an editor can always leave, got %v", err)
+	}
+
+	if _, err := env.svc.SetRole(ctx, a, m.ID, b, RoleOwner); err != nil {
+		t.Fatalf("SetRole: %v", err)
+	}
+	if err := env.svc.KeepOwners(ctx, a); err != nil {
+		t.Errorf("a second owner should let the first go, got %v", err)
+	}
+}
+
+func TestRecipientsHonorDeliveryPreferences(t *testing.T) {
+	env := setupTestService(t, "a@ex.com", "b@ex.com", "c@ex.com")
+	ctx := context.Background()
+	a, b := env.ids["a@ex.com"], env.ids["b@ex.com"]
+	for _, id := range []string{a, b} {
+		u, _ := env.userSvc.GetByID(ctx, id)
+		if err := env.userSvc.ConfirmReceivingEmail(ctx, id, u.ReceivingEmail); err != nil {
+			t.Fatalf("ConfirmReceivingEmail: %v", err)
+		}
+	}
+
+	m, err := env.svc.Create(ctx, a, "Ops")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	for _, email := range []string{"b@ex.com", "c@ex.com"} {
+		if err := env.svc.AddMember(ctx, a, m.ID, email, RoleViewer); err != nil {
+			t.Fatalf("AddMember: %v", err)
+		}
+	}
+	// Added members receive nothing until they opt in.
+	recipients, err := env.svc.Recipients(ctx, m.ID)
+	if err != nil {
+		t.Fatalf("Recipients: %v", err)
+	}
+	if len(recipients) != 1 || recipients[0].ID != a {
+		t.Errorf("expected only the owner to receive digests, got %v", recipients)
+	}
+
+	for _, id := range []string{b, env.ids["c@ex.com"]} {
+		if _, err := env.svc.SetDelivery(ctx, id, m.ID, true); err != nil {
+			t.Fatalf("SetDelivery: %v", err)
+		}
+	}
+	if _, err := env.svc.SetDelivery(ctx, a, m.ID, false); err != nil {
+		t.Fatalf("SetDelivery: %v", err)
+	}
+	recipients, err = env.svc.Recipients(ctx, m.ID)
+	if err != nil {
+		t.Fatalf("Recipients: %v", err)
+	}
+	// a opted out and c has not confirmed a receiving email.
+	if len(recipients) != 1 || recipients[0].ID != b {
+		t.Errorf("expected only b to receive digests, got %v", recipients)
+	}
+}
@@ -0,0 +1,59 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/lib/pq"
+)
+
+// save writes u and records action in the audit log with the fields that
+// differ from before. Plain updates that change nothing are not recorded.
+func (s *Service) save(ctx context.Context, action string, before, u *User) error {
+	if err := s.repo.Update(ctx, u); err != nil {
+		return err
+	}
+	changes := diff(before, u)
+	if action == audit.ActionUserUpdated && len(changes) == 0 {
+		return nil
+	}
+	s.audit.TryRecord(ctx, audit.Event{UserID: u.ID, Action: action, Changes: changes})
+	return nil
+}
+
+// diff lists the fields that differ between two versions of a user. The
+// login password and legacy IMAP credential only ever appear redacted.
+func diff(before, after *User) audit.Changes {
+	var c audit.Changes
+	c.Add("name", before.Name, after.Name)
+	c.Add("email", before.Email, after.Email)
+	c.Add("receivingEmail", before.ReceivingEmail, after.ReceivingEmail)
+	c.Add("receivingEmailVerified", before.ReceivingEmailVerified, after.ReceivingEmailVerified)
+	c.AddSecret("password", before.PasswordHash, after.PasswordHash)
+	c.AddSecret("imapPassword", before.IMAPPassword, after.IMAPPassword)
+	c.Add("oidcSubject", before.OIDCSubject, after.OIDCSubject)
+	c.Add("role", before.Role, after.Role)
+	c.Add("disabled", before.Disabled, after.Disabled)
+	c.Add("tags", list(before.Tags), list(after.Tags))
+	c.Add("blackListSenders", list(before.BlackListSenders), list(after.BlackListSenders))
+	c.Add("startTime", timestamp(before.StartTime), timestamp(after.StartTime))
+	c.Add("summaryCount", before.SummaryCount, after.SummaryCount)
+	c.Add("updateInterval", before.UpdateInterval, after.UpdateInterval)
+	return c
+}
+
+// list treats nil and empty lists alike.
+func list(a pq.StringArray) []string {
+	if len(a) == 0 {
+		return nil
+	}
+	return a
+}
+
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
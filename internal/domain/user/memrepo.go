@@ -2,6 +2,8 @@ package user
 
 import (
 	"context"
+	"sort"
+	"strings"
 	"sync"
 )
 
@@ -45,6 +47,18 @@ func (r *MemoryRepository) FindByEmail(_ context.Context, email string) (*User,
 	return nil, ErrNotFound
 }
 
+func (r *MemoryRepository) FindByOIDCSubject(_ context.Context, subject string) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, u := range r.users {
+		if u.OIDCSubject != "" && u.OIDCSubject == subject {
+			cp := *u
+			return &cp, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
 func (r *MemoryRepository) Update(_ context.Context, u *User) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -69,3 +83,31 @@ func (r *MemoryRepository) ListAll(_ context.Context) ([]*User, error) {
 	}
 	return result, nil
 }
+
+func (r *MemoryRepository) Search(_ context.Context, query string, limit, offset int) ([]*User, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	q := strings.ToLower(query)
+	var matches []*User
+	for _, u := range r.users {
+		if strings.Contains(strings.ToLower(u.Name), q) || strings.Contains(strings.ToLower(u.Email), q) {
+			cp := *u
+			matches = append(matches, &cp)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].CreatedAt.Equal(matches[j].CreatedAt) {
+			return matches[i].CreatedAt.Before(matches[j].CreatedAt)
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	total := int64(len(matches))
+	if offset >= len(matches) {
+		return []*User{}, total, nil
+	}
+	matches = matches[offset:]
+	if limit < len(matches) {
+		matches = matches[:limit]
+	}
+	return matches, total, nil
+}
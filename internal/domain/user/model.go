@@ -13,24 +13,45 @@ var (
 	ErrAlreadyExists   = errors.New("user already exists")
 	ErrInvalidPassword = errors.New("invalid credentials")
 	ErrNoTags          = errors.New("no tags configured")
+	ErrNoAccount       = errors.New("no account for this identity")
+	ErrEmailUnverified = errors.New("identity has no verified email")
+	ErrDisabled        = errors.New("account is disabled")
+	ErrInvalidRole     = errors.New("invalid role")
+	ErrUnverified      = errors.New("receiving email is not verified")
+	ErrEmailChanged    = errors.New("receiving email has changed")
+	ErrLinkUnproven    = errors.New("account email is not verified")
 )
 
-// User represents a registered MailDruid user.
+// Roles a user can hold.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// User represents a registered MailDruid user. The mailboxes a user's
+// digest reads are kept by the mailbox package.
 type User struct {
-	ID               string         `json:"id" gorm:"primaryKey"`
-	Name             string         `json:"name"`
-	Email            string         `json:"email" gorm:"uniqueIndex"`
-	ReceivingEmail   string         `json:"receivingEmail"`
-	Password         string         `json:"-"`
-	Domain           string         `json:"domain"`
-	Port             int            `json:"port"`
-	Folder           string         `json:"folder"`
-	Tags             pq.StringArray `json:"tags" gorm:"type:text[]"`
-	BlackListSenders pq.StringArray `json:"blackListSenders" gorm:"type:text[]"`
-	StartTime        time.Time      `json:"startTime"`
-	SummaryCount     int            `json:"summaryCount"`
-	LastUID          string         `json:"-"`
-	UpdateInterval   string         `json:"updateInterval"`
-	CreatedAt        time.Time      `json:"createdAt"`
-	UpdatedAt        time.Time      `json:"updatedAt"`
+	ID                     string         `json:"id" gorm:"primaryKey"`
+	Name                   string         `json:"name"`
+	Email                  string         `json:"email" gorm:"uniqueIndex"`
+	ReceivingEmail         string         `json:"receivingEmail"`
+	ReceivingEmailVerified bool           `json:"receivingEmailVerified"`       // digests are only delivered to verified addresses
+	PasswordHash           string         `json:"-"`                            // bcrypt hash of the MailDruid login password
+	IMAPPassword           string         `json:"-"`                            // encrypted IMAP credential of legacy accounts without a login password
+	TokenGeneration        int            `json:"-"`                            // bumped to invalidate all issued tokens
+	OIDCSubject            string         `json:"-" gorm:"column:oidc_subject"` // "sub" claim of the linked single sign-on identity
+	Role                   string         `json:"role"`
+	Disabled               bool           `json:"disabled"` // disabled accounts cannot sign in and are skipped by the scheduler
+	Tags                   pq.StringArray `json:"tags" gorm:"type:text[]"`
+	BlackListSenders       pq.StringArray `json:"blackListSenders" gorm:"type:text[]"`
+	StartTime              time.Time      `json:"startTime"`
+	SummaryCount           int            `json:"summaryCount"`
+	UpdateInterval         string         `json:"updateInterval"`
+	CreatedAt              time.Time      `json:"createdAt"`
+	UpdatedAt              time.Time      `json:"updatedAt"`
+}
+
+// IsAdmin reports whether the user holds the admin role.
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
 }
@@ -0,0 +1,48 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// hashPassword returns a bcrypt hash of a login password.
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("hashing password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// checkPassword compares a login password with its bcrypt hash.
+func checkPassword(hash, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrInvalidPassword
+	}
+	if err != nil {
+		return fmt.Errorf("checking password: %w", err)
+	}
+	return nil
+}
+
+// dummyHash is a bcrypt hash of a random password at the cost real hashes
+// use. Comparing against it when there is no hash to check makes a failed
+// login take as long whether or not the account exists.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("maildruid-dummy-password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(fmt.Sprintf("hashing dummy password: %v", err))
+	}
+	return hash
+})
+
+// rejectPassword spends the time of a bcrypt comparison and returns
+// ErrInvalidPassword.
+func rejectPassword(password string) error {
+	_ = bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+	return ErrInvalidPassword
+}
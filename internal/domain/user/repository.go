@@ -7,7 +7,12 @@ type Repository interface {
 	Create(ctx context.Context, user *User) error
 	FindByID(ctx context.Context, id string) (*User, error)
 	FindByEmail(ctx context.Context, email string) (*User, error)
+	FindByOIDCSubject(ctx context.Context, subject string) (*User, error)
 	Update(ctx context.Context, user *User) error
 	Delete(ctx context.Context, id string) error
 	ListAll(ctx context.Context) ([]*User, error)
+	// Search returns a page of users whose name or email contains query
+	// (case-insensitively), oldest first, along with the number of matches.
+	// An empty query matches every user.
+	Search(ctx context.Context, query string, limit, offset int) ([]*User, int64, error)
 }
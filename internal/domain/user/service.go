@@ -2,25 +2,30 @@ package user
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
 	"github.com/akhil-datla/maildruid/internal/infrastructure/encryption"
 	"github.com/gofrs/uuid"
 )
 
-// Service contains user business logic.
+// Service contains user business logic. Changes to accounts are written
+// to the audit log.
 type Service struct {
 	repo      Repository
 	encryptor *encryption.Service
+	audit     *audit.Service
 	logger    *slog.Logger
 }
 
 // NewService creates a new user service.
-func NewService(repo Repository, enc *encryption.Service, logger *slog.Logger) *Service {
-	return &Service{repo: repo, encryptor: enc, logger: logger}
+func NewService(repo Repository, enc *encryption.Service, auditSvc *audit.Service, logger *slog.Logger) *Service {
+	return &Service{repo: repo, encryptor: enc, audit: auditSvc, logger: logger}
 }
 
 // CreateInput holds data needed to register a new user.
@@ -28,12 +33,11 @@ type CreateInput struct {
 	Name           string
 	Email          string
 	ReceivingEmail string
-	Password       string
-	Domain         string
-	Port           int
+	Password       string // MailDruid login password
 }
 
-// Create registers a new user with an encrypted password.
+// Create registers a new user with a hashed login password. Mailboxes are
+// added separately.
 func (s *Service) Create(ctx context.Context, in CreateInput) error {
 	_, err := s.repo.FindByEmail(ctx, in.Email)
 	if err == nil {
@@ -48,9 +52,9 @@ func (s *Service) Create(ctx context.Context, in CreateInput) error {
 		return fmt.Errorf("generating UUID: %w", err)
 	}
 
-	encrypted, err := s.encryptor.Encrypt(in.Password)
+	hash, err := hashPassword(in.Password)
 	if err != nil {
-		return fmt.Errorf("encrypting password: %w", err)
+		return err
 	}
 
 	u := &User{
@@ -58,44 +62,212 @@ func (s *Service) Create(ctx context.Context, in CreateInput) error {
 		Name:           in.Name,
 		Email:          in.Email,
 		ReceivingEmail: in.ReceivingEmail,
-		Password:       base64.RawStdEncoding.EncodeToString(encrypted),
-		Domain:         in.Domain,
-		Port:           in.Port,
+		PasswordHash:   hash,
+		Role:           RoleUser,
 		SummaryCount:   5,
 	}
 
 	if err := s.repo.Create(ctx, u); err != nil {
 		return fmt.Errorf("creating user: %w", err)
 	}
+	s.audit.TryRecord(ctx, audit.Event{UserID: u.ID, Action: audit.ActionUserCreated, Changes: diff(&User{}, u)})
 
 	s.logger.Info("user created", "id", u.ID, "email", u.Email)
 	return nil
 }
 
-// Authenticate validates credentials and returns the user ID.
+// Authenticate validates the login password and returns the user ID.
+//
+// Accounts created before login passwords were separated from IMAP
+// credentials have no password hash. For those the password is checked
+// against the IMAP credential once and, on success, stored as the login
+// password hash so later logins no longer touch the mailbox secret.
 func (s *Service) Authenticate(ctx context.Context, email, password string) (string, error) {
 	u, err := s.repo.FindByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, ErrNotFound) {
-			return "", ErrInvalidPassword
+			return "", rejectPassword(password)
+		}
+		return "", err
+	}
+
+	if err := s.verifyPassword(ctx, u, password); err != nil {
+		if errors.Is(err, ErrInvalidPassword) {
+			s.audit.TryRecord(ctx, audit.Event{UserID: u.ID, Action: audit.ActionLoginFailed, Detail: "wrong password"})
 		}
 		return "", err
 	}
+	if u.Disabled {
+		return "", ErrDisabled
+	}
 
-	rawPass, err := base64.RawStdEncoding.DecodeString(u.Password)
+	if u.PasswordHash == "" {
+		hash, err := hashPassword(password)
+		if err != nil {
+			return "", err
+		}
+		// The user's mailbox keeps its own copy of the IMAP credential.
+		u.PasswordHash = hash
+		u.IMAPPassword = ""
+		if err := s.repo.Update(ctx, u); err != nil {
+			return "", fmt.Errorf("upgrading credentials: %w", err)
+		}
+		s.logger.Info("migrated legacy login password", "id", u.ID)
+	}
+
+	return u.ID, nil
+}
+
+// RotateSecrets re-encrypts every user's stored secrets under the primary
+// encryption key and returns how many users were updated. Users whose
+// secrets are already current are left untouched.
+func (s *Service) RotateSecrets(ctx context.Context) (int, error) {
+	users, err := s.repo.ListAll(ctx)
 	if err != nil {
-		return "", fmt.Errorf("decoding password: %w", err)
+		return 0, err
+	}
+
+	updated := 0
+	for _, u := range users {
+		rotated, err := s.rotateSecrets(u)
+		if err != nil {
+			return updated, fmt.Errorf("user %s: %w", u.ID, err)
+		}
+		if !rotated {
+			continue
+		}
+		if err := s.repo.Update(ctx, u); err != nil {
+			return updated, fmt.Errorf("user %s: %w", u.ID, err)
+		}
+		updated++
 	}
+	return updated, nil
+}
 
-	decrypted, err := s.encryptor.Decrypt(rawPass)
+// rotateSecrets re-encrypts u's secrets in place when they are not sealed
+// with the primary key. It reports whether anything changed.
+func (s *Service) rotateSecrets(u *User) (bool, error) {
+	if u.IMAPPassword == "" {
+		return false, nil
+	}
+	raw, err := base64.RawStdEncoding.DecodeString(u.IMAPPassword)
+	if err != nil {
+		return false, fmt.Errorf("decoding IMAP password: %w", err)
+	}
+	rotated, changed, err := s.encryptor.Rotate(raw)
 	if err != nil {
-		return "", fmt.Errorf("decrypting password: %w", err)
+		return false, fmt.Errorf("re-encrypting IMAP password: %w", err)
+	}
+	if changed {
+		u.IMAPPassword = base64.RawStdEncoding.EncodeToString(rotated)
+	}
+	return changed, nil
+}
+
+// verifyPassword checks password against the user's login password hash, or
+// against the IMAP credential for legacy accounts without one. Accounts
+// that sign in through single sign-on have no password to check.
+func (s *Service) verifyPassword(ctx context.Context, u *User, password string) error {
+	if u.PasswordHash != "" {
+		return checkPassword(u.PasswordHash, password)
+	}
+	if u.OIDCSubject != "" || u.IMAPPassword == "" {
+		return rejectPassword(password)
+	}
+
+	decrypted, err := s.DecryptIMAPPassword(ctx, u)
+	if err != nil {
+		return fmt.Errorf("decrypting password: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(decrypted), []byte(password)) != 1 {
+		return ErrInvalidPassword
+	}
+	return nil
+}
+
+// OIDCIdentity holds the verified claims of an OpenID Connect ID token.
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// LoginOIDC returns the ID of the user behind an OpenID Connect identity.
+//
+// Users are matched on the subject claim first. On the first SSO login an
+// existing account with the same verified email is linked to the subject,
+// but only if the account has proven it owns that address: login emails
+// are never checked, so an account whose receiving email is not the same,
+// verified address gets ErrLinkUnproven instead. If there is no account
+// and provision is set, a new one without a login password is created.
+// IMAP settings are filled in later by the user.
+func (s *Service) LoginOIDC(ctx context.Context, id OIDCIdentity, provision bool) (string, error) {
+	u, err := s.repo.FindByOIDCSubject(ctx, id.Subject)
+	if err == nil {
+		if u.Disabled {
+			return "", ErrDisabled
+		}
+		return u.ID, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return "", err
 	}
 
-	if decrypted != password {
-		return "", ErrInvalidPassword
+	if id.Email == "" || !id.EmailVerified {
+		return "", ErrEmailUnverified
+	}
+
+	u, err = s.repo.FindByEmail(ctx, id.Email)
+	switch {
+	case err == nil:
+		if u.OIDCSubject != "" {
+			return "", ErrAlreadyExists
+		}
+		if u.Disabled {
+			return "", ErrDisabled
+		}
+		if !u.ReceivingEmailVerified || !strings.EqualFold(u.ReceivingEmail, u.Email) {
+			return "", ErrLinkUnproven
+		}
+		before := *u
+		u.OIDCSubject = id.Subject
+		if err := s.save(ctx, audit.ActionUserUpdated, &before, u); err != nil {
+			return "", fmt.Errorf("linking OIDC identity: %w", err)
+		}
+		s.logger.Info("linked OIDC identity", "id", u.ID, "subject", id.Subject)
+		return u.ID, nil
+	case !errors.Is(err, ErrNotFound):
+		return "", fmt.Errorf("checking existing user: %w", err)
+	case !provision:
+		return "", ErrNoAccount
+	}
+
+	uid, err := uuid.NewV4()
+	if err != nil {
+		return "", fmt.Errorf("generating UUID: %w", err)
+	}
+	name := id.Name
+	if name == "" {
+		name = id.Email
+	}
+	u = &User{
+		ID:    uid.String(),
+		Name:  name,
+		Email: id.Email,
+		// The provider vouched for the email, so it needs no second check.
+		ReceivingEmail:         id.Email,
+		ReceivingEmailVerified: true,
+		OIDCSubject:            id.Subject,
+		Role:                   RoleUser,
+		SummaryCount:           5,
+	}
+	if err := s.repo.Create(ctx, u); err != nil {
+		return "", fmt.Errorf("creating user: %w", err)
 	}
+	s.audit.TryRecord(ctx, audit.Event{UserID: u.ID, Action: audit.ActionUserCreated, Detail: "provisioned by single sign-on", Changes: diff(&User{}, u)})
 
+	s.logger.Info("user provisioned via OIDC", "id", u.ID, "email", u.Email)
 	return u.ID, nil
 }
 
@@ -104,6 +276,11 @@ func (s *Service) GetByID(ctx context.Context, id string) (*User, error) {
 	return s.repo.FindByID(ctx, id)
 }
 
+// GetByEmail retrieves a user by login email.
+func (s *Service) GetByEmail(ctx context.Context, email string) (*User, error) {
+	return s.repo.FindByEmail(ctx, email)
+}
+
 // UpdateInput holds optional fields for updating a user.
 type UpdateInput struct {
 	Name           *string
@@ -111,17 +288,16 @@ type UpdateInput struct {
 	ReceivingEmail *string
 	OldPassword    *string
 	NewPassword    *string
-	Domain         *string
-	Port           *int
-	Folder         *string
 }
 
-// Update modifies user fields. Password change requires old password verification.
+// Update modifies user fields. Changing the login password requires the old
+// password.
 func (s *Service) Update(ctx context.Context, id string, in UpdateInput) error {
 	u, err := s.repo.FindByID(ctx, id)
 	if err != nil {
 		return err
 	}
+	before := *u
 
 	if in.Name != nil && *in.Name != "" {
 		u.Name = *in.Name
@@ -129,44 +305,155 @@ func (s *Service) Update(ctx context.Context, id string, in UpdateInput) error {
 	if in.Email != nil && *in.Email != "" {
 		u.Email = *in.Email
 	}
-	if in.ReceivingEmail != nil && *in.ReceivingEmail != "" {
+	if in.ReceivingEmail != nil && *in.ReceivingEmail != "" && *in.ReceivingEmail != u.ReceivingEmail {
 		u.ReceivingEmail = *in.ReceivingEmail
-	}
-	if in.Domain != nil && *in.Domain != "" {
-		u.Domain = *in.Domain
-	}
-	if in.Port != nil && *in.Port != 0 {
-		u.Port = *in.Port
-	}
-	if in.Folder != nil && *in.Folder != "" {
-		u.Folder = *in.Folder
+		u.ReceivingEmailVerified = false
 	}
 
 	if in.OldPassword != nil && in.NewPassword != nil && *in.OldPassword != "" && *in.NewPassword != "" {
-		rawPass, err := base64.RawStdEncoding.DecodeString(u.Password)
-		if err != nil {
-			return fmt.Errorf("decoding password: %w", err)
-		}
-		decrypted, err := s.encryptor.Decrypt(rawPass)
-		if err != nil {
-			return fmt.Errorf("decrypting password: %w", err)
-		}
-		if decrypted != *in.OldPassword {
-			return ErrInvalidPassword
+		if err := s.verifyPassword(ctx, u, *in.OldPassword); err != nil {
+			return err
 		}
-		encrypted, err := s.encryptor.Encrypt(*in.NewPassword)
+		hash, err := hashPassword(*in.NewPassword)
 		if err != nil {
-			return fmt.Errorf("encrypting new password: %w", err)
+			return err
 		}
-		u.Password = base64.RawStdEncoding.EncodeToString(encrypted)
+		u.PasswordHash = hash
+		// A password change signs out every existing session.
+		u.TokenGeneration++
 	}
 
-	return s.repo.Update(ctx, u)
+	action := audit.ActionUserUpdated
+	if u.PasswordHash != before.PasswordHash {
+		action = audit.ActionPasswordChanged
+	}
+	return s.save(ctx, action, &before, u)
 }
 
-// Delete removes a user by ID.
+// ResetPassword replaces the login password without the old one, for the
+// forgotten-password flow, and signs out every existing session.
+func (s *Service) ResetPassword(ctx context.Context, id, password string) error {
+	u, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	before := *u
+	u.PasswordHash = hash
+	u.TokenGeneration++
+	if err := s.save(ctx, audit.ActionPasswordReset, &before, u); err != nil {
+		return err
+	}
+	s.logger.Info("password reset", "id", id)
+	return nil
+}
+
+// ConfirmReceivingEmail marks the user's receiving email as verified. It
+// returns ErrEmailChanged if the address is no longer email, so a link
+// sent to an old address cannot verify a new one.
+func (s *Service) ConfirmReceivingEmail(ctx context.Context, id, email string) error {
+	u, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if u.ReceivingEmail != email {
+		return ErrEmailChanged
+	}
+	if u.ReceivingEmailVerified {
+		return nil
+	}
+	before := *u
+	u.ReceivingEmailVerified = true
+	if err := s.save(ctx, audit.ActionEmailVerified, &before, u); err != nil {
+		return err
+	}
+	s.logger.Info("receiving email verified", "id", id)
+	return nil
+}
+
+// TokenGeneration returns the user's current token generation. Tokens
+// issued for an older generation are no longer valid.
+func (s *Service) TokenGeneration(ctx context.Context, id string) (int, error) {
+	u, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	return u.TokenGeneration, nil
+}
+
+// RevokeTokens invalidates every access and refresh token issued to the
+// user so far.
+func (s *Service) RevokeTokens(ctx context.Context, id string) error {
+	u, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	before := *u
+	u.TokenGeneration++
+	return s.save(ctx, audit.ActionSessionsRevoked, &before, u)
+}
+
+// SetDisabled disables or re-enables an account. Disabling signs out every
+// existing session.
+func (s *Service) SetDisabled(ctx context.Context, id string, disabled bool) error {
+	u, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if u.Disabled == disabled {
+		return nil
+	}
+	before := *u
+	u.Disabled = disabled
+	action := audit.ActionUserEnabled
+	if disabled {
+		u.TokenGeneration++
+		action = audit.ActionUserDisabled
+	}
+	if err := s.save(ctx, action, &before, u); err != nil {
+		return err
+	}
+	s.logger.Info("user account updated", "id", id, "disabled", disabled)
+	return nil
+}
+
+// SetRoleByEmail assigns role to the user with the given email.
+func (s *Service) SetRoleByEmail(ctx context.Context, email, role string) (*User, error) {
+	if role != RoleUser && role != RoleAdmin {
+		return nil, ErrInvalidRole
+	}
+	u, err := s.repo.FindByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if u.Role == role {
+		return u, nil
+	}
+	before := *u
+	u.Role = role
+	if err := s.save(ctx, audit.ActionRoleChanged, &before, u); err != nil {
+		return nil, err
+	}
+	s.logger.Info("user role changed", "id", u.ID, "email", u.Email, "role", role)
+	return u, nil
+}
+
+// Search returns a page of users whose name or email contains query, along
+// with the total number of matches.
+func (s *Service) Search(ctx context.Context, query string, limit, offset int) ([]*User, int64, error) {
+	return s.repo.Search(ctx, query, limit, offset)
+}
+
+// Delete removes a user by ID. The user's audit log is kept.
 func (s *Service) Delete(ctx context.Context, id string) error {
-	return s.repo.Delete(ctx, id)
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.audit.TryRecord(ctx, audit.Event{UserID: id, Action: audit.ActionUserDeleted})
+	return nil
 }
 
 // UpdateTags sets the email filter tags for a user.
@@ -175,8 +462,9 @@ func (s *Service) UpdateTags(ctx context.Context, id string, tags []string) erro
 	if err != nil {
 		return err
 	}
+	before := *u
 	u.Tags = tags
-	return s.repo.Update(ctx, u)
+	return s.save(ctx, audit.ActionUserUpdated, &before, u)
 }
 
 // UpdateBlackListSenders sets the sender blacklist for a user.
@@ -185,8 +473,9 @@ func (s *Service) UpdateBlackListSenders(ctx context.Context, id string, senders
 	if err != nil {
 		return err
 	}
+	before := *u
 	u.BlackListSenders = senders
-	return s.repo.Update(ctx, u)
+	return s.save(ctx, audit.ActionUserUpdated, &before, u)
 }
 
 // UpdateStartTime sets the email processing start time.
@@ -196,6 +485,7 @@ func (s *Service) UpdateStartTime(ctx context.Context, id string, startTime stri
 		return err
 	}
 
+	before := *u
 	if startTime != "" {
 		parsed, err := parseTime(startTime)
 		if err != nil {
@@ -204,7 +494,7 @@ func (s *Service) UpdateStartTime(ctx context.Context, id string, startTime stri
 		u.StartTime = parsed
 	}
 
-	return s.repo.Update(ctx, u)
+	return s.save(ctx, audit.ActionUserUpdated, &before, u)
 }
 
 // UpdateSummaryCount sets the number of sentences in summaries.
@@ -213,46 +503,65 @@ func (s *Service) UpdateSummaryCount(ctx context.Context, id string, count int)
 	if err != nil {
 		return err
 	}
+	before := *u
 	u.SummaryCount = count
-	return s.repo.Update(ctx, u)
+	return s.save(ctx, audit.ActionUserUpdated, &before, u)
 }
 
-// UpdateFolder sets the IMAP folder to scan.
-func (s *Service) UpdateFolder(ctx context.Context, id string, folder string) error {
+// UpdateInterval sets the scheduling interval for a user.
+func (s *Service) UpdateInterval(ctx context.Context, id string, interval string) error {
 	u, err := s.repo.FindByID(ctx, id)
 	if err != nil {
 		return err
 	}
-	u.Folder = folder
-	return s.repo.Update(ctx, u)
+	before := *u
+	u.UpdateInterval = interval
+	return s.save(ctx, audit.ActionScheduleUpdated, &before, u)
 }
 
-// UpdateInterval sets the scheduling interval for a user.
-func (s *Service) UpdateInterval(ctx context.Context, id string, interval string) error {
+// DecryptIMAPPassword decrypts and returns the legacy IMAP credential. A
+// credential not sealed with the primary key is re-encrypted and saved.
+func (s *Service) DecryptIMAPPassword(ctx context.Context, u *User) (string, error) {
+	rawPass, err := base64.RawStdEncoding.DecodeString(u.IMAPPassword)
+	if err != nil {
+		return "", fmt.Errorf("decoding password: %w", err)
+	}
+	password, rotated, err := s.encryptor.DecryptRotate(rawPass)
+	if err != nil || rotated == nil {
+		return password, err
+	}
+
+	old := u.IMAPPassword
+	u.IMAPPassword = base64.RawStdEncoding.EncodeToString(rotated)
+	if err := s.replaceIMAPPassword(ctx, u.ID, old, u.IMAPPassword); err != nil {
+		s.logger.Error("failed to upgrade IMAP password encryption", "id", u.ID, "error", err)
+	}
+	return password, nil
+}
+
+// replaceIMAPPassword stores a re-encrypted IMAP credential unless the
+// stored one has changed in the meantime.
+func (s *Service) replaceIMAPPassword(ctx context.Context, id, old, rotated string) error {
 	u, err := s.repo.FindByID(ctx, id)
 	if err != nil {
 		return err
 	}
-	u.UpdateInterval = interval
+	if u.IMAPPassword != old {
+		return nil
+	}
+	u.IMAPPassword = rotated
 	return s.repo.Update(ctx, u)
 }
 
-// DecryptPassword decrypts and returns the user's IMAP password.
-func (s *Service) DecryptPassword(u *User) (string, error) {
-	rawPass, err := base64.RawStdEncoding.DecodeString(u.Password)
+func (s *Service) encryptIMAPPassword(password string) (string, error) {
+	encrypted, err := s.encryptor.Encrypt(password)
 	if err != nil {
-		return "", fmt.Errorf("decoding password: %w", err)
+		return "", fmt.Errorf("encrypting IMAP password: %w", err)
 	}
-	return s.encryptor.Decrypt(rawPass)
+	return base64.RawStdEncoding.EncodeToString(encrypted), nil
 }
 
 // ListAll returns all users.
 func (s *Service) ListAll(ctx context.Context) ([]*User, error) {
 	return s.repo.ListAll(ctx)
 }
-
-// SaveLastUID persists the last processed UID map.
-func (s *Service) SaveLastUID(ctx context.Context, u *User, lastUID string) error {
-	u.LastUID = lastUID
-	return s.repo.Update(ctx, u)
-}
@@ -6,7 +6,9 @@ import (
 	"os"
 	"testing"
 
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
 	"github.com/akhil-datla/maildruid/internal/infrastructure/encryption"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func setupTestService(t *testing.T) (*Service, *MemoryRepository) {
@@ -17,7 +19,7 @@ func setupTestService(t *testing.T) (*Service, *MemoryRepository) {
 		t.Fatalf("encryption.New: %v", err)
 	}
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
-	svc := NewService(repo, enc, logger)
+	svc := NewService(repo, enc, audit.NewService(audit.NewMemoryRepository(), logger), logger)
 	return svc, repo
 }
 
@@ -30,8 +32,6 @@ func TestCreateUser(t *testing.T) {
 		Email:          "test@example.com",
 		ReceivingEmail: "recv@example.com",
 		Password:       "secret123",
-		Domain:         "imap.example.com",
-		Port:           993,
 	})
 	if err != nil {
 		t.Fatalf("Create: %v", err)
@@ -48,11 +48,11 @@ func TestCreateUser(t *testing.T) {
 	if users[0].Email != "test@example.com" {
 		t.Errorf("expected email 'test@example.com', got %q", users[0].Email)
 	}
-	if users[0].Password == "" {
-		t.Error("password should be encrypted, not empty")
+	if users[0].PasswordHash == "" || users[0].PasswordHash == "secret123" {
+		t.Errorf("login password should be hashed, got %q", users[0].PasswordHash)
 	}
-	if users[0].Password == "secret123" {
-		t.Error("password should be encrypted, not plaintext")
+	if users[0].IMAPPassword != "" {
+		t.Errorf("new users keep no IMAP credential, got %q", users[0].IMAPPassword)
 	}
 	if users[0].SummaryCount != 5 {
 		t.Errorf("expected default summary count 5, got %d", users[0].SummaryCount)
@@ -68,8 +68,6 @@ func TestCreateDuplicateUser(t *testing.T) {
 		Email:          "dup@example.com",
 		ReceivingEmail: "recv@example.com",
 		Password:       "secret123",
-		Domain:         "imap.example.com",
-		Port:           993,
 	}
 
 	if err := svc.Create(ctx, input); err != nil {
@@ -91,8 +89,6 @@ func TestAuthenticateSuccess(t *testing.T) {
 		Email:          "auth@example.com",
 		ReceivingEmail: "recv@example.com",
 		Password:       "mypassword",
-		Domain:         "imap.example.com",
-		Port:           993,
 	}); err != nil {
 		t.Fatalf("Create: %v", err)
 	}
@@ -115,8 +111,6 @@ func TestAuthenticateWrongPassword(t *testing.T) {
 		Email:          "auth2@example.com",
 		ReceivingEmail: "recv@example.com",
 		Password:       "correctpass",
-		Domain:         "imap.example.com",
-		Port:           993,
 	}); err != nil {
 		t.Fatalf("Create: %v", err)
 	}
@@ -135,6 +129,11 @@ func TestAuthenticateNonexistentUser(t *testing.T) {
 	if err != ErrInvalidPassword {
 		t.Fatalf("expected ErrInvalidPassword, got %v", err)
 	}
+	// Unknown emails are checked against a hash as costly as a real one,
+	// so the response time does not reveal which accounts exist.
+	if cost, err := bcrypt.Cost(dummyHash()); err != nil || cost != bcrypt.DefaultCost {
+		t.Errorf("expected a dummy hash of cost %d, got %d (%v)", bcrypt.DefaultCost, cost, err)
+	}
 }
 
 func TestGetByID(t *testing.T) {
@@ -146,8 +145,6 @@ func TestGetByID(t *testing.T) {
 		Email:          "get@example.com",
 		ReceivingEmail: "recv@example.com",
 		Password:       "pass",
-		Domain:         "imap.example.com",
-		Port:           993,
 	}); err != nil {
 		t.Fatalf("Create: %v", err)
 	}
@@ -181,8 +178,6 @@ func TestUpdateProfile(t *testing.T) {
 		Email:          "update@example.com",
 		ReceivingEmail: "recv@example.com",
 		Password:       "pass",
-		Domain:         "imap.example.com",
-		Port:           993,
 	}); err != nil {
 		t.Fatalf("Create: %v", err)
 	}
@@ -217,8 +212,6 @@ func TestUpdatePassword(t *testing.T) {
 		Email:          "passchange@example.com",
 		ReceivingEmail: "recv@example.com",
 		Password:       "oldpass",
-		Domain:         "imap.example.com",
-		Port:           993,
 	}); err != nil {
 		t.Fatalf("Create: %v", err)
 	}
@@ -257,8 +250,6 @@ func TestUpdatePasswordWrongOld(t *testing.T) {
 		Email:          "wrongold@example.com",
 		ReceivingEmail: "recv@example.com",
 		Password:       "realpass",
-		Domain:         "imap.example.com",
-		Port:           993,
 	}); err != nil {
 		t.Fatalf("Create: %v", err)
 	}
@@ -285,8 +276,6 @@ func TestDeleteUser(t *testing.T) {
 		Email:          "delete@example.com",
 		ReceivingEmail: "recv@example.com",
 		Password:       "pass",
-		Domain:         "imap.example.com",
-		Port:           993,
 	}); err != nil {
 		t.Fatalf("Create: %v", err)
 	}
@@ -310,7 +299,7 @@ func TestUpdateTags(t *testing.T) {
 
 	if err := svc.Create(ctx, CreateInput{
 		Name: "Tag User", Email: "tags@example.com", ReceivingEmail: "r@ex.com",
-		Password: "p", Domain: "imap.ex.com", Port: 993,
+		Password: "p",
 	}); err != nil {
 		t.Fatalf("Create: %v", err)
 	}
@@ -333,7 +322,7 @@ func TestUpdateBlackListSenders(t *testing.T) {
 
 	if err := svc.Create(ctx, CreateInput{
 		Name: "BL User", Email: "bl@example.com", ReceivingEmail: "r@ex.com",
-		Password: "p", Domain: "imap.ex.com", Port: 993,
+		Password: "p",
 	}); err != nil {
 		t.Fatalf("Create: %v", err)
 	}
@@ -350,36 +339,13 @@ func TestUpdateBlackListSenders(t *testing.T) {
 	}
 }
 
-func TestUpdateFolder(t *testing.T) {
-	svc, _ := setupTestService(t)
-	ctx := context.Background()
-
-	if err := svc.Create(ctx, CreateInput{
-		Name: "Folder User", Email: "folder@example.com", ReceivingEmail: "r@ex.com",
-		Password: "p", Domain: "imap.ex.com", Port: 993,
-	}); err != nil {
-		t.Fatalf("Create: %v", err)
-	}
-	id, _ := svc.Authenticate(ctx, "folder@example.com", "p")
-
-	err := svc.UpdateFolder(ctx, id, "INBOX")
-	if err != nil {
-		t.Fatalf("UpdateFolder: %v", err)
-	}
-
-	u, _ := svc.GetByID(ctx, id)
-	if u.Folder != "INBOX" {
-		t.Errorf("expected 'INBOX', got %q", u.Folder)
-	}
-}
-
 func TestUpdateStartTime(t *testing.T) {
 	svc, _ := setupTestService(t)
 	ctx := context.Background()
 
 	if err := svc.Create(ctx, CreateInput{
 		Name: "ST User", Email: "st@example.com", ReceivingEmail: "r@ex.com",
-		Password: "p", Domain: "imap.ex.com", Port: 993,
+		Password: "p",
 	}); err != nil {
 		t.Fatalf("Create: %v", err)
 	}
@@ -402,7 +368,7 @@ func TestUpdateStartTimeInvalid(t *testing.T) {
 
 	if err := svc.Create(ctx, CreateInput{
 		Name: "ST2", Email: "st2@example.com", ReceivingEmail: "r@ex.com",
-		Password: "p", Domain: "imap.ex.com", Port: 993,
+		Password: "p",
 	}); err != nil {
 		t.Fatalf("Create: %v", err)
 	}
@@ -420,7 +386,7 @@ func TestUpdateSummaryCount(t *testing.T) {
 
 	if err := svc.Create(ctx, CreateInput{
 		Name: "SC User", Email: "sc@example.com", ReceivingEmail: "r@ex.com",
-		Password: "p", Domain: "imap.ex.com", Port: 993,
+		Password: "p",
 	}); err != nil {
 		t.Fatalf("Create: %v", err)
 	}
@@ -443,7 +409,7 @@ func TestUpdateInterval(t *testing.T) {
 
 	if err := svc.Create(ctx, CreateInput{
 		Name: "Int User", Email: "int@example.com", ReceivingEmail: "r@ex.com",
-		Password: "p", Domain: "imap.ex.com", Port: 993,
+		Password: "p",
 	}); err != nil {
 		t.Fatalf("Create: %v", err)
 	}
@@ -460,70 +426,425 @@ func TestUpdateInterval(t *testing.T) {
 	}
 }
 
-func TestDecryptPassword(t *testing.T) {
+func TestAuthenticateLegacyUpgrade(t *testing.T) {
+	svc, repo := setupTestService(t)
+	ctx := context.Background()
+
+	// Accounts created before login passwords existed only carry the
+	// encrypted IMAP credential.
+	imapPassword, err := svc.encryptIMAPPassword("legacy-pass")
+	if err != nil {
+		t.Fatalf("encryptIMAPPassword: %v", err)
+	}
+	if err := repo.Create(ctx, &User{
+		ID: "legacy", Email: "legacy@example.com", ReceivingEmail: "r@ex.com",
+		IMAPPassword: imapPassword,
+	}); err != nil {
+		t.Fatalf("repo.Create: %v", err)
+	}
+
+	if _, err := svc.Authenticate(ctx, "legacy@example.com", "wrong"); err != ErrInvalidPassword {
+		t.Fatalf("expected ErrInvalidPassword, got %v", err)
+	}
+	u, _ := repo.FindByID(ctx, "legacy")
+	if u.PasswordHash != "" {
+		t.Fatal("failed login must not set a password hash")
+	}
+
+	id, err := svc.Authenticate(ctx, "legacy@example.com", "legacy-pass")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if id != "legacy" {
+		t.Errorf("expected id 'legacy', got %q", id)
+	}
+
+	u, _ = repo.FindByID(ctx, "legacy")
+	if u.PasswordHash == "" {
+		t.Fatal("successful legacy login should store a password hash")
+	}
+	if u.IMAPPassword != "" {
+		t.Error("the IMAP credential should be dropped once a login password exists")
+	}
+
+	oldP, newP := "legacy-pass", "new-login-pass"
+	if err := svc.Update(ctx, id, UpdateInput{OldPassword: &oldP, NewPassword: &newP}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, "legacy@example.com", "legacy-pass"); err != ErrInvalidPassword {
+		t.Fatalf("expected ErrInvalidPassword, got %v", err)
+	}
+}
+
+func TestListAll(t *testing.T) {
 	svc, _ := setupTestService(t)
 	ctx := context.Background()
 
-	if err := svc.Create(ctx, CreateInput{
-		Name: "Dec User", Email: "dec@example.com", ReceivingEmail: "r@ex.com",
-		Password: "my-secret-pass", Domain: "imap.ex.com", Port: 993,
+	for _, email := range []string{"a@ex.com", "b@ex.com", "c@ex.com"} {
+		if err := svc.Create(ctx, CreateInput{
+			Name: "User", Email: email, ReceivingEmail: "r@ex.com",
+			Password: "p",
+		}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	users, err := svc.ListAll(ctx)
+	if err != nil {
+		t.Fatalf("ListAll: %v", err)
+	}
+	if len(users) != 3 {
+		t.Errorf("expected 3 users, got %d", len(users))
+	}
+}
+
+func TestRotateSecrets(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	repo := NewMemoryRepository()
+
+	oldKey := encryption.Key{ID: "old", Secret: []byte("0123456789abcdef")}
+	newKey := encryption.Key{ID: "new", Secret: []byte("fedcba9876543210fedcba9876543210")}
+
+	oldEnc, _ := encryption.NewKeyring(oldKey, nil, "")
+	oldSvc := NewService(repo, oldEnc, audit.NewService(audit.NewMemoryRepository(), logger), logger)
+	// Only legacy accounts without a login password keep an IMAP credential.
+	for _, email := range []string{"a@ex.com", "b@ex.com"} {
+		imapPassword, err := oldSvc.encryptIMAPPassword("imap-" + email)
+		if err != nil {
+			t.Fatalf("encryptIMAPPassword: %v", err)
+		}
+		if err := repo.Create(ctx, &User{ID: email, Email: email, IMAPPassword: imapPassword}); err != nil {
+			t.Fatalf("repo.Create: %v", err)
+		}
+	}
+	if err := oldSvc.Create(ctx, CreateInput{
+		Name: "User", Email: "c@ex.com", ReceivingEmail: "r@ex.com", Password: "login-pass",
 	}); err != nil {
 		t.Fatalf("Create: %v", err)
 	}
-	id, _ := svc.Authenticate(ctx, "dec@example.com", "my-secret-pass")
 
+	enc, _ := encryption.NewKeyring(newKey, []encryption.Key{oldKey}, "")
+	svc := NewService(repo, enc, audit.NewService(audit.NewMemoryRepository(), logger), logger)
+
+	n, err := svc.RotateSecrets(ctx)
+	if err != nil {
+		t.Fatalf("RotateSecrets: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 users rotated, got %d", n)
+	}
+	if n, _ := svc.RotateSecrets(ctx); n != 0 {
+		t.Errorf("second rotation should be a no-op, rotated %d", n)
+	}
+
+	// Secrets are now readable without the old key.
+	newEnc, _ := encryption.NewKeyring(newKey, nil, "")
+	newSvc := NewService(repo, newEnc, audit.NewService(audit.NewMemoryRepository(), logger), logger)
+	users, _ := repo.ListAll(ctx)
+	for _, u := range users {
+		if u.IMAPPassword == "" {
+			continue
+		}
+		pass, err := newSvc.DecryptIMAPPassword(ctx, u)
+		if err != nil || pass != "imap-"+u.Email {
+			t.Errorf("user %s: got %q, %v", u.Email, pass, err)
+		}
+	}
+}
+
+func TestLoginOIDCProvisionsUser(t *testing.T) {
+	svc, _ := setupTestService(t)
+	ctx := context.Background()
+	ident := OIDCIdentity{Subject: "sub-1", Email: "sso@example.com", EmailVerified: true, Name: "SSO User"}
+
+	if _, err := svc.LoginOIDC(ctx, ident, false); err != ErrNoAccount {
+		t.Fatalf("expected ErrNoAccount without provisioning, got %v", err)
+	}
+
+	id, err := svc.LoginOIDC(ctx, ident, true)
+	if err != nil {
+		t.Fatalf("LoginOIDC: %v", err)
+	}
 	u, _ := svc.GetByID(ctx, id)
-	pass, err := svc.DecryptPassword(u)
+	if u.Name != "SSO User" || u.ReceivingEmail != "sso@example.com" || u.PasswordHash != "" {
+		t.Errorf("unexpected provisioned user: %+v", u)
+	}
+
+	// The subject identifies the user even if the email changes.
+	ident.Email = "renamed@example.com"
+	again, err := svc.LoginOIDC(ctx, ident, false)
+	if err != nil || again != id {
+		t.Errorf("expected the same user, got %q, %v", again, err)
+	}
+
+	// There is no password to log in with.
+	if _, err := svc.Authenticate(ctx, "sso@example.com", ""); err != ErrInvalidPassword {
+		t.Errorf("expected ErrInvalidPassword for SSO user, got %v", err)
+	}
+}
+
+func TestLoginOIDCLinksExistingUser(t *testing.T) {
+	svc, _ := setupTestService(t)
+	ctx := context.Background()
+
+	if err := svc.Create(ctx, CreateInput{
+		Name: "Existing", Email: "link@example.com", ReceivingEmail: "link@example.com",
+		Password: "mypassword",
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	existing, _ := svc.Authenticate(ctx, "link@example.com", "mypassword")
+	if err := svc.ConfirmReceivingEmail(ctx, existing, "link@example.com"); err != nil {
+		t.Fatalf("ConfirmReceivingEmail: %v", err)
+	}
+
+	// An unverified email must not take over the account.
+	unverified := OIDCIdentity{Subject: "sub-2", Email: "link@example.com"}
+	if _, err := svc.LoginOIDC(ctx, unverified, true); err != ErrEmailUnverified {
+		t.Fatalf("expected ErrEmailUnverified, got %v", err)
+	}
+
+	id, err := svc.LoginOIDC(ctx, OIDCIdentity{Subject: "sub-2", Email: "link@example.com", EmailVerified: true}, true)
 	if err != nil {
-		t.Fatalf("DecryptPassword: %v", err)
+		t.Fatalf("LoginOIDC: %v", err)
+	}
+	if id != existing {
+		t.Errorf("expected existing user %q to be linked, got %q", existing, id)
 	}
-	if pass != "my-secret-pass" {
-		t.Errorf("expected 'my-secret-pass', got %q", pass)
+
+	// A second identity with the same email cannot claim the account.
+	if _, err := svc.LoginOIDC(ctx, OIDCIdentity{Subject: "sub-3", Email: "link@example.com", EmailVerified: true}, true); err != ErrAlreadyExists {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
 	}
 }
 
-func TestSaveLastUID(t *testing.T) {
+func TestLoginOIDCRefusesUnprovenAccount(t *testing.T) {
 	svc, _ := setupTestService(t)
 	ctx := context.Background()
 
+	// Someone registers the victim's address before the victim ever
+	// signs in, with a receiving email of their own.
 	if err := svc.Create(ctx, CreateInput{
-		Name: "UID User", Email: "uid@example.com", ReceivingEmail: "r@ex.com",
-		Password: "p", Domain: "imap.ex.com", Port: 993,
+		Name: "Squatter", Email: "victim@example.com", ReceivingEmail: "attacker@example.com",
+		Password: "mypassword",
 	}); err != nil {
 		t.Fatalf("Create: %v", err)
 	}
-	id, _ := svc.Authenticate(ctx, "uid@example.com", "p")
+	squatter, _ := svc.Authenticate(ctx, "victim@example.com", "mypassword")
+	if err := svc.ConfirmReceivingEmail(ctx, squatter, "attacker@example.com"); err != nil {
+		t.Fatalf("ConfirmReceivingEmail: %v", err)
+	}
 
-	u, _ := svc.GetByID(ctx, id)
-	err := svc.SaveLastUID(ctx, u, `{"report":500}`)
+	ident := OIDCIdentity{Subject: "victim-sub", Email: "victim@example.com", EmailVerified: true}
+	if _, err := svc.LoginOIDC(ctx, ident, true); err != ErrLinkUnproven {
+		t.Fatalf("expected ErrLinkUnproven, got %v", err)
+	}
+
+	// Pointing the receiving email at the address is not enough until
+	// its owner follows the verification link.
+	victim := "victim@example.com"
+	if err := svc.Update(ctx, squatter, UpdateInput{ReceivingEmail: &victim}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if _, err := svc.LoginOIDC(ctx, ident, true); err != ErrLinkUnproven {
+		t.Fatalf("expected ErrLinkUnproven, got %v", err)
+	}
+	u, _ := svc.GetByID(ctx, squatter)
+	if u.OIDCSubject != "" {
+		t.Errorf("expected the identity to stay unlinked, got %q", u.OIDCSubject)
+	}
+}
+
+func TestDisabledUserCannotSignIn(t *testing.T) {
+	svc, repo := setupTestService(t)
+	ctx := context.Background()
+
+	if err := svc.Create(ctx, CreateInput{
+		Name: "User", Email: "u@ex.com", ReceivingEmail: "r@ex.com",
+		Password: "secret123",
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	id, err := svc.Authenticate(ctx, "u@ex.com", "secret123")
 	if err != nil {
-		t.Fatalf("SaveLastUID: %v", err)
+		t.Fatalf("Authenticate: %v", err)
 	}
 
-	u2, _ := svc.GetByID(ctx, id)
-	if u2.LastUID != `{"report":500}` {
-		t.Errorf("expected lastUID, got %q", u2.LastUID)
+	if err := svc.SetDisabled(ctx, id, true); err != nil {
+		t.Fatalf("SetDisabled: %v", err)
+	}
+	u, _ := repo.FindByID(ctx, id)
+	if !u.Disabled || u.TokenGeneration != 1 {
+		t.Errorf("expected disabled account with bumped token generation, got %+v", u)
+	}
+	if _, err := svc.Authenticate(ctx, "u@ex.com", "secret123"); err != ErrDisabled {
+		t.Errorf("expected ErrDisabled, got %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, "u@ex.com", "wrong"); err != ErrInvalidPassword {
+		t.Errorf("wrong password on a disabled account should still be ErrInvalidPassword, got %v", err)
+	}
+
+	if err := svc.SetDisabled(ctx, id, false); err != nil {
+		t.Fatalf("SetDisabled: %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, "u@ex.com", "secret123"); err != nil {
+		t.Errorf("expected re-enabled account to sign in, got %v", err)
 	}
 }
 
-func TestListAll(t *testing.T) {
+func TestSetRoleByEmail(t *testing.T) {
 	svc, _ := setupTestService(t)
 	ctx := context.Background()
 
-	for i, email := range []string{"a@ex.com", "b@ex.com", "c@ex.com"} {
+	if err := svc.Create(ctx, CreateInput{
+		Name: "User", Email: "u@ex.com", ReceivingEmail: "r@ex.com",
+		Password: "secret123",
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	u, err := svc.SetRoleByEmail(ctx, "u@ex.com", RoleAdmin)
+	if err != nil {
+		t.Fatalf("SetRoleByEmail: %v", err)
+	}
+	if !u.IsAdmin() {
+		t.Errorf("expected admin, got role %q", u.Role)
+	}
+	if _, err := svc.SetRoleByEmail(ctx, "u@ex.com", "root"); err != ErrInvalidRole {
+		t.Errorf("expected ErrInvalidRole, got %v", err)
+	}
+	if _, err := svc.SetRoleByEmail(ctx, "none@ex.com", RoleAdmin); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	svc, _ := setupTestService(t)
+	ctx := context.Background()
+
+	for _, email := range []string{"alice@ex.com", "bob@ex.com", "alicia@other.com"} {
 		if err := svc.Create(ctx, CreateInput{
 			Name: "User", Email: email, ReceivingEmail: "r@ex.com",
-			Password: "p", Domain: "imap.ex.com", Port: 993 + i,
+			Password: "p",
 		}); err != nil {
 			t.Fatalf("Create: %v", err)
 		}
 	}
 
-	users, err := svc.ListAll(ctx)
+	users, total, err := svc.Search(ctx, "ALI", 1, 0)
 	if err != nil {
-		t.Fatalf("ListAll: %v", err)
+		t.Fatalf("Search: %v", err)
 	}
-	if len(users) != 3 {
-		t.Errorf("expected 3 users, got %d", len(users))
+	if total != 2 || len(users) != 1 {
+		t.Errorf("expected 1 of 2 matches, got %d of %d", len(users), total)
+	}
+
+	_, total, _ = svc.Search(ctx, "", 10, 0)
+	if total != 3 {
+		t.Errorf("expected empty query to match all 3 users, got %d", total)
+	}
+}
+
+func TestChangesAreAudited(t *testing.T) {
+	repo := NewMemoryRepository()
+	enc, err := encryption.New([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("encryption.New: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	events := audit.NewMemoryRepository()
+	svc := NewService(repo, enc, audit.NewService(events, logger), logger)
+
+	ctx := audit.WithSource(context.Background(), audit.Source{IP: "10.0.0.1", RequestID: "req-1"})
+	if err := svc.Create(ctx, CreateInput{
+		Name: "Audit", Email: "audit@ex.com", ReceivingEmail: "r@ex.com",
+		Password: "login-pass",
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	u, _ := repo.FindByEmail(ctx, "audit@ex.com")
+
+	ctx = audit.WithActor(ctx, u.ID)
+	receiving := "other@ex.com"
+	oldPass, newPass := "login-pass", "new-login-pass"
+	if err := svc.Update(ctx, u.ID, UpdateInput{
+		ReceivingEmail: &receiving, OldPassword: &oldPass, NewPassword: &newPass,
+	}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := svc.UpdateTags(ctx, u.ID, []string{"invoice"}); err != nil {
+		t.Fatalf("UpdateTags: %v", err)
+	}
+	// An update that changes nothing is not recorded.
+	if err := svc.UpdateTags(ctx, u.ID, []string{"invoice"}); err != nil {
+		t.Fatalf("UpdateTags: %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, "audit@ex.com", "wrong"); err == nil {
+		t.Fatal("expected wrong password to fail")
+	}
+
+	got := events.Events()
+	wantActions := []string{audit.ActionUserCreated, audit.ActionPasswordChanged, audit.ActionUserUpdated, audit.ActionLoginFailed}
+	if len(got) != len(wantActions) {
+		t.Fatalf("expected %d events, got %+v", len(wantActions), got)
+	}
+	for i, want := range wantActions {
+		if got[i].Action != want || got[i].UserID != u.ID || got[i].IP != "10.0.0.1" || got[i].RequestID != "req-1" {
+			t.Errorf("event %d: unexpected %+v", i, got[i])
+		}
+	}
+	if got[0].ActorID != "" || got[1].ActorID != u.ID {
+		t.Errorf("unexpected actors %q and %q", got[0].ActorID, got[1].ActorID)
+	}
+
+	changes := got[1].Changes
+	for _, field := range []string{"receivingEmail", "password"} {
+		if !changes.Has(field) {
+			t.Errorf("expected %s in diff %+v", field, changes)
+		}
+	}
+	for _, c := range append(got[0].Changes, changes...) {
+		if c.Field == "password" || c.Field == "imapPassword" {
+			if (c.Before != nil && c.Before != audit.Redacted) || c.After != audit.Redacted {
+				t.Errorf("expected %s to be redacted, got %+v", c.Field, c)
+			}
+		}
+	}
+	if changes.Has("name") {
+		t.Errorf("expected unchanged fields to be left out, got %+v", changes)
+	}
+}
+
+func TestDecryptIMAPPasswordUpgradesEncryption(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	repo := NewMemoryRepository()
+	auditSvc := audit.NewService(audit.NewMemoryRepository(), logger)
+
+	oldKey := encryption.Key{ID: "old", Secret: []byte("0123456789abcdef")}
+	newKey := encryption.Key{ID: "new", Secret: []byte("fedcba9876543210fedcba9876543210")}
+
+	oldEnc, _ := encryption.NewKeyring(oldKey, nil, "")
+	imapPassword, err := NewService(repo, oldEnc, auditSvc, logger).encryptIMAPPassword("imap-secret")
+	if err != nil {
+		t.Fatalf("encryptIMAPPassword: %v", err)
+	}
+	if err := repo.Create(ctx, &User{ID: "u1", Email: "a@ex.com", IMAPPassword: imapPassword}); err != nil {
+		t.Fatalf("repo.Create: %v", err)
+	}
+
+	enc, _ := encryption.NewKeyring(newKey, []encryption.Key{oldKey}, "")
+	svc := NewService(repo, enc, auditSvc, logger)
+	u, _ := repo.FindByID(ctx, "u1")
+	if pass, err := svc.DecryptIMAPPassword(ctx, u); err != nil || pass != "imap-secret" {
+		t.Fatalf("DecryptIMAPPassword = %q, %v", pass, err)
+	}
+
+	// One read is enough for the value to no longer need the old key.
+	newEnc, _ := encryption.NewKeyring(newKey, nil, "")
+	stored, _ := repo.FindByID(ctx, "u1")
+	if pass, err := NewService(repo, newEnc, auditSvc, logger).DecryptIMAPPassword(ctx, stored); err != nil || pass != "imap-secret" {
+		t.Errorf("expected the IMAP password to be re-encrypted, got %q, %v", pass, err)
 	}
 }
@@ -0,0 +1,54 @@
+package verification
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryRepository is an in-memory verification repository for testing.
+type MemoryRepository struct {
+	mu            sync.Mutex
+	verifications map[string]*Verification
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{verifications: make(map[string]*Verification)}
+}
+
+func (r *MemoryRepository) Save(_ context.Context, v *Verification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := *v
+	r.verifications[v.UserID] = &cp
+	return nil
+}
+
+func (r *MemoryRepository) FindByUser(_ context.Context, userID string) (*Verification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.verifications[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *v
+	return &cp, nil
+}
+
+func (r *MemoryRepository) FindByTokenHash(_ context.Context, hash string) (*Verification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, v := range r.verifications {
+		if v.TokenHash == hash {
+			cp := *v
+			return &cp, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryRepository) Delete(_ context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.verifications, userID)
+	return nil
+}
@@ -0,0 +1,26 @@
+package verification
+
+import (
+	"errors"
+	"time"
+)
+
+// Domain errors.
+var (
+	ErrNotFound     = errors.New("verification not found")
+	ErrInvalidToken = errors.New("invalid or expired verification token")
+	ErrTooSoon      = errors.New("verification email sent too recently")
+	ErrVerified     = errors.New("receiving email is already verified")
+)
+
+// Verification is an outstanding request to confirm a user's receiving
+// email. A user has at most one; sending a new link replaces the old one.
+type Verification struct {
+	UserID    string `gorm:"primaryKey"`
+	Email     string // the address the link was sent to
+	TokenHash string `gorm:"uniqueIndex"` // SHA-256 of the emailed token
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+func (Verification) TableName() string { return "email_verifications" }
@@ -0,0 +1,12 @@
+package verification
+
+import "context"
+
+// Repository defines persistence operations for email verifications.
+type Repository interface {
+	// Save stores v, replacing any verification the user already has.
+	Save(ctx context.Context, v *Verification) error
+	FindByUser(ctx context.Context, userID string) (*Verification, error)
+	FindByTokenHash(ctx context.Context, hash string) (*Verification, error)
+	Delete(ctx context.Context, userID string) error
+}
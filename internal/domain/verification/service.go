@@ -0,0 +1,140 @@
+package verification
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+)
+
+const (
+	// tokenTTL is how long a verification link stays valid.
+	tokenTTL = 48 * time.Hour
+	// resendInterval limits how often a link can be sent, so the endpoint
+	// cannot be used to flood someone's inbox.
+	resendInterval = time.Minute
+	tokenBytes     = 32
+)
+
+// Mailer delivers verification links.
+type Mailer interface {
+	SendEmailVerification(to, name, link string, expires time.Time) error
+}
+
+// Service confirms that users control the address their digests are sent
+// to (double opt-in).
+type Service struct {
+	repo      Repository
+	userSvc   *user.Service
+	mailer    Mailer
+	verifyURL string
+	logger    *slog.Logger
+	now       func() time.Time
+}
+
+// NewService creates a verification service. verifyURL is the address of
+// the verification endpoint; the token is appended as the "token" query
+// parameter.
+func NewService(repo Repository, userSvc *user.Service, mailer Mailer, verifyURL string, logger *slog.Logger) *Service {
+	return &Service{
+		repo:      repo,
+		userSvc:   userSvc,
+		mailer:    mailer,
+		verifyURL: verifyURL,
+		logger:    logger,
+		now:       time.Now,
+	}
+}
+
+// Send emails a verification link to the user's receiving address,
+// replacing any earlier link. It returns ErrVerified if the address is
+// already verified and ErrTooSoon if a link went out less than a minute
+// ago.
+func (s *Service) Send(ctx context.Context, userID string) error {
+	u, err := s.userSvc.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if u.ReceivingEmailVerified {
+		return ErrVerified
+	}
+
+	now := s.now()
+	prev, err := s.repo.FindByUser(ctx, userID)
+	switch {
+	case err == nil:
+		if prev.Email == u.ReceivingEmail && now.Sub(prev.CreatedAt) < resendInterval {
+			return ErrTooSoon
+		}
+	case !errors.Is(err, ErrNotFound):
+		return err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+	v := &Verification{
+		UserID:    userID,
+		Email:     u.ReceivingEmail,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(tokenTTL),
+		CreatedAt: now,
+	}
+	if err := s.repo.Save(ctx, v); err != nil {
+		return err
+	}
+
+	link := s.verifyURL + "?" + url.Values{"token": {token}}.Encode()
+	if err := s.mailer.SendEmailVerification(u.ReceivingEmail, u.Name, link, v.ExpiresAt); err != nil {
+		return fmt.Errorf("sending verification email: %w", err)
+	}
+	s.logger.Info("verification email sent", "user_id", userID)
+	return nil
+}
+
+// Verify confirms the receiving email the token was sent to. Tokens work
+// once, expire after two days and stop working when the user changes
+// their receiving email.
+func (s *Service) Verify(ctx context.Context, token string) error {
+	v, err := s.repo.FindByTokenHash(ctx, hashToken(token))
+	if errors.Is(err, ErrNotFound) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+	if !s.now().Before(v.ExpiresAt) {
+		return ErrInvalidToken
+	}
+
+	err = s.userSvc.ConfirmReceivingEmail(ctx, v.UserID, v.Email)
+	if errors.Is(err, user.ErrEmailChanged) || errors.Is(err, user.ErrNotFound) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, v.UserID)
+}
+
+func newToken() (string, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating verification token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
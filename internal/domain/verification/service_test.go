@@ -0,0 +1,161 @@
+package verification
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/encryption"
+)
+
+type sentLink struct {
+	to, link string
+}
+
+type fakeMailer struct {
+	sent []sentLink
+}
+
+func (m *fakeMailer) SendEmailVerification(to, _, link string, _ time.Time) error {
+	m.sent = append(m.sent, sentLink{to: to, link: link})
+	return nil
+}
+
+// token extracts the token from the most recent link.
+func (m *fakeMailer) token(t *testing.T) string {
+	t.Helper()
+	if len(m.sent) == 0 {
+		t.Fatal("no verification email sent")
+	}
+	u, err := url.Parse(m.sent[len(m.sent)-1].link)
+	if err != nil {
+		t.Fatalf("bad link: %v", err)
+	}
+	return u.Query().Get("token")
+}
+
+func setupTestService(t *testing.T) (*Service, *user.Service, *fakeMailer, string) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	enc, err := encryption.New([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("encryption.New: %v", err)
+	}
+	userSvc := user.NewService(user.NewMemoryRepository(), enc, audit.NewService(audit.NewMemoryRepository(), logger), logger)
+	ctx := context.Background()
+	if err := userSvc.Create(ctx, user.CreateInput{
+		Name: "Test", Email: "u@ex.com", ReceivingEmail: "r@ex.com",
+		Password: "secret123",
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	u, err := userSvc.GetByEmail(ctx, "u@ex.com")
+	if err != nil {
+		t.Fatalf("GetByEmail: %v", err)
+	}
+
+	mailer := &fakeMailer{}
+	svc := NewService(NewMemoryRepository(), userSvc, mailer, "https://maildruid.test/api/v1/verify-email", logger)
+	return svc, userSvc, mailer, u.ID
+}
+
+func TestSendAndVerify(t *testing.T) {
+	svc, userSvc, mailer, id := setupTestService(t)
+	ctx := context.Background()
+
+	if err := svc.Send(ctx, id); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if mailer.sent[0].to != "r@ex.com" {
+		t.Errorf("expected link sent to the receiving email, got %s", mailer.sent[0].to)
+	}
+	token := mailer.token(t)
+
+	if err := svc.Verify(ctx, "bogus"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+	if err := svc.Verify(ctx, token); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	u, _ := userSvc.GetByID(ctx, id)
+	if !u.ReceivingEmailVerified {
+		t.Error("expected receiving email to be verified")
+	}
+	if err := svc.Verify(ctx, token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected token to be single-use, got %v", err)
+	}
+	if err := svc.Send(ctx, id); !errors.Is(err, ErrVerified) {
+		t.Errorf("expected ErrVerified, got %v", err)
+	}
+}
+
+func TestResendLimitAndExpiry(t *testing.T) {
+	svc, _, mailer, id := setupTestService(t)
+	ctx := context.Background()
+	now := time.Now()
+	svc.now = func() time.Time { return now }
+
+	if err := svc.Send(ctx, id); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	first := mailer.token(t)
+	if err := svc.Send(ctx, id); !errors.Is(err, ErrTooSoon) {
+		t.Fatalf("expected ErrTooSoon, got %v", err)
+	}
+
+	now = now.Add(resendInterval)
+	if err := svc.Send(ctx, id); err != nil {
+		t.Fatalf("Send after interval: %v", err)
+	}
+	if err := svc.Verify(ctx, first); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected a new link to replace the old one, got %v", err)
+	}
+
+	now = now.Add(tokenTTL)
+	if err := svc.Verify(ctx, mailer.token(t)); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected expired token to be rejected, got %v", err)
+	}
+}
+
+func TestChangingReceivingEmailRequiresVerification(t *testing.T) {
+	svc, userSvc, mailer, id := setupTestService(t)
+	ctx := context.Background()
+
+	if err := svc.Send(ctx, id); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	oldToken := mailer.token(t)
+
+	addr := "new@ex.com"
+	if err := userSvc.Update(ctx, id, user.UpdateInput{ReceivingEmail: &addr}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	// A link sent to the old address must not verify the new one.
+	if err := svc.Verify(ctx, oldToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken for a stale link, got %v", err)
+	}
+
+	if err := svc.Send(ctx, id); err != nil {
+		t.Fatalf("Send to new address: %v", err)
+	}
+	if mailer.sent[len(mailer.sent)-1].to != addr {
+		t.Errorf("expected link sent to %s, got %s", addr, mailer.sent[len(mailer.sent)-1].to)
+	}
+	if err := svc.Verify(ctx, mailer.token(t)); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	// Setting the same address again keeps it verified.
+	if err := userSvc.Update(ctx, id, user.UpdateInput{ReceivingEmail: &addr}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if u, _ := userSvc.GetByID(ctx, id); !u.ReceivingEmailVerified {
+		t.Error("expected unchanged address to stay verified")
+	}
+}
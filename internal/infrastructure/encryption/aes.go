@@ -1,54 +1,193 @@
 package encryption
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
 )
 
-// Service provides AES-256-CFB encryption and decryption.
+// DefaultKeyID identifies the key passed to New.
+const DefaultKeyID = "default"
+
+// Ciphertexts produced by Encrypt are envelopes of the form
+//
+//	magic | version | len(keyID) | keyID | nonce | AES-GCM sealed data
+//
+// The header is authenticated as additional data, so a ciphertext cannot be
+// moved to a different key ID without failing to decrypt. Values without the
+// magic prefix are treated as legacy AES-CFB ciphertexts (random IV followed
+// by the encrypted bytes).
+var envelopeMagic = []byte("MDE")
+
+const envelopeV1 byte = 1
+
+// Errors returned by Decrypt.
+var (
+	ErrUnknownKey = errors.New("ciphertext encrypted with unknown key")
+	ErrDecrypt    = errors.New("ciphertext failed authentication")
+)
+
+// Key is a named encryption key. Secret must be 16, 24, or 32 bytes for
+// AES-128, AES-192, or AES-256.
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// Service provides authenticated AES-GCM encryption over a keyring. New
+// values are always encrypted with the primary key; any configured key can
+// decrypt.
 type Service struct {
-	key []byte
+	primary string
+	legacy  string
+	keys    map[string][]byte
 }
 
-// New creates an encryption service with the given key.
-// Key must be 16, 24, or 32 bytes for AES-128, AES-192, or AES-256.
+// New creates an encryption service with a single key identified by
+// DefaultKeyID. Legacy ciphertexts are decrypted with the same key.
 func New(key []byte) (*Service, error) {
-	keyLen := len(key)
-	if keyLen != 16 && keyLen != 24 && keyLen != 32 {
-		return nil, fmt.Errorf("invalid key size %d: must be 16, 24, or 32 bytes", keyLen)
+	return NewKeyring(Key{ID: DefaultKeyID, Secret: key}, nil, "")
+}
+
+// NewKeyring creates an encryption service that encrypts with primary and
+// decrypts with primary or any of previous. Legacy CFB ciphertexts, which
+// carry no key ID, are decrypted with the key named legacyKeyID, or with the
+// primary key when legacyKeyID is empty.
+func NewKeyring(primary Key, previous []Key, legacyKeyID string) (*Service, error) {
+	s := &Service{
+		primary: primary.ID,
+		legacy:  legacyKeyID,
+		keys:    make(map[string][]byte, len(previous)+1),
+	}
+	if s.legacy == "" {
+		s.legacy = primary.ID
+	}
+
+	for _, k := range append([]Key{primary}, previous...) {
+		if k.ID == "" || len(k.ID) > 255 {
+			return nil, fmt.Errorf("invalid key ID %q: must be 1-255 bytes", k.ID)
+		}
+		if _, dup := s.keys[k.ID]; dup {
+			return nil, fmt.Errorf("duplicate key ID %q", k.ID)
+		}
+		keyLen := len(k.Secret)
+		if keyLen != 16 && keyLen != 24 && keyLen != 32 {
+			return nil, fmt.Errorf("key %q: invalid key size %d: must be 16, 24, or 32 bytes", k.ID, keyLen)
+		}
+		keyCopy := make([]byte, keyLen)
+		copy(keyCopy, k.Secret)
+		s.keys[k.ID] = keyCopy
 	}
-	keyCopy := make([]byte, keyLen)
-	copy(keyCopy, key)
-	return &Service{key: keyCopy}, nil
+
+	if _, ok := s.keys[s.legacy]; !ok {
+		return nil, fmt.Errorf("legacy key %q is not configured", s.legacy)
+	}
+	return s, nil
 }
 
-// Encrypt encrypts plaintext using AES-CFB with a random IV.
+// PrimaryKeyID returns the ID of the key used for new ciphertexts.
+func (s *Service) PrimaryKeyID() string {
+	return s.primary
+}
+
+// Encrypt encrypts plaintext with the primary key using AES-GCM and a random
+// nonce, returning a versioned envelope.
 func (s *Service) Encrypt(plaintext string) ([]byte, error) {
-	block, err := aes.NewCipher(s.key)
+	aead, err := newGCM(s.keys[s.primary])
 	if err != nil {
-		return nil, fmt.Errorf("creating cipher: %w", err)
+		return nil, err
 	}
 
-	plain := []byte(plaintext)
-	ciphertext := make([]byte, aes.BlockSize+len(plain))
-	iv := ciphertext[:aes.BlockSize]
+	header := envelopeHeader(s.primary)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
 
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		return nil, fmt.Errorf("generating IV: %w", err)
+	out := make([]byte, 0, len(header)+len(nonce)+len(plaintext)+aead.Overhead())
+	out = append(out, header...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, []byte(plaintext), header), nil
+}
+
+// Decrypt decrypts a ciphertext produced by Encrypt or by the legacy CFB
+// implementation.
+func (s *Service) Decrypt(ciphertext []byte) (string, error) {
+	keyID, rest, ok := parseEnvelope(ciphertext)
+	if !ok {
+		return s.decryptLegacy(ciphertext)
 	}
 
-	stream := cipher.NewCFBEncrypter(block, iv)
-	stream.XORKeyStream(ciphertext[aes.BlockSize:], plain)
+	key, known := s.keys[keyID]
+	if !known {
+		return "", fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(rest) < aead.NonceSize()+aead.Overhead() {
+		return "", fmt.Errorf("ciphertext too short: %d bytes", len(ciphertext))
+	}
 
-	return ciphertext, nil
+	header := ciphertext[:len(ciphertext)-len(rest)]
+	nonce, sealed := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, header)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+	return string(plain), nil
 }
 
-// Decrypt decrypts ciphertext produced by Encrypt.
-func (s *Service) Decrypt(ciphertext []byte) (string, error) {
-	block, err := aes.NewCipher(s.key)
+// NeedsRotation reports whether ciphertext should be re-encrypted: either it
+// is a legacy CFB value or it was sealed with a key other than the primary.
+func (s *Service) NeedsRotation(ciphertext []byte) bool {
+	keyID, _, ok := parseEnvelope(ciphertext)
+	return !ok || keyID != s.primary
+}
+
+// Rotate decrypts ciphertext and, if it needs rotation, re-encrypts it with
+// the primary key. The returned bool reports whether a new ciphertext was
+// produced.
+func (s *Service) Rotate(ciphertext []byte) ([]byte, bool, error) {
+	if !s.NeedsRotation(ciphertext) {
+		return ciphertext, false, nil
+	}
+	plain, err := s.Decrypt(ciphertext)
+	if err != nil {
+		return nil, false, err
+	}
+	out, err := s.Encrypt(plain)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// DecryptRotate decrypts ciphertext like Decrypt and, when it needs
+// rotation, also returns it re-encrypted with the primary key so the caller
+// can store the upgraded value. rotated is nil for current ciphertexts.
+func (s *Service) DecryptRotate(ciphertext []byte) (plaintext string, rotated []byte, err error) {
+	plaintext, err = s.Decrypt(ciphertext)
+	if err != nil || !s.NeedsRotation(ciphertext) {
+		return plaintext, nil, err
+	}
+	rotated, err = s.Encrypt(plaintext)
+	if err != nil {
+		return "", nil, err
+	}
+	return plaintext, rotated, nil
+}
+
+// decryptLegacy decrypts a value written before envelopes existed: an
+// AES-CFB ciphertext prefixed with its IV. CFB is unauthenticated, so a wrong
+// key or tampered value yields garbage rather than an error.
+func (s *Service) decryptLegacy(ciphertext []byte) (string, error) {
+	block, err := aes.NewCipher(s.keys[s.legacy])
 	if err != nil {
 		return "", fmt.Errorf("creating cipher: %w", err)
 	}
@@ -58,11 +197,44 @@ func (s *Service) Decrypt(ciphertext []byte) (string, error) {
 	}
 
 	iv := ciphertext[:aes.BlockSize]
-	encrypted := make([]byte, len(ciphertext)-aes.BlockSize)
-	copy(encrypted, ciphertext[aes.BlockSize:])
+	decrypted := make([]byte, len(ciphertext)-aes.BlockSize)
+	copy(decrypted, ciphertext[aes.BlockSize:])
 
 	stream := cipher.NewCFBDecrypter(block, iv)
-	stream.XORKeyStream(encrypted, encrypted)
+	stream.XORKeyStream(decrypted, decrypted)
+
+	return string(decrypted), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("creating GCM: %w", err)
+	}
+	return aead, nil
+}
 
-	return string(encrypted), nil
+func envelopeHeader(keyID string) []byte {
+	header := make([]byte, 0, len(envelopeMagic)+2+len(keyID))
+	header = append(header, envelopeMagic...)
+	header = append(header, envelopeV1, byte(len(keyID)))
+	return append(header, keyID...)
+}
+
+// parseEnvelope splits an envelope into its key ID and the nonce-prefixed
+// sealed data. ok is false for values that are not v1 envelopes.
+func parseEnvelope(ciphertext []byte) (keyID string, rest []byte, ok bool) {
+	n := len(envelopeMagic)
+	if len(ciphertext) < n+2 || !bytes.Equal(ciphertext[:n], envelopeMagic) || ciphertext[n] != envelopeV1 {
+		return "", nil, false
+	}
+	idLen := int(ciphertext[n+1])
+	if idLen == 0 || len(ciphertext) < n+2+idLen {
+		return "", nil, false
+	}
+	return string(ciphertext[n+2 : n+2+idLen]), ciphertext[n+2+idLen:], true
 }
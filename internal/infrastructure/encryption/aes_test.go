@@ -1,6 +1,10 @@
 package encryption
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
 	"testing"
 )
 
@@ -47,7 +51,7 @@ func TestEncryptProducesDifferentCiphertexts(t *testing.T) {
 	b, _ := svc.Encrypt("same text")
 
 	if string(a) == string(b) {
-		t.Error("two encryptions of same plaintext should produce different ciphertexts (random IV)")
+		t.Error("two encryptions of same plaintext should produce different ciphertexts (random nonce)")
 	}
 }
 
@@ -67,3 +71,133 @@ func TestDecryptRejectsShortCiphertext(t *testing.T) {
 		t.Error("expected error for short ciphertext")
 	}
 }
+
+// legacyEncrypt reproduces the pre-envelope AES-CFB format.
+func legacyEncrypt(t *testing.T, key []byte, plaintext string) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("NewCipher: %v", err)
+	}
+	out := make([]byte, aes.BlockSize+len(plaintext))
+	if _, err := rand.Read(out[:aes.BlockSize]); err != nil {
+		t.Fatalf("rand: %v", err)
+	}
+	cipher.NewCFBEncrypter(block, out[:aes.BlockSize]).XORKeyStream(out[aes.BlockSize:], []byte(plaintext))
+	return out
+}
+
+func TestDecryptRejectsTamperedCiphertext(t *testing.T) {
+	svc, _ := New([]byte("0123456789abcdef0123456789abcdef"))
+
+	encrypted, err := svc.Encrypt("secret")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	encrypted[len(encrypted)-1] ^= 0x01
+
+	if _, err := svc.Decrypt(encrypted); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("expected ErrDecrypt, got %v", err)
+	}
+}
+
+func TestDecryptLegacyCFB(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	svc, _ := New(key)
+
+	legacy := legacyEncrypt(t, key, "old secret")
+	got, err := svc.Decrypt(legacy)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if got != "old secret" {
+		t.Errorf("got %q, want %q", got, "old secret")
+	}
+	if !svc.NeedsRotation(legacy) {
+		t.Error("legacy ciphertext should need rotation")
+	}
+
+	rotated, changed, err := svc.Rotate(legacy)
+	if err != nil || !changed {
+		t.Fatalf("Rotate: changed=%v err=%v", changed, err)
+	}
+	if svc.NeedsRotation(rotated) {
+		t.Error("rotated ciphertext should not need rotation")
+	}
+	if got, _ := svc.Decrypt(rotated); got != "old secret" {
+		t.Errorf("rotated value decrypts to %q", got)
+	}
+
+	got, upgraded, err := svc.DecryptRotate(legacy)
+	if err != nil || got != "old secret" || upgraded == nil || svc.NeedsRotation(upgraded) {
+		t.Errorf("DecryptRotate = %q, %x, %v", got, upgraded, err)
+	}
+	if _, upgraded, _ := svc.DecryptRotate(rotated); upgraded != nil {
+		t.Error("current ciphertext should not be re-encrypted")
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	oldKey := Key{ID: "2025", Secret: []byte("0123456789abcdef")}
+	newKey := Key{ID: "2026", Secret: []byte("fedcba9876543210fedcba9876543210")}
+
+	oldSvc, err := NewKeyring(oldKey, nil, "")
+	if err != nil {
+		t.Fatalf("NewKeyring(old): %v", err)
+	}
+	encrypted, _ := oldSvc.Encrypt("secret")
+
+	// Dropping the old key makes its ciphertexts undecryptable.
+	newOnly, _ := NewKeyring(newKey, nil, "")
+	if _, err := newOnly.Decrypt(encrypted); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+
+	svc, err := NewKeyring(newKey, []Key{oldKey}, "2025")
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	if svc.PrimaryKeyID() != "2026" {
+		t.Errorf("expected primary 2026, got %q", svc.PrimaryKeyID())
+	}
+	if got, err := svc.Decrypt(encrypted); err != nil || got != "secret" {
+		t.Fatalf("Decrypt with previous key: %q, %v", got, err)
+	}
+	if !svc.NeedsRotation(encrypted) {
+		t.Error("ciphertext under previous key should need rotation")
+	}
+
+	rotated, changed, err := svc.Rotate(encrypted)
+	if err != nil || !changed {
+		t.Fatalf("Rotate: changed=%v err=%v", changed, err)
+	}
+	if got, err := newOnly.Decrypt(rotated); err != nil || got != "secret" {
+		t.Fatalf("rotated value should decrypt with new key only: %q, %v", got, err)
+	}
+	if _, changed, _ := svc.Rotate(rotated); changed {
+		t.Error("rotating a current ciphertext should be a no-op")
+	}
+
+	// Legacy values are decrypted with the designated legacy key.
+	legacy := legacyEncrypt(t, oldKey.Secret, "legacy")
+	if got, _ := svc.Decrypt(legacy); got != "legacy" {
+		t.Errorf("legacy value decrypts to %q", got)
+	}
+}
+
+func TestNewKeyringValidation(t *testing.T) {
+	good := Key{ID: "a", Secret: []byte("0123456789abcdef")}
+
+	if _, err := NewKeyring(Key{ID: "", Secret: good.Secret}, nil, ""); err == nil {
+		t.Error("expected error for empty key ID")
+	}
+	if _, err := NewKeyring(good, []Key{{ID: "a", Secret: good.Secret}}, ""); err == nil {
+		t.Error("expected error for duplicate key ID")
+	}
+	if _, err := NewKeyring(good, []Key{{ID: "b", Secret: []byte("short")}}, ""); err == nil {
+		t.Error("expected error for invalid previous key size")
+	}
+	if _, err := NewKeyring(good, nil, "missing"); err == nil {
+		t.Error("expected error for unknown legacy key")
+	}
+}
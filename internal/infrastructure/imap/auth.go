@@ -0,0 +1,42 @@
+package imap
+
+import "github.com/emersion/go-sasl"
+
+// xoauth2 is the SASL mechanism Gmail and Microsoft 365 introduced for
+// OAuth2 bearer tokens before OAUTHBEARER was standardized.
+const xoauth2 = "XOAUTH2"
+
+type xoauth2Client struct {
+	username string
+	token    string
+}
+
+func newXOAuth2Client(username, token string) sasl.Client {
+	return &xoauth2Client{username: username, token: token}
+}
+
+func (a *xoauth2Client) Start() (string, []byte, error) {
+	return xoauth2, []byte("user=" + a.username + "\x01auth=Bearer " + a.token + "\x01\x01"), nil
+}
+
+// Next answers the JSON error the server sends when it rejects the token.
+// The protocol requires an empty response, after which the server fails
+// the command.
+func (a *xoauth2Client) Next(challenge []byte) ([]byte, error) {
+	return []byte{}, nil
+}
+
+// oauthBearerClient completes a rejected OAUTHBEARER exchange the way RFC
+// 7628 section 3.2.3 asks, with a dummy %x01 response, instead of
+// cancelling it, which some servers do not answer.
+type oauthBearerClient struct {
+	sasl.Client
+}
+
+func newOAuthBearerClient(opts *sasl.OAuthBearerOptions) sasl.Client {
+	return oauthBearerClient{sasl.NewOAuthBearerClient(opts)}
+}
+
+func (a oauthBearerClient) Next(challenge []byte) ([]byte, error) {
+	return []byte{0x01}, nil
+}
@@ -0,0 +1,138 @@
+package imap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/backend/memory"
+	"github.com/emersion/go-imap/server"
+	"github.com/emersion/go-sasl"
+)
+
+// xoauth2Server accepts the memory backend's single user with token.
+type xoauth2Server struct {
+	conn  server.Conn
+	be    *memory.Backend
+	token string
+	done  bool
+}
+
+func (s *xoauth2Server) Next(response []byte) ([]byte, bool, error) {
+	if s.done {
+		return nil, true, errors.New("invalid token")
+	}
+	if !bytes.Equal(response, []byte("user=username\x01auth=Bearer "+s.token+"\x01\x01")) {
+		s.done = true
+		return []byte(`{"status":"401"}`), false, nil
+	}
+	u, err := s.be.Login(s.conn.Info(), "username", "password")
+	if err != nil {
+		return nil, true, err
+	}
+	ctx := s.conn.Context()
+	ctx.State = imap.AuthenticatedState
+	ctx.User = u
+	return nil, true, nil
+}
+
+// startServer runs an in-process IMAP server and points dial at it.
+func startServer(t *testing.T, mechanism, token string) (string, int) {
+	t.Helper()
+	be := memory.New()
+	s := server.New(be)
+	s.AllowInsecureAuth = true
+	s.ErrorLog = discardLogger{}
+	switch mechanism {
+	case xoauth2:
+		s.EnableAuth(xoauth2, func(conn server.Conn) sasl.Server {
+			return &xoauth2Server{conn: conn, be: be, token: token}
+		})
+	case sasl.OAuthBearer:
+		s.EnableAuth(sasl.OAuthBearer, func(conn server.Conn) sasl.Server {
+			return sasl.NewOAuthBearerServer(func(opts sasl.OAuthBearerOptions) *sasl.OAuthBearerError {
+				if opts.Token != token || opts.Username != "username" {
+					return &sasl.OAuthBearerError{Status: "invalid_token"}
+				}
+				u, err := be.Login(conn.Info(), "username", "password")
+				if err != nil {
+					return &sasl.OAuthBearerError{Status: "invalid_token"}
+				}
+				ctx := conn.Context()
+				ctx.State = imap.AuthenticatedState
+				ctx.User = u
+				return nil
+			})
+		})
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+
+	dialTCP(t)
+
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return host, p
+}
+
+// dialTCP points dial at a plain TCP connection for the test.
+func dialTCP(t *testing.T) {
+	orig := dial
+	dial = func(ctx context.Context, addr string) (net.Conn, error) {
+		return new(net.Dialer).DialContext(ctx, "tcp", addr)
+	}
+	t.Cleanup(func() { dial = orig })
+}
+
+func TestNewOAuth2AuthenticatesWithXOAUTH2(t *testing.T) {
+	host, port := startServer(t, xoauth2, "access-token")
+
+	if _, err := NewOAuth2(context.Background(), "username", "stale-token", host, port, Timeouts{}); err == nil {
+		t.Fatal("expected a rejected token to fail")
+	}
+
+	c, err := NewOAuth2(context.Background(), "username", "access-token", host, port, Timeouts{})
+	if err != nil {
+		t.Fatalf("NewOAuth2: %v", err)
+	}
+	status, err := c.SelectFolder("INBOX")
+	if err != nil {
+		t.Fatalf("SelectFolder: %v", err)
+	}
+	if status.UIDValidity == 0 {
+		t.Error("expected a UIDVALIDITY")
+	}
+	out := NewCollector(0)
+	uids, err := c.GetEmails("INBOX", 1, Criteria{Tags: []string{"message"}}, Limits{}, out)
+	if err != nil {
+		t.Fatalf("GetEmails: %v", err)
+	}
+	emails := out.Emails()
+	if len(emails) != 1 || len(uids) != 1 || uids[0] != 6 {
+		t.Fatalf("expected the single message with UID 6, got %v %v", emails, uids)
+	}
+	e := emails[0]
+	if e.Subject != "A little message, just for you" || e.From != "contact@example.org" || out.Text() != "Hi there :). " || e.Sent.IsZero() {
+		t.Errorf("unexpected email %+v", e)
+	}
+}
+
+func TestNewOAuth2PrefersOAUTHBEARER(t *testing.T) {
+	host, port := startServer(t, sasl.OAuthBearer, "access-token")
+
+	if _, err := NewOAuth2(context.Background(), "username", "access-token", host, port, Timeouts{}); err != nil {
+		t.Fatalf("NewOAuth2: %v", err)
+	}
+	if _, err := NewOAuth2(context.Background(), "username", "stale-token", host, port, Timeouts{}); err == nil {
+		t.Error("expected a rejected token to fail")
+	}
+}
@@ -1,49 +1,437 @@
 package imap
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
+	"net/textproto"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 
-	goiMAP "github.com/BrianLeishman/go-imap"
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/commands"
+	"github.com/emersion/go-imap/responses"
+	"github.com/emersion/go-sasl"
+	"github.com/jhillyerd/enmime"
 )
 
-// Client wraps IMAP operations.
+// Client wraps IMAP operations on one connection. The connection is
+// closed when the context it was opened with is done, which aborts the
+// command in progress; Close releases it earlier.
 type Client struct {
-	dialer *goiMAP.Dialer
+	conn *client.Client
+	raw  net.Conn // the connection under conn, for clearing its deadline
+	ctx  context.Context
+	stop func() bool // cancels closing the connection with ctx
+
+	// Set for sessions taken from a pool, which Close returns to it.
+	pool      *Pool
+	key       string
+	host      string
+	idle      *time.Timer // evicts the session while it sits in the pool
+	idleSince time.Time
+	noReuse   bool // the session's state is unfit for another caller
+}
+
+// Timeouts bound how long the client waits on the server. Zero values mean
+// no limit.
+type Timeouts struct {
+	Dial    time.Duration // connecting, TLS handshake and greeting
+	Command time.Duration // each command, including one FETCH batch
 }
 
-// New creates a new IMAP client connection.
-func New(email, password, domain string, port int) (*Client, error) {
-	im, err := goiMAP.New(email, password, domain, port)
+// dial opens a TLS connection to an IMAP server. Tests replace it to talk
+// to an in-process server.
+var dial = func(ctx context.Context, addr string) (net.Conn, error) {
+	return new(tls.Dialer).DialContext(ctx, "tcp", addr)
+}
+
+// connect opens a connection and reads the server's greeting.
+func connect(ctx context.Context, domain string, port int, timeouts Timeouts) (*Client, error) {
+	dialCtx := ctx
+	if timeouts.Dial > 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(ctx, timeouts.Dial)
+		defer cancel()
+	}
+	conn, err := dial(dialCtx, net.JoinHostPort(domain, strconv.Itoa(port)))
 	if err != nil {
 		return nil, fmt.Errorf("connecting to IMAP: %w", err)
 	}
-	return &Client{dialer: im}, nil
+	// The greeting is read before client.New returns, so the dial timeout
+	// covers it through the connection's deadline.
+	if deadline, ok := dialCtx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	c, err := client.New(conn)
+	if err != nil {
+		stop()
+		conn.Close()
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, fmt.Errorf("connecting to IMAP: %w", err)
+	}
+	c.ErrorLog = discardLogger{}
+	c.Timeout = timeouts.Command
+	return &Client{conn: c, raw: conn, ctx: ctx, stop: stop}, nil
+}
+
+// bind ties the connection to ctx instead of the context it was bound to
+// before. It reports false when the previous context already closed it.
+func (c *Client) bind(ctx context.Context) bool {
+	if !c.stop() && c.ctx.Err() != nil {
+		return false
+	}
+	c.ctx = ctx
+	c.stop = context.AfterFunc(ctx, func() { c.conn.Terminate() })
+	return true
+}
+
+// New creates a new IMAP client connection authenticated with LOGIN.
+func New(ctx context.Context, email, password, domain string, port int, timeouts Timeouts) (*Client, error) {
+	c, err := connect(ctx, domain, port, timeouts)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.conn.Login(email, password); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("logging in to IMAP: %w", c.cause(err))
+	}
+	return c, nil
+}
+
+// NewOAuth2 creates a new IMAP client connection authenticated with an
+// OAuth2 access token. OAUTHBEARER (RFC 7628) is used when the server
+// advertises it, XOAUTH2 otherwise.
+func NewOAuth2(ctx context.Context, username, accessToken, domain string, port int, timeouts Timeouts) (*Client, error) {
+	c, err := connect(ctx, domain, port, timeouts)
+	if err != nil {
+		return nil, err
+	}
+
+	var auth sasl.Client
+	if ok, _ := c.conn.SupportAuth(sasl.OAuthBearer); ok {
+		auth = newOAuthBearerClient(&sasl.OAuthBearerOptions{
+			Username: username,
+			Token:    accessToken,
+			Host:     domain,
+			Port:     port,
+		})
+	} else {
+		auth = newXOAuth2Client(username, accessToken)
+	}
+	if err := c.conn.Authenticate(auth); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("authenticating to IMAP: %w", c.cause(err))
+	}
+	return c, nil
+}
+
+// Close logs out and closes the connection, or hands a pooled session
+// back to its pool. A connection that was already closed, by its context
+// or an earlier Close, is not an error.
+func (c *Client) Close() error {
+	if c.pool != nil {
+		c.pool.put(c)
+		return nil
+	}
+	return c.logout()
+}
+
+func (c *Client) logout() error {
+	c.stop()
+	err := c.conn.Logout()
+	if err == nil || errors.Is(err, client.ErrAlreadyLoggedOut) || c.ctx.Err() != nil {
+		return nil
+	}
+	c.conn.Terminate()
+	return fmt.Errorf("logging out of IMAP: %w", err)
+}
+
+// cause returns the context's error for a command that failed because the
+// context closed the connection, and err otherwise.
+func (c *Client) cause(err error) error {
+	if ctxErr := c.ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// discardLogger silences the library's logging of connection errors,
+// which the client reports to its callers instead.
+type discardLogger struct{}
+
+func (discardLogger) Printf(string, ...interface{}) {}
+func (discardLogger) Println(...interface{})        {}
+
+// Watch selects the first of folders and blocks until stop is closed or
+// the connection fails, calling notify whenever the server reports that
+// the folder holds more messages than before. It uses IDLE, falling back
+// to polling with NOOP every pollInterval on servers without it. The other
+// folders cannot be waited on over the same connection; every pollInterval
+// the watch leaves IDLE and asks for their UIDNEXT instead, calling notify
+// when it has grown. notify must not block.
+func (c *Client) Watch(stop <-chan struct{}, folders []string, pollInterval time.Duration, notify func()) error {
+	c.noReuse = true
+	updates := make(chan client.Update, 16)
+	c.conn.Updates = updates
+	// Unsolicited responses keep arriving until the session ends, and the
+	// connection stalls if nobody reads them.
+	defer func() {
+		go func() {
+			for {
+				select {
+				case <-updates:
+				case <-c.conn.LoggedOut():
+					return
+				}
+			}
+		}()
+	}()
+
+	others := make(map[string]*FolderStatus, len(folders)-1)
+	for _, folder := range folders[1:] {
+		status, err := c.Status(folder)
+		if err != nil {
+			return err
+		}
+		others[folder] = status
+	}
+	if _, err := c.SelectFolder(folders[0]); err != nil {
+		return err
+	}
+	messages := c.conn.Mailbox().Messages
+	onUpdate := func(u client.Update) {
+		switch u := u.(type) {
+		case *client.MailboxUpdate:
+			if u.Mailbox.Messages > messages {
+				notify()
+			}
+			messages = u.Mailbox.Messages
+		case *client.ExpungeUpdate:
+			if messages > 0 {
+				messages--
+			}
+		}
+	}
+
+	for {
+		polled, err := c.waitIdle(stop, folders[0], len(others) > 0, pollInterval, updates, onUpdate)
+		if err != nil || !polled {
+			return err
+		}
+		for folder, last := range others {
+			status, err := c.Status(folder)
+			if err != nil {
+				return err
+			}
+			if status.UIDValidity != last.UIDValidity || status.UIDNext > last.UIDNext {
+				notify()
+			}
+			others[folder] = status
+		}
+	}
+}
+
+// waitIdle idles on the selected folder, passing unsolicited responses to
+// onUpdate, until stop is closed or, when poll is set, pollInterval has
+// passed. It reports whether it returned to poll.
+func (c *Client) waitIdle(stop <-chan struct{}, folder string, poll bool, pollInterval time.Duration, updates <-chan client.Update, onUpdate func(client.Update)) (bool, error) {
+	// IDLE stays open for much longer than a command may take; a dead
+	// connection is noticed through TCP keep-alives instead.
+	timeout := c.conn.Timeout
+	c.conn.Timeout = 0
+	defer func() { c.conn.Timeout = timeout }()
+
+	var due <-chan time.Time
+	if poll {
+		timer := time.NewTimer(pollInterval)
+		defer timer.Stop()
+		due = timer.C
+	}
+	idleStop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- c.conn.Idle(idleStop, &client.IdleOptions{PollInterval: pollInterval})
+	}()
+	polled := false
+	for {
+		select {
+		case u := <-updates:
+			onUpdate(u)
+		case <-stop:
+			stop, due = nil, nil
+			close(idleStop)
+		case <-due:
+			stop, due = nil, nil
+			polled = true
+			close(idleStop)
+		case err := <-done:
+			if err != nil {
+				return false, fmt.Errorf("watching folder %q: %w", folder, c.cause(err))
+			}
+			return polled, nil
+		}
+	}
+}
+
+// Status returns the UIDVALIDITY and UIDNEXT of a folder without selecting
+// it.
+func (c *Client) Status(folder string) (*FolderStatus, error) {
+	st, err := c.conn.Status(folder, []imap.StatusItem{imap.StatusUidValidity, imap.StatusUidNext})
+	if err != nil {
+		return nil, fmt.Errorf("checking folder %q: %w", folder, c.cause(err))
+	}
+	return &FolderStatus{UIDValidity: st.UidValidity, UIDNext: st.UidNext}, nil
 }
 
 // GetFolders lists all available IMAP folders.
 func (c *Client) GetFolders() ([]string, error) {
-	folders, err := c.dialer.GetFolders()
-	if err != nil {
-		return nil, fmt.Errorf("listing folders: %w", err)
+	ch := make(chan *imap.MailboxInfo, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.conn.List("", "*", ch)
+	}()
+
+	var folders []string
+	for mbox := range ch {
+		folders = append(folders, mbox.Name)
+	}
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("listing folders: %w", c.cause(err))
 	}
 	return folders, nil
 }
 
-// SelectFolder switches to the specified folder.
-func (c *Client) SelectFolder(folder string) error {
-	if err := c.dialer.SelectFolder(folder); err != nil {
-		return fmt.Errorf("selecting folder %q: %w", folder, err)
+// IsFolderPattern reports whether folder contains wildcards and has to be
+// matched against the folders the server lists.
+func IsFolderPattern(folder string) bool {
+	return strings.ContainsAny(folder, "*?[")
+}
+
+// MatchFolders expands patterns against the available folders. Patterns
+// use path.Match syntax, so "Lists/*" matches "Lists/Engineering" but not
+// "Lists/Engineering/Archive"; names without wildcards are kept as they
+// are. The result is in pattern order with duplicates removed.
+func MatchFolders(available, patterns []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var folders []string
+	add := func(folder string) {
+		if !seen[folder] {
+			seen[folder] = true
+			folders = append(folders, folder)
+		}
 	}
-	return nil
+	for _, pattern := range patterns {
+		if !IsFolderPattern(pattern) {
+			add(pattern)
+			continue
+		}
+		for _, folder := range available {
+			ok, err := path.Match(pattern, folder)
+			if err != nil {
+				return nil, fmt.Errorf("folder pattern %q: %w", pattern, err)
+			}
+			if ok {
+				add(folder)
+			}
+		}
+	}
+	return folders, nil
+}
+
+// ResolveFolders expands the wildcard patterns among folders against the
+// folders the server lists. The server is only asked when there is a
+// pattern to expand.
+func (c *Client) ResolveFolders(folders []string) ([]string, error) {
+	var available []string
+	for _, f := range folders {
+		if IsFolderPattern(f) {
+			var err error
+			if available, err = c.GetFolders(); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	return MatchFolders(available, folders)
+}
+
+// FolderStatus holds the mailbox metadata the server reports when a folder
+// is selected.
+type FolderStatus struct {
+	UIDValidity   uint32
+	UIDNext       uint32 // UID the next message will get; 0 if not reported
+	HighestModSeq uint64 // 0 if the server does not support CONDSTORE
+}
+
+// selectResponse extends the library's SELECT handler with the
+// HIGHESTMODSEQ response code (RFC 7162), which it does not know about.
+type selectResponse struct {
+	responses.Select
+	highestModSeq uint64
+}
+
+func newSelectResponse(folder string) *selectResponse {
+	return &selectResponse{Select: responses.Select{
+		Mailbox: &imap.MailboxStatus{Name: folder, ReadOnly: true, Items: make(map[imap.StatusItem]interface{})},
+	}}
+}
+
+func (r *selectResponse) Handle(resp imap.Resp) error {
+	if status, ok := resp.(*imap.StatusResp); ok && status.Code == "HIGHESTMODSEQ" && len(status.Arguments) > 0 {
+		if s, ok := status.Arguments[0].(string); ok {
+			r.highestModSeq, _ = strconv.ParseUint(s, 10, 64)
+		}
+		return nil
+	}
+	return r.Select.Handle(resp)
+}
+
+func (r *selectResponse) status() *FolderStatus {
+	return &FolderStatus{UIDValidity: r.Mailbox.UidValidity, UIDNext: r.Mailbox.UidNext, HighestModSeq: r.highestModSeq}
+}
+
+// SelectFolder switches to the specified folder (read-only) and returns its
+// UIDVALIDITY and HIGHESTMODSEQ.
+func (c *Client) SelectFolder(folder string) (*FolderStatus, error) {
+	res := newSelectResponse(folder)
+	status, err := c.conn.Execute(&commands.Select{Mailbox: folder, ReadOnly: true}, res)
+	if err == nil {
+		err = status.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("selecting folder %q: %w", folder, c.cause(err))
+	}
+	c.conn.SetState(imap.SelectedState, res.Mailbox)
+
+	st := res.status()
+	if st.UIDValidity == 0 {
+		return nil, fmt.Errorf("selecting folder %q: server did not report UIDVALIDITY", folder)
+	}
+	return st, nil
 }
 
 // GetUIDs returns UIDs matching the given range (e.g., "1:*").
 func (c *Client) GetUIDs(uidRange string) ([]int, error) {
-	uids, err := c.dialer.GetUIDs(uidRange)
+	set, err := imap.ParseSeqSet(uidRange)
 	if err != nil {
-		return nil, fmt.Errorf("getting UIDs: %w", err)
+		return nil, fmt.Errorf("parsing UID range %q: %w", uidRange, err)
+	}
+	found, err := c.conn.UidSearch(&imap.SearchCriteria{Uid: set})
+	if err != nil {
+		return nil, fmt.Errorf("getting UIDs: %w", c.cause(err))
+	}
+	uids := make([]int, len(found))
+	for i, uid := range found {
+		uids[i] = int(uid)
 	}
 	return uids, nil
 }
@@ -55,69 +443,297 @@ type Email struct {
 	From    string
 	Text    string
 	Sent    time.Time
+	// Truncated is set when only the first Limits.MaxMessageBytes of the
+	// message were downloaded.
+	Truncated bool
+}
+
+// Criteria selects the messages a summary reads.
+type Criteria struct {
+	Tags      []string  // subject keywords; a message needs at least one
+	Blacklist []string  // sender addresses to leave out
+	Since     time.Time // earliest sent time; zero for no limit
+}
+
+// Match reports whether a message satisfies the criteria.
+func (c Criteria) Match(e Email) bool {
+	if !matchesTags(e.Subject, c.Tags) || isBlacklisted(e.From, c.Blacklist) {
+		return false
+	}
+	return c.Since.IsZero() || !e.Sent.Before(c.Since)
+}
+
+// search translates the criteria into an IMAP SEARCH for UIDs of at least
+// fromUID. The server matches SUBJECT as a case-insensitive substring and
+// SENTSINCE by date only, so the result is a superset of the UIDs to read
+// and Match gives the exact answer. The blacklist is left to Match: FROM
+// matches substrings too, and NOT FROM would also drop every address that
+// merely contains a blacklisted one.
+func (c Criteria) search(fromUID int) *imap.SearchCriteria {
+	uids := new(imap.SeqSet)
+	uids.AddRange(uint32(fromUID), 0)
+	sc := &imap.SearchCriteria{Uid: uids}
+
+	var tags []*imap.SearchCriteria
+	for _, tag := range c.Tags {
+		tags = append(tags, &imap.SearchCriteria{Header: textproto.MIMEHeader{"Subject": {tag}}})
+	}
+	// OR takes two keys, so more tags nest: OR a (OR b c).
+	for len(tags) > 1 {
+		n := len(tags)
+		tags = append(tags[:n-2], &imap.SearchCriteria{Or: [][2]*imap.SearchCriteria{{tags[n-2], tags[n-1]}}})
+	}
+	if len(tags) == 1 {
+		if tags[0].Header != nil {
+			sc.Header = tags[0].Header
+		} else {
+			sc.Or = tags[0].Or
+		}
+	}
+
+	if !c.Since.IsZero() {
+		// The Date header may be in another time zone than Since.
+		sc.SentSince = c.Since.AddDate(0, 0, -1)
+	}
+	return sc
+}
+
+// Limits bounds the memory used while reading mail. Zero values mean no
+// limit.
+type Limits struct {
+	BatchSize       int // messages downloaded per round trip
+	MaxMessageBytes int // bytes downloaded per message; longer ones are truncated
+	MaxTotalBytes   int // text collected per run; later messages are skipped
 }
 
-// GetEmails retrieves emails starting from the given UID.
-func (c *Client) GetEmails(folder string, fromUID int) ([]Email, []int, error) {
-	uids, err := c.dialer.GetUIDs(fmt.Sprintf("%d:*", fromUID))
+// GetEmails streams the emails with a UID of at least fromUID that match
+// the criteria into out, limits.BatchSize messages at a time. The server
+// searches for candidates, their headers are checked against the criteria,
+// and only the matches are downloaded, up to limits.MaxMessageBytes each.
+// Once out is full the remaining matches are counted as skipped without
+// being downloaded. It returns the UIDs of the candidates.
+func (c *Client) GetEmails(folder string, fromUID int, criteria Criteria, limits Limits, out *Collector) ([]int, error) {
+	if len(criteria.Tags) == 0 {
+		return nil, nil
+	}
+	found, err := c.conn.UidSearch(criteria.search(fromUID))
 	if err != nil {
-		return nil, nil, fmt.Errorf("getting UIDs: %w", err)
+		return nil, fmt.Errorf("searching emails: %w", c.cause(err))
 	}
 
+	// "n:*" always matches the newest message, even when its UID is below n.
+	uids := make([]uint32, 0, len(found))
+	for _, uid := range found {
+		if int(uid) >= fromUID {
+			uids = append(uids, uid)
+		}
+	}
 	if len(uids) == 0 {
-		return nil, nil, nil
+		return nil, nil
+	}
+
+	batch := limits.BatchSize
+	if batch <= 0 {
+		batch = len(uids)
+	}
+	candidates := make([]int, 0, len(uids))
+	for start := 0; start < len(uids); start += batch {
+		chunk := uids[start:min(start+batch, len(uids))]
+		if err := c.fetchBatch(chunk, criteria, limits.MaxMessageBytes, out); err != nil {
+			return nil, err
+		}
+		for _, uid := range chunk {
+			candidates = append(candidates, int(uid))
+		}
 	}
+	return candidates, nil
+}
+
+// fetchBatch checks the headers of the candidate UIDs and downloads the
+// matching messages into out.
+func (c *Client) fetchBatch(uids []uint32, criteria Criteria, maxMessageBytes int, out *Collector) error {
+	set := new(imap.SeqSet)
+	set.AddNum(uids...)
 
-	rawEmails, err := c.dialer.GetEmails(uids...)
+	whole, partial := new(imap.SeqSet), new(imap.SeqSet)
+	matched := 0
+	err := c.fetch(set, []imap.FetchItem{imap.FetchUid, imap.FetchEnvelope, imap.FetchRFC822Size}, func(msg *imap.Message) {
+		if !criteria.Match(parseEnvelope(msg)) {
+			return
+		}
+		matched++
+		if maxMessageBytes > 0 && int(msg.Size) > maxMessageBytes {
+			partial.AddNum(msg.Uid)
+		} else {
+			whole.AddNum(msg.Uid)
+		}
+	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("getting emails: %w", err)
-	}
-
-	emails := make([]Email, 0, len(rawEmails))
-	for _, raw := range rawEmails {
-		sender := extractSender(raw.From)
-		emails = append(emails, Email{
-			UID:     raw.UID,
-			Subject: raw.Subject,
-			From:    sender,
-			Text:    raw.Text,
-			Sent:    raw.Sent,
+		return fmt.Errorf("getting email headers: %w", c.cause(err))
+	}
+	if out.Full() {
+		out.Skip(matched)
+		return nil
+	}
+
+	for _, part := range []struct {
+		uids    *imap.SeqSet
+		section *imap.BodySectionName
+	}{
+		{whole, &imap.BodySectionName{Peek: true}},
+		{partial, &imap.BodySectionName{Peek: true, Partial: []int{0, maxMessageBytes}}},
+	} {
+		if part.uids.Empty() {
+			continue
+		}
+		truncated := len(part.section.Partial) > 0
+		err := c.fetch(part.uids, []imap.FetchItem{imap.FetchUid, part.section.FetchItem()}, func(msg *imap.Message) {
+			e := parseMessage(msg, part.section)
+			e.Truncated = truncated
+			// The headers were checked before download; check the parsed
+			// message again in case its headers decode differently.
+			if criteria.Match(e) {
+				out.Add(e)
+			}
 		})
+		if err != nil {
+			return fmt.Errorf("getting emails: %w", c.cause(err))
+		}
 	}
+	return nil
+}
 
-	return emails, uids, nil
+// fetch runs a UID FETCH and hands every message to fn as it arrives.
+func (c *Client) fetch(uids *imap.SeqSet, items []imap.FetchItem, fn func(*imap.Message)) error {
+	ch := make(chan *imap.Message, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.conn.UidFetch(uids, items, ch)
+	}()
+	for msg := range ch {
+		fn(msg)
+	}
+	return <-done
+}
+
+// parseEnvelope extracts the fields the criteria look at from a message's
+// envelope.
+func parseEnvelope(msg *imap.Message) Email {
+	e := Email{UID: int(msg.Uid)}
+	if msg.Envelope == nil {
+		return e
+	}
+	e.Subject = msg.Envelope.Subject
+	e.Sent = msg.Envelope.Date
+	if len(msg.Envelope.From) > 0 {
+		e.From = msg.Envelope.From[0].Address()
+	}
+	return e
+}
+
+// parseMessage extracts the fields MailDruid uses from a fetched message.
+// A body that cannot be parsed leaves the message without text rather than
+// failing the whole fetch.
+func parseMessage(msg *imap.Message, section *imap.BodySectionName) Email {
+	e := Email{UID: int(msg.Uid)}
+	body := msg.GetBody(section)
+	if body == nil {
+		return e
+	}
+	env, err := enmime.ReadEnvelope(body)
+	if err != nil {
+		return e
+	}
+	e.Subject = env.GetHeader("Subject")
+	e.Text = env.Text
+	if from, err := env.AddressList("From"); err == nil && len(from) > 0 {
+		e.From = from[0].Address
+	}
+	if sent, err := env.Date(); err == nil {
+		e.Sent = sent
+	}
+	return e
 }
 
 // FilterEmails filters emails by tags, blacklisted senders, and start time.
 func FilterEmails(emails []Email, tags, blacklist []string, startTime time.Time) []Email {
+	criteria := Criteria{Tags: tags, Blacklist: blacklist, Since: startTime}
 	var filtered []Email
 	for _, e := range emails {
-		if !matchesTags(e.Subject, tags) {
-			continue
+		if criteria.Match(e) {
+			filtered = append(filtered, e)
 		}
-		if isBlacklisted(e.From, blacklist) {
-			continue
-		}
-		if !startTime.IsZero() && e.Sent.Before(startTime) {
-			continue
-		}
-		filtered = append(filtered, e)
 	}
 	return filtered
 }
 
 // AggregateBody concatenates email bodies into a single string.
 func AggregateBody(emails []Email) string {
-	var b strings.Builder
+	out := NewCollector(0)
 	for _, e := range emails {
-		if e.Text != "" {
-			b.WriteString(e.Text)
-			b.WriteString(". ")
+		out.Add(e)
+	}
+	return out.Text()
+}
+
+// Collector gathers the text of fetched emails for summarization, up to a
+// total size, so that a run never holds more than that in memory. Once an
+// email does not fit, it and every later one are counted as skipped.
+type Collector struct {
+	maxBytes  int // 0 for no limit
+	text      strings.Builder
+	emails    []Email
+	truncated int
+	skipped   int
+	full      bool
+}
+
+// NewCollector creates a collector that keeps up to maxBytes of text, or
+// any amount when maxBytes is 0.
+func NewCollector(maxBytes int) *Collector {
+	return &Collector{maxBytes: maxBytes}
+}
+
+// Add appends an email's text. Emails without text are kept for their
+// metadata only.
+func (c *Collector) Add(e Email) {
+	if e.Text != "" {
+		n := len(e.Text) + len(". ")
+		if c.full || (c.maxBytes > 0 && c.text.Len()+n > c.maxBytes) {
+			c.full = true
+			c.skipped++
+			return
 		}
+		c.text.WriteString(e.Text)
+		c.text.WriteString(". ")
 	}
-	return b.String()
+	if e.Truncated {
+		c.truncated++
+	}
+	e.Text = ""
+	c.emails = append(c.emails, e)
 }
 
+// Skip counts n matching emails that were left out without downloading.
+func (c *Collector) Skip(n int) { c.skipped += n }
+
+// Full reports whether the collector has stopped taking text.
+func (c *Collector) Full() bool { return c.full }
+
+// Text returns the concatenated text of the collected emails.
+func (c *Collector) Text() string { return c.text.String() }
+
+// Emails returns the collected emails, without their text.
+func (c *Collector) Emails() []Email { return c.emails }
+
+// Truncated returns the number of collected emails whose text was cut
+// short by the per-message limit.
+func (c *Collector) Truncated() int { return c.truncated }
+
+// Skipped returns the number of matching emails left out because the
+// total limit was reached.
+func (c *Collector) Skipped() int { return c.skipped }
+
 func matchesTags(subject string, tags []string) bool {
 	lower := strings.ToLower(subject)
 	for _, tag := range tags {
@@ -136,10 +752,3 @@ func isBlacklisted(sender string, blacklist []string) bool {
 	}
 	return false
 }
-
-func extractSender(addresses goiMAP.EmailAddresses) string {
-	for addr := range addresses {
-		return addr
-	}
-	return ""
-}
@@ -1,8 +1,18 @@
 package imap
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/emersion/go-imap"
 )
 
 func TestFilterEmailsMatchesTags(t *testing.T) {
@@ -91,3 +101,418 @@ func TestAggregateBodyEmpty(t *testing.T) {
 		t.Errorf("expected empty string, got %q", body)
 	}
 }
+
+func TestMatchFolders(t *testing.T) {
+	available := []string{"INBOX", "Lists/Engineering", "Lists/Security", "Lists/Security/Archive", "Sent"}
+	got, err := MatchFolders(available, []string{"Lists/*", "INBOX", "Lists/Security", "Archive"})
+	if err != nil {
+		t.Fatalf("MatchFolders: %v", err)
+	}
+	want := []string{"Lists/Engineering", "Lists/Security", "INBOX", "Archive"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if got, _ := MatchFolders(available, []string{"Drafts/*"}); len(got) != 0 {
+		t.Errorf("expected no matches, got %v", got)
+	}
+	if _, err := MatchFolders(available, []string{"Lists/["}); err == nil {
+		t.Error("expected an error for a malformed pattern")
+	}
+}
+
+func TestParseFolderStatus(t *testing.T) {
+	resp := "* FLAGS (\\Answered \\Flagged \\Deleted \\Seen \\Draft)\r\n" +
+		"* OK [PERMANENTFLAGS ()] Read-only mailbox.\r\n" +
+		"* 172 EXISTS\r\n" +
+		"* 0 RECENT\r\n" +
+		"* OK [UIDVALIDITY 3857529045] UIDs valid\r\n" +
+		"* OK [UIDNEXT 4392] Predicted next UID\r\n" +
+		"* OK [HIGHESTMODSEQ 715194045007] Highest\r\n"
+
+	status := parseFolderStatus(t, resp)
+	if status.UIDValidity != 3857529045 {
+		t.Errorf("expected UIDVALIDITY 3857529045, got %d", status.UIDValidity)
+	}
+	if status.HighestModSeq != 715194045007 {
+		t.Errorf("expected HIGHESTMODSEQ 715194045007, got %d", status.HighestModSeq)
+	}
+}
+
+func TestParseFolderStatusWithoutCondstore(t *testing.T) {
+	status := parseFolderStatus(t, "* 3 EXISTS\r\n* OK [UIDVALIDITY 1] UIDs valid\r\n")
+	if status.UIDValidity != 1 {
+		t.Errorf("expected UIDVALIDITY 1, got %d", status.UIDValidity)
+	}
+	if status.HighestModSeq != 0 {
+		t.Errorf("expected HIGHESTMODSEQ 0, got %d", status.HighestModSeq)
+	}
+}
+
+// parseFolderStatus feeds a raw EXAMINE response to the SELECT handler.
+func parseFolderStatus(t *testing.T, raw string) *FolderStatus {
+	t.Helper()
+	r := imap.NewReader(bufio.NewReader(strings.NewReader(raw)))
+	res := newSelectResponse("INBOX")
+	for {
+		resp, err := imap.ReadResp(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("reading response: %v", err)
+		}
+		res.Handle(resp)
+	}
+	return res.status()
+}
+
+// scriptedServer answers just enough IMAP for Watch: LOGIN, EXAMINE of a
+// folder holding one message, and then one new message, announced during
+// IDLE or, when idle is false, in reply to a NOOP poll.
+func scriptedServer(t *testing.T, idle bool) (string, int) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	caps := "IMAP4rev1"
+	if idle {
+		caps += " IDLE"
+	}
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		fmt.Fprintf(conn, "* OK [CAPABILITY %s] ready\r\n", caps)
+		announced := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			tag, cmd, _ := strings.Cut(strings.TrimSpace(line), " ")
+			verb, _, _ := strings.Cut(cmd, " ")
+			switch strings.ToUpper(verb) {
+			case "CAPABILITY":
+				fmt.Fprintf(conn, "* CAPABILITY %s\r\n%s OK done\r\n", caps, tag)
+			case "EXAMINE", "SELECT":
+				fmt.Fprintf(conn, "* 1 EXISTS\r\n* OK [UIDVALIDITY 7] ok\r\n%s OK [READ-ONLY] done\r\n", tag)
+			case "IDLE":
+				fmt.Fprint(conn, "+ idling\r\n* 2 EXISTS\r\n")
+				if _, err := r.ReadString('\n'); err != nil { // DONE
+					return
+				}
+				fmt.Fprintf(conn, "%s OK idle done\r\n", tag)
+			case "NOOP":
+				if !announced {
+					fmt.Fprint(conn, "* 2 EXISTS\r\n")
+					announced = true
+				}
+				fmt.Fprintf(conn, "%s OK done\r\n", tag)
+			case "LOGOUT":
+				fmt.Fprintf(conn, "* BYE\r\n%s OK done\r\n", tag)
+				return
+			default: // LOGIN
+				fmt.Fprintf(conn, "%s OK done\r\n", tag)
+			}
+		}
+	}()
+
+	dialTCP(t)
+
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return host, p
+}
+
+func TestWatchNotifiesOnNewMail(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		idle bool
+	}{{"idle", true}, {"noop polling", false}} {
+		t.Run(tt.name, func(t *testing.T) {
+			host, port := scriptedServer(t, tt.idle)
+			c, err := New(context.Background(), "username", "password", host, port, Timeouts{})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			notified := make(chan struct{}, 1)
+			stop := make(chan struct{})
+			done := make(chan error, 1)
+			go func() {
+				done <- c.Watch(stop, []string{"INBOX"}, 10*time.Millisecond, func() {
+					select {
+					case notified <- struct{}{}:
+					default:
+					}
+				})
+			}()
+
+			select {
+			case <-notified:
+			case err := <-done:
+				t.Fatalf("Watch returned early: %v", err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("expected a notification for the new message")
+			}
+
+			close(stop)
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Errorf("Watch: %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Watch did not return after stop was closed")
+			}
+			if err := c.Close(); err != nil {
+				t.Errorf("Close: %v", err)
+			}
+		})
+	}
+}
+
+func TestWatchPollsOtherFolders(t *testing.T) {
+	host, port := startServer(t, "", "")
+	watcher, err := New(context.Background(), "username", "password", host, port, Timeouts{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer watcher.Close()
+	writer, err := New(context.Background(), "username", "password", host, port, Timeouts{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer writer.Close()
+	if err := writer.conn.Create("Lists"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	before, err := writer.Status("Lists")
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+
+	notified := make(chan struct{}, 1)
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- watcher.Watch(stop, []string{"INBOX", "Lists"}, 10*time.Millisecond, func() {
+			select {
+			case notified <- struct{}{}:
+			default:
+			}
+		})
+	}()
+
+	// Polls that find nothing new stay quiet.
+	select {
+	case <-notified:
+		t.Fatal("expected no notification before new mail")
+	case err := <-done:
+		t.Fatalf("Watch returned early: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	raw := "From: a@example.com\r\nSubject: hello\r\n\r\nbody"
+	if err := writer.conn.Append("Lists", nil, time.Now(), strings.NewReader(raw)); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	select {
+	case <-notified:
+	case err := <-done:
+		t.Fatalf("Watch returned early: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a notification for mail in the polled folder")
+	}
+	if after, err := writer.Status("Lists"); err != nil || after.UIDNext <= before.UIDNext {
+		t.Errorf("expected UIDNEXT to grow past %d, got %+v, %v", before.UIDNext, after, err)
+	}
+
+	close(stop)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Watch: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch did not return after stop was closed")
+	}
+}
+
+// appendMessages signs in to an in-process server holding the memory
+// backend's message with UID 6 and appends messages with UIDs from 7 on.
+func appendMessages(t *testing.T, messages ...[3]string) *Client {
+	t.Helper()
+	host, port := startServer(t, "", "")
+	c, err := New(context.Background(), "username", "password", host, port, Timeouts{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for _, m := range messages {
+		subject, from, body := m[0], m[1], m[2]
+		raw := fmt.Sprintf("From: %s\r\nSubject: %s\r\nDate: %s\r\n\r\n%s", from, subject, "Wed, 08 May 2024 09:00:00 +0000", body)
+		if err := c.conn.Append("INBOX", nil, time.Now(), strings.NewReader(raw)); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	if _, err := c.SelectFolder("INBOX"); err != nil {
+		t.Fatalf("SelectFolder: %v", err)
+	}
+	return c
+}
+
+func TestGetEmailsSearchesOnServer(t *testing.T) {
+	c := appendMessages(t,
+		[3]string{"Weekly report", "alice@example.org", "Body of 7"},         // 7
+		[3]string{"Lunch", "alice@example.org", "Body of 8"},                 // 8
+		[3]string{"Report draft", "spam@example.org", "Body of 9"},           // 9
+		[3]string{"Incident summary", "Bob <bob@example.org>", "Body of 10"}, // 10
+		[3]string{"Outage", "carol@example.org", "Body of 11"},               // 11
+		[3]string{"Outage report", "jimcarol@example.org", "Body of 12"},     // 12
+	)
+
+	criteria := Criteria{
+		Tags:      []string{"report", "incident", "outage"},
+		Blacklist: []string{"spam@example.org", "carol@example.org"},
+	}
+	out := NewCollector(0)
+	uids, err := c.GetEmails("INBOX", 1, criteria, Limits{BatchSize: 1}, out)
+	if err != nil {
+		t.Fatalf("GetEmails: %v", err)
+	}
+	// The blacklist is not part of the search, which would also leave out
+	// jimcarol@example.org.
+	if fmt.Sprint(uids) != "[7 9 10 11 12]" {
+		t.Errorf("expected the server to return UIDs 7 and 9 to 12, got %v", uids)
+	}
+	emails := out.Emails()
+	if len(emails) != 3 || out.Text() != "Body of 7. Body of 10. Body of 12. " || emails[1].From != "bob@example.org" {
+		t.Errorf("unexpected emails %+v with text %q", emails, out.Text())
+	}
+
+	out = NewCollector(0)
+	if _, err := c.GetEmails("INBOX", 8, criteria, Limits{}, out); err != nil || len(out.Emails()) != 2 || out.Emails()[0].UID != 10 {
+		t.Errorf("expected UIDs 10 and 12 from UID 8 on, got %+v, %v", out.Emails(), err)
+	}
+	out = NewCollector(0)
+	if uids, err := c.GetEmails("INBOX", 13, criteria, Limits{}, out); err != nil || uids != nil || out.Emails() != nil {
+		t.Errorf("expected nothing past the newest message, got %+v, %v, %v", out.Emails(), uids, err)
+	}
+
+	// Sent dates are compared exactly once the server has narrowed down
+	// the candidates by day.
+	criteria.Since = time.Date(2024, 5, 8, 10, 0, 0, 0, time.UTC)
+	out = NewCollector(0)
+	if _, err := c.GetEmails("INBOX", 1, criteria, Limits{}, out); err != nil || out.Emails() != nil {
+		t.Errorf("expected no email sent since 10:00, got %+v, %v", out.Emails(), err)
+	}
+}
+
+func TestGetEmailsLimitsMemory(t *testing.T) {
+	var messages [][3]string
+	for i, body := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		size := 100
+		if i == 1 {
+			size = 500
+		}
+		messages = append(messages, [3]string{fmt.Sprintf("Report %d", i+7), "a@example.org", strings.Repeat(body, size)})
+	}
+	c := appendMessages(t, messages...) // UIDs 7 to 13
+
+	// Message 8 is cut to 300 bytes including its headers, and the total
+	// fits messages 7 to 10. Message 11 fills the collector, 12 arrives in
+	// the same batch, and 13 is skipped without being downloaded.
+	out := NewCollector(550)
+	uids, err := c.GetEmails("INBOX", 7, Criteria{Tags: []string{"report"}}, Limits{BatchSize: 2, MaxMessageBytes: 300}, out)
+	if err != nil {
+		t.Fatalf("GetEmails: %v", err)
+	}
+	if len(uids) != 7 {
+		t.Errorf("expected 7 candidates, got %v", uids)
+	}
+	if n := len(out.Emails()); n != 4 || out.Truncated() != 1 || out.Skipped() != 3 {
+		t.Errorf("expected 4 emails with 1 truncated and 3 skipped, got %d, %d, %d", n, out.Truncated(), out.Skipped())
+	}
+	if text := out.Text(); len(text) > 550 || strings.Contains(text, strings.Repeat("b", 300)) || !strings.Contains(text, "ddd") {
+		t.Errorf("unexpected text %q", text)
+	}
+}
+
+// silentServer accepts connections, greets when greet is set, and then
+// never answers.
+func silentServer(t *testing.T, greet bool) (string, int) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+			if greet {
+				fmt.Fprint(conn, "* OK [CAPABILITY IMAP4rev1] ready\r\n")
+			}
+			go io.Copy(io.Discard, conn)
+		}
+	}()
+	dialTCP(t)
+
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return host, p
+}
+
+func TestNewTimesOut(t *testing.T) {
+	host, port := silentServer(t, false)
+	start := time.Now()
+	if _, err := New(context.Background(), "username", "password", host, port, Timeouts{Dial: 50 * time.Millisecond}); err == nil {
+		t.Fatal("expected a server that never greets to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the dial timeout to apply, took %v", elapsed)
+	}
+
+	host, port = silentServer(t, true)
+	if _, err := New(context.Background(), "username", "password", host, port, Timeouts{Command: 50 * time.Millisecond}); err == nil {
+		t.Fatal("expected a LOGIN that is never answered to time out")
+	}
+}
+
+func TestCancelAbortsCommand(t *testing.T) {
+	host, port := scriptedServer(t, true)
+	ctx, cancel := context.WithCancel(context.Background())
+	c, err := New(ctx, "username", "password", host, port, Timeouts{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	done := make(chan error, 1)
+	go func() { done <- c.Watch(stop, []string{"INBOX"}, time.Hour, func() {}) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the watch to end with context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected cancelling the context to abort the command")
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("Close after cancellation: %v", err)
+	}
+}
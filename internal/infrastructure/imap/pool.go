@@ -0,0 +1,233 @@
+package imap
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap"
+)
+
+// ErrPoolClosed is returned by Pool.Get after the pool has been closed.
+var ErrPoolClosed = errors.New("IMAP session pool closed")
+
+// PoolConfig bounds the sessions a pool keeps.
+type PoolConfig struct {
+	// MaxPerHost caps the open sessions to one server, in use or idle;
+	// 0 for no limit.
+	MaxPerHost int
+	// MaxIdlePerKey caps the idle sessions kept for one mailbox.
+	MaxIdlePerKey int
+	// IdleTimeout is how long an unused session is kept before it is
+	// logged out.
+	IdleTimeout time.Duration
+}
+
+// Pool keeps signed-in sessions so that mailboxes read again soon do not
+// log in again. Sessions are keyed by mailbox and checked with NOOP before
+// they are handed out; Close on a session returns it to the pool.
+type Pool struct {
+	cfg PoolConfig
+
+	mu      sync.Mutex
+	idle    map[string][]*Client // key -> idle sessions, most recently used last
+	open    map[string]int       // host -> open sessions
+	changed chan struct{}        // closed when a session is released
+	closed  bool
+}
+
+// NewPool creates an empty pool.
+func NewPool(cfg PoolConfig) *Pool {
+	return &Pool{
+		cfg:     cfg,
+		idle:    make(map[string][]*Client),
+		open:    make(map[string]int),
+		changed: make(chan struct{}),
+	}
+}
+
+// Get returns an idle session for key that still answers NOOP, or signs in
+// with dial when the host has a free slot. Idle sessions of other
+// mailboxes on the host are logged out to make room; when every slot is in
+// use, Get waits for one until ctx is done. The session is bound to ctx
+// like one opened directly.
+func (p *Pool) Get(ctx context.Context, key, host string, dial func(ctx context.Context) (*Client, error)) (*Client, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrPoolClosed
+		}
+		if c := p.takeIdle(key); c != nil {
+			p.mu.Unlock()
+			if c.bind(ctx) && c.conn.Noop() == nil {
+				return c, nil
+			}
+			p.discard(c)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		if p.cfg.MaxPerHost <= 0 || p.open[host] < p.cfg.MaxPerHost || p.evictIdle(host) {
+			p.open[host]++
+			p.mu.Unlock()
+			c, err := dial(ctx)
+			if err != nil {
+				p.release(host)
+				return nil, err
+			}
+			c.pool, c.key, c.host = p, key, host
+			return c, nil
+		}
+		wait := p.changed
+		p.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Idle returns the number of idle sessions.
+func (p *Pool) Idle() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, sessions := range p.idle {
+		n += len(sessions)
+	}
+	return n
+}
+
+// Close logs out every idle session. Sessions in use are logged out when
+// they are closed.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	var sessions []*Client
+	for key, idle := range p.idle {
+		sessions = append(sessions, idle...)
+		delete(p.idle, key)
+	}
+	p.mu.Unlock()
+
+	for _, c := range sessions {
+		c.idle.Stop()
+		p.discard(c)
+	}
+}
+
+// put takes back a session after use. Sessions the caller's context
+// closed, that failed or that do not fit are logged out instead.
+func (p *Pool) put(c *Client) {
+	if !c.bind(context.Background()) || c.noReuse || c.conn.State() == imap.LogoutState {
+		p.discard(c)
+		return
+	}
+	// go-imap sets the command timeout as a deadline on the connection
+	// and leaves it there, so an idle session would be closed once it
+	// passes. The next command sets a fresh one.
+	if err := c.raw.SetDeadline(time.Time{}); err != nil {
+		p.discard(c)
+		return
+	}
+
+	p.mu.Lock()
+	if p.closed || len(p.idle[c.key]) >= p.cfg.MaxIdlePerKey {
+		p.mu.Unlock()
+		p.discard(c)
+		return
+	}
+	c.idleSince = time.Now()
+	c.idle = time.AfterFunc(p.cfg.IdleTimeout, func() { p.expire(c) })
+	p.idle[c.key] = append(p.idle[c.key], c)
+	p.signal()
+	p.mu.Unlock()
+}
+
+// takeIdle removes the most recently used idle session of key. Must be
+// called with p.mu held.
+func (p *Pool) takeIdle(key string) *Client {
+	idle := p.idle[key]
+	if len(idle) == 0 {
+		return nil
+	}
+	c := idle[len(idle)-1]
+	p.remove(c)
+	c.idle.Stop()
+	return c
+}
+
+// evictIdle logs out the idle session of host that was used least
+// recently, handing its slot to the caller. It reports false when the
+// host has no idle session. Must be called with p.mu held.
+func (p *Pool) evictIdle(host string) bool {
+	var oldest *Client
+	for _, idle := range p.idle {
+		if c := idle[0]; c.host == host && (oldest == nil || c.idleSince.Before(oldest.idleSince)) {
+			oldest = c
+		}
+	}
+	if oldest == nil {
+		return false
+	}
+	p.remove(oldest)
+	oldest.idle.Stop()
+	p.open[host]--
+	go oldest.logout()
+	return true
+}
+
+// expire logs out a session that stayed idle for IdleTimeout, unless it
+// was taken in the meantime.
+func (p *Pool) expire(c *Client) {
+	p.mu.Lock()
+	found := p.remove(c)
+	p.mu.Unlock()
+	if found {
+		p.discard(c)
+	}
+}
+
+// remove drops c from the idle sessions and reports whether it was there.
+// Must be called with p.mu held.
+func (p *Pool) remove(c *Client) bool {
+	idle := p.idle[c.key]
+	for i, s := range idle {
+		if s == c {
+			idle = append(idle[:i], idle[i+1:]...)
+			if len(idle) == 0 {
+				delete(p.idle, c.key)
+			} else {
+				p.idle[c.key] = idle
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// discard logs out a session and frees its slot.
+func (p *Pool) discard(c *Client) {
+	c.logout()
+	p.release(c.host)
+}
+
+func (p *Pool) release(host string) {
+	p.mu.Lock()
+	if p.open[host]--; p.open[host] <= 0 {
+		delete(p.open, host)
+	}
+	p.signal()
+	p.mu.Unlock()
+}
+
+// signal wakes the callers waiting in Get. Must be called with p.mu held.
+func (p *Pool) signal() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
@@ -0,0 +1,172 @@
+package imap
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// poolHarness hands out sessions to the in-process server and counts the
+// logins.
+type poolHarness struct {
+	*Pool
+	host   string
+	logins atomic.Int32
+	dial   func(ctx context.Context) (*Client, error)
+}
+
+func newPoolHarness(t *testing.T, cfg PoolConfig) *poolHarness {
+	return newPoolHarnessTimeouts(t, cfg, Timeouts{Command: 5 * time.Second})
+}
+
+func newPoolHarnessTimeouts(t *testing.T, cfg PoolConfig, timeouts Timeouts) *poolHarness {
+	t.Helper()
+	host, port := startServer(t, "", "")
+	h := &poolHarness{Pool: NewPool(cfg), host: host}
+	h.dial = func(ctx context.Context) (*Client, error) {
+		h.logins.Add(1)
+		return New(ctx, "username", "password", host, port, timeouts)
+	}
+	t.Cleanup(h.Close)
+	return h
+}
+
+func (h *poolHarness) get(t *testing.T, ctx context.Context, key string) *Client {
+	t.Helper()
+	c, err := h.Get(ctx, key, h.host, h.dial)
+	if err != nil {
+		t.Fatalf("Get(%q): %v", key, err)
+	}
+	return c
+}
+
+func testPoolConfig() PoolConfig {
+	return PoolConfig{MaxPerHost: 4, MaxIdlePerKey: 2, IdleTimeout: time.Hour}
+}
+
+func TestPoolReusesSessions(t *testing.T) {
+	h := newPoolHarness(t, testPoolConfig())
+	ctx := context.Background()
+
+	first := h.get(t, ctx, "m1")
+	if _, err := first.SelectFolder("INBOX"); err != nil {
+		t.Fatalf("SelectFolder: %v", err)
+	}
+	first.Close()
+	if h.Idle() != 1 {
+		t.Fatalf("expected the session to be kept, got %d idle", h.Idle())
+	}
+
+	again := h.get(t, ctx, "m1")
+	if again != first || h.logins.Load() != 1 {
+		t.Errorf("expected the session to be reused, got %d logins", h.logins.Load())
+	}
+	if _, err := again.GetFolders(); err != nil {
+		t.Errorf("GetFolders on a reused session: %v", err)
+	}
+	other := h.get(t, ctx, "m2")
+	if other == again || h.logins.Load() != 2 {
+		t.Errorf("expected another mailbox to get its own session, got %d logins", h.logins.Load())
+	}
+	again.Close()
+	other.Close()
+}
+
+func TestPoolKeepsSessionsPastCommandTimeout(t *testing.T) {
+	h := newPoolHarnessTimeouts(t, testPoolConfig(), Timeouts{Command: 50 * time.Millisecond})
+	ctx := context.Background()
+
+	first := h.get(t, ctx, "m1")
+	if _, err := first.SelectFolder("INBOX"); err != nil {
+		t.Fatalf("SelectFolder: %v", err)
+	}
+	first.Close()
+
+	// Idle for longer than a command may take
+	time.Sleep(200 * time.Millisecond)
+
+	again := h.get(t, ctx, "m1")
+	if again != first || h.logins.Load() != 1 {
+		t.Fatalf("expected the idle session to survive the command timeout, got %d logins", h.logins.Load())
+	}
+	if _, err := again.SelectFolder("INBOX"); err != nil {
+		t.Errorf("SelectFolder on a reused session: %v", err)
+	}
+	again.Close()
+}
+
+func TestPoolChecksSessionsBeforeReuse(t *testing.T) {
+	h := newPoolHarness(t, testPoolConfig())
+	ctx := context.Background()
+
+	c := h.get(t, ctx, "m1")
+	c.Close()
+	c.conn.Terminate() // the server dropped the idle connection
+
+	again := h.get(t, ctx, "m1")
+	if again == c || h.logins.Load() != 2 {
+		t.Errorf("expected a dead session to be replaced, got %d logins", h.logins.Load())
+	}
+	again.Close()
+
+	// A session whose caller was cancelled is not put back.
+	cctx, cancel := context.WithCancel(ctx)
+	c = h.get(t, cctx, "m1")
+	cancel()
+	c.Close()
+	if h.Idle() != 0 {
+		t.Errorf("expected the cancelled session to be logged out, got %d idle", h.Idle())
+	}
+}
+
+func TestPoolLimitsSessionsPerHost(t *testing.T) {
+	cfg := testPoolConfig()
+	cfg.MaxPerHost = 1
+	h := newPoolHarness(t, cfg)
+	ctx := context.Background()
+
+	m1 := h.get(t, ctx, "m1")
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := h.Get(short, "m2", h.host, h.dial); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected to wait for a free slot, got %v", err)
+	}
+
+	got := make(chan *Client, 1)
+	go func() {
+		c, err := h.Get(ctx, "m2", h.host, h.dial)
+		if err != nil {
+			t.Errorf("Get: %v", err)
+		}
+		got <- c
+	}()
+	// Returning m1's session frees the slot; the idle session is logged
+	// out to make room for m2.
+	m1.Close()
+	select {
+	case m2 := <-got:
+		if m2 == m1 || h.Idle() != 0 {
+			t.Errorf("expected m1's idle session to make room for m2, got %d idle", h.Idle())
+		}
+		m2.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the waiting Get to proceed once the slot was freed")
+	}
+}
+
+func TestPoolEvictsIdleSessions(t *testing.T) {
+	cfg := testPoolConfig()
+	cfg.IdleTimeout = 20 * time.Millisecond
+	h := newPoolHarness(t, cfg)
+
+	h.get(t, context.Background(), "m1").Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for h.Idle() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the idle session to be logged out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
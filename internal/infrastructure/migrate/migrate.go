@@ -0,0 +1,342 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrNoDownScript is returned when rolling back a migration that has no
+// .down.sql file.
+var ErrNoDownScript = errors.New("migration has no down script")
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// advisoryLockKey identifies the migration lock among the PostgreSQL
+// advisory locks of a database.
+const advisoryLockKey = 7_295_117_204_631
+
+const createTrackingTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version BIGINT PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at TIMESTAMP NOT NULL
+)`
+
+// Migration is a single numbered schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status reports whether a migration has been applied to the database.
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies and rolls back numbered SQL migrations, recording
+// progress in the schema_migrations table. On PostgreSQL a run holds an
+// advisory lock, so instances starting together apply each migration
+// once; SQLite already allows a single writer.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+	// lock is taken on the connection that runs the migrations and
+	// returns the function that releases it; nil for no lock.
+	lock func(conn *gorm.DB) (unlock func() error, err error)
+}
+
+// New loads migrations from fsys. Files must be named
+// <version>_<name>.up.sql and optionally <version>_<name>.down.sql.
+func New(db *gorm.DB, fsys fs.FS) (*Migrator, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		if version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %q", entry.Name())
+		}
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("reading migration %q: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	m := &Migrator{db: db, migrations: migrations}
+	if db.Dialector.Name() == "postgres" {
+		m.lock = advisoryLock
+	}
+	return m, nil
+}
+
+// advisoryLock waits for the PostgreSQL migration lock on conn.
+func advisoryLock(conn *gorm.DB) (func() error, error) {
+	if err := conn.Exec("SELECT pg_advisory_lock(?)", advisoryLockKey).Error; err != nil {
+		return nil, fmt.Errorf("waiting for the migration lock: %w", err)
+	}
+	return func() error {
+		// The lock belongs to the session, so release it even when the
+		// run was cancelled.
+		err := conn.WithContext(context.Background()).Exec("SELECT pg_advisory_unlock(?)", advisoryLockKey).Error
+		if err != nil {
+			return fmt.Errorf("releasing the migration lock: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// Latest returns the highest migration version known to this binary.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Current returns the highest applied migration version, or 0 for an empty database.
+func (m *Migrator) Current(ctx context.Context) (int, error) {
+	applied, err := applied(m.db.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	current := 0
+	for v := range applied {
+		if v > current {
+			current = v
+		}
+	}
+	return current, nil
+}
+
+// Status lists every known migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := applied(m.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		at, ok := applied[mig.Version]
+		statuses = append(statuses, Status{
+			Version:   mig.Version,
+			Name:      mig.Name,
+			Applied:   ok,
+			AppliedAt: at,
+		})
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations that have not been applied yet.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := applied(m.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; !ok {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies all pending migrations in order.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.To(ctx, m.Latest())
+}
+
+// Down rolls back the most recently applied migration. It returns nil if
+// nothing has been applied.
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	current, err := m.Current(ctx)
+	if err != nil || current == 0 {
+		return nil, err
+	}
+	target := 0
+	for _, mig := range m.migrations {
+		if mig.Version < current {
+			target = mig.Version
+		}
+	}
+	reverted, err := m.To(ctx, target)
+	if err != nil || len(reverted) == 0 {
+		return nil, err
+	}
+	return &reverted[0], nil
+}
+
+// To migrates the schema up or down until exactly the migrations with
+// version <= target are applied. It returns the migrations that were run.
+func (m *Migrator) To(ctx context.Context, target int) ([]Migration, error) {
+	if target < 0 || (target > 0 && !m.known(target)) {
+		return nil, fmt.Errorf("unknown migration version %d", target)
+	}
+
+	// The lock and the migrations share one connection, and what has been
+	// applied is read only once the lock is held.
+	var run []Migration
+	err := m.db.WithContext(ctx).Connection(func(conn *gorm.DB) (err error) {
+		if m.lock != nil {
+			unlock, lerr := m.lock(conn)
+			if lerr != nil {
+				return lerr
+			}
+			defer func() {
+				if uerr := unlock(); err == nil {
+					err = uerr
+				}
+			}()
+		}
+		run, err = m.migrate(conn, target)
+		return err
+	})
+	return run, err
+}
+
+// migrate runs the migrations that bring conn to target.
+func (m *Migrator) migrate(conn *gorm.DB, target int) ([]Migration, error) {
+	applied, err := applied(conn)
+	if err != nil {
+		return nil, err
+	}
+	for v := range applied {
+		if !m.known(v) {
+			return nil, fmt.Errorf("database has migration %d applied which this binary does not know about", v)
+		}
+	}
+
+	var run []Migration
+
+	// Roll back newest first.
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok || mig.Version <= target {
+			continue
+		}
+		if err := revert(conn, mig); err != nil {
+			return run, err
+		}
+		run = append(run, mig)
+	}
+
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok || mig.Version > target {
+			continue
+		}
+		if err := apply(conn, mig); err != nil {
+			return run, err
+		}
+		run = append(run, mig)
+	}
+
+	return run, nil
+}
+
+func apply(conn *gorm.DB, mig Migration) error {
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(mig.Up).Error; err != nil {
+			return err
+		}
+		return tx.Exec(
+			"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+			mig.Version, mig.Name, time.Now().UTC(),
+		).Error
+	})
+	if err != nil {
+		return fmt.Errorf("applying migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+	return nil
+}
+
+func revert(conn *gorm.DB, mig Migration) error {
+	if mig.Down == "" {
+		return fmt.Errorf("reverting migration %d_%s: %w", mig.Version, mig.Name, ErrNoDownScript)
+	}
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(mig.Down).Error; err != nil {
+			return err
+		}
+		return tx.Exec("DELETE FROM schema_migrations WHERE version = ?", mig.Version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("reverting migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+	return nil
+}
+
+func applied(db *gorm.DB) (map[int]time.Time, error) {
+	if err := db.Exec(createTrackingTable).Error; err != nil {
+		return nil, fmt.Errorf("creating schema_migrations table: %w", err)
+	}
+
+	var rows []struct {
+		Version   int
+		AppliedAt time.Time
+	}
+	if err := db.Raw("SELECT version, applied_at FROM schema_migrations").Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("reading schema_migrations: %w", err)
+	}
+
+	applied := make(map[int]time.Time, len(rows))
+	for _, r := range rows {
+		applied[r.Version] = r.AppliedAt
+	}
+	return applied, nil
+}
+
+func (m *Migrator) known(version int) bool {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return true
+		}
+	}
+	return false
+}
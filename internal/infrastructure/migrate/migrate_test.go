@@ -0,0 +1,229 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var testMigrations = fstest.MapFS{
+	"0001_create_a.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER PRIMARY KEY);")},
+	"0001_create_a.down.sql": {Data: []byte("DROP TABLE a;")},
+	"0002_create_b.up.sql":   {Data: []byte("CREATE TABLE b (id INTEGER PRIMARY KEY);\nCREATE INDEX idx_b ON b (id);")},
+	"0002_create_b.down.sql": {Data: []byte("DROP TABLE b;")},
+	"0003_seed_a.up.sql":     {Data: []byte("INSERT INTO a (id) VALUES (1);")},
+}
+
+func setupMigrator(t *testing.T, fsys fstest.MapFS) (*Migrator, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	m, err := New(db, fsys)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return m, db
+}
+
+func TestUpAppliesAllPending(t *testing.T) {
+	m, db := setupMigrator(t, testMigrations)
+	ctx := context.Background()
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if len(applied) != 3 {
+		t.Fatalf("expected 3 applied, got %d", len(applied))
+	}
+	if !db.Migrator().HasTable("b") {
+		t.Error("expected table b to exist")
+	}
+
+	current, _ := m.Current(ctx)
+	if current != 3 {
+		t.Errorf("expected version 3, got %d", current)
+	}
+
+	// Second run is a no-op.
+	applied, err = m.Up(ctx)
+	if err != nil || len(applied) != 0 {
+		t.Errorf("expected no-op, got %d applied, err %v", len(applied), err)
+	}
+}
+
+func TestStatusReportsPending(t *testing.T) {
+	m, _ := setupMigrator(t, testMigrations)
+	ctx := context.Background()
+
+	if _, err := m.To(ctx, 1); err != nil {
+		t.Fatalf("To: %v", err)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if len(statuses) != 3 {
+		t.Fatalf("expected 3 statuses, got %d", len(statuses))
+	}
+	if !statuses[0].Applied || statuses[0].AppliedAt.IsZero() {
+		t.Errorf("expected 0001 applied, got %+v", statuses[0])
+	}
+	if statuses[1].Applied || statuses[2].Applied {
+		t.Errorf("expected 0002 and 0003 pending, got %+v", statuses[1:])
+	}
+
+	pending, _ := m.Pending(ctx)
+	if len(pending) != 2 || pending[0].Version != 2 {
+		t.Errorf("unexpected pending: %+v", pending)
+	}
+}
+
+func TestDownAndTo(t *testing.T) {
+	m, db := setupMigrator(t, testMigrations)
+	ctx := context.Background()
+
+	if _, err := m.To(ctx, 2); err != nil {
+		t.Fatalf("To(2): %v", err)
+	}
+
+	reverted, err := m.Down(ctx)
+	if err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if reverted == nil || reverted.Version != 2 {
+		t.Fatalf("expected to revert 2, got %+v", reverted)
+	}
+	if db.Migrator().HasTable("b") {
+		t.Error("expected table b to be dropped")
+	}
+
+	if _, err := m.To(ctx, 0); err != nil {
+		t.Fatalf("To(0): %v", err)
+	}
+	if current, _ := m.Current(ctx); current != 0 {
+		t.Errorf("expected version 0, got %d", current)
+	}
+
+	reverted, err = m.Down(ctx)
+	if err != nil || reverted != nil {
+		t.Errorf("expected no-op Down on empty schema, got %+v, %v", reverted, err)
+	}
+}
+
+func TestDownWithoutScript(t *testing.T) {
+	m, _ := setupMigrator(t, testMigrations)
+	ctx := context.Background()
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if _, err := m.Down(ctx); !errors.Is(err, ErrNoDownScript) {
+		t.Errorf("expected ErrNoDownScript, got %v", err)
+	}
+}
+
+func TestToUnknownVersion(t *testing.T) {
+	m, _ := setupMigrator(t, testMigrations)
+	if _, err := m.To(context.Background(), 42); err == nil {
+		t.Error("expected error for unknown version")
+	}
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	m, _ := setupMigrator(t, fstest.MapFS{
+		"0001_ok.up.sql":  {Data: []byte("CREATE TABLE a (id INTEGER);")},
+		"0002_bad.up.sql": {Data: []byte("CREATE TABLE b (id INTEGER); NOT VALID SQL;")},
+	})
+	ctx := context.Background()
+
+	if _, err := m.Up(ctx); err == nil {
+		t.Fatal("expected error from invalid migration")
+	}
+	if current, _ := m.Current(ctx); current != 1 {
+		t.Errorf("expected version 1 after failure, got %d", current)
+	}
+}
+
+func TestNewRejectsInvalidFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{"bad name", fstest.MapFS{"create.sql": {Data: []byte("SELECT 1;")}}},
+		{"missing up", fstest.MapFS{"0001_a.down.sql": {Data: []byte("SELECT 1;")}}},
+		{"conflicting names", fstest.MapFS{
+			"0001_a.up.sql": {Data: []byte("SELECT 1;")},
+			"0001_b.up.sql": {Data: []byte("SELECT 1;")},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(nil, tt.fsys); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestConcurrentMigratorsApplyOnce(t *testing.T) {
+	first, db := setupMigrator(t, testMigrations)
+	second, err := New(db, testMigrations)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// Stand in for the PostgreSQL advisory lock.
+	var mu sync.Mutex
+	locks := 0
+	lock := func(*gorm.DB) (func() error, error) {
+		mu.Lock()
+		locks++
+		return func() error { mu.Unlock(); return nil }, nil
+	}
+	first.lock, second.lock = lock, lock
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	counts := make([]int, 2)
+	errs := make([]error, 2)
+	for i, m := range []*Migrator{first, second} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			applied, err := m.Up(ctx)
+			counts[i], errs[i] = len(applied), err
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("Up: %v", err)
+		}
+	}
+	if locks != 2 {
+		t.Errorf("expected the lock to be taken twice, got %d", locks)
+	}
+	if counts[0]+counts[1] != 3 {
+		t.Errorf("expected 3 migrations applied in total, got %d and %d", counts[0], counts[1])
+	}
+	var rows int64
+	db.Raw("SELECT COUNT(*) FROM a").Scan(&rows)
+	if rows != 1 {
+		t.Errorf("expected the seed to run once, got %d rows", rows)
+	}
+}
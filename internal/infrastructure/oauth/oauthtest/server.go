@@ -0,0 +1,215 @@
+// Package oauthtest provides a minimal in-process OAuth2 authorization
+// server for tests. It implements an auto-approving authorization endpoint
+// with PKCE and a token endpoint for the authorization_code and
+// refresh_token grants, rotating refresh tokens on every refresh.
+package oauthtest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/akhil-datla/maildruid/internal/config"
+)
+
+// ClientID is the only client the server accepts.
+const ClientID = "maildruid-test"
+
+type grant struct {
+	challenge   string
+	redirectURI string
+}
+
+// Server is a running mock authorization server.
+type Server struct {
+	URL string
+
+	server *httptest.Server
+
+	mu            sync.Mutex
+	codes         map[string]grant
+	refreshTokens map[string]bool
+	accessTokens  map[string]bool
+	refreshes     int
+}
+
+// NewServer starts a server that is shut down when the test ends.
+func NewServer(t *testing.T) *Server {
+	t.Helper()
+
+	s := &Server{
+		codes:         make(map[string]grant),
+		refreshTokens: make(map[string]bool),
+		accessTokens:  make(map[string]bool),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+
+	s.server = httptest.NewServer(mux)
+	s.URL = s.server.URL
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// Provider returns the configuration of a provider backed by the server.
+func (s *Server) Provider(name string) config.OAuth2Provider {
+	return config.OAuth2Provider{
+		Name:     name,
+		ClientID: ClientID,
+		AuthURL:  s.URL + "/authorize",
+		TokenURL: s.URL + "/token",
+		Scopes:   []string{"mail"},
+	}
+}
+
+// Authorize follows an authorization URL as a browser would and returns
+// the redirect the server answers with, which carries the code and state.
+func (s *Server) Authorize(t *testing.T, authURL string) *url.URL {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: expected 302, got %d", resp.StatusCode)
+	}
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("authorize: bad redirect: %v", err)
+	}
+	return loc
+}
+
+// ValidAccessToken reports whether the server issued token.
+func (s *Server) ValidAccessToken(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.accessTokens[token]
+}
+
+// Refreshes returns how many refresh_token grants succeeded.
+func (s *Server) Refreshes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refreshes
+}
+
+// RevokeAll invalidates every refresh token, as when a user withdraws
+// MailDruid's access at the provider.
+func (s *Server) RevokeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshTokens = make(map[string]bool)
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != ClientID || q.Get("response_type") != "code" {
+		http.Error(w, "invalid client or response type", http.StatusBadRequest)
+		return
+	}
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "PKCE required", http.StatusBadRequest)
+		return
+	}
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirect.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+	s.mu.Lock()
+	s.codes[code] = grant{challenge: q.Get("code_challenge"), redirectURI: redirect.String()}
+	s.mu.Unlock()
+
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request")
+		return
+	}
+	clientID, _, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+	}
+	if clientID != ClientID {
+		tokenError(w, "invalid_client")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		code := r.PostForm.Get("code")
+		g, found := s.codes[code]
+		delete(s.codes, code)
+
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !found || g.redirectURI != r.PostForm.Get("redirect_uri") ||
+			base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+			tokenError(w, "invalid_grant")
+			return
+		}
+	case "refresh_token":
+		old := r.PostForm.Get("refresh_token")
+		if !s.refreshTokens[old] {
+			tokenError(w, "invalid_grant")
+			return
+		}
+		delete(s.refreshTokens, old)
+		s.refreshes++
+	default:
+		tokenError(w, "unsupported_grant_type")
+		return
+	}
+
+	access, refresh := randomString(), randomString()
+	s.accessTokens[access] = true
+	s.refreshTokens[refresh] = true
+	writeJSON(w, map[string]any{
+		"access_token":  access,
+		"refresh_token": refresh,
+		"token_type":    "Bearer",
+		"expires_in":    3600,
+	})
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
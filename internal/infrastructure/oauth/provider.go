@@ -0,0 +1,139 @@
+// Package oauth runs the OAuth2 authorization code flow against the
+// providers users connect their mailboxes through, and refreshes the
+// access tokens used to sign in to IMAP.
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/config"
+	"golang.org/x/oauth2"
+)
+
+var (
+	// ErrUnknownProvider is returned for a provider name that is not
+	// configured.
+	ErrUnknownProvider = errors.New("unknown OAuth2 provider")
+	// ErrNoRefreshToken is returned when a provider grants access without
+	// a refresh token, so MailDruid could not sign in again later.
+	ErrNoRefreshToken = errors.New("provider did not issue a refresh token")
+)
+
+// Token holds the credentials returned by an exchange or refresh. A
+// provider that does not rotate refresh tokens returns the one it was
+// given.
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	Expiry       time.Time
+}
+
+type provider struct {
+	oauth  oauth2.Config
+	params []oauth2.AuthCodeOption
+}
+
+// Providers holds the configured OAuth2 providers by name.
+type Providers struct {
+	providers map[string]*provider
+}
+
+// New returns the providers in cfg.
+func New(cfg config.IMAPOAuth2Config) *Providers {
+	p := &Providers{providers: make(map[string]*provider, len(cfg.Providers))}
+	for _, pc := range cfg.Providers {
+		var params []oauth2.AuthCodeOption
+		for k, v := range pc.AuthParams {
+			params = append(params, oauth2.SetAuthURLParam(k, v))
+		}
+		p.providers[pc.Name] = &provider{
+			oauth: oauth2.Config{
+				ClientID:     pc.ClientID,
+				ClientSecret: pc.ClientSecret,
+				RedirectURL:  cfg.RedirectURL,
+				Endpoint:     oauth2.Endpoint{AuthURL: pc.AuthURL, TokenURL: pc.TokenURL},
+				Scopes:       pc.Scopes,
+			},
+			params: params,
+		}
+	}
+	return p
+}
+
+// Names returns the configured provider names in alphabetical order.
+func (p *Providers) Names() []string {
+	if p == nil {
+		return nil
+	}
+	names := make([]string, 0, len(p.providers))
+	for name := range p.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Has reports whether a provider is configured.
+func (p *Providers) Has(name string) bool {
+	_, ok := p.get(name)
+	return ok
+}
+
+// get looks up a provider. A nil *Providers has none.
+func (p *Providers) get(name string) (*provider, bool) {
+	if p == nil {
+		return nil, false
+	}
+	pr, ok := p.providers[name]
+	return pr, ok
+}
+
+// NewVerifier returns a random PKCE code verifier.
+func NewVerifier() string {
+	return oauth2.GenerateVerifier()
+}
+
+// AuthCodeURL returns the URL that asks the user to grant mailbox access.
+// The verifier is kept by the caller and passed back to Exchange.
+func (p *Providers) AuthCodeURL(name, state, verifier string) (string, error) {
+	pr, ok := p.get(name)
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+	opts := append([]oauth2.AuthCodeOption{oauth2.S256ChallengeOption(verifier)}, pr.params...)
+	return pr.oauth.AuthCodeURL(state, opts...), nil
+}
+
+// Exchange redeems an authorization code. The grant must include a refresh
+// token.
+func (p *Providers) Exchange(ctx context.Context, name, code, verifier string) (*Token, error) {
+	pr, ok := p.get(name)
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	tok, err := pr.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("exchanging authorization code: %w", err)
+	}
+	if tok.RefreshToken == "" {
+		return nil, ErrNoRefreshToken
+	}
+	return &Token{AccessToken: tok.AccessToken, RefreshToken: tok.RefreshToken, Expiry: tok.Expiry}, nil
+}
+
+// Refresh trades a refresh token for a new access token.
+func (p *Providers) Refresh(ctx context.Context, name, refreshToken string) (*Token, error) {
+	pr, ok := p.get(name)
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	tok, err := pr.oauth.TokenSource(ctx, &oauth2.Token{RefreshToken: refreshToken}).Token()
+	if err != nil {
+		return nil, fmt.Errorf("refreshing access token: %w", err)
+	}
+	return &Token{AccessToken: tok.AccessToken, RefreshToken: tok.RefreshToken, Expiry: tok.Expiry}, nil
+}
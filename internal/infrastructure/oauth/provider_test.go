@@ -0,0 +1,71 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/akhil-datla/maildruid/internal/config"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/oauth/oauthtest"
+)
+
+func newTestProviders(t *testing.T) (*Providers, *oauthtest.Server) {
+	t.Helper()
+	srv := oauthtest.NewServer(t)
+	p := New(config.IMAPOAuth2Config{
+		RedirectURL: "http://maildruid.test/api/v1/imap/oauth2/callback",
+		Providers:   []config.OAuth2Provider{srv.Provider("test")},
+	})
+	return p, srv
+}
+
+func TestExchangeAndRefresh(t *testing.T) {
+	p, srv := newTestProviders(t)
+	ctx := context.Background()
+
+	verifier := NewVerifier()
+	authURL, err := p.AuthCodeURL("test", "state-1", verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	q := srv.Authorize(t, authURL).Query()
+	if q.Get("state") != "state-1" {
+		t.Fatalf("state not echoed: %q", q.Get("state"))
+	}
+
+	if _, err := p.Exchange(ctx, "test", q.Get("code"), NewVerifier()); err == nil {
+		t.Fatal("expected a wrong PKCE verifier to be rejected")
+	}
+	q = srv.Authorize(t, authURL).Query()
+	tok, err := p.Exchange(ctx, "test", q.Get("code"), verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if !srv.ValidAccessToken(tok.AccessToken) || tok.RefreshToken == "" {
+		t.Fatalf("unexpected token %+v", tok)
+	}
+
+	refreshed, err := p.Refresh(ctx, "test", tok.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if refreshed.AccessToken == tok.AccessToken || refreshed.RefreshToken == tok.RefreshToken {
+		t.Errorf("expected new tokens, got %+v", refreshed)
+	}
+	if _, err := p.Refresh(ctx, "test", tok.RefreshToken); err == nil {
+		t.Error("expected the rotated refresh token to be rejected")
+	}
+}
+
+func TestUnknownProvider(t *testing.T) {
+	p, _ := newTestProviders(t)
+	if p.Has("other") || len(p.Names()) != 1 {
+		t.Fatalf("unexpected providers %v", p.Names())
+	}
+	if _, err := p.AuthCodeURL("other", "s", NewVerifier()); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("expected ErrUnknownProvider, got %v", err)
+	}
+	if _, err := p.Refresh(context.Background(), "other", "r"); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("expected ErrUnknownProvider, got %v", err)
+	}
+}
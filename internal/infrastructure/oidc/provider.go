@@ -0,0 +1,104 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/akhil-datla/maildruid/internal/config"
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// ErrInvalidNonce is returned when the ID token was not issued for the
+// login attempt being completed.
+var ErrInvalidNonce = errors.New("ID token nonce mismatch")
+
+// Identity holds the claims of a verified ID token.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider runs the authorization code flow with PKCE against an OpenID
+// Connect issuer and verifies the ID tokens it returns.
+type Provider struct {
+	oauth    oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+}
+
+// New fetches the issuer's discovery document and returns a provider for
+// the configured client.
+func New(ctx context.Context, cfg config.OIDCConfig) (*Provider, error) {
+	p, err := gooidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("discovering OIDC issuer: %w", err)
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{gooidc.ScopeOpenID, "email", "profile"}
+	}
+
+	return &Provider{
+		oauth: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     p.Endpoint(),
+			Scopes:       scopes,
+		},
+		verifier: p.Verifier(&gooidc.Config{ClientID: cfg.ClientID}),
+	}, nil
+}
+
+// NewVerifier returns a random PKCE code verifier.
+func NewVerifier() string {
+	return oauth2.GenerateVerifier()
+}
+
+// AuthCodeURL returns the issuer URL that starts a login. The verifier is
+// kept by the caller and passed back to Exchange.
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) string {
+	return p.oauth.AuthCodeURL(state, gooidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange redeems an authorization code, verifies the returned ID token's
+// signature, issuer, audience, expiry and nonce, and returns its identity.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	token, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("exchanging authorization code: %w", err)
+	}
+
+	raw, ok := token.Extra("id_token").(string)
+	if !ok || raw == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	idToken, err := p.verifier.Verify(ctx, raw)
+	if err != nil {
+		return nil, fmt.Errorf("verifying ID token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, ErrInvalidNonce
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("decoding ID token claims: %w", err)
+	}
+
+	return &Identity{
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
@@ -0,0 +1,74 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/akhil-datla/maildruid/internal/config"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/oidc/oidctest"
+)
+
+func newTestProvider(t *testing.T) (*Provider, *oidctest.Issuer) {
+	t.Helper()
+	iss := oidctest.NewIssuer(t)
+	p, err := New(context.Background(), config.OIDCConfig{
+		IssuerURL:   iss.URL,
+		ClientID:    oidctest.ClientID,
+		RedirectURL: "http://maildruid.test/api/v1/auth/oidc/callback",
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return p, iss
+}
+
+func authorize(t *testing.T, p *Provider, iss *oidctest.Issuer, state, nonce, verifier string) url.Values {
+	t.Helper()
+	loc := iss.Authorize(t, p.AuthCodeURL(state, nonce, verifier))
+	q := loc.Query()
+	if q.Get("state") != state {
+		t.Fatalf("state not echoed: %q", q.Get("state"))
+	}
+	return q
+}
+
+func TestExchange(t *testing.T) {
+	p, iss := newTestProvider(t)
+	iss.SetIdentity(oidctest.Identity{Subject: "abc", Email: "a@example.com", EmailVerified: true, Name: "A"})
+
+	verifier := NewVerifier()
+	q := authorize(t, p, iss, "state-1", "nonce-1", verifier)
+
+	id, err := p.Exchange(context.Background(), q.Get("code"), verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	want := Identity{Subject: "abc", Email: "a@example.com", EmailVerified: true, Name: "A"}
+	if *id != want {
+		t.Errorf("expected %+v, got %+v", want, *id)
+	}
+}
+
+func TestExchangeRejectsWrongVerifierAndNonce(t *testing.T) {
+	p, iss := newTestProvider(t)
+	ctx := context.Background()
+
+	verifier := NewVerifier()
+	q := authorize(t, p, iss, "s", "n", verifier)
+	if _, err := p.Exchange(ctx, q.Get("code"), NewVerifier(), "n"); err == nil {
+		t.Error("expected error for a mismatched PKCE verifier")
+	}
+
+	q = authorize(t, p, iss, "s", "n", verifier)
+	if _, err := p.Exchange(ctx, q.Get("code"), verifier, "other"); !errors.Is(err, ErrInvalidNonce) {
+		t.Errorf("expected ErrInvalidNonce, got %v", err)
+	}
+}
+
+func TestNewFailsWithoutDiscovery(t *testing.T) {
+	if _, err := New(context.Background(), config.OIDCConfig{IssuerURL: "http://127.0.0.1:1", ClientID: "x"}); err == nil {
+		t.Error("expected discovery error")
+	}
+}
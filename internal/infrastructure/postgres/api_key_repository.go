@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/apikey"
+	"gorm.io/gorm"
+)
+
+// APIKeyRepository implements apikey.Repository with PostgreSQL.
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository creates a new PostgreSQL-backed API key repository.
+func NewAPIKeyRepository(db *DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db.GORM()}
+}
+
+func (r *APIKeyRepository) Create(ctx context.Context, k *apikey.APIKey) error {
+	if err := r.db.WithContext(ctx).Create(k).Error; err != nil {
+		return fmt.Errorf("creating api key: %w", err)
+	}
+	return nil
+}
+
+func (r *APIKeyRepository) FindByHash(ctx context.Context, hash string) (*apikey.APIKey, error) {
+	var k apikey.APIKey
+	if err := r.db.WithContext(ctx).Where("key_hash = ?", hash).First(&k).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apikey.ErrNotFound
+		}
+		return nil, fmt.Errorf("finding api key: %w", err)
+	}
+	return &k, nil
+}
+
+func (r *APIKeyRepository) ListByUser(ctx context.Context, userID string) ([]*apikey.APIKey, error) {
+	var keys []*apikey.APIKey
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("listing api keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (r *APIKeyRepository) Delete(ctx context.Context, userID, id string) error {
+	res := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&apikey.APIKey{})
+	if res.Error != nil {
+		return fmt.Errorf("deleting api key: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return apikey.ErrNotFound
+	}
+	return nil
+}
+
+func (r *APIKeyRepository) Touch(ctx context.Context, id string, at time.Time, ip string) error {
+	err := r.db.WithContext(ctx).Model(&apikey.APIKey{}).Where("id = ?", id).
+		Updates(map[string]any{"last_used_at": at, "last_used_ip": ip}).Error
+	if err != nil {
+		return fmt.Errorf("recording api key use: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/akhil-datla/maildruid/internal/domain/summary"
+	"gorm.io/gorm"
+)
+
+// DigestRepository implements summary.Repository with PostgreSQL.
+type DigestRepository struct {
+	db *gorm.DB
+}
+
+// NewDigestRepository creates a new PostgreSQL-backed digest repository.
+func NewDigestRepository(db *DB) *DigestRepository {
+	return &DigestRepository{db: db.GORM()}
+}
+
+func (r *DigestRepository) Create(ctx context.Context, d *summary.Digest) error {
+	if err := r.db.WithContext(ctx).Create(d).Error; err != nil {
+		return fmt.Errorf("creating digest: %w", err)
+	}
+	return nil
+}
+
+func (r *DigestRepository) FindByID(ctx context.Context, userID, id string) (*summary.Digest, error) {
+	var d summary.Digest
+	err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&d).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, summary.ErrDigestNotFound
+		}
+		return nil, fmt.Errorf("finding digest: %w", err)
+	}
+	return &d, nil
+}
+
+func (r *DigestRepository) ListByUser(ctx context.Context, userID string, limit, offset int) ([]*summary.Digest, int64, error) {
+	return r.list(ctx, "user_id = ?", userID, limit, offset)
+}
+
+func (r *DigestRepository) FindByTeamDigest(ctx context.Context, teamDigestID, id string) (*summary.Digest, error) {
+	var d summary.Digest
+	err := r.db.WithContext(ctx).Where("id = ? AND team_digest_id = ?", id, teamDigestID).First(&d).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, summary.ErrDigestNotFound
+		}
+		return nil, fmt.Errorf("finding digest: %w", err)
+	}
+	return &d, nil
+}
+
+func (r *DigestRepository) ListByTeamDigest(ctx context.Context, teamDigestID string, limit, offset int) ([]*summary.Digest, int64, error) {
+	return r.list(ctx, "team_digest_id = ?", teamDigestID, limit, offset)
+}
+
+func (r *DigestRepository) list(ctx context.Context, where, owner string, limit, offset int) ([]*summary.Digest, int64, error) {
+	var total int64
+	err := r.db.WithContext(ctx).Model(&summary.Digest{}).Where(where, owner).Count(&total).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("counting digests: %w", err)
+	}
+
+	var digests []*summary.Digest
+	err = r.db.WithContext(ctx).
+		Omit("word_cloud").
+		Where(where, owner).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&digests).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("listing digests: %w", err)
+	}
+	return digests, total, nil
+}
@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/akhil-datla/maildruid/internal/domain/mailbox"
+	"gorm.io/gorm"
+)
+
+// MailboxRepository implements mailbox.Repository with PostgreSQL.
+type MailboxRepository struct {
+	db *gorm.DB
+}
+
+// NewMailboxRepository creates a new PostgreSQL-backed mailbox repository.
+func NewMailboxRepository(db *DB) *MailboxRepository {
+	return &MailboxRepository{db: db.GORM()}
+}
+
+func (r *MailboxRepository) Create(ctx context.Context, mb *mailbox.Mailbox) error {
+	if err := r.db.WithContext(ctx).Create(mb).Error; err != nil {
+		return fmt.Errorf("creating mailbox: %w", err)
+	}
+	return nil
+}
+
+func (r *MailboxRepository) FindByID(ctx context.Context, owner mailbox.Owner, id string) (*mailbox.Mailbox, error) {
+	var mb mailbox.Mailbox
+	if err := r.db.WithContext(ctx).Scopes(ownedBy(owner)).Where("id = ?", id).First(&mb).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, mailbox.ErrNotFound
+		}
+		return nil, fmt.Errorf("finding mailbox: %w", err)
+	}
+	return &mb, nil
+}
+
+func (r *MailboxRepository) Update(ctx context.Context, mb *mailbox.Mailbox) error {
+	// The owner never changes, and the one that does not apply is NULL.
+	if err := r.db.WithContext(ctx).Omit("UserID", "TeamID").Save(mb).Error; err != nil {
+		return fmt.Errorf("updating mailbox: %w", err)
+	}
+	return nil
+}
+
+func (r *MailboxRepository) Delete(ctx context.Context, owner mailbox.Owner, id string) error {
+	err := r.db.WithContext(ctx).Scopes(ownedBy(owner)).Where("id = ?", id).Delete(&mailbox.Mailbox{}).Error
+	if err != nil {
+		return fmt.Errorf("deleting mailbox: %w", err)
+	}
+	return nil
+}
+
+func (r *MailboxRepository) ListByOwner(ctx context.Context, owner mailbox.Owner) ([]*mailbox.Mailbox, error) {
+	var mailboxes []*mailbox.Mailbox
+	if err := r.db.WithContext(ctx).Scopes(ownedBy(owner)).Order("created_at, id").Find(&mailboxes).Error; err != nil {
+		return nil, fmt.Errorf("listing mailboxes: %w", err)
+	}
+	return mailboxes, nil
+}
+
+func (r *MailboxRepository) ListPush(ctx context.Context) ([]*mailbox.Mailbox, error) {
+	var mailboxes []*mailbox.Mailbox
+	if err := r.db.WithContext(ctx).Where("enabled AND push").Order("created_at, id").Find(&mailboxes).Error; err != nil {
+		return nil, fmt.Errorf("listing mailboxes: %w", err)
+	}
+	return mailboxes, nil
+}
+
+func (r *MailboxRepository) ListAll(ctx context.Context) ([]*mailbox.Mailbox, error) {
+	var mailboxes []*mailbox.Mailbox
+	if err := r.db.WithContext(ctx).Find(&mailboxes).Error; err != nil {
+		return nil, fmt.Errorf("listing mailboxes: %w", err)
+	}
+	return mailboxes, nil
+}
+
+// ownedBy restricts a query to the mailboxes of a user or team.
+func ownedBy(owner mailbox.Owner) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if owner.TeamID != "" {
+			return db.Where("team_id = ?", owner.TeamID)
+		}
+		return db.Where("user_id = ?", owner.UserID)
+	}
+}
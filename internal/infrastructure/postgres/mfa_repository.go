@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/mfa"
+	"gorm.io/gorm"
+)
+
+// MFARepository implements mfa.Repository with PostgreSQL.
+type MFARepository struct {
+	db *gorm.DB
+}
+
+// NewMFARepository creates a new PostgreSQL-backed two-factor repository.
+func NewMFARepository(db *DB) *MFARepository {
+	return &MFARepository{db: db.GORM()}
+}
+
+func (r *MFARepository) FindCredential(ctx context.Context, userID string) (*mfa.Credential, error) {
+	var c mfa.Credential
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&c).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, mfa.ErrNotFound
+		}
+		return nil, fmt.Errorf("finding TOTP credential: %w", err)
+	}
+	return &c, nil
+}
+
+func (r *MFARepository) SaveCredential(ctx context.Context, c *mfa.Credential) error {
+	if err := r.db.WithContext(ctx).Save(c).Error; err != nil {
+		return fmt.Errorf("saving TOTP credential: %w", err)
+	}
+	return nil
+}
+
+func (r *MFARepository) AdvanceStep(ctx context.Context, userID string, step int64) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&mfa.Credential{}).
+		Where("user_id = ? AND last_step < ?", userID, step).
+		Update("last_step", step)
+	if res.Error != nil {
+		return false, fmt.Errorf("recording TOTP step: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *MFARepository) UpdateAttempts(ctx context.Context, userID string, failed int, lockedUntil *time.Time) error {
+	err := r.db.WithContext(ctx).Model(&mfa.Credential{}).Where("user_id = ?", userID).
+		Updates(map[string]any{"failed_attempts": failed, "locked_until": lockedUntil}).Error
+	if err != nil {
+		return fmt.Errorf("recording verification attempt: %w", err)
+	}
+	return nil
+}
+
+func (r *MFARepository) DeleteCredential(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&mfa.RecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("deleting recovery codes: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&mfa.Credential{}).Error; err != nil {
+			return fmt.Errorf("deleting TOTP credential: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *MFARepository) ListCredentials(ctx context.Context) ([]*mfa.Credential, error) {
+	var creds []*mfa.Credential
+	if err := r.db.WithContext(ctx).Find(&creds).Error; err != nil {
+		return nil, fmt.Errorf("listing TOTP credentials: %w", err)
+	}
+	return creds, nil
+}
+
+func (r *MFARepository) ReplaceRecoveryCodes(ctx context.Context, userID string, codes []*mfa.RecoveryCode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&mfa.RecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("deleting recovery codes: %w", err)
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		if err := tx.Create(codes).Error; err != nil {
+			return fmt.Errorf("creating recovery codes: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, userID, codeHash string, at time.Time) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&mfa.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", at)
+	if res.Error != nil {
+		return false, fmt.Errorf("using recovery code: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *MFARepository) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	var n int64
+	err := r.db.WithContext(ctx).Model(&mfa.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).Count(&n).Error
+	if err != nil {
+		return 0, fmt.Errorf("counting recovery codes: %w", err)
+	}
+	return int(n), nil
+}
@@ -0,0 +1,22 @@
+-- Baseline schema. IF NOT EXISTS lets databases created by the previous
+-- GORM auto-migration adopt version tracking without changes.
+CREATE TABLE IF NOT EXISTS users (
+    id TEXT PRIMARY KEY,
+    name TEXT,
+    email TEXT,
+    receiving_email TEXT,
+    password TEXT,
+    domain TEXT,
+    port BIGINT,
+    folder TEXT,
+    tags TEXT[],
+    black_list_senders TEXT[],
+    start_time TIMESTAMPTZ,
+    summary_count BIGINT,
+    last_uid TEXT,
+    update_interval TEXT,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
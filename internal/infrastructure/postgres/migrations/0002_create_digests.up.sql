@@ -0,0 +1,16 @@
+CREATE TABLE digests (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    summary TEXT NOT NULL,
+    keywords TEXT[],
+    word_cloud BYTEA,
+    tags TEXT[],
+    email_count BIGINT NOT NULL DEFAULT 0,
+    first_uid BIGINT NOT NULL DEFAULT 0,
+    last_uid BIGINT NOT NULL DEFAULT 0,
+    trigger TEXT NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_digests_user_created ON digests (user_id, created_at DESC);
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN last_uid TEXT;
+
+DROP TABLE IF EXISTS sync_states;
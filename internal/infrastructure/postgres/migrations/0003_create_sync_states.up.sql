@@ -0,0 +1,23 @@
+CREATE TABLE sync_states (
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    folder TEXT NOT NULL,
+    uid_validity BIGINT NOT NULL DEFAULT 0,
+    last_uid BIGINT NOT NULL DEFAULT 0,
+    highest_mod_seq BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, folder)
+);
+
+-- Carry over progress from the old tag->UID JSON blob. UIDVALIDITY was never
+-- recorded, so it is left at 0 and adopted on the next run.
+INSERT INTO sync_states (user_id, folder, uid_validity, last_uid, highest_mod_seq, updated_at)
+SELECT u.id,
+       COALESCE(NULLIF(u.folder, ''), 'INBOX'),
+       0,
+       (SELECT COALESCE(MAX(value::BIGINT), 0) FROM json_each_text(u.last_uid::JSON)),
+       0,
+       NOW()
+FROM users u
+WHERE u.last_uid IS NOT NULL AND u.last_uid LIKE '{%}';
+
+ALTER TABLE users DROP COLUMN last_uid;
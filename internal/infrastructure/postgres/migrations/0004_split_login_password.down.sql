@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN password_hash;
+ALTER TABLE users RENAME COLUMN imap_password TO password;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS revoked_tokens;
+DROP TABLE IF EXISTS sessions;
+ALTER TABLE users DROP COLUMN token_generation;
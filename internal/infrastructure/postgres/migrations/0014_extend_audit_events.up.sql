@@ -0,0 +1,19 @@
+ALTER TABLE audit_events ADD COLUMN actor_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_events ADD COLUMN request_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_events ADD COLUMN changes TEXT;
+
+DROP INDEX idx_audit_events_user_id;
+CREATE INDEX idx_audit_events_user_id ON audit_events (user_id, created_at);
+CREATE INDEX idx_audit_events_actor_id ON audit_events (actor_id, created_at);
+CREATE INDEX idx_audit_events_created_at ON audit_events (created_at);
+
+-- The audit log is append-only.
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
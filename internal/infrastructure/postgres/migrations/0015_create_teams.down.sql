@@ -0,0 +1,16 @@
+DROP INDEX IF EXISTS idx_audit_events_team_id;
+ALTER TABLE audit_events DROP COLUMN team_id;
+
+-- Team digest history has no owner in the old schema and is dropped.
+DELETE FROM digests WHERE user_id IS NULL;
+
+DROP INDEX IF EXISTS idx_digests_team_digest_created;
+ALTER TABLE digests DROP CONSTRAINT digests_owner_check;
+ALTER TABLE digests DROP COLUMN team_digest_id;
+ALTER TABLE digests ALTER COLUMN user_id SET NOT NULL;
+
+DROP TABLE IF EXISTS team_sync_states;
+DROP TABLE IF EXISTS team_digests;
+DROP TABLE IF EXISTS team_mailboxes;
+DROP TABLE IF EXISTS team_members;
+DROP TABLE IF EXISTS teams;
@@ -0,0 +1,71 @@
+CREATE TABLE teams (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE team_members (
+    team_id TEXT NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role TEXT NOT NULL,
+    deliver BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (team_id, user_id)
+);
+
+CREATE INDEX idx_team_members_user_id ON team_members (user_id);
+
+CREATE TABLE team_mailboxes (
+    id TEXT PRIMARY KEY,
+    team_id TEXT NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    username TEXT NOT NULL,
+    password TEXT NOT NULL,
+    domain TEXT NOT NULL,
+    port BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_team_mailboxes_team_id ON team_mailboxes (team_id);
+
+-- A mailbox cannot be deleted while a digest still reads it.
+CREATE TABLE team_digests (
+    id TEXT PRIMARY KEY,
+    team_id TEXT NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    mailbox_id TEXT NOT NULL REFERENCES team_mailboxes (id),
+    name TEXT NOT NULL,
+    folder TEXT NOT NULL DEFAULT '',
+    tags TEXT[],
+    black_list_senders TEXT[],
+    start_time TIMESTAMPTZ NOT NULL,
+    summary_count BIGINT NOT NULL DEFAULT 0,
+    update_interval TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_team_digests_team_id ON team_digests (team_id);
+
+CREATE TABLE team_sync_states (
+    digest_id TEXT NOT NULL REFERENCES team_digests (id) ON DELETE CASCADE,
+    folder TEXT NOT NULL,
+    uid_validity BIGINT NOT NULL DEFAULT 0,
+    last_uid BIGINT NOT NULL DEFAULT 0,
+    highest_mod_seq BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (digest_id, folder)
+);
+
+-- Digest history now belongs to either a user or a team digest.
+ALTER TABLE digests ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE digests ADD COLUMN team_digest_id TEXT REFERENCES team_digests (id) ON DELETE CASCADE;
+ALTER TABLE digests ADD CONSTRAINT digests_owner_check
+    CHECK ((user_id IS NULL) <> (team_digest_id IS NULL));
+
+CREATE INDEX idx_digests_team_digest_created ON digests (team_digest_id, created_at DESC);
+
+ALTER TABLE audit_events ADD COLUMN team_id TEXT NOT NULL DEFAULT '';
+CREATE INDEX idx_audit_events_team_id ON audit_events (team_id, created_at);
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN imap_oauth_token;
+ALTER TABLE users DROP COLUMN imap_oauth_provider;
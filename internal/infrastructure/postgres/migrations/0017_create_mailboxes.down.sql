@@ -0,0 +1,35 @@
+ALTER TABLE users ADD COLUMN domain TEXT;
+ALTER TABLE users ADD COLUMN port BIGINT;
+ALTER TABLE users ADD COLUMN folder TEXT;
+ALTER TABLE users ADD COLUMN imap_oauth_provider TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN imap_oauth_token TEXT NOT NULL DEFAULT '';
+
+-- Users get back the connection of their oldest mailbox; the others are
+-- dropped.
+UPDATE users SET
+    imap_password = CASE WHEN m.password <> '' THEN m.password ELSE users.imap_password END,
+    domain = m.domain,
+    port = m.port,
+    folder = m.folder,
+    imap_oauth_provider = m.oauth_provider,
+    imap_oauth_token = m.oauth_token
+FROM (
+    SELECT DISTINCT ON (user_id) *
+    FROM mailboxes
+    ORDER BY user_id, created_at, id
+) AS m
+WHERE m.user_id = users.id;
+
+ALTER TABLE sync_states DROP CONSTRAINT sync_states_mailbox_id_fkey;
+DELETE FROM sync_states s
+WHERE NOT EXISTS (
+    SELECT 1 FROM mailboxes m
+    WHERE m.id = s.mailbox_id
+      AND m.id = (SELECT id FROM mailboxes WHERE user_id = m.user_id ORDER BY created_at, id LIMIT 1)
+);
+UPDATE sync_states s SET mailbox_id = m.user_id FROM mailboxes m WHERE m.id = s.mailbox_id;
+ALTER TABLE sync_states RENAME COLUMN mailbox_id TO user_id;
+ALTER TABLE sync_states ADD CONSTRAINT sync_states_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+DROP TABLE mailboxes;
@@ -0,0 +1,44 @@
+CREATE TABLE mailboxes (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    username TEXT NOT NULL,
+    password TEXT NOT NULL DEFAULT '',
+    domain TEXT NOT NULL,
+    port BIGINT NOT NULL,
+    folder TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    oauth_provider TEXT NOT NULL DEFAULT '',
+    oauth_token TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_mailboxes_user_id ON mailboxes (user_id);
+
+-- Each user's single connection becomes their first mailbox. It reuses the
+-- user ID so existing sync state can follow it.
+INSERT INTO mailboxes (id, user_id, name, username, password, domain, port, folder, enabled,
+                       oauth_provider, oauth_token, created_at, updated_at)
+SELECT id, id, COALESCE(email, ''), COALESCE(email, ''), COALESCE(imap_password, ''),
+       domain, COALESCE(port, 0), COALESCE(folder, ''), TRUE,
+       imap_oauth_provider, imap_oauth_token,
+       COALESCE(created_at, NOW()), NOW()
+FROM users
+WHERE domain IS NOT NULL AND domain <> '';
+
+ALTER TABLE sync_states DROP CONSTRAINT sync_states_user_id_fkey;
+ALTER TABLE sync_states RENAME COLUMN user_id TO mailbox_id;
+DELETE FROM sync_states WHERE mailbox_id NOT IN (SELECT id FROM mailboxes);
+ALTER TABLE sync_states ADD CONSTRAINT sync_states_mailbox_id_fkey
+    FOREIGN KEY (mailbox_id) REFERENCES mailboxes (id) ON DELETE CASCADE;
+
+-- The IMAP credential stays on the user only where it is still the login
+-- password of a legacy account.
+UPDATE users SET imap_password = '' WHERE password_hash <> '';
+
+ALTER TABLE users DROP COLUMN domain;
+ALTER TABLE users DROP COLUMN port;
+ALTER TABLE users DROP COLUMN folder;
+ALTER TABLE users DROP COLUMN imap_oauth_provider;
+ALTER TABLE users DROP COLUMN imap_oauth_token;
@@ -0,0 +1,5 @@
+ALTER TABLE mailboxes ADD COLUMN folders TEXT[] NOT NULL DEFAULT '{}';
+
+UPDATE mailboxes SET folders = ARRAY[folder] WHERE folder <> '';
+
+ALTER TABLE mailboxes DROP COLUMN folder;
@@ -0,0 +1 @@
+ALTER TABLE mailboxes DROP COLUMN push;
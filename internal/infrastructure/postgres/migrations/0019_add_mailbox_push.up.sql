@@ -0,0 +1 @@
+ALTER TABLE mailboxes ADD COLUMN push BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,28 @@
+-- Team mailboxes move back to a table of their own. Their folders, push
+-- mode and OAuth2 links are dropped.
+CREATE TABLE team_mailboxes (
+    id TEXT PRIMARY KEY,
+    team_id TEXT NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    username TEXT NOT NULL,
+    password TEXT NOT NULL,
+    domain TEXT NOT NULL,
+    port BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_team_mailboxes_team_id ON team_mailboxes (team_id);
+
+INSERT INTO team_mailboxes (id, team_id, name, username, password, domain, port, created_at, updated_at)
+SELECT id, team_id, name, username, password, domain, port, created_at, updated_at
+FROM mailboxes
+WHERE team_id IS NOT NULL;
+
+ALTER TABLE team_digests DROP CONSTRAINT team_digests_mailbox_id_fkey;
+ALTER TABLE team_digests ADD CONSTRAINT team_digests_mailbox_id_fkey
+    FOREIGN KEY (mailbox_id) REFERENCES team_mailboxes (id);
+
+DELETE FROM mailboxes WHERE team_id IS NOT NULL;
+ALTER TABLE mailboxes DROP COLUMN team_id;
+ALTER TABLE mailboxes ALTER COLUMN user_id SET NOT NULL;
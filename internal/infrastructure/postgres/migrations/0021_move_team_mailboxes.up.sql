@@ -0,0 +1,19 @@
+-- Team mailboxes become rows of the shared mailboxes table, owned by a
+-- team instead of a user.
+ALTER TABLE mailboxes ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE mailboxes ADD COLUMN team_id TEXT REFERENCES teams (id) ON DELETE CASCADE;
+ALTER TABLE mailboxes ADD CONSTRAINT mailboxes_owner_check
+    CHECK ((user_id IS NULL) <> (team_id IS NULL));
+
+CREATE INDEX idx_mailboxes_team_id ON mailboxes (team_id);
+
+INSERT INTO mailboxes (id, team_id, name, username, password, domain, port, created_at, updated_at)
+SELECT id, team_id, name, username, password, domain, port, created_at, updated_at
+FROM team_mailboxes;
+
+-- A mailbox cannot be deleted while a digest still reads it.
+ALTER TABLE team_digests DROP CONSTRAINT team_digests_mailbox_id_fkey;
+ALTER TABLE team_digests ADD CONSTRAINT team_digests_mailbox_id_fkey
+    FOREIGN KEY (mailbox_id) REFERENCES mailboxes (id);
+
+DROP TABLE team_mailboxes;
@@ -2,16 +2,21 @@ package postgres
 
 import (
 	"context"
+	"embed"
 	"fmt"
+	"io/fs"
 	"log/slog"
 
 	"github.com/akhil-datla/maildruid/internal/config"
-	"github.com/akhil-datla/maildruid/internal/domain/user"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/migrate"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
 // DB wraps a GORM database connection.
 type DB struct {
 	db     *gorm.DB
@@ -31,9 +36,23 @@ func New(cfg config.DatabaseConfig, log *slog.Logger) (*DB, error) {
 	return &DB{db: db, logger: log}, nil
 }
 
-// Migrate runs auto-migrations for all domain models.
+// Migrator returns a migrator for the embedded PostgreSQL schema migrations.
+func (d *DB) Migrator() (*migrate.Migrator, error) {
+	files, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("loading migrations: %w", err)
+	}
+	return migrate.New(d.db, files)
+}
+
+// Migrate applies all pending schema migrations.
 func (d *DB) Migrate() error {
-	return d.db.AutoMigrate(&user.User{})
+	m, err := d.Migrator()
+	if err != nil {
+		return err
+	}
+	_, err = m.Up(context.Background())
+	return err
 }
 
 // Ping checks database connectivity.
@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/session"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SessionRepository implements session.Repository with PostgreSQL.
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository creates a new PostgreSQL-backed session repository.
+func NewSessionRepository(db *DB) *SessionRepository {
+	return &SessionRepository{db: db.GORM()}
+}
+
+func (r *SessionRepository) Create(ctx context.Context, s *session.Session) error {
+	if err := r.db.WithContext(ctx).Create(s).Error; err != nil {
+		return fmt.Errorf("creating session: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) FindByTokenHash(ctx context.Context, hash string) (*session.Session, error) {
+	var s session.Session
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&s).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, session.ErrNotFound
+		}
+		return nil, fmt.Errorf("finding session: %w", err)
+	}
+	return &s, nil
+}
+
+func (r *SessionRepository) Rotate(ctx context.Context, oldID string, at time.Time, next *session.Session) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&session.Session{}).
+			Where("id = ? AND revoked_at IS NULL", oldID).
+			Updates(map[string]any{"revoked_at": at, "replaced_by": next.ID})
+		if res.Error != nil {
+			return fmt.Errorf("revoking session: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return session.ErrRevoked
+		}
+		if err := tx.Create(next).Error; err != nil {
+			return fmt.Errorf("creating session: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *SessionRepository) Revoke(ctx context.Context, id string, at time.Time) error {
+	err := r.db.WithContext(ctx).Model(&session.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at).Error
+	if err != nil {
+		return fmt.Errorf("revoking session: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) RevokeAllForUser(ctx context.Context, userID string, at time.Time) error {
+	err := r.db.WithContext(ctx).Model(&session.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", at).Error
+	if err != nil {
+		return fmt.Errorf("revoking sessions: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) RevokeToken(ctx context.Context, t *session.RevokedToken) error {
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(t).Error
+	if err != nil {
+		return fmt.Errorf("revoking token: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) IsTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&session.RevokedToken{}).Where("token_id = ?", tokenID).Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("checking revoked token: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (r *SessionRepository) DeleteExpired(ctx context.Context, before time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", before).Delete(&session.Session{}).Error; err != nil {
+			return fmt.Errorf("deleting expired sessions: %w", err)
+		}
+		if err := tx.Where("expires_at < ?", before).Delete(&session.RevokedToken{}).Error; err != nil {
+			return fmt.Errorf("deleting expired revoked tokens: %w", err)
+		}
+		return nil
+	})
+}
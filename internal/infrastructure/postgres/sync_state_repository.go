@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/akhil-datla/maildruid/internal/domain/syncstate"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SyncStateRepository implements syncstate.Repository with PostgreSQL.
+type SyncStateRepository struct {
+	db *gorm.DB
+}
+
+// NewSyncStateRepository creates a new PostgreSQL-backed sync state repository.
+func NewSyncStateRepository(db *DB) *SyncStateRepository {
+	return &SyncStateRepository{db: db.GORM()}
+}
+
+func (r *SyncStateRepository) Get(ctx context.Context, mailboxID, folder string) (*syncstate.State, error) {
+	var s syncstate.State
+	err := r.db.WithContext(ctx).Where("mailbox_id = ? AND folder = ?", mailboxID, folder).First(&s).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, syncstate.ErrNotFound
+		}
+		return nil, fmt.Errorf("finding sync state: %w", err)
+	}
+	return &s, nil
+}
+
+func (r *SyncStateRepository) Save(ctx context.Context, s *syncstate.State) error {
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(s).Error
+	if err != nil {
+		return fmt.Errorf("saving sync state: %w", err)
+	}
+	return nil
+}
+
+func (r *SyncStateRepository) ListByMailbox(ctx context.Context, mailboxID string) ([]*syncstate.State, error) {
+	var states []*syncstate.State
+	if err := r.db.WithContext(ctx).Where("mailbox_id = ?", mailboxID).Order("folder").Find(&states).Error; err != nil {
+		return nil, fmt.Errorf("listing sync states: %w", err)
+	}
+	return states, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/akhil-datla/maildruid/internal/domain/user"
 	"gorm.io/gorm"
@@ -48,6 +49,17 @@ func (r *UserRepository) FindByEmail(_ context.Context, email string) (*user.Use
 	return &u, nil
 }
 
+func (r *UserRepository) FindByOIDCSubject(_ context.Context, subject string) (*user.User, error) {
+	var u user.User
+	if err := r.db.Where("oidc_subject = ? AND oidc_subject <> ''", subject).First(&u).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, user.ErrNotFound
+		}
+		return nil, fmt.Errorf("finding user by OIDC subject: %w", err)
+	}
+	return &u, nil
+}
+
 func (r *UserRepository) Update(_ context.Context, u *user.User) error {
 	if err := r.db.Save(u).Error; err != nil {
 		return fmt.Errorf("updating user: %w", err)
@@ -69,3 +81,27 @@ func (r *UserRepository) ListAll(_ context.Context) ([]*user.User, error) {
 	}
 	return users, nil
 }
+
+func (r *UserRepository) Search(_ context.Context, query string, limit, offset int) ([]*user.User, int64, error) {
+	pattern := likePattern(query)
+	scope := r.db.Model(&user.User{}).
+		Where(`LOWER(name) LIKE ? ESCAPE '\' OR LOWER(email) LIKE ? ESCAPE '\'`, pattern, pattern)
+
+	var total int64
+	if err := scope.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("counting users: %w", err)
+	}
+
+	var users []*user.User
+	if err := scope.Order("created_at, id").Limit(limit).Offset(offset).Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("searching users: %w", err)
+	}
+	return users, total, nil
+}
+
+// likePattern turns a search string into a lowercase LIKE pattern matching
+// it anywhere, with LIKE wildcards in the input escaped.
+func likePattern(query string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(query))
+	return "%" + escaped + "%"
+}
@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/akhil-datla/maildruid/internal/domain/verification"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// VerificationRepository implements verification.Repository with
+// PostgreSQL.
+type VerificationRepository struct {
+	db *gorm.DB
+}
+
+// NewVerificationRepository creates a new PostgreSQL-backed verification
+// repository.
+func NewVerificationRepository(db *DB) *VerificationRepository {
+	return &VerificationRepository{db: db.GORM()}
+}
+
+func (r *VerificationRepository) Save(ctx context.Context, v *verification.Verification) error {
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"email", "token_hash", "expires_at", "created_at"}),
+	}).Create(v).Error
+	if err != nil {
+		return fmt.Errorf("saving verification: %w", err)
+	}
+	return nil
+}
+
+func (r *VerificationRepository) FindByUser(ctx context.Context, userID string) (*verification.Verification, error) {
+	return r.find(ctx, "user_id = ?", userID)
+}
+
+func (r *VerificationRepository) FindByTokenHash(ctx context.Context, hash string) (*verification.Verification, error) {
+	return r.find(ctx, "token_hash = ?", hash)
+}
+
+func (r *VerificationRepository) find(ctx context.Context, query string, arg string) (*verification.Verification, error) {
+	var v verification.Verification
+	if err := r.db.WithContext(ctx).Where(query, arg).First(&v).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, verification.ErrNotFound
+		}
+		return nil, fmt.Errorf("finding verification: %w", err)
+	}
+	return &v, nil
+}
+
+func (r *VerificationRepository) Delete(ctx context.Context, userID string) error {
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&verification.Verification{}).Error; err != nil {
+		return fmt.Errorf("deleting verification: %w", err)
+	}
+	return nil
+}
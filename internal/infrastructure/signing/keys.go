@@ -0,0 +1,259 @@
+// Package signing signs and verifies access tokens. Tokens are signed with
+// RS256 or EdDSA keys identified by a "kid" header, so other services can
+// verify them with the public keys published as a JWK set. Without any
+// asymmetric keys, tokens fall back to HS256 with a shared secret.
+package signing
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSABits is the smallest RSA modulus accepted for signing.
+const minRSABits = 2048
+
+// Errors returned while verifying a token.
+var (
+	ErrUnknownKey      = errors.New("token signed with unknown key")
+	ErrUnexpectedAlg   = errors.New("unexpected signing method")
+	ErrUnsupportedType = errors.New("unsupported key type: must be RSA or Ed25519")
+)
+
+// Key is an asymmetric signing key. New tokens are signed with the newest
+// key whose ActiveFrom has passed; every key verifies tokens and is
+// published, so a key can be announced before it starts signing and kept
+// after it stops until the tokens it signed have expired.
+type Key struct {
+	ID         string
+	Private    crypto.Signer
+	ActiveFrom time.Time
+}
+
+type verifier struct {
+	method jwt.SigningMethod
+	public crypto.PublicKey
+}
+
+// KeySet holds the keys access tokens are signed and verified with.
+type KeySet struct {
+	keys    []Key // ordered by ActiveFrom
+	methods map[string]verifier
+	secret  []byte
+	now     func() time.Time
+}
+
+// NewHMAC creates a key set that signs and verifies HS256 tokens with
+// secret. It publishes no keys.
+func NewHMAC(secret []byte) *KeySet {
+	return &KeySet{secret: secret, now: time.Now}
+}
+
+// NewKeySet creates a key set from asymmetric keys. At least one key is
+// required, and key IDs must be unique.
+func NewKeySet(keys []Key) (*KeySet, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one signing key is required")
+	}
+	s := &KeySet{
+		keys:    slices.Clone(keys),
+		methods: make(map[string]verifier, len(keys)),
+		now:     time.Now,
+	}
+	for _, k := range s.keys {
+		if k.ID == "" {
+			return nil, errors.New("signing key ID must not be empty")
+		}
+		if _, dup := s.methods[k.ID]; dup {
+			return nil, fmt.Errorf("duplicate signing key ID %q", k.ID)
+		}
+		method, err := methodFor(k.Private)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", k.ID, err)
+		}
+		s.methods[k.ID] = verifier{method: method, public: k.Private.Public()}
+	}
+	slices.SortStableFunc(s.keys, func(a, b Key) int { return a.ActiveFrom.Compare(b.ActiveFrom) })
+	return s, nil
+}
+
+// current returns the key new tokens are signed with: the newest active
+// key, or the earliest one if none is active yet.
+func (s *KeySet) current() Key {
+	now := s.now()
+	cur := s.keys[0]
+	for _, k := range s.keys[1:] {
+		if k.ActiveFrom.After(now) {
+			break
+		}
+		cur = k
+	}
+	return cur
+}
+
+// SigningKeyID returns the ID of the key new tokens are signed with, or ""
+// for an HMAC key set.
+func (s *KeySet) SigningKeyID() string {
+	if len(s.keys) == 0 {
+		return ""
+	}
+	return s.current().ID
+}
+
+// Sign signs claims with the current key and sets the kid header.
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if len(s.keys) == 0 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+	}
+	k := s.current()
+	token := jwt.NewWithClaims(s.methods[k.ID].method, claims)
+	token.Header["kid"] = k.ID
+	return token.SignedString(k.Private)
+}
+
+// Keyfunc returns the key that verifies t, for use with jwt.Parse. Tokens
+// must name a known key in their kid header and use its algorithm.
+func (s *KeySet) Keyfunc(t *jwt.Token) (interface{}, error) {
+	if len(s.keys) == 0 {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrUnexpectedAlg
+		}
+		return s.secret, nil
+	}
+	kid, _ := t.Header["kid"].(string)
+	v, ok := s.methods[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if t.Method.Alg() != v.method.Alg() {
+		return nil, ErrUnexpectedAlg
+	}
+	return v.public, nil
+}
+
+// Methods lists the signing algorithms the key set accepts.
+func (s *KeySet) Methods() []string {
+	if len(s.keys) == 0 {
+		return []string{jwt.SigningMethodHS256.Alg()}
+	}
+	var algs []string
+	for _, v := range s.methods {
+		if !slices.Contains(algs, v.method.Alg()) {
+			algs = append(algs, v.method.Alg())
+		}
+	}
+	slices.Sort(algs)
+	return algs
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public half of every key, in activation order. An HMAC
+// key set has nothing to publish.
+func (s *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: make([]JWK, 0, len(s.keys))}
+	for _, k := range s.keys {
+		jwk := JWK{Use: "sig", Algorithm: s.methods[k.ID].method.Alg(), KeyID: k.ID}
+		switch pub := k.Private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+func methodFor(key crypto.Signer) (jwt.SigningMethod, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA key is %d bits, need at least %d", k.N.BitLen(), minRSABits)
+		}
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PrivateKey:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, ErrUnsupportedType
+	}
+}
+
+// ParsePrivateKey parses a PEM-encoded RSA or Ed25519 private key in PKCS #8
+// or, for RSA, PKCS #1 form.
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+	if _, err := methodFor(signer); err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// GenerateKey creates a new private key of the given algorithm ("EdDSA" or
+// "RS256") and returns it PEM-encoded in PKCS #8 form.
+func GenerateKey(alg string) ([]byte, error) {
+	var (
+		key crypto.Signer
+		err error
+	)
+	switch alg {
+	case jwt.SigningMethodEdDSA.Alg():
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	case jwt.SigningMethodRS256.Alg():
+		key, err = rsa.GenerateKey(rand.Reader, 3072)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q: must be EdDSA or RS256", alg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("generating key: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("encoding key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
@@ -0,0 +1,188 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func mustKey(t *testing.T, alg string) ed25519.PrivateKey {
+	t.Helper()
+	data, err := GenerateKey(alg)
+	if err != nil {
+		t.Fatalf("GenerateKey(%s): %v", alg, err)
+	}
+	key, err := ParsePrivateKey(data)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey: %v", err)
+	}
+	return key.(ed25519.PrivateKey)
+}
+
+func parse(s *KeySet, raw string) (*jwt.Token, error) {
+	return jwt.Parse(raw, s.Keyfunc, jwt.WithValidMethods(s.Methods()))
+}
+
+func TestSignAndVerifyEdDSA(t *testing.T) {
+	set, err := NewKeySet([]Key{{ID: "k1", Private: mustKey(t, "EdDSA")}})
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	raw, err := set.Sign(jwt.MapClaims{"sub": "u1"})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	tok, err := parse(set, raw)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if tok.Header["kid"] != "k1" || tok.Method.Alg() != "EdDSA" {
+		t.Errorf("unexpected header %v", tok.Header)
+	}
+}
+
+func TestSignAndVerifyRS256(t *testing.T) {
+	data, err := GenerateKey("RS256")
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	key, err := ParsePrivateKey(data)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey: %v", err)
+	}
+	set, err := NewKeySet([]Key{{ID: "rsa", Private: key}})
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	raw, err := set.Sign(jwt.MapClaims{"sub": "u1"})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if _, err := parse(set, raw); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	jwks := set.JWKS()
+	if len(jwks.Keys) != 1 || jwks.Keys[0].KeyType != "RSA" || jwks.Keys[0].Algorithm != "RS256" || jwks.Keys[0].E != "AQAB" {
+		t.Errorf("unexpected JWKS %+v", jwks)
+	}
+}
+
+func TestRollover(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	oldKey, newKey := mustKey(t, "EdDSA"), mustKey(t, "EdDSA")
+	set, err := NewKeySet([]Key{
+		{ID: "new", Private: newKey, ActiveFrom: now.Add(time.Hour)},
+		{ID: "old", Private: oldKey},
+	})
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	set.now = func() time.Time { return now }
+
+	if id := set.SigningKeyID(); id != "old" {
+		t.Fatalf("expected old key before rollover, got %q", id)
+	}
+	before, _ := set.Sign(jwt.MapClaims{"sub": "u1"})
+
+	// The upcoming key is published ahead of time.
+	if jwks := set.JWKS(); len(jwks.Keys) != 2 || jwks.Keys[0].KeyID != "old" || jwks.Keys[1].KeyID != "new" {
+		t.Fatalf("expected both keys published, got %+v", jwks)
+	}
+
+	now = now.Add(time.Hour)
+	if id := set.SigningKeyID(); id != "new" {
+		t.Fatalf("expected new key after rollover, got %q", id)
+	}
+	after, _ := set.Sign(jwt.MapClaims{"sub": "u1"})
+	for _, raw := range []string{before, after} {
+		if _, err := parse(set, raw); err != nil {
+			t.Errorf("expected token to verify across rollover: %v", err)
+		}
+	}
+}
+
+func TestKeyfuncRejections(t *testing.T) {
+	set, err := NewKeySet([]Key{{ID: "k1", Private: mustKey(t, "EdDSA")}})
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	other, _ := NewKeySet([]Key{{ID: "k2", Private: mustKey(t, "EdDSA")}})
+	foreign, _ := other.Sign(jwt.MapClaims{"sub": "u1"})
+	if _, err := parse(set, foreign); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+
+	// A token claiming a known kid but signed with HMAC over the public
+	// key must not verify.
+	pub := set.methods["k1"].public.(ed25519.PublicKey)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "u1"})
+	forged.Header["kid"] = "k1"
+	raw, _ := forged.SignedString([]byte(pub))
+	if _, err := parse(set, raw); err == nil {
+		t.Error("expected an HS256 token to be rejected")
+	}
+
+	hmac, _ := NewHMAC([]byte("secret")).Sign(jwt.MapClaims{"sub": "u1"})
+	if _, err := parse(set, hmac); err == nil {
+		t.Error("expected HMAC token to be rejected by an asymmetric key set")
+	}
+}
+
+func TestHMACFallback(t *testing.T) {
+	set := NewHMAC([]byte("secret"))
+	raw, err := set.Sign(jwt.MapClaims{"sub": "u1"})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if _, err := parse(set, raw); err != nil {
+		t.Errorf("parse: %v", err)
+	}
+	if _, err := parse(NewHMAC([]byte("other")), raw); err == nil {
+		t.Error("expected a token signed with another secret to be rejected")
+	}
+	if len(set.JWKS().Keys) != 0 || set.SigningKeyID() != "" {
+		t.Error("expected an HMAC key set to publish nothing")
+	}
+}
+
+func TestNewKeySetValidation(t *testing.T) {
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+	key := mustKey(t, "EdDSA")
+	tests := []struct {
+		name string
+		keys []Key
+	}{
+		{"empty", nil},
+		{"missing id", []Key{{Private: key}}},
+		{"duplicate id", []Key{{ID: "a", Private: key}, {ID: "a", Private: key}}},
+		{"small rsa", []Key{{ID: "a", Private: small}}},
+	}
+	for _, tt := range tests {
+		if _, err := NewKeySet(tt.keys); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestJWKSEd25519(t *testing.T) {
+	key := mustKey(t, "EdDSA")
+	set, _ := NewKeySet([]Key{{ID: "k1", Private: key}})
+	jwk := set.JWKS().Keys[0]
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		t.Fatalf("decoding x: %v", err)
+	}
+	if jwk.KeyType != "OKP" || jwk.Curve != "Ed25519" || jwk.Use != "sig" || string(x) != string(key.Public().(ed25519.PublicKey)) {
+		t.Errorf("unexpected JWK %+v", jwk)
+	}
+}
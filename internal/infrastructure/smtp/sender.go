@@ -3,6 +3,7 @@ package smtp
 import (
 	"crypto/tls"
 	"fmt"
+	"time"
 
 	"github.com/akhil-datla/maildruid/internal/config"
 	"github.com/matcornic/hermes/v2"
@@ -30,7 +31,8 @@ func New(cfg config.SMTPConfig) *Sender {
 }
 
 // SendSummary sends a summary email with an optional word cloud attachment.
-func (s *Sender) SendSummary(to, name string, tags []string, summary, wordCloudPath, errMsg string) error {
+// A non-empty note, such as an error message, is added after the summary.
+func (s *Sender) SendSummary(to, name string, tags []string, summary, wordCloudPath, note string) error {
 	m := gomail.NewMessage()
 	m.SetHeader("From", s.cfg.Email)
 	m.SetHeader("To", to)
@@ -43,8 +45,8 @@ func (s *Sender) SendSummary(to, name string, tags []string, summary, wordCloudP
 	if summary != "" {
 		intros = append(intros, summary)
 	}
-	if errMsg != "" {
-		intros = append(intros, errMsg)
+	if note != "" {
+		intros = append(intros, note)
 	}
 
 	email := hermes.Email{
@@ -67,7 +69,93 @@ func (s *Sender) SendSummary(to, name string, tags []string, summary, wordCloudP
 	if wordCloudPath != "" {
 		m.Embed(wordCloudPath)
 	}
+	return s.send(m)
+}
+
+// SendAccountUnlock emails a locked-out user a link that lifts the lockout
+// before it expires on its own.
+func (s *Sender) SendAccountUnlock(to, name, link string, until time.Time) error {
+	email := hermes.Email{
+		Body: hermes.Body{
+			Name:      name,
+			Signature: "Regards",
+			Intros: []string{
+				"Your MailDruid account has been temporarily locked after too many failed sign-in attempts.",
+				fmt.Sprintf("It will unlock automatically at %s.", until.UTC().Format("2006-01-02 15:04 MST")),
+			},
+			Actions: []hermes.Action{{
+				Instructions: "If these attempts were yours, you can unlock your account now:",
+				Button:       hermes.Button{Text: "Unlock account", Link: link},
+			}},
+			Outros: []string{
+				"If you did not try to sign in, consider changing your password once the lockout ends.",
+			},
+		},
+	}
+	return s.sendHTML(to, "MailDruid - Your account has been locked", email)
+}
+
+// SendEmailVerification emails a link that confirms the address may
+// receive digests.
+func (s *Sender) SendEmailVerification(to, name, link string, expires time.Time) error {
+	email := hermes.Email{
+		Body: hermes.Body{
+			Name:      name,
+			Signature: "Regards",
+			Intros: []string{
+				"A MailDruid account wants to send email digests to this address.",
+			},
+			Actions: []hermes.Action{{
+				Instructions: "To start receiving digests, confirm your address:",
+				Button:       hermes.Button{Text: "Confirm email address", Link: link},
+			}},
+			Outros: []string{
+				fmt.Sprintf("The link expires at %s.", expires.UTC().Format("2006-01-02 15:04 MST")),
+				"If you did not expect this email, ignore it and no digests will be sent.",
+			},
+		},
+	}
+	return s.sendHTML(to, "MailDruid - Confirm your email address", email)
+}
+
+// SendPasswordReset emails a link for choosing a new login password.
+func (s *Sender) SendPasswordReset(to, name, link string, expires time.Time) error {
+	email := hermes.Email{
+		Body: hermes.Body{
+			Name:      name,
+			Signature: "Regards",
+			Intros: []string{
+				"Someone asked to reset the password of your MailDruid account.",
+			},
+			Actions: []hermes.Action{{
+				Instructions: "To choose a new password, click the button below:",
+				Button:       hermes.Button{Text: "Reset password", Link: link},
+			}},
+			Outros: []string{
+				fmt.Sprintf("The link works once and expires at %s.", expires.UTC().Format("2006-01-02 15:04 MST")),
+				"If you did not ask for this, ignore this email; your password stays the same.",
+			},
+		},
+	}
+	return s.sendHTML(to, "MailDruid - Reset your password", email)
+}
+
+// sendHTML renders email with hermes and sends it to a single recipient.
+func (s *Sender) sendHTML(to, subject string, email hermes.Email) error {
+	body, err := s.hermes.GenerateHTML(email)
+	if err != nil {
+		return fmt.Errorf("generating email HTML: %w", err)
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.cfg.Email)
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", subject)
+	m.SetBody("text/html", body)
+	return s.send(m)
+}
 
+func (s *Sender) send(m *gomail.Message) error {
 	d := gomail.NewDialer(s.cfg.Host, s.cfg.Port, s.cfg.Email, s.cfg.Password)
 	d.TLSConfig = &tls.Config{ServerName: s.cfg.Host}
 
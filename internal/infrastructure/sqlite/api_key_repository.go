@@ -0,0 +1,119 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/apikey"
+	"gorm.io/gorm"
+)
+
+// apiKeyRow is the SQLite representation of apikey.APIKey.
+type apiKeyRow struct {
+	ID         string `gorm:"primaryKey"`
+	UserID     string
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     stringList `gorm:"type:text"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	LastUsedIP string
+	CreatedAt  time.Time
+}
+
+func (apiKeyRow) TableName() string { return "api_keys" }
+
+func toAPIKeyRow(k *apikey.APIKey) *apiKeyRow {
+	return &apiKeyRow{
+		ID:         k.ID,
+		UserID:     k.UserID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		KeyHash:    k.KeyHash,
+		Scopes:     stringList(k.Scopes),
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		LastUsedIP: k.LastUsedIP,
+		CreatedAt:  k.CreatedAt,
+	}
+}
+
+func (r *apiKeyRow) toAPIKey() *apikey.APIKey {
+	return &apikey.APIKey{
+		ID:         r.ID,
+		UserID:     r.UserID,
+		Name:       r.Name,
+		Prefix:     r.Prefix,
+		KeyHash:    r.KeyHash,
+		Scopes:     []string(r.Scopes),
+		ExpiresAt:  r.ExpiresAt,
+		LastUsedAt: r.LastUsedAt,
+		LastUsedIP: r.LastUsedIP,
+		CreatedAt:  r.CreatedAt,
+	}
+}
+
+// APIKeyRepository implements apikey.Repository with SQLite.
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository creates a new SQLite-backed API key repository.
+func NewAPIKeyRepository(db *DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db.GORM()}
+}
+
+func (r *APIKeyRepository) Create(ctx context.Context, k *apikey.APIKey) error {
+	row := toAPIKeyRow(k)
+	if err := r.db.WithContext(ctx).Create(row).Error; err != nil {
+		return fmt.Errorf("creating api key: %w", err)
+	}
+	k.CreatedAt = row.CreatedAt
+	return nil
+}
+
+func (r *APIKeyRepository) FindByHash(ctx context.Context, hash string) (*apikey.APIKey, error) {
+	var row apiKeyRow
+	if err := r.db.WithContext(ctx).Where("key_hash = ?", hash).First(&row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apikey.ErrNotFound
+		}
+		return nil, fmt.Errorf("finding api key: %w", err)
+	}
+	return row.toAPIKey(), nil
+}
+
+func (r *APIKeyRepository) ListByUser(ctx context.Context, userID string) ([]*apikey.APIKey, error) {
+	var rows []apiKeyRow
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("listing api keys: %w", err)
+	}
+	keys := make([]*apikey.APIKey, len(rows))
+	for i := range rows {
+		keys[i] = rows[i].toAPIKey()
+	}
+	return keys, nil
+}
+
+func (r *APIKeyRepository) Delete(ctx context.Context, userID, id string) error {
+	res := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&apiKeyRow{})
+	if res.Error != nil {
+		return fmt.Errorf("deleting api key: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return apikey.ErrNotFound
+	}
+	return nil
+}
+
+func (r *APIKeyRepository) Touch(ctx context.Context, id string, at time.Time, ip string) error {
+	err := r.db.WithContext(ctx).Model(&apiKeyRow{}).Where("id = ?", id).
+		Updates(map[string]any{"last_used_at": at, "last_used_ip": ip}).Error
+	if err != nil {
+		return fmt.Errorf("recording api key use: %w", err)
+	}
+	return nil
+}
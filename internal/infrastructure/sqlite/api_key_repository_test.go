@@ -0,0 +1,59 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/apikey"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+)
+
+func TestAPIKeyRepositoryRoundTrip(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewAPIKeyRepository(db)
+	ctx := context.Background()
+
+	if err := NewUserRepository(db).Create(ctx, &user.User{ID: "u1", Email: "u1@example.com"}); err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	k := &apikey.APIKey{
+		ID: "k1", UserID: "u1", Name: "cron", Prefix: "mdk_abcd", KeyHash: "hash",
+		Scopes: []string{apikey.ScopeReadOnly, apikey.ScopeSchedulesWrite}, ExpiresAt: &expires,
+	}
+	if err := repo.Create(ctx, k); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	used := time.Now().UTC()
+	if err := repo.Touch(ctx, "k1", used, "198.51.100.4"); err != nil {
+		t.Fatalf("Touch: %v", err)
+	}
+
+	got, err := repo.FindByHash(ctx, "hash")
+	if err != nil {
+		t.Fatalf("FindByHash: %v", err)
+	}
+	if len(got.Scopes) != 2 || got.Scopes[1] != apikey.ScopeSchedulesWrite {
+		t.Errorf("scopes did not round-trip: %v", got.Scopes)
+	}
+	if got.ExpiresAt == nil || !got.ExpiresAt.Equal(expires) {
+		t.Errorf("expiry did not round-trip: %v", got.ExpiresAt)
+	}
+	if got.LastUsedAt == nil || got.LastUsedIP != "198.51.100.4" {
+		t.Errorf("last use not recorded: %+v", got)
+	}
+
+	if err := repo.Delete(ctx, "u2", "k1"); err != apikey.ErrNotFound {
+		t.Errorf("expected ErrNotFound deleting another user's key, got %v", err)
+	}
+	if err := repo.Delete(ctx, "u1", "k1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	keys, _ := repo.ListByUser(ctx, "u1")
+	if len(keys) != 0 {
+		t.Errorf("expected no keys after delete, got %d", len(keys))
+	}
+}
@@ -0,0 +1,62 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"gorm.io/gorm"
+)
+
+// AuditRepository implements audit.Repository with SQLite. SQLite
+// compares DATETIME values as text, so all times are stored in UTC.
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditRepository creates a new SQLite-backed audit repository.
+func NewAuditRepository(db *DB) *AuditRepository {
+	return &AuditRepository{db: db.GORM()}
+}
+
+func (r *AuditRepository) Append(ctx context.Context, e *audit.Event) error {
+	e.CreatedAt = e.CreatedAt.UTC()
+	if err := r.db.WithContext(ctx).Create(e).Error; err != nil {
+		return fmt.Errorf("appending audit event: %w", err)
+	}
+	return nil
+}
+
+func (r *AuditRepository) List(ctx context.Context, f audit.Filter) ([]*audit.Event, int64, error) {
+	q := r.db.WithContext(ctx).Model(&audit.Event{})
+	if f.UserID != "" {
+		q = q.Where("user_id = ?", f.UserID)
+	}
+	if f.ActorID != "" {
+		q = q.Where("actor_id = ?", f.ActorID)
+	}
+	if f.TeamID != "" {
+		q = q.Where("team_id = ?", f.TeamID)
+	}
+	if f.Action != "" {
+		q = q.Where("action = ?", f.Action)
+	}
+	if !f.Since.IsZero() {
+		q = q.Where("created_at >= ?", f.Since.UTC())
+	}
+	if !f.Until.IsZero() {
+		q = q.Where("created_at < ?", f.Until.UTC())
+	}
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("counting audit events: %w", err)
+	}
+
+	var events []*audit.Event
+	err := q.Order("created_at DESC").Order("id DESC").Limit(f.Limit).Offset(f.Offset).Find(&events).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("listing audit events: %w", err)
+	}
+	return events, total, nil
+}
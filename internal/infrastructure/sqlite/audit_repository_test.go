@@ -0,0 +1,68 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+)
+
+func TestAuditRepositoryAppendAndList(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewAuditRepository(db)
+	ctx := context.Background()
+
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.FixedZone("EST", -5*3600))
+	for i, e := range []audit.Event{
+		{ID: "e1", UserID: "u1", Action: audit.ActionLoginSucceeded},
+		{ID: "e2", UserID: "u2", ActorID: "admin", Action: audit.ActionUserDisabled},
+		{ID: "e3", UserID: "u1", ActorID: "u1", Action: audit.ActionUserUpdated, IP: "10.0.0.1", RequestID: "req-1",
+			Changes: audit.Changes{{Field: "tags", Before: nil, After: []string{"invoice"}}}},
+	} {
+		e.CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		if err := repo.Append(ctx, &e); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	events, total, err := repo.List(ctx, audit.Filter{UserID: "u1", Limit: 10})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if total != 2 || len(events) != 2 || events[0].ID != "e3" || events[1].ID != "e1" {
+		t.Fatalf("expected e3 and e1 newest first, got %d %+v", total, events)
+	}
+	e := events[0]
+	if e.ActorID != "u1" || e.IP != "10.0.0.1" || e.RequestID != "req-1" || len(e.Changes) != 1 || e.Changes[0].Field != "tags" {
+		t.Errorf("fields did not round-trip: %+v", e)
+	}
+	if len(events[1].Changes) != 0 {
+		t.Errorf("expected no changes, got %+v", events[1].Changes)
+	}
+
+	events, total, err = repo.List(ctx, audit.Filter{Since: start.Add(time.Minute), Until: start.Add(2 * time.Minute), Limit: 10})
+	if err != nil || total != 1 || events[0].ID != "e2" {
+		t.Errorf("expected only e2 in the time range, got %d %+v %v", total, events, err)
+	}
+	events, _, err = repo.List(ctx, audit.Filter{ActorID: "admin", Action: audit.ActionUserDisabled, Limit: 10})
+	if err != nil || len(events) != 1 || events[0].ID != "e2" {
+		t.Errorf("expected e2 by actor and action, got %+v %v", events, err)
+	}
+}
+
+func TestAuditEventsAreAppendOnly(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewAuditRepository(db)
+	ctx := context.Background()
+
+	if err := repo.Append(ctx, &audit.Event{ID: "e1", UserID: "u1", Action: audit.ActionLoginSucceeded, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := db.GORM().Exec("UPDATE audit_events SET action = 'tampered'").Error; err == nil {
+		t.Error("expected updates to be rejected")
+	}
+	if err := db.GORM().Exec("DELETE FROM audit_events").Error; err == nil {
+		t.Error("expected deletes to be rejected")
+	}
+}
@@ -0,0 +1,153 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/summary"
+	"gorm.io/gorm"
+)
+
+// digestRow is the SQLite representation of summary.Digest. The owner
+// columns are pointers so an empty owner is stored as NULL.
+type digestRow struct {
+	ID           string `gorm:"primaryKey"`
+	UserID       *string
+	TeamDigestID *string
+	Summary      string
+	Keywords     stringList `gorm:"type:text"`
+	WordCloud    []byte
+	Tags         stringList `gorm:"type:text"`
+	EmailCount   int
+	Truncated    int
+	Skipped      int
+	FirstUID     int
+	LastUID      int
+	Folders      []summary.FolderRange `gorm:"serializer:json"`
+	Trigger      string
+	StartedAt    time.Time
+	CreatedAt    time.Time
+}
+
+func (digestRow) TableName() string { return "digests" }
+
+func toDigestRow(d *summary.Digest) *digestRow {
+	return &digestRow{
+		ID:           d.ID,
+		UserID:       nullString(d.UserID),
+		TeamDigestID: nullString(d.TeamDigestID),
+		Summary:      d.Summary,
+		Keywords:     stringList(d.Keywords),
+		WordCloud:    d.WordCloud,
+		Tags:         stringList(d.Tags),
+		EmailCount:   d.EmailCount,
+		Truncated:    d.Truncated,
+		Skipped:      d.Skipped,
+		FirstUID:     d.FirstUID,
+		LastUID:      d.LastUID,
+		Folders:      d.Folders,
+		Trigger:      string(d.Trigger),
+		StartedAt:    d.StartedAt,
+		CreatedAt:    d.CreatedAt,
+	}
+}
+
+func (r *digestRow) toDigest() *summary.Digest {
+	return &summary.Digest{
+		ID:           r.ID,
+		UserID:       derefString(r.UserID),
+		TeamDigestID: derefString(r.TeamDigestID),
+		Summary:      r.Summary,
+		Keywords:     []string(r.Keywords),
+		WordCloud:    r.WordCloud,
+		Tags:         []string(r.Tags),
+		EmailCount:   r.EmailCount,
+		Truncated:    r.Truncated,
+		Skipped:      r.Skipped,
+		FirstUID:     r.FirstUID,
+		LastUID:      r.LastUID,
+		Folders:      r.Folders,
+		Trigger:      summary.Trigger(r.Trigger),
+		StartedAt:    r.StartedAt,
+		CreatedAt:    r.CreatedAt,
+	}
+}
+
+// DigestRepository implements summary.Repository with SQLite.
+type DigestRepository struct {
+	db *gorm.DB
+}
+
+// NewDigestRepository creates a new SQLite-backed digest repository.
+func NewDigestRepository(db *DB) *DigestRepository {
+	return &DigestRepository{db: db.GORM()}
+}
+
+func (r *DigestRepository) Create(ctx context.Context, d *summary.Digest) error {
+	row := toDigestRow(d)
+	if err := r.db.WithContext(ctx).Create(row).Error; err != nil {
+		return fmt.Errorf("creating digest: %w", err)
+	}
+	d.CreatedAt = row.CreatedAt
+	return nil
+}
+
+func (r *DigestRepository) FindByID(ctx context.Context, userID, id string) (*summary.Digest, error) {
+	var row digestRow
+	err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&row).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, summary.ErrDigestNotFound
+		}
+		return nil, fmt.Errorf("finding digest: %w", err)
+	}
+	return row.toDigest(), nil
+}
+
+func (r *DigestRepository) ListByUser(ctx context.Context, userID string, limit, offset int) ([]*summary.Digest, int64, error) {
+	return r.list(ctx, "user_id = ?", userID, limit, offset)
+}
+
+func (r *DigestRepository) FindByTeamDigest(ctx context.Context, teamDigestID, id string) (*summary.Digest, error) {
+	var row digestRow
+	err := r.db.WithContext(ctx).Where("id = ? AND team_digest_id = ?", id, teamDigestID).First(&row).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, summary.ErrDigestNotFound
+		}
+		return nil, fmt.Errorf("finding digest: %w", err)
+	}
+	return row.toDigest(), nil
+}
+
+func (r *DigestRepository) ListByTeamDigest(ctx context.Context, teamDigestID string, limit, offset int) ([]*summary.Digest, int64, error) {
+	return r.list(ctx, "team_digest_id = ?", teamDigestID, limit, offset)
+}
+
+func (r *DigestRepository) list(ctx context.Context, where, owner string, limit, offset int) ([]*summary.Digest, int64, error) {
+	var total int64
+	err := r.db.WithContext(ctx).Model(&digestRow{}).Where(where, owner).Count(&total).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("counting digests: %w", err)
+	}
+
+	var rows []*digestRow
+	err = r.db.WithContext(ctx).
+		Omit("word_cloud").
+		Where(where, owner).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&rows).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("listing digests: %w", err)
+	}
+
+	digests := make([]*summary.Digest, 0, len(rows))
+	for _, row := range rows {
+		digests = append(digests, row.toDigest())
+	}
+	return digests, total, nil
+}
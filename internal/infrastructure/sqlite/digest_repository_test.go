@@ -0,0 +1,105 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/summary"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+)
+
+func TestDigestRepositoryListAndFind(t *testing.T) {
+	db := setupTestDB(t)
+	users := NewUserRepository(db)
+	repo := NewDigestRepository(db)
+	ctx := context.Background()
+
+	for _, id := range []string{"u1", "u2"} {
+		if err := users.Create(ctx, &user.User{ID: id, Email: id + "@example.com"}); err != nil {
+			t.Fatalf("creating user: %v", err)
+		}
+	}
+
+	base := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	for i, id := range []string{"d1", "d2", "d3"} {
+		err := repo.Create(ctx, &summary.Digest{
+			ID:         id,
+			UserID:     "u1",
+			Summary:    "summary " + id,
+			Keywords:   []string{"budget", "q4"},
+			WordCloud:  []byte{0x89, 'P', 'N', 'G'},
+			Tags:       []string{"report"},
+			EmailCount: i + 1,
+			Skipped:    2,
+			FirstUID:   10,
+			LastUID:    20,
+			Folders:    []summary.FolderRange{{MailboxID: "mb1", Folder: "INBOX", FirstUID: 10, LastUID: 20}},
+			Trigger:    summary.TriggerScheduled,
+			StartedAt:  base,
+			CreatedAt:  base.Add(time.Duration(i) * time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	if err := repo.Create(ctx, &summary.Digest{ID: "other", UserID: "u2", Trigger: summary.TriggerManual, CreatedAt: base}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	page, total, err := repo.ListByUser(ctx, "u1", 2, 0)
+	if err != nil {
+		t.Fatalf("ListByUser: %v", err)
+	}
+	if total != 3 {
+		t.Errorf("expected total 3, got %d", total)
+	}
+	if len(page) != 2 || page[0].ID != "d3" || page[1].ID != "d2" {
+		t.Fatalf("expected [d3 d2], got %v", page)
+	}
+	if page[0].WordCloud != nil {
+		t.Error("list should not load word cloud images")
+	}
+
+	page, _, _ = repo.ListByUser(ctx, "u1", 2, 2)
+	if len(page) != 1 || page[0].ID != "d1" {
+		t.Errorf("expected [d1] on second page, got %v", page)
+	}
+
+	d, err := repo.FindByID(ctx, "u1", "d2")
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if len(d.WordCloud) != 4 || len(d.Keywords) != 2 || d.Trigger != summary.TriggerScheduled || d.LastUID != 20 || d.Skipped != 2 {
+		t.Errorf("unexpected digest: %+v", d)
+	}
+
+	if len(d.Folders) != 1 || d.Folders[0] != (summary.FolderRange{MailboxID: "mb1", Folder: "INBOX", FirstUID: 10, LastUID: 20}) {
+		t.Errorf("unexpected folder ranges: %+v", d.Folders)
+	}
+
+	if _, err := repo.FindByID(ctx, "u2", "d2"); err != summary.ErrDigestNotFound {
+		t.Errorf("expected ErrDigestNotFound for other user, got %v", err)
+	}
+}
+
+func TestDigestsDeletedWithUser(t *testing.T) {
+	db := setupTestDB(t)
+	users := NewUserRepository(db)
+	repo := NewDigestRepository(db)
+	ctx := context.Background()
+
+	if err := users.Create(ctx, &user.User{ID: "u1", Email: "u1@example.com"}); err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	if err := repo.Create(ctx, &summary.Digest{ID: "d1", UserID: "u1", Trigger: summary.TriggerManual}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := users.Delete(ctx, "u1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if _, total, _ := repo.ListByUser(ctx, "u1", 10, 0); total != 0 {
+		t.Errorf("expected digests to be deleted with user, got %d", total)
+	}
+}
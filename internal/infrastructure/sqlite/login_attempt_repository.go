@@ -0,0 +1,104 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/lockout"
+	"gorm.io/gorm"
+)
+
+// LoginAttemptRepository implements lockout.Repository with SQLite. SQLite
+// compares DATETIME values as text, so all times are stored in UTC.
+type LoginAttemptRepository struct {
+	db *gorm.DB
+}
+
+// NewLoginAttemptRepository creates a new SQLite-backed login attempt
+// repository.
+func NewLoginAttemptRepository(db *DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db.GORM()}
+}
+
+// recordFailureSQL counts a failure in a single statement so concurrent
+// attempts on different instances cannot lose updates.
+const recordFailureSQL = `
+INSERT INTO login_attempts (subject, failures, last_failure_at, locked_until, unlock_token_hash, updated_at)
+VALUES (@subject, 1, @now, NULL, '', @now)
+ON CONFLICT (subject) DO UPDATE SET
+    failures = CASE
+        WHEN login_attempts.last_failure_at < @since OR login_attempts.locked_until <= @now THEN 1
+        ELSE login_attempts.failures + 1
+    END,
+    locked_until = CASE WHEN login_attempts.locked_until <= @now THEN NULL ELSE login_attempts.locked_until END,
+    unlock_token_hash = CASE WHEN login_attempts.locked_until <= @now THEN '' ELSE login_attempts.unlock_token_hash END,
+    last_failure_at = excluded.last_failure_at,
+    updated_at = excluded.updated_at
+RETURNING *`
+
+func (r *LoginAttemptRepository) Find(ctx context.Context, subject string) (*lockout.Counter, error) {
+	var c lockout.Counter
+	if err := r.db.WithContext(ctx).Where("subject = ?", subject).First(&c).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, lockout.ErrNotFound
+		}
+		return nil, fmt.Errorf("finding login attempts: %w", err)
+	}
+	return &c, nil
+}
+
+func (r *LoginAttemptRepository) RecordFailure(ctx context.Context, subject string, now, since time.Time) (*lockout.Counter, error) {
+	var c lockout.Counter
+	err := r.db.WithContext(ctx).Raw(recordFailureSQL, map[string]any{
+		"subject": subject,
+		"now":     now.UTC(),
+		"since":   since.UTC(),
+	}).Scan(&c).Error
+	if err != nil {
+		return nil, fmt.Errorf("recording failed login: %w", err)
+	}
+	return &c, nil
+}
+
+func (r *LoginAttemptRepository) Lock(ctx context.Context, subject string, until time.Time, tokenHash string) error {
+	res := r.db.WithContext(ctx).Model(&lockout.Counter{}).
+		Where("subject = ?", subject).
+		Updates(map[string]any{"locked_until": until.UTC(), "unlock_token_hash": tokenHash})
+	if res.Error != nil {
+		return fmt.Errorf("locking %s: %w", subject, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return lockout.ErrNotFound
+	}
+	return nil
+}
+
+func (r *LoginAttemptRepository) FindByUnlockToken(ctx context.Context, tokenHash string) (*lockout.Counter, error) {
+	if tokenHash == "" {
+		return nil, lockout.ErrNotFound
+	}
+	var c lockout.Counter
+	if err := r.db.WithContext(ctx).Where("unlock_token_hash = ?", tokenHash).First(&c).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, lockout.ErrNotFound
+		}
+		return nil, fmt.Errorf("finding unlock token: %w", err)
+	}
+	return &c, nil
+}
+
+func (r *LoginAttemptRepository) Reset(ctx context.Context, subject string) error {
+	if err := r.db.WithContext(ctx).Where("subject = ?", subject).Delete(&lockout.Counter{}).Error; err != nil {
+		return fmt.Errorf("resetting login attempts: %w", err)
+	}
+	return nil
+}
+
+func (r *LoginAttemptRepository) DeleteStale(ctx context.Context, before time.Time) error {
+	if err := r.db.WithContext(ctx).Where("updated_at < ?", before.UTC()).Delete(&lockout.Counter{}).Error; err != nil {
+		return fmt.Errorf("deleting stale login attempts: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/lockout"
+)
+
+func TestLoginAttemptRepositoryRecordFailure(t *testing.T) {
+	repo := NewLoginAttemptRepository(setupTestDB(t))
+	ctx := context.Background()
+
+	now := time.Now()
+	for i := 1; i <= 3; i++ {
+		c, err := repo.RecordFailure(ctx, "account:a@example.com", now, now.Add(-time.Minute))
+		if err != nil {
+			t.Fatalf("RecordFailure: %v", err)
+		}
+		if c.Failures != i {
+			t.Fatalf("expected %d failures, got %d", i, c.Failures)
+		}
+	}
+
+	// A failure after the window starts the count over.
+	later := now.Add(time.Hour)
+	c, err := repo.RecordFailure(ctx, "account:a@example.com", later, later.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("RecordFailure: %v", err)
+	}
+	if c.Failures != 1 {
+		t.Errorf("expected count to restart, got %d", c.Failures)
+	}
+}
+
+func TestLoginAttemptRepositoryLockAndUnlock(t *testing.T) {
+	repo := NewLoginAttemptRepository(setupTestDB(t))
+	ctx := context.Background()
+
+	now := time.Now()
+	if err := repo.Lock(ctx, "account:a@example.com", now, "h"); err != lockout.ErrNotFound {
+		t.Fatalf("expected ErrNotFound locking an unknown subject, got %v", err)
+	}
+	if _, err := repo.RecordFailure(ctx, "account:a@example.com", now, now.Add(-time.Minute)); err != nil {
+		t.Fatalf("RecordFailure: %v", err)
+	}
+	if err := repo.Lock(ctx, "account:a@example.com", now.Add(time.Minute), "h"); err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+
+	c, err := repo.FindByUnlockToken(ctx, "h")
+	if err != nil {
+		t.Fatalf("FindByUnlockToken: %v", err)
+	}
+	if !c.Locked(now) || c.Locked(now.Add(time.Minute)) {
+		t.Errorf("unexpected lock state: %+v", c)
+	}
+	if _, err := repo.FindByUnlockToken(ctx, ""); err != lockout.ErrNotFound {
+		t.Errorf("expected ErrNotFound for an empty token, got %v", err)
+	}
+
+	// Failing after the lock expires clears it.
+	after := now.Add(2 * time.Minute)
+	c, err = repo.RecordFailure(ctx, "account:a@example.com", after, now.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("RecordFailure: %v", err)
+	}
+	if c.Failures != 1 || c.LockedUntil != nil || c.UnlockTokenHash != "" {
+		t.Errorf("expected expired lock to be cleared, got %+v", c)
+	}
+
+	if err := repo.DeleteStale(ctx, after.Add(time.Second)); err != nil {
+		t.Fatalf("DeleteStale: %v", err)
+	}
+	if _, err := repo.Find(ctx, "account:a@example.com"); err != lockout.ErrNotFound {
+		t.Errorf("expected stale counter to be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/mailbox"
+	"gorm.io/gorm"
+)
+
+// mailboxRow is the SQLite representation of mailbox.Mailbox.
+type mailboxRow struct {
+	ID            string `gorm:"primaryKey"`
+	UserID        *string
+	TeamID        *string
+	Name          string
+	Username      string
+	Password      string
+	Domain        string
+	Port          int
+	Enabled       bool
+	Push          bool
+	Folders       stringList `gorm:"type:text"`
+	OAuthProvider string     `gorm:"column:oauth_provider"`
+	OAuthToken    string     `gorm:"column:oauth_token"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (mailboxRow) TableName() string { return "mailboxes" }
+
+func toMailboxRow(mb *mailbox.Mailbox) *mailboxRow {
+	return &mailboxRow{
+		ID:            mb.ID,
+		UserID:        nullString(mb.UserID),
+		TeamID:        nullString(mb.TeamID),
+		Name:          mb.Name,
+		Username:      mb.Username,
+		Password:      mb.Password,
+		Domain:        mb.Domain,
+		Port:          mb.Port,
+		Enabled:       mb.Enabled,
+		Push:          mb.Push,
+		Folders:       stringList(mb.Folders),
+		OAuthProvider: mb.OAuthProvider,
+		OAuthToken:    mb.OAuthToken,
+		CreatedAt:     mb.CreatedAt,
+		UpdatedAt:     mb.UpdatedAt,
+	}
+}
+
+func (r *mailboxRow) toMailbox() *mailbox.Mailbox {
+	return &mailbox.Mailbox{
+		ID:            r.ID,
+		UserID:        derefString(r.UserID),
+		TeamID:        derefString(r.TeamID),
+		Name:          r.Name,
+		Username:      r.Username,
+		Password:      r.Password,
+		Domain:        r.Domain,
+		Port:          r.Port,
+		Enabled:       r.Enabled,
+		Push:          r.Push,
+		Folders:       []string(r.Folders),
+		OAuthProvider: r.OAuthProvider,
+		OAuthToken:    r.OAuthToken,
+		CreatedAt:     r.CreatedAt,
+		UpdatedAt:     r.UpdatedAt,
+	}
+}
+
+// MailboxRepository implements mailbox.Repository with SQLite.
+type MailboxRepository struct {
+	db *gorm.DB
+}
+
+// NewMailboxRepository creates a new SQLite-backed mailbox repository.
+func NewMailboxRepository(db *DB) *MailboxRepository {
+	return &MailboxRepository{db: db.GORM()}
+}
+
+func (r *MailboxRepository) Create(ctx context.Context, mb *mailbox.Mailbox) error {
+	row := toMailboxRow(mb)
+	if err := r.db.WithContext(ctx).Create(row).Error; err != nil {
+		return fmt.Errorf("creating mailbox: %w", err)
+	}
+	mb.CreatedAt, mb.UpdatedAt = row.CreatedAt, row.UpdatedAt
+	return nil
+}
+
+func (r *MailboxRepository) FindByID(ctx context.Context, owner mailbox.Owner, id string) (*mailbox.Mailbox, error) {
+	var row mailboxRow
+	if err := r.db.WithContext(ctx).Scopes(ownedBy(owner)).Where("id = ?", id).First(&row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, mailbox.ErrNotFound
+		}
+		return nil, fmt.Errorf("finding mailbox: %w", err)
+	}
+	return row.toMailbox(), nil
+}
+
+func (r *MailboxRepository) Update(ctx context.Context, mb *mailbox.Mailbox) error {
+	row := toMailboxRow(mb)
+	if err := r.db.WithContext(ctx).Save(row).Error; err != nil {
+		return fmt.Errorf("updating mailbox: %w", err)
+	}
+	mb.UpdatedAt = row.UpdatedAt
+	return nil
+}
+
+func (r *MailboxRepository) Delete(ctx context.Context, owner mailbox.Owner, id string) error {
+	err := r.db.WithContext(ctx).Scopes(ownedBy(owner)).Where("id = ?", id).Delete(&mailboxRow{}).Error
+	if err != nil {
+		return fmt.Errorf("deleting mailbox: %w", err)
+	}
+	return nil
+}
+
+func (r *MailboxRepository) ListByOwner(ctx context.Context, owner mailbox.Owner) ([]*mailbox.Mailbox, error) {
+	return r.list(r.db.WithContext(ctx).Scopes(ownedBy(owner)).Order("created_at, id"))
+}
+
+func (r *MailboxRepository) ListPush(ctx context.Context) ([]*mailbox.Mailbox, error) {
+	return r.list(r.db.WithContext(ctx).Where("enabled AND push").Order("created_at, id"))
+}
+
+func (r *MailboxRepository) ListAll(ctx context.Context) ([]*mailbox.Mailbox, error) {
+	return r.list(r.db.WithContext(ctx))
+}
+
+func (r *MailboxRepository) list(q *gorm.DB) ([]*mailbox.Mailbox, error) {
+	var rows []mailboxRow
+	if err := q.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("listing mailboxes: %w", err)
+	}
+	mailboxes := make([]*mailbox.Mailbox, 0, len(rows))
+	for i := range rows {
+		mailboxes = append(mailboxes, rows[i].toMailbox())
+	}
+	return mailboxes, nil
+}
+
+// ownedBy restricts a query to the mailboxes of a user or team.
+func ownedBy(owner mailbox.Owner) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if owner.TeamID != "" {
+			return db.Where("team_id = ?", owner.TeamID)
+		}
+		return db.Where("user_id = ?", owner.UserID)
+	}
+}
@@ -0,0 +1,155 @@
+package sqlite
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/config"
+	"github.com/akhil-datla/maildruid/internal/domain/mailbox"
+	"github.com/akhil-datla/maildruid/internal/domain/syncstate"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+)
+
+func TestMailboxRepositoryRoundTrip(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewMailboxRepository(db)
+	ctx := context.Background()
+
+	for _, id := range []string{"u1", "u2"} {
+		if err := NewUserRepository(db).Create(ctx, &user.User{ID: id, Email: id + "@example.com"}); err != nil {
+			t.Fatalf("creating user: %v", err)
+		}
+	}
+
+	now := time.Now()
+	work := &mailbox.Mailbox{ID: "m1", UserID: "u1", Name: "Work", Username: "u1@work.example", Password: "sealed",
+		Domain: "imap.work.example", Port: 993, Enabled: true, CreatedAt: now}
+	personal := &mailbox.Mailbox{ID: "m2", UserID: "u1", Name: "Personal", Username: "u1@home.example",
+		Domain: "imap.home.example", Port: 993, Folders: []string{"Lists/*", "INBOX"}, CreatedAt: now.Add(time.Second)}
+	for _, mb := range []*mailbox.Mailbox{personal, work} {
+		if err := repo.Create(ctx, mb); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	got, err := repo.FindByID(ctx, mailbox.UserOwner("u1"), "m2")
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if len(got.Folders) != 2 || got.Folders[0] != "Lists/*" || got.Enabled {
+		t.Errorf("unexpected mailbox: %+v", got)
+	}
+	if _, err := repo.FindByID(ctx, mailbox.UserOwner("u2"), "m2"); err != mailbox.ErrNotFound {
+		t.Errorf("expected ErrNotFound for another user's mailbox, got %v", err)
+	}
+
+	got.Enabled, got.Push = true, true
+	if err := repo.Update(ctx, got); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if watched, err := repo.ListPush(ctx); err != nil || len(watched) != 1 || watched[0].ID != "m2" {
+		t.Errorf("expected only m2 in push mode, got %+v, err %v", watched, err)
+	}
+	list, err := repo.ListByOwner(ctx, mailbox.UserOwner("u1"))
+	if err != nil {
+		t.Fatalf("ListByOwner: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != "m1" || !list[1].Enabled {
+		t.Fatalf("expected both mailboxes oldest first, got %+v", list)
+	}
+
+	states := NewSyncStateRepository(db)
+	if err := states.Save(ctx, &syncstate.State{MailboxID: "m1", Folder: "INBOX", LastUID: 5}); err != nil {
+		t.Fatalf("saving sync state: %v", err)
+	}
+	if err := repo.Delete(ctx, mailbox.UserOwner("u2"), "m1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := repo.FindByID(ctx, mailbox.UserOwner("u1"), "m1"); err != nil {
+		t.Fatalf("another user must not delete the mailbox: %v", err)
+	}
+	if err := repo.Delete(ctx, mailbox.UserOwner("u1"), "m1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := states.Get(ctx, "m1", "INBOX"); err != syncstate.ErrNotFound {
+		t.Errorf("expected sync state to be deleted with the mailbox, got %v", err)
+	}
+}
+
+func TestMailboxMigrationMovesUserConnection(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	db, err := New(config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")}, logger)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer db.Close()
+
+	m, err := db.Migrator()
+	if err != nil {
+		t.Fatalf("Migrator: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := m.To(ctx, 16); err != nil {
+		t.Fatalf("migrating to 16: %v", err)
+	}
+
+	err = db.GORM().Exec(`INSERT INTO users (id, email, password_hash, imap_password, domain, port, folder,
+		imap_oauth_provider, imap_oauth_token, created_at) VALUES
+		('a', 'a@example.com', 'hash', 'sealed-a', 'imap.example.com', 993, 'Reports', '', '', CURRENT_TIMESTAMP),
+		('b', 'b@example.com', '', 'sealed-b', 'imap.example.com', 143, '', 'google', 'token-b', CURRENT_TIMESTAMP),
+		('c', 'c@example.com', 'hash', '', '', 0, '', '', '', CURRENT_TIMESTAMP)`).Error
+	if err != nil {
+		t.Fatalf("seeding users: %v", err)
+	}
+	err = db.GORM().Exec(`INSERT INTO sync_states (user_id, folder, last_uid, updated_at) VALUES
+		('a', 'Reports', 42, CURRENT_TIMESTAMP)`).Error
+	if err != nil {
+		t.Fatalf("seeding sync state: %v", err)
+	}
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	repo := NewMailboxRepository(db)
+	a, err := repo.FindByID(ctx, mailbox.UserOwner("a"), "a")
+	if err != nil {
+		t.Fatalf("user a: %v", err)
+	}
+	if a.Username != "a@example.com" || a.Password != "sealed-a" || a.Domain != "imap.example.com" ||
+		a.Port != 993 || len(a.Folders) != 1 || a.Folders[0] != "Reports" || !a.Enabled {
+		t.Errorf("user a: unexpected mailbox %+v", a)
+	}
+	b, err := repo.FindByID(ctx, mailbox.UserOwner("b"), "b")
+	if err != nil || b.OAuthProvider != "google" || b.OAuthToken != "token-b" || b.Port != 143 || len(b.Folders) != 0 {
+		t.Errorf("user b: unexpected mailbox %+v, err %v", b, err)
+	}
+	if list, _ := repo.ListByOwner(ctx, mailbox.UserOwner("c")); len(list) != 0 {
+		t.Errorf("user c has no connection and should get no mailbox, got %+v", list)
+	}
+
+	state, err := NewSyncStateRepository(db).Get(ctx, "a", "Reports")
+	if err != nil || state.LastUID != 42 {
+		t.Errorf("expected sync state to follow the mailbox, got %+v, err %v", state, err)
+	}
+
+	users := NewUserRepository(db)
+	if u, _ := users.FindByID(ctx, "a"); u.IMAPPassword != "" {
+		t.Errorf("user a has a login password and should not keep the IMAP credential")
+	}
+	if u, _ := users.FindByID(ctx, "b"); u.IMAPPassword != "sealed-b" {
+		t.Errorf("legacy user b should keep the IMAP credential to sign in")
+	}
+
+	if _, err := m.To(ctx, 16); err != nil {
+		t.Fatalf("migrating down to 16: %v", err)
+	}
+	var folder string
+	if err := db.GORM().Raw(`SELECT folder FROM users WHERE id = 'a'`).Scan(&folder).Error; err != nil || folder != "Reports" {
+		t.Errorf("down migration should restore the folder, got %q, err %v", folder, err)
+	}
+}
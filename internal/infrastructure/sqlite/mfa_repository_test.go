@@ -0,0 +1,70 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/mfa"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+)
+
+func TestMFARepositoryRoundTrip(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewMFARepository(db)
+	ctx := context.Background()
+
+	if err := NewUserRepository(db).Create(ctx, &user.User{ID: "u1", Email: "u1@example.com"}); err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	if _, err := repo.FindCredential(ctx, "u1"); err != mfa.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := repo.SaveCredential(ctx, &mfa.Credential{UserID: "u1", Secret: "sealed"}); err != nil {
+		t.Fatalf("SaveCredential: %v", err)
+	}
+	if err := repo.SaveCredential(ctx, &mfa.Credential{UserID: "u1", Secret: "sealed", Enabled: true}); err != nil {
+		t.Fatalf("SaveCredential (update): %v", err)
+	}
+
+	if ok, err := repo.AdvanceStep(ctx, "u1", 10); err != nil || !ok {
+		t.Fatalf("AdvanceStep: ok=%v err=%v", ok, err)
+	}
+	if ok, _ := repo.AdvanceStep(ctx, "u1", 10); ok {
+		t.Error("AdvanceStep should refuse a step that was already used")
+	}
+
+	locked := time.Now().Add(time.Minute)
+	if err := repo.UpdateAttempts(ctx, "u1", 3, &locked); err != nil {
+		t.Fatalf("UpdateAttempts: %v", err)
+	}
+	got, err := repo.FindCredential(ctx, "u1")
+	if err != nil {
+		t.Fatalf("FindCredential: %v", err)
+	}
+	if !got.Enabled || got.LastStep != 10 || got.FailedAttempts != 3 || !got.Locked(time.Now()) {
+		t.Errorf("credential did not round-trip: %+v", got)
+	}
+
+	codes := []*mfa.RecoveryCode{{ID: "c1", UserID: "u1", CodeHash: "h1"}, {ID: "c2", UserID: "u1", CodeHash: "h2"}}
+	if err := repo.ReplaceRecoveryCodes(ctx, "u1", codes); err != nil {
+		t.Fatalf("ReplaceRecoveryCodes: %v", err)
+	}
+	if ok, _ := repo.UseRecoveryCode(ctx, "u1", "h1", time.Now()); !ok {
+		t.Error("expected recovery code to be used")
+	}
+	if ok, _ := repo.UseRecoveryCode(ctx, "u1", "h1", time.Now()); ok {
+		t.Error("recovery code should only be usable once")
+	}
+	if n, _ := repo.CountRecoveryCodes(ctx, "u1"); n != 1 {
+		t.Errorf("expected 1 unused code, got %d", n)
+	}
+
+	if err := repo.DeleteCredential(ctx, "u1"); err != nil {
+		t.Fatalf("DeleteCredential: %v", err)
+	}
+	if n, _ := repo.CountRecoveryCodes(ctx, "u1"); n != 0 {
+		t.Errorf("recovery codes should be deleted with the credential, got %d", n)
+	}
+}
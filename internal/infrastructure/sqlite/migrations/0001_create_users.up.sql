@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS users (
+    id TEXT PRIMARY KEY,
+    name TEXT,
+    email TEXT,
+    receiving_email TEXT,
+    password TEXT,
+    domain TEXT,
+    port INTEGER,
+    folder TEXT,
+    tags TEXT,
+    black_list_senders TEXT,
+    start_time DATETIME,
+    summary_count INTEGER,
+    last_uid TEXT,
+    update_interval TEXT,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
@@ -0,0 +1,16 @@
+CREATE TABLE digests (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    summary TEXT NOT NULL,
+    keywords TEXT,
+    word_cloud BLOB,
+    tags TEXT,
+    email_count INTEGER NOT NULL DEFAULT 0,
+    first_uid INTEGER NOT NULL DEFAULT 0,
+    last_uid INTEGER NOT NULL DEFAULT 0,
+    trigger TEXT NOT NULL,
+    started_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_digests_user_created ON digests (user_id, created_at DESC);
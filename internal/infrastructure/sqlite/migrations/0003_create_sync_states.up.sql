@@ -0,0 +1,23 @@
+CREATE TABLE sync_states (
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    folder TEXT NOT NULL,
+    uid_validity INTEGER NOT NULL DEFAULT 0,
+    last_uid INTEGER NOT NULL DEFAULT 0,
+    highest_mod_seq INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, folder)
+);
+
+-- Carry over progress from the old tag->UID JSON blob. UIDVALIDITY was never
+-- recorded, so it is left at 0 and adopted on the next run.
+INSERT INTO sync_states (user_id, folder, uid_validity, last_uid, highest_mod_seq, updated_at)
+SELECT u.id,
+       COALESCE(NULLIF(u.folder, ''), 'INBOX'),
+       0,
+       (SELECT COALESCE(MAX(CAST(value AS INTEGER)), 0) FROM json_each(u.last_uid)),
+       0,
+       CURRENT_TIMESTAMP
+FROM users u
+WHERE u.last_uid IS NOT NULL AND json_valid(u.last_uid) AND u.last_uid LIKE '{%}';
+
+ALTER TABLE users DROP COLUMN last_uid;
@@ -0,0 +1,5 @@
+-- The old password column held the encrypted IMAP credential, which also
+-- served as the login password. Keep it as the IMAP credential and add a
+-- separate login password hash, filled in on each user's next login.
+ALTER TABLE users RENAME COLUMN password TO imap_password;
+ALTER TABLE users ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,10 @@
+CREATE TABLE audit_events (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL DEFAULT '',
+    action TEXT NOT NULL,
+    ip TEXT NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_audit_events_user_id ON audit_events (user_id);
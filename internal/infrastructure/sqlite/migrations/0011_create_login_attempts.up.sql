@@ -0,0 +1,11 @@
+CREATE TABLE login_attempts (
+    subject TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at DATETIME NOT NULL,
+    locked_until DATETIME,
+    unlock_token_hash TEXT NOT NULL DEFAULT '',
+    updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_login_attempts_unlock_token_hash ON login_attempts (unlock_token_hash);
+CREATE INDEX idx_login_attempts_updated_at ON login_attempts (updated_at);
@@ -0,0 +1,12 @@
+ALTER TABLE users ADD COLUMN receiving_email_verified BOOLEAN NOT NULL DEFAULT 0;
+
+-- Existing users already receive digests; keep delivering to them.
+UPDATE users SET receiving_email_verified = 1;
+
+CREATE TABLE email_verifications (
+    user_id TEXT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+);
@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS audit_events_no_delete;
+DROP TRIGGER IF EXISTS audit_events_no_update;
+
+DROP INDEX IF EXISTS idx_audit_events_created_at;
+DROP INDEX IF EXISTS idx_audit_events_actor_id;
+DROP INDEX IF EXISTS idx_audit_events_user_id;
+CREATE INDEX idx_audit_events_user_id ON audit_events (user_id);
+
+ALTER TABLE audit_events DROP COLUMN changes;
+ALTER TABLE audit_events DROP COLUMN request_id;
+ALTER TABLE audit_events DROP COLUMN actor_id;
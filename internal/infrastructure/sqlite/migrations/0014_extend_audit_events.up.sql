@@ -0,0 +1,19 @@
+ALTER TABLE audit_events ADD COLUMN actor_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_events ADD COLUMN request_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_events ADD COLUMN changes TEXT;
+
+DROP INDEX idx_audit_events_user_id;
+CREATE INDEX idx_audit_events_user_id ON audit_events (user_id, created_at);
+CREATE INDEX idx_audit_events_actor_id ON audit_events (actor_id, created_at);
+CREATE INDEX idx_audit_events_created_at ON audit_events (created_at);
+
+-- The audit log is append-only.
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TRIGGER audit_events_no_delete BEFORE DELETE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
@@ -0,0 +1,36 @@
+DROP INDEX IF EXISTS idx_audit_events_team_id;
+ALTER TABLE audit_events DROP COLUMN team_id;
+
+CREATE TABLE digests_old (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    summary TEXT NOT NULL,
+    keywords TEXT,
+    word_cloud BLOB,
+    tags TEXT,
+    email_count INTEGER NOT NULL DEFAULT 0,
+    first_uid INTEGER NOT NULL DEFAULT 0,
+    last_uid INTEGER NOT NULL DEFAULT 0,
+    trigger TEXT NOT NULL,
+    started_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+-- Team digest history has no owner in the old schema and is dropped.
+INSERT INTO digests_old (id, user_id, summary, keywords, word_cloud, tags, email_count,
+                         first_uid, last_uid, trigger, started_at, created_at)
+SELECT id, user_id, summary, keywords, word_cloud, tags, email_count,
+       first_uid, last_uid, trigger, started_at, created_at
+FROM digests
+WHERE user_id IS NOT NULL;
+
+DROP TABLE digests;
+ALTER TABLE digests_old RENAME TO digests;
+
+CREATE INDEX idx_digests_user_created ON digests (user_id, created_at DESC);
+
+DROP TABLE IF EXISTS team_sync_states;
+DROP TABLE IF EXISTS team_digests;
+DROP TABLE IF EXISTS team_mailboxes;
+DROP TABLE IF EXISTS team_members;
+DROP TABLE IF EXISTS teams;
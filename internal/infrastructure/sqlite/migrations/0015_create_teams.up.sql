@@ -0,0 +1,94 @@
+CREATE TABLE teams (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE TABLE team_members (
+    team_id TEXT NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role TEXT NOT NULL,
+    deliver BOOLEAN NOT NULL DEFAULT 1,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    PRIMARY KEY (team_id, user_id)
+);
+
+CREATE INDEX idx_team_members_user_id ON team_members (user_id);
+
+CREATE TABLE team_mailboxes (
+    id TEXT PRIMARY KEY,
+    team_id TEXT NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    username TEXT NOT NULL,
+    password TEXT NOT NULL,
+    domain TEXT NOT NULL,
+    port INTEGER NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_team_mailboxes_team_id ON team_mailboxes (team_id);
+
+-- A mailbox cannot be deleted while a digest still reads it.
+CREATE TABLE team_digests (
+    id TEXT PRIMARY KEY,
+    team_id TEXT NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    mailbox_id TEXT NOT NULL REFERENCES team_mailboxes (id),
+    name TEXT NOT NULL,
+    folder TEXT NOT NULL DEFAULT '',
+    tags TEXT,
+    black_list_senders TEXT,
+    start_time DATETIME NOT NULL,
+    summary_count INTEGER NOT NULL DEFAULT 0,
+    update_interval TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_team_digests_team_id ON team_digests (team_id);
+
+CREATE TABLE team_sync_states (
+    digest_id TEXT NOT NULL REFERENCES team_digests (id) ON DELETE CASCADE,
+    folder TEXT NOT NULL,
+    uid_validity INTEGER NOT NULL DEFAULT 0,
+    last_uid INTEGER NOT NULL DEFAULT 0,
+    highest_mod_seq INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME NOT NULL,
+    PRIMARY KEY (digest_id, folder)
+);
+
+-- Digest history now belongs to either a user or a team digest. SQLite
+-- cannot relax NOT NULL in place, so the table is rebuilt.
+CREATE TABLE digests_new (
+    id TEXT PRIMARY KEY,
+    user_id TEXT REFERENCES users (id) ON DELETE CASCADE,
+    team_digest_id TEXT REFERENCES team_digests (id) ON DELETE CASCADE,
+    summary TEXT NOT NULL,
+    keywords TEXT,
+    word_cloud BLOB,
+    tags TEXT,
+    email_count INTEGER NOT NULL DEFAULT 0,
+    first_uid INTEGER NOT NULL DEFAULT 0,
+    last_uid INTEGER NOT NULL DEFAULT 0,
+    trigger TEXT NOT NULL,
+    started_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    CHECK ((user_id IS NULL) <> (team_digest_id IS NULL))
+);
+
+INSERT INTO digests_new (id, user_id, summary, keywords, word_cloud, tags, email_count,
+                         first_uid, last_uid, trigger, started_at, created_at)
+SELECT id, user_id, summary, keywords, word_cloud, tags, email_count,
+       first_uid, last_uid, trigger, started_at, created_at
+FROM digests;
+
+DROP TABLE digests;
+ALTER TABLE digests_new RENAME TO digests;
+
+CREATE INDEX idx_digests_user_created ON digests (user_id, created_at DESC);
+CREATE INDEX idx_digests_team_digest_created ON digests (team_digest_id, created_at DESC);
+
+ALTER TABLE audit_events ADD COLUMN team_id TEXT NOT NULL DEFAULT '';
+CREATE INDEX idx_audit_events_team_id ON audit_events (team_id, created_at);
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN imap_oauth_provider TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN imap_oauth_token TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,41 @@
+ALTER TABLE users ADD COLUMN domain TEXT;
+ALTER TABLE users ADD COLUMN port INTEGER;
+ALTER TABLE users ADD COLUMN folder TEXT;
+ALTER TABLE users ADD COLUMN imap_oauth_provider TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN imap_oauth_token TEXT NOT NULL DEFAULT '';
+
+-- Users get back the connection of their oldest mailbox; the others are
+-- dropped.
+UPDATE users SET
+    imap_password = CASE WHEN m.password <> '' THEN m.password ELSE users.imap_password END,
+    domain = m.domain,
+    port = m.port,
+    folder = m.folder,
+    imap_oauth_provider = m.oauth_provider,
+    imap_oauth_token = m.oauth_token
+FROM (
+    SELECT *, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY created_at, id) AS n
+    FROM mailboxes
+) AS m
+WHERE m.user_id = users.id AND m.n = 1;
+
+CREATE TABLE sync_states_old (
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    folder TEXT NOT NULL,
+    uid_validity INTEGER NOT NULL DEFAULT 0,
+    last_uid INTEGER NOT NULL DEFAULT 0,
+    highest_mod_seq INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, folder)
+);
+
+INSERT INTO sync_states_old (user_id, folder, uid_validity, last_uid, highest_mod_seq, updated_at)
+SELECT m.user_id, s.folder, s.uid_validity, s.last_uid, s.highest_mod_seq, s.updated_at
+FROM sync_states s
+JOIN mailboxes m ON m.id = s.mailbox_id
+WHERE m.id = (SELECT id FROM mailboxes WHERE user_id = m.user_id ORDER BY created_at, id LIMIT 1);
+
+DROP TABLE sync_states;
+ALTER TABLE sync_states_old RENAME TO sync_states;
+
+DROP TABLE mailboxes;
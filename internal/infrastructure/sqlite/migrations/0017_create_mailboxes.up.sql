@@ -0,0 +1,56 @@
+CREATE TABLE mailboxes (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    username TEXT NOT NULL,
+    password TEXT NOT NULL DEFAULT '',
+    domain TEXT NOT NULL,
+    port INTEGER NOT NULL,
+    folder TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT 1,
+    oauth_provider TEXT NOT NULL DEFAULT '',
+    oauth_token TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_mailboxes_user_id ON mailboxes (user_id);
+
+-- Each user's single connection becomes their first mailbox. It reuses the
+-- user ID so existing sync state can follow it.
+INSERT INTO mailboxes (id, user_id, name, username, password, domain, port, folder, enabled,
+                       oauth_provider, oauth_token, created_at, updated_at)
+SELECT id, id, COALESCE(email, ''), COALESCE(email, ''), COALESCE(imap_password, ''),
+       domain, COALESCE(port, 0), COALESCE(folder, ''), 1,
+       imap_oauth_provider, imap_oauth_token,
+       COALESCE(created_at, CURRENT_TIMESTAMP), CURRENT_TIMESTAMP
+FROM users
+WHERE domain IS NOT NULL AND domain <> '';
+
+CREATE TABLE sync_states_new (
+    mailbox_id TEXT NOT NULL REFERENCES mailboxes (id) ON DELETE CASCADE,
+    folder TEXT NOT NULL,
+    uid_validity INTEGER NOT NULL DEFAULT 0,
+    last_uid INTEGER NOT NULL DEFAULT 0,
+    highest_mod_seq INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME NOT NULL,
+    PRIMARY KEY (mailbox_id, folder)
+);
+
+INSERT INTO sync_states_new (mailbox_id, folder, uid_validity, last_uid, highest_mod_seq, updated_at)
+SELECT user_id, folder, uid_validity, last_uid, highest_mod_seq, updated_at
+FROM sync_states
+WHERE user_id IN (SELECT id FROM mailboxes);
+
+DROP TABLE sync_states;
+ALTER TABLE sync_states_new RENAME TO sync_states;
+
+-- The IMAP credential stays on the user only where it is still the login
+-- password of a legacy account.
+UPDATE users SET imap_password = '' WHERE password_hash <> '';
+
+ALTER TABLE users DROP COLUMN domain;
+ALTER TABLE users DROP COLUMN port;
+ALTER TABLE users DROP COLUMN folder;
+ALTER TABLE users DROP COLUMN imap_oauth_provider;
+ALTER TABLE users DROP COLUMN imap_oauth_token;
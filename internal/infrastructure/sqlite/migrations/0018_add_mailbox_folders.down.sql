@@ -0,0 +1,6 @@
+ALTER TABLE mailboxes ADD COLUMN folder TEXT NOT NULL DEFAULT '';
+
+-- Only the first folder survives; wildcard patterns are kept as they are.
+UPDATE mailboxes SET folder = COALESCE(json_extract(folders, '$[0]'), '');
+
+ALTER TABLE mailboxes DROP COLUMN folders;
@@ -0,0 +1,2 @@
+ALTER TABLE digests DROP COLUMN skipped;
+ALTER TABLE digests DROP COLUMN truncated;
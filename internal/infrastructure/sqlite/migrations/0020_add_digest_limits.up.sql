@@ -0,0 +1,2 @@
+ALTER TABLE digests ADD COLUMN truncated INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE digests ADD COLUMN skipped INTEGER NOT NULL DEFAULT 0;
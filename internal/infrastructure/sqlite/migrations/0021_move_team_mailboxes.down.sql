@@ -0,0 +1,84 @@
+-- Team mailboxes move back to a table of their own. Their folders, push
+-- mode and OAuth2 links are dropped. As on the way up, the rows that
+-- reference the rebuilt tables are set aside and put back.
+CREATE TEMP TABLE saved_sync_states AS SELECT * FROM sync_states;
+CREATE TEMP TABLE saved_team_sync_states AS SELECT * FROM team_sync_states;
+CREATE TEMP TABLE saved_team_runs AS SELECT * FROM digests WHERE team_digest_id IS NOT NULL;
+
+CREATE TABLE team_mailboxes (
+    id TEXT PRIMARY KEY,
+    team_id TEXT NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    username TEXT NOT NULL,
+    password TEXT NOT NULL,
+    domain TEXT NOT NULL,
+    port INTEGER NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+INSERT INTO team_mailboxes (id, team_id, name, username, password, domain, port, created_at, updated_at)
+SELECT id, team_id, name, username, password, domain, port, created_at, updated_at
+FROM mailboxes
+WHERE team_id IS NOT NULL;
+
+CREATE INDEX idx_team_mailboxes_team_id ON team_mailboxes (team_id);
+
+CREATE TABLE team_digests_old (
+    id TEXT PRIMARY KEY,
+    team_id TEXT NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    mailbox_id TEXT NOT NULL REFERENCES team_mailboxes (id),
+    name TEXT NOT NULL,
+    folder TEXT NOT NULL DEFAULT '',
+    tags TEXT,
+    black_list_senders TEXT,
+    start_time DATETIME NOT NULL,
+    summary_count INTEGER NOT NULL DEFAULT 0,
+    update_interval TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+INSERT INTO team_digests_old SELECT * FROM team_digests;
+
+DROP TABLE team_digests;
+ALTER TABLE team_digests_old RENAME TO team_digests;
+
+CREATE INDEX idx_team_digests_team_id ON team_digests (team_id);
+
+CREATE TABLE mailboxes_old (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    username TEXT NOT NULL,
+    password TEXT NOT NULL DEFAULT '',
+    domain TEXT NOT NULL,
+    port INTEGER NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT 1,
+    push BOOLEAN NOT NULL DEFAULT 0,
+    folders TEXT NOT NULL DEFAULT '[]',
+    oauth_provider TEXT NOT NULL DEFAULT '',
+    oauth_token TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+INSERT INTO mailboxes_old (id, user_id, name, username, password, domain, port, enabled, push, folders,
+                           oauth_provider, oauth_token, created_at, updated_at)
+SELECT id, user_id, name, username, password, domain, port, enabled, push, folders,
+       oauth_provider, oauth_token, created_at, updated_at
+FROM mailboxes
+WHERE user_id IS NOT NULL;
+
+DROP TABLE mailboxes;
+ALTER TABLE mailboxes_old RENAME TO mailboxes;
+
+CREATE INDEX idx_mailboxes_user_id ON mailboxes (user_id);
+
+INSERT INTO sync_states SELECT * FROM saved_sync_states WHERE mailbox_id IN (SELECT id FROM mailboxes);
+INSERT INTO team_sync_states SELECT * FROM saved_team_sync_states;
+INSERT INTO digests SELECT * FROM saved_team_runs;
+
+DROP TABLE saved_sync_states;
+DROP TABLE saved_team_sync_states;
+DROP TABLE saved_team_runs;
@@ -0,0 +1,75 @@
+-- Team mailboxes become rows of the shared mailboxes table, owned by a
+-- team instead of a user. SQLite cannot relax NOT NULL in place, so the
+-- table is rebuilt, and team_digests with it to point at the new table.
+-- Dropping a table deletes the rows that reference it, so those are set
+-- aside first and put back at the end.
+CREATE TEMP TABLE saved_sync_states AS SELECT * FROM sync_states;
+CREATE TEMP TABLE saved_team_sync_states AS SELECT * FROM team_sync_states;
+CREATE TEMP TABLE saved_team_runs AS SELECT * FROM digests WHERE team_digest_id IS NOT NULL;
+
+CREATE TABLE mailboxes_new (
+    id TEXT PRIMARY KEY,
+    user_id TEXT REFERENCES users (id) ON DELETE CASCADE,
+    team_id TEXT REFERENCES teams (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    username TEXT NOT NULL,
+    password TEXT NOT NULL DEFAULT '',
+    domain TEXT NOT NULL,
+    port INTEGER NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT 1,
+    push BOOLEAN NOT NULL DEFAULT 0,
+    folders TEXT NOT NULL DEFAULT '[]',
+    oauth_provider TEXT NOT NULL DEFAULT '',
+    oauth_token TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    CHECK ((user_id IS NULL) <> (team_id IS NULL))
+);
+
+INSERT INTO mailboxes_new (id, user_id, name, username, password, domain, port, enabled, push, folders,
+                           oauth_provider, oauth_token, created_at, updated_at)
+SELECT id, user_id, name, username, password, domain, port, enabled, push, folders,
+       oauth_provider, oauth_token, created_at, updated_at
+FROM mailboxes;
+
+INSERT INTO mailboxes_new (id, team_id, name, username, password, domain, port, created_at, updated_at)
+SELECT id, team_id, name, username, password, domain, port, created_at, updated_at
+FROM team_mailboxes;
+
+DROP TABLE mailboxes;
+ALTER TABLE mailboxes_new RENAME TO mailboxes;
+
+CREATE INDEX idx_mailboxes_user_id ON mailboxes (user_id);
+CREATE INDEX idx_mailboxes_team_id ON mailboxes (team_id);
+
+-- A mailbox cannot be deleted while a digest still reads it.
+CREATE TABLE team_digests_new (
+    id TEXT PRIMARY KEY,
+    team_id TEXT NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    mailbox_id TEXT NOT NULL REFERENCES mailboxes (id),
+    name TEXT NOT NULL,
+    folder TEXT NOT NULL DEFAULT '',
+    tags TEXT,
+    black_list_senders TEXT,
+    start_time DATETIME NOT NULL,
+    summary_count INTEGER NOT NULL DEFAULT 0,
+    update_interval TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+INSERT INTO team_digests_new SELECT * FROM team_digests;
+
+DROP TABLE team_digests;
+DROP TABLE team_mailboxes;
+ALTER TABLE team_digests_new RENAME TO team_digests;
+
+CREATE INDEX idx_team_digests_team_id ON team_digests (team_id);
+
+INSERT INTO sync_states SELECT * FROM saved_sync_states;
+INSERT INTO team_sync_states SELECT * FROM saved_team_sync_states;
+INSERT INTO digests SELECT * FROM saved_team_runs;
+
+DROP TABLE saved_sync_states;
+DROP TABLE saved_team_sync_states;
+DROP TABLE saved_team_runs;
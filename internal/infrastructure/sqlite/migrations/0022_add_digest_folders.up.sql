@@ -0,0 +1,3 @@
+-- UID ranges per folder and mailbox; first_uid/last_uid only hold a
+-- range for runs that read a single folder.
+ALTER TABLE digests ADD COLUMN folders TEXT;
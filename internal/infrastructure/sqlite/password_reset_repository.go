@@ -0,0 +1,62 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/akhil-datla/maildruid/internal/domain/passwordreset"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PasswordResetRepository implements passwordreset.Repository with SQLite.
+// SQLite compares DATETIME values as text, so all times are stored in UTC.
+type PasswordResetRepository struct {
+	db *gorm.DB
+}
+
+// NewPasswordResetRepository creates a new SQLite-backed password reset
+// repository.
+func NewPasswordResetRepository(db *DB) *PasswordResetRepository {
+	return &PasswordResetRepository{db: db.GORM()}
+}
+
+func (r *PasswordResetRepository) Save(ctx context.Context, reset *passwordreset.Reset) error {
+	reset.ExpiresAt, reset.CreatedAt = reset.ExpiresAt.UTC(), reset.CreatedAt.UTC()
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"token_hash", "expires_at", "created_at"}),
+	}).Create(reset).Error
+	if err != nil {
+		return fmt.Errorf("saving password reset: %w", err)
+	}
+	return nil
+}
+
+func (r *PasswordResetRepository) FindByUser(ctx context.Context, userID string) (*passwordreset.Reset, error) {
+	return r.find(ctx, "user_id = ?", userID)
+}
+
+func (r *PasswordResetRepository) FindByTokenHash(ctx context.Context, hash string) (*passwordreset.Reset, error) {
+	return r.find(ctx, "token_hash = ?", hash)
+}
+
+func (r *PasswordResetRepository) find(ctx context.Context, query string, arg string) (*passwordreset.Reset, error) {
+	var reset passwordreset.Reset
+	if err := r.db.WithContext(ctx).Where(query, arg).First(&reset).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, passwordreset.ErrNotFound
+		}
+		return nil, fmt.Errorf("finding password reset: %w", err)
+	}
+	return &reset, nil
+}
+
+func (r *PasswordResetRepository) DeleteByTokenHash(ctx context.Context, hash string) (bool, error) {
+	res := r.db.WithContext(ctx).Where("token_hash = ?", hash).Delete(&passwordreset.Reset{})
+	if res.Error != nil {
+		return false, fmt.Errorf("deleting password reset: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
@@ -0,0 +1,41 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/passwordreset"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+)
+
+func TestPasswordResetRepositoryDeleteIsSingleUse(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewPasswordResetRepository(db)
+	ctx := context.Background()
+
+	if err := NewUserRepository(db).Create(ctx, &user.User{ID: "u1", Email: "u1@example.com"}); err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	now := time.Now()
+	if err := repo.Save(ctx, &passwordreset.Reset{UserID: "u1", TokenHash: "h1", ExpiresAt: now, CreatedAt: now}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := repo.Save(ctx, &passwordreset.Reset{UserID: "u1", TokenHash: "h2", ExpiresAt: now, CreatedAt: now}); err != nil {
+		t.Fatalf("Save (replace): %v", err)
+	}
+	if _, err := repo.FindByTokenHash(ctx, "h1"); err != passwordreset.ErrNotFound {
+		t.Errorf("expected old token to be replaced, got %v", err)
+	}
+
+	if deleted, err := repo.DeleteByTokenHash(ctx, "h1"); err != nil || deleted {
+		t.Errorf("expected replaced token not to be deletable, got %v, %v", deleted, err)
+	}
+	if deleted, err := repo.DeleteByTokenHash(ctx, "h2"); err != nil || !deleted {
+		t.Fatalf("DeleteByTokenHash: %v, %v", deleted, err)
+	}
+	if deleted, _ := repo.DeleteByTokenHash(ctx, "h2"); deleted {
+		t.Error("expected second delete to report nothing deleted")
+	}
+}
@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/session"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+)
+
+func TestSessionRepositoryRotateAndRevoke(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewSessionRepository(db)
+	ctx := context.Background()
+
+	users := NewUserRepository(db)
+	if err := users.Create(ctx, &user.User{ID: "u1", Email: "u1@example.com"}); err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	now := time.Now()
+	first := &session.Session{ID: "s1", UserID: "u1", TokenHash: "h1", ExpiresAt: now.Add(time.Hour)}
+	if err := repo.Create(ctx, first); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	next := &session.Session{ID: "s2", UserID: "u1", TokenHash: "h2", ExpiresAt: now.Add(time.Hour)}
+	if err := repo.Rotate(ctx, "s1", now, next); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	again := &session.Session{ID: "s3", UserID: "u1", TokenHash: "h3", ExpiresAt: now.Add(time.Hour)}
+	if err := repo.Rotate(ctx, "s1", now, again); err != session.ErrRevoked {
+		t.Fatalf("expected ErrRevoked rotating twice, got %v", err)
+	}
+
+	old, err := repo.FindByTokenHash(ctx, "h1")
+	if err != nil {
+		t.Fatalf("FindByTokenHash: %v", err)
+	}
+	if old.RevokedAt == nil || old.ReplacedBy != "s2" {
+		t.Errorf("old session not marked rotated: %+v", old)
+	}
+	if _, err := repo.FindByTokenHash(ctx, "h3"); err != session.ErrNotFound {
+		t.Errorf("failed rotation must not create a session, got %v", err)
+	}
+
+	if err := repo.RevokeAllForUser(ctx, "u1", now); err != nil {
+		t.Fatalf("RevokeAllForUser: %v", err)
+	}
+	cur, _ := repo.FindByTokenHash(ctx, "h2")
+	if cur.Active(now) {
+		t.Error("session should be revoked")
+	}
+
+	// Deleting the user removes their sessions.
+	if err := users.Delete(ctx, "u1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := repo.FindByTokenHash(ctx, "h2"); err != session.ErrNotFound {
+		t.Errorf("expected sessions to cascade on user delete, got %v", err)
+	}
+}
+
+func TestSessionRepositoryRevokedTokens(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewSessionRepository(db)
+	ctx := context.Background()
+
+	if err := NewUserRepository(db).Create(ctx, &user.User{ID: "u1", Email: "u1@example.com"}); err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	now := time.Now()
+	if err := repo.RevokeToken(ctx, &session.RevokedToken{TokenID: "old", UserID: "u1", ExpiresAt: now.Add(-time.Minute)}); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if err := repo.RevokeToken(ctx, &session.RevokedToken{TokenID: "live", UserID: "u1", ExpiresAt: now.Add(time.Minute)}); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	// Revoking twice is harmless.
+	if err := repo.RevokeToken(ctx, &session.RevokedToken{TokenID: "live", UserID: "u1", ExpiresAt: now.Add(time.Minute)}); err != nil {
+		t.Fatalf("RevokeToken (again): %v", err)
+	}
+
+	if err := repo.DeleteExpired(ctx, now); err != nil {
+		t.Fatalf("DeleteExpired: %v", err)
+	}
+	if revoked, _ := repo.IsTokenRevoked(ctx, "old"); revoked {
+		t.Error("expired revocation should be purged")
+	}
+	if revoked, _ := repo.IsTokenRevoked(ctx, "live"); !revoked {
+		t.Error("live revocation should be kept")
+	}
+}
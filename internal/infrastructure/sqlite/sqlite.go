@@ -0,0 +1,87 @@
+package sqlite
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+
+	"github.com/akhil-datla/maildruid/internal/config"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/migrate"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// DB wraps a GORM database connection backed by a SQLite file.
+type DB struct {
+	db     *gorm.DB
+	logger *slog.Logger
+}
+
+// New opens (or creates) the SQLite database at cfg.Path.
+func New(cfg config.DatabaseConfig, log *slog.Logger) (*DB, error) {
+	dsn := cfg.Path + "?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+
+	// SQLite allows a single writer; serialize access through one connection.
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	log.Info("opened database", "driver", "sqlite", "path", cfg.Path)
+	return &DB{db: db, logger: log}, nil
+}
+
+// Migrator returns a migrator for the embedded SQLite schema migrations.
+func (d *DB) Migrator() (*migrate.Migrator, error) {
+	files, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("loading migrations: %w", err)
+	}
+	return migrate.New(d.db, files)
+}
+
+// Migrate applies all pending schema migrations.
+func (d *DB) Migrate() error {
+	m, err := d.Migrator()
+	if err != nil {
+		return err
+	}
+	_, err = m.Up(context.Background())
+	return err
+}
+
+// Ping checks database connectivity.
+func (d *DB) Ping(ctx context.Context) error {
+	sqlDB, err := d.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// Close closes the database connection.
+func (d *DB) Close() error {
+	sqlDB, err := d.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// GORM returns the underlying GORM DB for use by repositories.
+func (d *DB) GORM() *gorm.DB {
+	return d.db
+}
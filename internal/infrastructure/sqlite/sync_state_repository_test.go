@@ -0,0 +1,103 @@
+package sqlite
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/akhil-datla/maildruid/internal/config"
+	"github.com/akhil-datla/maildruid/internal/domain/mailbox"
+	"github.com/akhil-datla/maildruid/internal/domain/syncstate"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+)
+
+func TestSyncStateRepositoryUpsert(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewSyncStateRepository(db)
+	ctx := context.Background()
+
+	if err := NewUserRepository(db).Create(ctx, &user.User{ID: "u1", Email: "u1@example.com"}); err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	if err := NewMailboxRepository(db).Create(ctx, &mailbox.Mailbox{ID: "m1", UserID: "u1", Name: "Work", Username: "u1", Domain: "imap.example.com", Port: 993}); err != nil {
+		t.Fatalf("creating mailbox: %v", err)
+	}
+
+	if _, err := repo.Get(ctx, "m1", "INBOX"); err != syncstate.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	state := &syncstate.State{MailboxID: "m1", Folder: "INBOX", UIDValidity: 100, LastUID: 10}
+	if err := repo.Save(ctx, state); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	state.LastUID = 25
+	state.HighestModSeq = 9000
+	if err := repo.Save(ctx, state); err != nil {
+		t.Fatalf("Save (update): %v", err)
+	}
+	if err := repo.Save(ctx, &syncstate.State{MailboxID: "m1", Folder: "Lists/Go", UIDValidity: 7, LastUID: 3}); err != nil {
+		t.Fatalf("Save (second folder): %v", err)
+	}
+
+	got, err := repo.Get(ctx, "m1", "INBOX")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.UIDValidity != 100 || got.LastUID != 25 || got.HighestModSeq != 9000 {
+		t.Errorf("unexpected state: %+v", got)
+	}
+
+	states, err := repo.ListByMailbox(ctx, "m1")
+	if err != nil {
+		t.Fatalf("ListByMailbox: %v", err)
+	}
+	if len(states) != 2 {
+		t.Errorf("expected 2 folders, got %d", len(states))
+	}
+}
+
+func TestSyncStateMigrationBackfillsLastUID(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	db, err := New(config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")}, logger)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer db.Close()
+
+	m, err := db.Migrator()
+	if err != nil {
+		t.Fatalf("Migrator: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := m.To(ctx, 2); err != nil {
+		t.Fatalf("migrating to 2: %v", err)
+	}
+
+	err = db.GORM().Exec(`INSERT INTO users (id, email, domain, folder, last_uid) VALUES
+		('a', 'a@example.com', 'imap.example.com', 'Reports', '{"report":500,"weekly":480}'),
+		('b', 'b@example.com', 'imap.example.com', '', '{"digest":12}'),
+		('c', 'c@example.com', 'imap.example.com', 'INBOX', '')`).Error
+	if err != nil {
+		t.Fatalf("seeding users: %v", err)
+	}
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	repo := NewSyncStateRepository(db)
+	a, err := repo.Get(ctx, "a", "Reports")
+	if err != nil || a.LastUID != 500 || a.UIDValidity != 0 {
+		t.Errorf("user a: unexpected state %+v, err %v", a, err)
+	}
+	b, err := repo.Get(ctx, "b", "INBOX")
+	if err != nil || b.LastUID != 12 {
+		t.Errorf("user b: unexpected state %+v, err %v", b, err)
+	}
+	if _, err := repo.Get(ctx, "c", "INBOX"); err != syncstate.ErrNotFound {
+		t.Errorf("user c: expected no state, got %v", err)
+	}
+}
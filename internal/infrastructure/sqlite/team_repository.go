@@ -0,0 +1,289 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/syncstate"
+	"github.com/akhil-datla/maildruid/internal/domain/team"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// teamDigestRow is the SQLite representation of team.Digest.
+type teamDigestRow struct {
+	ID               string `gorm:"primaryKey"`
+	TeamID           string
+	MailboxID        string
+	Name             string
+	Folder           string
+	Tags             stringList `gorm:"type:text"`
+	BlackListSenders stringList `gorm:"type:text"`
+	StartTime        time.Time
+	SummaryCount     int
+	UpdateInterval   string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+func (teamDigestRow) TableName() string { return "team_digests" }
+
+func toTeamDigestRow(d *team.Digest) *teamDigestRow {
+	return &teamDigestRow{
+		ID:               d.ID,
+		TeamID:           d.TeamID,
+		MailboxID:        d.MailboxID,
+		Name:             d.Name,
+		Folder:           d.Folder,
+		Tags:             stringList(d.Tags),
+		BlackListSenders: stringList(d.BlackListSenders),
+		StartTime:        d.StartTime,
+		SummaryCount:     d.SummaryCount,
+		UpdateInterval:   d.UpdateInterval,
+		CreatedAt:        d.CreatedAt,
+		UpdatedAt:        d.UpdatedAt,
+	}
+}
+
+func (r *teamDigestRow) toDigest() *team.Digest {
+	return &team.Digest{
+		ID:               r.ID,
+		TeamID:           r.TeamID,
+		MailboxID:        r.MailboxID,
+		Name:             r.Name,
+		Folder:           r.Folder,
+		Tags:             []string(r.Tags),
+		BlackListSenders: []string(r.BlackListSenders),
+		StartTime:        r.StartTime,
+		SummaryCount:     r.SummaryCount,
+		UpdateInterval:   r.UpdateInterval,
+		CreatedAt:        r.CreatedAt,
+		UpdatedAt:        r.UpdatedAt,
+	}
+}
+
+// teamSyncStateRow is a team digest's progress through a folder.
+type teamSyncStateRow struct {
+	DigestID      string `gorm:"primaryKey"`
+	Folder        string `gorm:"primaryKey"`
+	UIDValidity   uint32
+	LastUID       int
+	HighestModSeq uint64
+	UpdatedAt     time.Time
+}
+
+func (teamSyncStateRow) TableName() string { return "team_sync_states" }
+
+// membershipRow is a team joined with one of its members.
+type membershipRow struct {
+	team.Team
+	Role    string
+	Deliver bool
+}
+
+// TeamRepository implements team.Repository with SQLite.
+type TeamRepository struct {
+	db *gorm.DB
+}
+
+// NewTeamRepository creates a new SQLite-backed team repository.
+func NewTeamRepository(db *DB) *TeamRepository {
+	return &TeamRepository{db: db.GORM()}
+}
+
+func (r *TeamRepository) Create(ctx context.Context, t *team.Team, owner *team.Member) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(t).Error; err != nil {
+			return err
+		}
+		return tx.Create(owner).Error
+	})
+	if err != nil {
+		return fmt.Errorf("creating team: %w", err)
+	}
+	return nil
+}
+
+func (r *TeamRepository) FindByID(ctx context.Context, id string) (*team.Team, error) {
+	var t team.Team
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&t).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, team.ErrNotFound
+		}
+		return nil, fmt.Errorf("finding team: %w", err)
+	}
+	return &t, nil
+}
+
+func (r *TeamRepository) Update(ctx context.Context, t *team.Team) error {
+	if err := r.db.WithContext(ctx).Save(t).Error; err != nil {
+		return fmt.Errorf("updating team: %w", err)
+	}
+	return nil
+}
+
+// Delete relies on ON DELETE CASCADE to remove everything the team owns.
+func (r *TeamRepository) Delete(ctx context.Context, id string) error {
+	if err := r.db.WithContext(ctx).Where("id = ?", id).Delete(&team.Team{}).Error; err != nil {
+		return fmt.Errorf("deleting team: %w", err)
+	}
+	return nil
+}
+
+func (r *TeamRepository) ListByUser(ctx context.Context, userID string) ([]*team.Membership, error) {
+	var rows []*membershipRow
+	err := r.db.WithContext(ctx).
+		Table("teams").
+		Select("teams.*, team_members.role, team_members.deliver").
+		Joins("JOIN team_members ON team_members.team_id = teams.id").
+		Where("team_members.user_id = ?", userID).
+		Order("teams.name").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("listing teams: %w", err)
+	}
+	result := make([]*team.Membership, 0, len(rows))
+	for _, row := range rows {
+		t := row.Team
+		result = append(result, &team.Membership{Team: &t, Role: row.Role, Deliver: row.Deliver})
+	}
+	return result, nil
+}
+
+func (r *TeamRepository) AddMember(ctx context.Context, m *team.Member) error {
+	if err := r.db.WithContext(ctx).Create(m).Error; err != nil {
+		return fmt.Errorf("adding team member: %w", err)
+	}
+	return nil
+}
+
+func (r *TeamRepository) FindMember(ctx context.Context, teamID, userID string) (*team.Member, error) {
+	var m team.Member
+	err := r.db.WithContext(ctx).Where("team_id = ? AND user_id = ?", teamID, userID).First(&m).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, team.ErrMemberNotFound
+		}
+		return nil, fmt.Errorf("finding team member: %w", err)
+	}
+	return &m, nil
+}
+
+func (r *TeamRepository) UpdateMember(ctx context.Context, m *team.Member) error {
+	if err := r.db.WithContext(ctx).Save(m).Error; err != nil {
+		return fmt.Errorf("updating team member: %w", err)
+	}
+	return nil
+}
+
+func (r *TeamRepository) RemoveMember(ctx context.Context, teamID, userID string) error {
+	err := r.db.WithContext(ctx).Where("team_id = ? AND user_id = ?", teamID, userID).Delete(&team.Member{}).Error
+	if err != nil {
+		return fmt.Errorf("removing team member: %w", err)
+	}
+	return nil
+}
+
+func (r *TeamRepository) ListMembers(ctx context.Context, teamID string) ([]*team.Member, error) {
+	var members []*team.Member
+	if err := r.db.WithContext(ctx).Where("team_id = ?", teamID).Order("created_at").Find(&members).Error; err != nil {
+		return nil, fmt.Errorf("listing team members: %w", err)
+	}
+	return members, nil
+}
+
+func (r *TeamRepository) CreateDigest(ctx context.Context, d *team.Digest) error {
+	row := toTeamDigestRow(d)
+	if err := r.db.WithContext(ctx).Create(row).Error; err != nil {
+		return fmt.Errorf("creating digest: %w", err)
+	}
+	d.CreatedAt, d.UpdatedAt = row.CreatedAt, row.UpdatedAt
+	return nil
+}
+
+func (r *TeamRepository) FindDigest(ctx context.Context, teamID, id string) (*team.Digest, error) {
+	q := r.db.WithContext(ctx).Where("id = ?", id)
+	if teamID != "" {
+		q = q.Where("team_id = ?", teamID)
+	}
+	var row teamDigestRow
+	if err := q.First(&row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, team.ErrDigestNotFound
+		}
+		return nil, fmt.Errorf("finding digest: %w", err)
+	}
+	return row.toDigest(), nil
+}
+
+func (r *TeamRepository) UpdateDigest(ctx context.Context, d *team.Digest) error {
+	row := toTeamDigestRow(d)
+	if err := r.db.WithContext(ctx).Save(row).Error; err != nil {
+		return fmt.Errorf("updating digest: %w", err)
+	}
+	d.UpdatedAt = row.UpdatedAt
+	return nil
+}
+
+func (r *TeamRepository) DeleteDigest(ctx context.Context, teamID, id string) error {
+	err := r.db.WithContext(ctx).Where("id = ? AND team_id = ?", id, teamID).Delete(&teamDigestRow{}).Error
+	if err != nil {
+		return fmt.Errorf("deleting digest: %w", err)
+	}
+	return nil
+}
+
+func (r *TeamRepository) ListDigests(ctx context.Context, teamID string) ([]*team.Digest, error) {
+	return r.listDigests(r.db.WithContext(ctx).Where("team_id = ?", teamID).Order("name"))
+}
+
+func (r *TeamRepository) ListScheduledDigests(ctx context.Context) ([]*team.Digest, error) {
+	return r.listDigests(r.db.WithContext(ctx).Where("update_interval NOT IN ('', '0')"))
+}
+
+func (r *TeamRepository) listDigests(q *gorm.DB) ([]*team.Digest, error) {
+	var rows []*teamDigestRow
+	if err := q.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("listing digests: %w", err)
+	}
+	digests := make([]*team.Digest, 0, len(rows))
+	for _, row := range rows {
+		digests = append(digests, row.toDigest())
+	}
+	return digests, nil
+}
+
+func (r *TeamRepository) SyncState(ctx context.Context, digestID, folder string) (*syncstate.State, error) {
+	var row teamSyncStateRow
+	err := r.db.WithContext(ctx).Where("digest_id = ? AND folder = ?", digestID, folder).First(&row).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, syncstate.ErrNotFound
+		}
+		return nil, fmt.Errorf("finding sync state: %w", err)
+	}
+	return &syncstate.State{
+		Folder:        row.Folder,
+		UIDValidity:   row.UIDValidity,
+		LastUID:       row.LastUID,
+		HighestModSeq: row.HighestModSeq,
+		UpdatedAt:     row.UpdatedAt,
+	}, nil
+}
+
+func (r *TeamRepository) SaveSyncState(ctx context.Context, digestID string, s *syncstate.State) error {
+	row := &teamSyncStateRow{
+		DigestID:      digestID,
+		Folder:        s.Folder,
+		UIDValidity:   s.UIDValidity,
+		LastUID:       s.LastUID,
+		HighestModSeq: s.HighestModSeq,
+	}
+	if err := r.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(row).Error; err != nil {
+		return fmt.Errorf("saving sync state: %w", err)
+	}
+	s.UpdatedAt = row.UpdatedAt
+	return nil
+}
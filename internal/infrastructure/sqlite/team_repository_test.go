@@ -0,0 +1,245 @@
+package sqlite
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/config"
+	"github.com/akhil-datla/maildruid/internal/domain/mailbox"
+	"github.com/akhil-datla/maildruid/internal/domain/summary"
+	"github.com/akhil-datla/maildruid/internal/domain/syncstate"
+	"github.com/akhil-datla/maildruid/internal/domain/team"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+)
+
+func TestTeamRepositoryRoundTrip(t *testing.T) {
+	db := setupTestDB(t)
+	users := NewUserRepository(db)
+	repo := NewTeamRepository(db)
+	digests := NewDigestRepository(db)
+	ctx := context.Background()
+
+	for _, id := range []string{"u1", "u2"} {
+		if err := users.Create(ctx, &user.User{ID: id, Email: id + "@example.com"}); err != nil {
+			t.Fatalf("creating user: %v", err)
+		}
+	}
+
+	tm := &team.Team{ID: "t1", Name: "Support"}
+	if err := repo.Create(ctx, tm, &team.Member{TeamID: "t1", UserID: "u1", Role: team.RoleOwner, Deliver: true}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := repo.AddMember(ctx, &team.Member{TeamID: "t1", UserID: "u2", Role: team.RoleViewer}); err != nil {
+		t.Fatalf("AddMember: %v", err)
+	}
+
+	teams, err := repo.ListByUser(ctx, "u2")
+	if err != nil {
+		t.Fatalf("ListByUser: %v", err)
+	}
+	if len(teams) != 1 || teams[0].Name != "Support" || teams[0].Role != team.RoleViewer || teams[0].Deliver {
+		t.Fatalf("unexpected memberships: %+v", teams)
+	}
+
+	mailboxes := NewMailboxRepository(db)
+	mb := &mailbox.Mailbox{ID: "m1", TeamID: "t1", Name: "Shared", Username: "support@example.com", Password: "sealed", Domain: "imap.example.com", Port: 993, Enabled: true}
+	if err := mailboxes.Create(ctx, mb); err != nil {
+		t.Fatalf("creating team mailbox: %v", err)
+	}
+	if _, err := mailboxes.FindByID(ctx, mailbox.TeamOwner("other"), "m1"); err != mailbox.ErrNotFound {
+		t.Errorf("expected ErrNotFound for another team, got %v", err)
+	}
+	if _, err := mailboxes.FindByID(ctx, mailbox.UserOwner("u1"), "m1"); err != mailbox.ErrNotFound {
+		t.Errorf("team mailboxes must not be found as a user's, got %v", err)
+	}
+
+	d := &team.Digest{
+		ID:             "td1",
+		TeamID:         "t1",
+		MailboxID:      "m1",
+		Name:           "Escalations",
+		Tags:           []string{"urgent"},
+		StartTime:      time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		SummaryCount:   5,
+		UpdateInterval: "60",
+	}
+	if err := repo.CreateDigest(ctx, d); err != nil {
+		t.Fatalf("CreateDigest: %v", err)
+	}
+	if err := repo.CreateDigest(ctx, &team.Digest{ID: "td2", TeamID: "t1", MailboxID: "m1", Name: "Manual"}); err != nil {
+		t.Fatalf("CreateDigest: %v", err)
+	}
+
+	got, err := repo.FindDigest(ctx, "", "td1")
+	if err != nil {
+		t.Fatalf("FindDigest: %v", err)
+	}
+	if len(got.Tags) != 1 || got.Tags[0] != "urgent" || !got.Scheduled() {
+		t.Errorf("unexpected digest: %+v", got)
+	}
+	scheduled, err := repo.ListScheduledDigests(ctx)
+	if err != nil {
+		t.Fatalf("ListScheduledDigests: %v", err)
+	}
+	if len(scheduled) != 1 || scheduled[0].ID != "td1" {
+		t.Errorf("expected only td1 to be scheduled, got %v", scheduled)
+	}
+
+	if err := repo.SaveSyncState(ctx, "td1", &syncstate.State{Folder: "INBOX", UIDValidity: 7, LastUID: 40}); err != nil {
+		t.Fatalf("SaveSyncState: %v", err)
+	}
+	state, err := repo.SyncState(ctx, "td1", "INBOX")
+	if err != nil || state.LastUID != 40 || state.UIDValidity != 7 {
+		t.Errorf("unexpected sync state %+v, err %v", state, err)
+	}
+
+	run := &summary.Digest{ID: "run1", TeamDigestID: "td1", Summary: "s", Trigger: summary.TriggerScheduled, CreatedAt: time.Now()}
+	if err := digests.Create(ctx, run); err != nil {
+		t.Fatalf("creating team digest run: %v", err)
+	}
+	runs, total, err := digests.ListByTeamDigest(ctx, "td1", 10, 0)
+	if err != nil || total != 1 || runs[0].UserID != "" || runs[0].TeamDigestID != "td1" {
+		t.Fatalf("unexpected runs %v (total %d), err %v", runs, total, err)
+	}
+	if _, err := digests.FindByID(ctx, "", "run1"); err != summary.ErrDigestNotFound {
+		t.Errorf("team runs must not be found as user digests, got %v", err)
+	}
+
+	if err := repo.Delete(ctx, "t1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := repo.FindDigest(ctx, "", "td1"); err != team.ErrDigestNotFound {
+		t.Errorf("expected digest to be deleted with its team, got %v", err)
+	}
+	if _, err := digests.FindByTeamDigest(ctx, "td1", "run1"); err != summary.ErrDigestNotFound {
+		t.Errorf("expected history to be deleted with its team, got %v", err)
+	}
+	if _, err := repo.SyncState(ctx, "td1", "INBOX"); err != syncstate.ErrNotFound {
+		t.Errorf("expected sync state to be deleted with its team, got %v", err)
+	}
+	if _, err := mailboxes.FindByID(ctx, mailbox.TeamOwner("t1"), "m1"); err != mailbox.ErrNotFound {
+		t.Errorf("expected mailbox to be deleted with its team, got %v", err)
+	}
+	if teams, _ := repo.ListByUser(ctx, "u1"); len(teams) != 0 {
+		t.Errorf("expected no memberships, got %v", teams)
+	}
+}
+
+func TestTeamMigrationKeepsUserDigests(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	db, err := New(config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")}, logger)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer db.Close()
+
+	m, err := db.Migrator()
+	if err != nil {
+		t.Fatalf("Migrator: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := m.To(ctx, 14); err != nil {
+		t.Fatalf("migrating to 14: %v", err)
+	}
+	err = db.GORM().Exec(`INSERT INTO users (id, email) VALUES ('u1', 'u1@example.com')`).Error
+	if err != nil {
+		t.Fatalf("seeding user: %v", err)
+	}
+	err = db.GORM().Exec(`INSERT INTO digests (id, user_id, summary, trigger, started_at, created_at)
+		VALUES ('d1', 'u1', 'kept', 'manual', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`).Error
+	if err != nil {
+		t.Fatalf("seeding digest: %v", err)
+	}
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	digests := NewDigestRepository(db)
+	if d, err := digests.FindByID(ctx, "u1", "d1"); err != nil || d.Summary != "kept" {
+		t.Fatalf("expected user digest to survive the migration, got %+v, %v", d, err)
+	}
+
+	if _, err := m.To(ctx, 14); err != nil {
+		t.Fatalf("reverting to 14: %v", err)
+	}
+	var count int64
+	if err := db.GORM().Table("digests").Where("user_id = ?", "u1").Count(&count).Error; err != nil || count != 1 {
+		t.Errorf("expected user digest to survive the rollback, got %d, %v", count, err)
+	}
+}
+
+func TestTeamMailboxMigrationKeepsDigests(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	db, err := New(config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")}, logger)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer db.Close()
+
+	m, err := db.Migrator()
+	if err != nil {
+		t.Fatalf("Migrator: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := m.To(ctx, 20); err != nil {
+		t.Fatalf("migrating to 20: %v", err)
+	}
+	for _, stmt := range []string{
+		`INSERT INTO users (id, email) VALUES ('u1', 'u1@example.com')`,
+		`INSERT INTO mailboxes (id, user_id, name, username, domain, port, created_at, updated_at)
+			VALUES ('um1', 'u1', 'Own', 'u1@example.com', 'imap.example.com', 993, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+		`INSERT INTO sync_states (mailbox_id, folder, last_uid, updated_at) VALUES ('um1', 'INBOX', 12, CURRENT_TIMESTAMP)`,
+		`INSERT INTO teams (id, name, created_at, updated_at) VALUES ('t1', 'Support', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+		`INSERT INTO team_mailboxes (id, team_id, name, username, password, domain, port, created_at, updated_at)
+			VALUES ('tm1', 't1', 'Shared', 'support@example.com', 'sealed', 'imap.example.com', 993, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+		`INSERT INTO team_digests (id, team_id, mailbox_id, name, start_time, created_at, updated_at)
+			VALUES ('td1', 't1', 'tm1', 'Escalations', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+		`INSERT INTO team_sync_states (digest_id, folder, last_uid, updated_at) VALUES ('td1', 'INBOX', 40, CURRENT_TIMESTAMP)`,
+		`INSERT INTO digests (id, team_digest_id, summary, trigger, started_at, created_at)
+			VALUES ('run1', 'td1', 'kept', 'manual', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+	} {
+		if err := db.GORM().Exec(stmt).Error; err != nil {
+			t.Fatalf("seeding: %v", err)
+		}
+	}
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	mailboxes := NewMailboxRepository(db)
+	mb, err := mailboxes.FindByID(ctx, mailbox.TeamOwner("t1"), "tm1")
+	if err != nil || mb.Password != "sealed" || !mb.Enabled || mb.UserID != "" {
+		t.Fatalf("expected the team mailbox to move, got %+v, %v", mb, err)
+	}
+	if state, err := NewSyncStateRepository(db).Get(ctx, "um1", "INBOX"); err != nil || state.LastUID != 12 {
+		t.Errorf("expected the user's sync state to survive, got %+v, %v", state, err)
+	}
+	teams := NewTeamRepository(db)
+	if state, err := teams.SyncState(ctx, "td1", "INBOX"); err != nil || state.LastUID != 40 {
+		t.Errorf("expected the digest's sync state to survive, got %+v, %v", state, err)
+	}
+	if _, total, err := NewDigestRepository(db).ListByTeamDigest(ctx, "td1", 10, 0); err != nil || total != 1 {
+		t.Errorf("expected the team's history to survive, got %d, %v", total, err)
+	}
+	if err := mailboxes.Delete(ctx, mailbox.TeamOwner("t1"), "tm1"); err == nil {
+		t.Error("a mailbox read by a digest must not be deleted")
+	}
+
+	if _, err := m.To(ctx, 20); err != nil {
+		t.Fatalf("reverting to 20: %v", err)
+	}
+	var count int64
+	if err := db.GORM().Table("team_mailboxes").Where("id = ?", "tm1").Count(&count).Error; err != nil || count != 1 {
+		t.Errorf("expected the team mailbox back in its table, got %d, %v", count, err)
+	}
+	if err := db.GORM().Table("team_sync_states").Where("digest_id = ?", "td1").Count(&count).Error; err != nil || count != 1 {
+		t.Errorf("expected the digest's sync state to survive the rollback, got %d, %v", count, err)
+	}
+	if err := db.GORM().Table("sync_states").Where("mailbox_id = ?", "um1").Count(&count).Error; err != nil || count != 1 {
+		t.Errorf("expected the user's sync state to survive the rollback, got %d, %v", count, err)
+	}
+}
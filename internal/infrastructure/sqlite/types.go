@@ -0,0 +1,60 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// stringList stores a []string as a JSON array in a TEXT column, since
+// SQLite has no native array type.
+type stringList []string
+
+// Value implements driver.Valuer.
+func (l stringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal([]string(l))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner.
+func (l *stringList) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("scanning string list: unsupported type %T", src)
+	}
+	if len(data) == 0 {
+		*l = nil
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(l))
+}
+
+// nullString maps an empty string to NULL for optional reference columns.
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// derefString maps NULL back to an empty string.
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
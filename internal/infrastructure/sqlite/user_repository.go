@@ -0,0 +1,191 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+	"gorm.io/gorm"
+)
+
+// userRow is the SQLite representation of user.User. It mirrors the domain
+// model column for column but stores list fields as JSON text.
+type userRow struct {
+	ID                     string `gorm:"primaryKey"`
+	Name                   string
+	Email                  string `gorm:"uniqueIndex"`
+	ReceivingEmail         string
+	ReceivingEmailVerified bool
+	PasswordHash           string
+	IMAPPassword           string
+	TokenGeneration        int
+	OIDCSubject            string `gorm:"column:oidc_subject"`
+	Role                   string
+	Disabled               bool
+	Tags                   stringList `gorm:"type:text"`
+	BlackListSenders       stringList `gorm:"type:text"`
+	StartTime              time.Time
+	SummaryCount           int
+	UpdateInterval         string
+	CreatedAt              time.Time
+	UpdatedAt              time.Time
+}
+
+func (userRow) TableName() string { return "users" }
+
+func toUserRow(u *user.User) *userRow {
+	return &userRow{
+		ID:                     u.ID,
+		Name:                   u.Name,
+		Email:                  u.Email,
+		ReceivingEmail:         u.ReceivingEmail,
+		ReceivingEmailVerified: u.ReceivingEmailVerified,
+		PasswordHash:           u.PasswordHash,
+		IMAPPassword:           u.IMAPPassword,
+		TokenGeneration:        u.TokenGeneration,
+		OIDCSubject:            u.OIDCSubject,
+		Role:                   u.Role,
+		Disabled:               u.Disabled,
+		Tags:                   stringList(u.Tags),
+		BlackListSenders:       stringList(u.BlackListSenders),
+		StartTime:              u.StartTime,
+		SummaryCount:           u.SummaryCount,
+		UpdateInterval:         u.UpdateInterval,
+		CreatedAt:              u.CreatedAt,
+		UpdatedAt:              u.UpdatedAt,
+	}
+}
+
+func (r *userRow) toUser() *user.User {
+	return &user.User{
+		ID:                     r.ID,
+		Name:                   r.Name,
+		Email:                  r.Email,
+		ReceivingEmail:         r.ReceivingEmail,
+		ReceivingEmailVerified: r.ReceivingEmailVerified,
+		PasswordHash:           r.PasswordHash,
+		IMAPPassword:           r.IMAPPassword,
+		TokenGeneration:        r.TokenGeneration,
+		OIDCSubject:            r.OIDCSubject,
+		Role:                   r.Role,
+		Disabled:               r.Disabled,
+		Tags:                   []string(r.Tags),
+		BlackListSenders:       []string(r.BlackListSenders),
+		StartTime:              r.StartTime,
+		SummaryCount:           r.SummaryCount,
+		UpdateInterval:         r.UpdateInterval,
+		CreatedAt:              r.CreatedAt,
+		UpdatedAt:              r.UpdatedAt,
+	}
+}
+
+// UserRepository implements user.Repository with SQLite.
+type UserRepository struct {
+	db *gorm.DB
+}
+
+// NewUserRepository creates a new SQLite-backed user repository.
+func NewUserRepository(db *DB) *UserRepository {
+	return &UserRepository{db: db.GORM()}
+}
+
+func (r *UserRepository) Create(ctx context.Context, u *user.User) error {
+	row := toUserRow(u)
+	if err := r.db.WithContext(ctx).Create(row).Error; err != nil {
+		return fmt.Errorf("creating user: %w", err)
+	}
+	u.CreatedAt, u.UpdatedAt = row.CreatedAt, row.UpdatedAt
+	return nil
+}
+
+func (r *UserRepository) FindByID(ctx context.Context, id string) (*user.User, error) {
+	var row userRow
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, user.ErrNotFound
+		}
+		return nil, fmt.Errorf("finding user: %w", err)
+	}
+	return row.toUser(), nil
+}
+
+func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*user.User, error) {
+	var row userRow
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, user.ErrNotFound
+		}
+		return nil, fmt.Errorf("finding user by email: %w", err)
+	}
+	return row.toUser(), nil
+}
+
+func (r *UserRepository) FindByOIDCSubject(ctx context.Context, subject string) (*user.User, error) {
+	var row userRow
+	if err := r.db.WithContext(ctx).Where("oidc_subject = ? AND oidc_subject <> ''", subject).First(&row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, user.ErrNotFound
+		}
+		return nil, fmt.Errorf("finding user by OIDC subject: %w", err)
+	}
+	return row.toUser(), nil
+}
+
+func (r *UserRepository) Update(ctx context.Context, u *user.User) error {
+	row := toUserRow(u)
+	if err := r.db.WithContext(ctx).Save(row).Error; err != nil {
+		return fmt.Errorf("updating user: %w", err)
+	}
+	u.UpdatedAt = row.UpdatedAt
+	return nil
+}
+
+func (r *UserRepository) Delete(ctx context.Context, id string) error {
+	if err := r.db.WithContext(ctx).Where("id = ?", id).Delete(&userRow{}).Error; err != nil {
+		return fmt.Errorf("deleting user: %w", err)
+	}
+	return nil
+}
+
+func (r *UserRepository) ListAll(ctx context.Context) ([]*user.User, error) {
+	var rows []*userRow
+	if err := r.db.WithContext(ctx).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("listing users: %w", err)
+	}
+	users := make([]*user.User, 0, len(rows))
+	for _, row := range rows {
+		users = append(users, row.toUser())
+	}
+	return users, nil
+}
+
+func (r *UserRepository) Search(ctx context.Context, query string, limit, offset int) ([]*user.User, int64, error) {
+	pattern := likePattern(query)
+	scope := r.db.WithContext(ctx).Model(&userRow{}).
+		Where(`LOWER(name) LIKE ? ESCAPE '\' OR LOWER(email) LIKE ? ESCAPE '\'`, pattern, pattern)
+
+	var total int64
+	if err := scope.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("counting users: %w", err)
+	}
+
+	var rows []*userRow
+	if err := scope.Order("created_at, id").Limit(limit).Offset(offset).Find(&rows).Error; err != nil {
+		return nil, 0, fmt.Errorf("searching users: %w", err)
+	}
+	users := make([]*user.User, 0, len(rows))
+	for _, row := range rows {
+		users = append(users, row.toUser())
+	}
+	return users, total, nil
+}
+
+// likePattern turns a search string into a lowercase LIKE pattern matching
+// it anywhere, with LIKE wildcards in the input escaped.
+func likePattern(query string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(query))
+	return "%" + escaped + "%"
+}
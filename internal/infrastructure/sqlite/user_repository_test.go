@@ -0,0 +1,193 @@
+package sqlite
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/config"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+)
+
+func setupTestDB(t *testing.T) *DB {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	db, err := New(config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")}, logger)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	return db
+}
+
+func TestUserRepositoryRoundTrip(t *testing.T) {
+	repo := NewUserRepository(setupTestDB(t))
+	ctx := context.Background()
+
+	start := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	u := &user.User{
+		ID:                     "user-1",
+		Name:                   "Test",
+		Email:                  "test@example.com",
+		ReceivingEmail:         "recv@example.com",
+		PasswordHash:           "hash",
+		ReceivingEmailVerified: true,
+		IMAPPassword:           "encrypted",
+		Tags:                   []string{"report", "weekly, digest"},
+		BlackListSenders:       []string{"spam@co.com"},
+		StartTime:              start,
+		SummaryCount:           5,
+	}
+	if err := repo.Create(ctx, u); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	got, err := repo.FindByEmail(ctx, "test@example.com")
+	if err != nil {
+		t.Fatalf("FindByEmail: %v", err)
+	}
+	if got.ID != "user-1" || got.Name != "Test" ||
+		got.PasswordHash != "hash" || got.IMAPPassword != "encrypted" || !got.ReceivingEmailVerified {
+		t.Errorf("unexpected user: %+v", got)
+	}
+	if len(got.Tags) != 2 || got.Tags[1] != "weekly, digest" {
+		t.Errorf("expected tags to round-trip, got %v", got.Tags)
+	}
+	if len(got.BlackListSenders) != 1 || got.BlackListSenders[0] != "spam@co.com" {
+		t.Errorf("expected blacklist to round-trip, got %v", got.BlackListSenders)
+	}
+	if !got.StartTime.Equal(start) {
+		t.Errorf("expected start time %v, got %v", start, got.StartTime)
+	}
+
+	got.Tags = nil
+	got.SummaryCount = 10
+	if err := repo.Update(ctx, got); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	got, err = repo.FindByID(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if len(got.Tags) != 0 || got.SummaryCount != 10 {
+		t.Errorf("update not persisted: %+v", got)
+	}
+}
+
+func TestUserRepositoryUniqueEmail(t *testing.T) {
+	repo := NewUserRepository(setupTestDB(t))
+	ctx := context.Background()
+
+	if err := repo.Create(ctx, &user.User{ID: "a", Email: "dup@example.com"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := repo.Create(ctx, &user.User{ID: "b", Email: "dup@example.com"}); err == nil {
+		t.Error("expected unique constraint violation")
+	}
+}
+
+func TestUserRepositorySearch(t *testing.T) {
+	repo := NewUserRepository(setupTestDB(t))
+	ctx := context.Background()
+
+	for i, u := range []*user.User{
+		{ID: "a", Name: "Alice", Email: "alice@example.com", Role: user.RoleAdmin},
+		{ID: "b", Name: "Bob", Email: "bob@example.com"},
+		{ID: "c", Name: "Carol", Email: "carol_100%@example.com", Disabled: true},
+	} {
+		u.CreatedAt = time.Date(2025, 1, 1+i, 0, 0, 0, 0, time.UTC)
+		if err := repo.Create(ctx, u); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	users, total, err := repo.Search(ctx, "ALICE", 10, 0)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if total != 1 || len(users) != 1 || users[0].ID != "a" || users[0].Role != user.RoleAdmin {
+		t.Errorf("expected Alice as admin, got %d %+v", total, users)
+	}
+
+	// LIKE wildcards in the query match literally.
+	users, total, _ = repo.Search(ctx, "_100%", 10, 0)
+	if total != 1 || users[0].ID != "c" || !users[0].Disabled {
+		t.Errorf("expected disabled Carol, got %d %+v", total, users)
+	}
+	if _, total, _ = repo.Search(ctx, "%", 10, 0); total != 1 {
+		t.Errorf("expected %% to match literally, got %d matches", total)
+	}
+
+	users, total, _ = repo.Search(ctx, "", 2, 1)
+	if total != 3 || len(users) != 2 || users[0].ID != "b" || users[1].ID != "c" {
+		t.Errorf("expected second page [b c] of 3, got %d %+v", total, users)
+	}
+}
+
+func TestUserRepositoryDeleteAndNotFound(t *testing.T) {
+	repo := NewUserRepository(setupTestDB(t))
+	ctx := context.Background()
+
+	for _, id := range []string{"a", "b"} {
+		if err := repo.Create(ctx, &user.User{ID: id, Email: id + "@example.com"}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	if err := repo.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if _, err := repo.FindByID(ctx, "a"); err != user.ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := repo.FindByEmail(ctx, "a@example.com"); err != user.ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	users, err := repo.ListAll(ctx)
+	if err != nil {
+		t.Fatalf("ListAll: %v", err)
+	}
+	if len(users) != 1 || users[0].ID != "b" {
+		t.Errorf("expected only user b, got %v", users)
+	}
+}
+
+func TestUserMigrationKeepsIMAPPassword(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	db, err := New(config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")}, logger)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer db.Close()
+
+	m, err := db.Migrator()
+	if err != nil {
+		t.Fatalf("Migrator: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := m.To(ctx, 3); err != nil {
+		t.Fatalf("migrating to 3: %v", err)
+	}
+	if err := db.GORM().Exec(`INSERT INTO users (id, email, password) VALUES ('a', 'a@example.com', 'encrypted')`).Error; err != nil {
+		t.Fatalf("seeding user: %v", err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	got, err := NewUserRepository(db).FindByID(ctx, "a")
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if got.IMAPPassword != "encrypted" || got.PasswordHash != "" {
+		t.Errorf("unexpected credentials after migration: %+v", got)
+	}
+}
@@ -0,0 +1,48 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+	"github.com/akhil-datla/maildruid/internal/domain/verification"
+)
+
+func TestVerificationRepositorySaveReplaces(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewVerificationRepository(db)
+	ctx := context.Background()
+
+	users := NewUserRepository(db)
+	if err := users.Create(ctx, &user.User{ID: "u1", Email: "u1@example.com"}); err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	now := time.Now()
+	if err := repo.Save(ctx, &verification.Verification{UserID: "u1", Email: "a@example.com", TokenHash: "h1", ExpiresAt: now, CreatedAt: now}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := repo.Save(ctx, &verification.Verification{UserID: "u1", Email: "b@example.com", TokenHash: "h2", ExpiresAt: now, CreatedAt: now}); err != nil {
+		t.Fatalf("Save (replace): %v", err)
+	}
+
+	if _, err := repo.FindByTokenHash(ctx, "h1"); err != verification.ErrNotFound {
+		t.Errorf("expected old token to be replaced, got %v", err)
+	}
+	v, err := repo.FindByTokenHash(ctx, "h2")
+	if err != nil {
+		t.Fatalf("FindByTokenHash: %v", err)
+	}
+	if v.Email != "b@example.com" {
+		t.Errorf("expected b@example.com, got %s", v.Email)
+	}
+
+	// Deleting the user removes the pending verification.
+	if err := users.Delete(ctx, "u1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := repo.FindByUser(ctx, "u1"); err != verification.ErrNotFound {
+		t.Errorf("expected verification to cascade on user delete, got %v", err)
+	}
+}
@@ -0,0 +1,293 @@
+// Package push keeps a connection open to every mailbox in push mode and
+// hands new mail to the scheduler as it arrives.
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/config"
+	"github.com/akhil-datla/maildruid/internal/domain/mailbox"
+	"github.com/akhil-datla/maildruid/internal/domain/syncstate"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/imap"
+)
+
+// defaultFolder is watched when a mailbox names no folders.
+const defaultFolder = "INBOX"
+
+// Conn is a signed-in connection to a mailbox that can wait for new mail.
+// *imap.Client implements it.
+type Conn interface {
+	ResolveFolders(folders []string) ([]string, error)
+	Status(folder string) (*imap.FolderStatus, error)
+	Watch(stop <-chan struct{}, folders []string, pollInterval time.Duration, notify func()) error
+	Close() error
+}
+
+// Dialer signs in to a mailbox.
+type Dialer func(ctx context.Context, mb *mailbox.Mailbox) (Conn, error)
+
+// Lister returns the mailboxes to watch, in order of priority.
+type Lister func(ctx context.Context) ([]*mailbox.Mailbox, error)
+
+// Progress returns the sync states of a folder of a mailbox, one for each
+// digest reading it.
+type Progress func(ctx context.Context, mb *mailbox.Mailbox, folder string) ([]*syncstate.State, error)
+
+// Handler processes the new mail of a mailbox. An error means the mail
+// could not be handled yet, for example because a run is in progress, and
+// is retried after the debounce delay.
+type Handler func(mb *mailbox.Mailbox) error
+
+// Supervisor runs one watcher per mailbox in push mode, up to the
+// configured number of connections. Watchers reconnect with exponential
+// backoff, and the set of mailboxes is reloaded periodically so that
+// changes made through the API are picked up.
+type Supervisor struct {
+	cfg      config.PushConfig
+	list     Lister
+	dial     Dialer
+	progress Progress
+	handle   Handler
+	logger   *slog.Logger
+
+	mu       sync.Mutex
+	watchers map[string]*watcher // mailbox ID -> watcher
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// watcher is the bookkeeping for one watched mailbox.
+type watcher struct {
+	updatedAt time.Time // version of the mailbox being watched
+	cancel    context.CancelFunc
+}
+
+// New creates a supervisor. It does nothing until Start is called.
+func New(cfg config.PushConfig, list Lister, dial Dialer, progress Progress, handle Handler, logger *slog.Logger) *Supervisor {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Supervisor{
+		cfg:      cfg,
+		list:     list,
+		dial:     dial,
+		progress: progress,
+		handle:   handle,
+		logger:   logger,
+		watchers: make(map[string]*watcher),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Start loads the mailboxes to watch and keeps the set up to date until
+// Stop is called.
+func (s *Supervisor) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.cfg.RefreshInterval)
+		defer ticker.Stop()
+		for {
+			s.reconcile(s.ctx)
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	s.logger.Info("push mode started", "max_connections", s.cfg.MaxConnections)
+}
+
+// Stop closes every connection and waits for the watchers to exit.
+func (s *Supervisor) Stop() {
+	s.cancel()
+	s.wg.Wait()
+	s.logger.Info("push mode stopped")
+}
+
+// Watching returns the number of mailboxes being watched.
+func (s *Supervisor) Watching() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.watchers)
+}
+
+// reconcile starts and stops watchers to match the mailboxes in push mode.
+// A mailbox that changed since its watcher started is reconnected, so new
+// credentials and folders take effect.
+func (s *Supervisor) reconcile(ctx context.Context) {
+	mailboxes, err := s.list(ctx)
+	if err != nil {
+		s.logger.Error("failed to load mailboxes for push mode", "error", err)
+		return
+	}
+	if len(mailboxes) > s.cfg.MaxConnections {
+		s.logger.Warn("push connection limit reached, some mailboxes are not watched",
+			"mailboxes", len(mailboxes), "max_connections", s.cfg.MaxConnections)
+		mailboxes = mailboxes[:s.cfg.MaxConnections]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	want := make(map[string]*mailbox.Mailbox, len(mailboxes))
+	for _, mb := range mailboxes {
+		want[mb.ID] = mb
+	}
+	for id, w := range s.watchers {
+		if mb, ok := want[id]; !ok || !mb.UpdatedAt.Equal(w.updatedAt) {
+			w.cancel()
+			delete(s.watchers, id)
+		}
+	}
+	for _, mb := range mailboxes {
+		if _, ok := s.watchers[mb.ID]; ok {
+			continue
+		}
+		wctx, cancel := context.WithCancel(s.ctx)
+		s.watchers[mb.ID] = &watcher{updatedAt: mb.UpdatedAt, cancel: cancel}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.run(wctx, mb)
+		}()
+	}
+}
+
+// run keeps a mailbox connected until ctx is cancelled. The delay before
+// reconnecting doubles after every failure and starts over once a
+// connection has stayed up for longer than the maximum delay.
+func (s *Supervisor) run(ctx context.Context, mb *mailbox.Mailbox) {
+	backoff := s.cfg.MinBackoff
+	for {
+		started := time.Now()
+		err := s.session(ctx, mb)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > s.cfg.MaxBackoff {
+			backoff = s.cfg.MinBackoff
+		}
+		s.logger.Warn("push connection lost", "mailbox_id", mb.ID, "error", err, "retry_in", backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, s.cfg.MaxBackoff)
+	}
+}
+
+// session signs in, watches the mailbox's folders and hands new mail to
+// the handler until the connection fails or ctx is cancelled. Right after
+// signing in, each folder's UIDNEXT is compared with the stored sync
+// states, so a run only follows a reconnect if mail arrived in between.
+func (s *Supervisor) session(ctx context.Context, mb *mailbox.Mailbox) error {
+	conn, err := s.dial(ctx, mb)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	folders := []string{defaultFolder}
+	if len(mb.Folders) > 0 {
+		if folders, err = conn.ResolveFolders(mb.Folders); err != nil {
+			return err
+		}
+		if len(folders) == 0 {
+			return fmt.Errorf("no folders match %v", []string(mb.Folders))
+		}
+	}
+	missed, err := s.missed(ctx, conn, mb, folders)
+	if err != nil {
+		return err
+	}
+
+	events := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case events <- struct{}{}:
+		default:
+		}
+	}
+	stop := make(chan struct{})
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- conn.Watch(stop, folders, s.cfg.PollInterval, notify)
+	}()
+
+	// The first notification of a burst arms the timer and the rest are
+	// handled by the same run.
+	pending := time.NewTimer(s.cfg.Debounce)
+	pending.Stop()
+	defer pending.Stop()
+	armed := false
+	arm := func() {
+		if !armed {
+			pending.Reset(s.cfg.Debounce)
+			armed = true
+		}
+	}
+	if missed {
+		arm()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			close(stop)
+			<-watchErr
+			return nil
+		case err := <-watchErr:
+			if err == nil {
+				err = errors.New("watch ended")
+			}
+			return err
+		case <-events:
+			arm()
+		case <-pending.C:
+			armed = false
+			if err := s.handle(mb); err != nil {
+				s.logger.Debug("new mail not handled yet", "mailbox_id", mb.ID, "error", err)
+				arm()
+			}
+		}
+	}
+}
+
+// missed reports whether any of folders holds mail that a digest reading
+// the mailbox has not seen. When the stored progress cannot be loaded the
+// mail is assumed to be new, so none is skipped.
+func (s *Supervisor) missed(ctx context.Context, conn Conn, mb *mailbox.Mailbox, folders []string) (bool, error) {
+	for _, folder := range folders {
+		status, err := conn.Status(folder)
+		if err != nil {
+			return false, err
+		}
+		states, err := s.progress(ctx, mb, folder)
+		if err != nil {
+			s.logger.Warn("failed to load sync state for push mode", "mailbox_id", mb.ID, "folder", folder, "error", err)
+			return true, nil
+		}
+		for _, state := range states {
+			if behind(state, status) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// behind reports whether a folder holds messages past state. A server that
+// does not report UIDNEXT is assumed to have new mail.
+func behind(state *syncstate.State, status *imap.FolderStatus) bool {
+	return state.UIDValidity != status.UIDValidity || status.UIDNext == 0 || int(status.UIDNext)-1 > state.LastUID
+}
@@ -0,0 +1,249 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/config"
+	"github.com/akhil-datla/maildruid/internal/domain/mailbox"
+	"github.com/akhil-datla/maildruid/internal/domain/syncstate"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/imap"
+)
+
+// fakeConn waits on its folders until stop is closed, and lets the test
+// announce new mail or drop the connection. Every folder reports UIDNEXT
+// 11.
+type fakeConn struct {
+	folder    chan string // the folder waited on
+	newMail   chan struct{}
+	drop      chan error
+	loggedOut chan struct{}
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		folder:    make(chan string, 1),
+		newMail:   make(chan struct{}),
+		drop:      make(chan error),
+		loggedOut: make(chan struct{}),
+	}
+}
+
+func (c *fakeConn) ResolveFolders(folders []string) ([]string, error) { return folders, nil }
+
+func (c *fakeConn) Status(string) (*imap.FolderStatus, error) {
+	return &imap.FolderStatus{UIDValidity: 7, UIDNext: 11}, nil
+}
+
+func (c *fakeConn) Watch(stop <-chan struct{}, folders []string, _ time.Duration, notify func()) error {
+	c.folder <- folders[0]
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-c.newMail:
+			notify()
+		case err := <-c.drop:
+			return err
+		}
+	}
+}
+
+func (c *fakeConn) Close() error {
+	close(c.loggedOut)
+	return nil
+}
+
+// harness wires a supervisor to fake mailboxes, connections and handler.
+type harness struct {
+	*Supervisor
+	mu        sync.Mutex
+	mailboxes []*mailbox.Mailbox
+	dials     chan string    // mailbox ID of every dial attempt
+	conns     chan *fakeConn // connections handed out, in order
+	failDials int            // dials to fail before succeeding
+	lastUID   int            // how far every folder has been read
+	handled   chan string    // mailbox ID of every handled notification
+}
+
+func newHarness(t *testing.T, cfg config.PushConfig) *harness {
+	t.Helper()
+	h := &harness{
+		dials:   make(chan string, 16),
+		conns:   make(chan *fakeConn, 16),
+		handled: make(chan string, 16),
+	}
+	list := func(context.Context) ([]*mailbox.Mailbox, error) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return append([]*mailbox.Mailbox(nil), h.mailboxes...), nil
+	}
+	dial := func(_ context.Context, mb *mailbox.Mailbox) (Conn, error) {
+		h.dials <- mb.ID
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.failDials > 0 {
+			h.failDials--
+			return nil, errors.New("connection refused")
+		}
+		c := newFakeConn()
+		h.conns <- c
+		return c, nil
+	}
+	progress := func(_ context.Context, _ *mailbox.Mailbox, folder string) ([]*syncstate.State, error) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return []*syncstate.State{{Folder: folder, UIDValidity: 7, LastUID: h.lastUID}}, nil
+	}
+	handle := func(mb *mailbox.Mailbox) error {
+		h.handled <- mb.ID
+		return nil
+	}
+	h.Supervisor = New(cfg, list, dial, progress, handle, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(h.Stop)
+	return h
+}
+
+func (h *harness) setLastUID(uid int) {
+	h.mu.Lock()
+	h.lastUID = uid
+	h.mu.Unlock()
+}
+
+func (h *harness) setMailboxes(mbs ...*mailbox.Mailbox) {
+	h.mu.Lock()
+	h.mailboxes = mbs
+	h.mu.Unlock()
+}
+
+func testConfig() config.PushConfig {
+	return config.PushConfig{
+		Enabled:         true,
+		MaxConnections:  10,
+		PollInterval:    time.Hour,
+		Debounce:        10 * time.Millisecond,
+		MinBackoff:      10 * time.Millisecond,
+		MaxBackoff:      40 * time.Millisecond,
+		RefreshInterval: time.Hour,
+	}
+}
+
+func receive[T any](t *testing.T, ch <-chan T, what string) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+	var zero T
+	return zero
+}
+
+func TestSupervisorHandlesNewMail(t *testing.T) {
+	h := newHarness(t, testConfig())
+	h.setLastUID(8)
+	h.setMailboxes(&mailbox.Mailbox{ID: "m1", UserID: "u1", Folders: []string{"Lists/Security", "INBOX"}})
+	h.Start()
+
+	conn := receive(t, h.conns, "a connection")
+	if folder := receive(t, conn.folder, "the watched folder"); folder != "Lists/Security" {
+		t.Errorf("expected the first folder to be watched, got %q", folder)
+	}
+	// Mail that arrived while disconnected, UIDs 9 and 10, is handled on
+	// connect.
+	if id := receive(t, h.handled, "the catch-up run"); id != "m1" {
+		t.Errorf("expected mailbox m1 to be handled, got %q", id)
+	}
+
+	conn.newMail <- struct{}{}
+	conn.newMail <- struct{}{}
+	receive(t, h.handled, "a run for the new mail")
+	select {
+	case <-h.handled:
+		t.Error("expected a burst of new mail to be handled by one run")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSupervisorReconnectsWithoutRunWhenUpToDate(t *testing.T) {
+	h := newHarness(t, testConfig())
+	h.setLastUID(10)
+	h.setMailboxes(&mailbox.Mailbox{ID: "m1", UserID: "u1"})
+	h.Start()
+
+	conn := receive(t, h.conns, "a connection")
+	receive(t, conn.folder, "the watched folder")
+	conn.drop <- errors.New("connection reset")
+	conn = receive(t, h.conns, "a new connection")
+	receive(t, conn.folder, "the watched folder")
+	select {
+	case id := <-h.handled:
+		t.Errorf("expected no run without new mail, got one for %q", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	conn.newMail <- struct{}{}
+	receive(t, h.handled, "a run for the new mail")
+}
+
+func TestSupervisorReconnectsWithBackoff(t *testing.T) {
+	h := newHarness(t, testConfig())
+	h.failDials = 2
+	h.setMailboxes(&mailbox.Mailbox{ID: "m1", UserID: "u1"})
+	h.Start()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		receive(t, h.dials, "a dial attempt")
+	}
+	// Two failures wait 10ms and then 20ms before trying again.
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("expected reconnects to back off, took only %v", elapsed)
+	}
+	conn := receive(t, h.conns, "a connection")
+	receive(t, conn.folder, "the watched folder")
+
+	// A dropped connection is closed and reopened.
+	conn.drop <- errors.New("connection reset")
+	receive(t, conn.loggedOut, "the dropped connection to be closed")
+	receive(t, h.conns, "a new connection")
+}
+
+func TestSupervisorLimitsConnections(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxConnections = 2
+	h := newHarness(t, cfg)
+	m1 := &mailbox.Mailbox{ID: "m1", UserID: "u1"}
+	m2 := &mailbox.Mailbox{ID: "m2", UserID: "u1"}
+	m3 := &mailbox.Mailbox{ID: "m3", UserID: "u2"}
+	h.setMailboxes(m1, m2, m3)
+	h.reconcile(context.Background())
+
+	first := map[string]bool{receive(t, h.dials, "a dial"): true, receive(t, h.dials, "a dial"): true}
+	if !first["m1"] || !first["m2"] || h.Watching() != 2 {
+		t.Fatalf("expected only m1 and m2 to be watched, got %v", first)
+	}
+	c1, c2 := receive(t, h.conns, "a connection"), receive(t, h.conns, "a connection")
+
+	// Turning push off for one mailbox frees its slot for the next.
+	h.setMailboxes(m2, m3)
+	h.reconcile(context.Background())
+	if id := receive(t, h.dials, "a dial"); id != "m3" {
+		t.Errorf("expected m3 to take the free slot, got %q", id)
+	}
+	select {
+	case <-c1.loggedOut:
+	case <-c2.loggedOut:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the dropped mailbox's connection to be closed")
+	}
+	if h.Watching() != 2 {
+		t.Errorf("expected 2 watched mailboxes, got %d", h.Watching())
+	}
+}
@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -9,23 +10,63 @@ import (
 	"sync"
 	"time"
 
+	"github.com/akhil-datla/maildruid/internal/domain/mailbox"
 	"github.com/akhil-datla/maildruid/internal/domain/summary"
+	"github.com/akhil-datla/maildruid/internal/domain/team"
 	"github.com/akhil-datla/maildruid/internal/domain/user"
 	"github.com/akhil-datla/maildruid/internal/infrastructure/smtp"
 )
 
 // TaskInfo represents a scheduled task visible in the API.
 type TaskInfo struct {
-	Interval string   `json:"interval"`
-	UserIDs  []string `json:"userIds"`
+	Interval      string   `json:"interval"`
+	UserIDs       []string `json:"userIds"`
+	TeamDigestIDs []string `json:"teamDigestIds,omitempty"`
+}
+
+// ErrRunInProgress is returned by RunNow when a digest run for the user is
+// already underway.
+var ErrRunInProgress = errors.New("a run is already in progress")
+
+// ErrNoRecipients is reported when a team digest run is skipped because no
+// member has delivery turned on and a verified receiving email.
+var ErrNoRecipients = errors.New("no team member receives this digest")
+
+// Run states reported in RunStatus.
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+	RunCancelled = "cancelled"
+	RunSkipped   = "skipped"
+)
+
+// RunStatus describes the most recent digest run for a user or team digest
+// since the server started.
+type RunStatus struct {
+	State      string          `json:"state"`
+	Trigger    summary.Trigger `json:"trigger"`
+	StartedAt  time.Time       `json:"startedAt"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// run is the bookkeeping for a user's or team digest's current or last
+// run.
+type run struct {
+	status RunStatus
+	cancel context.CancelFunc // nil once the run has finished
 }
 
 // Scheduler manages periodic email processing tasks.
 type Scheduler struct {
 	mu         sync.RWMutex
-	tasks      map[string][]string            // interval -> []userID
-	stopChans  map[string]chan struct{}         // interval -> stop channel
+	tasks      map[string][]string      // interval -> []userID
+	teamTasks  map[string][]string      // interval -> []team digest ID
+	stopChans  map[string]chan struct{} // interval -> stop channel
+	runs       map[string]*run          // user or team digest ID -> current or last run
 	userSvc    *user.Service
+	teamSvc    *team.Service
 	summarySvc *summary.Service
 	mailer     *smtp.Sender
 	logger     *slog.Logger
@@ -34,12 +75,15 @@ type Scheduler struct {
 }
 
 // New creates a new scheduler.
-func New(userSvc *user.Service, summarySvc *summary.Service, mailer *smtp.Sender, logger *slog.Logger) *Scheduler {
+func New(userSvc *user.Service, teamSvc *team.Service, summarySvc *summary.Service, mailer *smtp.Sender, logger *slog.Logger) *Scheduler {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Scheduler{
 		tasks:      make(map[string][]string),
+		teamTasks:  make(map[string][]string),
 		stopChans:  make(map[string]chan struct{}),
+		runs:       make(map[string]*run),
 		userSvc:    userSvc,
+		teamSvc:    teamSvc,
 		summarySvc: summarySvc,
 		mailer:     mailer,
 		logger:     logger,
@@ -54,6 +98,10 @@ func (s *Scheduler) LoadExisting(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("loading users for scheduling: %w", err)
 	}
+	digests, err := s.teamSvc.ScheduledDigests(ctx)
+	if err != nil {
+		return fmt.Errorf("loading team digests for scheduling: %w", err)
+	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -67,18 +115,25 @@ func (s *Scheduler) LoadExisting(ctx context.Context) error {
 			s.startWorker(u.UpdateInterval)
 		}
 	}
+	for _, d := range digests {
+		s.teamTasks[d.UpdateInterval] = append(s.teamTasks[d.UpdateInterval], d.ID)
+		if _, exists := s.stopChans[d.UpdateInterval]; !exists {
+			s.startWorker(d.UpdateInterval)
+		}
+	}
 
-	s.logger.Info("loaded scheduled tasks", "intervals", len(s.tasks))
+	s.logger.Info("loaded scheduled tasks", "intervals", len(s.tasks), "team_intervals", len(s.teamTasks))
 	return nil
 }
 
-// AddTask schedules a new periodic task for a user.
-func (s *Scheduler) AddTask(userID, interval string) error {
+// AddTask schedules a new periodic task for a user. ctx attributes the
+// change in the audit log.
+func (s *Scheduler) AddTask(ctx context.Context, userID, interval string) error {
 	if _, err := strconv.Atoi(interval); err != nil {
 		return fmt.Errorf("interval must be a number (minutes): %w", err)
 	}
 
-	u, err := s.userSvc.GetByID(s.ctx, userID)
+	u, err := s.userSvc.GetByID(ctx, userID)
 	if err != nil {
 		return err
 	}
@@ -96,7 +151,7 @@ func (s *Scheduler) AddTask(userID, interval string) error {
 		s.startWorker(interval)
 	}
 
-	if err := s.userSvc.UpdateInterval(s.ctx, userID, interval); err != nil {
+	if err := s.userSvc.UpdateInterval(ctx, userID, interval); err != nil {
 		return fmt.Errorf("saving interval: %w", err)
 	}
 
@@ -105,7 +160,7 @@ func (s *Scheduler) AddTask(userID, interval string) error {
 }
 
 // RemoveTask removes a user's scheduled task.
-func (s *Scheduler) RemoveTask(userID, interval string) error {
+func (s *Scheduler) RemoveTask(ctx context.Context, userID, interval string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -115,13 +170,9 @@ func (s *Scheduler) RemoveTask(userID, interval string) error {
 	}
 
 	s.tasks[interval] = removeFromSlice(userIDs, userID)
+	s.prune(interval)
 
-	if len(s.tasks[interval]) == 0 {
-		s.stopWorker(interval)
-		delete(s.tasks, interval)
-	}
-
-	if err := s.userSvc.UpdateInterval(s.ctx, userID, "0"); err != nil {
+	if err := s.userSvc.UpdateInterval(ctx, userID, "0"); err != nil {
 		return fmt.Errorf("clearing interval: %w", err)
 	}
 
@@ -130,7 +181,7 @@ func (s *Scheduler) RemoveTask(userID, interval string) error {
 }
 
 // UpdateTask reschedules a task with a new interval.
-func (s *Scheduler) UpdateTask(userID, oldInterval, newInterval string) error {
+func (s *Scheduler) UpdateTask(ctx context.Context, userID, oldInterval, newInterval string) error {
 	if _, err := strconv.Atoi(newInterval); err != nil {
 		return fmt.Errorf("interval must be a number (minutes): %w", err)
 	}
@@ -140,10 +191,7 @@ func (s *Scheduler) UpdateTask(userID, oldInterval, newInterval string) error {
 	// Remove from old interval
 	if userIDs, ok := s.tasks[oldInterval]; ok {
 		s.tasks[oldInterval] = removeFromSlice(userIDs, userID)
-		if len(s.tasks[oldInterval]) == 0 {
-			s.stopWorker(oldInterval)
-			delete(s.tasks, oldInterval)
-		}
+		s.prune(oldInterval)
 	}
 
 	// Add to new interval
@@ -154,7 +202,7 @@ func (s *Scheduler) UpdateTask(userID, oldInterval, newInterval string) error {
 
 	s.mu.Unlock()
 
-	if err := s.userSvc.UpdateInterval(s.ctx, userID, newInterval); err != nil {
+	if err := s.userSvc.UpdateInterval(ctx, userID, newInterval); err != nil {
 		return fmt.Errorf("updating interval: %w", err)
 	}
 
@@ -169,10 +217,61 @@ func (s *Scheduler) RemoveAllForUser(userID string) {
 
 	for interval, userIDs := range s.tasks {
 		s.tasks[interval] = removeFromSlice(userIDs, userID)
-		if len(s.tasks[interval]) == 0 {
-			s.stopWorker(interval)
-			delete(s.tasks, interval)
-		}
+		s.prune(interval)
+	}
+}
+
+// ScheduleTeamDigest (re)schedules a team digest on its configured
+// interval, or removes it from the schedule when it has none.
+func (s *Scheduler) ScheduleTeamDigest(d *team.Digest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeTeamDigest(d.ID)
+	if !d.Scheduled() {
+		return
+	}
+	s.teamTasks[d.UpdateInterval] = append(s.teamTasks[d.UpdateInterval], d.ID)
+	if _, exists := s.stopChans[d.UpdateInterval]; !exists {
+		s.startWorker(d.UpdateInterval)
+	}
+	s.logger.Info("team digest scheduled", "digest_id", d.ID, "interval", d.UpdateInterval)
+}
+
+// UnscheduleTeamDigest removes a team digest from the schedule and aborts
+// a run in progress, if any.
+func (s *Scheduler) UnscheduleTeamDigest(digestID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeTeamDigest(digestID)
+	if r, ok := s.runs[digestID]; ok && r.cancel != nil {
+		r.cancel()
+	}
+}
+
+// removeTeamDigest drops a team digest from every interval. Must be called
+// with mu held.
+func (s *Scheduler) removeTeamDigest(digestID string) {
+	for interval, ids := range s.teamTasks {
+		s.teamTasks[interval] = removeFromSlice(ids, digestID)
+		s.prune(interval)
+	}
+}
+
+// prune stops an interval's worker once nothing is scheduled on it. Must
+// be called with mu held.
+func (s *Scheduler) prune(interval string) {
+	if len(s.tasks[interval]) == 0 {
+		delete(s.tasks, interval)
+	}
+	if len(s.teamTasks[interval]) == 0 {
+		delete(s.teamTasks, interval)
+	}
+	_, users := s.tasks[interval]
+	_, teams := s.teamTasks[interval]
+	if !users && !teams {
+		s.stopWorker(interval)
 	}
 }
 
@@ -181,15 +280,148 @@ func (s *Scheduler) ListTasks() []TaskInfo {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	tasks := make([]TaskInfo, 0, len(s.tasks))
+	tasks := make([]TaskInfo, 0, len(s.tasks)+len(s.teamTasks))
 	for interval, userIDs := range s.tasks {
 		ids := make([]string, len(userIDs))
 		copy(ids, userIDs)
-		tasks = append(tasks, TaskInfo{Interval: interval, UserIDs: ids})
+		tasks = append(tasks, TaskInfo{Interval: interval, UserIDs: ids, TeamDigestIDs: append([]string(nil), s.teamTasks[interval]...)})
+	}
+	for interval, digestIDs := range s.teamTasks {
+		if _, ok := s.tasks[interval]; ok {
+			continue
+		}
+		ids := make([]string, len(digestIDs))
+		copy(ids, digestIDs)
+		tasks = append(tasks, TaskInfo{Interval: interval, UserIDs: []string{}, TeamDigestIDs: ids})
+	}
+	return tasks
+}
+
+// ListTasksFor returns the scheduled tasks that include userID, listing
+// only that user.
+func (s *Scheduler) ListTasksFor(userID string) []TaskInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tasks := make([]TaskInfo, 0, 1)
+	for interval, userIDs := range s.tasks {
+		for _, id := range userIDs {
+			if id == userID {
+				tasks = append(tasks, TaskInfo{Interval: interval, UserIDs: []string{userID}})
+				break
+			}
+		}
 	}
 	return tasks
 }
 
+// RunNow starts a digest run for the user outside the schedule. The run
+// continues in the background; its outcome is reported by LastRun.
+func (s *Scheduler) RunNow(userID string) error {
+	u, err := s.userSvc.GetByID(s.ctx, userID)
+	if err != nil {
+		return err
+	}
+	if u.Disabled {
+		return user.ErrDisabled
+	}
+	if !u.ReceivingEmailVerified {
+		return user.ErrUnverified
+	}
+	ctx, ok := s.beginRun(userID, summary.TriggerManual)
+	if !ok {
+		return ErrRunInProgress
+	}
+	go s.processUser(ctx, userID, "", summary.TriggerManual)
+	return nil
+}
+
+// Push starts a run over a mailbox after new mail arrived on it in push
+// mode: the owner's digest for a user's mailbox, every digest reading it
+// for a team's. A run that finds no mail matching the digest's tags is
+// recorded as skipped, and failures are only logged, so that arriving mail
+// never produces an email of its own unless it matches. Like RunNow it
+// returns ErrRunInProgress while another run is underway.
+func (s *Scheduler) Push(mb *mailbox.Mailbox) error {
+	if mb.TeamID != "" {
+		return s.pushTeam(mb)
+	}
+	u, err := s.userSvc.GetByID(s.ctx, mb.UserID)
+	if err != nil {
+		return err
+	}
+	if u.Disabled || !u.ReceivingEmailVerified || len(u.Tags) == 0 {
+		return nil
+	}
+	ctx, ok := s.beginRun(u.ID, summary.TriggerPush)
+	if !ok {
+		return ErrRunInProgress
+	}
+	go s.processUser(ctx, u.ID, mb.ID, summary.TriggerPush)
+	return nil
+}
+
+// pushTeam starts a run of every digest reading a team mailbox. Digests
+// already running are left alone and reported with ErrRunInProgress, so
+// the new mail is offered again once they finish.
+func (s *Scheduler) pushTeam(mb *mailbox.Mailbox) error {
+	digests, err := s.teamSvc.MailboxDigests(s.ctx, mb)
+	if err != nil {
+		return err
+	}
+	var busy error
+	for _, d := range digests {
+		ctx, ok := s.beginRun(d.ID, summary.TriggerPush)
+		if !ok {
+			busy = ErrRunInProgress
+			continue
+		}
+		go s.processTeamDigest(ctx, d.ID, summary.TriggerPush)
+	}
+	return busy
+}
+
+// RunTeamDigestNow starts a run of a team digest outside the schedule.
+// Like RunNow, the run continues in the background and its outcome is
+// reported by LastRun under the digest's ID.
+func (s *Scheduler) RunTeamDigestNow(digestID string) error {
+	ctx, ok := s.beginRun(digestID, summary.TriggerManual)
+	if !ok {
+		return ErrRunInProgress
+	}
+	go s.processTeamDigest(ctx, digestID, summary.TriggerManual)
+	return nil
+}
+
+// Cancel removes the user's schedule and aborts a run in progress, if any.
+func (s *Scheduler) Cancel(ctx context.Context, userID string) error {
+	s.RemoveAllForUser(userID)
+
+	s.mu.Lock()
+	if r, ok := s.runs[userID]; ok && r.cancel != nil {
+		r.cancel()
+	}
+	s.mu.Unlock()
+
+	if err := s.userSvc.UpdateInterval(ctx, userID, "0"); err != nil {
+		return fmt.Errorf("clearing interval: %w", err)
+	}
+	s.logger.Info("schedule cancelled", "user_id", userID)
+	return nil
+}
+
+// LastRun returns the status of the current or most recent run of a user
+// or team digest.
+func (s *Scheduler) LastRun(userID string) (RunStatus, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.runs[userID]
+	if !ok {
+		return RunStatus{}, false
+	}
+	return r.status, true
+}
+
 // Stop shuts down all scheduled workers.
 func (s *Scheduler) Stop() {
 	s.cancel()
@@ -241,28 +473,118 @@ func (s *Scheduler) processTick(interval string) {
 	s.mu.RLock()
 	userIDs := make([]string, len(s.tasks[interval]))
 	copy(userIDs, s.tasks[interval])
+
+	digestIDs := make([]string, len(s.teamTasks[interval]))
+	copy(digestIDs, s.teamTasks[interval])
 	s.mu.RUnlock()
 
 	for _, userID := range userIDs {
-		go s.processUser(userID)
+		ctx, ok := s.beginRun(userID, summary.TriggerScheduled)
+		if !ok {
+			s.logger.Warn("skipping tick, previous run still in progress", "user_id", userID)
+			continue
+		}
+		go s.processUser(ctx, userID, "", summary.TriggerScheduled)
+	}
+	for _, digestID := range digestIDs {
+		ctx, ok := s.beginRun(digestID, summary.TriggerScheduled)
+		if !ok {
+			s.logger.Warn("skipping tick, previous run still in progress", "team_digest_id", digestID)
+			continue
+		}
+		go s.processTeamDigest(ctx, digestID, summary.TriggerScheduled)
 	}
 }
 
-func (s *Scheduler) processUser(userID string) {
-	u, err := s.userSvc.GetByID(s.ctx, userID)
+// beginRun records the start of a run for a user or team digest and
+// returns its context. It reports false if a run is already in progress.
+func (s *Scheduler) beginRun(id string, trigger summary.Trigger) (context.Context, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r, ok := s.runs[id]; ok && r.cancel != nil {
+		return nil, false
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.runs[id] = &run{
+		status: RunStatus{State: RunRunning, Trigger: trigger, StartedAt: time.Now()},
+		cancel: cancel,
+	}
+	return ctx, true
+}
+
+// finishRun records the outcome of a run in progress.
+func (s *Scheduler) finishRun(id, state string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.runs[id]
+	if !ok {
+		return
+	}
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+	now := time.Now()
+	r.status.State = state
+	r.status.FinishedAt = &now
+	if err != nil {
+		r.status.Error = err.Error()
+	}
+}
+
+// processUser runs the user's digest and emails the result. A push run
+// reads only mailboxID; other runs read every enabled mailbox.
+func (s *Scheduler) processUser(ctx context.Context, userID, mailboxID string, trigger summary.Trigger) {
+	u, err := s.userSvc.GetByID(ctx, userID)
 	if err != nil {
 		s.logger.Error("failed to get user for processing", "user_id", userID, "error", err)
+		s.finishRun(userID, RunFailed, err)
+		return
+	}
+	if u.Disabled {
+		s.finishRun(userID, RunSkipped, user.ErrDisabled)
+		return
+	}
+	// Never deliver to an address its owner has not confirmed.
+	if !u.ReceivingEmailVerified {
+		s.logger.Warn("skipping digest for unverified receiving email", "user_id", userID)
+		s.finishRun(userID, RunSkipped, user.ErrUnverified)
 		return
 	}
 
-	result, err := s.summarySvc.Generate(s.ctx, u)
+	var result *summary.Result
+	if mailboxID != "" {
+		result, err = s.summarySvc.GenerateMailbox(ctx, u, mailboxID, trigger)
+	} else {
+		result, err = s.summarySvc.Generate(ctx, u, trigger)
+	}
+	if ctx.Err() != nil {
+		s.logger.Info("summary run cancelled", "user_id", userID)
+		if result != nil && result.WordCloudPath != "" {
+			os.Remove(result.WordCloudPath)
+		}
+		s.finishRun(userID, RunCancelled, nil)
+		return
+	}
+	if err != nil && trigger == summary.TriggerPush {
+		if errors.Is(err, summary.ErrNoMatches) {
+			s.finishRun(userID, RunSkipped, err)
+			return
+		}
+		s.logger.Warn("push run failed", "user_id", userID, "mailbox_id", mailboxID, "error", err)
+		s.finishRun(userID, RunFailed, err)
+		return
+	}
 	if err != nil {
 		s.logger.Warn("summary generation failed", "user_id", userID, "error", err)
 		_ = s.mailer.SendSummary(u.ReceivingEmail, u.Name, u.Tags, "", "", fmt.Sprintf("Summary generation error: %s", err.Error()))
+		s.finishRun(userID, RunFailed, err)
 		return
 	}
 
-	sendErr := s.mailer.SendSummary(u.ReceivingEmail, u.Name, u.Tags, result.Summary, result.WordCloudPath, "")
+	sendErr := s.mailer.SendSummary(u.ReceivingEmail, u.Name, u.Tags, result.Summary, result.WordCloudPath, result.Note())
 	if sendErr != nil {
 		s.logger.Error("failed to send summary email", "user_id", userID, "error", sendErr)
 	}
@@ -271,9 +593,81 @@ func (s *Scheduler) processUser(userID string) {
 		os.Remove(result.WordCloudPath)
 	}
 
+	if sendErr != nil {
+		s.finishRun(userID, RunFailed, sendErr)
+		return
+	}
+	s.finishRun(userID, RunSucceeded, nil)
 	s.logger.Info("periodic summary sent", "user_id", userID)
 }
 
+// processTeamDigest runs a team digest and emails the result to every
+// member who receives it. Push runs are handled quietly, as for users.
+func (s *Scheduler) processTeamDigest(ctx context.Context, digestID string, trigger summary.Trigger) {
+	d, err := s.teamSvc.GetDigest(ctx, digestID)
+	if err != nil {
+		s.logger.Error("failed to get team digest for processing", "team_digest_id", digestID, "error", err)
+		s.finishRun(digestID, RunFailed, err)
+		return
+	}
+	recipients, err := s.teamSvc.Recipients(ctx, d.TeamID)
+	if err != nil {
+		s.logger.Error("failed to list team digest recipients", "team_digest_id", digestID, "error", err)
+		s.finishRun(digestID, RunFailed, err)
+		return
+	}
+	if len(recipients) == 0 {
+		s.finishRun(digestID, RunSkipped, ErrNoRecipients)
+		return
+	}
+
+	result, err := s.summarySvc.GenerateTeam(ctx, d, trigger)
+	if ctx.Err() != nil {
+		s.logger.Info("summary run cancelled", "team_digest_id", digestID)
+		if result != nil && result.WordCloudPath != "" {
+			os.Remove(result.WordCloudPath)
+		}
+		s.finishRun(digestID, RunCancelled, nil)
+		return
+	}
+	if err != nil && trigger == summary.TriggerPush {
+		if errors.Is(err, summary.ErrNoMatches) {
+			s.finishRun(digestID, RunSkipped, err)
+			return
+		}
+		s.logger.Warn("push run failed", "team_digest_id", digestID, "error", err)
+		s.finishRun(digestID, RunFailed, err)
+		return
+	}
+	if err != nil {
+		s.logger.Warn("summary generation failed", "team_digest_id", digestID, "error", err)
+		for _, r := range recipients {
+			_ = s.mailer.SendSummary(r.ReceivingEmail, r.Name, d.Tags, "", "", fmt.Sprintf("Summary generation error: %s", err.Error()))
+		}
+		s.finishRun(digestID, RunFailed, err)
+		return
+	}
+
+	var sendErr error
+	for _, r := range recipients {
+		if err := s.mailer.SendSummary(r.ReceivingEmail, r.Name, d.Tags, result.Summary, result.WordCloudPath, result.Note()); err != nil {
+			s.logger.Error("failed to send summary email", "team_digest_id", digestID, "user_id", r.ID, "error", err)
+			sendErr = err
+		}
+	}
+
+	if result.WordCloudPath != "" {
+		os.Remove(result.WordCloudPath)
+	}
+
+	if sendErr != nil {
+		s.finishRun(digestID, RunFailed, sendErr)
+		return
+	}
+	s.finishRun(digestID, RunSucceeded, nil)
+	s.logger.Info("team summary sent", "team_digest_id", digestID, "recipients", len(recipients))
+}
+
 func removeFromSlice(s []string, item string) []string {
 	result := make([]string, 0, len(s))
 	for _, v := range s {
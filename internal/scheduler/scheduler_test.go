@@ -1,7 +1,17 @@
 package scheduler
 
 import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
 	"testing"
+
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/akhil-datla/maildruid/internal/domain/mailbox"
+	"github.com/akhil-datla/maildruid/internal/domain/summary"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/encryption"
 )
 
 func TestRemoveFromSlice(t *testing.T) {
@@ -35,3 +45,89 @@ func TestRemoveFromSlice(t *testing.T) {
 		})
 	}
 }
+
+func TestRunBookkeeping(t *testing.T) {
+	s := New(nil, nil, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer s.Stop()
+
+	if _, ok := s.LastRun("u1"); ok {
+		t.Fatal("expected no run before the first one starts")
+	}
+
+	ctx, ok := s.beginRun("u1", summary.TriggerManual)
+	if !ok {
+		t.Fatal("expected first run to start")
+	}
+	if _, ok := s.beginRun("u1", summary.TriggerScheduled); ok {
+		t.Error("expected overlapping run to be refused")
+	}
+	if st, _ := s.LastRun("u1"); st.State != RunRunning || st.Trigger != summary.TriggerManual {
+		t.Errorf("expected running manual run, got %+v", st)
+	}
+
+	s.finishRun("u1", RunFailed, errors.New("imap down"))
+	if ctx.Err() == nil {
+		t.Error("expected run context to be released when the run finishes")
+	}
+	st, _ := s.LastRun("u1")
+	if st.State != RunFailed || st.Error != "imap down" || st.FinishedAt == nil {
+		t.Errorf("unexpected status %+v", st)
+	}
+
+	if _, ok := s.beginRun("u1", summary.TriggerScheduled); !ok {
+		t.Error("expected a new run once the previous one finished")
+	}
+}
+
+func TestListTasksFor(t *testing.T) {
+	s := New(nil, nil, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s.tasks["60"] = []string{"a", "b"}
+	s.tasks["30"] = []string{"c"}
+
+	tasks := s.ListTasksFor("b")
+	if len(tasks) != 1 || tasks[0].Interval != "60" || len(tasks[0].UserIDs) != 1 || tasks[0].UserIDs[0] != "b" {
+		t.Errorf("expected only b's task, got %+v", tasks)
+	}
+	if tasks := s.ListTasksFor("z"); len(tasks) != 0 {
+		t.Errorf("expected no tasks, got %+v", tasks)
+	}
+}
+
+func TestUnverifiedReceivingEmailIsSkipped(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	enc, err := encryption.New([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("encryption.New: %v", err)
+	}
+	userSvc := user.NewService(user.NewMemoryRepository(), enc, audit.NewService(audit.NewMemoryRepository(), logger), logger)
+	ctx := context.Background()
+	if err := userSvc.Create(ctx, user.CreateInput{
+		Name: "Test", Email: "u@ex.com", ReceivingEmail: "r@ex.com",
+		Password: "secret123",
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	u, _ := userSvc.GetByEmail(ctx, "u@ex.com")
+
+	// No summary service or mailer: reaching either would panic.
+	s := New(userSvc, nil, nil, nil, logger)
+	defer s.Stop()
+
+	if err := s.RunNow(u.ID); !errors.Is(err, user.ErrUnverified) {
+		t.Errorf("expected RunNow to refuse an unverified address, got %v", err)
+	}
+
+	// New mail in push mode is ignored rather than reported.
+	if err := s.Push(&mailbox.Mailbox{ID: "m1", UserID: u.ID}); err != nil {
+		t.Errorf("Push: %v", err)
+	}
+	if _, ok := s.LastRun(u.ID); ok {
+		t.Error("expected no push run for an unverified address")
+	}
+
+	runCtx, _ := s.beginRun(u.ID, summary.TriggerScheduled)
+	s.processUser(runCtx, u.ID, "", summary.TriggerScheduled)
+	if st, _ := s.LastRun(u.ID); st.State != RunSkipped || st.Error != user.ErrUnverified.Error() {
+		t.Errorf("expected skipped run, got %+v", st)
+	}
+}
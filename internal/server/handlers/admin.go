@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/akhil-datla/maildruid/internal/domain/team"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+	"github.com/akhil-datla/maildruid/internal/scheduler"
+	"github.com/akhil-datla/maildruid/internal/server/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// AdminHandler handles operator endpoints for managing users and their
+// schedules. Routes are expected to sit behind middleware.RequireAdmin.
+type AdminHandler struct {
+	userSvc   *user.Service
+	teamSvc   *team.Service
+	scheduler *scheduler.Scheduler
+	logger    *slog.Logger
+}
+
+// NewAdminHandler creates a new admin handler.
+func NewAdminHandler(userSvc *user.Service, teamSvc *team.Service, sched *scheduler.Scheduler, logger *slog.Logger) *AdminHandler {
+	return &AdminHandler{userSvc: userSvc, teamSvc: teamSvc, scheduler: sched, logger: logger}
+}
+
+// ListUsers returns a page of users, optionally filtered by a search on
+// name and email.
+// GET /api/v1/admin/users
+func (h *AdminHandler) ListUsers(c echo.Context) error {
+	var req ListUsersRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = defaultPageSize
+	}
+
+	users, total, err := h.userSvc.Search(c.Request().Context(), req.Query, req.PageSize, (req.Page-1)*req.PageSize)
+	if err != nil {
+		h.logger.Error("listing users failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, errResp("failed to list users"))
+	}
+
+	return c.JSON(http.StatusOK, UserListResponse{
+		Items:    users,
+		Total:    total,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+	})
+}
+
+// GetUser returns a single user.
+// GET /api/v1/admin/users/:id
+func (h *AdminHandler) GetUser(c echo.Context) error {
+	u, err := h.userSvc.GetByID(c.Request().Context(), c.Param("id"))
+	if errors.Is(err, user.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, errResp("user not found"))
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("failed to get user"))
+	}
+	return c.JSON(http.StatusOK, u)
+}
+
+// DisableUser blocks sign-in for a user, revokes their sessions and stops
+// their digests. API keys stop working while the account is disabled.
+// POST /api/v1/admin/users/:id/disable
+func (h *AdminHandler) DisableUser(c echo.Context) error {
+	return h.setDisabled(c, true)
+}
+
+// EnableUser re-enables a disabled user.
+// POST /api/v1/admin/users/:id/enable
+func (h *AdminHandler) EnableUser(c echo.Context) error {
+	return h.setDisabled(c, false)
+}
+
+func (h *AdminHandler) setDisabled(c echo.Context, disabled bool) error {
+	id := c.Param("id")
+	if disabled && id == middleware.GetUserID(c) {
+		return c.JSON(http.StatusBadRequest, errResp("cannot disable your own account"))
+	}
+
+	err := h.userSvc.SetDisabled(c.Request().Context(), id, disabled)
+	if errors.Is(err, user.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, errResp("user not found"))
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("failed to update user"))
+	}
+
+	h.logger.Info("admin changed account state", "admin_id", middleware.GetUserID(c), "user_id", id, "disabled", disabled)
+	if disabled {
+		return c.JSON(http.StatusOK, msgOK("user disabled"))
+	}
+	return c.JSON(http.StatusOK, msgOK("user enabled"))
+}
+
+// DeleteUser removes a user and their schedule. It is refused while the
+// user is the only owner of a team.
+// DELETE /api/v1/admin/users/:id
+func (h *AdminHandler) DeleteUser(c echo.Context) error {
+	id := c.Param("id")
+	if id == middleware.GetUserID(c) {
+		return c.JSON(http.StatusBadRequest, errResp("cannot delete your own account here"))
+	}
+
+	ctx := c.Request().Context()
+	if _, err := h.userSvc.GetByID(ctx, id); errors.Is(err, user.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, errResp("user not found"))
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("failed to get user"))
+	}
+	if err := h.teamSvc.KeepOwners(ctx, id); errors.Is(err, team.ErrLastOwner) {
+		return c.JSON(http.StatusConflict, errResp("user is the only owner of a team"))
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("failed to delete user"))
+	}
+
+	h.scheduler.RemoveAllForUser(id)
+	if err := h.userSvc.Delete(ctx, id); err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("failed to delete user"))
+	}
+
+	h.logger.Info("admin deleted user", "admin_id", middleware.GetUserID(c), "user_id", id)
+	return c.JSON(http.StatusOK, msgOK("user deleted successfully"))
+}
+
+// GetSchedule returns a user's schedule and the status of their last run.
+// GET /api/v1/admin/users/:id/schedule
+func (h *AdminHandler) GetSchedule(c echo.Context) error {
+	id := c.Param("id")
+	u, err := h.userSvc.GetByID(c.Request().Context(), id)
+	if errors.Is(err, user.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, errResp("user not found"))
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("failed to get user"))
+	}
+
+	resp := ScheduleStatusResponse{Interval: u.UpdateInterval}
+	if run, ok := h.scheduler.LastRun(id); ok {
+		resp.LastRun = &run
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// RunSchedule starts a digest run for a user immediately. The run happens
+// in the background; poll GetSchedule for its outcome.
+// POST /api/v1/admin/users/:id/schedule/run
+func (h *AdminHandler) RunSchedule(c echo.Context) error {
+	id := c.Param("id")
+	err := h.scheduler.RunNow(id)
+	switch {
+	case errors.Is(err, user.ErrNotFound):
+		return c.JSON(http.StatusNotFound, errResp("user not found"))
+	case errors.Is(err, user.ErrDisabled):
+		return c.JSON(http.StatusConflict, errResp("account is disabled"))
+	case errors.Is(err, user.ErrUnverified):
+		return c.JSON(http.StatusConflict, errResp("receiving email is not verified"))
+	case errors.Is(err, scheduler.ErrRunInProgress):
+		return c.JSON(http.StatusConflict, errResp("a run is already in progress"))
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, errResp("failed to start run"))
+	}
+
+	h.logger.Info("admin started digest run", "admin_id", middleware.GetUserID(c), "user_id", id)
+	return c.JSON(http.StatusAccepted, msgOK("run started"))
+}
+
+// CancelSchedule removes a user's schedule and aborts a run in progress.
+// DELETE /api/v1/admin/users/:id/schedule
+func (h *AdminHandler) CancelSchedule(c echo.Context) error {
+	id := c.Param("id")
+	if _, err := h.userSvc.GetByID(c.Request().Context(), id); errors.Is(err, user.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, errResp("user not found"))
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("failed to get user"))
+	}
+
+	if err := h.scheduler.Cancel(c.Request().Context(), id); err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("failed to cancel schedule"))
+	}
+
+	h.logger.Info("admin cancelled schedule", "admin_id", middleware.GetUserID(c), "user_id", id)
+	return c.JSON(http.StatusOK, msgOK("schedule cancelled"))
+}
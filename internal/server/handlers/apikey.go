@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/akhil-datla/maildruid/internal/domain/apikey"
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/akhil-datla/maildruid/internal/server/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// APIKeyHandler handles personal API key management endpoints.
+type APIKeyHandler struct {
+	apiKeySvc *apikey.Service
+	auditSvc  *audit.Service
+}
+
+// NewAPIKeyHandler creates a new API key handler.
+func NewAPIKeyHandler(apiKeySvc *apikey.Service, auditSvc *audit.Service) *APIKeyHandler {
+	return &APIKeyHandler{apiKeySvc: apiKeySvc, auditSvc: auditSvc}
+}
+
+// Create issues a new API key. The key is only returned in this response.
+// POST /api/v1/users/me/api-keys
+func (h *APIKeyHandler) Create(c echo.Context) error {
+	var req CreateAPIKeyRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	id := middleware.GetUserID(c)
+	k, key, err := h.apiKeySvc.Create(ctx, id, apikey.CreateInput{
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if errors.Is(err, apikey.ErrInvalidScope) {
+		return c.JSON(http.StatusBadRequest, errResp(err.Error()))
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("failed to create api key"))
+	}
+	h.auditSvc.TryRecord(ctx, audit.Event{
+		UserID: id,
+		Action: audit.ActionAPIKeyCreated,
+		Detail: k.ID,
+		Changes: audit.Changes{
+			{Field: "name", After: k.Name},
+			{Field: "scopes", After: []string(k.Scopes)},
+		},
+	})
+
+	return c.JSON(http.StatusCreated, CreateAPIKeyResponse{APIKey: k, Key: key})
+}
+
+// List returns the authenticated user's API keys.
+// GET /api/v1/users/me/api-keys
+func (h *APIKeyHandler) List(c echo.Context) error {
+	id := middleware.GetUserID(c)
+	keys, err := h.apiKeySvc.List(c.Request().Context(), id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("failed to list api keys"))
+	}
+	if keys == nil {
+		keys = []*apikey.APIKey{}
+	}
+	return c.JSON(http.StatusOK, keys)
+}
+
+// Delete revokes one of the authenticated user's API keys.
+// DELETE /api/v1/users/me/api-keys/:id
+func (h *APIKeyHandler) Delete(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := middleware.GetUserID(c)
+	err := h.apiKeySvc.Delete(ctx, id, c.Param("id"))
+	if errors.Is(err, apikey.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, errResp("api key not found"))
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("failed to revoke api key"))
+	}
+	h.auditSvc.TryRecord(ctx, audit.Event{UserID: id, Action: audit.ActionAPIKeyDeleted, Detail: c.Param("id")})
+	return c.JSON(http.StatusOK, msgOK("api key revoked"))
+}
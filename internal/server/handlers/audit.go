@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/akhil-datla/maildruid/internal/domain/team"
+	"github.com/akhil-datla/maildruid/internal/server/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// AuditHandler exposes the audit log.
+type AuditHandler struct {
+	auditSvc *audit.Service
+	teamSvc  *team.Service
+	logger   *slog.Logger
+}
+
+// NewAuditHandler creates a new audit handler.
+func NewAuditHandler(auditSvc *audit.Service, teamSvc *team.Service, logger *slog.Logger) *AuditHandler {
+	return &AuditHandler{auditSvc: auditSvc, teamSvc: teamSvc, logger: logger}
+}
+
+// ListMine returns the events recorded for the authenticated user's
+// account, newest first.
+// GET /api/v1/users/me/audit
+func (h *AuditHandler) ListMine(c echo.Context) error {
+	var req ListAuditRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	return h.list(c, audit.Filter{
+		UserID: middleware.GetUserID(c),
+		Action: req.Action,
+		Since:  req.Since,
+		Until:  req.Until,
+	}, req.Page, req.PageSize)
+}
+
+// List returns events across all accounts, newest first. It can be
+// narrowed to the account an event is about, the user who caused it, an
+// action and a time range.
+// GET /api/v1/admin/audit
+func (h *AuditHandler) List(c echo.Context) error {
+	var req AdminListAuditRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	return h.list(c, audit.Filter{
+		UserID:  req.UserID,
+		ActorID: req.ActorID,
+		Action:  req.Action,
+		Since:   req.Since,
+		Until:   req.Until,
+	}, req.Page, req.PageSize)
+}
+
+// ListTeam returns the events recorded for a team, newest first. Team
+// owners only.
+// GET /api/v1/teams/:id/audit
+func (h *AuditHandler) ListTeam(c echo.Context) error {
+	var req ListAuditRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	teamID := c.Param("id")
+	err := h.teamSvc.Authorize(c.Request().Context(), middleware.GetUserID(c), teamID, team.RoleOwner)
+	switch {
+	case errors.Is(err, team.ErrNotFound):
+		return c.JSON(http.StatusNotFound, errResp("team not found"))
+	case errors.Is(err, team.ErrForbidden):
+		return c.JSON(http.StatusForbidden, errResp("your team role does not allow this"))
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, errResp("failed to list audit events"))
+	}
+	return h.list(c, audit.Filter{
+		TeamID: teamID,
+		Action: req.Action,
+		Since:  req.Since,
+		Until:  req.Until,
+	}, req.Page, req.PageSize)
+}
+
+func (h *AuditHandler) list(c echo.Context, f audit.Filter, page, pageSize int) error {
+	if page == 0 {
+		page = 1
+	}
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+	if !f.Since.IsZero() && !f.Until.IsZero() && !f.Until.After(f.Since) {
+		return c.JSON(http.StatusBadRequest, errResp("until must be after since"))
+	}
+	f.Limit = pageSize
+	f.Offset = (page - 1) * pageSize
+
+	events, total, err := h.auditSvc.List(c.Request().Context(), f)
+	if err != nil {
+		h.logger.Error("listing audit events failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, errResp("failed to list audit events"))
+	}
+	if events == nil {
+		events = []*audit.Event{}
+	}
+
+	return c.JSON(http.StatusOK, AuditListResponse{
+		Items:    events,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
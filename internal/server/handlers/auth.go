@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/config"
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/akhil-datla/maildruid/internal/domain/lockout"
+	"github.com/akhil-datla/maildruid/internal/domain/mfa"
+	"github.com/akhil-datla/maildruid/internal/domain/session"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+	"github.com/akhil-datla/maildruid/internal/server/middleware"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// challengeTTL is how long a user has to complete the second login step.
+const challengeTTL = 5 * time.Minute
+
+// challengeClaims identify a user who passed the first login step, with a
+// password or single sign-on, but still has to present a TOTP code.
+type challengeClaims struct {
+	UserID     string `json:"uid"`
+	Generation int    `json:"gen"`
+	Method     string `json:"method"`
+	jwt.RegisteredClaims
+}
+
+// AuthHandler handles login, token refresh and logout.
+type AuthHandler struct {
+	userSvc    *user.Service
+	sessionSvc *session.Service
+	mfaSvc     *mfa.Service
+	lockoutSvc *lockout.Service
+	auditSvc   *audit.Service
+	tokenKeys  middleware.TokenKeys
+	authCfg    config.AuthConfig
+}
+
+// NewAuthHandler creates a new auth handler.
+func NewAuthHandler(
+	userSvc *user.Service,
+	sessionSvc *session.Service,
+	mfaSvc *mfa.Service,
+	lockoutSvc *lockout.Service,
+	auditSvc *audit.Service,
+	tokenKeys middleware.TokenKeys,
+	authCfg config.AuthConfig,
+) *AuthHandler {
+	return &AuthHandler{
+		userSvc: userSvc, sessionSvc: sessionSvc, mfaSvc: mfaSvc, lockoutSvc: lockoutSvc,
+		auditSvc: auditSvc, tokenKeys: tokenKeys, authCfg: authCfg,
+	}
+}
+
+// Login authenticates a user and returns an access token and refresh token.
+// Users with two-factor authentication, or all users when it is required,
+// get a challenge token instead that is completed at /auth/2fa. Repeated
+// failures are answered with 429 and a Retry-After header.
+// POST /api/v1/auth/login
+func (h *AuthHandler) Login(c echo.Context) error {
+	var req LoginRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	ip := c.RealIP()
+	if err := h.lockoutSvc.Check(ctx, req.Email, ip); err != nil {
+		return loginBlocked(c, err)
+	}
+
+	id, err := h.userSvc.Authenticate(ctx, req.Email, req.Password)
+	if errors.Is(err, user.ErrInvalidPassword) {
+		if err := h.lockoutSvc.Failed(ctx, req.Email, ip); err != nil {
+			return c.JSON(http.StatusInternalServerError, errResp("authentication failed"))
+		}
+		return c.JSON(http.StatusUnauthorized, errResp("invalid credentials"))
+	}
+	if err == nil || errors.Is(err, user.ErrDisabled) {
+		if err := h.lockoutSvc.Succeeded(ctx, req.Email); err != nil {
+			return c.JSON(http.StatusInternalServerError, errResp("authentication failed"))
+		}
+	}
+	if errors.Is(err, user.ErrDisabled) {
+		return c.JSON(http.StatusForbidden, errResp("account is disabled"))
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("authentication failed"))
+	}
+
+	challenge, err := newChallenge(ctx, h.userSvc, h.mfaSvc, h.authCfg, id, "password")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("authentication failed"))
+	}
+	if challenge != nil {
+		return c.JSON(http.StatusOK, challenge)
+	}
+
+	sess, refresh, err := h.sessionSvc.Issue(ctx, id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("could not start session"))
+	}
+	h.auditSvc.TryRecord(ctx, audit.Event{UserID: id, ActorID: id, Action: audit.ActionLoginSucceeded, Detail: "password"})
+
+	return h.tokens(c, sess, refresh)
+}
+
+// Unlock lifts an account lockout with the token from an unlock email.
+// The emailed link opens a page that submits the token, so merely
+// fetching the link, as mail scanners do, does not unlock anything.
+// POST /api/v1/auth/unlock
+func (h *AuthHandler) Unlock(c echo.Context) error {
+	var req UnlockRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	err := h.lockoutSvc.Unlock(c.Request().Context(), req.Token, c.RealIP())
+	if errors.Is(err, lockout.ErrInvalidToken) {
+		return c.JSON(http.StatusBadRequest, errResp("invalid or expired unlock link"))
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("failed to unlock account"))
+	}
+	return c.JSON(http.StatusOK, msgOK("account unlocked, you can sign in again"))
+}
+
+// CompleteTwoFactor finishes a login with a TOTP or recovery code. If the
+// user was asked to enroll, the code confirms the enrollment and the
+// response carries the new recovery codes.
+// POST /api/v1/auth/2fa
+func (h *AuthHandler) CompleteTwoFactor(c echo.Context) error {
+	var req TwoFactorLoginRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	claims, err := h.parseChallenge(c, req.ChallengeToken)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, errResp("invalid or expired challenge"))
+	}
+	id := claims.UserID
+
+	enabled, err := h.mfaSvc.Enabled(ctx, id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("authentication failed"))
+	}
+	var codes []string
+	if enabled {
+		err = h.mfaSvc.Verify(ctx, id, req.Code)
+	} else {
+		codes, err = h.mfaSvc.Confirm(ctx, id, req.Code)
+	}
+	if errors.Is(err, mfa.ErrInvalidCode) {
+		h.auditSvc.TryRecord(ctx, audit.Event{UserID: id, ActorID: id, Action: audit.ActionLoginFailed, Detail: "wrong two-factor code"})
+	}
+	if err != nil {
+		return mfaError(c, err)
+	}
+	if !enabled {
+		h.auditSvc.TryRecord(ctx, audit.Event{UserID: id, ActorID: id, Action: audit.ActionTwoFactorEnabled})
+	}
+
+	sess, refresh, err := h.sessionSvc.Issue(ctx, id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("could not start session"))
+	}
+	h.auditSvc.TryRecord(ctx, audit.Event{UserID: id, ActorID: id, Action: audit.ActionLoginSucceeded, Detail: claims.Method + "+totp"})
+	tokens, err := signTokens(h.tokenKeys, h.authCfg, sess, refresh)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("could not generate token"))
+	}
+
+	return c.JSON(http.StatusOK, TwoFactorLoginResponse{TokenResponse: tokens, RecoveryCodes: codes})
+}
+
+// EnrollTwoFactor starts TOTP enrollment during a login that requires
+// two-factor authentication the user has not set up yet.
+// POST /api/v1/auth/2fa/enroll
+func (h *AuthHandler) EnrollTwoFactor(c echo.Context) error {
+	var req TwoFactorEnrollRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	claims, err := h.parseChallenge(c, req.ChallengeToken)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, errResp("invalid or expired challenge"))
+	}
+
+	enrollment, err := h.mfaSvc.Enroll(c.Request().Context(), claims.UserID)
+	if err != nil {
+		return mfaError(c, err)
+	}
+	return c.JSON(http.StatusOK, enrollment)
+}
+
+// Refresh exchanges a refresh token for a new access token and refresh token.
+// POST /api/v1/auth/refresh
+func (h *AuthHandler) Refresh(c echo.Context) error {
+	var req RefreshRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	sess, refresh, err := h.sessionSvc.Refresh(c.Request().Context(), req.RefreshToken)
+	if errors.Is(err, session.ErrInvalidToken) {
+		return c.JSON(http.StatusUnauthorized, errResp("invalid or expired refresh token"))
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("could not refresh session"))
+	}
+
+	return h.tokens(c, sess, refresh)
+}
+
+// Logout revokes the current access token and, if given, the refresh token.
+// With allSessions set, every token issued to the user is revoked.
+// POST /api/v1/auth/logout
+func (h *AuthHandler) Logout(c echo.Context) error {
+	var req LogoutRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	id := middleware.GetUserID(c)
+
+	if req.AllSessions {
+		if err := h.userSvc.RevokeTokens(ctx, id); err != nil {
+			return c.JSON(http.StatusInternalServerError, errResp("failed to log out"))
+		}
+		if err := h.sessionSvc.RevokeAll(ctx, id); err != nil {
+			return c.JSON(http.StatusInternalServerError, errResp("failed to log out"))
+		}
+		return c.JSON(http.StatusOK, msgOK("logged out of all sessions"))
+	}
+
+	if claims := middleware.GetClaims(c); claims != nil && claims.ExpiresAt != nil {
+		if err := h.sessionSvc.RevokeAccessToken(ctx, id, claims.ID, claims.ExpiresAt.Time); err != nil {
+			return c.JSON(http.StatusInternalServerError, errResp("failed to log out"))
+		}
+	}
+	if req.RefreshToken != "" {
+		if err := h.sessionSvc.Revoke(ctx, id, req.RefreshToken); err != nil {
+			return c.JSON(http.StatusInternalServerError, errResp("failed to log out"))
+		}
+	}
+
+	return c.JSON(http.StatusOK, msgOK("logged out"))
+}
+
+// loginBlocked answers a login attempt refused by the lockout service.
+func loginBlocked(c echo.Context, err error) error {
+	var blocked *lockout.BlockedError
+	if !errors.As(err, &blocked) {
+		return c.JSON(http.StatusInternalServerError, errResp("authentication failed"))
+	}
+	c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+	if blocked.Locked {
+		return c.JSON(http.StatusTooManyRequests, errResp("too many failed login attempts, try again later"))
+	}
+	return c.JSON(http.StatusTooManyRequests, errResp("too many failed login attempts, slow down"))
+}
+
+// newChallenge returns the second login step for a user who passed the
+// first one with method, or nil when the user needs no second factor:
+// neither has TOTP enabled nor is it required.
+func newChallenge(ctx context.Context, userSvc *user.Service, mfaSvc *mfa.Service, authCfg config.AuthConfig, userID, method string) (*ChallengeResponse, error) {
+	enabled, err := mfaSvc.Enabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled && !authCfg.Require2FA {
+		return nil, nil
+	}
+
+	gen, err := userSvc.TokenGeneration(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, challengeClaims{
+		UserID:     userID,
+		Generation: gen,
+		Method:     method,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(challengeTTL)),
+		},
+	})
+	signed, err := token.SignedString(challengeKey(authCfg))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChallengeResponse{
+		ChallengeToken:     signed,
+		ExpiresIn:          int(challengeTTL.Seconds()),
+		EnrollmentRequired: !enabled,
+	}, nil
+}
+
+// parseChallenge validates a challenge token and returns its claims. A
+// password change since the challenge was issued invalidates it.
+func (h *AuthHandler) parseChallenge(c echo.Context, raw string) (*challengeClaims, error) {
+	claims := &challengeClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		return challengeKey(h.authCfg), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+
+	gen, err := h.userSvc.TokenGeneration(c.Request().Context(), claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if gen != claims.Generation {
+		return nil, errors.New("challenge issued before credentials changed")
+	}
+	return claims, nil
+}
+
+func challengeKey(authCfg config.AuthConfig) []byte {
+	return deriveKey(authCfg.SigningKey, "maildruid 2fa challenge")
+}
+
+// tokens signs an access token for sess and writes it with the refresh token.
+func (h *AuthHandler) tokens(c echo.Context, sess *session.Session, refresh string) error {
+	resp, err := signTokens(h.tokenKeys, h.authCfg, sess, refresh)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("could not generate token"))
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// signTokens signs an access token for sess and pairs it with the refresh
+// token.
+func signTokens(keys middleware.TokenKeys, authCfg config.AuthConfig, sess *session.Session, refresh string) (*TokenResponse, error) {
+	token, err := middleware.GenerateToken(sess.UserID, sess.Generation, keys, authCfg.TokenExpiry)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenResponse{
+		Token:        token,
+		RefreshToken: refresh,
+		ExpiresIn:    int(authCfg.TokenExpiry.Seconds()),
+	}, nil
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"net/http"
 
 	"github.com/go-playground/validator/v10"
@@ -48,3 +50,12 @@ func bindAndValidate(c echo.Context, req interface{}) error {
 	}
 	return nil
 }
+
+// deriveKey derives a key for a single purpose from the JWT signing key,
+// so short-lived tokens such as login challenges can never pass as access
+// tokens.
+func deriveKey(signingKey, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/config"
+	"github.com/akhil-datla/maildruid/internal/domain/mailbox"
+	"github.com/akhil-datla/maildruid/internal/domain/team"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/oauth"
+	"github.com/akhil-datla/maildruid/internal/server/middleware"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// imapOAuthCookie carries the state and PKCE verifier of a mailbox
+	// authorization in progress, and the mailbox it is for, between the
+	// redirect to the provider and the callback.
+	imapOAuthCookie     = "maildruid_imap_oauth"
+	imapOAuthCookiePath = "/api/v1/imap/oauth2"
+	imapOAuthTTL        = 10 * time.Minute
+
+	// imapOAuthLandingPath is the frontend route the browser returns to
+	// once the mailbox is linked.
+	imapOAuthLandingPath = "/settings"
+)
+
+// imapOAuthClaims is the signed content of the authorization cookie. The
+// subject is the user linking the mailbox; TeamID is set when the mailbox
+// is one the team shares.
+type imapOAuthClaims struct {
+	State     string `json:"state"`
+	Verifier  string `json:"verifier"`
+	Provider  string `json:"provider"`
+	MailboxID string `json:"mailbox"`
+	TeamID    string `json:"team,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// IMAPOAuthHandler links mailboxes to OAuth2 providers so MailDruid signs
+// in to IMAP with access tokens instead of a password.
+type IMAPOAuthHandler struct {
+	providers  *oauth.Providers
+	mailboxSvc *mailbox.Service
+	teamSvc    *team.Service
+	cfg        config.Config
+	logger     *slog.Logger
+}
+
+// NewIMAPOAuthHandler creates a new IMAP OAuth2 handler.
+func NewIMAPOAuthHandler(providers *oauth.Providers, mailboxSvc *mailbox.Service, teamSvc *team.Service, cfg config.Config, logger *slog.Logger) *IMAPOAuthHandler {
+	return &IMAPOAuthHandler{providers: providers, mailboxSvc: mailboxSvc, teamSvc: teamSvc, cfg: cfg, logger: logger}
+}
+
+// Providers lists the OAuth2 providers a mailbox can be linked to.
+// GET /api/v1/imap/oauth2/providers
+func (h *IMAPOAuthHandler) Providers(c echo.Context) error {
+	names := h.providers.Names()
+	if names == nil {
+		names = []string{}
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"providers": names})
+}
+
+// Authorize starts linking one of the user's mailboxes to a provider. It
+// returns the provider URL the browser should open; the provider sends it
+// back to Callback.
+// POST /api/v1/mailboxes/:id/oauth2/:provider
+func (h *IMAPOAuthHandler) Authorize(c echo.Context) error {
+	return h.authorize(c, mailbox.UserOwner(middleware.GetUserID(c)), c.Param("id"))
+}
+
+// AuthorizeTeam starts linking one of a team's mailboxes to a provider,
+// like Authorize. Editors and owners only.
+// POST /api/v1/teams/:id/mailboxes/:mailboxId/oauth2/:provider
+func (h *IMAPOAuthHandler) AuthorizeTeam(c echo.Context) error {
+	teamID := c.Param("id")
+	if err := h.teamSvc.Authorize(c.Request().Context(), middleware.GetUserID(c), teamID, team.RoleEditor); err != nil {
+		return h.teamError(c, err)
+	}
+	return h.authorize(c, mailbox.TeamOwner(teamID), c.Param("mailboxId"))
+}
+
+func (h *IMAPOAuthHandler) authorize(c echo.Context, owner mailbox.Owner, id string) error {
+	provider := c.Param("provider")
+	if !h.providers.Has(provider) {
+		return c.JSON(http.StatusNotFound, errResp("unknown provider"))
+	}
+	mb, err := h.mailboxSvc.Get(c.Request().Context(), owner, id)
+	if errors.Is(err, mailbox.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, errResp("mailbox not found"))
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("could not start authorization"))
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("could not start authorization"))
+	}
+	verifier := oauth.NewVerifier()
+
+	cookie := jwt.NewWithClaims(jwt.SigningMethodHS256, imapOAuthClaims{
+		State:     state,
+		Verifier:  verifier,
+		Provider:  provider,
+		MailboxID: mb.ID,
+		TeamID:    owner.TeamID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   middleware.GetUserID(c),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(imapOAuthTTL)),
+		},
+	})
+	signed, err := cookie.SignedString(h.cookieKey())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("could not start authorization"))
+	}
+	authURL, err := h.providers.AuthCodeURL(provider, state, verifier)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("could not start authorization"))
+	}
+	h.setCookie(c, signed, int(imapOAuthTTL.Seconds()))
+
+	return c.JSON(http.StatusOK, map[string]string{"url": authURL})
+}
+
+// Callback completes linking a mailbox: it checks the state, redeems the
+// code and stores the refresh token with the mailbox the flow was started
+// for. For a team mailbox the user must still be allowed to edit it.
+// GET /api/v1/imap/oauth2/callback
+func (h *IMAPOAuthHandler) Callback(c echo.Context) error {
+	var req OIDCCallbackRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	pending, err := h.readCookie(c)
+	h.setCookie(c, "", -1)
+	if err != nil || req.State == "" || subtle.ConstantTimeCompare([]byte(req.State), []byte(pending.State)) != 1 {
+		return c.JSON(http.StatusBadRequest, errResp("invalid or expired authorization attempt"))
+	}
+	if req.Error != "" {
+		h.logger.Info("mailbox authorization refused by provider", "provider", pending.Provider, "error", req.Error, "description", req.ErrorDescription)
+		return c.JSON(http.StatusForbidden, errResp("authorization was refused by the provider"))
+	}
+	if req.Code == "" {
+		return c.JSON(http.StatusBadRequest, errResp("missing authorization code"))
+	}
+
+	ctx := c.Request().Context()
+	tok, err := h.providers.Exchange(ctx, pending.Provider, req.Code, pending.Verifier)
+	if errors.Is(err, oauth.ErrNoRefreshToken) {
+		return c.JSON(http.StatusBadGateway, errResp("provider did not grant offline access"))
+	}
+	if err != nil {
+		h.logger.Warn("mailbox authorization failed", "provider", pending.Provider, "error", err)
+		return c.JSON(http.StatusBadGateway, errResp("could not redeem authorization code"))
+	}
+
+	owner := mailbox.UserOwner(pending.Subject)
+	if pending.TeamID != "" {
+		if err := h.teamSvc.Authorize(ctx, pending.Subject, pending.TeamID, team.RoleEditor); err != nil {
+			return h.teamError(c, err)
+		}
+		owner = mailbox.TeamOwner(pending.TeamID)
+	}
+	err = h.mailboxSvc.LinkOAuth(ctx, owner, pending.MailboxID, pending.Provider, tok.RefreshToken)
+	if errors.Is(err, mailbox.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, errResp("mailbox not found"))
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("failed to link mailbox"))
+	}
+
+	return c.Redirect(http.StatusFound, imapOAuthLandingPath+"#imapOAuth=linked")
+}
+
+// Unlink forgets a mailbox's OAuth2 tokens, so it signs in with the IMAP
+// password again.
+// DELETE /api/v1/mailboxes/:id/oauth2
+func (h *IMAPOAuthHandler) Unlink(c echo.Context) error {
+	return h.unlink(c, mailbox.UserOwner(middleware.GetUserID(c)), c.Param("id"))
+}
+
+// UnlinkTeam forgets the OAuth2 tokens of one of a team's mailboxes.
+// Editors and owners only.
+// DELETE /api/v1/teams/:id/mailboxes/:mailboxId/oauth2
+func (h *IMAPOAuthHandler) UnlinkTeam(c echo.Context) error {
+	teamID := c.Param("id")
+	if err := h.teamSvc.Authorize(c.Request().Context(), middleware.GetUserID(c), teamID, team.RoleEditor); err != nil {
+		return h.teamError(c, err)
+	}
+	return h.unlink(c, mailbox.TeamOwner(teamID), c.Param("mailboxId"))
+}
+
+func (h *IMAPOAuthHandler) unlink(c echo.Context, owner mailbox.Owner, id string) error {
+	err := h.mailboxSvc.UnlinkOAuth(c.Request().Context(), owner, id)
+	if errors.Is(err, mailbox.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, errResp("mailbox not found"))
+	}
+	if errors.Is(err, mailbox.ErrNotLinked) {
+		return c.JSON(http.StatusNotFound, errResp("mailbox is not linked to a provider"))
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("failed to unlink mailbox"))
+	}
+	return c.JSON(http.StatusOK, msgOK("mailbox unlinked"))
+}
+
+// teamError reports a failed team role check.
+func (h *IMAPOAuthHandler) teamError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, team.ErrNotFound):
+		return c.JSON(http.StatusNotFound, errResp("team not found"))
+	case errors.Is(err, team.ErrForbidden):
+		return c.JSON(http.StatusForbidden, errResp("your team role does not allow this"))
+	default:
+		h.logger.Error("team role check failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, errResp("could not check team role"))
+	}
+}
+
+func (h *IMAPOAuthHandler) readCookie(c echo.Context) (*imapOAuthClaims, error) {
+	cookie, err := c.Cookie(imapOAuthCookie)
+	if err != nil {
+		return nil, err
+	}
+	claims := &imapOAuthClaims{}
+	_, err = jwt.ParseWithClaims(cookie.Value, claims, func(t *jwt.Token) (interface{}, error) {
+		return h.cookieKey(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (h *IMAPOAuthHandler) setCookie(c echo.Context, value string, maxAge int) {
+	c.SetCookie(&http.Cookie{
+		Name:     imapOAuthCookie,
+		Value:    value,
+		Path:     imapOAuthCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.cfg.IMAP.OAuth2.RedirectURL, "https://"),
+		// Lax so the cookie comes back on the top-level redirect from the
+		// provider.
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (h *IMAPOAuthHandler) cookieKey() []byte {
+	return deriveKey(h.cfg.Auth.SigningKey, "maildruid imap oauth")
+}
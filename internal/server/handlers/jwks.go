@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/akhil-datla/maildruid/internal/infrastructure/signing"
+	"github.com/labstack/echo/v4"
+)
+
+// JWKSHandler publishes the public keys access tokens are signed with.
+type JWKSHandler struct {
+	keys *signing.KeySet
+}
+
+// NewJWKSHandler creates a new JWKS handler.
+func NewJWKSHandler(keys *signing.KeySet) *JWKSHandler {
+	return &JWKSHandler{keys: keys}
+}
+
+// Keys returns the verification keys as a JSON Web Key Set. Upcoming keys
+// are listed before they start signing, so verifiers that cache the set
+// for a few minutes keep up with a rollover.
+// GET /.well-known/jwks.json
+func (h *JWKSHandler) Keys(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, h.keys.JWKS())
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/akhil-datla/maildruid/internal/domain/mailbox"
+	"github.com/akhil-datla/maildruid/internal/domain/summary"
+	"github.com/akhil-datla/maildruid/internal/server/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// MailboxHandler handles the mailbox connections of the authenticated user.
+type MailboxHandler struct {
+	mailboxSvc *mailbox.Service
+	summarySvc *summary.Service
+	logger     *slog.Logger
+}
+
+// NewMailboxHandler creates a new mailbox handler.
+func NewMailboxHandler(mailboxSvc *mailbox.Service, summarySvc *summary.Service, logger *slog.Logger) *MailboxHandler {
+	return &MailboxHandler{mailboxSvc: mailboxSvc, summarySvc: summarySvc, logger: logger}
+}
+
+// List returns the user's mailbox connections, oldest first.
+// GET /api/v1/mailboxes
+func (h *MailboxHandler) List(c echo.Context) error {
+	mailboxes, err := h.mailboxSvc.List(c.Request().Context(), mailbox.UserOwner(middleware.GetUserID(c)))
+	if err != nil {
+		return h.mailboxError(c, err)
+	}
+	if mailboxes == nil {
+		mailboxes = []*mailbox.Mailbox{}
+	}
+	return c.JSON(http.StatusOK, mailboxes)
+}
+
+// Get returns one of the user's mailbox connections.
+// GET /api/v1/mailboxes/:id
+func (h *MailboxHandler) Get(c echo.Context) error {
+	mb, err := h.mailboxSvc.Get(c.Request().Context(), mailbox.UserOwner(middleware.GetUserID(c)), c.Param("id"))
+	if err != nil {
+		return h.mailboxError(c, err)
+	}
+	return c.JSON(http.StatusOK, mb)
+}
+
+// Create adds a mailbox connection. Its mail is included in the user's
+// digest unless it is created disabled.
+// POST /api/v1/mailboxes
+func (h *MailboxHandler) Create(c echo.Context) error {
+	var req CreateMailboxRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	mb, err := h.mailboxSvc.Create(c.Request().Context(), mailbox.UserOwner(middleware.GetUserID(c)), mailbox.Input{
+		Name:     &req.Name,
+		Username: &req.Username,
+		Password: &req.Password,
+		Domain:   &req.Domain,
+		Port:     &req.Port,
+		Folders:  folderList(req.Folder, req.Folders),
+		Enabled:  req.Enabled,
+		Push:     req.Push,
+	})
+	if err != nil {
+		return h.mailboxError(c, err)
+	}
+	return c.JSON(http.StatusCreated, mb)
+}
+
+// Update changes a mailbox connection. Omitted fields are left unchanged.
+// PATCH /api/v1/mailboxes/:id
+func (h *MailboxHandler) Update(c echo.Context) error {
+	var req UpdateMailboxRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	mb, err := h.mailboxSvc.Update(c.Request().Context(), mailbox.UserOwner(middleware.GetUserID(c)), c.Param("id"), mailbox.Input{
+		Name:     req.Name,
+		Username: req.Username,
+		Password: req.Password,
+		Domain:   req.Domain,
+		Port:     req.Port,
+		Folders:  folderList(req.Folder, req.Folders),
+		Enabled:  req.Enabled,
+		Push:     req.Push,
+	})
+	if err != nil {
+		return h.mailboxError(c, err)
+	}
+	return c.JSON(http.StatusOK, mb)
+}
+
+// Delete removes a mailbox connection and its sync state.
+// DELETE /api/v1/mailboxes/:id
+func (h *MailboxHandler) Delete(c echo.Context) error {
+	if err := h.mailboxSvc.Delete(c.Request().Context(), mailbox.UserOwner(middleware.GetUserID(c)), c.Param("id")); err != nil {
+		return h.mailboxError(c, err)
+	}
+	return c.JSON(http.StatusOK, msgOK("mailbox deleted"))
+}
+
+// Test signs in to the mailbox and opens each of its folders, reporting
+// the server's error if any step fails. Wildcard patterns must match at
+// least one folder.
+// POST /api/v1/mailboxes/:id/test
+func (h *MailboxHandler) Test(c echo.Context) error {
+	mb, err := h.mailboxSvc.Get(c.Request().Context(), mailbox.UserOwner(middleware.GetUserID(c)), c.Param("id"))
+	if err != nil {
+		return h.mailboxError(c, err)
+	}
+	return testMailbox(c, h.summarySvc, mb)
+}
+
+// Folders lists the IMAP folders of a mailbox.
+// GET /api/v1/mailboxes/:id/folders
+func (h *MailboxHandler) Folders(c echo.Context) error {
+	mb, err := h.mailboxSvc.Get(c.Request().Context(), mailbox.UserOwner(middleware.GetUserID(c)), c.Param("id"))
+	if err != nil {
+		return h.mailboxError(c, err)
+	}
+	return mailboxFolders(c, h.summarySvc, h.logger, mb)
+}
+
+// testMailbox signs in to mb and opens each of its folders. It serves the
+// user and team mailbox test endpoints.
+func testMailbox(c echo.Context, summarySvc *summary.Service, mb *mailbox.Mailbox) error {
+	im, err := summarySvc.Connect(c.Request().Context(), mb)
+	if err != nil {
+		return c.JSON(http.StatusBadGateway, errResp(err.Error()))
+	}
+	defer im.Close()
+	folders := []string{"INBOX"}
+	if len(mb.Folders) > 0 {
+		if folders, err = im.ResolveFolders(mb.Folders); err != nil {
+			return c.JSON(http.StatusBadGateway, errResp(err.Error()))
+		}
+		if len(folders) == 0 {
+			return c.JSON(http.StatusBadGateway, errResp("no folders match the mailbox's folder patterns"))
+		}
+	}
+	for _, folder := range folders {
+		if _, err := im.SelectFolder(folder); err != nil {
+			return c.JSON(http.StatusBadGateway, errResp(err.Error()))
+		}
+	}
+	return c.JSON(http.StatusOK, msgOK("connected to mailbox"))
+}
+
+// mailboxFolders lists the IMAP folders of mb. It serves the user and
+// team mailbox folder endpoints.
+func mailboxFolders(c echo.Context, summarySvc *summary.Service, logger *slog.Logger, mb *mailbox.Mailbox) error {
+	im, err := summarySvc.Connect(c.Request().Context(), mb)
+	if err != nil {
+		logger.Warn("failed to connect to email server", "mailbox_id", mb.ID, "error", err)
+		return c.JSON(http.StatusBadGateway, errResp("failed to connect to email server"))
+	}
+	defer im.Close()
+
+	folders, err := im.GetFolders()
+	if err != nil {
+		return c.JSON(http.StatusBadGateway, errResp("failed to list folders"))
+	}
+	return c.JSON(http.StatusOK, folders)
+}
+
+// mailboxError maps mailbox service errors to responses.
+func (h *MailboxHandler) mailboxError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, mailbox.ErrNotFound):
+		return c.JSON(http.StatusNotFound, errResp("mailbox not found"))
+	case errors.Is(err, mailbox.ErrInvalidFolder), errors.Is(err, mailbox.ErrPasswordNeeded):
+		return c.JSON(http.StatusBadRequest, errResp(err.Error()))
+	case errors.Is(err, mailbox.ErrNotLinked):
+		return c.JSON(http.StatusNotFound, errResp("mailbox is not linked to a provider"))
+	default:
+		h.logger.Error("mailbox request failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, errResp("mailbox request failed"))
+	}
+}
+
+// folderList picks the folders of a mailbox request. The list form wins;
+// a single folder is shorthand for a list of one, and an empty one clears
+// the list. Nil leaves the folders unchanged.
+func folderList(folder *string, folders []string) *[]string {
+	switch {
+	case folders != nil:
+		return &folders
+	case folder != nil && *folder == "":
+		return &[]string{}
+	case folder != nil:
+		return &[]string{*folder}
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/config"
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/akhil-datla/maildruid/internal/domain/mfa"
+	"github.com/akhil-datla/maildruid/internal/domain/session"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/oidc"
+	"github.com/akhil-datla/maildruid/internal/server/middleware"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// oidcCookie carries the state, nonce and PKCE verifier of a login in
+	// progress between the redirect to the provider and the callback.
+	oidcCookie     = "maildruid_oidc"
+	oidcCookiePath = "/api/v1/auth/oidc"
+	oidcLoginTTL   = 10 * time.Minute
+
+	// oidcLandingPath is the frontend route that picks up the tokens from
+	// the URL fragment after a successful login.
+	oidcLandingPath = "/sso"
+)
+
+// oidcLoginClaims is the signed content of the login cookie.
+type oidcLoginClaims struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	jwt.RegisteredClaims
+}
+
+// OIDCHandler handles single sign-on through an OpenID Connect provider.
+type OIDCHandler struct {
+	provider   *oidc.Provider
+	userSvc    *user.Service
+	sessionSvc *session.Service
+	mfaSvc     *mfa.Service
+	auditSvc   *audit.Service
+	tokenKeys  middleware.TokenKeys
+	authCfg    config.AuthConfig
+	logger     *slog.Logger
+}
+
+// NewOIDCHandler creates a new OIDC handler.
+func NewOIDCHandler(
+	provider *oidc.Provider,
+	userSvc *user.Service,
+	sessionSvc *session.Service,
+	mfaSvc *mfa.Service,
+	auditSvc *audit.Service,
+	tokenKeys middleware.TokenKeys,
+	authCfg config.AuthConfig,
+	logger *slog.Logger,
+) *OIDCHandler {
+	return &OIDCHandler{
+		provider: provider, userSvc: userSvc, sessionSvc: sessionSvc, mfaSvc: mfaSvc, auditSvc: auditSvc,
+		tokenKeys: tokenKeys, authCfg: authCfg, logger: logger,
+	}
+}
+
+// Login redirects the browser to the identity provider.
+// GET /api/v1/auth/oidc/login
+func (h *OIDCHandler) Login(c echo.Context) error {
+	state, err := randomToken()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("could not start sign-in"))
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("could not start sign-in"))
+	}
+	verifier := oidc.NewVerifier()
+
+	cookie := jwt.NewWithClaims(jwt.SigningMethodHS256, oidcLoginClaims{
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(oidcLoginTTL)),
+		},
+	})
+	signed, err := cookie.SignedString(h.cookieKey())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("could not start sign-in"))
+	}
+	h.setCookie(c, signed, int(oidcLoginTTL.Seconds()))
+
+	return c.Redirect(http.StatusFound, h.provider.AuthCodeURL(state, nonce, verifier))
+}
+
+// Callback completes a sign-in: it checks the state, redeems the code,
+// finds or provisions the user and hands tokens to the frontend in the
+// URL fragment. Users with two-factor authentication, or all users when it
+// is required, get a challenge token instead, completed at /auth/2fa like
+// after a password login.
+// GET /api/v1/auth/oidc/callback
+func (h *OIDCHandler) Callback(c echo.Context) error {
+	var req OIDCCallbackRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	login, err := h.readCookie(c)
+	h.setCookie(c, "", -1)
+	if err != nil || req.State == "" || subtle.ConstantTimeCompare([]byte(req.State), []byte(login.State)) != 1 {
+		return c.JSON(http.StatusBadRequest, errResp("invalid or expired sign-in attempt"))
+	}
+	if req.Error != "" {
+		h.logger.Info("OIDC sign-in refused by provider", "error", req.Error, "description", req.ErrorDescription)
+		return c.JSON(http.StatusUnauthorized, errResp("sign-in was refused by the identity provider"))
+	}
+	if req.Code == "" {
+		return c.JSON(http.StatusBadRequest, errResp("missing authorization code"))
+	}
+
+	ctx := c.Request().Context()
+	ident, err := h.provider.Exchange(ctx, req.Code, login.Verifier, login.Nonce)
+	if err != nil {
+		h.logger.Warn("OIDC sign-in failed", "error", err)
+		return c.JSON(http.StatusUnauthorized, errResp("single sign-on failed"))
+	}
+
+	id, err := h.userSvc.LoginOIDC(ctx, user.OIDCIdentity{
+		Subject:       ident.Subject,
+		Email:         ident.Email,
+		EmailVerified: ident.EmailVerified,
+		Name:          ident.Name,
+	}, h.authCfg.OIDC.AutoProvision)
+	switch {
+	case errors.Is(err, user.ErrNoAccount):
+		return c.JSON(http.StatusForbidden, errResp("no account for this identity"))
+	case errors.Is(err, user.ErrEmailUnverified):
+		return c.JSON(http.StatusForbidden, errResp("identity provider did not return a verified email"))
+	case errors.Is(err, user.ErrAlreadyExists):
+		return c.JSON(http.StatusConflict, errResp("email is linked to a different identity"))
+	case errors.Is(err, user.ErrLinkUnproven):
+		return c.JSON(http.StatusConflict, errResp("an account with this email exists; verify it as the receiving email before signing in with SSO"))
+	case errors.Is(err, user.ErrDisabled):
+		return c.JSON(http.StatusForbidden, errResp("account is disabled"))
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, errResp("authentication failed"))
+	}
+
+	challenge, err := newChallenge(ctx, h.userSvc, h.mfaSvc, h.authCfg, id, "sso")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("authentication failed"))
+	}
+	if challenge != nil {
+		fragment := url.Values{
+			"challengeToken":     {challenge.ChallengeToken},
+			"expiresIn":          {strconv.Itoa(challenge.ExpiresIn)},
+			"enrollmentRequired": {strconv.FormatBool(challenge.EnrollmentRequired)},
+		}
+		return c.Redirect(http.StatusFound, oidcLandingPath+"#"+fragment.Encode())
+	}
+
+	sess, refresh, err := h.sessionSvc.Issue(ctx, id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("could not start session"))
+	}
+	h.auditSvc.TryRecord(ctx, audit.Event{UserID: id, ActorID: id, Action: audit.ActionLoginSucceeded, Detail: "sso"})
+	tokens, err := signTokens(h.tokenKeys, h.authCfg, sess, refresh)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("could not generate token"))
+	}
+
+	fragment := url.Values{
+		"token":        {tokens.Token},
+		"refreshToken": {tokens.RefreshToken},
+		"expiresIn":    {strconv.Itoa(tokens.ExpiresIn)},
+	}
+	return c.Redirect(http.StatusFound, oidcLandingPath+"#"+fragment.Encode())
+}
+
+func (h *OIDCHandler) readCookie(c echo.Context) (*oidcLoginClaims, error) {
+	cookie, err := c.Cookie(oidcCookie)
+	if err != nil {
+		return nil, err
+	}
+	claims := &oidcLoginClaims{}
+	_, err = jwt.ParseWithClaims(cookie.Value, claims, func(t *jwt.Token) (interface{}, error) {
+		return h.cookieKey(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (h *OIDCHandler) setCookie(c echo.Context, value string, maxAge int) {
+	c.SetCookie(&http.Cookie{
+		Name:     oidcCookie,
+		Value:    value,
+		Path:     oidcCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.authCfg.OIDC.RedirectURL, "https://"),
+		// Lax so the cookie comes back on the top-level redirect from the
+		// provider.
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (h *OIDCHandler) cookieKey() []byte {
+	return deriveKey(h.authCfg.SigningKey, "maildruid oidc login")
+}
+
+// randomToken returns 32 random bytes, base64url encoded.
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/akhil-datla/maildruid/internal/domain/passwordreset"
+	"github.com/labstack/echo/v4"
+)
+
+// PasswordResetHandler handles the forgotten-password flow.
+type PasswordResetHandler struct {
+	resetSvc *passwordreset.Service
+	logger   *slog.Logger
+}
+
+// NewPasswordResetHandler creates a new password reset handler.
+func NewPasswordResetHandler(resetSvc *passwordreset.Service, logger *slog.Logger) *PasswordResetHandler {
+	return &PasswordResetHandler{resetSvc: resetSvc, logger: logger}
+}
+
+// ForgotPassword emails a reset link to the account with the given email.
+// The response is the same whether or not the account exists.
+// POST /api/v1/auth/forgot-password
+func (h *PasswordResetHandler) ForgotPassword(c echo.Context) error {
+	var req ForgotPasswordRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	h.resetSvc.Request(c.Request().Context(), req.Email)
+	return c.JSON(http.StatusAccepted, msgOK("if an account exists for that email, a reset link has been sent"))
+}
+
+// ResetPassword sets a new password with the token from a reset email and
+// signs out every existing session.
+// POST /api/v1/auth/reset-password
+func (h *PasswordResetHandler) ResetPassword(c echo.Context) error {
+	var req ResetPasswordRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	err := h.resetSvc.Reset(c.Request().Context(), req.Token, req.Password)
+	if errors.Is(err, passwordreset.ErrInvalidToken) {
+		return c.JSON(http.StatusBadRequest, errResp("invalid or expired reset link"))
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("failed to reset password"))
+	}
+	return c.JSON(http.StatusOK, msgOK("password reset, sign in with your new password"))
+}
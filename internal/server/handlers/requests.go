@@ -1,12 +1,15 @@
 package handlers
 
+import "time"
+
 // Request types for JSON body binding with validation.
 
 type CreateUserRequest struct {
 	Name           string `json:"name" validate:"required,min=2,max=100"`
 	Email          string `json:"email" validate:"required,email"`
 	ReceivingEmail string `json:"receivingEmail" validate:"required,email"`
-	Password       string `json:"password" validate:"required,min=6"`
+	Password       string `json:"password" validate:"required,min=8,max=72"`
+	IMAPPassword   string `json:"imapPassword" validate:"required"`
 	Domain         string `json:"domain" validate:"required"`
 	Port           int    `json:"port" validate:"required,min=1,max=65535"`
 }
@@ -16,15 +19,58 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `query:"token" validate:"required"`
+}
+
+type UnlockRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type OIDCCallbackRequest struct {
+	Code             string `query:"code"`
+	State            string `query:"state"`
+	Error            string `query:"error"`
+	ErrorDescription string `query:"error_description"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+}
+
+type TwoFactorEnrollRequest struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken,omitempty"`
+	AllSessions  bool   `json:"allSessions,omitempty"`
+}
+
 type UpdateUserRequest struct {
 	Name           *string `json:"name,omitempty" validate:"omitempty,min=2,max=100"`
 	Email          *string `json:"email,omitempty" validate:"omitempty,email"`
 	ReceivingEmail *string `json:"receivingEmail,omitempty" validate:"omitempty,email"`
 	OldPassword    *string `json:"oldPassword,omitempty"`
-	NewPassword    *string `json:"newPassword,omitempty" validate:"omitempty,min=6"`
-	Domain         *string `json:"domain,omitempty"`
-	Port           *int    `json:"port,omitempty" validate:"omitempty,min=1,max=65535"`
-	Folder         *string `json:"folder,omitempty"`
+	NewPassword    *string `json:"newPassword,omitempty" validate:"omitempty,min=8,max=72"`
 }
 
 type UpdateTagsRequest struct {
@@ -43,10 +89,6 @@ type UpdateSummaryCountRequest struct {
 	Count int `json:"count" validate:"required,min=1,max=100"`
 }
 
-type UpdateFolderRequest struct {
-	Folder string `json:"folder" validate:"required"`
-}
-
 type ScheduleTaskRequest struct {
 	Interval string `json:"interval" validate:"required"`
 }
@@ -55,3 +97,105 @@ type UpdateTaskRequest struct {
 	OldInterval string `json:"oldInterval" validate:"required"`
 	NewInterval string `json:"newInterval" validate:"required"`
 }
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type ListSummariesRequest struct {
+	Page     int `query:"page" validate:"omitempty,min=1"`
+	PageSize int `query:"pageSize" validate:"omitempty,min=1,max=100"`
+}
+
+type ListUsersRequest struct {
+	Query    string `query:"q" validate:"omitempty,max=100"`
+	Page     int    `query:"page" validate:"omitempty,min=1"`
+	PageSize int    `query:"pageSize" validate:"omitempty,min=1,max=100"`
+}
+
+type ListAuditRequest struct {
+	Action   string    `query:"action" validate:"omitempty,max=64"`
+	Since    time.Time `query:"since"`
+	Until    time.Time `query:"until"`
+	Page     int       `query:"page" validate:"omitempty,min=1"`
+	PageSize int       `query:"pageSize" validate:"omitempty,min=1,max=100"`
+}
+
+type AdminListAuditRequest struct {
+	UserID   string    `query:"userId" validate:"omitempty,max=64"`
+	ActorID  string    `query:"actorId" validate:"omitempty,max=64"`
+	Action   string    `query:"action" validate:"omitempty,max=64"`
+	Since    time.Time `query:"since"`
+	Until    time.Time `query:"until"`
+	Page     int       `query:"page" validate:"omitempty,min=1"`
+	PageSize int       `query:"pageSize" validate:"omitempty,min=1,max=100"`
+}
+
+type CreateTeamRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type UpdateTeamRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type AddTeamMemberRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=owner editor viewer"`
+}
+
+type UpdateTeamMemberRequest struct {
+	Role string `json:"role" validate:"required,oneof=owner editor viewer"`
+}
+
+type UpdateTeamDeliveryRequest struct {
+	Deliver *bool `json:"deliver" validate:"required"`
+}
+
+type CreateMailboxRequest struct {
+	Name     string   `json:"name" validate:"required,max=100"`
+	Username string   `json:"username" validate:"required"`
+	Password string   `json:"password,omitempty"` // empty for mailboxes linked to an OAuth2 provider
+	Domain   string   `json:"domain" validate:"required"`
+	Port     int      `json:"port" validate:"required,min=1,max=65535"`
+	Folder   *string  `json:"folder,omitempty"`
+	Folders  []string `json:"folders,omitempty" validate:"max=50,dive,max=255"`
+	Enabled  *bool    `json:"enabled,omitempty"`
+	Push     *bool    `json:"push,omitempty"`
+}
+
+type UpdateMailboxRequest struct {
+	Name     *string  `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	Username *string  `json:"username,omitempty" validate:"omitempty,min=1"`
+	Password *string  `json:"password,omitempty"`
+	Domain   *string  `json:"domain,omitempty" validate:"omitempty,min=1"`
+	Port     *int     `json:"port,omitempty" validate:"omitempty,min=1,max=65535"`
+	Folder   *string  `json:"folder,omitempty"`
+	Folders  []string `json:"folders,omitempty" validate:"max=50,dive,max=255"`
+	Enabled  *bool    `json:"enabled,omitempty"`
+	Push     *bool    `json:"push,omitempty"`
+}
+
+type CreateTeamDigestRequest struct {
+	Name             string     `json:"name" validate:"required,max=100"`
+	MailboxID        string     `json:"mailboxId" validate:"required"`
+	Folder           string     `json:"folder,omitempty"`
+	Tags             []string   `json:"tags" validate:"required,min=1,dive,required"`
+	BlackListSenders []string   `json:"blackListSenders,omitempty"`
+	StartTime        *time.Time `json:"startTime,omitempty"`
+	SummaryCount     *int       `json:"summaryCount,omitempty" validate:"omitempty,min=1,max=100"`
+	UpdateInterval   string     `json:"updateInterval,omitempty"`
+}
+
+type UpdateTeamDigestRequest struct {
+	Name             *string    `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	MailboxID        *string    `json:"mailboxId,omitempty" validate:"omitempty,min=1"`
+	Folder           *string    `json:"folder,omitempty"`
+	Tags             []string   `json:"tags,omitempty" validate:"omitempty,min=1,dive,required"`
+	BlackListSenders []string   `json:"blackListSenders,omitempty"`
+	StartTime        *time.Time `json:"startTime,omitempty"`
+	SummaryCount     *int       `json:"summaryCount,omitempty" validate:"omitempty,min=1,max=100"`
+	UpdateInterval   *string    `json:"updateInterval,omitempty"`
+}
@@ -1,7 +1,20 @@
 package handlers
 
+import (
+	"time"
+
+	"github.com/akhil-datla/maildruid/internal/domain/apikey"
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/akhil-datla/maildruid/internal/domain/summary"
+	"github.com/akhil-datla/maildruid/internal/domain/team"
+	"github.com/akhil-datla/maildruid/internal/domain/user"
+	"github.com/akhil-datla/maildruid/internal/scheduler"
+)
+
 // Response types for consistent API responses.
 
+const defaultPageSize = 20
+
 type MessageResponse struct {
 	Message string `json:"message"`
 }
@@ -11,12 +24,94 @@ type ErrorResponse struct {
 }
 
 type TokenResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int    `json:"expiresIn"` // access token lifetime in seconds
+}
+
+// ChallengeResponse is returned by a password login that still needs a
+// second factor.
+type ChallengeResponse struct {
+	ChallengeToken     string `json:"challengeToken"`
+	ExpiresIn          int    `json:"expiresIn"`
+	EnrollmentRequired bool   `json:"enrollmentRequired"`
+}
+
+// TwoFactorLoginResponse carries the tokens of a completed two-step login
+// and, if the login confirmed a new enrollment, the recovery codes.
+type TwoFactorLoginResponse struct {
+	*TokenResponse
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// CreateAPIKeyResponse includes the plaintext key, which is shown only once.
+type CreateAPIKeyResponse struct {
+	*apikey.APIKey
+	Key string `json:"key"`
 }
 
 type SummaryResponse struct {
-	Summary string `json:"summary"`
-	Image   string `json:"image,omitempty"`
+	ID        string `json:"id,omitempty"`
+	Summary   string `json:"summary"`
+	Image     string `json:"image,omitempty"`
+	Truncated int    `json:"truncated,omitempty"`
+	Skipped   int    `json:"skipped,omitempty"`
+}
+
+type DigestResponse struct {
+	ID         string                `json:"id"`
+	Summary    string                `json:"summary"`
+	Keywords   []string              `json:"keywords"`
+	Tags       []string              `json:"tags"`
+	EmailCount int                   `json:"emailCount"`
+	Truncated  int                   `json:"truncated"`
+	Skipped    int                   `json:"skipped"`
+	FirstUID   int                   `json:"firstUid"`
+	LastUID    int                   `json:"lastUid"`
+	Folders    []summary.FolderRange `json:"folders"`
+	Trigger    string                `json:"trigger"`
+	StartedAt  time.Time             `json:"startedAt"`
+	CreatedAt  time.Time             `json:"createdAt"`
+	Image      string                `json:"image,omitempty"`
+}
+
+type DigestListResponse struct {
+	Items    []DigestResponse `json:"items"`
+	Total    int64            `json:"total"`
+	Page     int              `json:"page"`
+	PageSize int              `json:"pageSize"`
+}
+
+type UserListResponse struct {
+	Items    []*user.User `json:"items"`
+	Total    int64        `json:"total"`
+	Page     int          `json:"page"`
+	PageSize int          `json:"pageSize"`
+}
+
+type AuditListResponse struct {
+	Items    []*audit.Event `json:"items"`
+	Total    int64          `json:"total"`
+	Page     int            `json:"page"`
+	PageSize int            `json:"pageSize"`
+}
+
+// ScheduleStatusResponse describes a user's schedule. LastRun is omitted
+// if the user has not had a run since the server started.
+type ScheduleStatusResponse struct {
+	Interval string               `json:"interval"`
+	LastRun  *scheduler.RunStatus `json:"lastRun,omitempty"`
+}
+
+// TeamDigestResponse is a team digest configuration with the status of
+// its current or most recent run since the server started.
+type TeamDigestResponse struct {
+	*team.Digest
+	LastRun *scheduler.RunStatus `json:"lastRun,omitempty"`
 }
 
 type HealthResponse struct {
@@ -31,3 +126,28 @@ func msgOK(msg string) *MessageResponse {
 func errResp(msg string) *ErrorResponse {
 	return &ErrorResponse{Error: msg}
 }
+
+func newDigestResponse(d *summary.Digest) DigestResponse {
+	return DigestResponse{
+		ID:         d.ID,
+		Summary:    d.Summary,
+		Keywords:   nonNil(d.Keywords),
+		Tags:       nonNil(d.Tags),
+		EmailCount: d.EmailCount,
+		Truncated:  d.Truncated,
+		Skipped:    d.Skipped,
+		FirstUID:   d.FirstUID,
+		LastUID:    d.LastUID,
+		Folders:    nonNil(d.Folders),
+		Trigger:    string(d.Trigger),
+		StartedAt:  d.StartedAt,
+		CreatedAt:  d.CreatedAt,
+	}
+}
+
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 
+	"github.com/akhil-datla/maildruid/internal/domain/user"
 	"github.com/akhil-datla/maildruid/internal/scheduler"
 	"github.com/akhil-datla/maildruid/internal/server/middleware"
 	"github.com/labstack/echo/v4"
@@ -11,11 +12,12 @@ import (
 // ScheduleHandler handles task scheduling endpoints.
 type ScheduleHandler struct {
 	scheduler *scheduler.Scheduler
+	userSvc   *user.Service
 }
 
 // NewScheduleHandler creates a new schedule handler.
-func NewScheduleHandler(sched *scheduler.Scheduler) *ScheduleHandler {
-	return &ScheduleHandler{scheduler: sched}
+func NewScheduleHandler(sched *scheduler.Scheduler, userSvc *user.Service) *ScheduleHandler {
+	return &ScheduleHandler{scheduler: sched, userSvc: userSvc}
 }
 
 // Create schedules a new periodic task.
@@ -27,7 +29,7 @@ func (h *ScheduleHandler) Create(c echo.Context) error {
 	}
 
 	id := middleware.GetUserID(c)
-	if err := h.scheduler.AddTask(id, req.Interval); err != nil {
+	if err := h.scheduler.AddTask(c.Request().Context(), id, req.Interval); err != nil {
 		return c.JSON(http.StatusBadRequest, errResp(err.Error()))
 	}
 	return c.JSON(http.StatusCreated, msgOK("task scheduled"))
@@ -42,7 +44,7 @@ func (h *ScheduleHandler) Update(c echo.Context) error {
 	}
 
 	id := middleware.GetUserID(c)
-	if err := h.scheduler.UpdateTask(id, req.OldInterval, req.NewInterval); err != nil {
+	if err := h.scheduler.UpdateTask(c.Request().Context(), id, req.OldInterval, req.NewInterval); err != nil {
 		return c.JSON(http.StatusBadRequest, errResp(err.Error()))
 	}
 	return c.JSON(http.StatusOK, msgOK("task updated"))
@@ -57,15 +59,23 @@ func (h *ScheduleHandler) Delete(c echo.Context) error {
 	}
 
 	id := middleware.GetUserID(c)
-	if err := h.scheduler.RemoveTask(id, req.Interval); err != nil {
+	if err := h.scheduler.RemoveTask(c.Request().Context(), id, req.Interval); err != nil {
 		return c.JSON(http.StatusBadRequest, errResp(err.Error()))
 	}
 	return c.JSON(http.StatusOK, msgOK("task deleted"))
 }
 
-// List returns all scheduled tasks.
+// List returns every scheduled task to admins and only the caller's own
+// task to everyone else.
 // GET /api/v1/schedules
 func (h *ScheduleHandler) List(c echo.Context) error {
-	tasks := h.scheduler.ListTasks()
-	return c.JSON(http.StatusOK, tasks)
+	id := middleware.GetUserID(c)
+	u, err := h.userSvc.GetByID(c.Request().Context(), id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("failed to get user"))
+	}
+	if u.IsAdmin() && !middleware.IsAPIKey(c) {
+		return c.JSON(http.StatusOK, h.scheduler.ListTasks())
+	}
+	return c.JSON(http.StatusOK, h.scheduler.ListTasksFor(id))
 }
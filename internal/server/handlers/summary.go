@@ -6,8 +6,8 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
-	"strings"
 
+	"github.com/akhil-datla/maildruid/internal/domain/mailbox"
 	"github.com/akhil-datla/maildruid/internal/domain/summary"
 	"github.com/akhil-datla/maildruid/internal/domain/user"
 	"github.com/akhil-datla/maildruid/internal/server/middleware"
@@ -38,19 +38,22 @@ func (h *SummaryHandler) Generate(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, errResp("failed to get user"))
 	}
 
-	result, err := h.summarySvc.Generate(c.Request().Context(), u)
+	result, err := h.summarySvc.Generate(c.Request().Context(), u, summary.TriggerManual)
 	if errors.Is(err, user.ErrNoTags) {
 		return c.JSON(http.StatusBadRequest, errResp("configure tags before generating a summary"))
 	}
+	if errors.Is(err, mailbox.ErrNoMailboxes) {
+		return c.JSON(http.StatusBadRequest, errResp("add or enable a mailbox before generating a summary"))
+	}
+	if errors.Is(err, summary.ErrNoMatches) {
+		return c.JSON(http.StatusNotFound, errResp("no emails to summarize"))
+	}
 	if err != nil {
-		if strings.Contains(err.Error(), "no emails found") {
-			return c.JSON(http.StatusNotFound, errResp("no emails to summarize"))
-		}
 		h.logger.Error("summary generation failed", "error", err, "user_id", id)
 		return c.JSON(http.StatusInternalServerError, errResp("summary generation failed"))
 	}
 
-	resp := SummaryResponse{Summary: result.Summary}
+	resp := SummaryResponse{ID: result.DigestID, Summary: result.Summary, Truncated: result.Truncated, Skipped: result.Skipped}
 
 	if result.WordCloudPath != "" {
 		data, err := os.ReadFile(result.WordCloudPath)
@@ -62,3 +65,57 @@ func (h *SummaryHandler) Generate(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, resp)
 }
+
+// List returns a page of the user's past digests, newest first.
+// GET /api/v1/summaries
+func (h *SummaryHandler) List(c echo.Context) error {
+	var req ListSummariesRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = defaultPageSize
+	}
+
+	id := middleware.GetUserID(c)
+	digests, total, err := h.summarySvc.List(c.Request().Context(), id, req.PageSize, (req.Page-1)*req.PageSize)
+	if err != nil {
+		h.logger.Error("listing digests failed", "error", err, "user_id", id)
+		return c.JSON(http.StatusInternalServerError, errResp("failed to list summaries"))
+	}
+
+	items := make([]DigestResponse, 0, len(digests))
+	for _, d := range digests {
+		items = append(items, newDigestResponse(d))
+	}
+
+	return c.JSON(http.StatusOK, DigestListResponse{
+		Items:    items,
+		Total:    total,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+	})
+}
+
+// Get returns a single past digest including its word cloud image.
+// GET /api/v1/summaries/:id
+func (h *SummaryHandler) Get(c echo.Context) error {
+	id := middleware.GetUserID(c)
+	d, err := h.summarySvc.Get(c.Request().Context(), id, c.Param("id"))
+	if errors.Is(err, summary.ErrDigestNotFound) {
+		return c.JSON(http.StatusNotFound, errResp("summary not found"))
+	}
+	if err != nil {
+		h.logger.Error("getting digest failed", "error", err, "user_id", id)
+		return c.JSON(http.StatusInternalServerError, errResp("failed to get summary"))
+	}
+
+	resp := newDigestResponse(d)
+	if len(d.WordCloud) > 0 {
+		resp.Image = base64.StdEncoding.EncodeToString(d.WordCloud)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,471 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/akhil-datla/maildruid/internal/domain/mailbox"
+	"github.com/akhil-datla/maildruid/internal/domain/summary"
+	"github.com/akhil-datla/maildruid/internal/domain/team"
+	"github.com/akhil-datla/maildruid/internal/scheduler"
+	"github.com/akhil-datla/maildruid/internal/server/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// TeamHandler handles teams and the mailboxes and digests they share.
+// Every endpoint acts for the authenticated user, whose role in the team
+// is checked by the team service.
+type TeamHandler struct {
+	teamSvc    *team.Service
+	summarySvc *summary.Service
+	scheduler  *scheduler.Scheduler
+	logger     *slog.Logger
+}
+
+// NewTeamHandler creates a new team handler.
+func NewTeamHandler(teamSvc *team.Service, summarySvc *summary.Service, sched *scheduler.Scheduler, logger *slog.Logger) *TeamHandler {
+	return &TeamHandler{teamSvc: teamSvc, summarySvc: summarySvc, scheduler: sched, logger: logger}
+}
+
+// Create starts a team owned by the authenticated user.
+// POST /api/v1/teams
+func (h *TeamHandler) Create(c echo.Context) error {
+	var req CreateTeamRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	m, err := h.teamSvc.Create(c.Request().Context(), middleware.GetUserID(c), req.Name)
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	return c.JSON(http.StatusCreated, m)
+}
+
+// List returns the teams the authenticated user belongs to.
+// GET /api/v1/teams
+func (h *TeamHandler) List(c echo.Context) error {
+	teams, err := h.teamSvc.List(c.Request().Context(), middleware.GetUserID(c))
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	if teams == nil {
+		teams = []*team.Membership{}
+	}
+	return c.JSON(http.StatusOK, teams)
+}
+
+// Get returns a team with the caller's role in it.
+// GET /api/v1/teams/:id
+func (h *TeamHandler) Get(c echo.Context) error {
+	m, err := h.teamSvc.Get(c.Request().Context(), middleware.GetUserID(c), c.Param("id"))
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	return c.JSON(http.StatusOK, m)
+}
+
+// Update renames a team.
+// PATCH /api/v1/teams/:id
+func (h *TeamHandler) Update(c echo.Context) error {
+	var req UpdateTeamRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if err := h.teamSvc.Rename(c.Request().Context(), middleware.GetUserID(c), c.Param("id"), req.Name); err != nil {
+		return h.teamError(c, err)
+	}
+	return c.JSON(http.StatusOK, msgOK("team updated"))
+}
+
+// Delete removes a team with its mailboxes, digests and history.
+// DELETE /api/v1/teams/:id
+func (h *TeamHandler) Delete(c echo.Context) error {
+	digests, err := h.teamSvc.Delete(c.Request().Context(), middleware.GetUserID(c), c.Param("id"))
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	for _, d := range digests {
+		h.scheduler.UnscheduleTeamDigest(d.ID)
+	}
+	return c.JSON(http.StatusOK, msgOK("team deleted"))
+}
+
+// Members lists a team's members.
+// GET /api/v1/teams/:id/members
+func (h *TeamHandler) Members(c echo.Context) error {
+	members, err := h.teamSvc.Members(c.Request().Context(), middleware.GetUserID(c), c.Param("id"))
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	return c.JSON(http.StatusOK, members)
+}
+
+// AddMember adds an existing account to a team by its login email. The
+// response is the same whether or not the account exists.
+// POST /api/v1/teams/:id/members
+func (h *TeamHandler) AddMember(c echo.Context) error {
+	var req AddTeamMemberRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	err := h.teamSvc.AddMember(c.Request().Context(), middleware.GetUserID(c), c.Param("id"), req.Email, req.Role)
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	return c.JSON(http.StatusAccepted, msgOK("if an account exists for that email, it has been added to the team"))
+}
+
+// UpdateMember changes a member's role.
+// PATCH /api/v1/teams/:id/members/:userId
+func (h *TeamHandler) UpdateMember(c echo.Context) error {
+	var req UpdateTeamMemberRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	m, err := h.teamSvc.SetRole(c.Request().Context(), middleware.GetUserID(c), c.Param("id"), c.Param("userId"), req.Role)
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	return c.JSON(http.StatusOK, m)
+}
+
+// RemoveMember removes a member from a team, or lets the caller leave.
+// DELETE /api/v1/teams/:id/members/:userId
+func (h *TeamHandler) RemoveMember(c echo.Context) error {
+	err := h.teamSvc.RemoveMember(c.Request().Context(), middleware.GetUserID(c), c.Param("id"), c.Param("userId"))
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	return c.JSON(http.StatusOK, msgOK("member removed"))
+}
+
+// UpdateDelivery turns emailing the team's digests to the caller on or
+// off.
+// PUT /api/v1/teams/:id/members/me/delivery
+func (h *TeamHandler) UpdateDelivery(c echo.Context) error {
+	var req UpdateTeamDeliveryRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	m, err := h.teamSvc.SetDelivery(c.Request().Context(), middleware.GetUserID(c), c.Param("id"), *req.Deliver)
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	return c.JSON(http.StatusOK, m)
+}
+
+// Mailboxes lists a team's mailbox connections.
+// GET /api/v1/teams/:id/mailboxes
+func (h *TeamHandler) Mailboxes(c echo.Context) error {
+	mailboxes, err := h.teamSvc.Mailboxes(c.Request().Context(), middleware.GetUserID(c), c.Param("id"))
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	if mailboxes == nil {
+		mailboxes = []*mailbox.Mailbox{}
+	}
+	return c.JSON(http.StatusOK, mailboxes)
+}
+
+// GetMailbox returns one of a team's mailbox connections.
+// GET /api/v1/teams/:id/mailboxes/:mailboxId
+func (h *TeamHandler) GetMailbox(c echo.Context) error {
+	mb, err := h.teamSvc.Mailbox(c.Request().Context(), middleware.GetUserID(c), c.Param("id"), c.Param("mailboxId"))
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	return c.JSON(http.StatusOK, mb)
+}
+
+// CreateMailbox adds a mailbox connection to a team. It accepts the same
+// fields as a user's mailbox.
+// POST /api/v1/teams/:id/mailboxes
+func (h *TeamHandler) CreateMailbox(c echo.Context) error {
+	var req CreateMailboxRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	mb, err := h.teamSvc.CreateMailbox(c.Request().Context(), middleware.GetUserID(c), c.Param("id"), mailbox.Input{
+		Name:     &req.Name,
+		Username: &req.Username,
+		Password: &req.Password,
+		Domain:   &req.Domain,
+		Port:     &req.Port,
+		Folders:  folderList(req.Folder, req.Folders),
+		Enabled:  req.Enabled,
+		Push:     req.Push,
+	})
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	return c.JSON(http.StatusCreated, mb)
+}
+
+// UpdateMailbox changes a team's mailbox connection. Omitted fields are
+// left unchanged.
+// PATCH /api/v1/teams/:id/mailboxes/:mailboxId
+func (h *TeamHandler) UpdateMailbox(c echo.Context) error {
+	var req UpdateMailboxRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	mb, err := h.teamSvc.UpdateMailbox(c.Request().Context(), middleware.GetUserID(c), c.Param("id"), c.Param("mailboxId"), mailbox.Input{
+		Name:     req.Name,
+		Username: req.Username,
+		Password: req.Password,
+		Domain:   req.Domain,
+		Port:     req.Port,
+		Folders:  folderList(req.Folder, req.Folders),
+		Enabled:  req.Enabled,
+		Push:     req.Push,
+	})
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	return c.JSON(http.StatusOK, mb)
+}
+
+// DeleteMailbox removes a team's mailbox connection.
+// DELETE /api/v1/teams/:id/mailboxes/:mailboxId
+func (h *TeamHandler) DeleteMailbox(c echo.Context) error {
+	err := h.teamSvc.DeleteMailbox(c.Request().Context(), middleware.GetUserID(c), c.Param("id"), c.Param("mailboxId"))
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	return c.JSON(http.StatusOK, msgOK("mailbox deleted"))
+}
+
+// TestMailbox signs in to one of a team's mailboxes and opens each of its
+// folders, like the user mailbox test.
+// POST /api/v1/teams/:id/mailboxes/:mailboxId/test
+func (h *TeamHandler) TestMailbox(c echo.Context) error {
+	mb, err := h.teamSvc.Mailbox(c.Request().Context(), middleware.GetUserID(c), c.Param("id"), c.Param("mailboxId"))
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	return testMailbox(c, h.summarySvc, mb)
+}
+
+// MailboxFolders lists the IMAP folders of one of a team's mailboxes.
+// GET /api/v1/teams/:id/mailboxes/:mailboxId/folders
+func (h *TeamHandler) MailboxFolders(c echo.Context) error {
+	mb, err := h.teamSvc.Mailbox(c.Request().Context(), middleware.GetUserID(c), c.Param("id"), c.Param("mailboxId"))
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	return mailboxFolders(c, h.summarySvc, h.logger, mb)
+}
+
+// Digests lists a team's digest configurations.
+// GET /api/v1/teams/:id/digests
+func (h *TeamHandler) Digests(c echo.Context) error {
+	digests, err := h.teamSvc.Digests(c.Request().Context(), middleware.GetUserID(c), c.Param("id"))
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	items := make([]TeamDigestResponse, 0, len(digests))
+	for _, d := range digests {
+		items = append(items, h.digestResponse(d))
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+// GetDigest returns one of a team's digest configurations with the status
+// of its last run.
+// GET /api/v1/teams/:id/digests/:digestId
+func (h *TeamHandler) GetDigest(c echo.Context) error {
+	d, err := h.teamSvc.Digest(c.Request().Context(), middleware.GetUserID(c), c.Param("id"), c.Param("digestId"))
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	return c.JSON(http.StatusOK, h.digestResponse(d))
+}
+
+// CreateDigest adds a digest configuration to a team and schedules it
+// when it has an interval.
+// POST /api/v1/teams/:id/digests
+func (h *TeamHandler) CreateDigest(c echo.Context) error {
+	var req CreateTeamDigestRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	d, err := h.teamSvc.CreateDigest(c.Request().Context(), middleware.GetUserID(c), c.Param("id"), team.DigestInput{
+		Name:             &req.Name,
+		MailboxID:        &req.MailboxID,
+		Folder:           &req.Folder,
+		Tags:             req.Tags,
+		BlackListSenders: req.BlackListSenders,
+		StartTime:        req.StartTime,
+		SummaryCount:     req.SummaryCount,
+		UpdateInterval:   &req.UpdateInterval,
+	})
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	h.scheduler.ScheduleTeamDigest(d)
+	return c.JSON(http.StatusCreated, h.digestResponse(d))
+}
+
+// UpdateDigest changes a team's digest configuration and reschedules it.
+// Omitted fields are left unchanged.
+// PATCH /api/v1/teams/:id/digests/:digestId
+func (h *TeamHandler) UpdateDigest(c echo.Context) error {
+	var req UpdateTeamDigestRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	d, err := h.teamSvc.UpdateDigest(c.Request().Context(), middleware.GetUserID(c), c.Param("id"), c.Param("digestId"), team.DigestInput{
+		Name:             req.Name,
+		MailboxID:        req.MailboxID,
+		Folder:           req.Folder,
+		Tags:             req.Tags,
+		BlackListSenders: req.BlackListSenders,
+		StartTime:        req.StartTime,
+		SummaryCount:     req.SummaryCount,
+		UpdateInterval:   req.UpdateInterval,
+	})
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	h.scheduler.ScheduleTeamDigest(d)
+	return c.JSON(http.StatusOK, h.digestResponse(d))
+}
+
+// DeleteDigest removes a team's digest configuration and its history.
+// DELETE /api/v1/teams/:id/digests/:digestId
+func (h *TeamHandler) DeleteDigest(c echo.Context) error {
+	id := c.Param("digestId")
+	if err := h.teamSvc.DeleteDigest(c.Request().Context(), middleware.GetUserID(c), c.Param("id"), id); err != nil {
+		return h.teamError(c, err)
+	}
+	h.scheduler.UnscheduleTeamDigest(id)
+	return c.JSON(http.StatusOK, msgOK("digest deleted"))
+}
+
+// RunDigest starts a run of a team digest immediately. The run happens in
+// the background; poll GetDigest for its outcome.
+// POST /api/v1/teams/:id/digests/:digestId/run
+func (h *TeamHandler) RunDigest(c echo.Context) error {
+	ctx := c.Request().Context()
+	userID, teamID := middleware.GetUserID(c), c.Param("id")
+	if err := h.teamSvc.Authorize(ctx, userID, teamID, team.RoleEditor); err != nil {
+		return h.teamError(c, err)
+	}
+	d, err := h.teamSvc.Digest(ctx, userID, teamID, c.Param("digestId"))
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	if len(d.Tags) == 0 {
+		return c.JSON(http.StatusBadRequest, errResp("configure tags before running the digest"))
+	}
+
+	err = h.scheduler.RunTeamDigestNow(d.ID)
+	if errors.Is(err, scheduler.ErrRunInProgress) {
+		return c.JSON(http.StatusConflict, errResp("a run is already in progress"))
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("failed to start run"))
+	}
+	return c.JSON(http.StatusAccepted, msgOK("run started"))
+}
+
+// History returns a page of a team digest's past runs, newest first.
+// GET /api/v1/teams/:id/digests/:digestId/history
+func (h *TeamHandler) History(c echo.Context) error {
+	var req ListSummariesRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = defaultPageSize
+	}
+
+	ctx := c.Request().Context()
+	d, err := h.teamSvc.Digest(ctx, middleware.GetUserID(c), c.Param("id"), c.Param("digestId"))
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	digests, total, err := h.summarySvc.ListTeam(ctx, d.ID, req.PageSize, (req.Page-1)*req.PageSize)
+	if err != nil {
+		h.logger.Error("listing team digest history failed", "error", err, "team_digest_id", d.ID)
+		return c.JSON(http.StatusInternalServerError, errResp("failed to list summaries"))
+	}
+
+	items := make([]DigestResponse, 0, len(digests))
+	for _, d := range digests {
+		items = append(items, newDigestResponse(d))
+	}
+	return c.JSON(http.StatusOK, DigestListResponse{
+		Items:    items,
+		Total:    total,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+	})
+}
+
+// HistoryItem returns a single past run of a team digest including its
+// word cloud image.
+// GET /api/v1/teams/:id/digests/:digestId/history/:runId
+func (h *TeamHandler) HistoryItem(c echo.Context) error {
+	ctx := c.Request().Context()
+	d, err := h.teamSvc.Digest(ctx, middleware.GetUserID(c), c.Param("id"), c.Param("digestId"))
+	if err != nil {
+		return h.teamError(c, err)
+	}
+	run, err := h.summarySvc.GetTeam(ctx, d.ID, c.Param("runId"))
+	if errors.Is(err, summary.ErrDigestNotFound) {
+		return c.JSON(http.StatusNotFound, errResp("summary not found"))
+	}
+	if err != nil {
+		h.logger.Error("getting team digest run failed", "error", err, "team_digest_id", d.ID)
+		return c.JSON(http.StatusInternalServerError, errResp("failed to get summary"))
+	}
+
+	resp := newDigestResponse(run)
+	if len(run.WordCloud) > 0 {
+		resp.Image = base64.StdEncoding.EncodeToString(run.WordCloud)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *TeamHandler) digestResponse(d *team.Digest) TeamDigestResponse {
+	resp := TeamDigestResponse{Digest: d}
+	if run, ok := h.scheduler.LastRun(d.ID); ok {
+		resp.LastRun = &run
+	}
+	return resp
+}
+
+// teamError maps team service errors to responses. Teams the caller does
+// not belong to are reported as not found.
+func (h *TeamHandler) teamError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, team.ErrNotFound):
+		return c.JSON(http.StatusNotFound, errResp("team not found"))
+	case errors.Is(err, team.ErrForbidden):
+		return c.JSON(http.StatusForbidden, errResp("your team role does not allow this"))
+	case errors.Is(err, team.ErrMemberNotFound):
+		return c.JSON(http.StatusNotFound, errResp("team member not found"))
+	case errors.Is(err, team.ErrMailboxNotFound):
+		return c.JSON(http.StatusNotFound, errResp("mailbox not found"))
+	case errors.Is(err, team.ErrDigestNotFound):
+		return c.JSON(http.StatusNotFound, errResp("digest not found"))
+	case errors.Is(err, team.ErrAlreadyMember):
+		return c.JSON(http.StatusConflict, errResp("user is already a team member"))
+	case errors.Is(err, team.ErrLastOwner):
+		return c.JSON(http.StatusConflict, errResp("a team needs at least one owner"))
+	case errors.Is(err, team.ErrMailboxInUse):
+		return c.JSON(http.StatusConflict, errResp("mailbox is used by a digest"))
+	case errors.Is(err, team.ErrInvalidRole), errors.Is(err, team.ErrInvalidInterval),
+		errors.Is(err, mailbox.ErrPasswordNeeded), errors.Is(err, mailbox.ErrInvalidFolder):
+		return c.JSON(http.StatusBadRequest, errResp(err.Error()))
+	default:
+		h.logger.Error("team request failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, errResp("team request failed"))
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/akhil-datla/maildruid/internal/config"
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/akhil-datla/maildruid/internal/domain/mfa"
+	"github.com/akhil-datla/maildruid/internal/server/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// TwoFactorHandler manages a signed-in user's TOTP setup.
+type TwoFactorHandler struct {
+	mfaSvc   *mfa.Service
+	auditSvc *audit.Service
+	authCfg  config.AuthConfig
+}
+
+// NewTwoFactorHandler creates a new two-factor handler.
+func NewTwoFactorHandler(mfaSvc *mfa.Service, auditSvc *audit.Service, authCfg config.AuthConfig) *TwoFactorHandler {
+	return &TwoFactorHandler{mfaSvc: mfaSvc, auditSvc: auditSvc, authCfg: authCfg}
+}
+
+// Status reports whether two-factor authentication is enabled.
+// GET /api/v1/users/me/2fa
+func (h *TwoFactorHandler) Status(c echo.Context) error {
+	status, err := h.mfaSvc.Status(c.Request().Context(), middleware.GetUserID(c))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("failed to load two-factor status"))
+	}
+	return c.JSON(http.StatusOK, status)
+}
+
+// Enroll starts TOTP setup and returns the secret and provisioning URI.
+// POST /api/v1/users/me/2fa/totp
+func (h *TwoFactorHandler) Enroll(c echo.Context) error {
+	enrollment, err := h.mfaSvc.Enroll(c.Request().Context(), middleware.GetUserID(c))
+	if err != nil {
+		return mfaError(c, err)
+	}
+	return c.JSON(http.StatusOK, enrollment)
+}
+
+// Confirm enables TOTP with a code from the authenticator and returns the
+// recovery codes.
+// POST /api/v1/users/me/2fa/totp/confirm
+func (h *TwoFactorHandler) Confirm(c echo.Context) error {
+	var req TwoFactorCodeRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	id := middleware.GetUserID(c)
+	codes, err := h.mfaSvc.Confirm(ctx, id, req.Code)
+	if err != nil {
+		return mfaError(c, err)
+	}
+	h.auditSvc.TryRecord(ctx, audit.Event{UserID: id, Action: audit.ActionTwoFactorEnabled})
+	return c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Disable turns TOTP off. It is refused while two-factor authentication
+// is required for everyone.
+// DELETE /api/v1/users/me/2fa/totp
+func (h *TwoFactorHandler) Disable(c echo.Context) error {
+	var req TwoFactorCodeRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if h.authCfg.Require2FA {
+		return c.JSON(http.StatusForbidden, errResp("two-factor authentication is required"))
+	}
+
+	ctx := c.Request().Context()
+	id := middleware.GetUserID(c)
+	if err := h.mfaSvc.Disable(ctx, id, req.Code); err != nil {
+		return mfaError(c, err)
+	}
+	h.auditSvc.TryRecord(ctx, audit.Event{UserID: id, Action: audit.ActionTwoFactorDisabled})
+	return c.JSON(http.StatusOK, msgOK("two-factor authentication disabled"))
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes.
+// POST /api/v1/users/me/2fa/recovery-codes
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c echo.Context) error {
+	var req TwoFactorCodeRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	id := middleware.GetUserID(c)
+	codes, err := h.mfaSvc.RegenerateRecoveryCodes(ctx, id, req.Code)
+	if err != nil {
+		return mfaError(c, err)
+	}
+	h.auditSvc.TryRecord(ctx, audit.Event{UserID: id, Action: audit.ActionRecoveryCodesNew})
+	return c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// mfaError maps two-factor errors to responses.
+func mfaError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, mfa.ErrInvalidCode):
+		return c.JSON(http.StatusUnauthorized, errResp("invalid verification code"))
+	case errors.Is(err, mfa.ErrLocked):
+		return c.JSON(http.StatusTooManyRequests, errResp("too many failed attempts, try again later"))
+	case errors.Is(err, mfa.ErrNotEnrolled):
+		return c.JSON(http.StatusBadRequest, errResp("start two-factor enrollment first"))
+	case errors.Is(err, mfa.ErrNotFound):
+		return c.JSON(http.StatusBadRequest, errResp("two-factor authentication is not enabled"))
+	case errors.Is(err, mfa.ErrAlreadyEnabled):
+		return c.JSON(http.StatusConflict, errResp("two-factor authentication is already enabled"))
+	default:
+		return c.JSON(http.StatusInternalServerError, errResp("two-factor verification failed"))
+	}
+}
@@ -1,28 +1,36 @@
 package handlers
 
 import (
+	"context"
 	"errors"
+	"log/slog"
 	"net/http"
 
-	"github.com/akhil-datla/maildruid/internal/config"
+	"github.com/akhil-datla/maildruid/internal/domain/mailbox"
+	"github.com/akhil-datla/maildruid/internal/domain/team"
 	"github.com/akhil-datla/maildruid/internal/domain/user"
-	"github.com/akhil-datla/maildruid/internal/infrastructure/imap"
+	"github.com/akhil-datla/maildruid/internal/domain/verification"
 	"github.com/akhil-datla/maildruid/internal/server/middleware"
 	"github.com/labstack/echo/v4"
 )
 
 // UserHandler handles user-related HTTP endpoints.
 type UserHandler struct {
-	userSvc *user.Service
-	authCfg config.AuthConfig
+	userSvc    *user.Service
+	mailboxSvc *mailbox.Service
+	verifySvc  *verification.Service
+	teamSvc    *team.Service
+	logger     *slog.Logger
 }
 
 // NewUserHandler creates a new user handler.
-func NewUserHandler(userSvc *user.Service, authCfg config.AuthConfig) *UserHandler {
-	return &UserHandler{userSvc: userSvc, authCfg: authCfg}
+func NewUserHandler(userSvc *user.Service, mailboxSvc *mailbox.Service, verifySvc *verification.Service, teamSvc *team.Service, logger *slog.Logger) *UserHandler {
+	return &UserHandler{userSvc: userSvc, mailboxSvc: mailboxSvc, verifySvc: verifySvc, teamSvc: teamSvc, logger: logger}
 }
 
-// Create registers a new user.
+// Create registers a new user with their first mailbox connection and
+// emails a confirmation link to the receiving address. Digests are not
+// delivered until it is confirmed.
 // POST /api/v1/users
 func (h *UserHandler) Create(c echo.Context) error {
 	var req CreateUserRequest
@@ -35,8 +43,6 @@ func (h *UserHandler) Create(c echo.Context) error {
 		Email:          req.Email,
 		ReceivingEmail: req.ReceivingEmail,
 		Password:       req.Password,
-		Domain:         req.Domain,
-		Port:           req.Port,
 	})
 
 	if errors.Is(err, user.ErrAlreadyExists) {
@@ -46,31 +52,25 @@ func (h *UserHandler) Create(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, errResp("failed to create user"))
 	}
 
-	return c.JSON(http.StatusCreated, msgOK("user created successfully"))
-}
-
-// Login authenticates a user and returns a JWT.
-// POST /api/v1/auth/login
-func (h *UserHandler) Login(c echo.Context) error {
-	var req LoginRequest
-	if err := bindAndValidate(c, &req); err != nil {
-		return err
-	}
-
-	id, err := h.userSvc.Authenticate(c.Request().Context(), req.Email, req.Password)
-	if errors.Is(err, user.ErrInvalidPassword) {
-		return c.JSON(http.StatusUnauthorized, errResp("invalid credentials"))
-	}
+	ctx := c.Request().Context()
+	u, err := h.userSvc.GetByEmail(ctx, req.Email)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, errResp("authentication failed"))
+		return c.JSON(http.StatusInternalServerError, errResp("failed to create user"))
 	}
-
-	token, err := middleware.GenerateToken(id, []byte(h.authCfg.SigningKey), h.authCfg.TokenExpiry)
+	_, err = h.mailboxSvc.Create(ctx, mailbox.UserOwner(u.ID), mailbox.Input{
+		Name:     &req.Email,
+		Username: &req.Email,
+		Password: &req.IMAPPassword,
+		Domain:   &req.Domain,
+		Port:     &req.Port,
+	})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, errResp("could not generate token"))
+		h.logger.Error("failed to create mailbox", "user_id", u.ID, "error", err)
+		return c.JSON(http.StatusInternalServerError, errResp("failed to create mailbox"))
 	}
 
-	return c.JSON(http.StatusOK, TokenResponse{Token: token})
+	h.sendVerification(ctx, u.ID)
+	return c.JSON(http.StatusCreated, msgOK("user created successfully; check your receiving email to confirm it"))
 }
 
 // GetProfile returns the authenticated user's profile.
@@ -96,16 +96,14 @@ func (h *UserHandler) Update(c echo.Context) error {
 		return err
 	}
 
+	ctx := c.Request().Context()
 	id := middleware.GetUserID(c)
-	err := h.userSvc.Update(c.Request().Context(), id, user.UpdateInput{
+	err := h.userSvc.Update(ctx, id, user.UpdateInput{
 		Name:           req.Name,
 		Email:          req.Email,
 		ReceivingEmail: req.ReceivingEmail,
 		OldPassword:    req.OldPassword,
 		NewPassword:    req.NewPassword,
-		Domain:         req.Domain,
-		Port:           req.Port,
-		Folder:         req.Folder,
 	})
 
 	if errors.Is(err, user.ErrNotFound) {
@@ -118,59 +116,75 @@ func (h *UserHandler) Update(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, errResp("failed to update user"))
 	}
 
-	return c.JSON(http.StatusOK, msgOK("user updated successfully"))
-}
-
-// Delete removes the authenticated user.
-// DELETE /api/v1/users/me
-func (h *UserHandler) Delete(c echo.Context) error {
-	id := middleware.GetUserID(c)
-	if err := h.userSvc.Delete(c.Request().Context(), id); err != nil {
-		return c.JSON(http.StatusInternalServerError, errResp("failed to delete user"))
+	// A new receiving email has to be confirmed before digests go to it.
+	if req.ReceivingEmail != nil && h.sendVerification(ctx, id) {
+		return c.JSON(http.StatusOK, msgOK("user updated successfully; check your new receiving email to confirm it"))
 	}
-	return c.JSON(http.StatusOK, msgOK("user deleted successfully"))
+	return c.JSON(http.StatusOK, msgOK("user updated successfully"))
 }
 
-// GetFolders lists IMAP folders for the authenticated user.
-// GET /api/v1/users/me/folders
-func (h *UserHandler) GetFolders(c echo.Context) error {
-	id := middleware.GetUserID(c)
-	u, err := h.userSvc.GetByID(c.Request().Context(), id)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, errResp("failed to get user"))
-	}
-
-	password, err := h.userSvc.DecryptPassword(u)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, errResp("failed to decrypt credentials"))
+// VerifyEmail confirms a receiving email with the token from a
+// verification link.
+// GET /api/v1/verify-email
+func (h *UserHandler) VerifyEmail(c echo.Context) error {
+	var req VerifyEmailRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
 	}
 
-	im, err := imap.New(u.Email, password, u.Domain, u.Port)
-	if err != nil {
-		return c.JSON(http.StatusBadGateway, errResp("failed to connect to email server"))
+	err := h.verifySvc.Verify(c.Request().Context(), req.Token)
+	if errors.Is(err, verification.ErrInvalidToken) {
+		return c.JSON(http.StatusBadRequest, errResp("invalid or expired verification link"))
 	}
-
-	folders, err := im.GetFolders()
 	if err != nil {
-		return c.JSON(http.StatusBadGateway, errResp("failed to list folders"))
+		return c.JSON(http.StatusInternalServerError, errResp("failed to verify email"))
 	}
+	return c.JSON(http.StatusOK, msgOK("email verified, digests will now be delivered"))
+}
 
-	return c.JSON(http.StatusOK, folders)
+// ResendVerification emails a new confirmation link to the authenticated
+// user's receiving address.
+// POST /api/v1/users/me/verify-email
+func (h *UserHandler) ResendVerification(c echo.Context) error {
+	err := h.verifySvc.Send(c.Request().Context(), middleware.GetUserID(c))
+	switch {
+	case errors.Is(err, verification.ErrVerified):
+		return c.JSON(http.StatusConflict, errResp("receiving email is already verified"))
+	case errors.Is(err, verification.ErrTooSoon):
+		return c.JSON(http.StatusTooManyRequests, errResp("a link was sent recently, try again in a minute"))
+	case err != nil:
+		h.logger.Error("failed to send verification email", "user_id", middleware.GetUserID(c), "error", err)
+		return c.JSON(http.StatusInternalServerError, errResp("failed to send verification email"))
+	}
+	return c.JSON(http.StatusOK, msgOK("verification email sent"))
 }
 
-// UpdateFolder sets the IMAP folder.
-// PATCH /api/v1/users/me/folder
-func (h *UserHandler) UpdateFolder(c echo.Context) error {
-	var req UpdateFolderRequest
-	if err := bindAndValidate(c, &req); err != nil {
-		return err
+// sendVerification emails a confirmation link if the user's receiving
+// email is unverified and reports whether one was sent. Failures are
+// logged; the user can ask for a new link later.
+func (h *UserHandler) sendVerification(ctx context.Context, id string) bool {
+	err := h.verifySvc.Send(ctx, id)
+	if err != nil && !errors.Is(err, verification.ErrVerified) && !errors.Is(err, verification.ErrTooSoon) {
+		h.logger.Error("failed to send verification email", "user_id", id, "error", err)
 	}
+	return err == nil
+}
 
+// Delete removes the authenticated user. It is refused while the user is
+// the only owner of a team.
+// DELETE /api/v1/users/me
+func (h *UserHandler) Delete(c echo.Context) error {
 	id := middleware.GetUserID(c)
-	if err := h.userSvc.UpdateFolder(c.Request().Context(), id, req.Folder); err != nil {
-		return c.JSON(http.StatusInternalServerError, errResp("failed to update folder"))
+	ctx := c.Request().Context()
+	if err := h.teamSvc.KeepOwners(ctx, id); errors.Is(err, team.ErrLastOwner) {
+		return c.JSON(http.StatusConflict, errResp("hand over or delete the teams you own alone first"))
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("failed to delete user"))
 	}
-	return c.JSON(http.StatusOK, msgOK("folder updated"))
+	if err := h.userSvc.Delete(ctx, id); err != nil {
+		return c.JSON(http.StatusInternalServerError, errResp("failed to delete user"))
+	}
+	return c.JSON(http.StatusOK, msgOK("user deleted successfully"))
 }
 
 // UpdateTags sets the email filter tags.
@@ -2,17 +2,47 @@ package server
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/akhil-datla/maildruid/internal/config"
+	"github.com/akhil-datla/maildruid/internal/domain/apikey"
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/akhil-datla/maildruid/internal/domain/lockout"
+	"github.com/akhil-datla/maildruid/internal/domain/mailbox"
+	"github.com/akhil-datla/maildruid/internal/domain/mfa"
+	"github.com/akhil-datla/maildruid/internal/domain/passwordreset"
+	"github.com/akhil-datla/maildruid/internal/domain/session"
+	"github.com/akhil-datla/maildruid/internal/domain/summary"
+	"github.com/akhil-datla/maildruid/internal/domain/team"
 	"github.com/akhil-datla/maildruid/internal/domain/user"
+	"github.com/akhil-datla/maildruid/internal/domain/verification"
 	"github.com/akhil-datla/maildruid/internal/infrastructure/encryption"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/imap"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/oauth"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/oauth/oauthtest"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/oidc"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/oidc/oidctest"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/signing"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/smtp"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/sqlite"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/wordcloud"
+	"github.com/akhil-datla/maildruid/internal/scheduler"
 	"github.com/akhil-datla/maildruid/internal/server/handlers"
 	"github.com/akhil-datla/maildruid/internal/server/middleware"
 	"github.com/labstack/echo/v4"
@@ -21,14 +51,51 @@ import (
 	echoMW "github.com/labstack/echo/v4/middleware"
 )
 
-// testEnv sets up a real Echo server with real services backed by an in-memory repo.
+// testEnv sets up a real Echo server with real services backed by a SQLite database.
 type testEnv struct {
-	echo    *echo.Echo
-	userSvc *user.Service
-	authCfg config.AuthConfig
+	echo       *echo.Echo
+	db         *sqlite.DB
+	userSvc    *user.Service
+	mailboxSvc *mailbox.Service
+	teamSvc    *team.Service
+	sessionSvc *session.Service
+	mfaSvc     *mfa.Service
+	lockoutSvc *lockout.Service
+	verifySvc  *verification.Service
+	resetSvc   *passwordreset.Service
+	apiKeySvc  *apikey.Service
+	auditSvc   *audit.Service
+	summarySvc *summary.Service
+	sched      *scheduler.Scheduler
+	tokenKeys  *signing.KeySet
+	digests    summary.Repository
+	authCfg    config.AuthConfig
+	mailer     *testMailer
 }
 
-func setupTestEnv(t *testing.T) *testEnv {
+// testMailer records the links MailDruid would email.
+type testMailer struct {
+	unlocks       []string
+	verifications map[string]string // receiving email -> latest link
+	resets        map[string]string // login email -> latest link
+}
+
+func (m *testMailer) SendAccountUnlock(_, _, link string, _ time.Time) error {
+	m.unlocks = append(m.unlocks, link)
+	return nil
+}
+
+func (m *testMailer) SendPasswordReset(to, _, link string, _ time.Time) error {
+	m.resets[to] = link
+	return nil
+}
+
+func (m *testMailer) SendEmailVerification(to, _, link string, _ time.Time) error {
+	m.verifications[to] = link
+	return nil
+}
+
+func setupTestEnv(t *testing.T, opts ...func(*config.AuthConfig)) *testEnv {
 	t.Helper()
 
 	enc, err := encryption.New([]byte("0123456789abcdef0123456789abcdef"))
@@ -37,37 +104,151 @@ func setupTestEnv(t *testing.T) *testEnv {
 	}
 
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
-	repo := user.NewMemoryRepository()
-	userSvc := user.NewService(repo, enc, logger)
+	db, err := sqlite.New(config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")}, logger)
+	if err != nil {
+		t.Fatalf("sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	repo := sqlite.NewUserRepository(db)
+	auditSvc := audit.NewService(sqlite.NewAuditRepository(db), logger)
+	userSvc := user.NewService(repo, enc, auditSvc, logger)
+	mailboxSvc := mailbox.NewService(sqlite.NewMailboxRepository(db), enc, auditSvc, logger)
+	sessionSvc := session.NewService(sqlite.NewSessionRepository(db), userSvc, 24*time.Hour, logger)
+	apiKeySvc := apikey.NewService(sqlite.NewAPIKeyRepository(db), logger)
+	digests := sqlite.NewDigestRepository(db)
+	teamSvc := team.NewService(sqlite.NewTeamRepository(db), userSvc, mailboxSvc, auditSvc, logger)
+	summarySvc := summary.NewService(userSvc, mailboxSvc, teamSvc, nil, wordcloud.New(""), digests, sqlite.NewSyncStateRepository(db), imap.Timeouts{}, nil, imap.Limits{}, logger)
 
 	authCfg := config.AuthConfig{
 		SigningKey:    "test-signing-key-32-bytes-long!!",
 		EncryptionKey: "0123456789abcdef0123456789abcdef",
 		TokenExpiry:   3600_000_000_000, // 1h in nanoseconds
+		TOTPIssuer:    "MailDruid",
+	}
+	for _, opt := range opts {
+		opt(&authCfg)
 	}
+	tokenKeys := signing.NewHMAC([]byte(authCfg.SigningKey))
+	mfaSvc := mfa.NewService(sqlite.NewMFARepository(db), userSvc, enc, authCfg.TOTPIssuer, logger)
+	sched := scheduler.New(userSvc, teamSvc, summarySvc, smtp.New(config.SMTPConfig{}), logger)
+	t.Cleanup(sched.Stop)
+	mailer := &testMailer{verifications: make(map[string]string), resets: make(map[string]string)}
+	resetSvc := passwordreset.NewService(sqlite.NewPasswordResetRepository(db), userSvc, mailer, "http://localhost/reset-password", logger)
+	verifySvc := verification.NewService(sqlite.NewVerificationRepository(db), userSvc, mailer, "http://localhost/api/v1/verify-email", logger)
+	lockoutSvc := lockout.NewService(sqlite.NewLoginAttemptRepository(db), userSvc,
+		auditSvc, mailer, lockout.Policy{
+			Enabled:          authCfg.Lockout.Enabled,
+			AccountThreshold: authCfg.Lockout.AccountThreshold,
+			IPThreshold:      authCfg.Lockout.IPThreshold,
+			Window:           authCfg.Lockout.Window,
+			LockoutDuration:  authCfg.Lockout.Duration,
+			FreeAttempts:     authCfg.Lockout.FreeAttempts,
+			MaxDelay:         authCfg.Lockout.MaxDelay,
+		}, "http://localhost/unlock", logger)
 
 	e := echo.New()
+	e.IPExtractor = ipExtractor(nil)
 	e.Validator = handlers.NewValidator()
 	e.Use(echoMW.Recover())
+	e.Use(echoMW.RequestID())
+	e.Use(middleware.AuditSource())
 	e.Use(echoMW.RateLimiter(echoMW.NewRateLimiterMemoryStore(rate.Limit(100))))
 
-	userH := handlers.NewUserHandler(userSvc, authCfg)
+	authH := handlers.NewAuthHandler(userSvc, sessionSvc, mfaSvc, lockoutSvc, auditSvc, tokenKeys, authCfg)
+	userH := handlers.NewUserHandler(userSvc, mailboxSvc, verifySvc, teamSvc, logger)
+	mailboxH := handlers.NewMailboxHandler(mailboxSvc, summarySvc, logger)
+	resetH := handlers.NewPasswordResetHandler(resetSvc, logger)
+	twoFactorH := handlers.NewTwoFactorHandler(mfaSvc, auditSvc, authCfg)
+	apiKeyH := handlers.NewAPIKeyHandler(apiKeySvc, auditSvc)
+	auditH := handlers.NewAuditHandler(auditSvc, teamSvc, logger)
+	summaryH := handlers.NewSummaryHandler(userSvc, summarySvc, logger)
+	scheduleH := handlers.NewScheduleHandler(sched, userSvc)
+	adminH := handlers.NewAdminHandler(userSvc, teamSvc, sched, logger)
+	teamH := handlers.NewTeamHandler(teamSvc, summarySvc, sched, logger)
 
 	// Public routes
 	v1 := e.Group("/api/v1")
 	v1.POST("/users", userH.Create)
-	v1.POST("/auth/login", userH.Login)
+	v1.POST("/auth/login", authH.Login)
+	v1.POST("/auth/2fa", authH.CompleteTwoFactor)
+	v1.POST("/auth/2fa/enroll", authH.EnrollTwoFactor)
+	v1.POST("/auth/unlock", authH.Unlock)
+	v1.POST("/auth/forgot-password", resetH.ForgotPassword)
+	v1.POST("/auth/reset-password", resetH.ResetPassword)
+	v1.POST("/auth/refresh", authH.Refresh)
+	v1.GET("/verify-email", userH.VerifyEmail)
 
 	// Protected routes
-	auth := v1.Group("", middleware.JWTAuth([]byte(authCfg.SigningKey)))
+	auth := v1.Group("",
+		middleware.WithAPIKeys(apikey.KeyPrefix, apiKeyAuth(apiKeySvc, userSvc),
+			middleware.JWTAuth(tokenKeys, sessionSvc)),
+		middleware.RequireScopes(apiKeyScopes),
+		middleware.AuditActor(),
+	)
+	auth.POST("/auth/logout", authH.Logout, middleware.SessionOnly())
+	auth.GET("/users/me/api-keys", apiKeyH.List, middleware.SessionOnly())
+	auth.POST("/users/me/api-keys", apiKeyH.Create, middleware.SessionOnly())
+	auth.DELETE("/users/me/api-keys/:id", apiKeyH.Delete, middleware.SessionOnly())
+	auth.GET("/users/me/2fa", twoFactorH.Status, middleware.SessionOnly())
+	auth.POST("/users/me/2fa/totp", twoFactorH.Enroll, middleware.SessionOnly())
+	auth.POST("/users/me/2fa/totp/confirm", twoFactorH.Confirm, middleware.SessionOnly())
+	auth.DELETE("/users/me/2fa/totp", twoFactorH.Disable, middleware.SessionOnly())
+	auth.POST("/users/me/2fa/recovery-codes", twoFactorH.RegenerateRecoveryCodes, middleware.SessionOnly())
 	auth.GET("/users/me", userH.GetProfile)
 	auth.PATCH("/users/me", userH.Update)
 	auth.DELETE("/users/me", userH.Delete)
-	auth.PATCH("/users/me/folder", userH.UpdateFolder)
+	auth.POST("/users/me/verify-email", userH.ResendVerification, middleware.SessionOnly())
+	auth.GET("/users/me/audit", auditH.ListMine)
+	auth.GET("/mailboxes", mailboxH.List)
+	auth.POST("/mailboxes", mailboxH.Create)
+	auth.GET("/mailboxes/:id", mailboxH.Get)
+	auth.PATCH("/mailboxes/:id", mailboxH.Update)
+	auth.DELETE("/mailboxes/:id", mailboxH.Delete)
+	auth.POST("/mailboxes/:id/test", mailboxH.Test)
 	auth.PUT("/users/me/tags", userH.UpdateTags)
 	auth.PUT("/users/me/blacklist", userH.UpdateBlacklist)
 	auth.PATCH("/users/me/start-time", userH.UpdateStartTime)
 	auth.PATCH("/users/me/summary-count", userH.UpdateSummaryCount)
+	auth.GET("/summaries", summaryH.List)
+	auth.GET("/summaries/:id", summaryH.Get)
+	auth.GET("/schedules", scheduleH.List)
+	auth.POST("/schedules", scheduleH.Create)
+	auth.GET("/teams", teamH.List)
+	auth.POST("/teams", teamH.Create)
+	auth.GET("/teams/:id", teamH.Get)
+	auth.PATCH("/teams/:id", teamH.Update)
+	auth.DELETE("/teams/:id", teamH.Delete)
+	auth.GET("/teams/:id/audit", auditH.ListTeam)
+	auth.GET("/teams/:id/members", teamH.Members)
+	auth.POST("/teams/:id/members", teamH.AddMember)
+	auth.PUT("/teams/:id/members/me/delivery", teamH.UpdateDelivery)
+	auth.PATCH("/teams/:id/members/:userId", teamH.UpdateMember)
+	auth.DELETE("/teams/:id/members/:userId", teamH.RemoveMember)
+	auth.GET("/teams/:id/mailboxes", teamH.Mailboxes)
+	auth.POST("/teams/:id/mailboxes", teamH.CreateMailbox)
+	auth.GET("/teams/:id/mailboxes/:mailboxId", teamH.GetMailbox)
+	auth.PATCH("/teams/:id/mailboxes/:mailboxId", teamH.UpdateMailbox)
+	auth.DELETE("/teams/:id/mailboxes/:mailboxId", teamH.DeleteMailbox)
+	auth.GET("/teams/:id/digests", teamH.Digests)
+	auth.POST("/teams/:id/digests", teamH.CreateDigest)
+	auth.GET("/teams/:id/digests/:digestId", teamH.GetDigest)
+	auth.PATCH("/teams/:id/digests/:digestId", teamH.UpdateDigest)
+	auth.DELETE("/teams/:id/digests/:digestId", teamH.DeleteDigest)
+	auth.GET("/teams/:id/digests/:digestId/history", teamH.History)
+	auth.GET("/teams/:id/digests/:digestId/history/:runId", teamH.HistoryItem)
+	admin := auth.Group("/admin", middleware.SessionOnly(), middleware.RequireAdmin(isAdmin(userSvc)))
+	admin.GET("/users", adminH.ListUsers)
+	admin.GET("/users/:id", adminH.GetUser)
+	admin.DELETE("/users/:id", adminH.DeleteUser)
+	admin.POST("/users/:id/disable", adminH.DisableUser)
+	admin.POST("/users/:id/enable", adminH.EnableUser)
+	admin.GET("/users/:id/schedule", adminH.GetSchedule)
+	admin.POST("/users/:id/schedule/run", adminH.RunSchedule)
+	admin.DELETE("/users/:id/schedule", adminH.CancelSchedule)
+	admin.GET("/audit", auditH.List)
 
 	// Frontend
 	e.GET("/*", echo.WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -75,7 +256,12 @@ func setupTestEnv(t *testing.T) *testEnv {
 		_, _ = w.Write([]byte("<!doctype html>"))
 	})))
 
-	return &testEnv{echo: e, userSvc: userSvc, authCfg: authCfg}
+	return &testEnv{
+		echo: e, db: db, userSvc: userSvc, mailboxSvc: mailboxSvc, teamSvc: teamSvc, sessionSvc: sessionSvc,
+		mfaSvc: mfaSvc, lockoutSvc: lockoutSvc, verifySvc: verifySvc, resetSvc: resetSvc, apiKeySvc: apiKeySvc,
+		auditSvc: auditSvc, summarySvc: summarySvc, sched: sched, tokenKeys: tokenKeys, digests: digests,
+		authCfg: authCfg, mailer: mailer,
+	}
 }
 
 func (te *testEnv) request(method, path string, body interface{}, token string) *httptest.ResponseRecorder {
@@ -110,7 +296,7 @@ func registerAndLogin(t *testing.T, env *testEnv, email string) string {
 	t.Helper()
 	rec := env.request("POST", "/api/v1/users", map[string]interface{}{
 		"name": "Test", "email": email, "receivingEmail": "r@t.com",
-		"password": "secret123", "domain": "imap.t.com", "port": 993,
+		"password": "secret123", "imapPassword": "imap-secret", "domain": "imap.t.com", "port": 993,
 	}, "")
 	if rec.Code != http.StatusCreated {
 		t.Fatalf("register %s: expected 201, got %d: %s", email, rec.Code, rec.Body.String())
@@ -142,6 +328,7 @@ func TestFullUserLifecycle(t *testing.T) {
 		"email":          "jane@test.com",
 		"receivingEmail": "jane@gmail.com",
 		"password":       "secret123",
+		"imapPassword":   "imap-secret",
 		"domain":         "imap.test.com",
 		"port":           993,
 	}, "")
@@ -182,12 +369,11 @@ func TestFullUserLifecycle(t *testing.T) {
 	if profile["receivingEmail"] != "jane@gmail.com" {
 		t.Errorf("profile receivingEmail: expected 'jane@gmail.com', got %v", profile["receivingEmail"])
 	}
-	if profile["domain"] != "imap.test.com" {
-		t.Errorf("profile domain: expected 'imap.test.com', got %v", profile["domain"])
-	}
 	// Password should NOT be in response (json:"-")
-	if _, exists := profile["password"]; exists {
-		t.Error("profile should not expose password")
+	for _, key := range []string{"password", "passwordHash", "imapPassword"} {
+		if _, exists := profile[key]; exists {
+			t.Errorf("profile should not expose %s", key)
+		}
 	}
 
 	// 4. Update profile
@@ -209,12 +395,29 @@ func TestFullUserLifecycle(t *testing.T) {
 		t.Errorf("updated email: expected 'jane.smith@gmail.com', got %v", profile["receivingEmail"])
 	}
 
-	// 5. Update folder
-	rec = env.request("PATCH", "/api/v1/users/me/folder", map[string]interface{}{
-		"folder": "INBOX",
+	// 5. The registered connection is the first mailbox; choose its folders
+	rec = env.request("GET", "/api/v1/mailboxes", nil, token)
+	var mailboxes []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &mailboxes); err != nil || len(mailboxes) != 1 {
+		t.Fatalf("list mailboxes: expected one mailbox, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if mailboxes[0]["domain"] != "imap.test.com" || mailboxes[0]["username"] != "jane@test.com" {
+		t.Errorf("unexpected mailbox %v", mailboxes[0])
+	}
+	if _, exists := mailboxes[0]["password"]; exists {
+		t.Error("mailbox should not expose its password")
+	}
+	rec = env.request("PATCH", "/api/v1/mailboxes/"+mailboxes[0]["id"].(string), map[string]interface{}{
+		"folders": []string{"INBOX", "Lists/*"},
 	}, token)
 	if rec.Code != http.StatusOK {
-		t.Fatalf("update folder: expected 200, got %d: %s", rec.Code, rec.Body.String())
+		t.Fatalf("update folders: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = env.request("PATCH", "/api/v1/mailboxes/"+mailboxes[0]["id"].(string), map[string]interface{}{
+		"folders": []string{"Lists/["},
+	}, token)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("malformed folder pattern: expected 400, got %d: %s", rec.Code, rec.Body.String())
 	}
 
 	// 6. Update tags
@@ -252,8 +455,9 @@ func TestFullUserLifecycle(t *testing.T) {
 	// 10. Verify all settings persisted
 	rec = env.request("GET", "/api/v1/users/me", nil, token)
 	profile = parseJSON(t, rec)
-	if profile["folder"] != "INBOX" {
-		t.Errorf("folder: expected 'INBOX', got %v", profile["folder"])
+	rec = env.request("GET", "/api/v1/mailboxes/"+mailboxes[0]["id"].(string), nil, token)
+	if folders, _ := parseJSON(t, rec)["folders"].([]interface{}); len(folders) != 2 || folders[1] != "Lists/*" {
+		t.Errorf("folders: expected [INBOX Lists/*], got %v", folders)
 	}
 	tags, ok := profile["tags"].([]interface{})
 	if !ok || len(tags) != 3 {
@@ -273,10 +477,10 @@ func TestFullUserLifecycle(t *testing.T) {
 		t.Fatalf("delete: expected 200, got %d: %s", rec.Code, rec.Body.String())
 	}
 
-	// 12. Verify user is gone
+	// 12. Verify the deleted user's token no longer works
 	rec = env.request("GET", "/api/v1/users/me", nil, token)
-	if rec.Code != http.StatusNotFound {
-		t.Errorf("after delete: expected 404, got %d", rec.Code)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("after delete: expected 401, got %d", rec.Code)
 	}
 }
 
@@ -290,27 +494,32 @@ func TestRegistrationValidation(t *testing.T) {
 	}{
 		{
 			"missing name",
-			map[string]interface{}{"email": "a@b.com", "receivingEmail": "a@b.com", "password": "123456", "domain": "imap.com", "port": 993},
+			map[string]interface{}{"email": "a@b.com", "receivingEmail": "a@b.com", "password": "12345678", "imapPassword": "imap", "domain": "imap.com", "port": 993},
 			http.StatusBadRequest,
 		},
 		{
 			"invalid email",
-			map[string]interface{}{"name": "Test", "email": "not-email", "receivingEmail": "a@b.com", "password": "123456", "domain": "imap.com", "port": 993},
+			map[string]interface{}{"name": "Test", "email": "not-email", "receivingEmail": "a@b.com", "password": "12345678", "imapPassword": "imap", "domain": "imap.com", "port": 993},
 			http.StatusBadRequest,
 		},
 		{
 			"password too short",
-			map[string]interface{}{"name": "Test", "email": "a@b.com", "receivingEmail": "a@b.com", "password": "123", "domain": "imap.com", "port": 993},
+			map[string]interface{}{"name": "Test", "email": "a@b.com", "receivingEmail": "a@b.com", "password": "123", "imapPassword": "imap", "domain": "imap.com", "port": 993},
+			http.StatusBadRequest,
+		},
+		{
+			"missing IMAP password",
+			map[string]interface{}{"name": "Test", "email": "a@b.com", "receivingEmail": "a@b.com", "password": "12345678", "domain": "imap.com", "port": 993},
 			http.StatusBadRequest,
 		},
 		{
 			"port out of range",
-			map[string]interface{}{"name": "Test", "email": "a@b.com", "receivingEmail": "a@b.com", "password": "123456", "domain": "imap.com", "port": 99999},
+			map[string]interface{}{"name": "Test", "email": "a@b.com", "receivingEmail": "a@b.com", "password": "12345678", "imapPassword": "imap", "domain": "imap.com", "port": 99999},
 			http.StatusBadRequest,
 		},
 		{
 			"missing domain",
-			map[string]interface{}{"name": "Test", "email": "a@b.com", "receivingEmail": "a@b.com", "password": "123456", "port": 993},
+			map[string]interface{}{"name": "Test", "email": "a@b.com", "receivingEmail": "a@b.com", "password": "12345678", "imapPassword": "imap", "port": 993},
 			http.StatusBadRequest,
 		},
 	}
@@ -351,7 +560,7 @@ func TestDuplicateRegistration(t *testing.T) {
 
 	body := map[string]interface{}{
 		"name": "User", "email": "dup@test.com", "receivingEmail": "r@t.com",
-		"password": "secret123", "domain": "imap.test.com", "port": 993,
+		"password": "secret123", "imapPassword": "imap-secret", "domain": "imap.test.com", "port": 993,
 	}
 
 	rec := env.request("POST", "/api/v1/users", body, "")
@@ -372,14 +581,37 @@ func TestProtectedRoutesRequireAuth(t *testing.T) {
 		method string
 		path   string
 	}{
+		{"POST", "/api/v1/auth/logout"},
 		{"GET", "/api/v1/users/me"},
 		{"PATCH", "/api/v1/users/me"},
 		{"DELETE", "/api/v1/users/me"},
-		{"PATCH", "/api/v1/users/me/folder"},
+		{"POST", "/api/v1/users/me/verify-email"},
+		{"GET", "/api/v1/users/me/audit"},
+		{"GET", "/api/v1/admin/audit"},
+		{"GET", "/api/v1/mailboxes"},
+		{"POST", "/api/v1/mailboxes"},
+		{"PATCH", "/api/v1/mailboxes/some-id"},
+		{"POST", "/api/v1/mailboxes/some-id/test"},
 		{"PUT", "/api/v1/users/me/tags"},
 		{"PUT", "/api/v1/users/me/blacklist"},
 		{"PATCH", "/api/v1/users/me/start-time"},
 		{"PATCH", "/api/v1/users/me/summary-count"},
+		{"GET", "/api/v1/summaries"},
+		{"GET", "/api/v1/summaries/some-id"},
+		{"GET", "/api/v1/users/me/api-keys"},
+		{"GET", "/api/v1/users/me/2fa"},
+		{"POST", "/api/v1/users/me/2fa/totp"},
+		{"DELETE", "/api/v1/users/me/2fa/totp"},
+		{"POST", "/api/v1/users/me/api-keys"},
+		{"DELETE", "/api/v1/users/me/api-keys/some-id"},
+		{"GET", "/api/v1/schedules"},
+		{"GET", "/api/v1/teams"},
+		{"POST", "/api/v1/teams"},
+		{"GET", "/api/v1/teams/some-id/mailboxes"},
+		{"GET", "/api/v1/teams/some-id/digests/some-id/history"},
+		{"GET", "/api/v1/admin/users"},
+		{"POST", "/api/v1/admin/users/some-id/disable"},
+		{"POST", "/api/v1/admin/users/some-id/schedule/run"},
 	}
 
 	for _, ep := range endpoints {
@@ -474,14 +706,16 @@ func TestPasswordChangeFlow(t *testing.T) {
 	// Register
 	env.request("POST", "/api/v1/users", map[string]interface{}{
 		"name": "Pass", "email": "pass@t.com", "receivingEmail": "r@t.com",
-		"password": "oldpass123", "domain": "imap.t.com", "port": 993,
+		"password": "oldpass123", "imapPassword": "imap-secret", "domain": "imap.t.com", "port": 993,
 	}, "")
 
 	// Login with old password
 	rec := env.request("POST", "/api/v1/auth/login", map[string]interface{}{
 		"email": "pass@t.com", "password": "oldpass123",
 	}, "")
-	token := parseJSON(t, rec)["token"].(string)
+	result := parseJSON(t, rec)
+	token := result["token"].(string)
+	refresh := result["refreshToken"].(string)
 
 	// Change password
 	rec = env.request("PATCH", "/api/v1/users/me", map[string]interface{}{
@@ -492,6 +726,16 @@ func TestPasswordChangeFlow(t *testing.T) {
 		t.Fatalf("password change: expected 200, got %d: %s", rec.Code, rec.Body.String())
 	}
 
+	// Existing sessions are signed out
+	rec = env.request("GET", "/api/v1/users/me", nil, token)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("access token after password change: expected 401, got %d", rec.Code)
+	}
+	rec = env.request("POST", "/api/v1/auth/refresh", map[string]interface{}{"refreshToken": refresh}, "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("refresh token after password change: expected 401, got %d", rec.Code)
+	}
+
 	// Old password should fail
 	rec = env.request("POST", "/api/v1/auth/login", map[string]interface{}{
 		"email": "pass@t.com", "password": "oldpass123",
@@ -508,3 +752,1347 @@ func TestPasswordChangeFlow(t *testing.T) {
 		t.Errorf("new password: expected 200, got %d: %s", rec.Code, rec.Body.String())
 	}
 }
+
+func TestSummaryHistory(t *testing.T) {
+	env := setupTestEnv(t)
+	token := registerAndLogin(t, env, "history@t.com")
+	other := registerAndLogin(t, env, "other@t.com")
+
+	u, err := env.userSvc.GetByID(context.Background(), userIDFromProfile(t, env, token))
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+
+	base := time.Date(2025, 5, 1, 9, 0, 0, 0, time.UTC)
+	for i, id := range []string{"d1", "d2", "d3"} {
+		err := env.digests.Create(context.Background(), &summary.Digest{
+			ID:         id,
+			UserID:     u.ID,
+			Summary:    "digest " + id,
+			Keywords:   []string{"launch"},
+			WordCloud:  []byte("png-bytes"),
+			Tags:       []string{"report"},
+			EmailCount: 4,
+			Trigger:    summary.TriggerScheduled,
+			StartedAt:  base,
+			CreatedAt:  base.Add(time.Duration(i) * 24 * time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("creating digest: %v", err)
+		}
+	}
+
+	// Paginated list, newest first, without images
+	rec := env.request("GET", "/api/v1/summaries?page=1&pageSize=2", nil, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	list := parseJSON(t, rec)
+	if list["total"] != float64(3) {
+		t.Errorf("total: expected 3, got %v", list["total"])
+	}
+	items, _ := list["items"].([]interface{})
+	if len(items) != 2 {
+		t.Fatalf("items: expected 2, got %v", list["items"])
+	}
+	first := items[0].(map[string]interface{})
+	if first["id"] != "d3" || first["trigger"] != "scheduled" {
+		t.Errorf("unexpected first item: %v", first)
+	}
+	if _, ok := first["image"]; ok {
+		t.Error("list items should not include images")
+	}
+
+	// Single digest includes the image
+	rec = env.request("GET", "/api/v1/summaries/d1", nil, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if parseJSON(t, rec)["image"] == "" {
+		t.Error("expected image in digest detail")
+	}
+
+	// Other users cannot see it
+	rec = env.request("GET", "/api/v1/summaries/d1", nil, other)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("other user: expected 404, got %d", rec.Code)
+	}
+
+	// Invalid page size
+	rec = env.request("GET", "/api/v1/summaries?pageSize=1000", nil, token)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid page size: expected 400, got %d", rec.Code)
+	}
+}
+
+func userIDFromProfile(t *testing.T, env *testEnv, token string) string {
+	t.Helper()
+	rec := env.request("GET", "/api/v1/users/me", nil, token)
+	id, _ := parseJSON(t, rec)["id"].(string)
+	if id == "" {
+		t.Fatal("profile has no id")
+	}
+	return id
+}
+
+// firstMailboxID returns the ID of the mailbox created at registration.
+func firstMailboxID(t *testing.T, env *testEnv, token string) string {
+	t.Helper()
+	rec := env.request("GET", "/api/v1/mailboxes", nil, token)
+	var mailboxes []mailbox.Mailbox
+	if err := json.Unmarshal(rec.Body.Bytes(), &mailboxes); err != nil || len(mailboxes) == 0 {
+		t.Fatalf("list mailboxes: %d: %s", rec.Code, rec.Body.String())
+	}
+	return mailboxes[0].ID
+}
+
+func TestRefreshAndLogout(t *testing.T) {
+	env := setupTestEnv(t)
+
+	env.request("POST", "/api/v1/users", map[string]interface{}{
+		"name": "Sess", "email": "sess@t.com", "receivingEmail": "r@t.com",
+		"password": "secret123", "imapPassword": "imap-secret", "domain": "imap.t.com", "port": 993,
+	}, "")
+	login := func() (string, string) {
+		rec := env.request("POST", "/api/v1/auth/login", map[string]interface{}{
+			"email": "sess@t.com", "password": "secret123",
+		}, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("login: expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		result := parseJSON(t, rec)
+		if result["expiresIn"] != float64(3600) {
+			t.Errorf("expiresIn: expected 3600, got %v", result["expiresIn"])
+		}
+		return result["token"].(string), result["refreshToken"].(string)
+	}
+
+	token, refresh := login()
+
+	// Refresh rotates the refresh token
+	rec := env.request("POST", "/api/v1/auth/refresh", map[string]interface{}{"refreshToken": refresh}, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("refresh: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	result := parseJSON(t, rec)
+	newToken, newRefresh := result["token"].(string), result["refreshToken"].(string)
+	if newRefresh == refresh {
+		t.Fatal("refresh token should rotate")
+	}
+	if rec := env.request("GET", "/api/v1/users/me", nil, newToken); rec.Code != http.StatusOK {
+		t.Errorf("refreshed access token: expected 200, got %d", rec.Code)
+	}
+
+	// Missing refresh token fails validation
+	if rec := env.request("POST", "/api/v1/auth/refresh", map[string]interface{}{}, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("empty refresh: expected 400, got %d", rec.Code)
+	}
+
+	// Logout revokes the access token and refresh token
+	rec = env.request("POST", "/api/v1/auth/logout", map[string]interface{}{"refreshToken": newRefresh}, newToken)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("logout: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := env.request("GET", "/api/v1/users/me", nil, newToken); rec.Code != http.StatusUnauthorized {
+		t.Errorf("access token after logout: expected 401, got %d", rec.Code)
+	}
+	if rec := env.request("POST", "/api/v1/auth/refresh", map[string]interface{}{"refreshToken": newRefresh}, ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("refresh after logout: expected 401, got %d", rec.Code)
+	}
+
+	// The first access token was not revoked by logging out another session
+	if rec := env.request("GET", "/api/v1/users/me", nil, token); rec.Code != http.StatusOK {
+		t.Errorf("other access token: expected 200, got %d", rec.Code)
+	}
+
+	// Logging out of all sessions revokes everything
+	otherToken, otherRefresh := login()
+	rec = env.request("POST", "/api/v1/auth/logout", map[string]interface{}{"allSessions": true}, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("logout all: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, tok := range []string{token, otherToken} {
+		if rec := env.request("GET", "/api/v1/users/me", nil, tok); rec.Code != http.StatusUnauthorized {
+			t.Errorf("access token after logout all: expected 401, got %d", rec.Code)
+		}
+	}
+	if rec := env.request("POST", "/api/v1/auth/refresh", map[string]interface{}{"refreshToken": otherRefresh}, ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("refresh after logout all: expected 401, got %d", rec.Code)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	env := setupTestEnv(t)
+	token := registerAndLogin(t, env, "keys@t.com")
+
+	// Unknown scopes are rejected
+	rec := env.request("POST", "/api/v1/users/me/api-keys", map[string]interface{}{
+		"name": "bad", "scopes": []string{"admin"},
+	}, token)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown scope: expected 400, got %d", rec.Code)
+	}
+
+	rec = env.request("POST", "/api/v1/users/me/api-keys", map[string]interface{}{
+		"name": "dashboard", "scopes": []string{"read-only"},
+	}, token)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create key: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	created := parseJSON(t, rec)
+	key, _ := created["key"].(string)
+	id, _ := created["id"].(string)
+	if key == "" || id == "" {
+		t.Fatalf("create key: missing key or id: %v", created)
+	}
+
+	// A read-only key can read but not write
+	if rec := env.request("GET", "/api/v1/users/me", nil, key); rec.Code != http.StatusOK {
+		t.Errorf("read with key: expected 200, got %d", rec.Code)
+	}
+	if rec := env.request("PATCH", "/api/v1/users/me", map[string]interface{}{"name": "x"}, key); rec.Code != http.StatusForbidden {
+		t.Errorf("write with read-only key: expected 403, got %d", rec.Code)
+	}
+
+	// Keys cannot manage keys
+	if rec := env.request("GET", "/api/v1/users/me/api-keys", nil, key); rec.Code != http.StatusForbidden {
+		t.Errorf("list keys with key: expected 403, got %d", rec.Code)
+	}
+
+	// The listing shows last use and never the secret
+	rec = env.request("GET", "/api/v1/users/me/api-keys", nil, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list keys: expected 200, got %d", rec.Code)
+	}
+	var keys []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &keys); err != nil {
+		t.Fatalf("list keys: %v", err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("expected 1 key, got %d", len(keys))
+	}
+	if keys[0]["lastUsedAt"] == nil || keys[0]["lastUsedIp"] == "" {
+		t.Errorf("expected last-used details, got %v", keys[0])
+	}
+	if _, ok := keys[0]["key"]; ok {
+		t.Error("listing must not include the key")
+	}
+
+	// Revoked keys stop working
+	if rec := env.request("DELETE", "/api/v1/users/me/api-keys/"+id, nil, token); rec.Code != http.StatusOK {
+		t.Fatalf("delete key: expected 200, got %d", rec.Code)
+	}
+	if rec := env.request("GET", "/api/v1/users/me", nil, key); rec.Code != http.StatusUnauthorized {
+		t.Errorf("revoked key: expected 401, got %d", rec.Code)
+	}
+	if rec := env.request("DELETE", "/api/v1/users/me/api-keys/"+id, nil, token); rec.Code != http.StatusNotFound {
+		t.Errorf("delete missing key: expected 404, got %d", rec.Code)
+	}
+}
+
+// enableOIDC registers the SSO routes against a mock issuer.
+func (te *testEnv) enableOIDC(t *testing.T, autoProvision bool) *oidctest.Issuer {
+	t.Helper()
+	iss := oidctest.NewIssuer(t)
+	cfg := te.authCfg
+	cfg.OIDC = config.OIDCConfig{
+		Enabled:       true,
+		IssuerURL:     iss.URL,
+		ClientID:      oidctest.ClientID,
+		RedirectURL:   "http://maildruid.test/api/v1/auth/oidc/callback",
+		AutoProvision: autoProvision,
+	}
+	provider, err := oidc.New(context.Background(), cfg.OIDC)
+	if err != nil {
+		t.Fatalf("oidc: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	h := handlers.NewOIDCHandler(provider, te.userSvc, te.sessionSvc, te.mfaSvc, te.auditSvc, te.tokenKeys, cfg, logger)
+	te.echo.GET("/api/v1/auth/oidc/login", h.Login)
+	te.echo.GET("/api/v1/auth/oidc/callback", h.Callback)
+	return iss
+}
+
+// ssoLogin runs the browser side of an SSO login and returns the callback
+// response.
+func (te *testEnv) ssoLogin(t *testing.T, iss *oidctest.Issuer) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := te.request("GET", "/api/v1/auth/oidc/login", nil, "")
+	if rec.Code != http.StatusFound {
+		t.Fatalf("oidc login: expected 302, got %d", rec.Code)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatalf("expected an HttpOnly login cookie, got %v", cookies)
+	}
+
+	back := iss.Authorize(t, rec.Header().Get("Location"))
+	req := httptest.NewRequest("GET", "/api/v1/auth/oidc/callback?"+back.RawQuery, nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	te.echo.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestOIDCLogin(t *testing.T) {
+	env := setupTestEnv(t)
+	iss := env.enableOIDC(t, true)
+	iss.SetIdentity(oidctest.Identity{Subject: "sso-1", Email: "sso@t.com", EmailVerified: true, Name: "Sso User"})
+
+	rec := env.ssoLogin(t, iss)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("callback: expected 302, got %d: %s", rec.Code, rec.Body.String())
+	}
+	loc, _ := url.Parse(rec.Header().Get("Location"))
+	if loc.Path != "/sso" {
+		t.Errorf("expected redirect to /sso, got %q", loc.Path)
+	}
+	fragment, _ := url.ParseQuery(loc.Fragment)
+	token := fragment.Get("token")
+	if token == "" || fragment.Get("refreshToken") == "" {
+		t.Fatalf("expected tokens in fragment, got %q", loc.Fragment)
+	}
+
+	// The user was provisioned from the ID token
+	rec = env.request("GET", "/api/v1/users/me", nil, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("profile: expected 200, got %d", rec.Code)
+	}
+	profile := parseJSON(t, rec)
+	if profile["email"] != "sso@t.com" || profile["name"] != "Sso User" {
+		t.Errorf("unexpected profile: %v", profile)
+	}
+
+	// Logging in again finds the same account
+	rec = env.ssoLogin(t, iss)
+	loc, _ = url.Parse(rec.Header().Get("Location"))
+	fragment, _ = url.ParseQuery(loc.Fragment)
+	if id := userIDFromProfile(t, env, fragment.Get("token")); id != profile["id"] {
+		t.Errorf("expected the same user, got %q", id)
+	}
+
+	// SSO accounts have no password
+	rec = env.request("POST", "/api/v1/auth/login", map[string]interface{}{"email": "sso@t.com", "password": "anything"}, "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("password login for SSO user: expected 401, got %d", rec.Code)
+	}
+}
+
+func TestOIDCLoginChallengesTwoFactor(t *testing.T) {
+	env := setupTestEnv(t)
+	iss := env.enableOIDC(t, true)
+	iss.SetIdentity(oidctest.Identity{Subject: "sso-2fa", Email: "sso2fa@t.com", EmailVerified: true})
+
+	loc, _ := url.Parse(env.ssoLogin(t, iss).Header().Get("Location"))
+	fragment, _ := url.ParseQuery(loc.Fragment)
+	token := fragment.Get("token")
+	rec := env.request("POST", "/api/v1/users/me/2fa/totp", nil, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("enroll: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	secret := parseJSON(t, rec)["secret"].(string)
+	now := time.Now()
+	rec = env.request("POST", "/api/v1/users/me/2fa/totp/confirm", map[string]interface{}{"code": totpCode(t, secret, now)}, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("confirm: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Signing in through the identity provider no longer skips the
+	// second factor.
+	rec = env.ssoLogin(t, iss)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("callback: expected 302, got %d: %s", rec.Code, rec.Body.String())
+	}
+	loc, _ = url.Parse(rec.Header().Get("Location"))
+	fragment, _ = url.ParseQuery(loc.Fragment)
+	if fragment.Get("token") != "" || fragment.Get("refreshToken") != "" {
+		t.Fatalf("expected no tokens before the second factor, got %q", loc.Fragment)
+	}
+	challenge := fragment.Get("challengeToken")
+	if challenge == "" || fragment.Get("enrollmentRequired") != "false" {
+		t.Fatalf("expected a challenge, got %q", loc.Fragment)
+	}
+
+	rec = env.request("POST", "/api/v1/auth/2fa", map[string]interface{}{
+		"challengeToken": challenge, "code": totpCode(t, secret, now.Add(30*time.Second)),
+	}, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("2fa: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if tok, _ := parseJSON(t, rec)["token"].(string); tok == "" {
+		t.Fatal("expected a token after the second step")
+	}
+}
+
+func TestOIDCCallbackRejectsForgedState(t *testing.T) {
+	env := setupTestEnv(t)
+	iss := env.enableOIDC(t, true)
+
+	rec := env.request("GET", "/api/v1/auth/oidc/login", nil, "")
+	back := iss.Authorize(t, rec.Header().Get("Location"))
+
+	// Without the login cookie the callback cannot be completed
+	if rec := env.request("GET", "/api/v1/auth/oidc/callback?"+back.RawQuery, nil, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("callback without cookie: expected 400, got %d", rec.Code)
+	}
+
+	// A cookie from another login attempt does not match the state
+	q := back.Query()
+	q.Set("state", "forged")
+	req := httptest.NewRequest("GET", "/api/v1/auth/oidc/callback?"+q.Encode(), nil)
+	req.AddCookie(rec.Result().Cookies()[0])
+	forged := httptest.NewRecorder()
+	env.echo.ServeHTTP(forged, req)
+	if forged.Code != http.StatusBadRequest {
+		t.Errorf("forged state: expected 400, got %d", forged.Code)
+	}
+}
+
+func TestOIDCWithoutProvisioning(t *testing.T) {
+	env := setupTestEnv(t)
+	iss := env.enableOIDC(t, false)
+
+	iss.SetIdentity(oidctest.Identity{Subject: "sso-2", Email: "nobody@t.com", EmailVerified: true})
+	if rec := env.ssoLogin(t, iss); rec.Code != http.StatusForbidden {
+		t.Errorf("unknown identity: expected 403, got %d", rec.Code)
+	}
+
+	// An account that never proved it owns the address is not linked
+	token := registerAndLogin(t, env, "linked@t.com")
+	iss.SetIdentity(oidctest.Identity{Subject: "sso-3", Email: "linked@t.com", EmailVerified: true})
+	if rec := env.ssoLogin(t, iss); rec.Code != http.StatusConflict {
+		t.Errorf("unproven account: expected 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Once the login email is verified as the receiving email it is
+	rec := env.request("PATCH", "/api/v1/users/me", map[string]interface{}{"receivingEmail": "linked@t.com"}, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	link, _ := url.Parse(env.mailer.verifications["linked@t.com"])
+	if rec := env.request("GET", link.RequestURI(), nil, ""); rec.Code != http.StatusOK {
+		t.Fatalf("verify: expected 200, got %d", rec.Code)
+	}
+	if rec := env.ssoLogin(t, iss); rec.Code != http.StatusFound {
+		t.Errorf("linked identity: expected 302, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestOIDCLoginWithoutPasswordLogin(t *testing.T) {
+	env := setupTestEnv(t, func(cfg *config.AuthConfig) { cfg.Require2FA = true })
+	iss := oidctest.NewIssuer(t)
+	iss.SetIdentity(oidctest.Identity{Subject: "sso-only", Email: "ssoonly@t.com", EmailVerified: true})
+
+	// Route through the real server so its route table is what is tested
+	cfg := config.Config{Auth: env.authCfg, Server: config.ServerConfig{RateLimit: 100}}
+	cfg.Auth.OIDC = config.OIDCConfig{
+		Enabled:              true,
+		IssuerURL:            iss.URL,
+		ClientID:             oidctest.ClientID,
+		RedirectURL:          "http://maildruid.test/api/v1/auth/oidc/callback",
+		AutoProvision:        true,
+		DisablePasswordLogin: true,
+	}
+	provider, err := oidc.New(context.Background(), cfg.Auth.OIDC)
+	if err != nil {
+		t.Fatalf("oidc: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	srv := New(cfg, env.db, env.userSvc, env.mailboxSvc, env.sessionSvc, env.tokenKeys, env.mfaSvc,
+		env.lockoutSvc, env.verifySvc, env.resetSvc, env.apiKeySvc, env.auditSvc, env.teamSvc, provider,
+		nil, env.summarySvc, env.sched, logger)
+	env.echo = srv.echo
+
+	if rec := env.request("POST", "/api/v1/auth/login", map[string]interface{}{"email": "ssoonly@t.com", "password": "secret123"}, ""); rec.Code == http.StatusOK {
+		t.Fatal("password login should be unavailable")
+	}
+
+	rec := env.ssoLogin(t, iss)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("callback: expected 302, got %d: %s", rec.Code, rec.Body.String())
+	}
+	loc, _ := url.Parse(rec.Header().Get("Location"))
+	fragment, _ := url.ParseQuery(loc.Fragment)
+	challenge := fragment.Get("challengeToken")
+	if challenge == "" || fragment.Get("enrollmentRequired") != "true" {
+		t.Fatalf("expected an enrollment challenge, got %q", loc.Fragment)
+	}
+
+	rec = env.request("POST", "/api/v1/auth/2fa/enroll", map[string]interface{}{"challengeToken": challenge}, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("enroll: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	secret := parseJSON(t, rec)["secret"].(string)
+	rec = env.request("POST", "/api/v1/auth/2fa", map[string]interface{}{
+		"challengeToken": challenge, "code": totpCode(t, secret, time.Now()),
+	}, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("2fa: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	token, _ := parseJSON(t, rec)["token"].(string)
+	if token == "" {
+		t.Fatal("expected a token after the second step")
+	}
+	if rec := env.request("GET", "/api/v1/users/me", nil, token); rec.Code != http.StatusOK {
+		t.Errorf("profile: expected 200, got %d", rec.Code)
+	}
+}
+
+// enableIMAPOAuth registers the mailbox linking routes against a mock
+// authorization server.
+func (te *testEnv) enableIMAPOAuth(t *testing.T) *oauthtest.Server {
+	t.Helper()
+	srv := oauthtest.NewServer(t)
+	cfg := config.Config{Auth: te.authCfg}
+	cfg.IMAP.OAuth2 = config.IMAPOAuth2Config{
+		RedirectURL: "http://maildruid.test/api/v1/imap/oauth2/callback",
+		Providers:   []config.OAuth2Provider{srv.Provider("fake")},
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	h := handlers.NewIMAPOAuthHandler(oauth.New(cfg.IMAP.OAuth2), te.mailboxSvc, te.teamSvc, cfg, logger)
+	te.echo.GET("/api/v1/imap/oauth2/callback", h.Callback)
+	auth := te.echo.Group("/api/v1", middleware.JWTAuth(te.tokenKeys, te.sessionSvc), middleware.AuditActor())
+	auth.GET("/imap/oauth2/providers", h.Providers)
+	auth.POST("/mailboxes/:id/oauth2/:provider", h.Authorize)
+	auth.DELETE("/mailboxes/:id/oauth2", h.Unlink)
+	auth.POST("/teams/:id/mailboxes/:mailboxId/oauth2/:provider", h.AuthorizeTeam)
+	auth.DELETE("/teams/:id/mailboxes/:mailboxId/oauth2", h.UnlinkTeam)
+	return srv
+}
+
+func TestIMAPOAuthLink(t *testing.T) {
+	env := setupTestEnv(t)
+	srv := env.enableIMAPOAuth(t)
+	token := registerAndLogin(t, env, "oauth@t.com")
+	mailboxID := firstMailboxID(t, env, token)
+	other := registerAndLogin(t, env, "other@t.com")
+
+	rec := env.request("GET", "/api/v1/imap/oauth2/providers", nil, token)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"fake"`) {
+		t.Fatalf("providers: got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := env.request("POST", "/api/v1/mailboxes/"+mailboxID+"/oauth2/other", nil, token); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown provider: expected 404, got %d", rec.Code)
+	}
+	if rec := env.request("POST", "/api/v1/mailboxes/"+mailboxID+"/oauth2/fake", nil, other); rec.Code != http.StatusNotFound {
+		t.Errorf("another user's mailbox: expected 404, got %d", rec.Code)
+	}
+
+	rec = env.request("POST", "/api/v1/mailboxes/"+mailboxID+"/oauth2/fake", nil, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("authorize: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatalf("expected an HttpOnly authorization cookie, got %v", cookies)
+	}
+	back := srv.Authorize(t, parseJSON(t, rec)["url"].(string))
+
+	// The callback only completes with the cookie of the same attempt
+	if rec := env.request("GET", "/api/v1/imap/oauth2/callback?"+back.RawQuery, nil, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("callback without cookie: expected 400, got %d", rec.Code)
+	}
+	req := httptest.NewRequest("GET", "/api/v1/imap/oauth2/callback?"+back.RawQuery, nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	env.echo.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("callback: expected 302, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	u, err := env.userSvc.GetByEmail(context.Background(), "oauth@t.com")
+	if err != nil {
+		t.Fatalf("GetByEmail: %v", err)
+	}
+	mb, err := env.mailboxSvc.Get(context.Background(), mailbox.UserOwner(u.ID), mailboxID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if mb.OAuthProvider != "fake" {
+		t.Fatalf("expected the mailbox to be linked, got %q", mb.OAuthProvider)
+	}
+	refresh, err := env.mailboxSvc.DecryptOAuthToken(context.Background(), mb)
+	if err != nil || refresh == "" {
+		t.Fatalf("DecryptOAuthToken = %q, %v", refresh, err)
+	}
+
+	rec = env.request("DELETE", "/api/v1/mailboxes/"+mailboxID+"/oauth2", nil, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unlink: expected 200, got %d", rec.Code)
+	}
+	if rec := env.request("DELETE", "/api/v1/mailboxes/"+mailboxID+"/oauth2", nil, token); rec.Code != http.StatusNotFound {
+		t.Errorf("unlink twice: expected 404, got %d", rec.Code)
+	}
+}
+
+func TestIMAPOAuthLinkTeamMailbox(t *testing.T) {
+	env := setupTestEnv(t)
+	srv := env.enableIMAPOAuth(t)
+	ownerToken := registerAndLogin(t, env, "owner@t.com")
+	viewerToken := registerAndLogin(t, env, "viewer@t.com")
+
+	rec := env.request("POST", "/api/v1/teams", map[string]interface{}{"name": "Support"}, ownerToken)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create team: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	teamID, _ := parseJSON(t, rec)["id"].(string)
+	base := "/api/v1/teams/" + teamID
+	if rec := env.request("POST", base+"/members", map[string]interface{}{"email": "viewer@t.com", "role": "viewer"}, ownerToken); rec.Code != http.StatusAccepted {
+		t.Fatalf("add member: expected 202, got %d", rec.Code)
+	}
+	// A mailbox signed in to with OAuth2 needs no password.
+	rec = env.request("POST", base+"/mailboxes", map[string]interface{}{
+		"name": "Shared", "username": "support@t.com", "domain": "imap.t.com", "port": 993, "folders": []string{"INBOX", "Support"},
+	}, ownerToken)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create mailbox: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	mailboxID, _ := parseJSON(t, rec)["id"].(string)
+
+	if rec := env.request("POST", base+"/mailboxes/"+mailboxID+"/oauth2/fake", nil, viewerToken); rec.Code != http.StatusForbidden {
+		t.Errorf("viewer authorize: expected 403, got %d", rec.Code)
+	}
+	if rec := env.request("POST", "/api/v1/mailboxes/"+mailboxID+"/oauth2/fake", nil, ownerToken); rec.Code != http.StatusNotFound {
+		t.Errorf("team mailbox as the user's own: expected 404, got %d", rec.Code)
+	}
+	rec = env.request("POST", base+"/mailboxes/"+mailboxID+"/oauth2/fake", nil, ownerToken)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("authorize: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	back := srv.Authorize(t, parseJSON(t, rec)["url"].(string))
+	req := httptest.NewRequest("GET", "/api/v1/imap/oauth2/callback?"+back.RawQuery, nil)
+	req.AddCookie(rec.Result().Cookies()[0])
+	rec = httptest.NewRecorder()
+	env.echo.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("callback: expected 302, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	mb, err := env.mailboxSvc.Get(context.Background(), mailbox.TeamOwner(teamID), mailboxID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if mb.OAuthProvider != "fake" || len(mb.Folders) != 2 {
+		t.Fatalf("expected a linked mailbox with two folders, got %+v", mb)
+	}
+
+	if rec := env.request("DELETE", base+"/mailboxes/"+mailboxID+"/oauth2", nil, viewerToken); rec.Code != http.StatusForbidden {
+		t.Errorf("viewer unlink: expected 403, got %d", rec.Code)
+	}
+	if rec := env.request("DELETE", base+"/mailboxes/"+mailboxID+"/oauth2", nil, ownerToken); rec.Code != http.StatusOK {
+		t.Fatalf("unlink: expected 200, got %d", rec.Code)
+	}
+}
+
+// totpCode computes the RFC 6238 code for a base32 secret at t, as an
+// authenticator app would.
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatalf("decoding TOTP secret: %v", err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(at.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1_000_000)
+}
+
+func loginChallenge(t *testing.T, env *testEnv, email string) map[string]interface{} {
+	t.Helper()
+	rec := env.request("POST", "/api/v1/auth/login", map[string]interface{}{"email": email, "password": "secret123"}, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	result := parseJSON(t, rec)
+	if _, ok := result["token"]; ok {
+		t.Fatal("login should not issue a token before the second factor")
+	}
+	if result["challengeToken"] == nil {
+		t.Fatalf("expected a challenge token, got %v", result)
+	}
+	return result
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	env := setupTestEnv(t)
+	token := registerAndLogin(t, env, "totp@t.com")
+
+	rec := env.request("POST", "/api/v1/users/me/2fa/totp", nil, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("enroll: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	enrollment := parseJSON(t, rec)
+	secret := enrollment["secret"].(string)
+	if uri, _ := enrollment["uri"].(string); !strings.HasPrefix(uri, "otpauth://totp/") {
+		t.Errorf("unexpected provisioning URI %q", uri)
+	}
+
+	now := time.Now()
+	rec = env.request("POST", "/api/v1/users/me/2fa/totp/confirm", map[string]interface{}{"code": totpCode(t, secret, now)}, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("confirm: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	recovery := parseJSON(t, rec)["recoveryCodes"].([]interface{})
+	if len(recovery) != 10 {
+		t.Fatalf("expected 10 recovery codes, got %d", len(recovery))
+	}
+	if status := parseJSON(t, env.request("GET", "/api/v1/users/me/2fa", nil, token)); status["enabled"] != true {
+		t.Errorf("expected 2FA enabled, got %v", status)
+	}
+
+	// Login now needs a second step
+	challenge := loginChallenge(t, env, "totp@t.com")["challengeToken"]
+	rec = env.request("POST", "/api/v1/auth/2fa", map[string]interface{}{"challengeToken": challenge, "code": "000000"}, "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong code: expected 401, got %d", rec.Code)
+	}
+	rec = env.request("POST", "/api/v1/auth/2fa", map[string]interface{}{"challengeToken": "bogus", "code": "000000"}, "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("bogus challenge: expected 401, got %d", rec.Code)
+	}
+	rec = env.request("POST", "/api/v1/auth/2fa", map[string]interface{}{
+		"challengeToken": challenge, "code": totpCode(t, secret, now.Add(30*time.Second)),
+	}, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("2fa: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if tok, _ := parseJSON(t, rec)["token"].(string); tok == "" {
+		t.Fatal("expected a token after the second step")
+	}
+
+	// A recovery code works once in place of a TOTP code
+	challenge = loginChallenge(t, env, "totp@t.com")["challengeToken"]
+	rec = env.request("POST", "/api/v1/auth/2fa", map[string]interface{}{"challengeToken": challenge, "code": recovery[0]}, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("recovery code: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = env.request("POST", "/api/v1/auth/2fa", map[string]interface{}{"challengeToken": challenge, "code": recovery[0]}, "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("reused recovery code: expected 401, got %d", rec.Code)
+	}
+
+	// Disabling restores single-step login
+	rec = env.request("DELETE", "/api/v1/users/me/2fa/totp", map[string]interface{}{"code": recovery[1]}, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("disable: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = env.request("POST", "/api/v1/auth/login", map[string]interface{}{"email": "totp@t.com", "password": "secret123"}, "")
+	if tok, _ := parseJSON(t, rec)["token"].(string); tok == "" {
+		t.Error("expected a token once 2FA is disabled")
+	}
+}
+
+func TestTwoFactorRequired(t *testing.T) {
+	env := setupTestEnv(t, func(cfg *config.AuthConfig) { cfg.Require2FA = true })
+
+	env.request("POST", "/api/v1/users", map[string]interface{}{
+		"name": "Req", "email": "req@t.com", "receivingEmail": "r@t.com",
+		"password": "secret123", "imapPassword": "imap-secret", "domain": "imap.t.com", "port": 993,
+	}, "")
+
+	result := loginChallenge(t, env, "req@t.com")
+	if result["enrollmentRequired"] != true {
+		t.Fatalf("expected enrollment to be required, got %v", result)
+	}
+	challenge := result["challengeToken"]
+
+	// Completing without enrolling first fails
+	rec := env.request("POST", "/api/v1/auth/2fa", map[string]interface{}{"challengeToken": challenge, "code": "123456"}, "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("complete before enroll: expected 400, got %d", rec.Code)
+	}
+
+	rec = env.request("POST", "/api/v1/auth/2fa/enroll", map[string]interface{}{"challengeToken": challenge}, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("enroll: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	secret := parseJSON(t, rec)["secret"].(string)
+
+	rec = env.request("POST", "/api/v1/auth/2fa", map[string]interface{}{
+		"challengeToken": challenge, "code": totpCode(t, secret, time.Now()),
+	}, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("2fa: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	result = parseJSON(t, rec)
+	token, _ := result["token"].(string)
+	if token == "" {
+		t.Fatal("expected a token after enrolling")
+	}
+	if codes, _ := result["recoveryCodes"].([]interface{}); len(codes) != 10 {
+		t.Errorf("expected recovery codes after enrolling, got %v", result["recoveryCodes"])
+	}
+
+	rec = env.request("DELETE", "/api/v1/users/me/2fa/totp", map[string]interface{}{"code": "000000"}, token)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("disable while required: expected 403, got %d", rec.Code)
+	}
+}
+
+func TestAdminAPI(t *testing.T) {
+	env := setupTestEnv(t)
+	adminToken := registerAndLogin(t, env, "admin@t.com")
+	userToken := registerAndLogin(t, env, "someone@t.com")
+	adminID := userIDFromProfile(t, env, adminToken)
+	userID := userIDFromProfile(t, env, userToken)
+
+	// Only admins reach the admin API
+	if rec := env.request("GET", "/api/v1/admin/users", nil, adminToken); rec.Code != http.StatusForbidden {
+		t.Fatalf("before promotion: expected 403, got %d", rec.Code)
+	}
+	if _, err := env.userSvc.SetRoleByEmail(context.Background(), "admin@t.com", user.RoleAdmin); err != nil {
+		t.Fatalf("SetRoleByEmail: %v", err)
+	}
+	if rec := env.request("GET", "/api/v1/admin/users", nil, userToken); rec.Code != http.StatusForbidden {
+		t.Errorf("non-admin: expected 403, got %d", rec.Code)
+	}
+
+	// Search
+	rec := env.request("GET", "/api/v1/admin/users?q=SOMEONE", nil, adminToken)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("search: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	list := parseJSON(t, rec)
+	items, _ := list["items"].([]interface{})
+	if list["total"] != float64(1) || len(items) != 1 {
+		t.Fatalf("search: expected one match, got %v", list)
+	}
+	if u := items[0].(map[string]interface{}); u["id"] != userID || u["role"] != "user" {
+		t.Errorf("search: unexpected user %v", u)
+	}
+	if list := parseJSON(t, env.request("GET", "/api/v1/admin/users", nil, adminToken)); list["total"] != float64(2) {
+		t.Errorf("list: expected 2 users, got %v", list["total"])
+	}
+
+	// Schedules are scoped to the caller unless they are an admin
+	if rec := env.request("POST", "/api/v1/schedules", map[string]interface{}{"interval": "60"}, userToken); rec.Code != http.StatusCreated {
+		t.Fatalf("schedule: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := env.request("POST", "/api/v1/schedules", map[string]interface{}{"interval": "60"}, adminToken); rec.Code != http.StatusCreated {
+		t.Fatalf("schedule: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var tasks []scheduler.TaskInfo
+	rec = env.request("GET", "/api/v1/schedules", nil, userToken)
+	if err := json.Unmarshal(rec.Body.Bytes(), &tasks); err != nil {
+		t.Fatalf("list schedules: %v: %s", err, rec.Body.String())
+	}
+	if len(tasks) != 1 || len(tasks[0].UserIDs) != 1 || tasks[0].UserIDs[0] != userID {
+		t.Errorf("user should only see their own task, got %+v", tasks)
+	}
+	rec = env.request("GET", "/api/v1/schedules", nil, adminToken)
+	if err := json.Unmarshal(rec.Body.Bytes(), &tasks); err != nil {
+		t.Fatalf("list schedules: %v", err)
+	}
+	if len(tasks) != 1 || len(tasks[0].UserIDs) != 2 {
+		t.Errorf("admin should see every task, got %+v", tasks)
+	}
+
+	// Admins cannot lock themselves out
+	if rec := env.request("POST", "/api/v1/admin/users/"+adminID+"/disable", nil, adminToken); rec.Code != http.StatusBadRequest {
+		t.Errorf("self-disable: expected 400, got %d", rec.Code)
+	}
+
+	rec = env.request("POST", "/api/v1/users/me/api-keys", map[string]interface{}{"name": "cron"}, userToken)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create key: expected 201, got %d", rec.Code)
+	}
+	apiKey, _ := parseJSON(t, rec)["key"].(string)
+
+	// Disabling signs the user out and blocks login, API keys and runs
+	if rec := env.request("POST", "/api/v1/admin/users/"+userID+"/disable", nil, adminToken); rec.Code != http.StatusOK {
+		t.Fatalf("disable: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := env.request("GET", "/api/v1/users/me", nil, userToken); rec.Code != http.StatusUnauthorized {
+		t.Errorf("disabled user's token: expected 401, got %d", rec.Code)
+	}
+	if rec := env.request("GET", "/api/v1/users/me", nil, apiKey); rec.Code != http.StatusUnauthorized {
+		t.Errorf("disabled user's API key: expected 401, got %d", rec.Code)
+	}
+	rec = env.request("POST", "/api/v1/auth/login", map[string]interface{}{"email": "someone@t.com", "password": "secret123"}, "")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("disabled login: expected 403, got %d", rec.Code)
+	}
+	if rec := env.request("POST", "/api/v1/admin/users/"+userID+"/schedule/run", nil, adminToken); rec.Code != http.StatusConflict {
+		t.Errorf("run for disabled user: expected 409, got %d", rec.Code)
+	}
+
+	if rec := env.request("POST", "/api/v1/admin/users/"+userID+"/enable", nil, adminToken); rec.Code != http.StatusOK {
+		t.Fatalf("enable: expected 200, got %d", rec.Code)
+	}
+	rec = env.request("POST", "/api/v1/auth/login", map[string]interface{}{"email": "someone@t.com", "password": "secret123"}, "")
+	if rec.Code != http.StatusOK {
+		t.Errorf("re-enabled login: expected 200, got %d", rec.Code)
+	}
+
+	// Schedule status and cancellation
+	status := parseJSON(t, env.request("GET", "/api/v1/admin/users/"+userID+"/schedule", nil, adminToken))
+	if status["interval"] != "60" || status["lastRun"] != nil {
+		t.Errorf("schedule status: unexpected %v", status)
+	}
+	if rec := env.request("DELETE", "/api/v1/admin/users/"+userID+"/schedule", nil, adminToken); rec.Code != http.StatusOK {
+		t.Fatalf("cancel: expected 200, got %d", rec.Code)
+	}
+	if status := parseJSON(t, env.request("GET", "/api/v1/admin/users/"+userID+"/schedule", nil, adminToken)); status["interval"] != "0" {
+		t.Errorf("cancel: expected interval cleared, got %v", status["interval"])
+	}
+
+	// Delete
+	if rec := env.request("DELETE", "/api/v1/admin/users/"+userID, nil, adminToken); rec.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d", rec.Code)
+	}
+	if rec := env.request("GET", "/api/v1/admin/users/"+userID, nil, adminToken); rec.Code != http.StatusNotFound {
+		t.Errorf("deleted user: expected 404, got %d", rec.Code)
+	}
+	if rec := env.request("POST", "/api/v1/admin/users/"+userID+"/schedule/run", nil, adminToken); rec.Code != http.StatusNotFound {
+		t.Errorf("run for deleted user: expected 404, got %d", rec.Code)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	env := setupTestEnv(t, func(c *config.AuthConfig) {
+		c.Lockout = config.LockoutConfig{
+			Enabled:          true,
+			AccountThreshold: 3,
+			IPThreshold:      100,
+			Window:           15 * time.Minute,
+			Duration:         15 * time.Minute,
+			FreeAttempts:     5,
+			MaxDelay:         30 * time.Second,
+		}
+	})
+	registerAndLogin(t, env, "locked@test.com")
+
+	login := func(password string) *httptest.ResponseRecorder {
+		return env.request("POST", "/api/v1/auth/login", map[string]interface{}{
+			"email": "locked@test.com", "password": password,
+		}, "")
+	}
+
+	for i := 0; i < 3; i++ {
+		if rec := login("wrong-password"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d: %s", i+1, rec.Code, rec.Body.String())
+		}
+	}
+
+	// Even the right password is refused while locked.
+	rec := login("secret123")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 when locked, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Retry-After") != "900" {
+		t.Errorf("expected Retry-After 900, got %q", rec.Header().Get("Retry-After"))
+	}
+
+	env.lockoutSvc.Wait()
+	if len(env.mailer.unlocks) != 1 {
+		t.Fatalf("expected one unlock email, got %d", len(env.mailer.unlocks))
+	}
+	link, err := url.Parse(env.mailer.unlocks[0])
+	if err != nil {
+		t.Fatalf("bad unlock link: %v", err)
+	}
+	if link.Path != "/unlock" {
+		t.Errorf("expected the link to open the unlock page, got %q", link.Path)
+	}
+
+	// Fetching the link, as a mail scanner would, only loads the page
+	if rec := env.request("GET", link.RequestURI(), nil, ""); rec.Code != http.StatusOK {
+		t.Fatalf("unlock page: expected 200, got %d", rec.Code)
+	}
+	if rec := login("secret123"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the account to stay locked after fetching the link, got %d", rec.Code)
+	}
+
+	unlock := func(token string) int {
+		return env.request("POST", "/api/v1/auth/unlock", map[string]interface{}{"token": token}, "").Code
+	}
+	if code := unlock("bogus"); code != http.StatusBadRequest {
+		t.Errorf("bogus token: expected 400, got %d", code)
+	}
+	if code := unlock(link.Query().Get("token")); code != http.StatusOK {
+		t.Fatalf("unlock: expected 200, got %d", code)
+	}
+	if rec := login("secret123"); rec.Code != http.StatusOK {
+		t.Fatalf("login after unlock: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if code := unlock(link.Query().Get("token")); code != http.StatusBadRequest {
+		t.Errorf("reused unlock link: expected 400, got %d", code)
+	}
+}
+
+func TestReceivingEmailVerification(t *testing.T) {
+	env := setupTestEnv(t)
+	token := registerAndLogin(t, env, "verify@test.com")
+
+	profile := func() map[string]interface{} {
+		t.Helper()
+		rec := env.request("GET", "/api/v1/users/me", nil, token)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("profile: expected 200, got %d", rec.Code)
+		}
+		return parseJSON(t, rec)
+	}
+	verify := func(link string) int {
+		t.Helper()
+		u, err := url.Parse(link)
+		if err != nil {
+			t.Fatalf("bad verification link: %v", err)
+		}
+		return env.request("GET", u.RequestURI(), nil, "").Code
+	}
+
+	if profile()["receivingEmailVerified"] != false {
+		t.Fatal("expected new receiving email to be unverified")
+	}
+	link, ok := env.mailer.verifications["r@t.com"]
+	if !ok {
+		t.Fatal("expected a verification email to the receiving address on registration")
+	}
+
+	if rec := env.request("POST", "/api/v1/users/me/verify-email", nil, token); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("immediate resend: expected 429, got %d", rec.Code)
+	}
+	if rec := env.request("GET", "/api/v1/verify-email?token=bogus", nil, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("bogus token: expected 400, got %d", rec.Code)
+	}
+	if code := verify(link); code != http.StatusOK {
+		t.Fatalf("verify: expected 200, got %d", code)
+	}
+	if profile()["receivingEmailVerified"] != true {
+		t.Fatal("expected receiving email to be verified")
+	}
+	if code := verify(link); code != http.StatusBadRequest {
+		t.Errorf("reused link: expected 400, got %d", code)
+	}
+	if rec := env.request("POST", "/api/v1/users/me/verify-email", nil, token); rec.Code != http.StatusConflict {
+		t.Errorf("resend when verified: expected 409, got %d", rec.Code)
+	}
+
+	// Changing the address starts over.
+	rec := env.request("PATCH", "/api/v1/users/me", map[string]interface{}{"receivingEmail": "new@t.com"}, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if profile()["receivingEmailVerified"] != false {
+		t.Error("expected changed receiving email to be unverified")
+	}
+	link, ok = env.mailer.verifications["new@t.com"]
+	if !ok {
+		t.Fatal("expected a verification email to the new address")
+	}
+	if code := verify(link); code != http.StatusOK {
+		t.Fatalf("verify new address: expected 200, got %d", code)
+	}
+	if profile()["receivingEmailVerified"] != true {
+		t.Error("expected new receiving email to be verified")
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	env := setupTestEnv(t)
+	oldToken := registerAndLogin(t, env, "forgot@test.com")
+
+	// Known and unknown emails get the same answer.
+	known := env.request("POST", "/api/v1/auth/forgot-password", map[string]interface{}{"email": "forgot@test.com"}, "")
+	unknown := env.request("POST", "/api/v1/auth/forgot-password", map[string]interface{}{"email": "nobody@test.com"}, "")
+	if known.Code != http.StatusAccepted || unknown.Code != known.Code || unknown.Body.String() != known.Body.String() {
+		t.Fatalf("expected identical 202 responses, got %d %s and %d %s",
+			known.Code, known.Body.String(), unknown.Code, unknown.Body.String())
+	}
+	env.resetSvc.Wait()
+	if _, ok := env.mailer.resets["nobody@test.com"]; ok {
+		t.Error("expected no email for an unknown account")
+	}
+	link, ok := env.mailer.resets["forgot@test.com"]
+	if !ok {
+		t.Fatal("expected a reset email")
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("bad reset link: %v", err)
+	}
+	resetToken := u.Query().Get("token")
+
+	reset := func(token, password string) int {
+		return env.request("POST", "/api/v1/auth/reset-password", map[string]interface{}{
+			"token": token, "password": password,
+		}, "").Code
+	}
+	if code := reset(resetToken, "short"); code != http.StatusBadRequest {
+		t.Errorf("short password: expected 400, got %d", code)
+	}
+	if code := reset("bogus", "brand-new-password"); code != http.StatusBadRequest {
+		t.Errorf("bogus token: expected 400, got %d", code)
+	}
+	if code := reset(resetToken, "brand-new-password"); code != http.StatusOK {
+		t.Fatalf("reset: expected 200, got %d", code)
+	}
+	if code := reset(resetToken, "another-password"); code != http.StatusBadRequest {
+		t.Errorf("reused token: expected 400, got %d", code)
+	}
+
+	if rec := env.request("GET", "/api/v1/users/me", nil, oldToken); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected old sessions to be revoked, got %d", rec.Code)
+	}
+	rec := env.request("POST", "/api/v1/auth/login", map[string]interface{}{
+		"email": "forgot@test.com", "password": "brand-new-password",
+	}, "")
+	if rec.Code != http.StatusOK {
+		t.Errorf("login with new password: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	env := setupTestEnv(t)
+	token := registerAndLogin(t, env, "audited@t.com")
+	adminToken := registerAndLogin(t, env, "auditor@t.com")
+	userID := userIDFromProfile(t, env, token)
+	adminID := userIDFromProfile(t, env, adminToken)
+
+	rec := env.request("PATCH", "/api/v1/mailboxes/"+firstMailboxID(t, env, token), map[string]interface{}{
+		"domain": "imap.new.com", "password": "new-imap-secret",
+	}, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	requestID := rec.Header().Get(echo.HeaderXRequestID)
+
+	rec = env.request("GET", "/api/v1/users/me/audit?action=mailbox.updated", nil, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "new-imap-secret") || strings.Contains(rec.Body.String(), "imap-secret") {
+		t.Fatalf("audit log leaked a secret: %s", rec.Body.String())
+	}
+	var list struct {
+		Items []audit.Event `json:"items"`
+		Total int64         `json:"total"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decoding audit log: %v", err)
+	}
+	if list.Total != 1 || len(list.Items) != 1 {
+		t.Fatalf("expected one update event, got %+v", list)
+	}
+	e := list.Items[0]
+	if e.UserID != userID || e.ActorID != userID || e.IP == "" || e.RequestID == "" || e.RequestID != requestID {
+		t.Errorf("unexpected event source %+v", e)
+	}
+	if !e.Changes.Has("domain") || !e.Changes.Has("password") {
+		t.Errorf("expected domain and password changes, got %+v", e.Changes)
+	}
+	for _, c := range e.Changes {
+		if c.Field == "password" && (c.Before != audit.Redacted || c.After != audit.Redacted) {
+			t.Errorf("expected redacted IMAP password, got %+v", c)
+		}
+	}
+
+	// Logins are recorded, and the user only sees their own events.
+	list.Items = nil
+	rec = env.request("GET", "/api/v1/users/me/audit?action=login.succeeded", nil, token)
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decoding audit log: %v", err)
+	}
+	if list.Total != 1 || list.Items[0].UserID != userID || list.Items[0].Detail != "password" {
+		t.Errorf("expected one password login, got %+v", list)
+	}
+
+	// The admin-wide query is for admins only.
+	if rec := env.request("GET", "/api/v1/admin/audit", nil, token); rec.Code != http.StatusForbidden {
+		t.Errorf("non-admin: expected 403, got %d", rec.Code)
+	}
+	if _, err := env.userSvc.SetRoleByEmail(context.Background(), "auditor@t.com", user.RoleAdmin); err != nil {
+		t.Fatalf("SetRoleByEmail: %v", err)
+	}
+	if rec := env.request("POST", "/api/v1/admin/users/"+userID+"/disable", nil, adminToken); rec.Code != http.StatusOK {
+		t.Fatalf("disable: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = env.request("GET", "/api/v1/admin/audit?actorId="+adminID+"&action=user.disabled", nil, adminToken)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("admin list: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	list.Items = nil
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decoding audit log: %v", err)
+	}
+	if list.Total != 1 || list.Items[0].UserID != userID || list.Items[0].ActorID != adminID {
+		t.Errorf("expected the admin to be recorded as actor, got %+v", list)
+	}
+
+	since := url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339))
+	rec = env.request("GET", "/api/v1/admin/audit?since="+since, nil, adminToken)
+	if list := parseJSON(t, rec); rec.Code != http.StatusOK || list["total"] != float64(0) {
+		t.Errorf("future since: expected no events, got %d %v", rec.Code, list)
+	}
+	if rec := env.request("GET", "/api/v1/admin/audit?since=yesterday", nil, adminToken); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad since: expected 400, got %d", rec.Code)
+	}
+}
+
+func TestDeleteLastTeamOwner(t *testing.T) {
+	env := setupTestEnv(t)
+	ownerToken := registerAndLogin(t, env, "soleowner@t.com")
+	memberToken := registerAndLogin(t, env, "member@t.com")
+	adminToken := registerAndLogin(t, env, "admin@t.com")
+	if _, err := env.userSvc.SetRoleByEmail(context.Background(), "admin@t.com", user.RoleAdmin); err != nil {
+		t.Fatalf("SetRoleByEmail: %v", err)
+	}
+	ownerID := userIDFromProfile(t, env, ownerToken)
+	memberID := userIDFromProfile(t, env, memberToken)
+
+	rec := env.request("POST", "/api/v1/teams", map[string]interface{}{"name": "Support"}, ownerToken)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create team: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	base := "/api/v1/teams/" + parseJSON(t, rec)["id"].(string)
+	env.request("POST", base+"/members", map[string]interface{}{"email": "member@t.com", "role": "editor"}, ownerToken)
+
+	// Neither the owner nor an admin can leave the team without an owner
+	if rec := env.request("DELETE", "/api/v1/users/me", nil, ownerToken); rec.Code != http.StatusConflict {
+		t.Errorf("delete last owner: expected 409, got %d", rec.Code)
+	}
+	if rec := env.request("DELETE", "/api/v1/admin/users/"+ownerID, nil, adminToken); rec.Code != http.StatusConflict {
+		t.Errorf("admin delete last owner: expected 409, got %d", rec.Code)
+	}
+
+	if rec := env.request("PATCH", base+"/members/"+memberID, map[string]interface{}{"role": "owner"}, ownerToken); rec.Code != http.StatusOK {
+		t.Fatalf("promote: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := env.request("DELETE", "/api/v1/users/me", nil, ownerToken); rec.Code != http.StatusOK {
+		t.Fatalf("delete with another owner: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := env.request("GET", base, nil, memberToken); rec.Code != http.StatusOK {
+		t.Errorf("team after owner left: expected 200, got %d", rec.Code)
+	}
+}
+
+func TestTeams(t *testing.T) {
+	env := setupTestEnv(t)
+	ownerToken := registerAndLogin(t, env, "owner@t.com")
+	viewerToken := registerAndLogin(t, env, "viewer@t.com")
+	outsiderToken := registerAndLogin(t, env, "outsider@t.com")
+	viewerID := userIDFromProfile(t, env, viewerToken)
+
+	rec := env.request("POST", "/api/v1/teams", map[string]interface{}{"name": "Support"}, ownerToken)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create team: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	teamID, _ := parseJSON(t, rec)["id"].(string)
+	base := "/api/v1/teams/" + teamID
+
+	// Known and unknown emails get the same answer.
+	known := env.request("POST", base+"/members", map[string]interface{}{"email": "viewer@t.com", "role": "viewer"}, ownerToken)
+	unknown := env.request("POST", base+"/members", map[string]interface{}{"email": "nobody@t.com", "role": "viewer"}, ownerToken)
+	if known.Code != http.StatusAccepted || unknown.Code != known.Code || unknown.Body.String() != known.Body.String() {
+		t.Fatalf("add member: expected identical 202 responses, got %d %s and %d %s",
+			known.Code, known.Body.String(), unknown.Code, unknown.Body.String())
+	}
+	rec = env.request("GET", base, nil, viewerToken)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("member get team: expected 200, got %d", rec.Code)
+	}
+	if deliver, _ := parseJSON(t, rec)["deliver"].(bool); deliver {
+		t.Error("expected new members to opt in to delivery")
+	}
+
+	// Non-members cannot tell the team exists.
+	for _, path := range []string{base, base + "/members", base + "/mailboxes", base + "/digests"} {
+		if rec := env.request("GET", path, nil, outsiderToken); rec.Code != http.StatusNotFound {
+			t.Errorf("outsider GET %s: expected 404, got %d", path, rec.Code)
+		}
+	}
+
+	mailbox := map[string]interface{}{
+		"name": "Shared", "username": "support@t.com", "password": "shared-imap-secret",
+		"domain": "imap.t.com", "port": 993,
+	}
+	if rec := env.request("POST", base+"/mailboxes", mailbox, viewerToken); rec.Code != http.StatusForbidden {
+		t.Errorf("viewer create mailbox: expected 403, got %d", rec.Code)
+	}
+	rec = env.request("POST", base+"/mailboxes", mailbox, ownerToken)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create mailbox: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "shared-imap-secret") {
+		t.Fatalf("mailbox response leaked the credential: %s", rec.Body.String())
+	}
+	mailboxID, _ := parseJSON(t, rec)["id"].(string)
+	if rec := env.request("GET", "/api/v1/mailboxes", nil, ownerToken); strings.Contains(rec.Body.String(), mailboxID) {
+		t.Errorf("team mailboxes must not be listed with the user's own: %s", rec.Body.String())
+	}
+
+	rec = env.request("POST", base+"/digests", map[string]interface{}{
+		"name": "Escalations", "mailboxId": mailboxID, "tags": []string{"urgent"}, "updateInterval": "60",
+	}, ownerToken)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create digest: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	digestID, _ := parseJSON(t, rec)["id"].(string)
+
+	rec = env.request("GET", base+"/digests", nil, viewerToken)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), digestID) {
+		t.Errorf("viewer list digests: expected 200 with the digest, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := env.request("PATCH", base+"/digests/"+digestID, map[string]interface{}{"name": "x"}, viewerToken); rec.Code != http.StatusForbidden {
+		t.Errorf("viewer update digest: expected 403, got %d", rec.Code)
+	}
+	if rec := env.request("PATCH", base+"/digests/"+digestID, map[string]interface{}{"updateInterval": "often"}, ownerToken); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid interval: expected 400, got %d", rec.Code)
+	}
+	rec = env.request("GET", base+"/digests/"+digestID+"/history", nil, viewerToken)
+	if rec.Code != http.StatusOK {
+		t.Errorf("viewer history: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := env.request("GET", base+"/digests/"+digestID+"/history", nil, outsiderToken); rec.Code != http.StatusNotFound {
+		t.Errorf("outsider history: expected 404, got %d", rec.Code)
+	}
+	if rec := env.request("DELETE", base+"/mailboxes/"+mailboxID, nil, ownerToken); rec.Code != http.StatusConflict {
+		t.Errorf("delete mailbox in use: expected 409, got %d", rec.Code)
+	}
+
+	rec = env.request("PUT", base+"/members/me/delivery", map[string]interface{}{"deliver": true}, viewerToken)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delivery: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if deliver, _ := parseJSON(t, rec)["deliver"].(bool); !deliver {
+		t.Error("expected delivery to be on")
+	}
+
+	if rec := env.request("PATCH", base+"/members/"+viewerID, map[string]interface{}{"role": "editor"}, viewerToken); rec.Code != http.StatusForbidden {
+		t.Errorf("viewer promotes self: expected 403, got %d", rec.Code)
+	}
+	ownerID := userIDFromProfile(t, env, ownerToken)
+	if rec := env.request("DELETE", base+"/members/"+ownerID, nil, ownerToken); rec.Code != http.StatusConflict {
+		t.Errorf("last owner leaves: expected 409, got %d", rec.Code)
+	}
+
+	if rec := env.request("GET", base+"/audit", nil, viewerToken); rec.Code != http.StatusForbidden {
+		t.Errorf("viewer team audit: expected 403, got %d", rec.Code)
+	}
+	rec = env.request("GET", base+"/audit?action=team.mailbox_created", nil, ownerToken)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("team audit: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "shared-imap-secret") {
+		t.Fatalf("team audit leaked the credential: %s", rec.Body.String())
+	}
+	if total, _ := parseJSON(t, rec)["total"].(float64); total != 1 {
+		t.Errorf("expected one mailbox event, got %v", total)
+	}
+
+	if rec := env.request("DELETE", base, nil, viewerToken); rec.Code != http.StatusForbidden {
+		t.Errorf("viewer delete team: expected 403, got %d", rec.Code)
+	}
+	if rec := env.request("DELETE", base, nil, ownerToken); rec.Code != http.StatusOK {
+		t.Fatalf("delete team: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = env.request("GET", "/api/v1/teams", nil, viewerToken)
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("teams after delete: expected [], got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/labstack/echo/v4"
+)
+
+// AuditSource returns middleware that puts the client IP and request ID
+// into the request context, so audit events recorded while handling the
+// request are attributed to it. It must run after the request ID
+// middleware.
+func AuditSource() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx := audit.WithSource(req.Context(), audit.Source{
+				IP:        c.RealIP(),
+				RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+			})
+			c.SetRequest(req.WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+// AuditActor returns middleware that names the authenticated user as the
+// actor of audit events recorded while handling the request.
+func AuditActor() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			c.SetRequest(req.WithContext(audit.WithActor(req.Context(), GetUserID(c))))
+			return next(c)
+		}
+	}
+}
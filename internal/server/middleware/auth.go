@@ -1,40 +1,51 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/gofrs/uuid"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 )
 
-// Claims holds JWT token claims.
+// Claims holds JWT token claims. The registered ID claim (jti) identifies
+// the token for revocation.
 type Claims struct {
-	UserID string `json:"uid"`
+	UserID     string `json:"uid"`
+	Generation int    `json:"gen"`
 	jwt.RegisteredClaims
 }
 
-// JWTAuth returns middleware that validates JWT tokens.
-func JWTAuth(signingKey []byte) echo.MiddlewareFunc {
+// RevocationChecker reports whether a token issued to userID for the given
+// token generation and token ID has been revoked.
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, userID string, generation int, tokenID string) (bool, error)
+}
+
+// TokenKeys signs access tokens and supplies the keys that verify them.
+type TokenKeys interface {
+	Sign(claims jwt.Claims) (string, error)
+	Keyfunc(t *jwt.Token) (interface{}, error)
+	Methods() []string
+}
+
+// JWTAuth returns middleware that validates JWT tokens. When revocations is
+// non-nil, tokens it reports as revoked are rejected.
+func JWTAuth(keys TokenKeys, revocations RevocationChecker) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			auth := c.Request().Header.Get("Authorization")
-			if auth == "" {
-				return echo.NewHTTPError(http.StatusUnauthorized, "missing authorization header")
+			raw, err := bearerToken(c)
+			if err != nil {
+				return err
 			}
 
-			parts := strings.SplitN(auth, " ", 2)
-			if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
-				return echo.NewHTTPError(http.StatusUnauthorized, "invalid authorization format")
-			}
-
-			token, err := jwt.ParseWithClaims(parts[1], &Claims{}, func(t *jwt.Token) (interface{}, error) {
-				if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, echo.NewHTTPError(http.StatusUnauthorized, "unexpected signing method")
-				}
-				return signingKey, nil
-			})
+			token, err := jwt.ParseWithClaims(raw, &Claims{}, keys.Keyfunc, jwt.WithValidMethods(keys.Methods()))
 
 			if err != nil || !token.Valid {
 				return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired token")
@@ -45,24 +56,155 @@ func JWTAuth(signingKey []byte) echo.MiddlewareFunc {
 				return echo.NewHTTPError(http.StatusUnauthorized, "invalid token claims")
 			}
 
+			if revocations != nil {
+				revoked, err := revocations.IsRevoked(c.Request().Context(), claims.UserID, claims.Generation, claims.ID)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusInternalServerError, "could not verify token")
+				}
+				if revoked {
+					return echo.NewHTTPError(http.StatusUnauthorized, "token has been revoked")
+				}
+			}
+
 			c.Set("user_id", claims.UserID)
+			c.Set("token_claims", claims)
+			return next(c)
+		}
+	}
+}
+
+// ErrInvalidAPIKey is returned by an APIKeyAuthFunc for unknown or expired
+// keys.
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// APIKeyAuthFunc resolves a personal API key presented from ip to its
+// owner and scopes.
+type APIKeyAuthFunc func(ctx context.Context, key, ip string) (userID string, scopes []string, err error)
+
+// WithAPIKeys authenticates bearer tokens starting with prefix as API keys
+// and hands every other request to jwtAuth.
+func WithAPIKeys(prefix string, authenticate APIKeyAuthFunc, jwtAuth echo.MiddlewareFunc) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		viaJWT := jwtAuth(next)
+		return func(c echo.Context) error {
+			raw, err := bearerToken(c)
+			if err != nil || !strings.HasPrefix(raw, prefix) {
+				return viaJWT(c)
+			}
+
+			userID, scopes, err := authenticate(c.Request().Context(), raw, c.RealIP())
+			if errors.Is(err, ErrInvalidAPIKey) {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired api key")
+			}
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "could not verify api key")
+			}
+
+			c.Set("user_id", userID)
+			c.Set("api_key_scopes", scopes)
+			return next(c)
+		}
+	}
+}
+
+// RequireScopes limits what scoped API keys can reach. routes maps
+// "METHOD /route/path" to the scope that grants access; a scoped key is
+// refused on any route it has no matching scope for. Unscoped keys and
+// session tokens are not affected.
+func RequireScopes(routes map[string]string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			scopes, ok := c.Get("api_key_scopes").([]string)
+			if !ok || len(scopes) == 0 {
+				return next(c)
+			}
+			required, listed := routes[c.Request().Method+" "+c.Path()]
+			if !listed || !slices.Contains(scopes, required) {
+				return echo.NewHTTPError(http.StatusForbidden, "api key does not have the required scope")
+			}
+			return next(c)
+		}
+	}
+}
+
+// SessionOnly rejects requests authenticated with an API key, for endpoints
+// such as key management that need an interactive login.
+func SessionOnly() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if IsAPIKey(c) {
+				return echo.NewHTTPError(http.StatusForbidden, "not available to api keys")
+			}
+			return next(c)
+		}
+	}
+}
+
+// AdminCheckFunc reports whether a user holds the admin role.
+type AdminCheckFunc func(ctx context.Context, userID string) (bool, error)
+
+// RequireAdmin rejects requests from users that are not administrators.
+func RequireAdmin(isAdmin AdminCheckFunc) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ok, err := isAdmin(c.Request().Context(), GetUserID(c))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "could not verify role")
+			}
+			if !ok {
+				return echo.NewHTTPError(http.StatusForbidden, "admin role required")
+			}
 			return next(c)
 		}
 	}
 }
 
-// GenerateToken creates a signed JWT token for the given user ID.
-func GenerateToken(userID string, signingKey []byte, expiry time.Duration) (string, error) {
+// IsAPIKey reports whether the request was authenticated with an API key.
+func IsAPIKey(c echo.Context) bool {
+	_, ok := c.Get("api_key_scopes").([]string)
+	return ok
+}
+
+// bearerToken extracts the token from the Authorization header.
+func bearerToken(c echo.Context) (string, error) {
+	auth := c.Request().Header.Get("Authorization")
+	if auth == "" {
+		return "", echo.NewHTTPError(http.StatusUnauthorized, "missing authorization header")
+	}
+
+	parts := strings.SplitN(auth, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+		return "", echo.NewHTTPError(http.StatusUnauthorized, "invalid authorization format")
+	}
+	return parts[1], nil
+}
+
+// GenerateToken creates a signed JWT access token for the given user ID and
+// token generation.
+func GenerateToken(userID string, generation int, keys TokenKeys, expiry time.Duration) (string, error) {
+	jti, err := uuid.NewV4()
+	if err != nil {
+		return "", fmt.Errorf("generating token ID: %w", err)
+	}
+
 	claims := &Claims{
-		UserID: userID,
+		UserID:     userID,
+		Generation: generation,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti.String(),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(signingKey)
+	return keys.Sign(claims)
+}
+
+// GetClaims returns the validated token claims from the echo context, or nil
+// outside JWTAuth.
+func GetClaims(c echo.Context) *Claims {
+	claims, _ := c.Get("token_claims").(*Claims)
+	return claims
 }
 
 // GetUserID extracts the user ID from the echo context.
@@ -1,18 +1,21 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/akhil-datla/maildruid/internal/infrastructure/signing"
 	"github.com/labstack/echo/v4"
 )
 
 func TestGenerateAndValidateToken(t *testing.T) {
-	key := []byte("test-signing-key-for-jwt")
+	key := signing.NewHMAC([]byte("test-signing-key-for-jwt"))
 
-	token, err := GenerateToken("user-123", key, 1*time.Hour)
+	token, err := GenerateToken("user-123", 0, key, 1*time.Hour)
 	if err != nil {
 		t.Fatalf("GenerateToken error: %v", err)
 	}
@@ -22,7 +25,7 @@ func TestGenerateAndValidateToken(t *testing.T) {
 
 	// Validate via middleware
 	e := echo.New()
-	handler := JWTAuth(key)(func(c echo.Context) error {
+	handler := JWTAuth(key, nil)(func(c echo.Context) error {
 		id := GetUserID(c)
 		if id != "user-123" {
 			t.Errorf("expected user_id 'user-123', got %q", id)
@@ -43,11 +46,59 @@ func TestGenerateAndValidateToken(t *testing.T) {
 	}
 }
 
+func TestJWTAuthWithSigningKeys(t *testing.T) {
+	newKeySet := func(id string) *signing.KeySet {
+		pemKey, err := signing.GenerateKey("EdDSA")
+		if err != nil {
+			t.Fatalf("GenerateKey: %v", err)
+		}
+		priv, err := signing.ParsePrivateKey(pemKey)
+		if err != nil {
+			t.Fatalf("ParsePrivateKey: %v", err)
+		}
+		keys, err := signing.NewKeySet([]signing.Key{{ID: id, Private: priv}})
+		if err != nil {
+			t.Fatalf("NewKeySet: %v", err)
+		}
+		return keys
+	}
+	keys := newKeySet("k1")
+	token, err := GenerateToken("user-123", 0, keys, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken error: %v", err)
+	}
+
+	e := echo.New()
+	ok := func(c echo.Context) error { return c.String(http.StatusOK, "ok") }
+	for _, tt := range []struct {
+		name string
+		keys TokenKeys
+		want int
+	}{
+		{"signing key", keys, http.StatusOK},
+		{"unknown key", newKeySet("k2"), http.StatusUnauthorized},
+		{"hmac", signing.NewHMAC([]byte("test-key")), http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		err := JWTAuth(tt.keys, nil)(ok)(e.NewContext(req, rec))
+		code := rec.Code
+		var he *echo.HTTPError
+		if errors.As(err, &he) {
+			code = he.Code
+		}
+		if code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, code)
+		}
+	}
+}
+
 func TestJWTAuthRejectsMissingHeader(t *testing.T) {
-	key := []byte("test-key")
+	key := signing.NewHMAC([]byte("test-key"))
 	e := echo.New()
 
-	handler := JWTAuth(key)(func(c echo.Context) error {
+	handler := JWTAuth(key, nil)(func(c echo.Context) error {
 		return c.String(http.StatusOK, "ok")
 	})
 
@@ -70,10 +121,10 @@ func TestJWTAuthRejectsMissingHeader(t *testing.T) {
 }
 
 func TestJWTAuthRejectsInvalidToken(t *testing.T) {
-	key := []byte("test-key")
+	key := signing.NewHMAC([]byte("test-key"))
 	e := echo.New()
 
-	handler := JWTAuth(key)(func(c echo.Context) error {
+	handler := JWTAuth(key, nil)(func(c echo.Context) error {
 		return c.String(http.StatusOK, "ok")
 	})
 
@@ -97,16 +148,16 @@ func TestJWTAuthRejectsInvalidToken(t *testing.T) {
 }
 
 func TestJWTAuthRejectsExpiredToken(t *testing.T) {
-	key := []byte("test-key")
+	key := signing.NewHMAC([]byte("test-key"))
 
 	// Generate a token that expires immediately
-	token, err := GenerateToken("user-456", key, -1*time.Hour)
+	token, err := GenerateToken("user-456", 0, key, -1*time.Hour)
 	if err != nil {
 		t.Fatalf("GenerateToken error: %v", err)
 	}
 
 	e := echo.New()
-	handler := JWTAuth(key)(func(c echo.Context) error {
+	handler := JWTAuth(key, nil)(func(c echo.Context) error {
 		return c.String(http.StatusOK, "ok")
 	})
 
@@ -122,10 +173,10 @@ func TestJWTAuthRejectsExpiredToken(t *testing.T) {
 }
 
 func TestJWTAuthRejectsBadFormat(t *testing.T) {
-	key := []byte("test-key")
+	key := signing.NewHMAC([]byte("test-key"))
 	e := echo.New()
 
-	handler := JWTAuth(key)(func(c echo.Context) error {
+	handler := JWTAuth(key, nil)(func(c echo.Context) error {
 		return c.String(http.StatusOK, "ok")
 	})
 
@@ -139,3 +190,126 @@ func TestJWTAuthRejectsBadFormat(t *testing.T) {
 		t.Fatal("expected error for bad auth format")
 	}
 }
+
+type fakeRevocations struct {
+	revoked map[string]bool
+	gen     int
+}
+
+func (f fakeRevocations) IsRevoked(_ context.Context, _ string, generation int, tokenID string) (bool, error) {
+	return generation != f.gen || f.revoked[tokenID], nil
+}
+
+func TestJWTAuthRejectsRevokedToken(t *testing.T) {
+	key := signing.NewHMAC([]byte("test-key"))
+	e := echo.New()
+
+	token, err := GenerateToken("user-789", 2, key, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken error: %v", err)
+	}
+
+	var claims *Claims
+	run := func(checker RevocationChecker) error {
+		handler := JWTAuth(key, checker)(func(c echo.Context) error {
+			claims = GetClaims(c)
+			return c.String(http.StatusOK, "ok")
+		})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return handler(e.NewContext(req, httptest.NewRecorder()))
+	}
+
+	if err := run(fakeRevocations{gen: 2}); err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+	if claims == nil || claims.ID == "" || claims.Generation != 2 {
+		t.Fatalf("expected claims with token ID and generation, got %+v", claims)
+	}
+
+	if err := run(fakeRevocations{gen: 3}); err == nil {
+		t.Error("expected error for token from an old generation")
+	}
+	if err := run(fakeRevocations{gen: 2, revoked: map[string]bool{claims.ID: true}}); err == nil {
+		t.Error("expected error for revoked token ID")
+	}
+}
+
+func TestWithAPIKeysAndScopes(t *testing.T) {
+	key := signing.NewHMAC([]byte("test-key"))
+	e := echo.New()
+
+	authenticate := func(_ context.Context, k, ip string) (string, []string, error) {
+		switch k {
+		case "mdk_read":
+			return "user-1", []string{"read-only"}, nil
+		case "mdk_full":
+			return "user-1", nil, nil
+		}
+		return "", nil, ErrInvalidAPIKey
+	}
+	routes := map[string]string{"GET /things": "read-only"}
+
+	auth := WithAPIKeys("mdk_", authenticate, JWTAuth(key, nil))
+	ok := func(c echo.Context) error { return c.String(http.StatusOK, GetUserID(c)) }
+	e.GET("/things", ok, auth, RequireScopes(routes))
+	e.POST("/things", ok, auth, RequireScopes(routes))
+	e.GET("/keys", ok, auth, RequireScopes(routes), SessionOnly())
+
+	jwtToken, _ := GenerateToken("user-1", 0, key, time.Hour)
+
+	tests := []struct {
+		method, path, token string
+		code                int
+	}{
+		{http.MethodGet, "/things", "mdk_read", http.StatusOK},
+		{http.MethodPost, "/things", "mdk_read", http.StatusForbidden},
+		{http.MethodPost, "/things", "mdk_full", http.StatusOK},
+		{http.MethodGet, "/things", "mdk_unknown", http.StatusUnauthorized},
+		{http.MethodGet, "/keys", "mdk_full", http.StatusForbidden},
+		{http.MethodGet, "/keys", jwtToken, http.StatusOK},
+		{http.MethodPost, "/things", jwtToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != tt.code {
+			t.Errorf("%s %s with %s: expected %d, got %d", tt.method, tt.path, tt.token, tt.code, rec.Code)
+		}
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	key := signing.NewHMAC([]byte("test-key"))
+	e := echo.New()
+
+	isAdmin := func(_ context.Context, userID string) (bool, error) {
+		if userID == "broken" {
+			return false, errors.New("db down")
+		}
+		return userID == "admin-1", nil
+	}
+	ok := func(c echo.Context) error { return c.String(http.StatusOK, "ok") }
+	e.GET("/admin", ok, JWTAuth(key, nil), RequireAdmin(isAdmin))
+
+	tests := []struct {
+		userID string
+		code   int
+	}{
+		{"admin-1", http.StatusOK},
+		{"user-1", http.StatusForbidden},
+		{"broken", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		token, _ := GenerateToken(tt.userID, 0, key, time.Hour)
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != tt.code {
+			t.Errorf("%s: expected %d, got %d", tt.userID, tt.code, rec.Code)
+		}
+	}
+}
@@ -2,15 +2,28 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/akhil-datla/maildruid/internal/config"
+	"github.com/akhil-datla/maildruid/internal/domain/apikey"
+	"github.com/akhil-datla/maildruid/internal/domain/audit"
+	"github.com/akhil-datla/maildruid/internal/domain/lockout"
+	"github.com/akhil-datla/maildruid/internal/domain/mailbox"
+	"github.com/akhil-datla/maildruid/internal/domain/mfa"
+	"github.com/akhil-datla/maildruid/internal/domain/passwordreset"
+	"github.com/akhil-datla/maildruid/internal/domain/session"
 	"github.com/akhil-datla/maildruid/internal/domain/summary"
+	"github.com/akhil-datla/maildruid/internal/domain/team"
 	"github.com/akhil-datla/maildruid/internal/domain/user"
-	"github.com/akhil-datla/maildruid/internal/infrastructure/postgres"
+	"github.com/akhil-datla/maildruid/internal/domain/verification"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/oauth"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/oidc"
+	"github.com/akhil-datla/maildruid/internal/infrastructure/signing"
 	"github.com/akhil-datla/maildruid/internal/scheduler"
 	"github.com/akhil-datla/maildruid/internal/server/handlers"
 	"github.com/akhil-datla/maildruid/internal/server/middleware"
@@ -32,8 +45,20 @@ type Server struct {
 // New creates and configures the HTTP server.
 func New(
 	cfg config.Config,
-	db *postgres.DB,
+	db handlers.DBPinger,
 	userSvc *user.Service,
+	mailboxSvc *mailbox.Service,
+	sessionSvc *session.Service,
+	tokenKeys *signing.KeySet,
+	mfaSvc *mfa.Service,
+	lockoutSvc *lockout.Service,
+	verifySvc *verification.Service,
+	resetSvc *passwordreset.Service,
+	apiKeySvc *apikey.Service,
+	auditSvc *audit.Service,
+	teamSvc *team.Service,
+	oidcProvider *oidc.Provider,
+	mailProviders *oauth.Providers,
 	summarySvc *summary.Service,
 	sched *scheduler.Scheduler,
 	logger *slog.Logger,
@@ -41,6 +66,7 @@ func New(
 	e := echo.New()
 	e.HideBanner = true
 	e.HidePort = true
+	e.IPExtractor = ipExtractor(cfg.Server.TrustedProxies)
 
 	// Validator
 	e.Validator = handlers.NewValidator()
@@ -48,6 +74,7 @@ func New(
 	// Middleware
 	e.Use(echoMW.Recover())
 	e.Use(echoMW.RequestID())
+	e.Use(middleware.AuditSource())
 	e.Use(echoMW.CORSWithConfig(echoMW.CORSConfig{
 		AllowOrigins: cfg.Server.AllowOrigins,
 		AllowMethods: []string{
@@ -72,45 +99,148 @@ func New(
 
 	// Handlers
 	healthH := handlers.NewHealthHandler(db, Version)
-	userH := handlers.NewUserHandler(userSvc, cfg.Auth)
-	scheduleH := handlers.NewScheduleHandler(sched)
+	jwksH := handlers.NewJWKSHandler(tokenKeys)
+	authH := handlers.NewAuthHandler(userSvc, sessionSvc, mfaSvc, lockoutSvc, auditSvc, tokenKeys, cfg.Auth)
+	userH := handlers.NewUserHandler(userSvc, mailboxSvc, verifySvc, teamSvc, logger)
+	mailboxH := handlers.NewMailboxHandler(mailboxSvc, summarySvc, logger)
+	imapOAuthH := handlers.NewIMAPOAuthHandler(mailProviders, mailboxSvc, teamSvc, cfg, logger)
+	resetH := handlers.NewPasswordResetHandler(resetSvc, logger)
+	twoFactorH := handlers.NewTwoFactorHandler(mfaSvc, auditSvc, cfg.Auth)
+	apiKeyH := handlers.NewAPIKeyHandler(apiKeySvc, auditSvc)
+	auditH := handlers.NewAuditHandler(auditSvc, teamSvc, logger)
+	scheduleH := handlers.NewScheduleHandler(sched, userSvc)
+	adminH := handlers.NewAdminHandler(userSvc, teamSvc, sched, logger)
 	summaryH := handlers.NewSummaryHandler(userSvc, summarySvc, logger)
+	teamH := handlers.NewTeamHandler(teamSvc, summarySvc, sched, logger)
 
 	// Public routes
 	e.GET("/healthz", healthH.Liveness)
 	e.GET("/readyz", healthH.Readiness)
+	e.GET("/.well-known/jwks.json", jwksH.Keys)
 
 	// API v1
 	v1 := e.Group("/api/v1")
 
 	// Auth (public)
-	v1.POST("/users", userH.Create)
-	v1.POST("/auth/login", userH.Login)
-	v1.GET("/schedules", scheduleH.List)
+	if !cfg.Auth.OIDC.DisablePasswordLogin {
+		v1.POST("/users", userH.Create)
+		v1.POST("/auth/login", authH.Login)
+		v1.POST("/auth/unlock", authH.Unlock)
+		v1.POST("/auth/forgot-password", resetH.ForgotPassword)
+		v1.POST("/auth/reset-password", resetH.ResetPassword)
+	}
+	// SSO logins are challenged for a second factor too, so these stay
+	// reachable when password login is off.
+	v1.POST("/auth/2fa", authH.CompleteTwoFactor)
+	v1.POST("/auth/2fa/enroll", authH.EnrollTwoFactor)
+	v1.POST("/auth/refresh", authH.Refresh)
+	v1.GET("/verify-email", userH.VerifyEmail)
+	if oidcProvider != nil {
+		oidcH := handlers.NewOIDCHandler(oidcProvider, userSvc, sessionSvc, mfaSvc, auditSvc, tokenKeys, cfg.Auth, logger)
+		v1.GET("/auth/oidc/login", oidcH.Login)
+		v1.GET("/auth/oidc/callback", oidcH.Callback)
+	}
+	v1.GET("/imap/oauth2/callback", imapOAuthH.Callback)
 
 	// Protected routes
-	auth := v1.Group("", middleware.JWTAuth([]byte(cfg.Auth.SigningKey)))
+	auth := v1.Group("",
+		middleware.WithAPIKeys(apikey.KeyPrefix, apiKeyAuth(apiKeySvc, userSvc),
+			middleware.JWTAuth(tokenKeys, sessionSvc)),
+		middleware.RequireScopes(apiKeyScopes),
+		middleware.AuditActor(),
+	)
+
+	auth.POST("/auth/logout", authH.Logout, middleware.SessionOnly())
+
+	// API keys
+	auth.GET("/users/me/api-keys", apiKeyH.List, middleware.SessionOnly())
+	auth.POST("/users/me/api-keys", apiKeyH.Create, middleware.SessionOnly())
+	auth.DELETE("/users/me/api-keys/:id", apiKeyH.Delete, middleware.SessionOnly())
+
+	// Two-factor authentication
+	auth.GET("/users/me/2fa", twoFactorH.Status, middleware.SessionOnly())
+	auth.POST("/users/me/2fa/totp", twoFactorH.Enroll, middleware.SessionOnly())
+	auth.POST("/users/me/2fa/totp/confirm", twoFactorH.Confirm, middleware.SessionOnly())
+	auth.DELETE("/users/me/2fa/totp", twoFactorH.Disable, middleware.SessionOnly())
+	auth.POST("/users/me/2fa/recovery-codes", twoFactorH.RegenerateRecoveryCodes, middleware.SessionOnly())
 
 	// User management
 	auth.GET("/users/me", userH.GetProfile)
 	auth.PATCH("/users/me", userH.Update)
 	auth.DELETE("/users/me", userH.Delete)
+	auth.POST("/users/me/verify-email", userH.ResendVerification, middleware.SessionOnly())
+	auth.GET("/users/me/audit", auditH.ListMine)
+
+	// Mailboxes
+	auth.GET("/mailboxes", mailboxH.List)
+	auth.POST("/mailboxes", mailboxH.Create)
+	auth.GET("/mailboxes/:id", mailboxH.Get)
+	auth.PATCH("/mailboxes/:id", mailboxH.Update)
+	auth.DELETE("/mailboxes/:id", mailboxH.Delete)
+	auth.POST("/mailboxes/:id/test", mailboxH.Test)
+	auth.GET("/mailboxes/:id/folders", mailboxH.Folders)
+	auth.GET("/imap/oauth2/providers", imapOAuthH.Providers)
+	auth.POST("/mailboxes/:id/oauth2/:provider", imapOAuthH.Authorize, middleware.SessionOnly())
+	auth.DELETE("/mailboxes/:id/oauth2", imapOAuthH.Unlink, middleware.SessionOnly())
 
 	// Email configuration
-	auth.GET("/users/me/folders", userH.GetFolders)
-	auth.PATCH("/users/me/folder", userH.UpdateFolder)
 	auth.PUT("/users/me/tags", userH.UpdateTags)
 	auth.PUT("/users/me/blacklist", userH.UpdateBlacklist)
 	auth.PATCH("/users/me/start-time", userH.UpdateStartTime)
 	auth.PATCH("/users/me/summary-count", userH.UpdateSummaryCount)
 
 	// Scheduling
+	auth.GET("/schedules", scheduleH.List)
 	auth.POST("/schedules", scheduleH.Create)
 	auth.PATCH("/schedules", scheduleH.Update)
 	auth.DELETE("/schedules", scheduleH.Delete)
 
-	// Summary generation
+	// Summary generation and history
 	auth.POST("/summaries/generate", summaryH.Generate)
+	auth.GET("/summaries", summaryH.List)
+	auth.GET("/summaries/:id", summaryH.Get)
+
+	// Teams
+	auth.GET("/teams", teamH.List)
+	auth.POST("/teams", teamH.Create)
+	auth.GET("/teams/:id", teamH.Get)
+	auth.PATCH("/teams/:id", teamH.Update)
+	auth.DELETE("/teams/:id", teamH.Delete)
+	auth.GET("/teams/:id/audit", auditH.ListTeam)
+	auth.GET("/teams/:id/members", teamH.Members)
+	auth.POST("/teams/:id/members", teamH.AddMember)
+	auth.PUT("/teams/:id/members/me/delivery", teamH.UpdateDelivery)
+	auth.PATCH("/teams/:id/members/:userId", teamH.UpdateMember)
+	auth.DELETE("/teams/:id/members/:userId", teamH.RemoveMember)
+	auth.GET("/teams/:id/mailboxes", teamH.Mailboxes)
+	auth.POST("/teams/:id/mailboxes", teamH.CreateMailbox)
+	auth.GET("/teams/:id/mailboxes/:mailboxId", teamH.GetMailbox)
+	auth.PATCH("/teams/:id/mailboxes/:mailboxId", teamH.UpdateMailbox)
+	auth.DELETE("/teams/:id/mailboxes/:mailboxId", teamH.DeleteMailbox)
+	auth.POST("/teams/:id/mailboxes/:mailboxId/test", teamH.TestMailbox)
+	auth.GET("/teams/:id/mailboxes/:mailboxId/folders", teamH.MailboxFolders)
+	auth.POST("/teams/:id/mailboxes/:mailboxId/oauth2/:provider", imapOAuthH.AuthorizeTeam, middleware.SessionOnly())
+	auth.DELETE("/teams/:id/mailboxes/:mailboxId/oauth2", imapOAuthH.UnlinkTeam, middleware.SessionOnly())
+	auth.GET("/teams/:id/digests", teamH.Digests)
+	auth.POST("/teams/:id/digests", teamH.CreateDigest)
+	auth.GET("/teams/:id/digests/:digestId", teamH.GetDigest)
+	auth.PATCH("/teams/:id/digests/:digestId", teamH.UpdateDigest)
+	auth.DELETE("/teams/:id/digests/:digestId", teamH.DeleteDigest)
+	auth.POST("/teams/:id/digests/:digestId/run", teamH.RunDigest)
+	auth.GET("/teams/:id/digests/:digestId/history", teamH.History)
+	auth.GET("/teams/:id/digests/:digestId/history/:runId", teamH.HistoryItem)
+
+	// Administration
+	admin := auth.Group("/admin", middleware.SessionOnly(), middleware.RequireAdmin(isAdmin(userSvc)))
+	admin.GET("/users", adminH.ListUsers)
+	admin.GET("/users/:id", adminH.GetUser)
+	admin.DELETE("/users/:id", adminH.DeleteUser)
+	admin.POST("/users/:id/disable", adminH.DisableUser)
+	admin.POST("/users/:id/enable", adminH.EnableUser)
+	admin.GET("/users/:id/schedule", adminH.GetSchedule)
+	admin.POST("/users/:id/schedule/run", adminH.RunSchedule)
+	admin.DELETE("/users/:id/schedule", adminH.CancelSchedule)
+	admin.GET("/audit", auditH.List)
 
 	// Serve embedded frontend (SPA fallback for non-API routes)
 	serveFrontend(e)
@@ -118,6 +248,82 @@ func New(
 	return &Server{echo: e, cfg: cfg.Server, logger: logger}
 }
 
+// ipExtractor decides where client IPs come from. Without trusted proxies
+// the peer address is used, so clients cannot dodge per-IP limits by
+// sending their own X-Forwarded-For header. CIDRs are validated by config.
+func ipExtractor(trustedProxies []string) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	opts := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, cidr := range trustedProxies {
+		if _, ipNet, err := net.ParseCIDR(cidr); err == nil {
+			opts = append(opts, echo.TrustIPRange(ipNet))
+		}
+	}
+	return echo.ExtractIPFromXFFHeader(opts...)
+}
+
+// apiKeyScopes lists the routes reachable with a scoped API key and the
+// scope each one needs. Scoped keys are refused everywhere else.
+var apiKeyScopes = map[string]string{
+	"GET /api/v1/users/me":              apikey.ScopeReadOnly,
+	"GET /api/v1/users/me/audit":        apikey.ScopeReadOnly,
+	"GET /api/v1/mailboxes":             apikey.ScopeReadOnly,
+	"GET /api/v1/mailboxes/:id":         apikey.ScopeReadOnly,
+	"GET /api/v1/mailboxes/:id/folders": apikey.ScopeReadOnly,
+	"GET /api/v1/summaries":             apikey.ScopeReadOnly,
+	"GET /api/v1/summaries/:id":         apikey.ScopeReadOnly,
+	"GET /api/v1/schedules":             apikey.ScopeReadOnly,
+	"POST /api/v1/summaries/generate":   apikey.ScopeSummariesGenerate,
+	"POST /api/v1/schedules":            apikey.ScopeSchedulesWrite,
+	"PATCH /api/v1/schedules":           apikey.ScopeSchedulesWrite,
+	"DELETE /api/v1/schedules":          apikey.ScopeSchedulesWrite,
+}
+
+// apiKeyAuth adapts the API key service to the auth middleware. Keys of
+// disabled accounts are refused.
+func apiKeyAuth(svc *apikey.Service, userSvc *user.Service) middleware.APIKeyAuthFunc {
+	return func(ctx context.Context, key, ip string) (string, []string, error) {
+		k, err := svc.Authenticate(ctx, key, ip)
+		if errors.Is(err, apikey.ErrInvalidKey) {
+			return "", nil, middleware.ErrInvalidAPIKey
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		u, err := userSvc.GetByID(ctx, k.UserID)
+		if errors.Is(err, user.ErrNotFound) {
+			return "", nil, middleware.ErrInvalidAPIKey
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		if u.Disabled {
+			return "", nil, middleware.ErrInvalidAPIKey
+		}
+		return k.UserID, []string(k.Scopes), nil
+	}
+}
+
+// isAdmin adapts the user service to the admin middleware.
+func isAdmin(userSvc *user.Service) middleware.AdminCheckFunc {
+	return func(ctx context.Context, userID string) (bool, error) {
+		u, err := userSvc.GetByID(ctx, userID)
+		if errors.Is(err, user.ErrNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return u.IsAdmin() && !u.Disabled, nil
+	}
+}
+
 // Start begins serving HTTP requests.
 func (s *Server) Start() error {
 	addr := fmt.Sprintf(":%d", s.cfg.Port)
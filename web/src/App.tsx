@@ -5,6 +5,9 @@ import Login from './pages/Login';
 import Register from './pages/Register';
 import Dashboard from './pages/Dashboard';
 import Settings from './pages/Settings';
+import SsoCallback from './pages/SsoCallback';
+import ResetPassword from './pages/ResetPassword';
+import UnlockAccount from './pages/UnlockAccount';
 
 function PrivateRoute({ children }: { children: React.ReactNode }) {
   const { isAuthenticated } = useAuth();
@@ -23,6 +26,9 @@ export default function App() {
         <Routes>
           <Route path="/login" element={<PublicRoute><Login /></PublicRoute>} />
           <Route path="/register" element={<PublicRoute><Register /></PublicRoute>} />
+          <Route path="/reset-password" element={<PublicRoute><ResetPassword /></PublicRoute>} />
+          <Route path="/unlock" element={<PublicRoute><UnlockAccount /></PublicRoute>} />
+          <Route path="/sso" element={<SsoCallback />} />
           <Route
             element={
               <PrivateRoute>
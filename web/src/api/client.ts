@@ -9,7 +9,7 @@ class ApiError extends Error {
   }
 }
 
-async function request<T>(path: string, options: RequestInit = {}): Promise<T> {
+async function request<T>(path: string, options: RequestInit = {}, retry = true): Promise<T> {
   const token = localStorage.getItem('token');
   const headers: Record<string, string> = {
     'Content-Type': 'application/json',
@@ -22,6 +22,10 @@ async function request<T>(path: string, options: RequestInit = {}): Promise<T> {
 
   const res = await fetch(`${API_BASE}${path}`, { ...options, headers });
 
+  if (res.status === 401 && retry && !path.startsWith('/auth/') && (await refreshSession())) {
+    return request<T>(path, options, false);
+  }
+
   if (!res.ok) {
     const body = await res.json().catch(() => ({ error: res.statusText }));
     throw new ApiError(res.status, body.error || body.message || 'Request failed');
@@ -30,39 +34,125 @@ async function request<T>(path: string, options: RequestInit = {}): Promise<T> {
   return res.json();
 }
 
+export interface TokenPair {
+  token: string;
+  refreshToken: string;
+  expiresIn: number;
+}
+
+let refreshing: Promise<boolean> | null = null;
+
+// refreshSession exchanges the stored refresh token for a new token pair.
+// Concurrent callers share one request, since each refresh token is single-use.
+function refreshSession(): Promise<boolean> {
+  const refreshToken = localStorage.getItem('refreshToken');
+  if (!refreshToken) return Promise.resolve(false);
+
+  refreshing ??= fetch(`${API_BASE}/auth/refresh`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ refreshToken }),
+  })
+    .then(async (res) => {
+      if (!res.ok) return false;
+      const pair: TokenPair = await res.json();
+      localStorage.setItem('token', pair.token);
+      localStorage.setItem('refreshToken', pair.refreshToken);
+      return true;
+    })
+    .catch(() => false)
+    .finally(() => {
+      refreshing = null;
+    });
+
+  return refreshing;
+}
+
 // Auth
 export const register = (data: {
   name: string;
   email: string;
   receivingEmail: string;
   password: string;
+  imapPassword: string;
   domain: string;
   port: number;
 }) => request<{ message: string }>('/users', { method: 'POST', body: JSON.stringify(data) });
 
+export interface LoginChallenge {
+  challengeToken: string;
+  expiresIn: number;
+  enrollmentRequired: boolean;
+}
+
+export interface TotpEnrollment {
+  secret: string;
+  uri: string;
+}
+
 export const login = (email: string, password: string) =>
-  request<{ token: string }>('/auth/login', {
+  request<TokenPair | LoginChallenge>('/auth/login', {
     method: 'POST',
     body: JSON.stringify({ email, password }),
   });
 
+export const completeTwoFactor = (challengeToken: string, code: string) =>
+  request<TokenPair & { recoveryCodes?: string[] }>('/auth/2fa', {
+    method: 'POST',
+    body: JSON.stringify({ challengeToken, code }),
+  });
+
+export const enrollTwoFactor = (challengeToken: string) =>
+  request<TotpEnrollment>('/auth/2fa/enroll', {
+    method: 'POST',
+    body: JSON.stringify({ challengeToken }),
+  });
+
+export const forgotPassword = (email: string) =>
+  request<{ message: string }>('/auth/forgot-password', {
+    method: 'POST',
+    body: JSON.stringify({ email }),
+  });
+
+export const resetPassword = (token: string, password: string) =>
+  request<{ message: string }>('/auth/reset-password', {
+    method: 'POST',
+    body: JSON.stringify({ token, password }),
+  });
+
+export const unlockAccount = (token: string) =>
+  request<{ message: string }>('/auth/unlock', {
+    method: 'POST',
+    body: JSON.stringify({ token }),
+  });
+
+export const logout = () =>
+  request<{ message: string }>('/auth/logout', {
+    method: 'POST',
+    body: JSON.stringify({ refreshToken: localStorage.getItem('refreshToken') ?? undefined }),
+  });
+
 // User
 export const getProfile = () => request<UserProfile>('/users/me');
 
 export const updateProfile = (data: Record<string, unknown>) =>
   request<{ message: string }>('/users/me', { method: 'PATCH', body: JSON.stringify(data) });
 
+export const resendVerification = () =>
+  request<{ message: string }>('/users/me/verify-email', { method: 'POST' });
+
 export const deleteAccount = () =>
   request<{ message: string }>('/users/me', { method: 'DELETE' });
 
-// Email config
-export const getFolders = () => request<string[]>('/users/me/folders');
+// Mailboxes
+export const getMailboxes = () => request<Mailbox[]>('/mailboxes');
 
-export const updateFolder = (folder: string) =>
-  request<{ message: string }>('/users/me/folder', {
-    method: 'PATCH',
-    body: JSON.stringify({ folder }),
-  });
+export const updateMailbox = (id: string, data: Record<string, unknown>) =>
+  request<Mailbox>(`/mailboxes/${id}`, { method: 'PATCH', body: JSON.stringify(data) });
+
+export const getMailboxFolders = (id: string) => request<string[]>(`/mailboxes/${id}/folders`);
+
+// Email config
 
 export const updateTags = (tags: string[]) =>
   request<{ message: string }>('/users/me/tags', {
@@ -119,14 +209,26 @@ export interface UserProfile {
   name: string;
   email: string;
   receivingEmail: string;
-  domain: string;
-  port: number;
-  folder: string;
+  receivingEmailVerified: boolean;
   tags: string[] | null;
   blackListSenders: string[] | null;
   startTime: string;
   summaryCount: number;
   updateInterval: string;
+  role: 'user' | 'admin';
+  disabled: boolean;
+}
+
+export interface Mailbox {
+  id: string;
+  name: string;
+  username: string;
+  domain: string;
+  port: number;
+  folders: string[] | null;
+  enabled: boolean;
+  push: boolean;
+  oauthProvider: string;
 }
 
 export interface TaskInfo {
@@ -137,6 +239,8 @@ export interface TaskInfo {
 export interface SummaryResult {
   summary: string;
   image?: string;
+  truncated?: number;
+  skipped?: number;
 }
 
 export { ApiError };
@@ -1,9 +1,10 @@
 import { createContext, useContext, useState, useCallback, type ReactNode } from 'react';
+import { logout as apiLogout } from '../api/client';
 
 interface AuthContextType {
   token: string | null;
   isAuthenticated: boolean;
-  login: (token: string) => void;
+  login: (token: string, refreshToken: string) => void;
   logout: () => void;
 }
 
@@ -12,13 +13,17 @@ const AuthContext = createContext<AuthContextType | null>(null);
 export function AuthProvider({ children }: { children: ReactNode }) {
   const [token, setToken] = useState<string | null>(() => localStorage.getItem('token'));
 
-  const login = useCallback((newToken: string) => {
+  const login = useCallback((newToken: string, refreshToken: string) => {
     localStorage.setItem('token', newToken);
+    localStorage.setItem('refreshToken', refreshToken);
     setToken(newToken);
   }, []);
 
   const logout = useCallback(() => {
+    // Best effort: the local session ends even if the server is unreachable.
+    if (localStorage.getItem('token')) apiLogout().catch(() => {});
     localStorage.removeItem('token');
+    localStorage.removeItem('refreshToken');
     setToken(null);
   }, []);
 
@@ -1,5 +1,13 @@
 import { useState, useEffect, useCallback } from 'react';
-import { getProfile, generateSummary, type UserProfile, type SummaryResult, ApiError } from '../api/client';
+import {
+  getProfile,
+  getMailboxes,
+  generateSummary,
+  type UserProfile,
+  type Mailbox,
+  type SummaryResult,
+  ApiError,
+} from '../api/client';
 import { useAuth } from '../context/AuthContext';
 import { useNavigate, Link } from 'react-router-dom';
 import {
@@ -17,6 +25,7 @@ import {
 
 export default function Dashboard() {
   const [profile, setProfile] = useState<UserProfile | null>(null);
+  const [mailboxes, setMailboxes] = useState<Mailbox[]>([]);
   const [summary, setSummary] = useState<SummaryResult | null>(null);
   const [loading, setLoading] = useState(true);
   const [generating, setGenerating] = useState(false);
@@ -26,8 +35,9 @@ export default function Dashboard() {
 
   const loadProfile = useCallback(async () => {
     try {
-      const data = await getProfile();
+      const [data, boxes] = await Promise.all([getProfile(), getMailboxes()]);
       setProfile(data);
+      setMailboxes(boxes);
     } catch (err) {
       if (err instanceof ApiError && err.status === 401) {
         logout();
@@ -67,7 +77,8 @@ export default function Dashboard() {
   }
 
   const hasTags = profile?.tags && profile.tags.length > 0;
-  const hasFolder = !!profile?.folder;
+  const enabled = mailboxes.filter((m) => m.enabled);
+  const hasMailbox = enabled.length > 0;
   const hasSchedule = profile?.updateInterval && profile.updateInterval !== '0';
 
   return (
@@ -95,9 +106,9 @@ export default function Dashboard() {
         />
         <StatusCard
           icon={<FolderOpen className="w-5 h-5" />}
-          label="Folder"
-          value={hasFolder ? profile!.folder : 'Not set'}
-          configured={hasFolder}
+          label="Mailboxes"
+          value={hasMailbox ? enabled.map((m) => `${m.name} (${m.folders?.length ? m.folders.join(', ') : 'INBOX'})`).join(', ') : 'None enabled'}
+          configured={hasMailbox}
           color="violet"
         />
         <StatusCard
@@ -1,7 +1,14 @@
-import { useState } from 'react';
-import { useNavigate, Link } from 'react-router-dom';
+import { useEffect, useState } from 'react';
+import { useNavigate, useLocation, Link } from 'react-router-dom';
 import { useAuth } from '../context/AuthContext';
-import { login as apiLogin, ApiError } from '../api/client';
+import {
+  login as apiLogin,
+  completeTwoFactor,
+  enrollTwoFactor,
+  ApiError,
+  type LoginChallenge,
+  type TotpEnrollment,
+} from '../api/client';
 import { Mail, Loader2, ArrowRight, Sparkles } from 'lucide-react';
 
 export default function Login() {
@@ -9,8 +16,26 @@ export default function Login() {
   const [password, setPassword] = useState('');
   const [error, setError] = useState('');
   const [loading, setLoading] = useState(false);
+  const [challenge, setChallenge] = useState<LoginChallenge | null>(null);
+  const [enrollment, setEnrollment] = useState<TotpEnrollment | null>(null);
+  const [code, setCode] = useState('');
+  const [recoveryCodes, setRecoveryCodes] = useState<string[] | null>(null);
   const { login } = useAuth();
   const navigate = useNavigate();
+  const location = useLocation();
+
+  // A single sign-on login that still needs a second factor lands here
+  // with its challenge.
+  useEffect(() => {
+    const sso = (location.state as { challenge?: LoginChallenge } | null)?.challenge;
+    if (!sso) return;
+    setChallenge(sso);
+    if (sso.enrollmentRequired) {
+      enrollTwoFactor(sso.challengeToken)
+        .then(setEnrollment)
+        .catch((err) => setError(err instanceof ApiError ? err.message : 'Login failed'));
+    }
+  }, [location.state]);
 
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
@@ -18,9 +43,25 @@ export default function Login() {
     setLoading(true);
 
     try {
-      const res = await apiLogin(email, password);
-      login(res.token);
-      navigate('/dashboard');
+      if (!challenge) {
+        const res = await apiLogin(email, password);
+        if ('challengeToken' in res) {
+          setChallenge(res);
+          if (res.enrollmentRequired) setEnrollment(await enrollTwoFactor(res.challengeToken));
+          return;
+        }
+        login(res.token, res.refreshToken);
+        navigate('/dashboard');
+        return;
+      }
+
+      const res = await completeTwoFactor(challenge.challengeToken, code);
+      login(res.token, res.refreshToken);
+      if (res.recoveryCodes?.length) {
+        setRecoveryCodes(res.recoveryCodes);
+      } else {
+        navigate('/dashboard');
+      }
     } catch (err) {
       setError(err instanceof ApiError ? err.message : 'Login failed');
     } finally {
@@ -90,32 +131,75 @@ export default function Login() {
               </div>
             )}
 
-            <div className="space-y-1.5">
-              <label className="block text-sm font-medium text-gray-700 dark:text-gray-300">Email address</label>
-              <input
-                type="email"
-                value={email}
-                onChange={(e) => setEmail(e.target.value)}
-                required
-                className="w-full px-4 py-3 border border-gray-200 dark:border-gray-800 rounded-xl bg-gray-50 dark:bg-gray-900 text-gray-900 dark:text-white placeholder-gray-400 focus:ring-2 focus:ring-brand-500/20 focus:border-brand-500 outline-none transition-all duration-200"
-                placeholder="you@example.com"
-              />
-            </div>
+            {recoveryCodes ? (
+              <div className="space-y-3">
+                <p className="text-sm text-gray-600 dark:text-gray-400">
+                  Two-factor authentication is on. Save these recovery codes somewhere safe; each one
+                  can be used once if you lose your authenticator.
+                </p>
+                <pre className="px-4 py-3 rounded-xl bg-gray-50 dark:bg-gray-900 text-sm text-gray-900 dark:text-white font-mono">
+                  {recoveryCodes.join('\n')}
+                </pre>
+              </div>
+            ) : challenge ? (
+              <div className="space-y-1.5">
+                {enrollment && (
+                  <p className="text-sm text-gray-600 dark:text-gray-400 mb-3">
+                    Two-factor authentication is required. Add this key to your authenticator app:{' '}
+                    <code className="font-mono break-all text-gray-900 dark:text-white">{enrollment.secret}</code>
+                  </p>
+                )}
+                <label className="block text-sm font-medium text-gray-700 dark:text-gray-300">
+                  {enrollment ? 'Authenticator code' : 'Authenticator or recovery code'}
+                </label>
+                <input
+                  type="text"
+                  inputMode="numeric"
+                  autoComplete="one-time-code"
+                  value={code}
+                  onChange={(e) => setCode(e.target.value)}
+                  required
+                  autoFocus
+                  className="w-full px-4 py-3 border border-gray-200 dark:border-gray-800 rounded-xl bg-gray-50 dark:bg-gray-900 text-gray-900 dark:text-white placeholder-gray-400 focus:ring-2 focus:ring-brand-500/20 focus:border-brand-500 outline-none transition-all duration-200"
+                  placeholder="123456"
+                />
+              </div>
+            ) : (
+              <>
+              <div className="space-y-1.5">
+                <label className="block text-sm font-medium text-gray-700 dark:text-gray-300">Email address</label>
+                <input
+                  type="email"
+                  value={email}
+                  onChange={(e) => setEmail(e.target.value)}
+                  required
+                  className="w-full px-4 py-3 border border-gray-200 dark:border-gray-800 rounded-xl bg-gray-50 dark:bg-gray-900 text-gray-900 dark:text-white placeholder-gray-400 focus:ring-2 focus:ring-brand-500/20 focus:border-brand-500 outline-none transition-all duration-200"
+                  placeholder="you@example.com"
+                />
+              </div>
 
-            <div className="space-y-1.5">
-              <label className="block text-sm font-medium text-gray-700 dark:text-gray-300">Password</label>
-              <input
-                type="password"
-                value={password}
-                onChange={(e) => setPassword(e.target.value)}
-                required
-                className="w-full px-4 py-3 border border-gray-200 dark:border-gray-800 rounded-xl bg-gray-50 dark:bg-gray-900 text-gray-900 dark:text-white placeholder-gray-400 focus:ring-2 focus:ring-brand-500/20 focus:border-brand-500 outline-none transition-all duration-200"
-                placeholder="Your IMAP password"
-              />
-            </div>
+              <div className="space-y-1.5">
+                <div className="flex items-center justify-between">
+                  <label className="block text-sm font-medium text-gray-700 dark:text-gray-300">Password</label>
+                  <Link to="/reset-password" className="text-sm text-brand-600 dark:text-brand-400 hover:text-brand-700 dark:hover:text-brand-300 transition-colors">
+                    Forgot password?
+                  </Link>
+                </div>
+                <input
+                  type="password"
+                  value={password}
+                  onChange={(e) => setPassword(e.target.value)}
+                  required
+                  className="w-full px-4 py-3 border border-gray-200 dark:border-gray-800 rounded-xl bg-gray-50 dark:bg-gray-900 text-gray-900 dark:text-white placeholder-gray-400 focus:ring-2 focus:ring-brand-500/20 focus:border-brand-500 outline-none transition-all duration-200"
+                  placeholder="Your MailDruid password"
+                />
+              </div>
+              </>
+            )}
 
             <button
-              type="submit"
+              type={recoveryCodes ? 'button' : 'submit'}
+              onClick={recoveryCodes ? () => navigate('/dashboard') : undefined}
               disabled={loading}
               className="w-full py-3 px-4 gradient-brand hover:opacity-90 disabled:opacity-50 text-white font-semibold rounded-xl transition-all duration-200 flex items-center justify-center gap-2 shadow-lg shadow-brand-500/25 cursor-pointer"
             >
@@ -123,13 +207,20 @@ export default function Login() {
                 <Loader2 className="w-5 h-5 animate-spin" />
               ) : (
                 <>
-                  Sign In
+                  {recoveryCodes ? 'Continue' : challenge ? 'Verify' : 'Sign In'}
                   <ArrowRight className="w-4 h-4" />
                 </>
               )}
             </button>
           </form>
 
+          <a
+            href="/api/v1/auth/oidc/login"
+            className="mt-4 w-full py-3 px-4 border border-gray-200 dark:border-gray-800 text-gray-700 dark:text-gray-300 hover:bg-gray-50 dark:hover:bg-gray-900 font-semibold rounded-xl transition-all duration-200 flex items-center justify-center"
+          >
+            Sign in with SSO
+          </a>
+
           <p className="text-center text-sm text-gray-500 dark:text-gray-400 mt-8">
             Don't have an account?{' '}
             <Link to="/register" className="text-brand-600 dark:text-brand-400 hover:text-brand-700 dark:hover:text-brand-300 font-semibold transition-colors">
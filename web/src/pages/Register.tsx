@@ -9,6 +9,7 @@ export default function Register() {
     email: '',
     receivingEmail: '',
     password: '',
+    imapPassword: '',
     domain: '',
     port: 993,
   });
@@ -75,7 +76,7 @@ export default function Register() {
                 </div>
                 <div>
                   <p className="font-medium text-sm">Encrypted Credentials</p>
-                  <p className="text-sm text-white/60">Your password is encrypted with AES-256 at rest.</p>
+                  <p className="text-sm text-white/60">Your IMAP password is encrypted at rest and never used to sign in.</p>
                 </div>
               </div>
             </div>
@@ -151,13 +152,26 @@ export default function Register() {
             </div>
 
             <div className="space-y-1.5">
-              <label className="block text-sm font-medium text-gray-700 dark:text-gray-300">IMAP password</label>
+              <label className="block text-sm font-medium text-gray-700 dark:text-gray-300">MailDruid password</label>
               <input
                 type="password"
                 value={form.password}
                 onChange={update('password')}
                 required
-                minLength={6}
+                minLength={8}
+                maxLength={72}
+                className="w-full px-4 py-3 border border-gray-200 dark:border-gray-800 rounded-xl bg-gray-50 dark:bg-gray-900 text-gray-900 dark:text-white placeholder-gray-400 focus:ring-2 focus:ring-brand-500/20 focus:border-brand-500 outline-none transition-all duration-200"
+                placeholder="Used to sign in to MailDruid"
+              />
+            </div>
+
+            <div className="space-y-1.5">
+              <label className="block text-sm font-medium text-gray-700 dark:text-gray-300">IMAP password</label>
+              <input
+                type="password"
+                value={form.imapPassword}
+                onChange={update('imapPassword')}
+                required
                 className="w-full px-4 py-3 border border-gray-200 dark:border-gray-800 rounded-xl bg-gray-50 dark:bg-gray-900 text-gray-900 dark:text-white placeholder-gray-400 focus:ring-2 focus:ring-brand-500/20 focus:border-brand-500 outline-none transition-all duration-200"
                 placeholder="App password recommended"
               />
@@ -0,0 +1,127 @@
+import { useState } from 'react';
+import { Link, useNavigate, useSearchParams } from 'react-router-dom';
+import { forgotPassword, resetPassword, ApiError } from '../api/client';
+import { Mail, Loader2, ArrowRight } from 'lucide-react';
+
+const inputClass =
+  'w-full px-4 py-3 border border-gray-200 dark:border-gray-800 rounded-xl bg-gray-50 dark:bg-gray-900 text-gray-900 dark:text-white placeholder-gray-400 focus:ring-2 focus:ring-brand-500/20 focus:border-brand-500 outline-none transition-all duration-200';
+
+// ResetPassword asks for an email to send a reset link to, or, when opened
+// from that link, for the new password.
+export default function ResetPassword() {
+  const [params] = useSearchParams();
+  const token = params.get('token');
+  const [email, setEmail] = useState('');
+  const [password, setPassword] = useState('');
+  const [error, setError] = useState('');
+  const [message, setMessage] = useState('');
+  const [loading, setLoading] = useState(false);
+  const navigate = useNavigate();
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError('');
+    setLoading(true);
+
+    try {
+      if (token) {
+        await resetPassword(token, password);
+        navigate('/login', { replace: true });
+        return;
+      }
+      const res = await forgotPassword(email);
+      setMessage(res.message);
+    } catch (err) {
+      setError(err instanceof ApiError ? err.message : 'Request failed');
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div className="min-h-screen flex items-center justify-center p-6 bg-white dark:bg-gray-950">
+      <div className="w-full max-w-sm animate-in">
+        <div className="flex items-center gap-3 mb-10">
+          <div className="w-10 h-10 rounded-xl gradient-brand flex items-center justify-center">
+            <Mail className="w-5 h-5 text-white" />
+          </div>
+          <span className="text-xl font-bold text-gray-900 dark:text-white">MailDruid</span>
+        </div>
+
+        <div className="mb-8">
+          <h2 className="text-2xl font-bold text-gray-900 dark:text-white">
+            {token ? 'Choose a new password' : 'Reset your password'}
+          </h2>
+          <p className="text-gray-500 dark:text-gray-400 mt-2">
+            {token
+              ? 'You will be signed out everywhere else.'
+              : "Enter your account email and we'll send you a reset link."}
+          </p>
+        </div>
+
+        <form onSubmit={handleSubmit} className="space-y-5">
+          {error && (
+            <div className="bg-red-50 dark:bg-red-950/50 text-red-600 dark:text-red-400 text-sm px-4 py-3 rounded-xl border border-red-100 dark:border-red-900/50 animate-in">
+              {error}
+            </div>
+          )}
+          {message && (
+            <div className="bg-emerald-50 dark:bg-emerald-950/50 text-emerald-600 dark:text-emerald-400 text-sm px-4 py-3 rounded-xl border border-emerald-100 dark:border-emerald-900/50 animate-in">
+              {message}
+            </div>
+          )}
+
+          {token ? (
+            <div className="space-y-1.5">
+              <label className="block text-sm font-medium text-gray-700 dark:text-gray-300">New password</label>
+              <input
+                type="password"
+                value={password}
+                onChange={(e) => setPassword(e.target.value)}
+                required
+                minLength={8}
+                maxLength={72}
+                autoComplete="new-password"
+                className={inputClass}
+                placeholder="At least 8 characters"
+              />
+            </div>
+          ) : (
+            <div className="space-y-1.5">
+              <label className="block text-sm font-medium text-gray-700 dark:text-gray-300">Email address</label>
+              <input
+                type="email"
+                value={email}
+                onChange={(e) => setEmail(e.target.value)}
+                required
+                className={inputClass}
+                placeholder="you@example.com"
+              />
+            </div>
+          )}
+
+          <button
+            type="submit"
+            disabled={loading}
+            className="w-full py-3 px-4 gradient-brand hover:opacity-90 disabled:opacity-50 text-white font-semibold rounded-xl transition-all duration-200 flex items-center justify-center gap-2 shadow-lg shadow-brand-500/25 cursor-pointer"
+          >
+            {loading ? (
+              <Loader2 className="w-5 h-5 animate-spin" />
+            ) : (
+              <>
+                {token ? 'Reset Password' : 'Send Reset Link'}
+                <ArrowRight className="w-4 h-4" />
+              </>
+            )}
+          </button>
+        </form>
+
+        <p className="text-center text-sm text-gray-500 dark:text-gray-400 mt-8">
+          <Link to="/login" className="text-brand-600 dark:text-brand-400 hover:text-brand-700 dark:hover:text-brand-300 font-semibold transition-colors">
+            Back to sign in
+          </Link>
+        </p>
+      </div>
+    </div>
+  );
+}
@@ -1,8 +1,9 @@
 import { useState, useEffect, useCallback } from 'react';
 import {
   getProfile,
-  getFolders,
-  updateFolder,
+  getMailboxes,
+  getMailboxFolders,
+  updateMailbox,
   updateTags,
   updateBlacklist,
   updateStartTime,
@@ -10,8 +11,10 @@ import {
   createSchedule,
   deleteSchedule,
   updateProfile,
+  resendVerification,
   deleteAccount,
   type UserProfile,
+  type Mailbox,
   ApiError,
 } from '../api/client';
 import { useAuth } from '../context/AuthContext';
@@ -37,6 +40,7 @@ import {
 
 export default function Settings() {
   const [profile, setProfile] = useState<UserProfile | null>(null);
+  const [mailbox, setMailbox] = useState<Mailbox | null>(null);
   const [folders, setFolders] = useState<string[]>([]);
   const [loading, setLoading] = useState(true);
   const [saving, setSaving] = useState('');
@@ -46,7 +50,7 @@ export default function Settings() {
   const { logout } = useAuth();
   const navigate = useNavigate();
 
-  const [selectedFolder, setSelectedFolder] = useState('');
+  const [selectedFolders, setSelectedFolders] = useState<string[]>([]);
   const [tags, setTags] = useState<string[]>([]);
   const [newTag, setNewTag] = useState('');
   const [blacklist, setBlacklist] = useState<string[]>([]);
@@ -67,7 +71,6 @@ export default function Settings() {
     try {
       const data = await getProfile();
       setProfile(data);
-      setSelectedFolder(data.folder || '');
       setTags(data.tags || []);
       setBlacklist(data.blackListSenders || []);
       setStartTime(data.startTime ? data.startTime.split('T')[0] : '');
@@ -87,8 +90,11 @@ export default function Settings() {
 
   const loadFolders = useCallback(async () => {
     try {
-      const data = await getFolders();
-      setFolders(data);
+      const [first] = await getMailboxes();
+      if (!first) return;
+      setMailbox(first);
+      setSelectedFolders(first.folders || []);
+      setFolders(await getMailboxFolders(first.id));
     } catch {
       /* folders fail silently if IMAP not configured */
     }
@@ -113,6 +119,16 @@ export default function Settings() {
     }
   };
 
+  const handleResend = async () => {
+    setError('');
+    try {
+      await resendVerification();
+      showSuccessMsg(`Confirmation link sent to ${profile?.receivingEmail}`);
+    } catch (err) {
+      setError(err instanceof ApiError ? err.message : 'Could not send the link');
+    }
+  };
+
   const handleDelete = async () => {
     try {
       if (profile?.updateInterval && profile.updateInterval !== '0') {
@@ -162,25 +178,40 @@ export default function Settings() {
           <InputField label="Name" value={name} onChange={setName} placeholder="Jane Doe" />
           <InputField label="Receiving email" value={receivingEmail} onChange={setReceivingEmail} type="email" placeholder="you@gmail.com" />
         </div>
+        {profile && !profile.receivingEmailVerified && (
+          <div className="flex items-center justify-between gap-3 text-sm text-amber-700 dark:text-amber-400 bg-amber-50 dark:bg-amber-950/50 border border-amber-100 dark:border-amber-900/50 px-4 py-3 rounded-xl">
+            <span className="flex items-center gap-2">
+              <AlertTriangle className="w-4 h-4 shrink-0" />
+              Digests are paused until you confirm {profile.receivingEmail}.
+            </span>
+            <button
+              type="button"
+              onClick={handleResend}
+              className="font-medium underline underline-offset-2 whitespace-nowrap"
+            >
+              Resend link
+            </button>
+          </div>
+        )}
         <SaveButton loading={saving === 'Profile'} onClick={() => handleSave('Profile', () => updateProfile({ name, receivingEmail }))} />
       </Section>
 
-      {/* Folder */}
-      <Section title="Email Folder" icon={<FolderOpen className="w-5 h-5" />} description="Select which IMAP folder to scan for emails.">
+      {/* Folders */}
+      <Section title="Email Folders" icon={<FolderOpen className="w-5 h-5" />} description={mailbox ? `Select which IMAP folders of ${mailbox.name} to scan for emails. INBOX is scanned when none are selected.` : 'Select which IMAP folders to scan for emails.'}>
         <select
-          value={selectedFolder}
-          onChange={(e) => setSelectedFolder(e.target.value)}
-          className="w-full px-4 py-3 border border-gray-200 dark:border-gray-800 rounded-xl bg-gray-50 dark:bg-gray-900 text-gray-900 dark:text-white focus:ring-2 focus:ring-brand-500/20 focus:border-brand-500 outline-none transition-all duration-200 cursor-pointer"
+          multiple
+          value={selectedFolders}
+          onChange={(e) => setSelectedFolders(Array.from(e.target.selectedOptions, (o) => o.value))}
+          className="w-full min-h-[8rem] px-4 py-3 border border-gray-200 dark:border-gray-800 rounded-xl bg-gray-50 dark:bg-gray-900 text-gray-900 dark:text-white focus:ring-2 focus:ring-brand-500/20 focus:border-brand-500 outline-none transition-all duration-200 cursor-pointer"
         >
-          <option value="">Select a folder...</option>
-          {folders.map((f) => (
+          {[...new Set([...selectedFolders, ...folders])].map((f) => (
             <option key={f} value={f}>{f}</option>
           ))}
         </select>
         {folders.length === 0 && (
           <p className="text-xs text-gray-400 mt-1">Connect your IMAP email to see available folders.</p>
         )}
-        <SaveButton loading={saving === 'Folder'} onClick={() => handleSave('Folder', () => updateFolder(selectedFolder))} />
+        <SaveButton loading={saving === 'Folders'} onClick={() => mailbox && handleSave('Folders', () => updateMailbox(mailbox.id, { folders: selectedFolders }))} />
       </Section>
 
       {/* Tags */}
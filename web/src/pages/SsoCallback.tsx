@@ -0,0 +1,41 @@
+import { useEffect } from 'react';
+import { useNavigate } from 'react-router-dom';
+import { useAuth } from '../context/AuthContext';
+import type { LoginChallenge } from '../api/client';
+import { Loader2 } from 'lucide-react';
+
+// SsoCallback picks up the tokens the server puts in the URL fragment after
+// a single sign-on login. Users who still need a second factor get a
+// challenge instead, which the login page completes.
+export default function SsoCallback() {
+  const { login } = useAuth();
+  const navigate = useNavigate();
+
+  useEffect(() => {
+    const params = new URLSearchParams(window.location.hash.slice(1));
+    const token = params.get('token');
+    const refreshToken = params.get('refreshToken');
+    const challengeToken = params.get('challengeToken');
+    window.history.replaceState(null, '', window.location.pathname);
+
+    if (challengeToken) {
+      const challenge: LoginChallenge = {
+        challengeToken,
+        expiresIn: Number(params.get('expiresIn')),
+        enrollmentRequired: params.get('enrollmentRequired') === 'true',
+      };
+      navigate('/login', { replace: true, state: { challenge } });
+    } else if (token && refreshToken) {
+      login(token, refreshToken);
+      navigate('/dashboard', { replace: true });
+    } else {
+      navigate('/login', { replace: true });
+    }
+  }, [login, navigate]);
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-white dark:bg-gray-950">
+      <Loader2 className="w-6 h-6 animate-spin text-brand-500" />
+    </div>
+  );
+}
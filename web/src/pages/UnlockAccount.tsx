@@ -0,0 +1,89 @@
+import { useState } from 'react';
+import { Link, useSearchParams } from 'react-router-dom';
+import { unlockAccount, ApiError } from '../api/client';
+import { Mail, Loader2, ArrowRight } from 'lucide-react';
+
+// UnlockAccount is opened from the link in an unlock email. The token is
+// only submitted when the user confirms, so mail scanners that fetch the
+// link do not lift the lockout.
+export default function UnlockAccount() {
+  const [params] = useSearchParams();
+  const token = params.get('token');
+  const [error, setError] = useState('');
+  const [message, setMessage] = useState('');
+  const [loading, setLoading] = useState(false);
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    if (!token) return;
+    setError('');
+    setLoading(true);
+
+    try {
+      const res = await unlockAccount(token);
+      setMessage(res.message);
+    } catch (err) {
+      setError(err instanceof ApiError ? err.message : 'Request failed');
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div className="min-h-screen flex items-center justify-center p-6 bg-white dark:bg-gray-950">
+      <div className="w-full max-w-sm animate-in">
+        <div className="flex items-center gap-3 mb-10">
+          <div className="w-10 h-10 rounded-xl gradient-brand flex items-center justify-center">
+            <Mail className="w-5 h-5 text-white" />
+          </div>
+          <span className="text-xl font-bold text-gray-900 dark:text-white">MailDruid</span>
+        </div>
+
+        <div className="mb-8">
+          <h2 className="text-2xl font-bold text-gray-900 dark:text-white">Unlock your account</h2>
+          <p className="text-gray-500 dark:text-gray-400 mt-2">
+            {token
+              ? 'Only continue if the failed sign-in attempts were yours.'
+              : 'Open this page from the link in your unlock email.'}
+          </p>
+        </div>
+
+        <form onSubmit={handleSubmit} className="space-y-5">
+          {error && (
+            <div className="bg-red-50 dark:bg-red-950/50 text-red-600 dark:text-red-400 text-sm px-4 py-3 rounded-xl border border-red-100 dark:border-red-900/50 animate-in">
+              {error}
+            </div>
+          )}
+          {message && (
+            <div className="bg-emerald-50 dark:bg-emerald-950/50 text-emerald-600 dark:text-emerald-400 text-sm px-4 py-3 rounded-xl border border-emerald-100 dark:border-emerald-900/50 animate-in">
+              {message}
+            </div>
+          )}
+
+          {token && !message && (
+            <button
+              type="submit"
+              disabled={loading}
+              className="w-full py-3 px-4 gradient-brand hover:opacity-90 disabled:opacity-50 text-white font-semibold rounded-xl transition-all duration-200 flex items-center justify-center gap-2 shadow-lg shadow-brand-500/25 cursor-pointer"
+            >
+              {loading ? (
+                <Loader2 className="w-5 h-5 animate-spin" />
+              ) : (
+                <>
+                  Unlock Account
+                  <ArrowRight className="w-4 h-4" />
+                </>
+              )}
+            </button>
+          )}
+        </form>
+
+        <p className="text-center text-sm text-gray-500 dark:text-gray-400 mt-8">
+          <Link to="/login" className="text-brand-600 dark:text-brand-400 hover:text-brand-700 dark:hover:text-brand-300 font-semibold transition-colors">
+            Back to sign in
+          </Link>
+        </p>
+      </div>
+    </div>
+  );
+}